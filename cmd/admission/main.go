@@ -5,23 +5,30 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/go-logr/zapr"
 	"github.com/kyma-project/warden/internal/admission"
 	"github.com/kyma-project/warden/internal/config"
 	"github.com/kyma-project/warden/internal/validate"
 	"github.com/kyma-project/warden/internal/webhook/certs"
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
 	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	ctrlwebhook "sigs.k8s.io/controller-runtime/pkg/webhook"
 )
 
 var (
 	scheme = runtime.NewScheme()
+	// version is set at build time with -ldflags "-X main.version=<version>"
+	version = "dev"
 )
 
 // nolint
@@ -33,7 +40,9 @@ func init() {
 
 func main() {
 	var configPath string
+	var uninstall bool
 	flag.StringVar(&configPath, "config-path", "./hack/config.yaml", "The path to the configuration file.")
+	flag.BoolVar(&uninstall, "uninstall", false, "Delete the webhook configurations of Warden and exit, e.g. in a pre-delete job.")
 	flag.Parse()
 
 	tmpLog, err := zap.NewDevelopment()
@@ -49,68 +58,190 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err := certs.SetupCertSecret(
-		context.Background(),
-		config.Admission.SecretName,
-		config.Admission.SystemNamespace,
-		config.Admission.ServiceName,
-		logger); err != nil {
-		logger.Error("failed to setup certificates and webhook secret", err.Error())
-		os.Exit(1)
+	if uninstall {
+		if err := deleteWebhookConfigurations(config.Admission.WebhookConfig(), logger); err != nil {
+			logger.Error("failed to delete webhook configurations ", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	webhookConfig := config.Admission.WebhookConfig()
+	webhookConfig.Version = version
+	certManager := config.Admission.CertManagerConfig()
+	if certManager.Certificate == "" && webhookConfig.CABundlePath == "" {
+		// the CA bundle follows the secret, also when another process rotates it
+		webhookConfig.CABundleSecret = config.Admission.SecretName
+	}
+	if certManager.Certificate == "" {
+		if err := certs.SetupCertSecret(
+			context.Background(),
+			config.Admission.SecretName,
+			config.Admission.SystemNamespace,
+			webhookConfig.ServingCertificateSANs(),
+			logger); err != nil {
+			logger.Error("failed to setup certificates and webhook secret", err.Error())
+			os.Exit(1)
+		}
 	}
 
 	logrZap := zapr.NewLogger(logger.Desugar())
 
 	mgr, err := manager.New(ctrl.GetConfigOrDie(), manager.Options{
-		Scheme:             scheme,
-		Port:               config.Admission.Port,
-		MetricsBindAddress: ":9090",
-		Logger:             logrZap,
+		Scheme:                 scheme,
+		Port:                   config.Admission.Port,
+		MetricsBindAddress:     ":9090",
+		HealthProbeBindAddress: config.Admission.HealthProbeBindAddress,
+		Logger:                 logrZap,
 	})
 	if err != nil {
 		logger.Error("failed to start manager", err.Error())
 		os.Exit(2)
 	}
 
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(mgr.GetConfig())
+	if err != nil {
+		logger.Error("failed to create discovery client", err.Error())
+		os.Exit(5)
+	}
+	if webhookConfig, err = webhookConfig.NegotiateAdmissionReviewVersions(discoveryClient); err != nil {
+		logger.Warnf("webhook admission review versions aren't negotiated: %s", err.Error())
+	}
+	logger.Infof("webhooks accept admission reviews %v", webhookConfig.AdmissionReviewVersions)
+	if len(webhookConfig.MatchConditions) > 0 {
+		// the webhooks are registered without the conditions when the API server can't evaluate them
+		if webhookConfig, err = webhookConfig.ForServer(discoveryClient); err != nil {
+			logger.Warnf("webhook match conditions are disabled: %s", err.Error())
+		} else if len(webhookConfig.MatchConditions) == 0 {
+			logger.Warn("webhook match conditions are disabled, the API server doesn't support them")
+		}
+	}
+
 	if err := certs.SetupResourcesController(context.TODO(), mgr,
-		config.Admission.ServiceName,
-		config.Admission.SystemNamespace,
+		webhookConfig,
 		config.Admission.SecretName,
+		config.Admission.Webhook.ResyncInterval,
 		logger); err != nil {
 
 		logger.Error("failed to setup webhook resource controller ", err.Error())
 		os.Exit(5)
 	}
 
-	repoFactory := validate.NotaryRepoFactory{Timeout: config.Notary.Timeout}
-	allowedRegistries := validate.ParseAllowedRegistries(config.Notary.AllowedRegistries)
-
-	validatorSvcConfig := validate.ServiceConfig{
-		NotaryConfig:      validate.NotaryConfig{Url: config.Notary.URL},
-		AllowedRegistries: allowedRegistries,
+	if config.Notary.TrustDir != "" && config.Notary.TrustDirMaxAge > 0 {
+		// the cache only saves downloads, so validations work also when it can't be pruned
+		pruned, err := validate.PruneTrustDir(config.Notary.TrustDir, config.Notary.TrustDirMaxAge, time.Now())
+		if err != nil {
+			logger.Error("failed to prune TUF metadata cache ", err.Error())
+		} else {
+			logger.Infof("pruned TUF metadata cache of %d repositories", pruned)
+		}
 	}
-	podValidatorSvc := validate.NewImageValidator(&validatorSvcConfig, repoFactory)
-	validatorSvc := validate.NewPodValidator(podValidatorSvc)
 
-	logger.Info("setting up webhook server")
+	repoFactory := validate.NewNotaryRepoFactory(config.Notary.Timeout)
+	validatorSvcConfig, err := config.Notary.ServiceConfig()
+	if err != nil {
+		logger.Error("invalid notary config ", err.Error())
+		os.Exit(6)
+	}
+	imageValidators, err := validate.NewNamespacedImageValidators(&validatorSvcConfig, config.Notary.ServiceConfigPatches(), validate.WithRepoFactory(repoFactory))
+	if err != nil {
+		logger.Error("invalid notary config ", err.Error())
+		os.Exit(9)
+	}
+	if config.Notary.CacheWarmUp.Enabled {
+		// the admission server has no readiness probe, so admissions may come before the warm-up finished
+		warmer := validate.NewCacheWarmer(mgr.GetAPIReader(), imageValidators, config.Notary.CacheWarmUp.Timeout, config.Notary.CacheWarmUp.Concurrency)
+		if err := mgr.Add(warmer); err != nil {
+			logger.Error("failed to setup cache warm-up ", err.Error())
+			os.Exit(10)
+		}
+	}
+	logger.Infof("setting up webhook server on port %d behind service port %d", config.Admission.Port, webhookConfig.ServicePort())
 	// webhook server setup
 	whs := mgr.GetWebhookServer()
 	whs.CertName = certs.CertFile
 	whs.KeyName = certs.KeyFile
+	// the mounted secret lets the server start, the loader switches to renewed or rotated certificates
+	var loader certs.ServingCertificateLoader = certs.NewSelfManagedCertificateLoader(mgr.GetAPIReader(),
+		types.NamespacedName{Name: config.Admission.SecretName, Namespace: config.Admission.SystemNamespace},
+		certs.DefaultCertificateReloadInterval, logger)
+	if certManager.Certificate != "" {
+		whs.CertName = corev1.TLSCertKey
+		whs.KeyName = corev1.TLSPrivateKeyKey
+		loader = certs.NewSecretCertificateLoader(mgr.GetAPIReader(),
+			types.NamespacedName{Name: certManager.SecretName, Namespace: config.Admission.SystemNamespace},
+			certs.DefaultCertificateReloadInterval, logger)
+	}
+	if config.Admission.CertDir != "" {
+		whs.CertDir = config.Admission.CertDir
+		loader = certs.NewFileCertificateLoader(whs.CertDir, whs.CertName, whs.KeyName, certs.DefaultCertificateFileDebounce, logger)
+	}
+	if err := loader.Load(context.Background()); err != nil {
+		logger.Error("failed to load serving certificate ", err.Error())
+		os.Exit(1)
+	}
+	if err := mgr.Add(loader); err != nil {
+		logger.Error("failed to setup serving certificate reload ", err.Error())
+		os.Exit(1)
+	}
+	whs.TLSOpts = append(whs.TLSOpts, loader.TLSOpt, config.Admission.TLSConfig().TLSOpt)
+	if webhookConfig.CABundleSecret != "" {
+		if err := certs.SetupCABundleController(mgr, webhookConfig, loader, logger); err != nil {
+			logger.Error("failed to setup CA bundle controller ", err.Error())
+			os.Exit(5)
+		}
+	}
+
+	// the audit log is opened after the other setup steps, their os.Exit would skip closing it
+	var auditSink validate.AuditSink
+	closeAuditSink := func() {}
+	if config.Admission.AuditLog.Path != "" {
+		fileSink, err := validate.NewFileAuditSink(config.Admission.AuditLog.Path, config.Admission.AuditLog.MaxSize, config.Admission.AuditLog.MaxBackups)
+		if err != nil {
+			logger.Error("failed to open audit log ", err.Error())
+			os.Exit(8)
+		}
+		closeAuditSink = func() {
+			if err := fileSink.Close(); err != nil {
+				logger.Error("failed to close audit log ", err.Error())
+			}
+		}
+		auditSink = fileSink
+	}
+	validatorSvc := validate.NewAuditedPodValidator(imageValidators, auditSink)
 
 	whs.Register(admission.ValidationPath, &ctrlwebhook.Admission{
-		Handler: admission.NewValidationWebhook(),
+		Handler: admission.NewValidationWebhook(mgr.GetClient(), validatorSvc, config.Admission.Timeout, logger.With("webhook", "validation")),
 	})
 
 	whs.Register(admission.DefaultingPath, &ctrlwebhook.Admission{
 		Handler: admission.NewDefaultingWebhook(mgr.GetClient(), validatorSvc, config.Admission.Timeout, logger.With("webhook", "defaulting")),
 	})
 
+	whs.Register(admission.WorkloadValidationPath, &ctrlwebhook.Admission{
+		Handler: admission.NewWorkloadValidationWebhook(mgr.GetClient(), validatorSvc, config.Admission.Timeout, logger.With("webhook", "workloads")),
+	})
+
+	whs.Register(admission.NamespaceValidationPath, &ctrlwebhook.Admission{
+		Handler: admission.NewNamespaceValidationWebhook(config.Admission.ProtectionAdminGroups),
+	})
+
 	logrZap.Info("starting the controller-manager")
 	// start the server manager
 	err = mgr.Start(ctrl.SetupSignalHandler())
+	closeAuditSink()
 	if err != nil {
 		logrZap.Error(err, "failed to start controller-manager")
 		os.Exit(1)
 	}
 }
+
+// deleteWebhookConfigurations runs the uninstall mode, the config has the names of the webhook configurations.
+func deleteWebhookConfigurations(webhookConfig certs.WebhookConfig, logger *zap.SugaredLogger) error {
+	client, err := ctrlclient.New(ctrl.GetConfigOrDie(), ctrlclient.Options{Scheme: scheme})
+	if err != nil {
+		return errors.Wrap(err, "failed to create client")
+	}
+	logger.Info("deleting webhook configurations")
+	return certs.DeleteWebhookConfigurations(context.Background(), client, webhookConfig)
+}
@@ -90,13 +90,20 @@ func main() {
 		os.Exit(1)
 	}
 
-	repoFactory := validate.NotaryRepoFactory{Timeout: config.Notary.Timeout}
-	allowedRegistries := validate.ParseAllowedRegistries(config.Notary.AllowedRegistries)
-
-	notaryConfig := &validate.ServiceConfig{NotaryConfig: validate.NotaryConfig{Url: config.Notary.URL}, AllowedRegistries: allowedRegistries}
+	repoFactory := validate.NewNotaryRepoFactory(config.Notary.Timeout)
+	// the same config as in admission, so pods admitted by it aren't flagged by the revalidation
+	notaryConfig, err := config.Notary.ServiceConfig()
+	if err != nil {
+		setupLog.Error(err, "invalid notary config")
+		os.Exit(1)
+	}
 
-	imageValidator := validate.NewImageValidator(notaryConfig, repoFactory)
-	podValidator := validate.NewPodValidator(imageValidator)
+	imageValidators, err := validate.NewNamespacedImageValidators(&notaryConfig, config.Notary.ServiceConfigPatches(), validate.WithRepoFactory(repoFactory))
+	if err != nil {
+		setupLog.Error(err, "invalid notary config")
+		os.Exit(1)
+	}
+	podValidator := validate.NewNamespacedPodValidator(imageValidators)
 
 	if err = (&controllers.PodReconciler{
 		Client:    mgr.GetClient(),
@@ -116,6 +123,29 @@ func main() {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	if config.Notary.CacheWarmUp.Enabled {
+		warmer := validate.NewCacheWarmer(mgr.GetAPIReader(), imageValidators, config.Notary.CacheWarmUp.Timeout, config.Notary.CacheWarmUp.Concurrency)
+		if err := mgr.Add(warmer); err != nil {
+			setupLog.Error(err, "unable to set up cache warm-up")
+			os.Exit(1)
+		}
+		if err := mgr.AddReadyzCheck("cache-warm-up", warmer.Check); err != nil {
+			setupLog.Error(err, "unable to set up cache warm-up ready check")
+			os.Exit(1)
+		}
+	}
+	// notary is checked only with the canary, the check logs the outages unless it gates the readiness
+	if config.Notary.HealthCanaryGUN != "" {
+		notaryHealth := validate.NewNotaryHealthChecker(repoFactory, notaryConfig.NotaryConfig, config.Notary.HealthCanaryGUN, config.Notary.HealthTimeout)
+		check := notaryHealth.WarningCheck
+		if config.Notary.HealthGatesReadiness {
+			check = notaryHealth.Check
+		}
+		if err := mgr.AddReadyzCheck("notary", check); err != nil {
+			setupLog.Error(err, "unable to set up notary ready check")
+			os.Exit(1)
+		}
+	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
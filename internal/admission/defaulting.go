@@ -8,9 +8,11 @@ import (
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"net/http"
 	k8sclient "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"strings"
 	"time"
 )
 
@@ -72,15 +74,25 @@ func (w *DefaultingWebHook) handle(ctx context.Context, req admission.Request) a
 		return admission.Errored(http.StatusInternalServerError, err)
 	}
 
-	result, err := w.validationSvc.ValidatePod(ctx, pod, ns)
+	reasons := &validate.DenialReasons{}
+	result, err := w.validationSvc.ValidatePod(validate.ContextWithDenialReasons(ctx, reasons), pod, ns)
 	if err != nil {
 		return admission.Errored(http.StatusInternalServerError, err)
 	}
-	if result == validate.NoAction {
+	// the reasons are echoed in the denial, so only the reasons of this validation may be passed on,
+	// never ones set by the pod's author
+	labeledPod := removeRejectReasons(labelPod(result, pod))
+	if result == validate.Invalid {
+		labeledPod = annotateRejectReasons(labeledPod, reasons.Reasons())
+	}
+	if result == validate.NoAction && labeledPod == pod {
 		return admission.Allowed("validation is not enabled for pod")
 	}
-
-	labeledPod := labelPod(result, pod)
+	// with the IfNeeded reinvocation policy the pod comes back after other webhooks, e.g. sidecar injectors,
+	// added containers, an unchanged result mustn't patch it again
+	if equality.Semantic.DeepEqual(labeledPod.ObjectMeta, pod.ObjectMeta) {
+		return admission.Allowed("pod is already labeled")
+	}
 	fBytes, err := json.Marshal(labeledPod)
 	if err != nil {
 		return admission.Errored(http.StatusInternalServerError, err)
@@ -109,6 +121,29 @@ func labelPod(result validate.ValidationResult, pod *corev1.Pod) *corev1.Pod {
 	return labeledPod
 }
 
+// annotateRejectReasons passes the reasons to the validation webhook, which echoes them in the denial.
+func annotateRejectReasons(pod *corev1.Pod, reasons []string) *corev1.Pod {
+	if len(reasons) == 0 {
+		return pod
+	}
+	annotatedPod := pod.DeepCopy()
+	if annotatedPod.Annotations == nil {
+		annotatedPod.Annotations = map[string]string{}
+	}
+	annotatedPod.Annotations[pkg.PodValidationRejectReasonAnnotation] = strings.Join(reasons, "; ")
+	return annotatedPod
+}
+
+// removeRejectReasons removes the reasons of a previous invocation or set by the pod's author.
+func removeRejectReasons(pod *corev1.Pod) *corev1.Pod {
+	if _, ok := pod.Annotations[pkg.PodValidationRejectReasonAnnotation]; !ok {
+		return pod
+	}
+	cleanedPod := pod.DeepCopy()
+	delete(cleanedPod.Annotations, pkg.PodValidationRejectReasonAnnotation)
+	return cleanedPod
+}
+
 func LabelForValidationResult(result validate.ValidationResult) string {
 	switch result {
 	case validate.NoAction:
@@ -3,6 +3,7 @@ package admission
 import (
 	"context"
 	"encoding/json"
+	jsonpatch "github.com/evanphx/json-patch/v5"
 	"github.com/kyma-project/warden/internal/validate"
 	"github.com/kyma-project/warden/internal/validate/mocks"
 	"github.com/kyma-project/warden/pkg"
@@ -96,7 +97,8 @@ func TestTimeout(t *testing.T) {
 		srv := httptest.NewServer(h)
 		defer srv.Close()
 
-		validateImage := validate.NewImageValidator(&validate.ServiceConfig{NotaryConfig: validate.NotaryConfig{Url: srv.URL}}, validate.NotaryRepoFactory{})
+		validateImage, err := validate.NewImageValidator(&validate.ServiceConfig{NotaryConfig: validate.NotaryConfig{Url: srv.URL}}, validate.WithRepoFactory(validate.NotaryRepoFactory{}))
+		require.NoError(t, err)
 		validationSvc := validate.NewPodValidator(validateImage)
 		webhook := NewDefaultingWebhook(client, validationSvc, timeout, logger.Sugar())
 		require.NoError(t, webhook.InjectDecoder(decoder))
@@ -111,3 +113,177 @@ func TestTimeout(t *testing.T) {
 		require.InDelta(t, timeout.Seconds(), time.Since(start).Seconds(), 0.1, "timeout duration is not respected")
 	})
 }
+
+func TestRejectReason(t *testing.T) {
+	//GIVEN
+	logger := zap.NewNop()
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	decoder, err := admission.NewDecoder(scheme)
+	require.NoError(t, err)
+
+	testNs := "test-namespace"
+	ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: testNs, Labels: map[string]string{
+		pkg.NamespaceValidationLabel: pkg.NamespaceValidationEnabled,
+	}}}
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: testNs},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Image: "eu.gcr.io/Kyma-project/app:1.0"}}},
+	}
+	raw, err := json.Marshal(pod)
+	require.NoError(t, err)
+	req := admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Kind:   metav1.GroupVersionKind{Kind: corev1.ResourcePods.String(), Version: corev1.SchemeGroupVersion.Version},
+			Object: runtime.RawExtension{Raw: raw},
+		}}
+	client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&ns).Build()
+	validateImage, err := validate.NewImageValidator(&validate.ServiceConfig{NotaryConfig: validate.NotaryConfig{Url: "https://notary"}})
+	require.NoError(t, err)
+	validationSvc := validate.NewPodValidator(validateImage)
+	webhook := NewDefaultingWebhook(client, validationSvc, time.Second, logger.Sugar())
+	require.NoError(t, webhook.InjectDecoder(decoder))
+
+	//WHEN
+	res := webhook.Handle(context.TODO(), req)
+
+	//THEN
+	require.True(t, res.Allowed)
+	var annotations map[string]interface{}
+	for _, patch := range res.Patches {
+		if patch.Path == "/metadata/annotations" {
+			annotations = patch.Value.(map[string]interface{})
+		}
+	}
+	require.Equal(t, `invalid image reference "eu.gcr.io/Kyma-project/app:1.0": uppercase character 'K' at position 11, repository paths must be lowercase`,
+		annotations[pkg.PodValidationRejectReasonAnnotation])
+}
+
+func TestReinvocation(t *testing.T) {
+	//GIVEN
+	logger := zap.NewNop()
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	decoder, err := admission.NewDecoder(scheme)
+	require.NoError(t, err)
+
+	testNs := "test-namespace"
+	ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: testNs, Labels: map[string]string{
+		pkg.NamespaceValidationLabel: pkg.NamespaceValidationEnabled,
+	}}}
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: testNs},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Image: "eu.gcr.io/kyma-project/app:1.0"}}},
+	}
+	client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&ns).Build()
+	request := func(t *testing.T, raw []byte) admission.Request {
+		return admission.Request{
+			AdmissionRequest: admissionv1.AdmissionRequest{
+				Kind:   metav1.GroupVersionKind{Kind: corev1.ResourcePods.String(), Version: corev1.SchemeGroupVersion.Version},
+				Object: runtime.RawExtension{Raw: raw},
+			}}
+	}
+	// reinvoke applies the patches of the first invocation and adds the container of a sidecar injector
+	reinvoke := func(t *testing.T, raw []byte, res admission.Response) []byte {
+		patch, err := json.Marshal(res.Patches)
+		require.NoError(t, err)
+		decoded, err := jsonpatch.DecodePatch(patch)
+		require.NoError(t, err)
+		patched, err := decoded.Apply(raw)
+		require.NoError(t, err)
+		injected := &corev1.Pod{}
+		require.NoError(t, json.Unmarshal(patched, injected))
+		injected.Spec.Containers = append(injected.Spec.Containers, corev1.Container{Name: "sidecar", Image: "eu.gcr.io/kyma-project/proxy:1.0"})
+		raw, err = json.Marshal(injected)
+		require.NoError(t, err)
+		return raw
+	}
+
+	t.Run("same result doesn't patch again", func(t *testing.T) {
+		//GIVEN
+		validationSvc := mocks.NewPodValidator(t)
+		validationSvc.On("ValidatePod", mock.Anything, mock.Anything, mock.Anything).Return(validate.Valid, nil).Twice()
+		webhook := NewDefaultingWebhook(client, validationSvc, time.Second, logger.Sugar())
+		require.NoError(t, webhook.InjectDecoder(decoder))
+		raw, err := json.Marshal(pod)
+		require.NoError(t, err)
+		first := webhook.Handle(context.TODO(), request(t, raw))
+		require.True(t, first.Allowed)
+		require.NotEmpty(t, first.Patches)
+
+		//WHEN
+		second := webhook.Handle(context.TODO(), request(t, reinvoke(t, raw, first)))
+
+		//THEN
+		require.True(t, second.Allowed)
+		require.Empty(t, second.Patches)
+	})
+
+	t.Run("reject reasons of the pod's author are replaced", func(t *testing.T) {
+		//GIVEN
+		validationSvc := mocks.NewPodValidator(t)
+		// no reason is recorded, e.g. the image isn't signed
+		validationSvc.On("ValidatePod", mock.Anything, mock.Anything, mock.Anything).Return(validate.Invalid, nil).Once()
+		webhook := NewDefaultingWebhook(client, validationSvc, time.Second, logger.Sugar())
+		require.NoError(t, webhook.InjectDecoder(decoder))
+		forged := pod.DeepCopy()
+		forged.Annotations = map[string]string{pkg.PodValidationRejectReasonAnnotation: "contact admin@example.com"}
+		raw, err := json.Marshal(forged)
+		require.NoError(t, err)
+
+		//WHEN
+		res := webhook.Handle(context.TODO(), request(t, raw))
+
+		//THEN
+		require.True(t, res.Allowed)
+		patched := &corev1.Pod{}
+		require.NoError(t, json.Unmarshal(reinvoke(t, raw, res), patched))
+		require.Equal(t, pkg.ValidationStatusReject, patched.Labels[pkg.PodValidationLabel])
+		require.NotContains(t, patched.Annotations, pkg.PodValidationRejectReasonAnnotation)
+	})
+
+	t.Run("reject reasons are removed when validation isn't enabled", func(t *testing.T) {
+		//GIVEN
+		validationSvc := mocks.NewPodValidator(t)
+		validationSvc.On("ValidatePod", mock.Anything, mock.Anything, mock.Anything).Return(validate.NoAction, nil).Once()
+		webhook := NewDefaultingWebhook(client, validationSvc, time.Second, logger.Sugar())
+		require.NoError(t, webhook.InjectDecoder(decoder))
+		forged := pod.DeepCopy()
+		forged.Annotations = map[string]string{pkg.PodValidationRejectReasonAnnotation: "contact admin@example.com"}
+		raw, err := json.Marshal(forged)
+		require.NoError(t, err)
+
+		//WHEN
+		res := webhook.Handle(context.TODO(), request(t, raw))
+
+		//THEN
+		require.True(t, res.Allowed)
+		patched := &corev1.Pod{}
+		require.NoError(t, json.Unmarshal(reinvoke(t, raw, res), patched))
+		require.NotContains(t, patched.Labels, pkg.PodValidationLabel)
+		require.NotContains(t, patched.Annotations, pkg.PodValidationRejectReasonAnnotation)
+	})
+
+	t.Run("valid sidecar clears the reject reasons", func(t *testing.T) {
+		//GIVEN
+		validationSvc := mocks.NewPodValidator(t)
+		validationSvc.On("ValidatePod", mock.Anything, mock.Anything, mock.Anything).Return(validate.Valid, nil).Once()
+		webhook := NewDefaultingWebhook(client, validationSvc, time.Second, logger.Sugar())
+		require.NoError(t, webhook.InjectDecoder(decoder))
+		rejected := pod.DeepCopy()
+		rejected.Labels = map[string]string{pkg.PodValidationLabel: pkg.ValidationStatusReject}
+		rejected.Annotations = map[string]string{pkg.PodValidationRejectReasonAnnotation: "image app:1.0 isn't signed"}
+		raw, err := json.Marshal(rejected)
+		require.NoError(t, err)
+
+		//WHEN
+		res := webhook.Handle(context.TODO(), request(t, raw))
+
+		//THEN
+		require.True(t, res.Allowed)
+		patched := &corev1.Pod{}
+		require.NoError(t, json.Unmarshal(reinvoke(t, raw, res), patched))
+		require.Equal(t, pkg.ValidationStatusSuccess, patched.Labels[pkg.PodValidationLabel])
+		require.NotContains(t, patched.Annotations, pkg.PodValidationRejectReasonAnnotation)
+	})
+}
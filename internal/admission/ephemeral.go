@@ -0,0 +1,98 @@
+package admission
+
+import (
+	"context"
+	"github.com/kyma-project/warden/internal/validate"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net/http"
+	k8sclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"strings"
+)
+
+const (
+	// EphemeralContainersSubResource is updated by kubectl debug, the defaulting webhook isn't called for it,
+	// so the images of the added containers are validated by the validation webhook.
+	EphemeralContainersSubResource = "ephemeralcontainers"
+)
+
+func (w *ValidationWebhook) handleEphemeralContainers(ctx context.Context, req admission.Request) admission.Response {
+	ctxTimeout, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+
+	var resp admission.Response
+	done := make(chan bool)
+	go func() {
+		resp = w.validateEphemeralContainers(ctxTimeout, req)
+		done <- true
+	}()
+
+	select {
+	case <-done:
+	case <-ctxTimeout.Done():
+		if err := ctxTimeout.Err(); err != nil {
+			return admission.Errored(http.StatusRequestTimeout, err)
+		}
+	}
+	return resp
+}
+
+func (w *ValidationWebhook) validateEphemeralContainers(ctx context.Context, req admission.Request) admission.Response {
+	pod, oldPod := &corev1.Pod{}, &corev1.Pod{}
+	if err := w.decoder.Decode(req, pod); err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if err := w.decoder.DecodeRaw(req.OldObject, oldPod); err != nil {
+		return admission.Errored(http.StatusInternalServerError, errors.Wrap(err, "while decoding old pod"))
+	}
+	added := addedEphemeralContainers(oldPod, pod)
+	if len(added) == 0 {
+		return admission.Allowed("no ephemeral containers added")
+	}
+
+	ns := &corev1.Namespace{}
+	if err := w.client.Get(ctx, k8sclient.ObjectKey{Name: pod.Namespace}, ns); err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	// only the added containers are validated, the others were validated with the pod
+	debugged := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        pod.Name,
+			Namespace:   pod.Namespace,
+			Labels:      pod.Labels,
+			Annotations: pod.Annotations,
+		},
+		Spec: corev1.PodSpec{EphemeralContainers: added},
+	}
+	reasons := &validate.DenialReasons{}
+	result, err := w.validationSvc.ValidatePod(validate.ContextWithDenialReasons(ctx, reasons), debugged, ns)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if result != validate.Invalid {
+		return admission.Allowed("")
+	}
+
+	w.logger.Infof("ephemeral containers were rejected: %s, %s", pod.Name, pod.Namespace)
+	if len(reasons.Reasons()) > 0 {
+		return admission.Denied("Ephemeral container images validation failed: " + strings.Join(reasons.Reasons(), "; "))
+	}
+	return admission.Denied("Ephemeral container images validation failed")
+}
+
+func addedEphemeralContainers(oldPod, pod *corev1.Pod) []corev1.EphemeralContainer {
+	existing := map[string]bool{}
+	for _, c := range oldPod.Spec.EphemeralContainers {
+		existing[c.Name] = true
+	}
+	var added []corev1.EphemeralContainer
+	for _, c := range pod.Spec.EphemeralContainers {
+		if !existing[c.Name] {
+			added = append(added, c)
+		}
+	}
+	return added
+}
@@ -0,0 +1,83 @@
+package admission
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/kyma-project/warden/internal/validate"
+	"github.com/kyma-project/warden/internal/validate/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"testing"
+	"time"
+)
+
+func TestValidationWebhook_EphemeralContainers(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	decoder, err := admission.NewDecoder(scheme)
+	require.NoError(t, err)
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-namespace"}}
+	client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(ns).Build()
+	raw, err := os.ReadFile(filepath.Join("testData", "ephemeralcontainers-review.json"))
+	require.NoError(t, err)
+	review := &admissionv1.AdmissionReview{}
+	require.NoError(t, json.Unmarshal(raw, review))
+	req := admission.Request{AdmissionRequest: *review.Request}
+	onlyAdded := mock.MatchedBy(func(pod *corev1.Pod) bool {
+		return pod.Name == "shop" && len(pod.Spec.Containers) == 0 && len(pod.Spec.EphemeralContainers) == 1 &&
+			pod.Spec.EphemeralContainers[0].Image == "docker.io/attacker/shell:latest"
+	})
+
+	t.Run("unsigned debug image is rejected", func(t *testing.T) {
+		//GIVEN
+		validationSvc := mocks.NewPodValidator(t)
+		validationSvc.On("ValidatePod", mock.Anything, onlyAdded, mock.Anything).Return(validate.Invalid, nil).Once()
+		webhook := NewValidationWebhook(client, validationSvc, time.Second, zap.NewNop().Sugar())
+		require.NoError(t, webhook.InjectDecoder(decoder))
+
+		//WHEN
+		res := webhook.Handle(context.TODO(), req)
+
+		//THEN
+		require.False(t, res.Allowed)
+		require.Equal(t, "Ephemeral container images validation failed", string(res.Result.Reason))
+	})
+
+	t.Run("signed debug image is allowed", func(t *testing.T) {
+		//GIVEN
+		validationSvc := mocks.NewPodValidator(t)
+		validationSvc.On("ValidatePod", mock.Anything, onlyAdded, mock.Anything).Return(validate.Valid, nil).Once()
+		webhook := NewValidationWebhook(client, validationSvc, time.Second, zap.NewNop().Sugar())
+		require.NoError(t, webhook.InjectDecoder(decoder))
+
+		//WHEN
+		res := webhook.Handle(context.TODO(), req)
+
+		//THEN
+		require.True(t, res.Allowed)
+	})
+
+	t.Run("update without added containers", func(t *testing.T) {
+		//GIVEN
+		validationSvc := mocks.NewPodValidator(t)
+		webhook := NewValidationWebhook(client, validationSvc, time.Second, zap.NewNop().Sugar())
+		require.NoError(t, webhook.InjectDecoder(decoder))
+		unchanged := req
+		unchanged.OldObject = req.Object
+
+		//WHEN
+		res := webhook.Handle(context.TODO(), unchanged)
+
+		//THEN
+		require.True(t, res.Allowed)
+	})
+}
@@ -0,0 +1,65 @@
+package admission
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/kyma-project/warden/internal/validate"
+	"github.com/kyma-project/warden/pkg"
+	"github.com/pkg/errors"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"net/http"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	NamespaceValidationPath = "/validation/namespaces"
+)
+
+// DefaultProtectionAdminGroups may disable the validation of namespaces when no groups are configured.
+var DefaultProtectionAdminGroups = []string{"system:masters"}
+
+// NamespaceValidationWebhook rejects disabling the validation of a namespace by users outside the admin groups,
+// so namespace admins can't sidestep the validation of their pods.
+type NamespaceValidationWebhook struct {
+	adminGroups []string
+}
+
+func NewNamespaceValidationWebhook(adminGroups []string) *NamespaceValidationWebhook {
+	if len(adminGroups) == 0 {
+		adminGroups = DefaultProtectionAdminGroups
+	}
+	return &NamespaceValidationWebhook{adminGroups: adminGroups}
+}
+
+func (w *NamespaceValidationWebhook) Handle(_ context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Update {
+		return admission.Allowed("")
+	}
+
+	oldNs, ns := &corev1.Namespace{}, &corev1.Namespace{}
+	if err := json.Unmarshal(req.OldObject.Raw, oldNs); err != nil {
+		return admission.Errored(http.StatusBadRequest, errors.Wrap(err, "while decoding old namespace"))
+	}
+	if err := json.Unmarshal(req.Object.Raw, ns); err != nil {
+		return admission.Errored(http.StatusBadRequest, errors.Wrap(err, "while decoding namespace"))
+	}
+
+	if !validate.IsValidationEnabledForNS(oldNs) || validate.IsValidationEnabledForNS(ns) || w.isAdmin(req.UserInfo.Groups) {
+		return admission.Allowed("")
+	}
+	return admission.Denied(fmt.Sprintf("only members of %v can disable the validation of namespace %s with the %s label",
+		w.adminGroups, req.Name, pkg.NamespaceValidationLabel))
+}
+
+func (w *NamespaceValidationWebhook) isAdmin(groups []string) bool {
+	for _, group := range groups {
+		for _, admin := range w.adminGroups {
+			if group == admin {
+				return true
+			}
+		}
+	}
+	return false
+}
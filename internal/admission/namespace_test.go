@@ -0,0 +1,149 @@
+package admission
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-logr/logr"
+	"github.com/kyma-project/warden/pkg"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"net/http"
+	"net/http/httptest"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"strings"
+	"testing"
+)
+
+func TestNamespaceValidationWebhook(t *testing.T) {
+	namespace := func(labels map[string]string) runtime.RawExtension {
+		raw, err := json.Marshal(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop", Labels: labels}})
+		require.NoError(t, err)
+		return runtime.RawExtension{Raw: raw}
+	}
+	enabled := map[string]string{pkg.NamespaceValidationLabel: pkg.NamespaceValidationEnabled}
+	disabled := map[string]string{pkg.NamespaceValidationLabel: "disabled"}
+
+	tests := []struct {
+		name      string
+		operation admissionv1.Operation
+		groups    []string
+		oldLabels map[string]string
+		labels    map[string]string
+		allowed   bool
+	}{
+		{
+			name:      "removal by a user is rejected",
+			operation: admissionv1.Update,
+			groups:    []string{"system:authenticated"},
+			oldLabels: enabled,
+			allowed:   false,
+		},
+		{
+			name:      "disabling by a user is rejected",
+			operation: admissionv1.Update,
+			groups:    []string{"system:authenticated"},
+			oldLabels: enabled,
+			labels:    disabled,
+			allowed:   false,
+		},
+		{
+			name:      "removal by an admin is allowed",
+			operation: admissionv1.Update,
+			groups:    []string{"system:authenticated", "system:masters"},
+			oldLabels: enabled,
+			allowed:   true,
+		},
+		{
+			name:      "keeping the label is allowed",
+			operation: admissionv1.Update,
+			oldLabels: enabled,
+			labels:    map[string]string{pkg.NamespaceValidationLabel: pkg.NamespaceValidationEnabled, "team": "shop"},
+			allowed:   true,
+		},
+		{
+			name:      "adding the label is allowed",
+			operation: admissionv1.Update,
+			labels:    enabled,
+			allowed:   true,
+		},
+		{
+			name:      "other operations are allowed",
+			operation: admissionv1.Delete,
+			oldLabels: enabled,
+			allowed:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//GIVEN
+			webhook := NewNamespaceValidationWebhook(nil)
+			req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: tt.operation,
+				Name:      "shop",
+				UserInfo:  authenticationv1.UserInfo{Username: "dev", Groups: tt.groups},
+				OldObject: namespace(tt.oldLabels),
+				Object:    namespace(tt.labels),
+			}}
+
+			//WHEN
+			res := webhook.Handle(context.TODO(), req)
+
+			//THEN
+			require.Equal(t, tt.allowed, res.Allowed)
+			if !tt.allowed {
+				require.Equal(t, "only members of [system:masters] can disable the validation of namespace shop with the "+
+					pkg.NamespaceValidationLabel+" label", string(res.Result.Reason))
+			}
+		})
+	}
+
+	t.Run("configured admin groups", func(t *testing.T) {
+		webhook := NewNamespaceValidationWebhook([]string{"kyma:admins"})
+		req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Update,
+			Name:      "shop",
+			UserInfo:  authenticationv1.UserInfo{Groups: []string{"system:masters"}},
+			OldObject: namespace(enabled),
+			Object:    namespace(nil),
+		}}
+
+		res := webhook.Handle(context.TODO(), req)
+
+		require.False(t, res.Allowed)
+	})
+}
+
+func TestNamespaceValidationWebhook_AdmissionReviewVersions(t *testing.T) {
+	raw, err := json.Marshal(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop",
+		Labels: map[string]string{pkg.NamespaceValidationLabel: pkg.NamespaceValidationEnabled}}})
+	require.NoError(t, err)
+	handler := &admission.Webhook{Handler: NewNamespaceValidationWebhook(nil)}
+	require.NoError(t, handler.InjectLogger(logr.Discard()))
+
+	for _, version := range []string{"v1", "v1beta1"} {
+		t.Run(version, func(t *testing.T) {
+			//GIVEN
+			review := `{"apiVersion":"admission.k8s.io/` + version + `","kind":"AdmissionReview","request":{"uid":"42",` +
+				`"operation":"UPDATE","name":"shop","userInfo":{"groups":["system:authenticated"]},` +
+				`"oldObject":` + string(raw) + `,"object":{"metadata":{"name":"shop"}}}}`
+			req := httptest.NewRequest(http.MethodPost, NamespaceValidationPath, strings.NewReader(review))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+
+			//WHEN
+			handler.ServeHTTP(rec, req)
+
+			//THEN
+			require.Equal(t, http.StatusOK, rec.Code)
+			res := &admissionv1.AdmissionReview{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), res))
+			require.Equal(t, "admission.k8s.io/"+version, res.APIVersion)
+			require.Equal(t, "42", string(res.Response.UID))
+			require.False(t, res.Response.Allowed)
+		})
+	}
+}
@@ -2,30 +2,54 @@ package admission
 
 import (
 	"context"
+	"github.com/kyma-project/warden/internal/validate"
 	"github.com/kyma-project/warden/pkg"
 	"github.com/pkg/errors"
+	"go.uber.org/zap"
 	admissionv1 "k8s.io/api/admission/v1"
 	corev1 "k8s.io/api/core/v1"
 	"net/http"
+	"strconv"
+	"unicode/utf8"
+
+	k8sclient "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"time"
 )
 
 const (
 	ValidationPath = "/validation/pods"
+	// maxRejectReasonLength bounds the reject reason shown in the denial, the annotation may be set by anyone who creates the pod.
+	maxRejectReasonLength = 512
 )
 
 type ValidationWebhook struct {
-	decoder *admission.Decoder
+	validationSvc validate.PodValidator
+	timeout       time.Duration
+	client        k8sclient.Client
+	decoder       *admission.Decoder
+	logger        *zap.SugaredLogger
 }
 
-func NewValidationWebhook() *ValidationWebhook {
-	return &ValidationWebhook{}
+func NewValidationWebhook(client k8sclient.Client, validationSvc validate.PodValidator, timeout time.Duration, logger *zap.SugaredLogger) *ValidationWebhook {
+	return &ValidationWebhook{
+		client:        client,
+		validationSvc: validationSvc,
+		logger:        logger,
+		timeout:       timeout,
+	}
 }
 
-func (w *ValidationWebhook) Handle(_ context.Context, req admission.Request) admission.Response {
+func (w *ValidationWebhook) Handle(ctx context.Context, req admission.Request) admission.Response {
 	if req.Operation == admissionv1.Delete {
+		// deletions are observed only for the audit trail of who removed the pods, they are always allowed
+		w.logger.With("name", req.Name, "namespace", req.Namespace, "user", req.UserInfo.Username, "uid", string(req.UID)).
+			Info("pod deletion observed")
 		return admission.Allowed("")
 	}
+	if req.SubResource == EphemeralContainersSubResource {
+		return w.handleEphemeralContainers(ctx, req)
+	}
 
 	if req.Resource.Resource != corev1.ResourcePods.String() {
 		return admission.Errored(http.StatusBadRequest,
@@ -46,9 +70,24 @@ func (w *ValidationWebhook) Handle(_ context.Context, req admission.Request) adm
 
 	}
 
+	if reason := pod.Annotations[pkg.PodValidationRejectReasonAnnotation]; reason != "" {
+		return admission.Denied("Pod images validation failed: " + quoteRejectReason(reason))
+	}
 	return admission.Denied("Pod images validation failed")
 }
 
+// quoteRejectReason quotes the reason, so it can't forge the rest of the message, and truncates it at a rune boundary.
+func quoteRejectReason(reason string) string {
+	if len(reason) <= maxRejectReasonLength {
+		return strconv.Quote(reason)
+	}
+	end := maxRejectReasonLength
+	for end > 0 && !utf8.RuneStart(reason[end]) {
+		end--
+	}
+	return strconv.Quote(reason[:end]) + "..."
+}
+
 func (w *ValidationWebhook) InjectDecoder(decoder *admission.Decoder) error {
 	w.decoder = decoder
 	return nil
@@ -6,21 +6,27 @@ import (
 	"github.com/kyma-project/warden/pkg"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	"net/http"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"testing"
+	"time"
 )
 
 func TestValidationWebhook(t *testing.T) {
 	scheme := runtime.NewScheme()
 	decoder, err := admission.NewDecoder(scheme)
 	require.NoError(t, err)
-	webhook := NewValidationWebhook()
+	webhook := NewValidationWebhook(nil, nil, time.Second, zap.NewNop().Sugar())
 	require.NoError(t, webhook.InjectDecoder(decoder))
 
 	testCases := []struct {
@@ -40,6 +46,48 @@ func TestValidationWebhook(t *testing.T) {
 			expectedStatus:  int32(http.StatusForbidden),
 			expectedMessage: "images validation failed",
 		},
+		{
+			name: "Pod should be rejected with the reason",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-pod",
+					Labels: map[string]string{
+						pkg.PodValidationLabel: pkg.ValidationStatusReject,
+					},
+					Annotations: map[string]string{
+						pkg.PodValidationRejectReasonAnnotation: `invalid image reference "Nginx:1.23": uppercase character 'N' at position 1, repository paths must be lowercase`,
+					}},
+			},
+			expectedStatus:  int32(http.StatusForbidden),
+			expectedMessage: `Pod images validation failed: "invalid image reference \"Nginx:1.23\": uppercase character 'N' at position 1`,
+		},
+		{
+			name: "Pod should be rejected with the quoted reason",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-pod",
+					Labels: map[string]string{
+						pkg.PodValidationLabel: pkg.ValidationStatusReject,
+					},
+					Annotations: map[string]string{
+						pkg.PodValidationRejectReasonAnnotation: "unsigned\nPod images validation succeeded",
+					}},
+			},
+			expectedStatus:  int32(http.StatusForbidden),
+			expectedMessage: `Pod images validation failed: "unsigned\nPod images validation succeeded"`,
+		},
+		{
+			name: "Pod should be rejected with the truncated reason",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-pod",
+					Labels: map[string]string{
+						pkg.PodValidationLabel: pkg.ValidationStatusReject,
+					},
+					Annotations: map[string]string{
+						pkg.PodValidationRejectReasonAnnotation: strings.Repeat("a", maxRejectReasonLength+1),
+					}},
+			},
+			expectedStatus:  int32(http.StatusForbidden),
+			expectedMessage: `Pod images validation failed: "` + strings.Repeat("a", maxRejectReasonLength) + `"...`,
+		},
 		{
 			name: "Pod should be allowed, validation success",
 			pod: &corev1.Pod{
@@ -96,11 +144,39 @@ func TestValidationWebhook(t *testing.T) {
 	}
 }
 
+func TestValidationWebhook_Delete(t *testing.T) {
+	//GIVEN
+	core, logs := observer.New(zapcore.InfoLevel)
+	webhook := NewValidationWebhook(nil, nil, time.Second, zap.New(core).Sugar())
+	req := admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			UID:       "request-uid",
+			Operation: admissionv1.Delete,
+			Resource:  metav1.GroupVersionResource{Resource: corev1.ResourcePods.String(), Version: corev1.SchemeGroupVersion.Version},
+			Name:      "test-pod",
+			Namespace: "default",
+			UserInfo:  authenticationv1.UserInfo{Username: "admin"},
+		}}
+
+	//WHEN
+	resp := webhook.Handle(context.TODO(), req)
+
+	//THEN
+	require.True(t, resp.Allowed)
+	entries := logs.FilterMessage("pod deletion observed").All()
+	require.Len(t, entries, 1)
+	fields := entries[0].ContextMap()
+	require.Equal(t, "test-pod", fields["name"])
+	require.Equal(t, "default", fields["namespace"])
+	require.Equal(t, "admin", fields["user"])
+	require.Equal(t, "request-uid", fields["uid"])
+}
+
 func TestValidationWebhook_Errors(t *testing.T) {
 	scheme := runtime.NewScheme()
 	decoder, err := admission.NewDecoder(scheme)
 	require.NoError(t, err)
-	webhook := NewValidationWebhook()
+	webhook := NewValidationWebhook(nil, nil, time.Second, zap.NewNop().Sugar())
 	require.NoError(t, webhook.InjectDecoder(decoder))
 	testCases := []struct {
 		name            string
@@ -0,0 +1,128 @@
+package admission
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/kyma-project/warden/internal/validate"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net/http"
+	k8sclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"strings"
+	"time"
+)
+
+const (
+	WorkloadValidationPath = "/validation/workloads"
+)
+
+// WorkloadValidationWebhook validates the images of the pod templates of deployments, statefulsets, daemonsets,
+// jobs and cronjobs, so invalid images are rejected with the workload instead of its pods.
+type WorkloadValidationWebhook struct {
+	validationSvc validate.PodValidator
+	timeout       time.Duration
+	client        k8sclient.Client
+	logger        *zap.SugaredLogger
+}
+
+func NewWorkloadValidationWebhook(client k8sclient.Client, validationSvc validate.PodValidator, timeout time.Duration, logger *zap.SugaredLogger) *WorkloadValidationWebhook {
+	return &WorkloadValidationWebhook{
+		client:        client,
+		validationSvc: validationSvc,
+		logger:        logger,
+		timeout:       timeout,
+	}
+}
+
+// workload has the pod template of all supported workloads, cronjobs have it in the job template.
+type workload struct {
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              struct {
+		Template    *corev1.PodTemplateSpec `json:"template"`
+		JobTemplate *struct {
+			Spec struct {
+				Template *corev1.PodTemplateSpec `json:"template"`
+			} `json:"spec"`
+		} `json:"jobTemplate"`
+	} `json:"spec"`
+}
+
+func (w *workload) podTemplate() *corev1.PodTemplateSpec {
+	if w.Spec.JobTemplate != nil {
+		return w.Spec.JobTemplate.Spec.Template
+	}
+	return w.Spec.Template
+}
+
+func (w *WorkloadValidationWebhook) Handle(ctx context.Context, req admission.Request) admission.Response {
+	ctxTimeout, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+
+	var resp admission.Response
+	done := make(chan bool)
+	go func() {
+		resp = w.handle(ctxTimeout, req)
+		done <- true
+	}()
+
+	select {
+	case <-done:
+	case <-ctxTimeout.Done():
+		if err := ctxTimeout.Err(); err != nil {
+			return admission.Errored(http.StatusRequestTimeout, err)
+		}
+	}
+	return resp
+}
+
+func (w *WorkloadValidationWebhook) handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation == admissionv1.Delete {
+		return admission.Allowed("")
+	}
+
+	obj := &workload{}
+	if err := json.Unmarshal(req.Object.Raw, obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, errors.Wrapf(err, "while decoding %s", req.Kind.Kind))
+	}
+	template := obj.podTemplate()
+	if template == nil {
+		return admission.Errored(http.StatusBadRequest,
+			errors.Errorf("Invalid request kind:%s, expected a workload with a pod template", req.Kind.Kind))
+	}
+
+	ns := &corev1.Namespace{}
+	if err := w.client.Get(ctx, k8sclient.ObjectKey{Name: req.Namespace}, ns); err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        obj.Name,
+			Namespace:   req.Namespace,
+			Labels:      template.Labels,
+			Annotations: template.Annotations,
+		},
+		Spec: template.Spec,
+	}
+	reasons := &validate.DenialReasons{}
+	result, err := w.validationSvc.ValidatePod(validate.ContextWithDenialReasons(ctx, reasons), pod, ns)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if result != validate.Invalid {
+		// the pods of the workload are validated again, e.g. when the service was unavailable
+		return admission.Allowed("")
+	}
+
+	w.logger.Infof("workload was rejected: %s %s, %s", req.Kind.Kind, obj.Name, req.Namespace)
+	message := fmt.Sprintf("%s %s has invalid pod template images", req.Kind.Kind, obj.Name)
+	if len(reasons.Reasons()) > 0 {
+		message += ": " + strings.Join(reasons.Reasons(), "; ")
+	}
+	return admission.Denied(message)
+}
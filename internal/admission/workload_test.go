@@ -0,0 +1,128 @@
+package admission
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/kyma-project/warden/internal/validate"
+	"github.com/kyma-project/warden/internal/validate/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"net/http"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"testing"
+	"time"
+)
+
+func TestWorkloadValidationWebhook(t *testing.T) {
+	testNs := "test-namespace"
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: testNs}}
+	client := fake.NewClientBuilder().WithObjects(ns).Build()
+	template := corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "shop"}},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "shop", Image: "eu.gcr.io/kyma-project/shop:1.0"}}},
+	}
+	deployment := &appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{Kind: "Deployment", APIVersion: "apps/v1"},
+		ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: testNs},
+		Spec:       appsv1.DeploymentSpec{Template: template},
+	}
+	request := func(t *testing.T, operation admissionv1.Operation, kind string, object runtime.Object) admission.Request {
+		raw, err := json.Marshal(object)
+		require.NoError(t, err)
+		return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: operation,
+			Kind:      metav1.GroupVersionKind{Kind: kind},
+			Namespace: testNs,
+			Object:    runtime.RawExtension{Raw: raw},
+		}}
+	}
+
+	t.Run("valid deployment", func(t *testing.T) {
+		//GIVEN
+		validationSvc := mocks.NewPodValidator(t)
+		validationSvc.On("ValidatePod", mock.Anything, mock.MatchedBy(func(pod *corev1.Pod) bool {
+			return pod.Name == "shop" && pod.Namespace == testNs && pod.Labels["app"] == "shop" &&
+				pod.Spec.Containers[0].Image == "eu.gcr.io/kyma-project/shop:1.0"
+		}), mock.Anything).Return(validate.Valid, nil).Once()
+		webhook := NewWorkloadValidationWebhook(client, validationSvc, time.Second, zap.NewNop().Sugar())
+
+		//WHEN
+		res := webhook.Handle(context.TODO(), request(t, admissionv1.Create, "Deployment", deployment))
+
+		//THEN
+		require.True(t, res.Allowed)
+	})
+
+	t.Run("invalid deployment is rejected", func(t *testing.T) {
+		//GIVEN
+		validationSvc := mocks.NewPodValidator(t)
+		validationSvc.On("ValidatePod", mock.Anything, mock.Anything, mock.Anything).Return(validate.Invalid, nil).Once()
+		webhook := NewWorkloadValidationWebhook(client, validationSvc, time.Second, zap.NewNop().Sugar())
+
+		//WHEN
+		res := webhook.Handle(context.TODO(), request(t, admissionv1.Update, "Deployment", deployment))
+
+		//THEN
+		require.False(t, res.Allowed)
+		require.Equal(t, "Deployment shop has invalid pod template images", string(res.Result.Reason))
+	})
+
+	t.Run("cronjob job template", func(t *testing.T) {
+		//GIVEN
+		cronJob := &batchv1.CronJob{
+			TypeMeta:   metav1.TypeMeta{Kind: "CronJob", APIVersion: "batch/v1"},
+			ObjectMeta: metav1.ObjectMeta{Name: "report", Namespace: testNs},
+			Spec: batchv1.CronJobSpec{JobTemplate: batchv1.JobTemplateSpec{
+				Spec: batchv1.JobSpec{Template: template},
+			}},
+		}
+		validationSvc := mocks.NewPodValidator(t)
+		validationSvc.On("ValidatePod", mock.Anything, mock.MatchedBy(func(pod *corev1.Pod) bool {
+			return pod.Name == "report" && pod.Spec.Containers[0].Image == "eu.gcr.io/kyma-project/shop:1.0"
+		}), mock.Anything).Return(validate.Invalid, nil).Once()
+		webhook := NewWorkloadValidationWebhook(client, validationSvc, time.Second, zap.NewNop().Sugar())
+
+		//WHEN
+		res := webhook.Handle(context.TODO(), request(t, admissionv1.Create, "CronJob", cronJob))
+
+		//THEN
+		require.False(t, res.Allowed)
+		require.Equal(t, "CronJob report has invalid pod template images", string(res.Result.Reason))
+	})
+
+	t.Run("unavailable validation leaves it to the pods", func(t *testing.T) {
+		validationSvc := mocks.NewPodValidator(t)
+		validationSvc.On("ValidatePod", mock.Anything, mock.Anything, mock.Anything).Return(validate.ServiceUnavailable, nil).Once()
+		webhook := NewWorkloadValidationWebhook(client, validationSvc, time.Second, zap.NewNop().Sugar())
+
+		res := webhook.Handle(context.TODO(), request(t, admissionv1.Create, "Deployment", deployment))
+
+		require.True(t, res.Allowed)
+	})
+
+	t.Run("delete is allowed", func(t *testing.T) {
+		webhook := NewWorkloadValidationWebhook(client, mocks.NewPodValidator(t), time.Second, zap.NewNop().Sugar())
+
+		res := webhook.Handle(context.TODO(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{Operation: admissionv1.Delete}})
+
+		require.True(t, res.Allowed)
+	})
+
+	t.Run("object without pod template", func(t *testing.T) {
+		webhook := NewWorkloadValidationWebhook(client, mocks.NewPodValidator(t), time.Second, zap.NewNop().Sugar())
+		configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "settings", Namespace: testNs}}
+
+		res := webhook.Handle(context.TODO(), request(t, admissionv1.Create, "ConfigMap", configMap))
+
+		require.False(t, res.Allowed)
+		require.Equal(t, int32(http.StatusBadRequest), res.Result.Code)
+	})
+}
@@ -1,17 +1,286 @@
 package config
 
 import (
+	"encoding/base64"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/kyma-project/warden/internal/validate"
+	"github.com/kyma-project/warden/internal/webhook/certs"
+	"github.com/pkg/errors"
+	"github.com/theupdateframework/notary/tuf/data"
 	"gopkg.in/yaml.v3"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type notary struct {
-	URL               string        `yaml:"URL"`
-	Timeout           time.Duration `yaml:"timeout"`
-	AllowedRegistries string        `yaml:"allowedRegistries"`
+	URL                        string                  `yaml:"URL"`
+	FallbackURLs               []string                `yaml:"fallbackURLs"`
+	Timeout                    time.Duration           `yaml:"timeout"`
+	TrustDir                   string                  `yaml:"trustDir"`
+	TrustDirMaxAge             time.Duration           `yaml:"trustDirMaxAge"`
+	ExpiredMetadataGrace       time.Duration           `yaml:"expiredMetadataGrace"`
+	MaxSignatureAge            time.Duration           `yaml:"maxSignatureAge"`
+	MaxResponseBytes           int64                   `yaml:"maxResponseBytes"`
+	AllowedRegistries          string                  `yaml:"allowedRegistries"`
+	AllowedRegistriesFile      string                  `yaml:"allowedRegistriesFile"`
+	Exceptions                 []exception             `yaml:"exceptions"`
+	PinnedDigests              map[string][]string     `yaml:"pinnedDigests"`
+	AcceptedRoles              []string                `yaml:"acceptedRoles"`
+	RequestsPerSecond          float64                 `yaml:"requestsPerSecond"`
+	Burst                      int                     `yaml:"burst"`
+	MaxConcurrentRegistryCalls int                     `yaml:"maxConcurrentRegistryCalls"`
+	RegistryTimeout            time.Duration           `yaml:"registryTimeout"`
+	HostOverrides              map[string]hostOverride `yaml:"hostOverrides"`
+	OfflineTrustBundle         string                  `yaml:"offlineTrustBundle"`
+	OfflineTrustBundleKey      string                  `yaml:"offlineTrustBundleKey"`
+	HarborURL                  string                  `yaml:"harborURL"`
+	Username                   string                  `yaml:"username"`
+	PasswordFile               string                  `yaml:"passwordFile"`
+	RegistryKeychains          []keychain              `yaml:"registryKeychains"`
+	InsecureRegistries         string                  `yaml:"insecureRegistries"`
+	NegativeCacheTTL           time.Duration           `yaml:"negativeCacheTTL"`
+	NegativeCacheMaxEntries    int                     `yaml:"negativeCacheMaxEntries"`
+	DisableNegativeCache       bool                    `yaml:"disableNegativeCache"`
+	DigestCacheTTL             time.Duration           `yaml:"digestCacheTTL"`
+	HealthCanaryGUN            string                  `yaml:"healthCanaryGUN"`
+	GUNMapping                 gunMapping              `yaml:"gunMapping"`
+	RequireFQDNRegistry        bool                    `yaml:"requireFQDNRegistry"`
+	RegistryMirrors            map[string]string       `yaml:"registryMirrors"`
+	RegistryMirrorFallback     bool                    `yaml:"registryMirrorFallback"`
+	HealthTimeout              time.Duration           `yaml:"healthTimeout"`
+	Platform                   string                  `yaml:"platform"`
+	AllowSchema1               bool                    `yaml:"allowSchema1"`
+	MaxRegistryResponseBytes   int64                   `yaml:"maxRegistryResponseBytes"`
+	SlowValidationThreshold    time.Duration           `yaml:"slowValidationThreshold"`
+	DigestTargets              bool                    `yaml:"digestTargets"`
+	DockerConfigPath           string                  `yaml:"dockerConfigPath"`
+	CacheWarmUp                cacheWarmUp             `yaml:"cacheWarmUp"`
+	TLS                        notaryTLS               `yaml:"tls"`
+	// NotaryHosts replace TLS, the credentials and the timeout for notary hosts.
+	NotaryHosts map[string]notaryHost `yaml:"notaryHosts"`
+	// NamespaceOverrides are merged over the global config for pods in the namespace.
+	NamespaceOverrides map[string]namespaceOverride `yaml:"namespaceOverrides"`
+	// HealthGatesReadiness makes the operator unready while notary is unhealthy, it only logs the errors otherwise.
+	HealthGatesReadiness bool `yaml:"healthGatesReadiness"`
+}
+
+type namespaceOverride struct {
+	URL               string `yaml:"URL"`
+	AllowedRegistries string `yaml:"allowedRegistries"`
+}
+
+// hostOverride replaces the timeouts and retries for images from the registry host.
+type hostOverride struct {
+	NotaryTimeout   time.Duration `yaml:"notaryTimeout"`
+	RegistryTimeout time.Duration `yaml:"registryTimeout"`
+	RegistryRetries *int          `yaml:"registryRetries"`
+}
+
+// notaryTLS configures TLS of notary requests, the system roots are trusted when CAFile isn't set.
+type notaryTLS struct {
+	CAFile             string `yaml:"caFile"`
+	CertFile           string `yaml:"certFile"`
+	KeyFile            string `yaml:"keyFile"`
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify"`
+}
+
+// notaryHost replaces the global notary settings for a notary host, zero values keep the global ones.
+type notaryHost struct {
+	TLS          *notaryTLS    `yaml:"tls"`
+	Username     string        `yaml:"username"`
+	PasswordFile string        `yaml:"passwordFile"`
+	Timeout      time.Duration `yaml:"timeout"`
+}
+
+// cacheWarmUp validates images of running pods at startup, defaults of the validator are used for zero values.
+type cacheWarmUp struct {
+	Enabled     bool          `yaml:"enabled"`
+	Timeout     time.Duration `yaml:"timeout"`
+	Concurrency int           `yaml:"concurrency"`
+}
+
+// gunMapping translates image repositories to notary GUNs.
+type gunMapping struct {
+	Repositories  map[string]string `yaml:"repositories"`
+	HostTemplates map[string]string `yaml:"hostTemplates"`
+}
+
+// exception admits the unsigned image until ExpiresAt, entries without ExpiresAt fail the config load.
+type exception struct {
+	Image     string    `yaml:"image"`
+	ExpiresAt time.Time `yaml:"expiresAt"`
+}
+
+// keychain selects the cloud provider keychain used for registry calls to Hosts.
+type keychain struct {
+	Provider string   `yaml:"provider"`
+	Hosts    []string `yaml:"hosts"`
+}
+
+// ServiceConfig returns the validator config of admission and of the operator, so the revalidation of running pods
+// reaches the same verdicts as the admission of the pods. The offline trust bundle is loaded and the registry
+// keychains are set up, so the config fails for bundles which can't be verified and for missing keychain providers.
+func (n notary) ServiceConfig() (validate.ServiceConfig, error) {
+	config := validate.ServiceConfig{
+		NotaryConfig: validate.NotaryConfig{
+			Url:                  n.URL,
+			FallbackUrls:         n.FallbackURLs,
+			AcceptedRoles:        n.NotaryRoles(),
+			RequestsPerSecond:    n.RequestsPerSecond,
+			Burst:                n.Burst,
+			TrustDir:             n.TrustDir,
+			ExpiredMetadataGrace: n.ExpiredMetadataGrace,
+			MaxSignatureAge:      n.MaxSignatureAge,
+			MaxResponseBytes:     n.MaxResponseBytes,
+			HarborURL:            n.HarborURL,
+			Username:             n.Username,
+			PasswordFile:         n.PasswordFile,
+			TLS:                  n.NotaryTLS(),
+			Hosts:                n.NotaryHostConfigs(),
+		},
+		AllowedRegistries:          validate.ParseAllowedRegistries(n.AllowedRegistries),
+		AllowedRegistriesFile:      n.AllowedRegistriesFile,
+		Exceptions:                 n.ImageExceptions(),
+		PinnedDigests:              n.PinnedDigests,
+		InsecureRegistries:         validate.ParseAllowedRegistries(n.InsecureRegistries),
+		MaxConcurrentRegistryCalls: n.MaxConcurrentRegistryCalls,
+		NotaryTimeout:              n.Timeout,
+		RegistryTimeout:            n.RegistryTimeout,
+		HostOverrides:              n.RegistryHostOverrides(),
+		NegativeCacheTTL:           n.NegativeCacheTTL,
+		NegativeCacheMaxEntries:    n.NegativeCacheMaxEntries,
+		DisableNegativeCache:       n.DisableNegativeCache,
+		DigestCacheTTL:             n.DigestCacheTTL,
+		RequireFQDNRegistry:        n.RequireFQDNRegistry,
+		RegistryMirrors:            n.RegistryMirrors,
+		RegistryMirrorFallback:     n.RegistryMirrorFallback,
+		Platform:                   n.Platform,
+		AllowSchema1:               n.AllowSchema1,
+		MaxRegistryResponseBytes:   n.MaxRegistryResponseBytes,
+		SlowValidationThreshold:    n.SlowValidationThreshold,
+		DigestTargets:              n.DigestTargets,
+		DockerConfigPath:           n.DockerConfigPath,
+		GUNMapping: validate.GUNMapping{
+			Repositories:  n.GUNMapping.Repositories,
+			HostTemplates: n.GUNMapping.HostTemplates,
+		},
+	}
+	if n.OfflineTrustBundle != "" {
+		bundle, err := loadOfflineTrustBundle(n.OfflineTrustBundle, n.OfflineTrustBundleKey)
+		if err != nil {
+			return validate.ServiceConfig{}, errors.Wrap(err, "failed to load offline trust bundle")
+		}
+		config.OfflineTrustBundle = bundle
+	}
+	for _, k := range n.RegistryKeychains {
+		keychain, err := registryKeychain(k.Provider, k.Hosts)
+		if err != nil {
+			return validate.ServiceConfig{}, errors.Wrap(err, "failed to setup registry keychain")
+		}
+		config.RegistryKeychains = append(config.RegistryKeychains, keychain)
+	}
+	return config, nil
+}
+
+// loadOfflineTrustBundle reads the bundle public key stored as base64 in keyPath and loads the bundle.
+func loadOfflineTrustBundle(path, keyPath string) (*validate.OfflineTrustBundle, error) {
+	encodedKey, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "while reading offline trust bundle key")
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encodedKey)))
+	if err != nil {
+		return nil, errors.Wrap(err, "while decoding offline trust bundle key")
+	}
+	return validate.NewOfflineTrustBundle(path, key)
+}
+
+// ServiceConfigPatches returns the namespace overrides in the form used by the validator.
+func (n notary) ServiceConfigPatches() map[string]validate.ServiceConfigPatch {
+	patches := make(map[string]validate.ServiceConfigPatch, len(n.NamespaceOverrides))
+	for namespace, o := range n.NamespaceOverrides {
+		patches[namespace] = validate.ServiceConfigPatch{
+			AllowedRegistries: validate.ParseAllowedRegistries(o.AllowedRegistries),
+			NotaryURL:         o.URL,
+		}
+	}
+	return patches
+}
+
+// RegistryHostOverrides returns the host overrides in the form used by the validator.
+func (n notary) RegistryHostOverrides() map[string]validate.HostOverride {
+	if len(n.HostOverrides) == 0 {
+		return nil
+	}
+	overrides := make(map[string]validate.HostOverride, len(n.HostOverrides))
+	for host, o := range n.HostOverrides {
+		overrides[host] = validate.HostOverride{
+			NotaryTimeout:   o.NotaryTimeout,
+			RegistryTimeout: o.RegistryTimeout,
+			RegistryRetries: o.RegistryRetries,
+		}
+	}
+	return overrides
+}
+
+// NotaryTLS returns the global TLS settings in the form used by the validator.
+func (n notary) NotaryTLS() validate.NotaryTLSConfig {
+	return n.TLS.toValidate()
+}
+
+// NotaryHostConfigs returns the notary host settings in the form used by the validator.
+func (n notary) NotaryHostConfigs() map[string]validate.NotaryHostConfig {
+	if len(n.NotaryHosts) == 0 {
+		return nil
+	}
+	hosts := make(map[string]validate.NotaryHostConfig, len(n.NotaryHosts))
+	for host, h := range n.NotaryHosts {
+		config := validate.NotaryHostConfig{
+			Username:     h.Username,
+			PasswordFile: h.PasswordFile,
+			Timeout:      h.Timeout,
+		}
+		if h.TLS != nil {
+			tls := h.TLS.toValidate()
+			config.TLS = &tls
+		}
+		hosts[host] = config
+	}
+	return hosts
+}
+
+func (t notaryTLS) toValidate() validate.NotaryTLSConfig {
+	return validate.NotaryTLSConfig{
+		CAFile:             t.CAFile,
+		CertFile:           t.CertFile,
+		KeyFile:            t.KeyFile,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+}
+
+// ImageExceptions returns the exceptions in the form used by the validator.
+func (n notary) ImageExceptions() []validate.ImageException {
+	exceptions := make([]validate.ImageException, 0, len(n.Exceptions))
+	for _, e := range n.Exceptions {
+		exceptions = append(exceptions, validate.ImageException{Image: e.Image, ExpiresAt: e.ExpiresAt})
+	}
+	return exceptions
+}
+
+// NotaryRoles returns the configured accepted roles, nil keeps the validator defaults.
+func (n notary) NotaryRoles() []data.RoleName {
+	if len(n.AcceptedRoles) == 0 {
+		return nil
+	}
+	roles := make([]data.RoleName, 0, len(n.AcceptedRoles))
+	for _, role := range n.AcceptedRoles {
+		roles = append(roles, data.RoleName(role))
+	}
+	return roles
 }
 
 type admission struct {
@@ -20,6 +289,179 @@ type admission struct {
 	SecretName      string        `yaml:"secretName"`
 	Timeout         time.Duration `yaml:"timeout"`
 	Port            int           `yaml:"port"`
+	AuditLog        auditLog      `yaml:"auditLog"`
+	// HealthProbeBindAddress serves the health checks, they aren't served when it's empty.
+	HealthProbeBindAddress string `yaml:"healthProbeBindAddress"`
+	// CertificateMode is "self-managed", the default, or "cert-manager" which requires CertManager.
+	CertificateMode string      `yaml:"certificateMode"`
+	CertManager     certManager `yaml:"certManager"`
+	Webhook         webhook     `yaml:"webhook"`
+	// ProtectionAdminGroups may remove the validation label of namespaces, system:masters by default.
+	ProtectionAdminGroups []string `yaml:"protectionAdminGroups"`
+	// TLS restricts the versions and cipher suites of the webhook server.
+	TLS serverTLS `yaml:"tls"`
+	// CertDir is the directory of the mounted certificate Secret, the webhook server then reloads the certificate
+	// when the files change instead of reading the Secret from the API server.
+	CertDir string `yaml:"certDir"`
+}
+
+// serverTLS hardens the TLS of the webhook server, TLS 1.2 and the secure cipher suites of Go are the defaults.
+type serverTLS struct {
+	MinVersion   string   `yaml:"minVersion"`
+	CipherSuites []string `yaml:"cipherSuites"`
+}
+
+func (a admission) TLSConfig() certs.TLSConfig {
+	return certs.TLSConfig{MinVersion: a.TLS.MinVersion, CipherSuites: a.TLS.CipherSuites}
+}
+
+// webhook overrides the defaults of the generated webhook configurations, fields which aren't set keep them.
+type webhook struct {
+	// Port is the port of the admission service, 443 by default.
+	Port *int32 `yaml:"port"`
+	// NamePrefix is prepended to the names of the webhook configurations, e.g. "dev-" for a second installation.
+	NamePrefix string `yaml:"namePrefix"`
+	// URL points the webhooks at a server outside the cluster instead of the admission service.
+	URL                string `yaml:"url"`
+	TimeoutSeconds     *int32 `yaml:"timeoutSeconds"`
+	FailurePolicy      string `yaml:"failurePolicy"`
+	MatchPolicy        string `yaml:"matchPolicy"`
+	ReinvocationPolicy string `yaml:"reinvocationPolicy"`
+	// Scope of the pod rules, Namespaced by default.
+	Scope string `yaml:"scope"`
+	// NamespaceSelector replaces the default one which excludes the system namespaces.
+	NamespaceSelector *labelSelector `yaml:"namespaceSelector"`
+	// ObjectSelector limits the webhooks to the selected pods.
+	ObjectSelector *labelSelector `yaml:"objectSelector"`
+	// MutatingOperations and ValidatingOperations replace the default CREATE and UPDATE operations.
+	MutatingOperations   []string `yaml:"mutatingOperations"`
+	ValidatingOperations []string `yaml:"validatingOperations"`
+	// SkipDryRun doesn't check the webhook configurations with dry runs, for API servers which don't allow them.
+	SkipDryRun bool `yaml:"skipDryRun"`
+	// SkipEphemeralContainers doesn't validate the images of containers added by kubectl debug.
+	SkipEphemeralContainers bool `yaml:"skipEphemeralContainers"`
+	// ValidateWorkloads rejects workloads whose pod templates have invalid images, not only their pods.
+	ValidateWorkloads bool `yaml:"validateWorkloads"`
+	// MatchConditions are CEL expressions which must all be true for the API server to call the webhooks.
+	MatchConditions []matchCondition `yaml:"matchConditions"`
+	// AdmissionReviewVersions replace the versions negotiated with the API server.
+	AdmissionReviewVersions []string `yaml:"admissionReviewVersions"`
+	// ResyncInterval is how often the webhook configurations are ensured besides the watch, 10m by default.
+	ResyncInterval time.Duration `yaml:"resyncInterval"`
+	// ClusterDomain qualifies the service names in the serving certificate, cluster.local by default.
+	ClusterDomain string `yaml:"clusterDomain"`
+	// ExtraSANs are added to the DNS names of the serving certificate, the certificate is regenerated when they change.
+	ExtraSANs []string `yaml:"extraSANs"`
+	// CABundlePath is a mounted file with the CA bundle of the webhooks, it replaces the one of the certificate secret.
+	CABundlePath string `yaml:"caBundlePath"`
+}
+
+type matchCondition struct {
+	Name       string `yaml:"name"`
+	Expression string `yaml:"expression"`
+}
+
+type labelSelector struct {
+	MatchLabels      map[string]string          `yaml:"matchLabels"`
+	MatchExpressions []labelSelectorRequirement `yaml:"matchExpressions"`
+}
+
+type labelSelectorRequirement struct {
+	Key      string   `yaml:"key"`
+	Operator string   `yaml:"operator"`
+	Values   []string `yaml:"values"`
+}
+
+func (s *labelSelector) labelSelector() *metav1.LabelSelector {
+	if s == nil {
+		return nil
+	}
+	selector := &metav1.LabelSelector{MatchLabels: s.MatchLabels}
+	for _, r := range s.MatchExpressions {
+		selector.MatchExpressions = append(selector.MatchExpressions, metav1.LabelSelectorRequirement{
+			Key:      r.Key,
+			Operator: metav1.LabelSelectorOperator(r.Operator),
+			Values:   r.Values,
+		})
+	}
+	return selector
+}
+
+// certManager points at the Certificate issued by cert-manager for the admission service and its Secret.
+type certManager struct {
+	Certificate string `yaml:"certificate"`
+	SecretName  string `yaml:"secretName"`
+}
+
+func (a admission) CertManagerConfig() certs.CertManagerConfig {
+	return certs.CertManagerConfig{Certificate: a.CertManager.Certificate, SecretName: a.CertManager.SecretName}
+}
+
+func operations(names []string) []admissionregistrationv1.OperationType {
+	if names == nil {
+		return nil
+	}
+	operations := make([]admissionregistrationv1.OperationType, 0, len(names))
+	for _, name := range names {
+		operations = append(operations, admissionregistrationv1.OperationType(name))
+	}
+	return operations
+}
+
+// WebhookConfig returns the config of the webhook configurations without the CA bundle.
+func (a admission) WebhookConfig() certs.WebhookConfig {
+	config := certs.WebhookConfig{
+		ServiceName:       a.ServiceName,
+		ServiceNamespace:  a.SystemNamespace,
+		Port:              a.Webhook.Port,
+		TimeoutSeconds:    a.Webhook.TimeoutSeconds,
+		NamespaceSelector: a.Webhook.NamespaceSelector.labelSelector(),
+		ObjectSelector:    a.Webhook.ObjectSelector.labelSelector(),
+	}
+	if a.Webhook.URL != "" {
+		config.URL = a.Webhook.URL
+		config.ServiceName = ""
+	}
+	config.NamePrefix = a.Webhook.NamePrefix
+	config.ClusterDomain = a.Webhook.ClusterDomain
+	config.ExtraSANs = a.Webhook.ExtraSANs
+	config.CABundlePath = a.Webhook.CABundlePath
+	config.ValidateWorkloads = a.Webhook.ValidateWorkloads
+	config.SkipEphemeralContainers = a.Webhook.SkipEphemeralContainers
+	config.SkipDryRun = a.Webhook.SkipDryRun
+	config.AdmissionReviewVersions = a.Webhook.AdmissionReviewVersions
+	config.MutatingOperations = operations(a.Webhook.MutatingOperations)
+	config.ValidatingOperations = operations(a.Webhook.ValidatingOperations)
+	for _, c := range a.Webhook.MatchConditions {
+		config.MatchConditions = append(config.MatchConditions, certs.MatchCondition{Name: c.Name, Expression: c.Expression})
+	}
+	if a.CertManager.Certificate != "" {
+		config.CertManagerCertificate = a.CertManagerConfig().InjectCAFrom(a.SystemNamespace)
+	}
+	if a.Webhook.FailurePolicy != "" {
+		policy := admissionregistrationv1.FailurePolicyType(a.Webhook.FailurePolicy)
+		config.FailurePolicy = &policy
+	}
+	if a.Webhook.MatchPolicy != "" {
+		policy := admissionregistrationv1.MatchPolicyType(a.Webhook.MatchPolicy)
+		config.MatchPolicy = &policy
+	}
+	if a.Webhook.ReinvocationPolicy != "" {
+		policy := admissionregistrationv1.ReinvocationPolicyType(a.Webhook.ReinvocationPolicy)
+		config.ReinvocationPolicy = &policy
+	}
+	if a.Webhook.Scope != "" {
+		scope := admissionregistrationv1.ScopeType(a.Webhook.Scope)
+		config.Scope = &scope
+	}
+	return config
+}
+
+// auditLog enables the JSON lines audit file of image decisions when Path is set.
+type auditLog struct {
+	Path       string `yaml:"path"`
+	MaxSize    int64  `yaml:"maxSize"`
+	MaxBackups int    `yaml:"maxBackups"`
 }
 
 type operator struct {
@@ -48,7 +490,28 @@ func Load(path string) (*config, error) {
 	}
 
 	err = yaml.Unmarshal(yamlFile, config)
-	return config, err
+	if err != nil {
+		return config, err
+	}
+	// temporary exceptions must not stay forever
+	for _, e := range config.Notary.ImageExceptions() {
+		if err := e.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if err := validate.PinnedDigests(config.Notary.PinnedDigests).Validate(); err != nil {
+		return nil, err
+	}
+	if err := certs.ValidateCertificateMode(certs.CertificateMode(config.Admission.CertificateMode), config.Admission.CertManagerConfig()); err != nil {
+		return nil, err
+	}
+	if err := config.Admission.WebhookConfig().Validate(); err != nil {
+		return nil, err
+	}
+	if err := config.Admission.TLSConfig().Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
 }
 
 func defaultConfig() *config {
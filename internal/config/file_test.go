@@ -4,8 +4,15 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/kyma-project/warden/internal/validate"
+	"github.com/kyma-project/warden/internal/webhook/certs"
 	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/tuf/data"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
 )
 
 const (
@@ -34,6 +41,264 @@ func TestLoad(t *testing.T) {
 		require.Equal(t, testURL, cfg.Notary.URL)
 	})
 
+	t.Run("Load namespace overrides", func(t *testing.T) {
+		path := filepath.Join(".", "testData", "config.yaml")
+
+		cfg, err := Load(path)
+		require.NoError(t, err)
+		require.Equal(t, map[string]validate.ServiceConfigPatch{
+			"experiments": {
+				AllowedRegistries: []string{"docker.io/experiments"},
+				NotaryURL:         "https://notary.experiments",
+			},
+		}, cfg.Notary.ServiceConfigPatches())
+	})
+
+	t.Run("Load accepted roles in order", func(t *testing.T) {
+		path := filepath.Join(".", "testData", "config.yaml")
+
+		cfg, err := Load(path)
+		require.NoError(t, err)
+		require.Equal(t, []data.RoleName{data.CanonicalTargetsRole, validate.NotaryReleasesRole}, cfg.Notary.NotaryRoles())
+		// delegation-signed images admitted by admission pass the revalidation of the operator too
+		serviceConfig, err := cfg.Notary.ServiceConfig()
+		require.NoError(t, err)
+		require.Equal(t, cfg.Notary.NotaryRoles(), serviceConfig.NotaryConfig.AcceptedRoles)
+	})
+
+	t.Run("Load validator config", func(t *testing.T) {
+		path := filepath.Join(".", "testData", "config.yaml")
+
+		cfg, err := Load(path)
+		require.NoError(t, err)
+		serviceConfig, err := cfg.Notary.ServiceConfig()
+		require.NoError(t, err)
+		require.Equal(t, "https://signing-dev.repositories.cloud.sap", serviceConfig.NotaryConfig.Url)
+		require.Equal(t, []string{"test1", "test2", "test3"}, serviceConfig.AllowedRegistries)
+		require.Equal(t, cfg.Notary.NotaryTLS(), serviceConfig.NotaryConfig.TLS)
+		require.Equal(t, cfg.Notary.NotaryHostConfigs(), serviceConfig.NotaryConfig.Hosts)
+		require.Equal(t, cfg.Notary.RegistryHostOverrides(), serviceConfig.HostOverrides)
+	})
+
+	t.Run("Load notary credentials and allowed registries file", func(t *testing.T) {
+		path := filepath.Join(".", "testData", "config.yaml")
+
+		cfg, err := Load(path)
+		require.NoError(t, err)
+		serviceConfig, err := cfg.Notary.ServiceConfig()
+		require.NoError(t, err)
+		require.Equal(t, "warden", serviceConfig.NotaryConfig.Username)
+		require.Equal(t, "/etc/notary/password", serviceConfig.NotaryConfig.PasswordFile)
+		require.Equal(t, "/etc/warden/allowed-registries.yaml", serviceConfig.AllowedRegistriesFile)
+	})
+
+	t.Run("Load notary health check", func(t *testing.T) {
+		path := filepath.Join(".", "testData", "config.yaml")
+
+		cfg, err := Load(path)
+		require.NoError(t, err)
+		require.Equal(t, "eu.gcr.io/kyma-project/canary", cfg.Notary.HealthCanaryGUN)
+		require.True(t, cfg.Notary.HealthGatesReadiness)
+	})
+
+	t.Run("Unavailable registry keychain error", func(t *testing.T) {
+		n := notary{RegistryKeychains: []keychain{{Provider: "unknown"}}}
+
+		_, err := n.ServiceConfig()
+		require.EqualError(t, err, "failed to setup registry keychain: keychain provider unknown is not available, build with the keychain_unknown tag")
+	})
+
+	t.Run("Load image exceptions", func(t *testing.T) {
+		path := filepath.Join(".", "testData", "config.yaml")
+
+		cfg, err := Load(path)
+		require.NoError(t, err)
+		require.Equal(t, []validate.ImageException{
+			{Image: "eu.gcr.io/kyma-project/hotfix:1.0", ExpiresAt: time.Date(2022, 11, 30, 18, 0, 0, 0, time.UTC)},
+		}, cfg.Notary.ImageExceptions())
+		// the operator revalidates with the same exceptions
+		serviceConfig, err := cfg.Notary.ServiceConfig()
+		require.NoError(t, err)
+		require.Equal(t, cfg.Notary.ImageExceptions(), serviceConfig.Exceptions)
+	})
+
+	t.Run("Image exception without expiry error", func(t *testing.T) {
+		path := filepath.Join(".", "testData", "config-exception-without-expiry.yaml")
+
+		cfg, err := Load(path)
+		require.EqualError(t, err, "image exception for eu.gcr.io/kyma-project/hotfix:1.0 must have the expiry time")
+		require.Nil(t, cfg)
+	})
+
+	t.Run("Load pinned digests", func(t *testing.T) {
+		path := filepath.Join(".", "testData", "config.yaml")
+
+		cfg, err := Load(path)
+		require.NoError(t, err)
+		require.Equal(t, map[string][]string{
+			"eu.gcr.io/kyma-project/warden/admission": {"sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"},
+		}, cfg.Notary.PinnedDigests)
+		serviceConfig, err := cfg.Notary.ServiceConfig()
+		require.NoError(t, err)
+		require.Equal(t, validate.PinnedDigests(cfg.Notary.PinnedDigests), serviceConfig.PinnedDigests)
+	})
+
+	t.Run("Load host overrides", func(t *testing.T) {
+		path := filepath.Join(".", "testData", "config.yaml")
+
+		cfg, err := Load(path)
+		require.NoError(t, err)
+		noRetries := 0
+		require.Equal(t, map[string]validate.HostOverride{
+			"partner.registry.example.com": {NotaryTimeout: 10 * time.Second, RegistryTimeout: 15 * time.Second, RegistryRetries: &noRetries},
+		}, cfg.Notary.RegistryHostOverrides())
+	})
+
+	t.Run("Load notary hosts", func(t *testing.T) {
+		path := filepath.Join(".", "testData", "config.yaml")
+
+		cfg, err := Load(path)
+		require.NoError(t, err)
+		require.Equal(t, validate.NotaryTLSConfig{CAFile: "/etc/notary/ca.pem"}, cfg.Notary.NotaryTLS())
+		require.Equal(t, map[string]validate.NotaryHostConfig{
+			"harbor.example.com": {
+				TLS:          &validate.NotaryTLSConfig{CAFile: "/etc/harbor/ca.pem"},
+				Username:     "robot$warden",
+				PasswordFile: "/etc/harbor/password",
+				Timeout:      20 * time.Second,
+			},
+		}, cfg.Notary.NotaryHostConfigs())
+	})
+
+	t.Run("Load cache warm-up", func(t *testing.T) {
+		path := filepath.Join(".", "testData", "config.yaml")
+
+		cfg, err := Load(path)
+		require.NoError(t, err)
+		require.Equal(t, cacheWarmUp{Enabled: true, Timeout: 30 * time.Second, Concurrency: 4}, cfg.Notary.CacheWarmUp)
+	})
+
+	t.Run("Load protection admin groups", func(t *testing.T) {
+		path := filepath.Join(".", "testData", "config.yaml")
+
+		cfg, err := Load(path)
+		require.NoError(t, err)
+		require.Equal(t, []string{"system:masters", "kyma:admins"}, cfg.Admission.ProtectionAdminGroups)
+	})
+
+	t.Run("Load webhook resync", func(t *testing.T) {
+		path := filepath.Join(".", "testData", "config.yaml")
+
+		cfg, err := Load(path)
+		require.NoError(t, err)
+		require.Equal(t, 5*time.Minute, cfg.Admission.Webhook.ResyncInterval)
+		require.Equal(t, ":8081", cfg.Admission.HealthProbeBindAddress)
+	})
+
+	t.Run("Malformed pinned digest error", func(t *testing.T) {
+		path := filepath.Join(".", "testData", "config-malformed-pinned-digest.yaml")
+
+		cfg, err := Load(path)
+		require.ErrorContains(t, err, "pinned digest sha256:0a0b of eu.gcr.io/kyma-project/warden/admission")
+		require.Nil(t, cfg)
+	})
+
+	t.Run("Load cert-manager certificate mode", func(t *testing.T) {
+		path := filepath.Join(".", "testData", "config-cert-manager.yaml")
+
+		cfg, err := Load(path)
+		require.NoError(t, err)
+		require.Equal(t, "cert-manager", cfg.Admission.CertificateMode)
+		require.Equal(t, certs.CertManagerConfig{
+			Certificate: "kyma-system/warden-admission",
+			SecretName:  "warden-admission-tls",
+		}, cfg.Admission.CertManagerConfig())
+	})
+
+	t.Run("Cert-manager settings in the self-managed mode error", func(t *testing.T) {
+		path := filepath.Join(".", "testData", "config-cert-manager-in-self-managed-mode.yaml")
+
+		cfg, err := Load(path)
+		require.EqualError(t, err, "cert-manager settings can be used only in the cert-manager certificate mode")
+		require.Nil(t, cfg)
+	})
+
+	t.Run("Load webhook overrides", func(t *testing.T) {
+		path := filepath.Join(".", "testData", "config.yaml")
+
+		cfg, err := Load(path)
+		require.NoError(t, err)
+		fail := admissionregistrationv1.Fail
+		equivalent := admissionregistrationv1.Equivalent
+		ifNeeded := admissionregistrationv1.IfNeededReinvocationPolicy
+		allScopes := admissionregistrationv1.AllScopes
+		require.Equal(t, certs.WebhookConfig{
+			ServiceName:             "warden-admission",
+			ServiceNamespace:        "default",
+			Port:                    pointer.Int32(8443),
+			ClusterDomain:           "cluster.example",
+			ExtraSANs:               []string{"warden-admission-headless.default.svc"},
+			CABundlePath:            "/etc/warden/ca/ca.crt",
+			ValidateWorkloads:       true,
+			AdmissionReviewVersions: []string{"v1"},
+			ValidatingOperations: []admissionregistrationv1.OperationType{
+				admissionregistrationv1.Create, admissionregistrationv1.Update, admissionregistrationv1.Delete,
+			},
+			TimeoutSeconds:     pointer.Int32(10),
+			FailurePolicy:      &fail,
+			MatchPolicy:        &equivalent,
+			ReinvocationPolicy: &ifNeeded,
+			Scope:              &allScopes,
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "kubernetes.io/metadata.name", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"kube-system"}},
+				},
+			},
+			ObjectSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"pods.warden.kyma-project.io/validate": "pending"},
+			},
+			MatchConditions: []certs.MatchCondition{{
+				Name:       "skip-kube-system-users",
+				Expression: "!request.userInfo.username.startsWith('system:serviceaccount:kube-system:')",
+			}},
+		}, cfg.Admission.WebhookConfig())
+	})
+
+	t.Run("Load webhook server TLS", func(t *testing.T) {
+		path := filepath.Join(".", "testData", "config.yaml")
+
+		cfg, err := Load(path)
+		require.NoError(t, err)
+		require.Equal(t, certs.TLSConfig{
+			MinVersion:   "1.2",
+			CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"},
+		}, cfg.Admission.TLSConfig())
+	})
+
+	t.Run("Load certificate directory", func(t *testing.T) {
+		path := filepath.Join(".", "testData", "config.yaml")
+
+		cfg, err := Load(path)
+		require.NoError(t, err)
+		require.Equal(t, "/etc/warden/certs", cfg.Admission.CertDir)
+	})
+
+	t.Run("Cipher suites with TLS 1.3 only error", func(t *testing.T) {
+		path := filepath.Join(".", "testData", "config-invalid-tls.yaml")
+
+		cfg, err := Load(path)
+		require.EqualError(t, err, "TLS cipher suites can't be used with the TLS min version 1.3")
+		require.Nil(t, cfg)
+	})
+
+	t.Run("Invalid webhook override error", func(t *testing.T) {
+		path := filepath.Join(".", "testData", "config-invalid-webhook.yaml")
+
+		cfg, err := Load(path)
+		require.EqualError(t, err, "webhook timeout 45s must be between 1 and 30 seconds")
+		require.Nil(t, cfg)
+	})
+
 	t.Run("Path does not exist error", func(t *testing.T) {
 		path := filepath.Join("this", "path", "doesnot.exist")
 
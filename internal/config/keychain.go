@@ -0,0 +1,27 @@
+package config
+
+import (
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/kyma-project/warden/internal/validate"
+	"github.com/pkg/errors"
+)
+
+// keychainProvider is registered by the build-tagged keychain_<provider>.go files,
+// so cloud SDKs are linked only into builds which need them.
+type keychainProvider struct {
+	keychain     authn.Keychain
+	defaultHosts []string
+}
+
+var keychainProviders = map[string]keychainProvider{}
+
+func registryKeychain(provider string, hosts []string) (validate.RegistryKeychain, error) {
+	p, ok := keychainProviders[provider]
+	if !ok {
+		return validate.RegistryKeychain{}, errors.Errorf("keychain provider %s is not available, build with the keychain_%s tag", provider, provider)
+	}
+	if len(hosts) == 0 {
+		hosts = p.defaultHosts
+	}
+	return validate.RegistryKeychain{Hosts: hosts, Keychain: p.keychain}, nil
+}
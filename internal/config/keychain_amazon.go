@@ -0,0 +1,17 @@
+//go:build keychain_amazon
+
+package config
+
+import (
+	"io"
+
+	ecr "github.com/awslabs/amazon-ecr-credential-helper/ecr-login"
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+func init() {
+	keychainProviders["amazon"] = keychainProvider{
+		keychain:     authn.NewKeychainFromHelper(ecr.NewECRHelper(ecr.WithLogger(io.Discard))),
+		defaultHosts: []string{"*.amazonaws.com"},
+	}
+}
@@ -0,0 +1,15 @@
+//go:build keychain_azure
+
+package config
+
+import (
+	"github.com/chrismellard/docker-credential-acr-env/pkg/credhelper"
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+func init() {
+	keychainProviders["azure"] = keychainProvider{
+		keychain:     authn.NewKeychainFromHelper(credhelper.NewACRCredentialsHelper()),
+		defaultHosts: []string{"*.azurecr.io"},
+	}
+}
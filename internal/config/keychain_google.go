@@ -0,0 +1,14 @@
+//go:build keychain_google
+
+package config
+
+import (
+	"github.com/google/go-containerregistry/pkg/v1/google"
+)
+
+func init() {
+	keychainProviders["google"] = keychainProvider{
+		keychain:     google.Keychain,
+		defaultHosts: []string{"gcr.io", "*.gcr.io", "*.pkg.dev"},
+	}
+}
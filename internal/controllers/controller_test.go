@@ -27,8 +27,10 @@ func Test_PodReconcile(t *testing.T) {
 	defer TearDown(t, testEnv)
 
 	imageValidator := mocks.NewImageValidatorService(t)
-	imageValidator.On("Validate", mock.Anything, validImage).Return(nil).Maybe()
-	imageValidator.On("Validate", mock.Anything, invalidImage).Return(errors.New("")).Maybe()
+	imageValidator.On("ValidateDetailed", mock.Anything, validImage).
+		Return(validate.ImageValidationResult{Outcome: validate.OutcomeSignatureVerified}, nil).Maybe()
+	imageValidator.On("ValidateDetailed", mock.Anything, invalidImage).
+		Return(validate.ImageValidationResult{Outcome: validate.OutcomeDenied}, errors.New("")).Maybe()
 
 	podValidator := validate.NewPodValidator(imageValidator)
 
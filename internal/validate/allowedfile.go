@@ -0,0 +1,102 @@
+package validate
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// allowedRegistriesFile serves AllowedRegistries entries from a mounted file, e.g. a ConfigMap key.
+// The file is read again when it changes, the previous list is kept when the new content can't be loaded.
+type allowedRegistriesFile struct {
+	path string
+
+	mu         sync.Mutex
+	modTime    time.Time
+	size       int64
+	registries []string
+	// generation counts loaded versions of the file.
+	generation uint64
+}
+
+// newAllowedRegistriesFile reads the file up front, a broken file leaves the list empty until it's fixed.
+func newAllowedRegistriesFile(path string) *allowedRegistriesFile {
+	if path == "" {
+		return nil
+	}
+	f := &allowedRegistriesFile{path: path}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reloadIfChanged()
+	return f
+}
+
+// entries returns the current list, the returned slice is never modified, so callers see one version of the file.
+func (f *allowedRegistriesFile) entries() []string {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.reloadIfChanged()
+	return f.registries
+}
+
+// version returns the generation of the current list, denials cached for an older list may not apply anymore.
+func (f *allowedRegistriesFile) version() uint64 {
+	if f == nil {
+		return 0
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.reloadIfChanged()
+	return f.generation
+}
+
+func (f *allowedRegistriesFile) reloadIfChanged() {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		log.Log.Error(err, "failed to read allowed registries file, keeping the previous list", "path", f.path)
+		return
+	}
+	if info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return
+	}
+	// the file is marked as loaded also when it's broken, so the error is logged once per change
+	f.modTime, f.size = info.ModTime(), info.Size()
+
+	registries, err := readAllowedRegistries(f.path)
+	if err != nil {
+		log.Log.Error(err, "failed to load allowed registries file, keeping the previous list", "path", f.path)
+		return
+	}
+	f.registries = registries
+	f.generation++
+	log.Log.Info("allowed registries loaded", "path", f.path, "registries", registries)
+}
+
+// readAllowedRegistries reads the YAML list of registries and repository prefixes.
+func readAllowedRegistries(path string) ([]string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("allowed registries: %w", err)
+	}
+	var entries []string
+	if err := yaml.Unmarshal(raw, &entries); err != nil {
+		return nil, fmt.Errorf("allowed registries %s: %w", path, err)
+	}
+	registries := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			registries = append(registries, entry)
+		}
+	}
+	return registries, nil
+}
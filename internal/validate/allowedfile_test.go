@@ -0,0 +1,141 @@
+package validate
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestValidate_AllowedRegistriesFile(t *testing.T) {
+	notSigned := func(name string, _ ...data.RoleName) (*client.TargetWithRole, error) {
+		return nil, client.ErrNoSuchTarget(name)
+	}
+	image := "eu.gcr.io/kyma-project/image:1.0"
+
+	t.Run("rewritten file switches the list", func(t *testing.T) {
+		//GIVEN
+		path := filepath.Join(t.TempDir(), "allowed.yaml")
+		writeAllowedRegistries(t, path, "- eu.gcr.io/other\n")
+		s := NewDefaultMockNotaryService().
+			WithFunc(notSigned).
+			WithNegativeCache(time.Minute, testingclock.NewFakeClock(time.Now())).
+			WithAllowedRegistriesFile(path).
+			Build()
+
+		//WHEN
+		before, beforeErr := s.ValidateDetailed(context.TODO(), image)
+		writeAllowedRegistries(t, path, "- eu.gcr.io/other\n- eu.gcr.io/kyma-project\n")
+		after, afterErr := s.ValidateDetailed(context.TODO(), image)
+
+		//THEN
+		var noTrustedTarget NoTrustedTargetError
+		require.ErrorAs(t, beforeErr, &noTrustedTarget)
+		require.Equal(t, OutcomeDenied, before.Outcome)
+		require.NoError(t, afterErr)
+		require.Equal(t, OutcomeAllowedList, after.Outcome)
+		require.Equal(t, "eu.gcr.io/kyma-project", after.AllowedListEntry)
+	})
+
+	t.Run("broken file keeps the previous list", func(t *testing.T) {
+		//GIVEN
+		path := filepath.Join(t.TempDir(), "allowed.yaml")
+		writeAllowedRegistries(t, path, "- eu.gcr.io/kyma-project\n")
+		s := NewDefaultMockNotaryService().WithFunc(notSigned).WithAllowedRegistriesFile(path).Build()
+
+		//WHEN
+		writeAllowedRegistries(t, path, "registries: [")
+		brokenErr := s.Validate(context.TODO(), image)
+		require.NoError(t, os.Remove(path))
+		removedErr := s.Validate(context.TODO(), image)
+
+		//THEN
+		require.NoError(t, brokenErr)
+		require.NoError(t, removedErr)
+	})
+
+	t.Run("static entries are kept", func(t *testing.T) {
+		//GIVEN
+		path := filepath.Join(t.TempDir(), "allowed.yaml")
+		writeAllowedRegistries(t, path, "[]")
+		b := NewDefaultMockNotaryService().WithFunc(notSigned).WithAllowedRegistriesFile(path)
+		b.NotaryService.AllowedRegistries = []string{"eu.gcr.io/kyma-project"}
+		s := b.Build()
+
+		//WHEN
+		err := s.Validate(context.TODO(), image)
+
+		//THEN
+		require.NoError(t, err)
+	})
+
+	t.Run("concurrent validations see one of the lists", func(t *testing.T) {
+		//GIVEN
+		path := filepath.Join(t.TempDir(), "allowed.yaml")
+		lists := []string{
+			"- eu.gcr.io/kyma-project/image\n- eu.gcr.io/kyma-project\n",
+			"- eu.gcr.io/kyma-project\n- eu.gcr.io/kyma-project/image\n",
+		}
+		writeAllowedRegistries(t, path, lists[0])
+		s := NewDefaultMockNotaryService().WithFunc(notSigned).WithAllowedRegistriesFile(path).Build()
+
+		//WHEN
+		var wg sync.WaitGroup
+		entries := make(chan string, 400)
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					result, err := s.ValidateDetailed(context.TODO(), image)
+					require.NoError(t, err)
+					entries <- result.AllowedListEntry
+				}
+			}()
+		}
+		for i := 0; i < 20; i++ {
+			writeAllowedRegistries(t, path, lists[i%2])
+		}
+		wg.Wait()
+		close(entries)
+
+		//THEN
+		for entry := range entries {
+			require.Contains(t, []string{"eu.gcr.io/kyma-project/image", "eu.gcr.io/kyma-project"}, entry)
+		}
+	})
+}
+
+func Test_readAllowedRegistries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "allowed.yaml")
+	writeAllowedRegistries(t, path, "- eu.gcr.io/kyma-project\n- ' docker.io/library '\n- ''\n")
+
+	registries, err := readAllowedRegistries(path)
+
+	require.NoError(t, err)
+	require.Equal(t, []string{"eu.gcr.io/kyma-project", "docker.io/library"}, registries)
+}
+
+// writeAllowedRegistries replaces the file content and moves its modification time forward,
+// so the change is noticed even when the file system has a coarse time resolution.
+func writeAllowedRegistries(t *testing.T, path, content string) {
+	var modTime time.Time
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime()
+	}
+	tmp := path + ".tmp"
+	require.NoError(t, os.WriteFile(tmp, []byte(content), 0o600))
+	next := modTime.Add(time.Second)
+	if now := time.Now(); now.After(next) {
+		next = now
+	}
+	require.NoError(t, os.Chtimes(tmp, next, next))
+	require.NoError(t, os.Rename(tmp, path))
+}
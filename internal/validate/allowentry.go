@@ -0,0 +1,43 @@
+package validate
+
+import (
+	"path"
+	"strings"
+)
+
+// allowedEntry is an AllowedRegistries entry, a repository prefix optionally scoped to tags by ":<tag pattern>",
+// e.g. "registry.internal/ci-tools/*:release-*". Tag patterns use the path.Match syntax.
+type allowedEntry struct {
+	repoPrefix string
+	// tagPattern is empty for entries which allow all references of the repositories.
+	tagPattern string
+}
+
+// parseAllowedEntry splits the tag pattern from the entry. Only entries with a repository path have one,
+// so the port of a registry entry like "localhost:5000" isn't taken for a tag pattern.
+func parseAllowedEntry(entry string) allowedEntry {
+	repo, tagPattern := entry, ""
+	slash := strings.LastIndex(entry, "/")
+	if i := strings.LastIndex(entry, tagDelim); slash >= 0 && i > slash {
+		repo, tagPattern = entry[:i], entry[i+len(tagDelim):]
+	}
+	// the entry is a prefix, so the trailing wildcard of "registry.internal/ci-tools/*" adds nothing
+	repo = strings.TrimSuffix(repo, "*")
+	return allowedEntry{repoPrefix: normalizeRepo(repo), tagPattern: tagPattern}
+}
+
+// matches checks the repository first and then the tag, references pinned by digest never match tag-scoped entries
+// because the tag doesn't decide which image is pulled. Entries with a malformed tag pattern match nothing.
+func (e allowedEntry) matches(ref imageRef) bool {
+	if !strings.HasPrefix(normalizeRepo(ref.repo), e.repoPrefix) {
+		return false
+	}
+	if e.tagPattern == "" {
+		return true
+	}
+	if ref.isPinned() {
+		return false
+	}
+	matched, err := path.Match(e.tagPattern, ref.tag)
+	return err == nil && matched
+}
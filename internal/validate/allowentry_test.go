@@ -0,0 +1,101 @@
+package validate
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_allowedListEntry_TagScoped(t *testing.T) {
+	allowed := []string{
+		"registry.internal/ci-tools/*:release-*",
+		"registry.internal/base-images",
+		"localhost:5000",
+		"registry.internal:5000/tools:v[0-9]*",
+		"registry.internal/broken:[",
+	}
+	tests := []struct {
+		name          string
+		image         string
+		expectedEntry string
+	}{
+		{
+			name:          "tag matching the pattern",
+			image:         "registry.internal/ci-tools/builder:release-1.2",
+			expectedEntry: "registry.internal/ci-tools/*:release-*",
+		},
+		{
+			name:  "tag not matching the pattern",
+			image: "registry.internal/ci-tools/builder:dev-john",
+		},
+		{
+			name:  "digest pinned reference with matching tag",
+			image: "registry.internal/ci-tools/builder:release-1.2@sha256:0a0b",
+		},
+		{
+			name:          "repo-only entry allows any tag",
+			image:         "registry.internal/base-images/alpine:dev-john",
+			expectedEntry: "registry.internal/base-images",
+		},
+		{
+			name:          "repo-only entry allows digest pinned references",
+			image:         "registry.internal/base-images/alpine:3.18@sha256:0a0b",
+			expectedEntry: "registry.internal/base-images",
+		},
+		{
+			name:          "registry port isn't a tag pattern",
+			image:         "localhost:5000/app:anything",
+			expectedEntry: "localhost:5000",
+		},
+		{
+			name:          "tag pattern of a registry with port",
+			image:         "registry.internal:5000/tools:v2.1",
+			expectedEntry: "registry.internal:5000/tools:v[0-9]*",
+		},
+		{
+			name:  "tag not matching the pattern of a registry with port",
+			image: "registry.internal:5000/tools:latest",
+		},
+		{
+			name:  "malformed tag pattern matches nothing",
+			image: "registry.internal/broken:[",
+		},
+		{
+			name:  "other repository",
+			image: "registry.internal/other/app:release-1.2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//GIVEN
+			s := NewDefaultMockNotaryService().Build()
+			s.AllowedRegistries = allowed
+			ref, err := parseImageRef(tt.image)
+			require.NoError(t, err)
+
+			//WHEN
+			entry, ok := s.allowedListEntry(ref, nil)
+
+			//THEN
+			require.Equal(t, tt.expectedEntry != "", ok)
+			require.Equal(t, tt.expectedEntry, entry)
+		})
+	}
+}
+
+func Test_Validate_TagScopedAllowedList(t *testing.T) {
+	//GIVEN
+	s := NewDefaultMockNotaryService().Build()
+	s.AllowedRegistries = []string{"registry.internal/ci-tools/*:release-*"}
+
+	//WHEN
+	released, releasedErr := s.ValidateDetailed(context.TODO(), "registry.internal/ci-tools/builder:release-1.2")
+	_, devErr := s.ValidateDetailed(context.TODO(), "registry.internal/ci-tools/builder:dev-john")
+
+	//THEN
+	require.NoError(t, releasedErr)
+	require.Equal(t, OutcomeAllowedList, released.Outcome)
+	require.Equal(t, "registry.internal/ci-tools/*:release-*", released.AllowedListEntry)
+	require.Error(t, devErr)
+}
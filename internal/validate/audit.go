@@ -0,0 +1,195 @@
+package validate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/theupdateframework/notary/tuf/data"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var auditWriteErrors = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "warden_audit_write_errors_total",
+	Help: "Number of validation audit entries which couldn't be written to the audit sink.",
+})
+
+func init() {
+	metrics.Registry.MustRegister(auditWriteErrors)
+}
+
+// ValidationAuditEntry records a single image admission decision.
+type ValidationAuditEntry struct {
+	Time      time.Time `json:"time"`
+	Image     string    `json:"image"`
+	Namespace string    `json:"namespace,omitempty"`
+	Pod       string    `json:"pod,omitempty"`
+	Outcome   Outcome   `json:"outcome"`
+	// AllowedListEntry is the allow list entry which admitted the image without the signature verification.
+	AllowedListEntry string        `json:"allowedListEntry,omitempty"`
+	NotaryRole       data.RoleName `json:"notaryRole,omitempty"`
+	SigningKeyIDs    []string      `json:"signingKeyIDs,omitempty"`
+	ResolvedDigest   string        `json:"resolvedDigest,omitempty"`
+	NotaryDuration   time.Duration `json:"notaryDurationNs"`
+	RegistryDuration time.Duration `json:"registryDurationNs"`
+	Error            string        `json:"error,omitempty"`
+
+	// ExceptionExpiresAt is the expiry time of the exception which admitted the image.
+	ExceptionExpiresAt *time.Time `json:"exceptionExpiresAt,omitempty"`
+	// Warnings are the problems which didn't deny the image, e.g. accepted expired trust metadata.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+func newValidationAuditEntry(result ImageValidationResult, err error) ValidationAuditEntry {
+	entry := ValidationAuditEntry{
+		Time:             time.Now().UTC(),
+		Image:            result.Image,
+		Outcome:          result.Outcome,
+		AllowedListEntry: result.AllowedListEntry,
+		NotaryRole:       result.NotaryRole,
+		SigningKeyIDs:    result.SigningKeyIDs,
+		ResolvedDigest:   result.ResolvedDigest,
+		NotaryDuration:   result.Durations.Notary,
+		RegistryDuration: result.Durations.Registry,
+		Warnings:         result.Warnings,
+	}
+	if result.Outcome == OutcomeException {
+		expiresAt := result.ExceptionExpiresAt.UTC()
+		entry.ExceptionExpiresAt = &expiresAt
+	}
+	if err != nil {
+		entry.Outcome = OutcomeDenied
+		entry.Error = err.Error()
+	}
+	return entry
+}
+
+// AuditSink receives every image validation decision, the images of a pod are written concurrently.
+type AuditSink interface {
+	Write(ctx context.Context, entry ValidationAuditEntry) error
+}
+
+// writeAudit never fails the validation, sink errors are only logged and counted.
+func writeAudit(ctx context.Context, sink AuditSink, entry ValidationAuditEntry) {
+	if sink == nil {
+		return
+	}
+	if err := sink.Write(ctx, entry); err != nil {
+		auditWriteErrors.Inc()
+		log.FromContext(ctx).Error(err, "failed to write validation audit entry", "image", entry.Image)
+	}
+}
+
+var _ AuditSink = &FileAuditSink{}
+
+// FileAuditSink appends audit entries to a file as JSON lines.
+// The file is rotated when it would grow over maxSize bytes, up to maxBackups rotated files are kept as <path>.1 ... <path>.N.
+type FileAuditSink struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewFileAuditSink opens the audit file for appending, maxSize of zero disables the rotation.
+func NewFileAuditSink(path string, maxSize int64, maxBackups int) (*FileAuditSink, error) {
+	s := &FileAuditSink{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileAuditSink) Write(_ context.Context, entry ValidationAuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return fmt.Errorf("audit file %s is closed", s.path)
+	}
+	if s.maxSize > 0 && s.size > 0 && s.size+int64(len(line)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	return err
+}
+
+// Close closes the audit file, entries written afterwards are rejected.
+func (s *FileAuditSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+func (s *FileAuditSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	s.file = file
+	s.size = info.Size()
+	return nil
+}
+
+// rotate reopens the audit file even when the old file couldn't be moved, so writes aren't lost.
+func (s *FileAuditSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	s.file = nil
+
+	shiftErr := s.shiftBackups()
+	if err := s.open(); err != nil {
+		return err
+	}
+	return shiftErr
+}
+
+func (s *FileAuditSink) shiftBackups() error {
+	if s.maxBackups <= 0 {
+		return os.Remove(s.path)
+	}
+	for i := s.maxBackups - 1; i > 0; i-- {
+		err := os.Rename(s.backupPath(i), s.backupPath(i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(s.path, s.backupPath(1))
+}
+
+func (s *FileAuditSink) backupPath(i int) string {
+	return fmt.Sprintf("%s.%d", s.path, i)
+}
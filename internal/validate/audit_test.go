@@ -0,0 +1,230 @@
+package validate
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/kyma-project/warden/pkg"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPodValidator_Audit(t *testing.T) {
+	signedImage := "eu.gcr.io/kyma-project/signed:1.0"
+	registryTransport, img := pushTestImageAs(t, signedImage)
+	imageHash := configHash(t, img)
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   "audited",
+		Labels: map[string]string{pkg.NamespaceValidationLabel: pkg.NamespaceValidationEnabled},
+	}}
+	newPod := func(image string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: ns.Name},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: image}}},
+		}
+	}
+
+	t.Run("signed image is allowed", func(t *testing.T) {
+		//GIVEN
+		f := NewDefaultMockNotaryFunction().WithRoleHashes(map[data.RoleName][]byte{NotaryReleasesRole: imageHash}).Build()
+		s := NewDefaultMockNotaryService().
+			WithFunc(f).
+			WithSigningKeys(map[data.RoleName][]string{NotaryReleasesRole: {"release-key"}}).
+			WithRegistryTransport(registryTransport).
+			Build()
+		sink := &recordingAuditSink{}
+		v := NewAuditedPodValidator(staticValidator{&s}, sink)
+
+		//WHEN
+		result, err := v.ValidatePod(context.TODO(), newPod(signedImage), ns)
+
+		//THEN
+		require.NoError(t, err)
+		require.Equal(t, Valid, result)
+		require.Len(t, sink.entries, 1)
+		entry := sink.entries[0]
+		require.Equal(t, signedImage, entry.Image)
+		require.Equal(t, "audited", entry.Namespace)
+		require.Equal(t, "app", entry.Pod)
+		require.Equal(t, OutcomeSignatureVerified, entry.Outcome)
+		require.Equal(t, NotaryReleasesRole, entry.NotaryRole)
+		require.Equal(t, []string{"release-key"}, entry.SigningKeyIDs)
+		require.Equal(t, "eu.gcr.io/kyma-project/signed@sha256:"+hex.EncodeToString(imageHash), entry.ResolvedDigest)
+		require.Empty(t, entry.AllowedListEntry)
+		require.Empty(t, entry.Error)
+		require.False(t, entry.Time.IsZero())
+	})
+
+	t.Run("unsigned image is denied", func(t *testing.T) {
+		//GIVEN
+		f := func(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+			return nil, client.ErrNoSuchTarget(name)
+		}
+		s := NewDefaultMockNotaryService().WithFunc(f).Build()
+		sink := &recordingAuditSink{}
+		v := NewAuditedPodValidator(staticValidator{&s}, sink)
+
+		//WHEN
+		result, err := v.ValidatePod(context.TODO(), newPod("eu.gcr.io/kyma-project/unsigned:1.0"), ns)
+
+		//THEN
+		require.NoError(t, err)
+		require.Equal(t, Invalid, result)
+		require.Len(t, sink.entries, 1)
+		entry := sink.entries[0]
+		require.Equal(t, "eu.gcr.io/kyma-project/unsigned:1.0", entry.Image)
+		require.Equal(t, OutcomeDenied, entry.Outcome)
+		require.Equal(t, "tag 1.0 is not signed by any of roles [targets/releases targets]: No valid trust data for 1.0", entry.Error)
+		require.Empty(t, entry.ResolvedDigest)
+	})
+
+	t.Run("allow list bypasses the signature verification", func(t *testing.T) {
+		//GIVEN
+		s := NewDefaultMockNotaryService().Build()
+		s.AllowedRegistries = []string{"docker.io/library", "eu.gcr.io/kyma-project"}
+		sink := &recordingAuditSink{}
+		v := NewAuditedPodValidator(staticValidator{&s}, sink)
+		pod := newPod("eu.gcr.io/kyma-project/unsigned:1.0")
+		pod.Name = ""
+		pod.GenerateName = "app-"
+
+		//WHEN
+		result, err := v.ValidatePod(context.TODO(), pod, ns)
+
+		//THEN
+		require.NoError(t, err)
+		require.Equal(t, Valid, result)
+		require.Len(t, sink.entries, 1)
+		entry := sink.entries[0]
+		require.Equal(t, OutcomeAllowedList, entry.Outcome)
+		require.Equal(t, "eu.gcr.io/kyma-project", entry.AllowedListEntry)
+		require.Equal(t, "app-", entry.Pod)
+		require.Empty(t, entry.NotaryRole)
+		require.Zero(t, entry.NotaryDuration)
+	})
+
+	t.Run("sink failure doesn't fail the validation", func(t *testing.T) {
+		//GIVEN
+		s := NewDefaultMockNotaryService().Build()
+		s.AllowedRegistries = []string{"eu.gcr.io/kyma-project"}
+		v := NewAuditedPodValidator(staticValidator{&s}, failingAuditSink{})
+		before := testutil.ToFloat64(auditWriteErrors)
+
+		//WHEN
+		result, err := v.ValidatePod(context.TODO(), newPod("eu.gcr.io/kyma-project/unsigned:1.0"), ns)
+
+		//THEN
+		require.NoError(t, err)
+		require.Equal(t, Valid, result)
+		require.Equal(t, before+1, testutil.ToFloat64(auditWriteErrors))
+	})
+}
+
+func TestFileAuditSink(t *testing.T) {
+	entry := ValidationAuditEntry{
+		Image:   "eu.gcr.io/kyma-project/image:1.0",
+		Outcome: OutcomeAllowedList,
+	}
+
+	t.Run("entries are appended as JSON lines", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "audit.log")
+		sink, err := NewFileAuditSink(path, 0, 0)
+		require.NoError(t, err)
+
+		require.NoError(t, sink.Write(context.TODO(), entry))
+		require.NoError(t, sink.Write(context.TODO(), entry))
+		require.NoError(t, sink.Close())
+
+		entries := readAuditFile(t, path)
+		require.Len(t, entries, 2)
+		require.Equal(t, entry.Image, entries[0].Image)
+		require.Equal(t, OutcomeAllowedList, entries[1].Outcome)
+	})
+
+	t.Run("file is rotated when it's full", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "audit.log")
+		line, err := json.Marshal(entry)
+		require.NoError(t, err)
+		sink, err := NewFileAuditSink(path, int64(len(line)+1)*2, 2)
+		require.NoError(t, err)
+
+		for i := 0; i < 7; i++ {
+			require.NoError(t, sink.Write(context.TODO(), entry))
+		}
+		require.NoError(t, sink.Close())
+
+		require.Len(t, readAuditFile(t, path), 1)
+		require.Len(t, readAuditFile(t, path+".1"), 2)
+		require.Len(t, readAuditFile(t, path+".2"), 2)
+		require.NoFileExists(t, path+".3")
+	})
+
+	t.Run("existing file is appended", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "audit.log")
+		sink, err := NewFileAuditSink(path, 0, 0)
+		require.NoError(t, err)
+		require.NoError(t, sink.Write(context.TODO(), entry))
+		require.NoError(t, sink.Close())
+
+		sink, err = NewFileAuditSink(path, 0, 0)
+		require.NoError(t, err)
+		require.NoError(t, sink.Write(context.TODO(), entry))
+		require.NoError(t, sink.Close())
+
+		require.Len(t, readAuditFile(t, path), 2)
+	})
+
+	t.Run("closed sink rejects entries", func(t *testing.T) {
+		sink, err := NewFileAuditSink(filepath.Join(t.TempDir(), "audit.log"), 0, 0)
+		require.NoError(t, err)
+		require.NoError(t, sink.Close())
+
+		require.Error(t, sink.Write(context.TODO(), entry))
+	})
+}
+
+type recordingAuditSink struct {
+	mu      sync.Mutex
+	entries []ValidationAuditEntry
+}
+
+func (s *recordingAuditSink) Write(_ context.Context, entry ValidationAuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+type failingAuditSink struct{}
+
+func (failingAuditSink) Write(context.Context, ValidationAuditEntry) error {
+	return errors.New("disk full")
+}
+
+func readAuditFile(t *testing.T, path string) []ValidationAuditEntry {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var entries []ValidationAuditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry ValidationAuditEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		entries = append(entries, entry)
+	}
+	require.NoError(t, scanner.Err())
+	return entries
+}
@@ -0,0 +1,105 @@
+package validate
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/theupdateframework/notary/trustpinning"
+	"github.com/theupdateframework/notary/tuf/signed"
+)
+
+// CacheAdmin forces the re-validation of images without restarting the webhook,
+// e.g. after signing keys were rotated or a signature was revoked.
+// Validators of NewImageValidator and the factory of NewNamespacedImageValidators implement it.
+type CacheAdmin interface {
+	// Invalidate drops the cached denials and registry digests of the image for all platforms.
+	Invalidate(image string)
+	// Flush drops all cached denials and registry digests.
+	Flush()
+}
+
+var (
+	_ CacheAdmin = &notaryService{}
+	_ CacheAdmin = &namespacedValidators{}
+)
+
+// Invalidate may be called concurrently with validations, results of validations which were in flight aren't cached.
+func (s *notaryService) Invalidate(image string) {
+	image = strings.TrimSpace(image)
+	s.negativeCache.removeIf(func(cached string) bool {
+		return cached == image
+	})
+	ref, err := parseImageRef(image)
+	if err != nil {
+		return
+	}
+	// the registry is asked for the tag also when the reference is pinned
+	if registryRef, err := name.ParseReference(ref.tagged()); err == nil {
+		s.digestCache.removeRef(registryRef.Name())
+	}
+}
+
+func (s *notaryService) Flush() {
+	s.negativeCache.flush()
+	s.digestCache.flush()
+}
+
+// invalidateRepository drops the cached denials of all images of the image repository,
+// they may be outdated when the notary repository changed its keys.
+func (s *notaryService) invalidateRepository(image string) {
+	ref, err := parseImageRef(strings.TrimSpace(image))
+	if err != nil {
+		return
+	}
+	repo := normalizeRepo(ref.repo)
+	s.negativeCache.removeIf(func(cached string) bool {
+		cachedRef, err := parseImageRef(cached)
+		return err == nil && normalizeRepo(cachedRef.repo) == repo
+	})
+}
+
+// Invalidate drops the image from the caches of the global and all namespace validators.
+func (v *namespacedValidators) Invalidate(image string) {
+	for _, admin := range v.cacheAdmins() {
+		admin.Invalidate(image)
+	}
+}
+
+func (v *namespacedValidators) Flush() {
+	for _, admin := range v.cacheAdmins() {
+		admin.Flush()
+	}
+}
+
+func (v *namespacedValidators) cacheAdmins() []CacheAdmin {
+	validators := []ImageValidatorService{v.global}
+	for _, validator := range v.namespaces {
+		validators = append(validators, validator)
+	}
+	var admins []CacheAdmin
+	for _, validator := range validators {
+		if admin, ok := validator.(CacheAdmin); ok {
+			admins = append(admins, admin)
+		}
+	}
+	return admins
+}
+
+// isKeyRotationError returns true for notary errors caused by keys which changed since the metadata was signed or cached.
+func isKeyRotationError(err error) bool {
+	var (
+		rootRotation  trustpinning.ErrRootRotationFail
+		validation    trustpinning.ErrValidationFail
+		roleThreshold signed.ErrRoleThreshold
+		insufficient  signed.ErrInsufficientSignatures
+		noKeys        signed.ErrNoKeys
+		invalidKeyID  signed.ErrInvalidKeyID
+	)
+	return err != nil && (errors.As(err, &rootRotation) ||
+		errors.As(err, &validation) ||
+		errors.As(err, &roleThreshold) ||
+		errors.As(err, &insufficient) ||
+		errors.As(err, &noKeys) ||
+		errors.As(err, &invalidKeyID))
+}
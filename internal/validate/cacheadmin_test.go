@@ -0,0 +1,192 @@
+package validate
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/tuf/signed"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+// tagCalls counts notary lookups per tag.
+type tagCalls struct {
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (c *tagCalls) inc(tag string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls[tag]++
+}
+
+func (c *tagCalls) get(tag string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls[tag]
+}
+
+func Test_CacheAdmin(t *testing.T) {
+	repo := "eu.gcr.io/kyma-project/unsigned"
+
+	newService := func(errFor func(tag string) error) (*notaryService, *tagCalls) {
+		calls := &tagCalls{calls: map[string]int{}}
+		f := func(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+			calls.inc(name)
+			return nil, errFor(name)
+		}
+		s := NewDefaultMockNotaryService().WithFunc(f).WithNegativeCache(time.Minute, testingclock.NewFakeClock(time.Now())).Build()
+		return &s, calls
+	}
+	noSuchTarget := func(tag string) error {
+		return client.ErrNoSuchTarget(tag)
+	}
+
+	t.Run("invalidated image hits notary again", func(t *testing.T) {
+		//GIVEN
+		s, calls := newService(noSuchTarget)
+		for _, tag := range []string{"a", "b"} {
+			_, err := s.ValidateDetailed(context.TODO(), repo+":"+tag)
+			require.Error(t, err)
+		}
+		a, b := calls.get("a"), calls.get("b")
+
+		//WHEN
+		s.Invalidate(repo + ":a")
+		_, errA := s.ValidateDetailed(context.TODO(), repo+":a")
+		_, errB := s.ValidateDetailed(context.TODO(), repo+":b")
+
+		//THEN
+		require.Error(t, errA)
+		require.Error(t, errB)
+		require.Greater(t, calls.get("a"), a)
+		require.Equal(t, b, calls.get("b"))
+	})
+
+	t.Run("flush drops all images", func(t *testing.T) {
+		//GIVEN
+		s, calls := newService(noSuchTarget)
+		for _, tag := range []string{"a", "b"} {
+			_, err := s.ValidateDetailed(context.TODO(), repo+":"+tag)
+			require.Error(t, err)
+		}
+		a, b := calls.get("a"), calls.get("b")
+
+		//WHEN
+		s.Flush()
+		_, _ = s.ValidateDetailed(context.TODO(), repo+":a")
+		_, _ = s.ValidateDetailed(context.TODO(), repo+":b")
+
+		//THEN
+		require.Greater(t, calls.get("a"), a)
+		require.Greater(t, calls.get("b"), b)
+	})
+
+	t.Run("key rotation error invalidates the repository", func(t *testing.T) {
+		//GIVEN
+		s, calls := newService(func(tag string) error {
+			if tag == "rotated" {
+				return signed.ErrRoleThreshold{Msg: "valid signatures did not meet threshold for targets"}
+			}
+			return client.ErrNoSuchTarget(tag)
+		})
+		_, err := s.ValidateDetailed(context.TODO(), repo+":a")
+		require.Error(t, err)
+		_, err = s.ValidateDetailed(context.TODO(), "eu.gcr.io/kyma-project/other:a")
+		require.Error(t, err)
+		a := calls.get("a")
+
+		//WHEN
+		_, err = s.ValidateDetailed(context.TODO(), repo+":rotated")
+		require.Error(t, err)
+		_, _ = s.ValidateDetailed(context.TODO(), repo+":a")
+		_, _ = s.ValidateDetailed(context.TODO(), "eu.gcr.io/kyma-project/other:a")
+
+		//THEN
+		require.Equal(t, a+1, calls.get("a"), "only the image of the rotated repository is validated again")
+	})
+
+	t.Run("denial of validation in flight isn't cached after invalidation", func(t *testing.T) {
+		//GIVEN
+		started, release := make(chan struct{}), make(chan struct{})
+		var once sync.Once
+		s, calls := newService(func(tag string) error {
+			once.Do(func() {
+				close(started)
+				<-release
+			})
+			return client.ErrNoSuchTarget(tag)
+		})
+		done := make(chan error)
+		go func() {
+			_, err := s.ValidateDetailed(context.TODO(), repo+":a")
+			done <- err
+		}()
+		<-started
+
+		//WHEN
+		s.Invalidate(repo + ":a")
+		close(release)
+		require.Error(t, <-done)
+		a := calls.get("a")
+		_, err := s.ValidateDetailed(context.TODO(), repo+":a")
+
+		//THEN
+		require.Error(t, err)
+		require.Greater(t, calls.get("a"), a)
+	})
+}
+
+func Test_digestCache_Invalidate(t *testing.T) {
+	//GIVEN
+	clk := testingclock.NewFakeClock(time.Now())
+	c := newDigestCache(&ServiceConfig{DigestCacheTTL: time.Minute}, clk)
+	amd64 := digestCacheKey{ref: "eu.gcr.io/kyma-project/app:1.0", platform: "linux/amd64", algorithm: SHA256Algorithm}
+	arm64 := digestCacheKey{ref: "eu.gcr.io/kyma-project/app:1.0", platform: "linux/arm64", algorithm: SHA256Algorithm}
+	other := digestCacheKey{ref: "eu.gcr.io/kyma-project/other:1.0", platform: "linux/amd64", algorithm: SHA256Algorithm}
+	for _, key := range []digestCacheKey{amd64, arm64, other} {
+		c.add(c.currentGeneration(), key, imageDigests{config: []byte("config")}, "")
+	}
+	inFlight := c.currentGeneration()
+
+	//WHEN
+	c.removeRef("eu.gcr.io/kyma-project/app:1.0")
+	c.add(inFlight, amd64, imageDigests{config: []byte("stale")}, "")
+
+	//THEN
+	_, ok := c.get(amd64)
+	require.False(t, ok)
+	_, ok = c.get(arm64)
+	require.False(t, ok)
+	_, ok = c.get(other)
+	require.True(t, ok)
+
+	c.flush()
+	_, ok = c.get(other)
+	require.False(t, ok)
+}
+
+func Test_namespacedValidators_CacheAdmin(t *testing.T) {
+	//GIVEN
+	validators, err := NewNamespacedImageValidators(&ServiceConfig{NotaryConfig: NotaryConfig{Url: "https://notary"}},
+		map[string]ServiceConfigPatch{"tools": {NotaryURL: "https://notary.tools"}})
+	require.NoError(t, err)
+	global := validators.GetValidator("default").(*notaryService)
+	tools := validators.GetValidator("tools").(*notaryService)
+	image := "eu.gcr.io/kyma-project/unsigned:a"
+	for _, s := range []*notaryService{global, tools} {
+		s.negativeCache.add(image+" linux/amd64", ImageValidationResult{}, client.ErrNoSuchTarget("a"))
+	}
+
+	//WHEN
+	validators.(CacheAdmin).Invalidate(image)
+
+	//THEN
+	require.Zero(t, global.negativeCache.len())
+	require.Zero(t, tools.negativeCache.len())
+}
@@ -0,0 +1,152 @@
+package validate
+
+import (
+	"context"
+	"fmt"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// NotaryBackend is the name of the built-in notary backend in VerificationChain.Backends.
+const NotaryBackend = "notary"
+
+// ChainPolicy decides how the results of the backends of a VerificationChain combine.
+type ChainPolicy string
+
+const (
+	// ChainPolicyAny admits the image verified by any backend, the backends are asked in order until one verifies it.
+	ChainPolicyAny ChainPolicy = "any"
+	// ChainPolicyAll admits the image only when every backend verifies it.
+	ChainPolicyAll ChainPolicy = "all"
+)
+
+// VerificationChain verifies images with several backends instead of notary alone,
+// e.g. while images are migrated from notary to another signing system.
+type VerificationChain struct {
+	// Backends are asked in order, they are NotaryBackend or names of backends added with WithBackend.
+	Backends []string
+	// Policy is ChainPolicyAny when it's not set.
+	Policy ChainPolicy
+}
+
+func (c VerificationChain) enabled() bool {
+	return len(c.Backends) > 0
+}
+
+// usesNotary returns true when notary is asked, i.e. without the chain or when the chain lists NotaryBackend.
+func (c VerificationChain) usesNotary() bool {
+	if !c.enabled() {
+		return true
+	}
+	for _, backend := range c.Backends {
+		if backend == NotaryBackend {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate rejects chains which can't verify any image, backend names are checked by NewImageValidator.
+func (c VerificationChain) Validate() error {
+	if c.Policy != "" && c.Policy != ChainPolicyAny && c.Policy != ChainPolicyAll {
+		return fmt.Errorf("verification chain policy %q must be %q or %q", c.Policy, ChainPolicyAny, ChainPolicyAll)
+	}
+	if c.Policy != "" && !c.enabled() {
+		return fmt.Errorf("verification chain must have backends")
+	}
+	seen := map[string]bool{}
+	for _, backend := range c.Backends {
+		if backend == "" {
+			return fmt.Errorf("verification chain backend must have the name")
+		}
+		if seen[backend] {
+			return fmt.Errorf("verification chain backend %s is listed more than once", backend)
+		}
+		seen[backend] = true
+	}
+	return nil
+}
+
+// VerificationBackend verifies image signatures of a signing system other than notary, e.g. cosign.
+type VerificationBackend interface {
+	// Verify returns the result with OutcomeSignatureVerified when the image is signed.
+	// Images given to ValidateWithDigest are pinned to the digest ("<repo>:<tag>@<algorithm>:<hex>").
+	Verify(ctx context.Context, image string) (ImageValidationResult, error)
+}
+
+// BackendError identifies the backend of the chain which denied the image.
+type BackendError struct {
+	Backend string
+	Err     error
+}
+
+func (e BackendError) Error() string {
+	return fmt.Sprintf("backend %s: %s", e.Backend, e.Err)
+}
+
+func (e BackendError) Unwrap() error {
+	return e.Err
+}
+
+// validateChainBackends rejects chain backends which are neither notary nor added with WithBackend.
+func (s *notaryService) validateChainBackends() error {
+	for _, backend := range s.VerificationChain.Backends {
+		if _, ok := s.backends[backend]; !ok && backend != NotaryBackend {
+			return fmt.Errorf("verification chain backend %s isn't registered", backend)
+		}
+	}
+	return nil
+}
+
+// verifyChain asks the backends of the chain, notary verifies the image with verifyNotary.
+// Under ChainPolicyAll the result of the first backend is returned with VerifiedBy listing all of them.
+func (s *notaryService) verifyChain(ctx context.Context, image string, verifyNotary func(context.Context) (ImageValidationResult, error)) (ImageValidationResult, error) {
+	var (
+		verified ImageValidationResult
+		errs     []error
+	)
+	for _, backend := range s.VerificationChain.Backends {
+		var (
+			result ImageValidationResult
+			err    error
+		)
+		if backend == NotaryBackend {
+			result, err = verifyNotary(ctx)
+		} else {
+			result, err = s.backends[backend].Verify(ctx, image)
+		}
+		if err == nil && result.Outcome != OutcomeSignatureVerified {
+			err = policyError{fmt.Errorf("image isn't verified, the outcome is %s", result.Outcome)}
+		}
+		s.metrics.observeBackend(backend, err)
+
+		if err != nil {
+			err = BackendError{Backend: backend, Err: err}
+			if s.VerificationChain.Policy == ChainPolicyAll {
+				result.Outcome = OutcomeDenied
+				return result, err
+			}
+			errs = append(errs, err)
+			continue
+		}
+		if len(verified.VerifiedBy) == 0 {
+			verified = result
+		}
+		verified.VerifiedBy = append(verified.VerifiedBy, backend)
+		if s.VerificationChain.Policy != ChainPolicyAll {
+			return verified, nil
+		}
+	}
+	if len(errs) > 0 {
+		return ImageValidationResult{Outcome: OutcomeDenied}, chainError(errs)
+	}
+	return verified, nil
+}
+
+// chainError returns the only error as it is, so the denial of a single backend is classified by its cause.
+func chainError(errs []error) error {
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return utilerrors.NewAggregate(errs)
+}
@@ -0,0 +1,199 @@
+package validate
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+// fakeCosignVerifier verifies the images of signed repositories.
+type fakeCosignVerifier struct {
+	signed map[string]bool
+	images []string
+}
+
+func (v *fakeCosignVerifier) Verify(_ context.Context, image string) (ImageValidationResult, error) {
+	v.images = append(v.images, image)
+	repo := strings.SplitN(image, ":", 2)[0]
+	if !v.signed[repo] {
+		return ImageValidationResult{Outcome: OutcomeDenied}, errors.New("no matching signatures")
+	}
+	return ImageValidationResult{Outcome: OutcomeSignatureVerified, ResolvedDigest: repo + "@sha256:cosign"}, nil
+}
+
+func Test_Validate_VerificationChain(t *testing.T) {
+	image := "eu.gcr.io/kyma-project/image:tag"
+	transport, img := pushTestImageAs(t, image)
+	hash := configHash(t, img)
+
+	tests := []struct {
+		name               string
+		policy             ChainPolicy
+		backends           []string
+		notarySigned       bool
+		cosignSigned       bool
+		expectedVerifiedBy []string
+		expectedErr        string
+		expectedResults    map[string]float64
+	}{
+		{
+			name:               "any: notary verifies, cosign isn't asked",
+			policy:             ChainPolicyAny,
+			backends:           []string{NotaryBackend, "cosign"},
+			notarySigned:       true,
+			expectedVerifiedBy: []string{NotaryBackend},
+			expectedResults:    map[string]float64{NotaryBackend + "/verified": 1},
+		},
+		{
+			name:               "any: backends disagree, cosign verifies what notary denies",
+			policy:             ChainPolicyAny,
+			backends:           []string{NotaryBackend, "cosign"},
+			cosignSigned:       true,
+			expectedVerifiedBy: []string{"cosign"},
+			expectedResults:    map[string]float64{NotaryBackend + "/not_found": 1, "cosign/verified": 1},
+		},
+		{
+			name:            "any: no backend verifies",
+			policy:          ChainPolicyAny,
+			backends:        []string{NotaryBackend, "cosign"},
+			expectedErr:     "[backend notary: tag tag is not signed by any of roles [targets/releases targets]: No valid trust data for tag, backend cosign: no matching signatures]",
+			expectedResults: map[string]float64{NotaryBackend + "/not_found": 1, "cosign/other": 1},
+		},
+		{
+			name:               "default policy is any",
+			backends:           []string{"cosign", NotaryBackend},
+			cosignSigned:       true,
+			expectedVerifiedBy: []string{"cosign"},
+			expectedResults:    map[string]float64{"cosign/verified": 1},
+		},
+		{
+			name:               "all: both backends verify",
+			policy:             ChainPolicyAll,
+			backends:           []string{NotaryBackend, "cosign"},
+			notarySigned:       true,
+			cosignSigned:       true,
+			expectedVerifiedBy: []string{NotaryBackend, "cosign"},
+			expectedResults:    map[string]float64{NotaryBackend + "/verified": 1, "cosign/verified": 1},
+		},
+		{
+			name:            "all: backends disagree, cosign denies what notary verifies",
+			policy:          ChainPolicyAll,
+			backends:        []string{NotaryBackend, "cosign"},
+			notarySigned:    true,
+			expectedErr:     "backend cosign: no matching signatures",
+			expectedResults: map[string]float64{NotaryBackend + "/verified": 1, "cosign/other": 1},
+		},
+		{
+			name:            "all: notary denial stops the chain",
+			policy:          ChainPolicyAll,
+			backends:        []string{NotaryBackend, "cosign"},
+			cosignSigned:    true,
+			expectedErr:     "backend notary: tag tag is not signed by any of roles [targets/releases targets]: No valid trust data for tag",
+			expectedResults: map[string]float64{NotaryBackend + "/not_found": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//GIVEN
+			f := func(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+				if !tt.notarySigned {
+					return nil, client.ErrNoSuchTarget(name)
+				}
+				return &client.TargetWithRole{Target: client.Target{Name: name, Hashes: data.Hashes{SHA256Algorithm: hash}}, Role: NotaryReleasesRole}, nil
+			}
+			reg := prometheus.NewRegistry()
+			cosign := &fakeCosignVerifier{signed: map[string]bool{"eu.gcr.io/kyma-project/image": tt.cosignSigned}}
+			s := NewDefaultMockNotaryService().WithFunc(f).WithRegistryTransport(transport).WithMetrics(reg).Build()
+			s.VerificationChain = VerificationChain{Backends: tt.backends, Policy: tt.policy}
+			s.backends = map[string]VerificationBackend{"cosign": cosign}
+
+			//WHEN
+			result, err := s.ValidateDetailed(context.TODO(), image)
+
+			//THEN
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				require.Equal(t, OutcomeDenied, result.Outcome)
+				require.Empty(t, result.VerifiedBy)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, OutcomeSignatureVerified, result.Outcome)
+				require.Equal(t, tt.expectedVerifiedBy, result.VerifiedBy)
+				require.Equal(t, image, result.Image)
+			}
+			for backendResult, expected := range tt.expectedResults {
+				labels := strings.SplitN(backendResult, "/", 2)
+				require.Equal(t, expected, testutil.ToFloat64(s.metrics.backendResults.WithLabelValues(labels...)), backendResult)
+			}
+			require.Len(t, metricSeries(t, reg, "warden_verification_backend_results_total"), len(tt.expectedResults))
+		})
+	}
+}
+
+func Test_ValidateWithDigest_VerificationChain(t *testing.T) {
+	//GIVEN
+	hash, err := hex.DecodeString("2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae")
+	require.NoError(t, err)
+	digest := "sha256:" + hex.EncodeToString(hash)
+	f := func(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+		return &client.TargetWithRole{Target: client.Target{Name: name, Hashes: data.Hashes{SHA256Algorithm: hash}}, Role: NotaryReleasesRole}, nil
+	}
+	cosign := &fakeCosignVerifier{}
+	s := NewDefaultMockNotaryService().WithFunc(f).Build()
+	s.VerificationChain = VerificationChain{Backends: []string{NotaryBackend, "cosign"}, Policy: ChainPolicyAll}
+	s.backends = map[string]VerificationBackend{"cosign": cosign}
+
+	//WHEN
+	err = s.ValidateWithDigest(context.TODO(), "eu.gcr.io/kyma-project/image:tag", digest)
+
+	//THEN
+	require.EqualError(t, err, "backend cosign: no matching signatures")
+	require.Equal(t, []string{"eu.gcr.io/kyma-project/image:tag@" + digest}, cosign.images)
+}
+
+func TestNewImageValidator_VerificationChain(t *testing.T) {
+	t.Run("backend added with the option", func(t *testing.T) {
+		cosign := &fakeCosignVerifier{signed: map[string]bool{"eu.gcr.io/kyma-project/image": true}}
+		v, err := NewImageValidator(&ServiceConfig{VerificationChain: VerificationChain{Backends: []string{"cosign"}}},
+			WithBackend("cosign", cosign))
+		require.NoError(t, err)
+
+		result, err := v.ValidateDetailed(context.TODO(), "eu.gcr.io/kyma-project/image:tag")
+
+		require.NoError(t, err)
+		require.Equal(t, []string{"cosign"}, result.VerifiedBy)
+	})
+
+	t.Run("backend which isn't registered", func(t *testing.T) {
+		_, err := NewImageValidator(&ServiceConfig{
+			NotaryConfig:      NotaryConfig{Url: "https://notary"},
+			VerificationChain: VerificationChain{Backends: []string{NotaryBackend, "cosign"}},
+		})
+
+		require.EqualError(t, err, "verification chain backend cosign isn't registered")
+	})
+}
+
+// metricSeries returns the series of the metric family.
+func metricSeries(t *testing.T, reg *prometheus.Registry, name string) []string {
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	var series []string
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			series = append(series, m.String())
+		}
+	}
+	return series
+}
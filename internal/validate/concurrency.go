@@ -0,0 +1,45 @@
+package validate
+
+import "context"
+
+const (
+	DefaultMaxConcurrentRegistryCalls = 16
+)
+
+// registryCallLimiter bounds the number of registry calls executed at the same time.
+type registryCallLimiter struct {
+	slots   chan struct{}
+	metrics *phaseMetrics
+}
+
+func newRegistryCallLimiter(max int, m *phaseMetrics) *registryCallLimiter {
+	if max <= 0 {
+		max = DefaultMaxConcurrentRegistryCalls
+	}
+	return &registryCallLimiter{
+		slots:   make(chan struct{}, max),
+		metrics: m,
+	}
+}
+
+// Acquire blocks until there is a free slot or the context is done.
+func (l *registryCallLimiter) Acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		l.metrics.addRegistryInflightCalls(1)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *registryCallLimiter) Release() {
+	if l == nil {
+		return
+	}
+	<-l.slots
+	l.metrics.addRegistryInflightCalls(-1)
+}
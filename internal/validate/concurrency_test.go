@@ -0,0 +1,92 @@
+package validate
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_getImageDigestHash_ConcurrentRegistryCallsAreBounded(t *testing.T) {
+	//GIVEN
+	const (
+		maxConcurrentCalls = 8
+		validations        = 200
+	)
+	var inflight, maxInflight int32
+	reg := registry.New()
+	slowRegistry := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt32(&inflight, 1)
+		defer atomic.AddInt32(&inflight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInflight)
+			if current <= max || atomic.CompareAndSwapInt32(&maxInflight, max, current) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		reg.ServeHTTP(w, r)
+	})
+	srv := httptest.NewServer(slowRegistry)
+	defer srv.Close()
+
+	image := strings.TrimPrefix(srv.URL, "http://") + "/test/image:tag"
+	img, err := random.Image(128, 1)
+	require.NoError(t, err)
+	ref, err := name.ParseReference(image)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img))
+	atomic.StoreInt32(&maxInflight, 0)
+
+	s := NewDefaultMockNotaryService().
+		WithMaxConcurrentRegistryCalls(maxConcurrentCalls).
+		WithInsecureRegistries(registryHost(image)).
+		Build()
+
+	//WHEN
+	wg := sync.WaitGroup{}
+	errs := make(chan error, validations)
+	for i := 0; i < validations; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.getImageDigestHash(context.TODO(), image, SHA256Algorithm)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	//THEN
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	require.LessOrEqual(t, atomic.LoadInt32(&maxInflight), int32(maxConcurrentCalls))
+	require.Greater(t, atomic.LoadInt32(&maxInflight), int32(1))
+}
+
+func Test_registryCallLimiter_AcquireRespectsContext(t *testing.T) {
+	//GIVEN
+	l := newRegistryCallLimiter(1, nil)
+	require.NoError(t, l.Acquire(context.TODO()))
+	defer l.Release()
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+
+	//WHEN
+	err := l.Acquire(ctx)
+
+	//THEN
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,179 @@
+package validate
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// NewServiceConfig returns the copy of sc when it's valid, see ServiceConfig.Validate.
+func NewServiceConfig(sc ServiceConfig) (*ServiceConfig, error) {
+	if err := sc.Validate(); err != nil {
+		return nil, err
+	}
+	return &sc, nil
+}
+
+// Validate reports every problem of the config at once, so misconfigurations are found at startup
+// instead of at the first admission. The error is an aggregate of the problems.
+func (sc *ServiceConfig) Validate() error {
+	return sc.validate(true)
+}
+
+// validate checks the notary URL only when requireNotaryURL is set, because repository factories other than
+// NotaryRepoFactory, e.g. fakes of tests, don't need it. Chains without NotaryBackend don't need it either.
+func (sc *ServiceConfig) validate(requireNotaryURL bool) error {
+	var errs []error
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	nc := sc.NotaryConfig
+	if nc.Url == "" && nc.HarborURL == "" && sc.OfflineTrustBundle == nil && requireNotaryURL &&
+		len(sc.AllowedRegistries) == 0 && sc.AllowedRegistriesFile == "" && sc.VerificationChain.usesNotary() {
+		add("notary URL is required unless the Harbor URL, the offline trust bundle or allowed registries are set")
+	}
+	for _, u := range append([]string{nc.Url, nc.HarborURL}, nc.FallbackUrls...) {
+		if err := validateServerURL(u); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if sc.OfflineTrustBundle != nil && len(nc.FallbackUrls) > 0 {
+		add("notary fallback URLs and the offline trust bundle are mutually exclusive")
+	}
+	if nc.Password != "" && nc.PasswordFile != "" {
+		add("notary password and password file are mutually exclusive")
+	}
+	if (nc.Password != "" || nc.PasswordFile != "") && nc.Username == "" {
+		add("notary password requires the username")
+	}
+	if err := validateNotaryTLS(nc.TLS); err != nil {
+		errs = append(errs, err)
+	}
+	errs = append(errs, validateNotaryHosts(nc.Hosts)...)
+	if sc.DisableNegativeCache && (sc.NegativeCacheTTL != 0 || sc.NegativeCacheMaxEntries != 0) {
+		add("disabled negative cache and negative cache settings are mutually exclusive")
+	}
+	if sc.RegistryMirrorFallback && len(sc.RegistryMirrors) == 0 {
+		add("registry mirror fallback requires registry mirrors")
+	}
+
+	for _, entry := range sc.AllowedRegistries {
+		if err := validateAllowedEntry(entry); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, host := range sc.InsecureRegistries {
+		if err := validateRegistryHost(host); err != nil {
+			add("insecure registry: %w", err)
+		}
+	}
+	upstreams := make([]string, 0, len(sc.RegistryMirrors))
+	for upstream := range sc.RegistryMirrors {
+		upstreams = append(upstreams, upstream)
+	}
+	sort.Strings(upstreams)
+	for _, upstream := range upstreams {
+		for _, host := range []string{upstream, sc.RegistryMirrors[upstream]} {
+			if err := validateRegistryHost(host); err != nil {
+				add("registry mirror %s: %w", upstream, err)
+			}
+		}
+	}
+	if sc.Platform != "" {
+		if _, err := parsePlatform(sc.Platform); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, e := range sc.Exceptions {
+		if err := e.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := sc.PinnedDigests.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	errs = append(errs, validateHostOverrides(sc.HostOverrides)...)
+	if err := sc.VerificationChain.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, setting := range []struct {
+		name     string
+		negative bool
+	}{
+		{"notary timeout", sc.NotaryTimeout < 0},
+		{"registry timeout", sc.RegistryTimeout < 0},
+		{"negative cache TTL", sc.NegativeCacheTTL < 0},
+		{"negative cache max entries", sc.NegativeCacheMaxEntries < 0},
+		{"digest cache TTL", sc.DigestCacheTTL < 0},
+		{"slow validation threshold", sc.SlowValidationThreshold < 0},
+		{"max concurrent registry calls", sc.MaxConcurrentRegistryCalls < 0},
+		{"max registry response bytes", sc.MaxRegistryResponseBytes < 0},
+		{"notary expired metadata grace", nc.ExpiredMetadataGrace < 0},
+		{"notary max signature age", nc.MaxSignatureAge < 0},
+		{"notary max response bytes", nc.MaxResponseBytes < 0},
+		{"notary requests per second", nc.RequestsPerSecond < 0},
+		{"notary burst", nc.Burst < 0},
+	} {
+		if setting.negative {
+			add("%s must not be negative", setting.name)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// validateConfig validates the config the service was created with.
+func (s *notaryService) validateConfig() error {
+	var requireNotaryURL bool
+	switch s.RepoFactory.(type) {
+	case NotaryRepoFactory, *NotaryRepoFactory:
+		requireNotaryURL = true
+	}
+	if err := s.ServiceConfig.validate(requireNotaryURL); err != nil {
+		return err
+	}
+	return s.validateChainBackends()
+}
+
+// validateServerURL accepts empty URLs, they are checked by the rules which require them.
+func validateServerURL(u string) error {
+	if u == "" {
+		return nil
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return fmt.Errorf("notary URL %s: %w", u, err)
+	}
+	if (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return fmt.Errorf("notary URL %s must have the http or https scheme and the host", u)
+	}
+	return nil
+}
+
+// validateAllowedEntry rejects entries which can't match any image reference.
+func validateAllowedEntry(entry string) error {
+	if strings.TrimSpace(entry) == "" {
+		return fmt.Errorf("allowed registry entry must not be empty")
+	}
+	if strings.ContainsAny(entry, " \t\n") || strings.Contains(entry, "://") {
+		return fmt.Errorf("allowed registry entry %q must be a repository prefix without the scheme and spaces", entry)
+	}
+	if e := parseAllowedEntry(entry); e.tagPattern != "" {
+		if _, err := path.Match(e.tagPattern, ""); err != nil {
+			return fmt.Errorf("allowed registry entry %q has malformed tag pattern: %w", entry, err)
+		}
+	}
+	return nil
+}
+
+func validateRegistryHost(host string) error {
+	if host == "" || strings.ContainsAny(host, "/ \t\n") {
+		return fmt.Errorf("%q must be a registry host without the scheme and the path", host)
+	}
+	return nil
+}
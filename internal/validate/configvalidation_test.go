@@ -0,0 +1,301 @@
+package validate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+func TestServiceConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		modify      func(sc *ServiceConfig)
+		expectedErr string
+	}{
+		{
+			name:   "valid config",
+			modify: func(sc *ServiceConfig) {},
+		},
+		{
+			name:        "empty notary URL without allowed registries",
+			modify:      func(sc *ServiceConfig) { sc.NotaryConfig.Url = "" },
+			expectedErr: "notary URL is required",
+		},
+		{
+			name: "empty notary URL with allowed registries",
+			modify: func(sc *ServiceConfig) {
+				sc.NotaryConfig.Url = ""
+				sc.AllowedRegistries = []string{"eu.gcr.io/kyma-project/"}
+			},
+		},
+		{
+			name: "empty notary URL with the Harbor URL",
+			modify: func(sc *ServiceConfig) {
+				sc.NotaryConfig.Url = ""
+				sc.NotaryConfig.HarborURL = "https://harbor.example.com"
+			},
+		},
+		{
+			name:        "notary URL without scheme",
+			modify:      func(sc *ServiceConfig) { sc.NotaryConfig.Url = "notary.example.com" },
+			expectedErr: "notary URL notary.example.com must have the http or https scheme and the host",
+		},
+		{
+			name:        "malformed notary URL",
+			modify:      func(sc *ServiceConfig) { sc.NotaryConfig.Url = "https://notary example.com" },
+			expectedErr: "notary URL https://notary example.com",
+		},
+		{
+			name:        "malformed Harbor URL",
+			modify:      func(sc *ServiceConfig) { sc.NotaryConfig.HarborURL = "ftp://harbor.example.com" },
+			expectedErr: "notary URL ftp://harbor.example.com must have the http or https scheme",
+		},
+		{
+			name:        "malformed fallback URL",
+			modify:      func(sc *ServiceConfig) { sc.NotaryConfig.FallbackUrls = []string{"https://"} },
+			expectedErr: "notary URL https:// must have the http or https scheme and the host",
+		},
+		{
+			name: "fallback URLs with the offline trust bundle",
+			modify: func(sc *ServiceConfig) {
+				sc.NotaryConfig.FallbackUrls = []string{"https://notary-secondary"}
+				sc.OfflineTrustBundle = &OfflineTrustBundle{}
+			},
+			expectedErr: "notary fallback URLs and the offline trust bundle are mutually exclusive",
+		},
+		{
+			name: "password with password file",
+			modify: func(sc *ServiceConfig) {
+				sc.NotaryConfig.Username = "warden"
+				sc.NotaryConfig.Password = "secret"
+				sc.NotaryConfig.PasswordFile = "/etc/notary/password"
+			},
+			expectedErr: "notary password and password file are mutually exclusive",
+		},
+		{
+			name:        "password without username",
+			modify:      func(sc *ServiceConfig) { sc.NotaryConfig.PasswordFile = "/etc/notary/password" },
+			expectedErr: "notary password requires the username",
+		},
+		{
+			name: "disabled negative cache with its TTL",
+			modify: func(sc *ServiceConfig) {
+				sc.DisableNegativeCache = true
+				sc.NegativeCacheTTL = time.Minute
+			},
+			expectedErr: "disabled negative cache and negative cache settings are mutually exclusive",
+		},
+		{
+			name:        "mirror fallback without mirrors",
+			modify:      func(sc *ServiceConfig) { sc.RegistryMirrorFallback = true },
+			expectedErr: "registry mirror fallback requires registry mirrors",
+		},
+		{
+			name:        "empty allowed registry",
+			modify:      func(sc *ServiceConfig) { sc.AllowedRegistries = []string{" "} },
+			expectedErr: "allowed registry entry must not be empty",
+		},
+		{
+			name:        "allowed registry with scheme",
+			modify:      func(sc *ServiceConfig) { sc.AllowedRegistries = []string{"https://eu.gcr.io/kyma-project/"} },
+			expectedErr: `allowed registry entry "https://eu.gcr.io/kyma-project/" must be a repository prefix`,
+		},
+		{
+			name:        "allowed registry with malformed tag pattern",
+			modify:      func(sc *ServiceConfig) { sc.AllowedRegistries = []string{"eu.gcr.io/kyma-project/app:[v1"} },
+			expectedErr: `allowed registry entry "eu.gcr.io/kyma-project/app:[v1" has malformed tag pattern`,
+		},
+		{
+			name:        "insecure registry with scheme",
+			modify:      func(sc *ServiceConfig) { sc.InsecureRegistries = []string{"http://registry.local"} },
+			expectedErr: `insecure registry: "http://registry.local" must be a registry host`,
+		},
+		{
+			name:        "registry mirror with path",
+			modify:      func(sc *ServiceConfig) { sc.RegistryMirrors = map[string]string{"docker.io": "mirror.local/docker"} },
+			expectedErr: `registry mirror docker.io: "mirror.local/docker" must be a registry host`,
+		},
+		{
+			name:        "malformed platform",
+			modify:      func(sc *ServiceConfig) { sc.Platform = "linux/amd64/v8/extra" },
+			expectedErr: "linux/amd64/v8/extra",
+		},
+		{
+			name:        "exception without expiry",
+			modify:      func(sc *ServiceConfig) { sc.Exceptions = []ImageException{{Image: "eu.gcr.io/kyma-project/app:1.0"}} },
+			expectedErr: "image exception for eu.gcr.io/kyma-project/app:1.0 must have the expiry time",
+		},
+		{
+			name:        "malformed pinned digest",
+			modify:      func(sc *ServiceConfig) { sc.PinnedDigests = PinnedDigests{"busybox": {"sha256:2c26"}} },
+			expectedErr: "pinned digest sha256:2c26 of busybox",
+		},
+		{
+			name: "host override with scheme",
+			modify: func(sc *ServiceConfig) {
+				sc.HostOverrides = map[string]HostOverride{"https://partner.example.com": {RegistryTimeout: time.Second}}
+			},
+			expectedErr: `host override: "https://partner.example.com" must be a registry host`,
+		},
+		{
+			name: "negative host override",
+			modify: func(sc *ServiceConfig) {
+				sc.HostOverrides = map[string]HostOverride{"partner.example.com": {NotaryTimeout: -time.Second}}
+			},
+			expectedErr: "host override partner.example.com: timeouts and retries must not be negative",
+		},
+		{
+			name: "empty notary URL with the chain without notary",
+			modify: func(sc *ServiceConfig) {
+				sc.NotaryConfig.Url = ""
+				sc.VerificationChain = VerificationChain{Backends: []string{"cosign"}}
+			},
+		},
+		{
+			name: "unknown verification chain policy",
+			modify: func(sc *ServiceConfig) {
+				sc.VerificationChain = VerificationChain{Backends: []string{NotaryBackend}, Policy: "first"}
+			},
+			expectedErr: `verification chain policy "first" must be "any" or "all"`,
+		},
+		{
+			name:        "verification chain policy without backends",
+			modify:      func(sc *ServiceConfig) { sc.VerificationChain = VerificationChain{Policy: ChainPolicyAll} },
+			expectedErr: "verification chain must have backends",
+		},
+		{
+			name: "duplicate verification chain backend",
+			modify: func(sc *ServiceConfig) {
+				sc.VerificationChain = VerificationChain{Backends: []string{NotaryBackend, "cosign", NotaryBackend}}
+			},
+			expectedErr: "verification chain backend notary is listed more than once",
+		},
+		{
+			name:        "notary client certificate without key",
+			modify:      func(sc *ServiceConfig) { sc.NotaryConfig.TLS = NotaryTLSConfig{CertFile: "/etc/notary/tls.crt"} },
+			expectedErr: "notary client certificate and key files must be set together",
+		},
+		{
+			name: "notary host with scheme",
+			modify: func(sc *ServiceConfig) {
+				sc.NotaryConfig.Hosts = map[string]NotaryHostConfig{"https://harbor.example.com": {Timeout: time.Second}}
+			},
+			expectedErr: `notary host: "https://harbor.example.com" must be a registry host`,
+		},
+		{
+			name: "notary host password without username",
+			modify: func(sc *ServiceConfig) {
+				sc.NotaryConfig.Hosts = map[string]NotaryHostConfig{"harbor.example.com": {PasswordFile: "/etc/harbor/password"}}
+			},
+			expectedErr: "notary host harbor.example.com: password requires the username",
+		},
+		{
+			name: "notary host with client key without certificate",
+			modify: func(sc *ServiceConfig) {
+				sc.NotaryConfig.Hosts = map[string]NotaryHostConfig{"harbor.example.com": {TLS: &NotaryTLSConfig{KeyFile: "/etc/harbor/tls.key"}}}
+			},
+			expectedErr: "notary host harbor.example.com: notary client certificate and key files must be set together",
+		},
+		{
+			name: "negative notary host timeout",
+			modify: func(sc *ServiceConfig) {
+				sc.NotaryConfig.Hosts = map[string]NotaryHostConfig{"harbor.example.com": {Timeout: -time.Second}}
+			},
+			expectedErr: "notary host harbor.example.com: timeout must not be negative",
+		},
+		{
+			name:        "negative notary timeout",
+			modify:      func(sc *ServiceConfig) { sc.NotaryTimeout = -time.Second },
+			expectedErr: "notary timeout must not be negative",
+		},
+		{
+			name:        "negative registry timeout",
+			modify:      func(sc *ServiceConfig) { sc.RegistryTimeout = -time.Second },
+			expectedErr: "registry timeout must not be negative",
+		},
+		{
+			name:        "negative digest cache TTL",
+			modify:      func(sc *ServiceConfig) { sc.DigestCacheTTL = -time.Second },
+			expectedErr: "digest cache TTL must not be negative",
+		},
+		{
+			name:        "negative notary burst",
+			modify:      func(sc *ServiceConfig) { sc.NotaryConfig.Burst = -1 },
+			expectedErr: "notary burst must not be negative",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//GIVEN
+			sc := &ServiceConfig{NotaryConfig: NotaryConfig{Url: "https://notary"}}
+			tt.modify(sc)
+
+			//WHEN
+			err := sc.Validate()
+
+			//THEN
+			if tt.expectedErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestServiceConfig_Validate_ListsEveryProblem(t *testing.T) {
+	//GIVEN
+	sc := &ServiceConfig{
+		NotaryTimeout:      -time.Second,
+		InsecureRegistries: []string{""},
+	}
+
+	//WHEN
+	err := sc.Validate()
+
+	//THEN
+	var aggregate utilerrors.Aggregate
+	require.ErrorAs(t, err, &aggregate)
+	require.Len(t, aggregate.Errors(), 3)
+}
+
+func TestNewServiceConfig(t *testing.T) {
+	t.Run("valid config", func(t *testing.T) {
+		sc, err := NewServiceConfig(ServiceConfig{NotaryConfig: NotaryConfig{Url: "https://notary"}})
+
+		require.NoError(t, err)
+		require.Equal(t, "https://notary", sc.NotaryConfig.Url)
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		sc, err := NewServiceConfig(ServiceConfig{})
+
+		require.ErrorContains(t, err, "notary URL is required")
+		require.Nil(t, sc)
+	})
+}
+
+func TestNewImageValidator_InvalidConfig(t *testing.T) {
+	t.Run("invalid config is refused", func(t *testing.T) {
+		v, err := NewImageValidator(&ServiceConfig{NotaryConfig: NotaryConfig{Url: "https://notary"}, RegistryTimeout: -time.Second})
+
+		require.ErrorContains(t, err, "registry timeout must not be negative")
+		require.Nil(t, v)
+	})
+
+	t.Run("repository factories other than notary don't need the notary URL", func(t *testing.T) {
+		_, err := NewImageValidator(&ServiceConfig{}, WithRepoFactory(MockNotaryRepoFactory{}))
+
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid namespace patch is refused", func(t *testing.T) {
+		v, err := NewNamespacedImageValidators(&ServiceConfig{NotaryConfig: NotaryConfig{Url: "https://notary"}},
+			map[string]ServiceConfigPatch{"tools": {NotaryURL: "notary.tools"}})
+
+		require.ErrorContains(t, err, "namespace tools: notary URL notary.tools must have the http or https scheme")
+		require.Nil(t, v)
+	})
+}
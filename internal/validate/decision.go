@@ -0,0 +1,85 @@
+package validate
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultDecisionHookQueueSize bounds the events waiting for the decision hook, newer events are dropped when it's full.
+const DefaultDecisionHookQueueSize = 1024
+
+// ValidationEvent describes the decision about the image for the decision hook.
+type ValidationEvent struct {
+	Time      time.Time
+	Image     string
+	Outcome   Outcome
+	Durations PhaseDurations
+	// ErrorClass and Error are empty for admitted images.
+	ErrorClass ErrorClassification
+	Error      string
+}
+
+func newValidationEvent(image string, result ImageValidationResult, err error) ValidationEvent {
+	event := ValidationEvent{
+		Time:      time.Now().UTC(),
+		Image:     image,
+		Outcome:   result.Outcome,
+		Durations: result.Durations,
+	}
+	if err != nil {
+		event.Outcome = OutcomeDenied
+		event.ErrorClass = ErrorClass(err)
+		event.Error = err.Error()
+	}
+	return event
+}
+
+// decisionHook calls the hook from its own goroutine, so a slow or panicking hook never holds back the validation.
+// Validators created with the same option share the queue.
+type decisionHook struct {
+	hook  func(ValidationEvent)
+	queue chan queuedEvent
+	start sync.Once
+}
+
+type queuedEvent struct {
+	event   ValidationEvent
+	metrics *phaseMetrics
+}
+
+func newDecisionHook(hook func(ValidationEvent), size int) *decisionHook {
+	return &decisionHook{
+		hook:  hook,
+		queue: make(chan queuedEvent, size),
+	}
+}
+
+// emit queues the event without blocking, the event is dropped and counted when the queue is full.
+func (h *decisionHook) emit(event ValidationEvent, m *phaseMetrics) {
+	if h == nil {
+		return
+	}
+	h.start.Do(func() {
+		go h.run()
+	})
+	select {
+	case h.queue <- queuedEvent{event: event, metrics: m}:
+	default:
+		m.observeDecisionHookDrop()
+	}
+}
+
+func (h *decisionHook) run() {
+	for queued := range h.queue {
+		h.call(queued)
+	}
+}
+
+func (h *decisionHook) call(queued queuedEvent) {
+	defer func() {
+		if r := recover(); r != nil {
+			queued.metrics.observeDecisionHookPanic()
+		}
+	}()
+	h.hook(queued.event)
+}
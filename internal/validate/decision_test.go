@@ -0,0 +1,145 @@
+package validate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+func TestWithDecisionHook(t *testing.T) {
+	image := "eu.gcr.io/kyma-project/function-controller:pinned"
+	registryTransport, img := pushTestImageAs(t, image)
+	hash := configHash(t, img)
+
+	t.Run("events describe the decisions", func(t *testing.T) {
+		//GIVEN
+		events := make(chan ValidationEvent, 10)
+		f := func(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+			if name != "pinned" {
+				return nil, client.ErrNoSuchTarget(name)
+			}
+			return &client.TargetWithRole{Target: client.Target{Name: name, Hashes: data.Hashes{SHA256Algorithm: hash}}, Role: NotaryReleasesRole}, nil
+		}
+		s := NewDefaultMockNotaryService().WithFunc(f).WithRegistryTransport(registryTransport).Build()
+		s.AllowedRegistries = []string{"eu.gcr.io/kyma-project/allowed"}
+		s.decisionHook = newDecisionHook(func(event ValidationEvent) { events <- event }, DefaultDecisionHookQueueSize)
+
+		//WHEN
+		require.NoError(t, s.Validate(context.TODO(), image))
+		require.NoError(t, s.Validate(context.TODO(), "eu.gcr.io/kyma-project/allowed:1.0"))
+		require.Error(t, s.Validate(context.TODO(), "eu.gcr.io/kyma-project/function-controller:unsigned"))
+		require.Error(t, s.Validate(context.TODO(), "eu.gcr.io/kyma-project/function-controller"))
+
+		//THEN
+		verified := <-events
+		require.Equal(t, image, verified.Image)
+		require.Equal(t, OutcomeSignatureVerified, verified.Outcome)
+		require.Empty(t, verified.ErrorClass)
+		require.Empty(t, verified.Error)
+		require.Greater(t, verified.Durations.Notary, time.Duration(0))
+		require.Greater(t, verified.Durations.Registry, time.Duration(0))
+		require.False(t, verified.Time.IsZero())
+
+		allowed := <-events
+		require.Equal(t, "eu.gcr.io/kyma-project/allowed:1.0", allowed.Image)
+		require.Equal(t, OutcomeAllowedList, allowed.Outcome)
+
+		unsigned := <-events
+		require.Equal(t, OutcomeDenied, unsigned.Outcome)
+		require.Equal(t, ClassPolicy, unsigned.ErrorClass)
+		require.Contains(t, unsigned.Error, "No valid trust data for unsigned")
+
+		malformed := <-events
+		require.Equal(t, OutcomeDenied, malformed.Outcome)
+		require.Equal(t, ClassPolicy, malformed.ErrorClass)
+		require.Equal(t, "image name is not formatted correctly", malformed.Error)
+	})
+
+	t.Run("infrastructure failures are reported with their class", func(t *testing.T) {
+		//GIVEN
+		events := make(chan ValidationEvent, 1)
+		s := NewDefaultMockNotaryService().WithRepoFactory(MockNotaryRepoFactoryNoSuchHost{}).Build()
+		s.decisionHook = newDecisionHook(func(event ValidationEvent) { events <- event }, DefaultDecisionHookQueueSize)
+
+		//WHEN
+		err := s.Validate(context.TODO(), image)
+
+		//THEN
+		require.Error(t, err)
+		event := <-events
+		require.Equal(t, OutcomeDenied, event.Outcome)
+		require.Equal(t, ClassInfrastructure, event.ErrorClass)
+	})
+
+	t.Run("blocking hook doesn't stall the validation", func(t *testing.T) {
+		//GIVEN
+		reg := prometheus.NewRegistry()
+		release := make(chan struct{})
+		defer close(release)
+		s := NewDefaultMockNotaryService().WithMetrics(reg).Build()
+		s.AllowedRegistries = []string{"eu.gcr.io/kyma-project/"}
+		s.decisionHook = newDecisionHook(func(ValidationEvent) { <-release }, 1)
+		start := time.Now()
+
+		//WHEN
+		for i := 0; i < 10; i++ {
+			require.NoError(t, s.Validate(context.TODO(), image))
+		}
+
+		//THEN
+		require.Less(t, time.Since(start), time.Second)
+		// the queue holds one event and the hook may hold another one
+		require.GreaterOrEqual(t, testutil.ToFloat64(s.metrics.hookDrops), float64(8))
+	})
+
+	t.Run("panicking hook is recovered", func(t *testing.T) {
+		//GIVEN
+		reg := prometheus.NewRegistry()
+		events := make(chan ValidationEvent, 2)
+		s := NewDefaultMockNotaryService().WithMetrics(reg).Build()
+		s.AllowedRegistries = []string{"eu.gcr.io/kyma-project/"}
+		s.decisionHook = newDecisionHook(func(event ValidationEvent) {
+			if event.Image == "eu.gcr.io/kyma-project/panic:1.0" {
+				panic("hook failed")
+			}
+			events <- event
+		}, DefaultDecisionHookQueueSize)
+
+		//WHEN
+		require.NoError(t, s.Validate(context.TODO(), "eu.gcr.io/kyma-project/panic:1.0"))
+		require.NoError(t, s.Validate(context.TODO(), image))
+
+		//THEN
+		event := <-events
+		require.Equal(t, image, event.Image)
+		require.Equal(t, float64(1), testutil.ToFloat64(s.metrics.hookPanics))
+	})
+
+	t.Run("option shares the hook between validators", func(t *testing.T) {
+		//GIVEN
+		events := make(chan ValidationEvent, 2)
+		f := func(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+			return nil, client.ErrNoSuchTarget(name)
+		}
+		validators, err := NewNamespacedImageValidators(&ServiceConfig{},
+			map[string]ServiceConfigPatch{"tools": {NotaryURL: "https://notary.tools"}},
+			WithRepoFactory(MockNotaryRepoFactory{GetTargetByNameFunc: &f}),
+			WithMetrics(prometheus.NewRegistry()),
+			WithDecisionHook(func(event ValidationEvent) { events <- event }))
+		require.NoError(t, err)
+
+		//WHEN
+		require.Error(t, validators.GetValidator("default").Validate(context.TODO(), "eu.gcr.io/kyma-project/app:1.0"))
+		require.Error(t, validators.GetValidator("tools").Validate(context.TODO(), "eu.gcr.io/kyma-project/app:2.0"))
+
+		//THEN
+		images := []string{(<-events).Image, (<-events).Image}
+		require.ElementsMatch(t, []string{"eu.gcr.io/kyma-project/app:1.0", "eu.gcr.io/kyma-project/app:2.0"}, images)
+	})
+}
@@ -0,0 +1,74 @@
+package validate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// InvalidDigestError is returned by ValidateWithDigest for digests which are not in the "<algorithm>:<hex>" format.
+type InvalidDigestError struct {
+	Digest string
+	Err    error
+}
+
+func (e InvalidDigestError) Error() string {
+	return fmt.Sprintf("invalid image digest %s: %s", e.Digest, e.Err)
+}
+
+func (e InvalidDigestError) Class() ErrorClassification {
+	return ClassPolicy
+}
+
+func (e InvalidDigestError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateWithDigest compares the digest against the one signed in notary for the image tag.
+// The digest may also be given as a container image ID ("<repo>@<algorithm>:<hex>"), it replaces a digest pinned in the image.
+// The registry isn't asked, so images whose tags were removed from the registry can still be validated.
+// Pinned digests, the allowed registries and the exceptions are applied like in Validate, with the image pinned to the digest.
+func (s *notaryService) ValidateWithDigest(ctx context.Context, image, digest string) error {
+	image, err := sanitizeImageRef(image)
+	if err != nil {
+		return err
+	}
+	ref, err := parseImageRef(image)
+	if err != nil {
+		return err
+	}
+	if ref.isDigestOnly() {
+		// the digest is compared with the one signed for the tag
+		return errMalformedImageName
+	}
+	algorithm, hash, err := parseDigest(digest[strings.LastIndex(digest, digestDelim)+1:])
+	if err != nil {
+		return InvalidDigestError{Digest: digest, Err: err}
+	}
+
+	ref.digestAlgorithm = algorithm
+	ref.digest = hash
+	if _, ok := s.matchPinnedDigest(ref.String(), nil); ok {
+		return nil
+	}
+	if _, decided, err := s.decideByPolicy(image, ref, nil); decided {
+		return err
+	}
+	if s.VerificationChain.enabled() {
+		_, err := s.verifyChain(ctx, ref.String(), func(ctx context.Context) (ImageValidationResult, error) {
+			return ImageValidationResult{Outcome: OutcomeSignatureVerified}, s.verifyWithDigest(ctx, ref)
+		})
+		return err
+	}
+	return s.verifyWithDigest(ctx, ref)
+}
+
+// verifyWithDigest compares the digest pinned in ref against notary.
+func (s *notaryService) verifyWithDigest(ctx context.Context, ref imageRef) error {
+	// the timings aren't reported without the detailed result, only timeout errors break them down
+	signed, err := s.notaryPhase(contextWithRequestTimings(ctx, newRequestTimings()), ref.repo, ref.tag)
+	if err != nil {
+		return err
+	}
+	return verifyPinnedDigest(ref, signed)
+}
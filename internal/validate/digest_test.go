@@ -0,0 +1,176 @@
+package validate
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestValidateWithDigest(t *testing.T) {
+	image := "eu.gcr.io/kyma-project/image:1.0"
+	signedHash := []byte{1, 2, 3, 4}
+	signedDigest := "sha256:" + hex.EncodeToString(signedHash)
+
+	t.Run("no registry traffic with reachable registry", func(t *testing.T) {
+		//GIVEN
+		registryTransport, _ := pushTestImageAs(t, image)
+		counting := &countingTransport{next: registryTransport}
+		s := NewDefaultMockNotaryService().WithHash(signedHash).WithRegistryTransport(counting).Build()
+
+		//WHEN
+		err := s.ValidateWithDigest(context.TODO(), image, signedDigest)
+
+		//THEN
+		require.NoError(t, err)
+		require.Zero(t, atomic.LoadInt32(&counting.requests))
+	})
+
+	t.Run("no registry traffic with unreachable registry", func(t *testing.T) {
+		//GIVEN
+		counting := &countingTransport{next: unreachableTransport{}}
+		s := NewDefaultMockNotaryService().WithHash(signedHash).WithRegistryTransport(counting).Build()
+
+		//WHEN
+		err := s.ValidateWithDigest(context.TODO(), image, signedDigest)
+
+		//THEN
+		require.NoError(t, err)
+		require.Zero(t, atomic.LoadInt32(&counting.requests))
+	})
+
+	t.Run("container image ID", func(t *testing.T) {
+		//GIVEN
+		s := NewDefaultMockNotaryService().WithHash(signedHash).WithRegistryTransport(unreachableTransport{}).Build()
+
+		//WHEN
+		err := s.ValidateWithDigest(context.TODO(), image, "docker-pullable://eu.gcr.io/kyma-project/image@"+signedDigest)
+
+		//THEN
+		require.NoError(t, err)
+	})
+
+	t.Run("digest mismatch", func(t *testing.T) {
+		//GIVEN
+		s := NewDefaultMockNotaryService().WithHash(signedHash).WithRegistryTransport(unreachableTransport{}).Build()
+		otherDigest := "sha256:" + hex.EncodeToString([]byte{4, 3, 2, 1})
+
+		//WHEN
+		err := s.ValidateWithDigest(context.TODO(), image, otherDigest)
+
+		//THEN
+		var mismatch DigestMismatchError
+		require.True(t, errors.As(err, &mismatch))
+		require.Equal(t, DigestMismatchError{Tag: "1.0", PinnedDigest: otherDigest}, mismatch)
+	})
+
+	t.Run("malformed digests", func(t *testing.T) {
+		s := NewDefaultMockNotaryService().WithHash(signedHash).WithRegistryTransport(unreachableTransport{}).Build()
+
+		for _, digest := range []string{"", "sha256", "sha256:not-hex", "md5:01020304"} {
+			t.Run(digest, func(t *testing.T) {
+				err := s.ValidateWithDigest(context.TODO(), image, digest)
+
+				var invalid InvalidDigestError
+				require.True(t, errors.As(err, &invalid))
+				require.Equal(t, digest, invalid.Digest)
+			})
+		}
+	})
+
+	t.Run("allowed image isn't looked up in notary", func(t *testing.T) {
+		//GIVEN
+		var calls int32
+		f := func(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+			atomic.AddInt32(&calls, 1)
+			return nil, client.ErrNoSuchTarget(name)
+		}
+		s := NewDefaultMockNotaryService().WithFunc(f).Build()
+		s.AllowedRegistries = []string{"eu.gcr.io/kyma-project"}
+
+		//WHEN
+		err := s.ValidateWithDigest(context.TODO(), image, signedDigest)
+
+		//THEN
+		require.NoError(t, err)
+		require.Zero(t, atomic.LoadInt32(&calls))
+	})
+
+	t.Run("pinned digest is admitted like by Validate", func(t *testing.T) {
+		//GIVEN
+		var calls int32
+		f := func(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+			atomic.AddInt32(&calls, 1)
+			return nil, client.ErrNoSuchTarget(name)
+		}
+		pinned := "sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+		s := NewDefaultMockNotaryService().WithFunc(f).Build()
+		s.PinnedDigests = PinnedDigests{"eu.gcr.io/kyma-project/image": {pinned}}
+		require.NoError(t, s.Validate(context.TODO(), image+"@"+pinned))
+
+		//WHEN
+		err := s.ValidateWithDigest(context.TODO(), image, pinned)
+
+		//THEN
+		require.NoError(t, err)
+		require.Zero(t, atomic.LoadInt32(&calls))
+	})
+
+	t.Run("exception for the digest", func(t *testing.T) {
+		//GIVEN
+		f := func(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+			return nil, client.ErrNoSuchTarget(name)
+		}
+		now := time.Now()
+		s := NewDefaultMockNotaryService().WithFunc(f).
+			WithExceptions(testingclock.NewFakeClock(now),
+				ImageException{Image: "eu.gcr.io/kyma-project/image@" + signedDigest, ExpiresAt: now.Add(time.Hour)}).
+			Build()
+
+		//WHEN
+		err := s.ValidateWithDigest(context.TODO(), image, signedDigest)
+
+		//THEN
+		require.NoError(t, err)
+	})
+
+	t.Run("unsigned tag", func(t *testing.T) {
+		//GIVEN
+		f := func(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+			return nil, client.ErrNoSuchTarget(name)
+		}
+		s := NewDefaultMockNotaryService().WithFunc(f).Build()
+
+		//WHEN
+		err := s.ValidateWithDigest(context.TODO(), image, signedDigest)
+
+		//THEN
+		require.True(t, errors.As(err, new(NoTrustedTargetError)))
+	})
+}
+
+// countingTransport counts requests sent to the registry.
+type countingTransport struct {
+	next     http.RoundTripper
+	requests int32
+}
+
+func (t *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.requests, 1)
+	return t.next.RoundTrip(r)
+}
+
+// unreachableTransport fails all registry calls.
+type unreachableTransport struct{}
+
+func (unreachableTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	return nil, errors.New("registry is unreachable")
+}
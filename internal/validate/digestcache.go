@@ -0,0 +1,137 @@
+package validate
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"k8s.io/utils/clock"
+)
+
+// maxDigestCacheEntries bounds the memory used when many different images are validated within the TTL.
+const maxDigestCacheEntries = 10000
+
+// digestCache stores registry digests of image references, so bursts of pods with the same image
+// skip the registry round-trip. It's independent of the negative cache, successful lookups are cached too.
+type digestCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	clock   clock.Clock
+	entries map[digestCacheKey]digestCacheEntry
+	// generation is increased by invalidations, so digests of lookups which were in flight aren't cached.
+	generation uint64
+}
+
+// digestCacheKey separates lookups which resolve the same reference to different images.
+type digestCacheKey struct {
+	// ref is the normalized reference, e.g. index.docker.io/library/nginx:latest
+	ref       string
+	platform  string
+	algorithm string
+}
+
+type digestCacheEntry struct {
+	digests imageDigests
+	// mediaType is the media type of the manifest the reference pointed to when the digests were resolved.
+	mediaType types.MediaType
+	expires   time.Time
+}
+
+// newDigestCache returns nil when ServiceConfig.DigestCacheTTL isn't set.
+func newDigestCache(sc *ServiceConfig, clk clock.Clock) *digestCache {
+	if sc.DigestCacheTTL <= 0 {
+		return nil
+	}
+	if clk == nil {
+		clk = clock.RealClock{}
+	}
+	return &digestCache{
+		ttl:     sc.DigestCacheTTL,
+		clock:   clk,
+		entries: map[digestCacheKey]digestCacheEntry{},
+	}
+}
+
+func (c *digestCache) get(key digestCacheKey) (imageDigests, bool) {
+	if c == nil {
+		return imageDigests{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return imageDigests{}, false
+	}
+	if !c.clock.Now().Before(entry.expires) {
+		delete(c.entries, key)
+		return imageDigests{}, false
+	}
+	return entry.digests, true
+}
+
+// currentGeneration is taken before the registry lookup and passed to add.
+func (c *digestCache) currentGeneration() uint64 {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// removeRef drops the digests of the reference for all platforms and algorithms.
+func (c *digestCache) removeRef(ref string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for key := range c.entries {
+		if key.ref == ref {
+			delete(c.entries, key)
+		}
+	}
+}
+
+func (c *digestCache) flush() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.entries = map[digestCacheKey]digestCacheEntry{}
+}
+
+// add caches the digests unless the cache was invalidated since generation.
+func (c *digestCache) add(generation uint64, key digestCacheKey, digests imageDigests, mediaType types.MediaType) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation != generation {
+		return
+	}
+
+	now := c.clock.Now()
+	if len(c.entries) >= maxDigestCacheEntries {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxDigestCacheEntries {
+			return
+		}
+	}
+	c.entries[key] = digestCacheEntry{
+		digests:   digests,
+		mediaType: mediaType,
+		expires:   now.Add(c.ttl),
+	}
+}
@@ -0,0 +1,119 @@
+package validate
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/require"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func Test_Validate_DigestCache(t *testing.T) {
+	image := "eu.gcr.io/kyma-project/cached:1.0"
+	registryTransport, img := pushTestImageAs(t, image)
+	imageHash := configHash(t, img)
+
+	t.Run("registry is called once within the TTL", func(t *testing.T) {
+		//GIVEN
+		counting := &countingTransport{next: registryTransport}
+		s := NewDefaultMockNotaryService().
+			WithHash(imageHash).
+			WithRegistryTransport(counting).
+			WithDigestCache(time.Minute, testingclock.NewFakeClock(time.Now())).
+			Build()
+		require.NoError(t, s.Validate(context.TODO(), image))
+		requests := atomic.LoadInt32(&counting.requests)
+
+		//WHEN
+		for i := 0; i < 5; i++ {
+			require.NoError(t, s.Validate(context.TODO(), image))
+		}
+
+		//THEN
+		require.Equal(t, requests, atomic.LoadInt32(&counting.requests))
+	})
+
+	t.Run("registry is called again after the TTL", func(t *testing.T) {
+		//GIVEN
+		counting := &countingTransport{next: registryTransport}
+		clk := testingclock.NewFakeClock(time.Now())
+		s := NewDefaultMockNotaryService().
+			WithHash(imageHash).
+			WithRegistryTransport(counting).
+			WithDigestCache(time.Minute, clk).
+			Build()
+		require.NoError(t, s.Validate(context.TODO(), image))
+		requests := atomic.LoadInt32(&counting.requests)
+
+		//WHEN
+		clk.Step(time.Minute)
+		err := s.Validate(context.TODO(), image)
+
+		//THEN
+		require.NoError(t, err)
+		require.Equal(t, 2*requests, atomic.LoadInt32(&counting.requests))
+	})
+
+	t.Run("cached digests aren't shared between platforms", func(t *testing.T) {
+		//GIVEN
+		counting := &countingTransport{next: registryTransport}
+		s := NewDefaultMockNotaryService().
+			WithHash(imageHash).
+			WithRegistryTransport(counting).
+			WithDigestCache(time.Minute, testingclock.NewFakeClock(time.Now())).
+			Build()
+		require.NoError(t, s.Validate(context.TODO(), image))
+		requests := atomic.LoadInt32(&counting.requests)
+
+		//WHEN
+		err := s.Validate(ContextWithPlatform(context.TODO(), "linux/arm64"), image)
+
+		//THEN
+		require.NoError(t, err)
+		require.Equal(t, 2*requests, atomic.LoadInt32(&counting.requests))
+	})
+
+	t.Run("registry errors aren't cached", func(t *testing.T) {
+		//GIVEN
+		counting := &countingTransport{next: unreachableTransport{}}
+		s := NewDefaultMockNotaryService().
+			WithHash(imageHash).
+			WithRegistryTransport(counting).
+			WithDigestCache(time.Minute, testingclock.NewFakeClock(time.Now())).
+			Build()
+		require.Error(t, s.Validate(context.TODO(), image))
+		requests := atomic.LoadInt32(&counting.requests)
+
+		//WHEN
+		err := s.Validate(context.TODO(), image)
+
+		//THEN
+		require.Error(t, err)
+		require.Greater(t, atomic.LoadInt32(&counting.requests), requests)
+	})
+}
+
+func Test_digestCache(t *testing.T) {
+	clk := testingclock.NewFakeClock(time.Now())
+	c := newDigestCache(&ServiceConfig{DigestCacheTTL: 10 * time.Second}, clk)
+	key := digestCacheKey{ref: "index.docker.io/library/nginx:latest", platform: "linux/amd64", algorithm: SHA256Algorithm}
+	c.add(c.currentGeneration(), key, imageDigests{config: []byte("config"), index: []byte("index")}, types.OCIImageIndex)
+
+	digests, ok := c.get(key)
+	require.True(t, ok)
+	require.Equal(t, []byte("config"), digests.config)
+	require.Equal(t, types.OCIImageIndex, c.entries[key].mediaType)
+
+	_, ok = c.get(digestCacheKey{ref: key.ref, platform: "linux/arm64", algorithm: SHA256Algorithm})
+	require.False(t, ok)
+
+	clk.Step(10 * time.Second)
+	_, ok = c.get(key)
+	require.False(t, ok)
+	require.Empty(t, c.entries)
+
+	require.Nil(t, newDigestCache(&ServiceConfig{}, clk))
+}
@@ -0,0 +1,109 @@
+package validate
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+// targetLister is implemented by notary repositories, the offline trust bundle finds digest targets only by name.
+type targetLister interface {
+	ListTargets(roles ...data.RoleName) ([]*client.TargetWithRole, error)
+}
+
+// digestTargetMetadata is the custom metadata of targets which are signed for an image digest.
+type digestTargetMetadata struct {
+	Digest string `json:"digest"`
+}
+
+// verifyDigestTarget verifies the pinned image by the target signed for its digest,
+// the digest is the one pulled by the runtime, so the registry isn't asked.
+func (s *notaryService) verifyDigestTarget(ctx context.Context, ref imageRef) (ImageValidationResult, error) {
+	result := ImageValidationResult{
+		Outcome: OutcomeDenied,
+	}
+
+	start := time.Now()
+	var signed signedHash
+	err := runPhase(ctx, NotaryPhase, s.settingsFor(ref.repo).notaryTimeout, func(ctx context.Context) (err error) {
+		signed, err = s.getNotaryDigestTarget(ctx, ref)
+		return err
+	})
+	result.Durations.Notary = time.Since(start)
+	if err != nil {
+		return result, err
+	}
+
+	result.Outcome = OutcomeSignatureVerified
+	result.NotaryRole = signed.role
+	result.SigningKeyIDs = signed.keyIDs
+	result.NotaryEndpoint = signed.endpoint
+	result.Warnings = signed.warnings()
+	result.ResolvedDigest = ref.repo + digestDelim + ref.pinnedDigest()
+	return result, nil
+}
+
+// getNotaryDigestTarget returns the target named by the pinned digest,
+// or the target which has the digest in its custom metadata.
+func (s *notaryService) getNotaryDigestTarget(ctx context.Context, ref imageRef) (signedHash, error) {
+	return s.lookupWithFailover(ctx, ref.repo, func(c targetReader) (signedHash, error) {
+		return s.digestTarget(c, ref)
+	})
+}
+
+func (s *notaryService) digestTarget(c targetReader, ref imageRef) (signedHash, error) {
+	digest := ref.pinnedDigest()
+	signed, err := s.signedTarget(c, ref.repo, digest)
+	var noTrustedTarget NoTrustedTargetError
+	if !errors.As(err, &noTrustedTarget) {
+		if err != nil {
+			return signedHash{}, err
+		}
+		return signed, verifyDigestTargetHash(ref, digest, signed)
+	}
+
+	lister, ok := c.(targetLister)
+	if !ok {
+		return signedHash{}, err
+	}
+	targets, listErr := lister.ListTargets(s.targetRoles(ref.repo)...)
+	if listErr != nil {
+		return signedHash{}, notaryError(listErr)
+	}
+	for _, target := range targets {
+		if target.Custom == nil {
+			continue
+		}
+		var metadata digestTargetMetadata
+		if json.Unmarshal(*target.Custom, &metadata) != nil || metadata.Digest != digest {
+			continue
+		}
+		signed, targetErr := s.signedTarget(c, ref.repo, target.Name)
+		if targetErr == nil {
+			targetErr = verifyDigestTargetHash(ref, target.Name, signed)
+		}
+		if targetErr == nil {
+			return signed, nil
+		}
+		// another target may be signed for the digest, the error explains the denial when none is
+		err = targetErr
+	}
+	return signedHash{}, err
+}
+
+// verifyDigestTargetHash checks the target is signed with the pinned digest, the name and the custom metadata
+// of the target aren't signed for the content, so they only select the target.
+func verifyDigestTargetHash(ref imageRef, target string, signed signedHash) error {
+	if subtle.ConstantTimeCompare(ref.digest, signed.hashes[ref.digestAlgorithm]) == 0 {
+		return DigestMismatchError{
+			Tag:          target,
+			PinnedDigest: ref.pinnedDigest(),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package validate
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	canonicaljson "github.com/docker/go/canonical/json"
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+func TestValidate_DigestTargets(t *testing.T) {
+	hash := []byte("0123456789abcdef0123456789abcdef")
+	otherHash := []byte("fedcba9876543210fedcba9876543210")
+	digest := "sha256:" + hex.EncodeToString(hash)
+	otherDigest := "sha256:" + hex.EncodeToString(otherHash)
+	image := "eu.gcr.io/kyma-project/image:1.0@" + digest
+
+	// signedTarget returns the target signed for the content with the hash
+	signedTarget := func(name string, hash []byte) *client.TargetWithRole {
+		return &client.TargetWithRole{
+			Target: client.Target{Name: name, Hashes: data.Hashes{SHA256Algorithm: hash}, Length: 1},
+			Role:   data.CanonicalTargetsRole,
+		}
+	}
+	customTarget := func(name, digest string, hash []byte) *client.TargetWithRole {
+		target := signedTarget(name, hash)
+		custom := canonicaljson.RawMessage(`{"digest":"` + digest + `"}`)
+		target.Custom = &custom
+		return target
+	}
+
+	testCases := []struct {
+		name          string
+		enabled       bool
+		image         string
+		targets       []*client.TargetWithRole
+		expectedError string
+		expectedErrIs error
+	}{
+		{
+			name:    "target named by digest",
+			enabled: true,
+			targets: []*client.TargetWithRole{signedTarget(digest, hash)},
+		},
+		{
+			name:    "target with digest in custom metadata",
+			enabled: true,
+			targets: []*client.TargetWithRole{customTarget("build-42", otherDigest, otherHash), customTarget("build-43", digest, hash)},
+		},
+		{
+			name:    "reference pinned without tag",
+			enabled: true,
+			image:   "eu.gcr.io/kyma-project/image@" + digest,
+			targets: []*client.TargetWithRole{customTarget("build-43", digest, hash)},
+		},
+		{
+			name:          "target named by digest signed for other content",
+			enabled:       true,
+			targets:       []*client.TargetWithRole{signedTarget(digest, otherHash)},
+			expectedError: "pinned digest " + digest + " doesn't match the digest signed for tag " + digest,
+			expectedErrIs: ErrDigestMismatch,
+		},
+		{
+			name:          "target with digest in custom metadata signed for other content",
+			enabled:       true,
+			targets:       []*client.TargetWithRole{customTarget("build-43", digest, otherHash)},
+			expectedError: "pinned digest " + digest + " doesn't match the digest signed for tag build-43",
+			expectedErrIs: ErrDigestMismatch,
+		},
+		{
+			name:          "no target for digest",
+			enabled:       true,
+			targets:       []*client.TargetWithRole{signedTarget(otherDigest, otherHash), customTarget("build-42", otherDigest, otherHash)},
+			expectedError: "tag 1.0 is not signed by any of roles [targets/releases targets]: No valid trust data for 1.0",
+			expectedErrIs: ErrNoTrustData,
+		},
+		{
+			name:          "digest targets disabled",
+			targets:       []*client.TargetWithRole{signedTarget(digest, hash), customTarget("build-43", digest, hash)},
+			expectedError: "tag 1.0 is not signed by any of roles [targets/releases targets]: No valid trust data for 1.0",
+			expectedErrIs: ErrNoTrustData,
+		},
+		{
+			name:          "reference pinned without tag with digest targets disabled",
+			image:         "eu.gcr.io/kyma-project/image@" + digest,
+			targets:       []*client.TargetWithRole{signedTarget(digest, hash)},
+			expectedError: "image name is not formatted correctly",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			//GIVEN
+			getTarget := func(name string, _ ...data.RoleName) (*client.TargetWithRole, error) {
+				for _, target := range tt.targets {
+					if target.Name == name {
+						return target, nil
+					}
+				}
+				return nil, client.ErrNoSuchTarget(name)
+			}
+			counting := &countingTransport{next: unreachableTransport{}}
+			b := NewDefaultMockNotaryService().
+				WithRepoFactory(MockNotaryRepoFactory{
+					GetTargetByNameFunc: &getTarget,
+					ListTargetsFunc: func(...data.RoleName) ([]*client.TargetWithRole, error) {
+						return tt.targets, nil
+					},
+				}).
+				WithRegistryTransport(counting)
+			if tt.enabled {
+				b = b.WithDigestTargets()
+			}
+			s := b.Build()
+
+			//WHEN
+			validated := image
+			if tt.image != "" {
+				validated = tt.image
+			}
+			result, err := s.ValidateDetailed(context.TODO(), validated)
+
+			//THEN
+			if tt.enabled {
+				require.Zero(t, atomic.LoadInt32(&counting.requests))
+			}
+			if tt.expectedError != "" {
+				require.EqualError(t, err, tt.expectedError)
+				if tt.expectedErrIs != nil {
+					require.ErrorIs(t, err, tt.expectedErrIs)
+				}
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, OutcomeSignatureVerified, result.Outcome)
+			require.Equal(t, data.CanonicalTargetsRole, result.NotaryRole)
+			require.Equal(t, "eu.gcr.io/kyma-project/image@"+digest, result.ResolvedDigest)
+		})
+	}
+
+	t.Run("listing failure is returned", func(t *testing.T) {
+		//GIVEN
+		getTarget := func(name string, _ ...data.RoleName) (*client.TargetWithRole, error) {
+			return nil, client.ErrNoSuchTarget(name)
+		}
+		s := NewDefaultMockNotaryService().
+			WithRepoFactory(MockNotaryRepoFactory{
+				GetTargetByNameFunc: &getTarget,
+				ListTargetsFunc: func(...data.RoleName) ([]*client.TargetWithRole, error) {
+					return nil, errors.New("notary failed")
+				},
+			}).
+			WithDigestTargets().
+			Build()
+
+		//WHEN
+		err := s.Validate(context.TODO(), image)
+
+		//THEN
+		require.EqualError(t, err, "notary failed")
+	})
+
+	t.Run("images without pinned digest are verified by tag only", func(t *testing.T) {
+		//GIVEN
+		getTarget := func(name string, _ ...data.RoleName) (*client.TargetWithRole, error) {
+			return nil, client.ErrNoSuchTarget(name)
+		}
+		listed := false
+		s := NewDefaultMockNotaryService().
+			WithRepoFactory(MockNotaryRepoFactory{
+				GetTargetByNameFunc: &getTarget,
+				ListTargetsFunc: func(...data.RoleName) ([]*client.TargetWithRole, error) {
+					listed = true
+					return nil, nil
+				},
+			}).
+			WithDigestTargets().
+			Build()
+
+		//WHEN
+		err := s.Validate(context.TODO(), "eu.gcr.io/kyma-project/image:1.0")
+
+		//THEN
+		var noTrustedTarget NoTrustedTargetError
+		require.ErrorAs(t, err, &noTrustedTarget)
+		require.False(t, listed)
+	})
+}
@@ -0,0 +1,146 @@
+package validate
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// dockerConfigFile is the format of ~/.docker/config.json and of kubernetes.io/dockerconfigjson secrets.
+type dockerConfigFile struct {
+	Auths map[string]dockerConfigAuth `json:"auths"`
+}
+
+type dockerConfigAuth struct {
+	// Auth is the base64 encoded "username:password".
+	Auth          string `json:"auth"`
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	IdentityToken string `json:"identitytoken"`
+	RegistryToken string `json:"registrytoken"`
+}
+
+func (a dockerConfigAuth) authConfig() (authn.AuthConfig, error) {
+	cfg := authn.AuthConfig{
+		Username:      a.Username,
+		Password:      a.Password,
+		IdentityToken: a.IdentityToken,
+		RegistryToken: a.RegistryToken,
+	}
+	if a.Auth == "" {
+		return cfg, nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(a.Auth)
+	if err != nil {
+		return authn.AuthConfig{}, fmt.Errorf("auth decode: %w", err)
+	}
+	username, password, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return authn.AuthConfig{}, fmt.Errorf("auth must have the username:password format")
+	}
+	cfg.Username, cfg.Password = username, password
+	return cfg, nil
+}
+
+// dockerConfigKeychain serves registry credentials from a mounted Docker config.json.
+// The file is read again when it changes, kubelet rotates the projected secret by replacing the file.
+type dockerConfigKeychain struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	auths   map[string]authn.AuthConfig
+	err     error
+}
+
+// newDockerConfigKeychain reads the config up front, a broken file is reported by the validations
+// until it's replaced with a valid one.
+func newDockerConfigKeychain(path string) *dockerConfigKeychain {
+	if path == "" {
+		return nil
+	}
+	k := &dockerConfigKeychain{path: path}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.reloadIfChanged()
+	return k
+}
+
+// authFor returns the credentials of the registry, false means that the config has no entry for it.
+func (k *dockerConfigKeychain) authFor(registry string) (authn.AuthConfig, bool, error) {
+	if k == nil {
+		return authn.AuthConfig{}, false, nil
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.reloadIfChanged()
+	if k.err != nil {
+		return authn.AuthConfig{}, false, k.err
+	}
+	cfg, ok := k.auths[registry]
+	return cfg, ok, nil
+}
+
+func (k *dockerConfigKeychain) reloadIfChanged() {
+	info, err := os.Stat(k.path)
+	if err != nil {
+		k.err = fmt.Errorf("docker config: %w", err)
+		k.modTime, k.size = time.Time{}, 0
+		return
+	}
+	if k.auths != nil && info.ModTime().Equal(k.modTime) && info.Size() == k.size {
+		return
+	}
+
+	auths, err := readDockerConfig(k.path)
+	if err != nil {
+		k.err = err
+		k.auths = nil
+		return
+	}
+	k.auths, k.err = auths, nil
+	k.modTime, k.size = info.ModTime(), info.Size()
+}
+
+// readDockerConfig returns the credentials keyed by the normalized registry host,
+// so "docker.io" and "https://index.docker.io/v1/" entries both match images from Docker Hub.
+func readDockerConfig(path string) (map[string]authn.AuthConfig, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("docker config: %w", err)
+	}
+	file := dockerConfigFile{}
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("docker config %s: %w", path, err)
+	}
+
+	auths := make(map[string]authn.AuthConfig, len(file.Auths))
+	for host, auth := range file.Auths {
+		registry, err := name.NewRegistry(dockerConfigHost(host))
+		if err != nil {
+			return nil, fmt.Errorf("docker config %s: registry %s: %w", path, host, err)
+		}
+		cfg, err := auth.authConfig()
+		if err != nil {
+			return nil, fmt.Errorf("docker config %s: registry %s: %w", path, host, err)
+		}
+		auths[registry.RegistryStr()] = cfg
+	}
+	return auths, nil
+}
+
+// dockerConfigHost strips the scheme and the path which docker login writes for some registries.
+func dockerConfigHost(key string) string {
+	key = strings.TrimPrefix(strings.TrimPrefix(key, "https://"), "http://")
+	host, _, _ := strings.Cut(key, "/")
+	return host
+}
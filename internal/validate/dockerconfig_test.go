@@ -0,0 +1,191 @@
+package validate
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate_DockerConfigCredentials(t *testing.T) {
+	image := "eu.gcr.io/kyma-project/private:1.0"
+	transport, imageHash := pushTestImageToBasicAuthRegistry(t, image, "warden", "s3cr3t")
+
+	t.Run("auth entry", func(t *testing.T) {
+		//GIVEN
+		path := writeDockerConfig(t, filepath.Join(t.TempDir(), "config.json"),
+			`{"auths":{"eu.gcr.io":{"auth":"`+base64.StdEncoding.EncodeToString([]byte("warden:s3cr3t"))+`"}}}`)
+		s := NewDefaultMockNotaryService().WithHash(imageHash).WithRegistryTransport(transport).Build()
+		s.dockerConfig = newDockerConfigKeychain(path)
+
+		//WHEN
+		err := s.Validate(context.TODO(), image)
+
+		//THEN
+		require.NoError(t, err)
+	})
+
+	t.Run("username and password entry with the scheme", func(t *testing.T) {
+		//GIVEN
+		path := writeDockerConfig(t, filepath.Join(t.TempDir(), "config.json"),
+			`{"auths":{"https://eu.gcr.io/v2/":{"username":"warden","password":"s3cr3t"}}}`)
+		s := NewDefaultMockNotaryService().WithHash(imageHash).WithRegistryTransport(transport).Build()
+		s.dockerConfig = newDockerConfigKeychain(path)
+
+		//WHEN
+		err := s.Validate(context.TODO(), image)
+
+		//THEN
+		require.NoError(t, err)
+	})
+
+	t.Run("rotated file is read again", func(t *testing.T) {
+		//GIVEN
+		path := writeDockerConfig(t, filepath.Join(t.TempDir(), "config.json"),
+			`{"auths":{"eu.gcr.io":{"username":"warden","password":"expired"}}}`)
+		s := NewDefaultMockNotaryService().WithHash(imageHash).WithRegistryTransport(transport).Build()
+		s.DisableNegativeCache = true
+		s.dockerConfig = newDockerConfigKeychain(path)
+		require.Error(t, s.Validate(context.TODO(), image))
+
+		//WHEN
+		writeDockerConfig(t, path, `{"auths":{"eu.gcr.io":{"username":"warden","password":"s3cr3t"}}}`)
+		err := s.Validate(context.TODO(), image)
+
+		//THEN
+		require.NoError(t, err)
+	})
+
+	t.Run("broken file fails the validation until it's fixed", func(t *testing.T) {
+		//GIVEN
+		path := writeDockerConfig(t, filepath.Join(t.TempDir(), "config.json"), `{"auths":`)
+		s := NewDefaultMockNotaryService().WithHash(imageHash).WithRegistryTransport(transport).Build()
+		s.DisableNegativeCache = true
+		s.dockerConfig = newDockerConfigKeychain(path)
+
+		//WHEN
+		err := s.Validate(context.TODO(), image)
+		writeDockerConfig(t, path, `{"auths":{"eu.gcr.io":{"username":"warden","password":"s3cr3t"}}}`)
+		fixedErr := s.Validate(context.TODO(), image)
+
+		//THEN
+		var authErr RegistryAuthError
+		require.ErrorAs(t, err, &authErr)
+		require.Equal(t, "eu.gcr.io", authErr.Registry)
+		require.NoError(t, fixedErr)
+	})
+
+	t.Run("registry keychains take precedence", func(t *testing.T) {
+		//GIVEN
+		path := writeDockerConfig(t, filepath.Join(t.TempDir(), "config.json"),
+			`{"auths":{"eu.gcr.io":{"username":"warden","password":"s3cr3t"}}}`)
+		keychain := &fakeKeychain{}
+		s := NewDefaultMockNotaryService().
+			WithHash(imageHash).
+			WithRegistryTransport(transport).
+			WithRegistryKeychains(RegistryKeychain{Hosts: []string{"eu.gcr.io"}, Keychain: keychain}).
+			Build()
+		s.dockerConfig = newDockerConfigKeychain(path)
+
+		//WHEN
+		err := s.Validate(context.TODO(), image)
+
+		//THEN
+		require.Error(t, err)
+		require.Equal(t, []string{"eu.gcr.io"}, keychain.resolved)
+	})
+}
+
+func Test_dockerConfigKeychain_authFor(t *testing.T) {
+	path := writeDockerConfig(t, filepath.Join(t.TempDir(), "config.json"), `{"auths":{
+		"https://index.docker.io/v1/":{"auth":"`+base64.StdEncoding.EncodeToString([]byte("hub:password"))+`"},
+		"europe-docker.pkg.dev":{"identitytoken":"refresh-token"},
+		"registry.local:5000":{"username":"user","password":"pass:word"}
+	}}`)
+	k := newDockerConfigKeychain(path)
+
+	testCases := []struct {
+		registry string
+		expected authn.AuthConfig
+		found    bool
+	}{
+		{registry: name.DefaultRegistry, expected: authn.AuthConfig{Username: "hub", Password: "password"}, found: true},
+		{registry: "europe-docker.pkg.dev", expected: authn.AuthConfig{IdentityToken: "refresh-token"}, found: true},
+		{registry: "registry.local:5000", expected: authn.AuthConfig{Username: "user", Password: "pass:word"}, found: true},
+		{registry: "eu.gcr.io"},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.registry, func(t *testing.T) {
+			cfg, ok, err := k.authFor(tt.registry)
+
+			require.NoError(t, err)
+			require.Equal(t, tt.found, ok)
+			require.Equal(t, tt.expected, cfg)
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		_, _, err := newDockerConfigKeychain(filepath.Join(t.TempDir(), "missing.json")).authFor("eu.gcr.io")
+
+		require.Error(t, err)
+	})
+
+	t.Run("malformed auth", func(t *testing.T) {
+		path := writeDockerConfig(t, filepath.Join(t.TempDir(), "config.json"), `{"auths":{"eu.gcr.io":{"auth":"bm8tY29sb24="}}}`)
+
+		_, _, err := newDockerConfigKeychain(path).authFor("eu.gcr.io")
+
+		require.ErrorContains(t, err, "username:password")
+	})
+}
+
+// writeDockerConfig replaces the config file and moves its modification time forward,
+// so the rewrite is noticed even on file systems with a coarse timestamp resolution.
+func writeDockerConfig(t *testing.T, path, content string) string {
+	modTime := time.Now()
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime().Add(time.Second)
+	}
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+	return path
+}
+
+// pushTestImageToBasicAuthRegistry pushes a random image to an in-memory registry which requires the credentials
+// once the image is pushed and returns the transport which redirects registry calls to it.
+func pushTestImageToBasicAuthRegistry(t *testing.T, image, username, password string) (http.RoundTripper, []byte) {
+	reg := registry.New()
+	var pushed atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); pushed.Load() && (!ok || u != username || p != password) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	transport := redirectTransport{host: strings.TrimPrefix(srv.URL, "http://")}
+
+	img, err := random.Image(128, 1)
+	require.NoError(t, err)
+	ref, err := name.ParseReference(image)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img, remote.WithTransport(transport)))
+	pushed.Store(true)
+
+	return transport, configHash(t, img)
+}
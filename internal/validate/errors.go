@@ -0,0 +1,198 @@
+package validate
+
+import (
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/storage"
+	"github.com/theupdateframework/notary/tuf/signed"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// Validation errors can be matched with these sentinels using errors.Is,
+// the notary client and registry errors they were mapped from are kept in the chain for errors.As.
+var (
+	// ErrNoTrustData is matched when notary or the offline trust bundle has no signed target for the image.
+	ErrNoTrustData = errors.New("no trust data")
+	// ErrNotaryUnavailable is matched when notary couldn't be reached or it failed to answer.
+	ErrNotaryUnavailable = errors.New("notary unavailable")
+	// ErrImageNotFound is matched when the registry doesn't have the image.
+	ErrImageNotFound = errors.New("image not found")
+	// ErrRegistryUnavailable is matched when the registry couldn't be reached or it failed to answer.
+	ErrRegistryUnavailable = errors.New("registry unavailable")
+	// ErrDigestMismatch is matched when the image differs from the one signed in notary.
+	ErrDigestMismatch = errors.New("digest mismatch")
+	// ErrUnsupportedHashAlgorithm is matched when the signed target or the digest has no sha256 or sha512 hash,
+	// the error message names the algorithms which were found.
+	ErrUnsupportedHashAlgorithm = errors.New("unsupported hash algorithm")
+	// ErrTrustMetadataExpired is matched when TUF metadata of the notary repository has expired,
+	// TrustMetadataExpiredError names the role and the expiry time.
+	ErrTrustMetadataExpired = errors.New("trust metadata expired")
+	// ErrSignatureTooOld is matched when the metadata protecting the signature is older than NotaryConfig.MaxSignatureAge,
+	// SignatureTooOldError names the role and the signing time.
+	ErrSignatureTooOld = errors.New("signature too old")
+	// ErrResponseTooLarge is matched when notary or the registry answered with a body above the configured limit,
+	// ResponseTooLargeError names the URL and the limit.
+	ErrResponseTooLarge = errors.New("response too large")
+)
+
+// classifiedError keeps the message and the chain of err, and it matches the sentinel.
+type classifiedError struct {
+	sentinel error
+	err      error
+}
+
+func (e classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e classifiedError) Unwrap() error {
+	return e.err
+}
+
+func (e classifiedError) Is(target error) bool {
+	return target == e.sentinel
+}
+
+// Class returns the class of the sentinel.
+func (e classifiedError) Class() ErrorClassification {
+	switch e.sentinel {
+	case ErrNotaryUnavailable, ErrRegistryUnavailable:
+		return ClassInfrastructure
+	}
+	return ClassPolicy
+}
+
+func classify(sentinel, err error) error {
+	if err == nil || errors.Is(err, sentinel) {
+		return err
+	}
+	return classifiedError{sentinel: sentinel, err: err}
+}
+
+// notaryError maps errors of the notary client onto the sentinels.
+func notaryError(err error) error {
+	var (
+		noSuchTarget      client.ErrNoSuchTarget
+		repoNotExist      client.ErrRepositoryNotExist
+		repoNotInit       client.ErrRepoNotInitialized
+		metaNotFound      storage.ErrMetaNotFound
+		serverUnavailable storage.ErrServerUnavailable
+		networkErr        storage.NetworkError
+		offline           storage.ErrOffline
+		netErr            net.Error
+		expired           signed.ErrExpired
+	)
+	switch {
+	case errors.As(err, &expired):
+		return trustMetadataExpiredError(expired)
+	case errors.As(err, &noSuchTarget),
+		errors.As(err, &repoNotExist),
+		errors.As(err, &repoNotInit),
+		errors.As(err, &metaNotFound):
+		return classify(ErrNoTrustData, err)
+	case errors.As(err, &serverUnavailable),
+		errors.As(err, &networkErr),
+		errors.As(err, &offline),
+		errors.As(err, &netErr):
+		return classify(ErrNotaryUnavailable, err)
+	}
+	return err
+}
+
+// registryError maps go-containerregistry errors onto the sentinels,
+// rate limits keep ErrRegistryRateLimited and authentication failures keep RegistryAuthError.
+func registryError(err error) error {
+	var (
+		transportErr *transport.Error
+		netErr       net.Error
+	)
+	switch {
+	case errors.Is(err, ErrRegistryRateLimited):
+		return err
+	case errors.As(err, &transportErr) && transportErr.StatusCode == http.StatusNotFound:
+		return classify(ErrImageNotFound, err)
+	case errors.As(err, &transportErr) && transportErr.StatusCode >= http.StatusInternalServerError,
+		errors.As(err, &netErr):
+		return classify(ErrRegistryUnavailable, err)
+	}
+	return err
+}
+
+// ErrorClassification tells callers of the validator whether the image was denied or the validation couldn't decide,
+// e.g. to fail open on infrastructure problems and fail closed on trust problems.
+// It doesn't change the decisions of the validator, every error denies the image.
+type ErrorClassification string
+
+const (
+	// ClassPolicy is the authoritative denial, e.g. the image isn't signed or its digest differs from the signed one.
+	ClassPolicy ErrorClassification = "Policy"
+	// ClassInfrastructure is returned when the validation couldn't decide, e.g. notary or the registry
+	// couldn't be reached, they timed out or they rate limited the requests.
+	ClassInfrastructure ErrorClassification = "Infrastructure"
+)
+
+// ErrorClass returns the class of the error returned by the validator, it's empty for nil.
+// Typed errors carry their class with the Class() ErrorClassification method, which errors of
+// VerificationBackend implementations may implement too. Errors without the class are ClassInfrastructure,
+// because nothing tells that the image was denied authoritatively.
+// Denials of several backends of the chain are ClassPolicy only when all of them are.
+func ErrorClass(err error) ErrorClassification {
+	if err == nil {
+		return ""
+	}
+	var aggregate utilerrors.Aggregate
+	if errors.As(err, &aggregate) {
+		for _, e := range aggregate.Errors() {
+			if ErrorClass(e) != ClassPolicy {
+				return ClassInfrastructure
+			}
+		}
+		return ClassPolicy
+	}
+	var classified interface{ Class() ErrorClassification }
+	if errors.As(err, &classified) {
+		return classified.Class()
+	}
+	if isPolicyDenial(err) {
+		return ClassPolicy
+	}
+	return ClassInfrastructure
+}
+
+// isPolicyDenial matches the denials which are returned without the class, e.g. notary client errors
+// of mocked repositories which weren't mapped by notaryError.
+func isPolicyDenial(err error) bool {
+	var (
+		noSuchTarget client.ErrNoSuchTarget
+		repoNotExist client.ErrRepositoryNotExist
+		repoNotInit  client.ErrRepoNotInitialized
+	)
+	return errors.Is(err, errMalformedImageName) ||
+		errors.Is(err, errMalformedImageDigest) ||
+		errors.Is(err, ErrUnsupportedHashAlgorithm) ||
+		errors.Is(err, ErrUnsupportedManifestSchema) ||
+		errors.As(err, &noSuchTarget) ||
+		errors.As(err, &repoNotExist) ||
+		errors.As(err, &repoNotInit)
+}
+
+// policyError is the denial which has no type of its own.
+type policyError struct {
+	err error
+}
+
+func (e policyError) Error() string {
+	return e.err.Error()
+}
+
+func (e policyError) Unwrap() error {
+	return e.err
+}
+
+func (e policyError) Class() ErrorClassification {
+	return ClassPolicy
+}
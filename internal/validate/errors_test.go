@@ -0,0 +1,405 @@
+package validate
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/tuf/signed"
+)
+
+func Test_Validate_ErrorsMatchSentinels(t *testing.T) {
+	image := "eu.gcr.io/kyma-project/image:1.0"
+	registryTransport, img := pushTestImageAs(t, image)
+	imageHash := configHash(t, img)
+
+	notaryFailing := func(err error) func(string, ...data.RoleName) (*client.TargetWithRole, error) {
+		return func(string, ...data.RoleName) (*client.TargetWithRole, error) {
+			return nil, err
+		}
+	}
+	statusServer := func(status int) string {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+		t.Cleanup(srv.Close)
+		return srv.URL
+	}
+	emptyRegistry := httptest.NewServer(registry.New())
+	t.Cleanup(emptyRegistry.Close)
+
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	bundlePath := filepath.Join(t.TempDir(), "bundle.json")
+	writeTrustBundle(t, bundlePath, privateKey, map[string]map[string]trustBundleTarget{})
+	bundle, err := NewOfflineTrustBundle(bundlePath, publicKey)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		validate func() error
+		is       error
+		// as is the original error which must stay in the chain
+		as interface{}
+	}{
+		{
+			name: "tag not signed",
+			validate: func() error {
+				s := NewDefaultMockNotaryService().WithFunc(notaryFailing(client.ErrNoSuchTarget("1.0"))).Build()
+				return s.Validate(context.TODO(), image)
+			},
+			is: ErrNoTrustData,
+			as: new(client.ErrNoSuchTarget),
+		},
+		{
+			name: "repository doesn't exist in notary",
+			validate: func() error {
+				s := NewDefaultMockNotaryService().WithFunc(notaryFailing(client.ErrRepositoryNotExist{})).Build()
+				return s.Validate(context.TODO(), image)
+			},
+			is: ErrNoTrustData,
+			as: &client.ErrRepositoryNotExist{},
+		},
+		{
+			name: "snapshot metadata expired",
+			validate: func() error {
+				expired := signed.ErrExpired{Role: data.CanonicalSnapshotRole, Expired: "Mon Jan 2 15:04:05 UTC 2006"}
+				s := NewDefaultMockNotaryService().WithFunc(notaryFailing(expired)).Build()
+				return s.Validate(context.TODO(), image)
+			},
+			is: ErrTrustMetadataExpired,
+			as: &signed.ErrExpired{},
+		},
+		{
+			name: "repository isn't in the offline trust bundle",
+			validate: func() error {
+				s := NewDefaultMockNotaryService().Build()
+				s.OfflineTrustBundle = bundle
+				return s.Validate(context.TODO(), image)
+			},
+			is: ErrNoTrustData,
+		},
+		{
+			name: "notary host doesn't resolve",
+			validate: func() error {
+				s := NewDefaultMockNotaryService().WithRepoFactory(MockNotaryRepoFactoryNoSuchHost{}).Build()
+				return s.Validate(context.TODO(), image)
+			},
+			is: ErrNotaryUnavailable,
+			as: new(*net.OpError),
+		},
+		{
+			name: "notary answers the ping with an error",
+			validate: func() error {
+				s := NewDefaultMockNotaryService().
+					WithRepoFactory(NotaryRepoFactory{TrustDir: t.TempDir()}).
+					WithConfig(NotaryConfig{Url: statusServer(http.StatusBadGateway)}).
+					Build()
+				return s.Validate(context.TODO(), image)
+			},
+			is: ErrNotaryUnavailable,
+		},
+		{
+			name: "image isn't in the registry",
+			validate: func() error {
+				s := NewDefaultMockNotaryService().
+					WithHash(imageHash).
+					WithRegistryTransport(redirectTransport{host: strings.TrimPrefix(emptyRegistry.URL, "http://")}).
+					Build()
+				return s.Validate(context.TODO(), image)
+			},
+			is: ErrImageNotFound,
+			as: new(*transport.Error),
+		},
+		{
+			name: "registry fails",
+			validate: func() error {
+				s := NewDefaultMockNotaryService().
+					WithHash(imageHash).
+					WithRegistryTransport(redirectTransport{host: strings.TrimPrefix(statusServer(http.StatusInternalServerError), "http://")}).
+					Build()
+				return s.Validate(context.TODO(), image)
+			},
+			is: ErrRegistryUnavailable,
+			as: new(*transport.Error),
+		},
+		{
+			name: "registry is rate limited",
+			validate: func() error {
+				rl := &rateLimitingRegistry{status: http.StatusTooManyRequests, retryAfter: "0", limitedResponses: registryRateLimitRetries + 1}
+				transport, hash := pushTestImageToRateLimitingRegistry(t, image, rl)
+				s := NewDefaultMockNotaryService().WithHash(hash).WithRegistryTransport(transport).Build()
+				return s.Validate(context.TODO(), image)
+			},
+			is: ErrRegistryRateLimited,
+		},
+		{
+			name: "image differs from the signed one",
+			validate: func() error {
+				s := NewDefaultMockNotaryService().WithHash([]byte{1, 2, 3}).WithRegistryTransport(registryTransport).Build()
+				return s.Validate(context.TODO(), image)
+			},
+			is: ErrDigestMismatch,
+		},
+		{
+			name: "pinned digest differs from the signed one",
+			validate: func() error {
+				s := NewDefaultMockNotaryService().WithHash(imageHash).Build()
+				return s.Validate(context.TODO(), image+"@sha256:"+hex.EncodeToString(make([]byte, 32)))
+			},
+			is: ErrDigestMismatch,
+			as: &DigestMismatchError{},
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			//WHEN
+			err := tt.validate()
+
+			//THEN
+			require.ErrorIs(t, err, tt.is)
+			if tt.as != nil {
+				require.ErrorAs(t, err, tt.as)
+			}
+			for _, sentinel := range []error{ErrNoTrustData, ErrNotaryUnavailable, ErrImageNotFound, ErrRegistryUnavailable, ErrDigestMismatch} {
+				if sentinel != tt.is {
+					require.False(t, errors.Is(err, sentinel), "unexpected match of %s", sentinel)
+				}
+			}
+		})
+	}
+}
+
+func Test_classify_KeepsMessage(t *testing.T) {
+	err := classify(ErrNoTrustData, client.ErrNoSuchTarget("1.0"))
+
+	require.EqualError(t, err, "No valid trust data for 1.0")
+	require.ErrorIs(t, err, ErrNoTrustData)
+	require.Equal(t, err, classify(ErrNoTrustData, err))
+	require.NoError(t, classify(ErrNoTrustData, nil))
+}
+
+func Test_ErrorClass_ImageValidationFailures(t *testing.T) {
+	image := "eu.gcr.io/kyma-project/function-controller:pinned"
+	registryTransport, img := pushTestImageAs(t, image)
+	imageHash := configHash(t, img)
+	otherHash := make([]byte, len(imageHash))
+	copy(otherHash, imageHash)
+	otherHash[0]++
+	notaryFailing := func(err error) func(string, ...data.RoleName) (*client.TargetWithRole, error) {
+		return func(string, ...data.RoleName) (*client.TargetWithRole, error) {
+			return nil, err
+		}
+	}
+	service := func(f func(string, ...data.RoleName) (*client.TargetWithRole, error), hash []byte) notaryService {
+		builder := NewDefaultMockNotaryService().WithRegistryTransport(registryTransport)
+		if f != nil {
+			return builder.WithFunc(f).Build()
+		}
+		return builder.WithHash(hash).Build()
+	}
+
+	testCases := []struct {
+		name     string
+		validate func() error
+		expected ErrorClassification
+	}{
+		{
+			name: "image name without tag",
+			validate: func() error {
+				s := service(nil, imageHash)
+				return s.Validate(context.TODO(), "makapaka")
+			},
+			expected: ClassPolicy,
+		},
+		{
+			name: "empty image name",
+			validate: func() error {
+				s := service(nil, imageHash)
+				return s.Validate(context.TODO(), ":")
+			},
+			expected: ClassPolicy,
+		},
+		{
+			name: "registry port without tag",
+			validate: func() error {
+				s := service(nil, imageHash)
+				return s.Validate(context.TODO(), "repo:5000/image-name")
+			},
+			expected: ClassPolicy,
+		},
+		{
+			name: "different hash in notary",
+			validate: func() error {
+				s := service(nil, otherHash)
+				return s.Validate(context.TODO(), image)
+			},
+			expected: ClassPolicy,
+		},
+		{
+			name: "image not in notary",
+			validate: func() error {
+				s := service(notaryFailing(client.ErrRepositoryNotExist{}), nil)
+				return s.Validate(context.TODO(), image)
+			},
+			expected: ClassPolicy,
+		},
+		{
+			name: "tag not signed by any preferred role",
+			validate: func() error {
+				f := NewDefaultMockNotaryFunction().WithRoleHashes(map[data.RoleName][]byte{"targets/dev": {1}}).Build()
+				s := service(f, nil)
+				return s.Validate(context.TODO(), image)
+			},
+			expected: ClassPolicy,
+		},
+		{
+			name: "image in notary but not in registry",
+			validate: func() error {
+				s := service(nil, imageHash)
+				return s.Validate(context.TODO(), "eu.gcr.io/kyma-project/function-controller:unknown")
+			},
+			expected: ClassPolicy,
+		},
+		{
+			name: "image signed by not accepted role",
+			validate: func() error {
+				s := service(NewDefaultMockNotaryFunction().WithHash(imageHash).WithRole("targets/untrusted").Build(), nil)
+				return s.Validate(context.TODO(), image)
+			},
+			expected: ClassPolicy,
+		},
+		{
+			name: "delegated image returned by other role",
+			validate: func() error {
+				f := NewDefaultMockNotaryFunction().WithHash(imageHash).WithRole(data.CanonicalTargetsRole).Build()
+				s := NewDefaultMockNotaryService().
+					WithFunc(f).
+					WithRegistryTransport(registryTransport).
+					WithDelegationRoles(map[string]data.RoleName{"eu.gcr.io/kyma-project": "targets/team-a"}).
+					Build()
+				return s.Validate(context.TODO(), image)
+			},
+			expected: ClassPolicy,
+		},
+		{
+			name: "pinned digest disagrees with the signed tag",
+			validate: func() error {
+				s := service(nil, imageHash)
+				return s.Validate(context.TODO(), image+"@sha256:"+hex.EncodeToString(otherHash))
+			},
+			expected: ClassPolicy,
+		},
+		{
+			name: "pinned digest algorithm is not signed",
+			validate: func() error {
+				s := service(nil, imageHash)
+				return s.Validate(context.TODO(), image+"@sha512:"+hex.EncodeToString(imageHash))
+			},
+			expected: ClassPolicy,
+		},
+		{
+			name: "notary not responding",
+			validate: func() error {
+				s := NewDefaultMockNotaryService().WithRepoFactory(MockNotaryRepoFactoryNoSuchHost{}).Build()
+				return s.Validate(context.TODO(), image)
+			},
+			expected: ClassInfrastructure,
+		},
+		{
+			name: "registry not responding",
+			validate: func() error {
+				s := NewDefaultMockNotaryService().WithHash(imageHash).WithRegistryTransport(unreachableTransport{}).Build()
+				return s.Validate(context.TODO(), image)
+			},
+			expected: ClassInfrastructure,
+		},
+		{
+			name: "notary responds after the timeout",
+			validate: func() error {
+				f := func(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+					time.Sleep(100 * time.Millisecond)
+					return nil, client.ErrNoSuchTarget(name)
+				}
+				s := service(f, nil)
+				s.NotaryTimeout = 10 * time.Millisecond
+				return s.Validate(context.TODO(), image)
+			},
+			expected: ClassInfrastructure,
+		},
+		{
+			name: "registry rate limits the requests",
+			validate: func() error {
+				srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(http.StatusTooManyRequests)
+				}))
+				t.Cleanup(srv.Close)
+				s := NewDefaultMockNotaryService().
+					WithHash(imageHash).
+					WithRegistryTransport(redirectTransport{host: strings.TrimPrefix(srv.URL, "http://")}).
+					Build()
+				return s.Validate(context.TODO(), image)
+			},
+			expected: ClassInfrastructure,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			//WHEN
+			err := tt.validate()
+
+			//THEN
+			require.Error(t, err)
+			require.Equal(t, tt.expected, ErrorClass(err), err.Error())
+		})
+	}
+}
+
+func Test_ErrorClass(t *testing.T) {
+	policy := BackendError{Backend: "cosign", Err: client.ErrNoSuchTarget("1.0")}
+	infrastructure := BackendError{Backend: NotaryBackend, Err: classify(ErrNotaryUnavailable, &net.OpError{Op: "dial"})}
+	tests := []struct {
+		name     string
+		err      error
+		expected ErrorClassification
+	}{
+		{name: "no error", err: nil, expected: ""},
+		{name: "unknown error", err: errors.New("unknown"), expected: ClassInfrastructure},
+		{name: "wrapped typed error", err: fmt.Errorf("validation: %w", SignerKeyError{}), expected: ClassPolicy},
+		{name: "timeout wrapping the denial", err: PhaseTimeoutError{Phase: NotaryPhase, Err: client.ErrNoSuchTarget("1.0")}, expected: ClassInfrastructure},
+		{name: "denial of the backend", err: policy, expected: ClassPolicy},
+		{name: "backend error with its class", err: BackendError{Backend: "cosign", Err: backendClassError{}}, expected: ClassInfrastructure},
+		{name: "denials of all backends", err: chainError([]error{policy, policy}), expected: ClassPolicy},
+		{name: "backend which couldn't decide", err: chainError([]error{policy, infrastructure}), expected: ClassInfrastructure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, ErrorClass(tt.err))
+		})
+	}
+}
+
+type backendClassError struct{}
+
+func (backendClassError) Error() string {
+	return "cosign is unavailable"
+}
+
+func (backendClassError) Class() ErrorClassification {
+	return ClassInfrastructure
+}
@@ -0,0 +1,81 @@
+package validate
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+// ImageException admits an unsigned image until ExpiresAt, e.g. an incident hotfix which can't wait for signing.
+type ImageException struct {
+	// Image is the exact image reference, e.g. "eu.gcr.io/kyma-project/app:1.0".
+	// References with a digest, e.g. "eu.gcr.io/kyma-project/app@sha256:<hex>", match images pinned to that digest.
+	Image     string
+	ExpiresAt time.Time
+}
+
+// Validate rejects exceptions which would never expire or which can't match any image.
+func (e ImageException) Validate() error {
+	if e.Image == "" {
+		return fmt.Errorf("image exception must have the image")
+	}
+	if e.ExpiresAt.IsZero() {
+		return fmt.Errorf("image exception for %s must have the expiry time", e.Image)
+	}
+	if _, digest, ok := strings.Cut(e.Image, digestDelim); ok {
+		if _, _, err := parseDigest(digest); err != nil {
+			return fmt.Errorf("image exception for %s: %w", e.Image, err)
+		}
+	}
+	return nil
+}
+
+// matches returns true for the exact image reference and for images pinned to the digest of the exception.
+func (e ImageException) matches(image string, ref imageRef) bool {
+	if e.Image == image {
+		return true
+	}
+	repo, digest, ok := strings.Cut(e.Image, digestDelim)
+	if !ok || !ref.isPinned() {
+		return false
+	}
+	return (repo == ref.repo || repo == ref.tagged()) && digest == ref.pinnedDigest()
+}
+
+// activeException returns the first exception matching the image which hasn't expired yet,
+// expired exceptions which match the image are counted, so forgotten entries are visible.
+// The rule is recorded to rec, the expired exceptions consulted only for the record aren't counted.
+func (s *notaryService) activeException(image string, ref imageRef, rec *ruleRecorder) (ImageException, bool) {
+	if len(s.Exceptions) == 0 {
+		return ImageException{}, false
+	}
+	rule := RuleTrace{Rule: RuleExceptions}
+	now := s.now()
+	for _, e := range s.Exceptions {
+		if !e.matches(image, ref) {
+			continue
+		}
+		rule.Entry = e.Image
+		if !now.Before(e.ExpiresAt) {
+			if rec == nil {
+				s.metrics.observeExpiredException()
+			}
+			rule.Reason = fmt.Sprintf("exception expired at %s", e.ExpiresAt.UTC().Format(time.RFC3339))
+			continue
+		}
+		rule.Outcome, rule.Reason = OutcomeException, ""
+		rec.record(rule)
+		return e, true
+	}
+	rec.record(rule)
+	return ImageException{}, false
+}
+
+func (s *notaryService) now() time.Time {
+	if s.clock == nil {
+		return clock.RealClock{}.Now()
+	}
+	return s.clock.Now()
+}
@@ -0,0 +1,146 @@
+package validate
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestValidate_Exceptions(t *testing.T) {
+	now := time.Date(2022, 11, 30, 12, 0, 0, 0, time.UTC)
+	digest := "sha256:" + hex.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	otherDigest := "sha256:" + hex.EncodeToString([]byte("fedcba9876543210fedcba9876543210"))
+	notSigned := func(name string, _ ...data.RoleName) (*client.TargetWithRole, error) {
+		return nil, client.ErrNoSuchTarget(name)
+	}
+
+	testCases := []struct {
+		name            string
+		image           string
+		exception       ImageException
+		expectedOutcome Outcome
+		expectedExpired float64
+	}{
+		{
+			name:            "active exception",
+			image:           "eu.gcr.io/kyma-project/hotfix:1.0",
+			exception:       ImageException{Image: "eu.gcr.io/kyma-project/hotfix:1.0", ExpiresAt: now.Add(time.Hour)},
+			expectedOutcome: OutcomeException,
+		},
+		{
+			name:            "expired exception",
+			image:           "eu.gcr.io/kyma-project/hotfix:1.0",
+			exception:       ImageException{Image: "eu.gcr.io/kyma-project/hotfix:1.0", ExpiresAt: now},
+			expectedOutcome: OutcomeDenied,
+			expectedExpired: 1,
+		},
+		{
+			name:            "exception for other tag",
+			image:           "eu.gcr.io/kyma-project/hotfix:1.1",
+			exception:       ImageException{Image: "eu.gcr.io/kyma-project/hotfix:1.0", ExpiresAt: now.Add(time.Hour)},
+			expectedOutcome: OutcomeDenied,
+		},
+		{
+			name:            "digest exception matches pinned image",
+			image:           "eu.gcr.io/kyma-project/hotfix:1.0@" + digest,
+			exception:       ImageException{Image: "eu.gcr.io/kyma-project/hotfix@" + digest, ExpiresAt: now.Add(time.Hour)},
+			expectedOutcome: OutcomeException,
+		},
+		{
+			name:            "tagged digest exception matches pinned image",
+			image:           "eu.gcr.io/kyma-project/hotfix:1.0@" + digest,
+			exception:       ImageException{Image: "eu.gcr.io/kyma-project/hotfix:1.0@" + digest, ExpiresAt: now.Add(time.Hour)},
+			expectedOutcome: OutcomeException,
+		},
+		{
+			name:            "digest exception doesn't match other digest",
+			image:           "eu.gcr.io/kyma-project/hotfix:1.0@" + otherDigest,
+			exception:       ImageException{Image: "eu.gcr.io/kyma-project/hotfix@" + digest, ExpiresAt: now.Add(time.Hour)},
+			expectedOutcome: OutcomeDenied,
+		},
+		{
+			name:            "digest exception doesn't match image which isn't pinned",
+			image:           "eu.gcr.io/kyma-project/hotfix:1.0",
+			exception:       ImageException{Image: "eu.gcr.io/kyma-project/hotfix@" + digest, ExpiresAt: now.Add(time.Hour)},
+			expectedOutcome: OutcomeDenied,
+		},
+		{
+			name:            "expired digest exception",
+			image:           "eu.gcr.io/kyma-project/hotfix:1.0@" + digest,
+			exception:       ImageException{Image: "eu.gcr.io/kyma-project/hotfix@" + digest, ExpiresAt: now.Add(-time.Hour)},
+			expectedOutcome: OutcomeDenied,
+			expectedExpired: 1,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			//GIVEN
+			clk := testingclock.NewFakeClock(now)
+			s := NewDefaultMockNotaryService().WithFunc(notSigned).WithMetrics(prometheus.NewRegistry()).WithExceptions(clk, tt.exception).Build()
+
+			//WHEN
+			result, err := s.ValidateDetailed(context.TODO(), tt.image)
+
+			//THEN
+			require.Equal(t, tt.expectedOutcome, result.Outcome)
+			require.Equal(t, tt.expectedExpired, testutil.ToFloat64(s.metrics.expiredExceptionHits))
+			if tt.expectedOutcome == OutcomeDenied {
+				var noTrustedTarget NoTrustedTargetError
+				require.ErrorAs(t, err, &noTrustedTarget)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.exception.ExpiresAt, result.ExceptionExpiresAt)
+		})
+	}
+
+	t.Run("exception expires while the validator runs", func(t *testing.T) {
+		//GIVEN
+		clk := testingclock.NewFakeClock(now)
+		exception := ImageException{Image: "eu.gcr.io/kyma-project/hotfix:1.0", ExpiresAt: now.Add(time.Hour)}
+		s := NewDefaultMockNotaryService().WithFunc(notSigned).WithExceptions(clk, exception).Build()
+
+		//WHEN
+		activeErr := s.Validate(context.TODO(), exception.Image)
+		clk.Step(time.Hour)
+		expiredErr := s.Validate(context.TODO(), exception.Image)
+
+		//THEN
+		require.NoError(t, activeErr)
+		require.Error(t, expiredErr)
+	})
+
+	t.Run("allowed registries are consulted first", func(t *testing.T) {
+		//GIVEN
+		exception := ImageException{Image: "eu.gcr.io/kyma-project/hotfix:1.0", ExpiresAt: now.Add(time.Hour)}
+		b := NewDefaultMockNotaryService().WithFunc(notSigned).WithExceptions(testingclock.NewFakeClock(now), exception)
+		b.NotaryService.AllowedRegistries = []string{"eu.gcr.io/kyma-project"}
+		s := b.Build()
+
+		//WHEN
+		result, err := s.ValidateDetailed(context.TODO(), exception.Image)
+
+		//THEN
+		require.NoError(t, err)
+		require.Equal(t, OutcomeAllowedList, result.Outcome)
+	})
+}
+
+func TestImageException_Validate(t *testing.T) {
+	expiresAt := time.Date(2022, 11, 30, 12, 0, 0, 0, time.UTC)
+
+	require.NoError(t, ImageException{Image: "eu.gcr.io/kyma-project/hotfix:1.0", ExpiresAt: expiresAt}.Validate())
+	require.EqualError(t, ImageException{Image: "eu.gcr.io/kyma-project/hotfix:1.0"}.Validate(),
+		"image exception for eu.gcr.io/kyma-project/hotfix:1.0 must have the expiry time")
+	require.EqualError(t, ImageException{ExpiresAt: expiresAt}.Validate(), "image exception must have the image")
+	require.EqualError(t, ImageException{Image: "eu.gcr.io/kyma-project/hotfix@sha256", ExpiresAt: expiresAt}.Validate(),
+		"image exception for eu.gcr.io/kyma-project/hotfix@sha256: image digest is not formatted correctly")
+}
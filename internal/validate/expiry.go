@@ -0,0 +1,222 @@
+package validate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/theupdateframework/notary"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/storage"
+	"github.com/theupdateframework/notary/trustpinning"
+	"github.com/theupdateframework/notary/tuf"
+	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/tuf/signed"
+)
+
+// notaryExpiryLayout is the format of the expiry time in notary signed.ErrExpired errors.
+const notaryExpiryLayout = "Mon Jan 2 15:04:05 MST 2006"
+
+// TrustMetadataExpiredError is returned when the TUF metadata of a role in the notary repository has expired,
+// the repository owner must re-sign it, e.g. by publishing the snapshot or timestamp again.
+type TrustMetadataExpiredError struct {
+	Role      data.RoleName
+	ExpiresAt time.Time
+	// Err is the notary client error, it's nil when the metadata expired before the grace period.
+	Err error
+}
+
+func (e TrustMetadataExpiredError) Error() string {
+	return fmt.Sprintf("trust metadata of role %s expired at %s", e.Role, e.ExpiresAt.UTC().Format(time.RFC3339))
+}
+
+func (e TrustMetadataExpiredError) Class() ErrorClassification {
+	return ClassPolicy
+}
+
+func (e TrustMetadataExpiredError) Unwrap() error {
+	return e.Err
+}
+
+func (e TrustMetadataExpiredError) Is(target error) bool {
+	return target == ErrTrustMetadataExpired
+}
+
+func trustMetadataExpiredError(err signed.ErrExpired) TrustMetadataExpiredError {
+	// the expiry stays zero when notary changes the format, the role is still reported
+	expiresAt, _ := time.Parse(notaryExpiryLayout, err.Expired)
+	return TrustMetadataExpiredError{Role: err.Role, ExpiresAt: expiresAt, Err: err}
+}
+
+// expiredMetadataReporter is implemented by readers which accepted expired metadata within the grace period.
+type expiredMetadataReporter interface {
+	expiredMetadata() []TrustMetadataExpiredError
+}
+
+// expiryGraceRepository loads the TUF metadata once more without expiry checks when the notary client
+// refuses expired metadata, the metadata is used only when no role expired longer than grace ago.
+type expiryGraceRepository struct {
+	client.Repository
+	grace time.Duration
+	// load returns the repository built from metadata whose expiry isn't checked.
+	load func() (*tuf.Repo, error)
+
+	mu      sync.Mutex
+	expired []TrustMetadataExpiredError
+	// expiry is the expiry of the roles loaded without expiry checks, it's nil until they're loaded.
+	expiry map[data.RoleName]time.Time
+}
+
+func (r *expiryGraceRepository) GetTargetByName(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+	target, err := r.Repository.GetTargetByName(name, roles...)
+	if !isExpiredMetadata(err) {
+		return target, err
+	}
+	reader, err := r.lenientReader()
+	if err != nil {
+		return nil, err
+	}
+	return reader.GetTargetByName(name, roles...)
+}
+
+func (r *expiryGraceRepository) GetAllTargetMetadataByName(name string) ([]client.TargetSignedStruct, error) {
+	targets, err := r.Repository.GetAllTargetMetadataByName(name)
+	if !isExpiredMetadata(err) {
+		return targets, err
+	}
+	reader, err := r.lenientReader()
+	if err != nil {
+		return nil, err
+	}
+	return reader.GetAllTargetMetadataByName(name)
+}
+
+func (r *expiryGraceRepository) ListTargets(roles ...data.RoleName) ([]*client.TargetWithRole, error) {
+	targets, err := r.Repository.ListTargets(roles...)
+	if !isExpiredMetadata(err) {
+		return targets, err
+	}
+	reader, err := r.lenientReader()
+	if err != nil {
+		return nil, err
+	}
+	return reader.ListTargets(roles...)
+}
+
+func (r *expiryGraceRepository) expiredMetadata() []TrustMetadataExpiredError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.expired
+}
+
+func (r *expiryGraceRepository) lenientReader() (client.ReadOnly, error) {
+	repo, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var expired []TrustMetadataExpiredError
+	expiry := metadataExpiry(repo)
+	for role, expiresAt := range expiry {
+		if !expiresAt.Before(now) {
+			continue
+		}
+		e := TrustMetadataExpiredError{Role: role, ExpiresAt: expiresAt}
+		if now.Sub(expiresAt) > r.grace {
+			return nil, e
+		}
+		expired = append(expired, e)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expired = expired
+	r.expiry = expiry
+	return client.NewReadOnly(repo), nil
+}
+
+func isExpiredMetadata(err error) bool {
+	var expired signed.ErrExpired
+	return errors.As(err, &expired)
+}
+
+// metadataExpiry returns the expiry of all loaded roles.
+func metadataExpiry(repo *tuf.Repo) map[data.RoleName]time.Time {
+	expiry := map[data.RoleName]time.Time{}
+	if repo.Root != nil {
+		expiry[data.CanonicalRootRole] = repo.Root.Signed.Expires
+	}
+	if repo.Timestamp != nil {
+		expiry[data.CanonicalTimestampRole] = repo.Timestamp.Signed.Expires
+	}
+	if repo.Snapshot != nil {
+		expiry[data.CanonicalSnapshotRole] = repo.Snapshot.Signed.Expires
+	}
+	for role, targets := range repo.Targets {
+		expiry[role] = targets.Signed.Expires
+	}
+	return expiry
+}
+
+// loadTUFRepoAllowExpired builds the repository like the notary client does, but it doesn't check the expiry.
+// Signatures and checksums are verified, the cached root is trusted when it exists.
+func loadTUFRepoAllowExpired(gun data.GUN, cache storage.MetadataStore, remote storage.RemoteStore) (*tuf.Repo, error) {
+	builder := tuf.NewRepoBuilder(gun, nil, trustpinning.TrustPinConfig{})
+
+	root, err := cache.GetSized(data.CanonicalRootRole.String(), storage.NoSizeLimit)
+	if err != nil {
+		root, err = remote.GetSized(data.CanonicalRootRole.String(), storage.NoSizeLimit)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := builder.Load(data.CanonicalRootRole, root, 1, true); err != nil {
+		return nil, err
+	}
+	timestamp, err := remote.GetSized(data.CanonicalTimestampRole.String(), notary.MaxTimestampSize)
+	if err != nil {
+		return nil, err
+	}
+	if err := builder.Load(data.CanonicalTimestampRole, timestamp, 1, true); err != nil {
+		return nil, err
+	}
+	if _, err := loadConsistent(builder, remote, data.CanonicalSnapshotRole); err != nil {
+		return nil, err
+	}
+
+	// parents are loaded before their delegations, their keys verify the delegations
+	toLoad := []data.DelegationRole{{
+		BaseRole: data.BaseRole{Name: data.CanonicalTargetsRole},
+		Paths:    []string{""},
+	}}
+	for len(toLoad) > 0 {
+		role := toLoad[0]
+		toLoad = toLoad[1:]
+		if !builder.GetConsistentInfo(role.Name).ChecksumKnown() {
+			continue
+		}
+		raw, err := loadConsistent(builder, remote, role.Name)
+		if err != nil {
+			return nil, err
+		}
+		targets := &data.SignedTargets{}
+		if err := json.Unmarshal(raw, targets); err != nil {
+			return nil, err
+		}
+		toLoad = append(targets.GetValidDelegations(role), toLoad...)
+	}
+
+	repo, _, err := builder.Finish()
+	return repo, err
+}
+
+func loadConsistent(builder tuf.RepoBuilder, remote storage.RemoteStore, role data.RoleName) ([]byte, error) {
+	info := builder.GetConsistentInfo(role)
+	raw, err := remote.GetSized(info.ConsistentName(), info.Length())
+	if err != nil {
+		return nil, err
+	}
+	return raw, builder.Load(role, raw, 1, true)
+}
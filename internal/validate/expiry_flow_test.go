@@ -0,0 +1,121 @@
+package validate_test
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/kyma-project/warden/internal/validate"
+	"github.com/kyma-project/warden/internal/validate/validatetest"
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+// pushRandomImage pushes the image to a test registry and returns the transport wrapper which redirects
+// registry requests to it, and the sha256 hash of the image config.
+func pushRandomImage(t *testing.T, image string) (validate.TransportWrapper, []byte) {
+	srv := httptest.NewServer(registry.New())
+	t.Cleanup(srv.Close)
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	img, err := random.Image(128, 1)
+	require.NoError(t, err)
+	ref, err := name.ParseReference(image)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img, remote.WithTransport(registryRedirect{next: http.DefaultTransport, host: host})))
+	cfgDigest, err := img.ConfigName()
+	require.NoError(t, err)
+	hash, err := hex.DecodeString(cfgDigest.Hex)
+	require.NoError(t, err)
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return registryRedirect{next: next, host: host}
+	}, hash
+}
+
+func TestExpiredTrustMetadata(t *testing.T) {
+	repo := "eu.gcr.io/kyma-project/app"
+	image := repo + ":1.0"
+	redirect, hash := pushRandomImage(t, image)
+
+	tests := []struct {
+		name             string
+		role             data.RoleName
+		expiredFor       time.Duration
+		grace            time.Duration
+		expectedWarnings int
+	}{
+		{
+			name:       "expired snapshot is denied",
+			role:       data.CanonicalSnapshotRole,
+			expiredFor: time.Hour,
+		},
+		{
+			name:       "expired timestamp is denied",
+			role:       data.CanonicalTimestampRole,
+			expiredFor: time.Hour,
+		},
+		{
+			name:             "snapshot expired within grace is accepted",
+			role:             data.CanonicalSnapshotRole,
+			expiredFor:       10 * time.Minute,
+			grace:            time.Hour,
+			expectedWarnings: 1,
+		},
+		{
+			name:             "timestamp expired within grace is accepted",
+			role:             data.CanonicalTimestampRole,
+			expiredFor:       10 * time.Minute,
+			grace:            time.Hour,
+			expectedWarnings: 1,
+		},
+		{
+			name:       "snapshot expired before grace is denied",
+			role:       data.CanonicalSnapshotRole,
+			expiredFor: 2 * time.Hour,
+			grace:      time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//GIVEN
+			expiresAt := time.Now().Add(-tt.expiredFor).UTC().Truncate(time.Second)
+			notary := validatetest.NewNotaryServer(t).
+				WithTarget(repo, "1.0", hash).
+				WithExpiredMetadata(repo, tt.role, expiresAt)
+			validator, err := validate.NewImageValidator(&validate.ServiceConfig{
+				NotaryConfig: validate.NotaryConfig{Url: notary.URL, ExpiredMetadataGrace: tt.grace},
+			},
+				validate.WithRepoFactory(validate.NotaryRepoFactory{Timeout: time.Second, TrustDir: t.TempDir()}),
+				validate.WithRegistryTransport(redirect))
+			require.NoError(t, err)
+
+			//WHEN
+			result, err := validator.ValidateDetailed(context.TODO(), image)
+
+			//THEN
+			if tt.expectedWarnings > 0 {
+				require.NoError(t, err)
+				require.Equal(t, validate.OutcomeSignatureVerified, result.Outcome)
+				require.Len(t, result.Warnings, tt.expectedWarnings)
+				require.Contains(t, result.Warnings[0], string(tt.role))
+				return
+			}
+			require.ErrorIs(t, err, validate.ErrTrustMetadataExpired)
+			var expired validate.TrustMetadataExpiredError
+			require.True(t, errors.As(err, &expired))
+			require.Equal(t, tt.role, expired.Role)
+			require.True(t, expiresAt.Equal(expired.ExpiresAt), "expected %s, got %s", expiresAt, expired.ExpiresAt)
+			require.Empty(t, result.Warnings)
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package validate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/tuf/signed"
+)
+
+func Test_trustMetadataExpiredError(t *testing.T) {
+	//GIVEN
+	expired := signed.ErrExpired{Role: data.CanonicalTimestampRole, Expired: "Tue Mar 3 10:20:30 UTC 2026"}
+
+	//WHEN
+	err := trustMetadataExpiredError(expired)
+
+	//THEN
+	require.Equal(t, data.CanonicalTimestampRole, err.Role)
+	require.Equal(t, time.Date(2026, time.March, 3, 10, 20, 30, 0, time.UTC), err.ExpiresAt.UTC())
+	require.EqualError(t, err, "trust metadata of role timestamp expired at 2026-03-03T10:20:30Z")
+	require.ErrorIs(t, err, ErrTrustMetadataExpired)
+	require.Equal(t, "expired_metadata", errorClass(err))
+}
@@ -0,0 +1,128 @@
+package validate
+
+import "context"
+
+// Explainer tells which rule decides about the image, e.g. for a debug endpoint.
+// Validators of NewImageValidator implement it.
+type Explainer interface {
+	// Explain returns the trace of the rules the validation consults for the image in order.
+	// No network calls are made unless ExplainLive is given.
+	Explain(ctx context.Context, image string, opts ...ExplainOption) DecisionTrace
+}
+
+var _ Explainer = &notaryService{}
+
+// Rule names the rule lists consulted by the validation.
+type Rule string
+
+const (
+	RulePinnedDigests         Rule = "PinnedDigests"
+	RuleRequireFQDNRegistry   Rule = "RequireFQDNRegistry"
+	RuleAllowedRegistries     Rule = "AllowedRegistries"
+	RuleAllowedRegistriesFile Rule = "AllowedRegistriesFile"
+	RuleExceptions            Rule = "Exceptions"
+	RuleSignature             Rule = "SignatureVerification"
+)
+
+// RuleTrace describes how a rule treated the image.
+type RuleTrace struct {
+	Rule Rule
+	// Entry is the entry of the rule list which matched the image, it's empty when none matched.
+	Entry string
+	// Outcome is set when the rule decided about the image, the rules after it aren't consulted.
+	Outcome Outcome
+	// Reason explains the denial or why a matching entry didn't apply, e.g. an expired exception.
+	Reason string
+}
+
+// DecisionTrace is the answer of Explainer.Explain.
+type DecisionTrace struct {
+	Image string
+	// Reference is the canonical form of the image reference, it's empty when the reference is malformed.
+	Reference string
+	// Rules are the configured rules which were consulted, in the order of the validation.
+	Rules []RuleTrace
+	// Outcome is the decision of the rules, it's empty when the signature verification decides.
+	Outcome Outcome
+	// Error is the denial of a malformed reference or of the rule which denied the image.
+	Error string
+	// ConsultsNotary is set when the signature verification asks notary, i.e. without the offline trust bundle
+	// and with the notary backend in the verification chain.
+	ConsultsNotary bool
+	// Backends are the verification chain backends which verify the signature, in order.
+	Backends []string
+	// Live is the result of the validation with ExplainLive, nil otherwise.
+	Live *ImageValidationResult
+	// LiveError is the error of the validation with ExplainLive.
+	LiveError string
+}
+
+// ExplainOption customizes Explainer.Explain.
+type ExplainOption func(*explainOptions)
+
+type explainOptions struct {
+	live bool
+}
+
+// ExplainLive adds the result of the validation with notary and registry calls,
+// the digest cache and the verifications shared with concurrent validations aren't used.
+func ExplainLive() ExplainOption {
+	return func(o *explainOptions) {
+		o.live = true
+	}
+}
+
+func (s *notaryService) Explain(ctx context.Context, image string, opts ...ExplainOption) DecisionTrace {
+	o := explainOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	trace := s.explainRules(image)
+	if o.live {
+		// the copy without caches asks notary and the registry even when the image was validated recently
+		uncached := *s
+		uncached.digestCache = nil
+		uncached.flights = nil
+		result, err := uncached.validate(ctx, image)
+		trace.Live = &result
+		if err != nil {
+			trace.LiveError = err.Error()
+		}
+	}
+	return trace
+}
+
+// ruleRecorder collects the rules consulted by the validation for Explain, it's nil during the validation.
+type ruleRecorder struct {
+	rules []RuleTrace
+}
+
+func (r *ruleRecorder) record(rule RuleTrace) {
+	if r == nil {
+		return
+	}
+	r.rules = append(r.rules, rule)
+}
+
+// explainRules records the rules consulted by the validation without the signature verification.
+func (s *notaryService) explainRules(image string) DecisionTrace {
+	trace := DecisionTrace{Image: image}
+	rec := &ruleRecorder{}
+	sanitized, _, result, decided, err := s.decide(image, rec)
+	trace.Rules = rec.rules
+	if parsed, parseErr := ParseImageRef(sanitized); sanitized != "" && parseErr == nil {
+		trace.Reference = parsed.String()
+	}
+	if decided {
+		trace.Outcome = result.Outcome
+		if err != nil {
+			trace.Error = err.Error()
+		}
+		return trace
+	}
+
+	trace.Rules = append(trace.Rules, RuleTrace{Rule: RuleSignature})
+	trace.ConsultsNotary = s.OfflineTrustBundle == nil && s.VerificationChain.usesNotary()
+	trace.Backends = s.VerificationChain.Backends
+	return trace
+}
@@ -0,0 +1,289 @@
+package validate
+
+import (
+	"context"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestExplain(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	digest := "sha256:" + "a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f90"
+	// the validators mustn't ask notary while explaining
+	noNotary := func(name string, _ ...data.RoleName) (*client.TargetWithRole, error) {
+		t.Errorf("notary asked for %s", name)
+		return nil, client.ErrNoSuchTarget(name)
+	}
+
+	tests := []struct {
+		name            string
+		configure       func(*MockNotaryServiceBuilder)
+		image           string
+		expectedRef     string
+		expectedRules   []RuleTrace
+		expectedOutcome Outcome
+		expectedError   string
+		expectedNotary  bool
+	}{
+		{
+			name: "allowed list hit",
+			configure: func(b *MockNotaryServiceBuilder) {
+				b.NotaryService.AllowedRegistries = []string{"eu.gcr.io/other", "eu.gcr.io/kyma-project"}
+			},
+			image:       "eu.gcr.io/kyma-project/app:1.0",
+			expectedRef: "eu.gcr.io/kyma-project/app:1.0",
+			expectedRules: []RuleTrace{
+				{Rule: RuleAllowedRegistries, Entry: "eu.gcr.io/kyma-project", Outcome: OutcomeAllowedList},
+			},
+			expectedOutcome: OutcomeAllowedList,
+		},
+		{
+			name: "pinned digest",
+			configure: func(b *MockNotaryServiceBuilder) {
+				b.NotaryService.AllowedRegistries = []string{"eu.gcr.io/kyma-project"}
+				b.NotaryService.PinnedDigests = PinnedDigests{"eu.gcr.io/kyma-project/app": {digest}}
+			},
+			image:       "eu.gcr.io/kyma-project/app@" + digest,
+			expectedRef: "eu.gcr.io/kyma-project/app@" + digest,
+			expectedRules: []RuleTrace{
+				{Rule: RulePinnedDigests, Entry: "eu.gcr.io/kyma-project/app@" + digest, Outcome: OutcomePinnedDigest},
+			},
+			expectedOutcome: OutcomePinnedDigest,
+		},
+		{
+			name: "implicit registry is denied",
+			configure: func(b *MockNotaryServiceBuilder) {
+				b.NotaryService.RequireFQDNRegistry = true
+				b.NotaryService.AllowedRegistries = []string{"eu.gcr.io/kyma-project"}
+			},
+			image:       "nginx:1.25",
+			expectedRef: "docker.io/library/nginx:1.25",
+			expectedRules: []RuleTrace{
+				{
+					Rule:    RuleRequireFQDNRegistry,
+					Outcome: OutcomeDenied,
+					Reason:  ImplicitRegistryError{Image: "nginx:1.25", QualifiedImage: "docker.io/library/nginx:1.25"}.Error(),
+				},
+			},
+			expectedOutcome: OutcomeDenied,
+			expectedError:   ImplicitRegistryError{Image: "nginx:1.25", QualifiedImage: "docker.io/library/nginx:1.25"}.Error(),
+		},
+		{
+			name: "implicit registry on the allowed list",
+			configure: func(b *MockNotaryServiceBuilder) {
+				b.NotaryService.RequireFQDNRegistry = true
+				b.NotaryService.AllowedRegistries = []string{"docker.io/library/nginx"}
+			},
+			image:       "nginx:1.25",
+			expectedRef: "docker.io/library/nginx:1.25",
+			expectedRules: []RuleTrace{
+				{Rule: RuleRequireFQDNRegistry, Entry: "docker.io/library/nginx", Outcome: OutcomeAllowedList},
+			},
+			expectedOutcome: OutcomeAllowedList,
+		},
+		{
+			name:            "malformed reference is denied",
+			configure:       func(b *MockNotaryServiceBuilder) {},
+			image:           "eu.gcr.io/kyma-project/app",
+			expectedRef:     "eu.gcr.io/kyma-project/app:latest",
+			expectedOutcome: OutcomeDenied,
+			expectedError:   "image name is not formatted correctly",
+		},
+		{
+			name: "active exception",
+			configure: func(b *MockNotaryServiceBuilder) {
+				b.WithExceptions(testingclock.NewFakeClock(now),
+					ImageException{Image: "eu.gcr.io/kyma-project/app:1.0", ExpiresAt: now.Add(time.Hour)})
+			},
+			image:       "eu.gcr.io/kyma-project/app:1.0",
+			expectedRef: "eu.gcr.io/kyma-project/app:1.0",
+			expectedRules: []RuleTrace{
+				{Rule: RuleExceptions, Entry: "eu.gcr.io/kyma-project/app:1.0", Outcome: OutcomeException},
+			},
+			expectedOutcome: OutcomeException,
+		},
+		{
+			name: "expired exception falls through to notary",
+			configure: func(b *MockNotaryServiceBuilder) {
+				b.NotaryService.AllowedRegistries = []string{"eu.gcr.io/other"}
+				b.WithExceptions(testingclock.NewFakeClock(now),
+					ImageException{Image: "eu.gcr.io/kyma-project/app:1.0", ExpiresAt: now.Add(-time.Hour)})
+			},
+			image:       "eu.gcr.io/kyma-project/app:1.0",
+			expectedRef: "eu.gcr.io/kyma-project/app:1.0",
+			expectedRules: []RuleTrace{
+				{Rule: RuleAllowedRegistries},
+				{Rule: RuleExceptions, Entry: "eu.gcr.io/kyma-project/app:1.0", Reason: "exception expired at 2024-05-01T11:00:00Z"},
+				{Rule: RuleSignature},
+			},
+			expectedNotary: true,
+		},
+		{
+			name:        "no rules fall through to notary",
+			configure:   func(b *MockNotaryServiceBuilder) {},
+			image:       "eu.gcr.io/kyma-project/app:1.0",
+			expectedRef: "eu.gcr.io/kyma-project/app:1.0",
+			expectedRules: []RuleTrace{
+				{Rule: RuleSignature},
+			},
+			expectedNotary: true,
+		},
+		{
+			name: "offline trust bundle replaces notary",
+			configure: func(b *MockNotaryServiceBuilder) {
+				b.NotaryService.OfflineTrustBundle = &OfflineTrustBundle{}
+			},
+			image:       "eu.gcr.io/kyma-project/app:1.0",
+			expectedRef: "eu.gcr.io/kyma-project/app:1.0",
+			expectedRules: []RuleTrace{
+				{Rule: RuleSignature},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//GIVEN
+			b := NewDefaultMockNotaryService().WithFunc(noNotary).WithRegistryTransport(unreachableTransport{})
+			tt.configure(b)
+			s := b.Build()
+
+			//WHEN
+			trace := s.Explain(context.TODO(), tt.image)
+
+			//THEN
+			require.Equal(t, tt.image, trace.Image)
+			require.Equal(t, tt.expectedRef, trace.Reference)
+			require.Equal(t, tt.expectedRules, trace.Rules)
+			require.Equal(t, tt.expectedOutcome, trace.Outcome)
+			require.Equal(t, tt.expectedError, trace.Error)
+			require.Equal(t, tt.expectedNotary, trace.ConsultsNotary)
+			require.Nil(t, trace.Live)
+		})
+	}
+
+	for _, tt := range tests {
+		if tt.expectedOutcome == "" {
+			// the signature verification decides, it's covered by TestExplain_Live
+			continue
+		}
+		t.Run(tt.name+" is the decision of the validation", func(t *testing.T) {
+			//GIVEN
+			b := NewDefaultMockNotaryService().WithFunc(noNotary).WithRegistryTransport(unreachableTransport{})
+			tt.configure(b)
+			s := b.Build()
+
+			//WHEN
+			trace := s.Explain(context.TODO(), tt.image)
+			result, err := s.ValidateDetailed(context.TODO(), tt.image)
+
+			//THEN
+			require.Equal(t, result.Outcome, trace.Outcome)
+			if err != nil {
+				require.Equal(t, err.Error(), trace.Error)
+			} else {
+				require.Empty(t, trace.Error)
+			}
+		})
+	}
+
+	t.Run("allowed registries file hit", func(t *testing.T) {
+		//GIVEN
+		path := filepath.Join(t.TempDir(), "allowed.yaml")
+		writeAllowedRegistries(t, path, "- eu.gcr.io/kyma-project\n")
+		s := NewDefaultMockNotaryService().WithFunc(noNotary).WithAllowedRegistriesFile(path).Build()
+		s.AllowedRegistries = []string{"eu.gcr.io/other"}
+
+		//WHEN
+		trace := s.Explain(context.TODO(), "eu.gcr.io/kyma-project/app:1.0")
+
+		//THEN
+		require.Equal(t, []RuleTrace{
+			{Rule: RuleAllowedRegistries},
+			{Rule: RuleAllowedRegistriesFile, Entry: "eu.gcr.io/kyma-project", Outcome: OutcomeAllowedList},
+		}, trace.Rules)
+		require.Equal(t, OutcomeAllowedList, trace.Outcome)
+		require.False(t, trace.ConsultsNotary)
+	})
+
+	t.Run("expired exceptions aren't counted", func(t *testing.T) {
+		//GIVEN
+		s := NewDefaultMockNotaryService().WithFunc(noNotary).WithMetrics(prometheus.NewRegistry()).
+			WithExceptions(testingclock.NewFakeClock(now),
+				ImageException{Image: "eu.gcr.io/kyma-project/app:1.0", ExpiresAt: now.Add(-time.Hour)}).
+			Build()
+
+		//WHEN
+		s.Explain(context.TODO(), "eu.gcr.io/kyma-project/app:1.0")
+
+		//THEN
+		require.Zero(t, testutil.ToFloat64(s.metrics.expiredExceptionHits))
+	})
+}
+
+func TestExplain_Live(t *testing.T) {
+	image := "eu.gcr.io/kyma-project/function-controller:signed"
+	registryTransport, img := pushTestImageAs(t, image)
+	hash := configHash(t, img)
+	notaryCalls := 0
+	f := func(name string, _ ...data.RoleName) (*client.TargetWithRole, error) {
+		notaryCalls++
+		if name != "signed" {
+			return nil, client.ErrNoSuchTarget(name)
+		}
+		return &client.TargetWithRole{Target: client.Target{Name: name, Hashes: data.Hashes{SHA256Algorithm: hash}}, Role: NotaryReleasesRole}, nil
+	}
+	s := NewDefaultMockNotaryService().WithFunc(f).WithRegistryTransport(registryTransport).Build()
+
+	t.Run("without the option notary isn't asked", func(t *testing.T) {
+		trace := s.Explain(context.TODO(), image)
+
+		require.True(t, trace.ConsultsNotary)
+		require.Nil(t, trace.Live)
+		require.Zero(t, notaryCalls)
+	})
+
+	t.Run("signed image", func(t *testing.T) {
+		trace := s.Explain(context.TODO(), image, ExplainLive())
+
+		require.True(t, trace.ConsultsNotary)
+		require.NotNil(t, trace.Live)
+		require.Equal(t, OutcomeSignatureVerified, trace.Live.Outcome)
+		require.Empty(t, trace.LiveError)
+		require.Equal(t, 1, notaryCalls)
+	})
+
+	t.Run("unsigned image", func(t *testing.T) {
+		trace := s.Explain(context.TODO(), "eu.gcr.io/kyma-project/function-controller:unsigned", ExplainLive())
+
+		require.NotNil(t, trace.Live)
+		require.Contains(t, trace.LiveError, "No valid trust data for unsigned")
+	})
+
+	t.Run("cached digests aren't used", func(t *testing.T) {
+		//GIVEN
+		counting := &countingTransport{next: registryTransport}
+		s := NewDefaultMockNotaryService().WithFunc(f).WithRegistryTransport(counting).
+			WithDigestCache(time.Minute, testingclock.NewFakeClock(time.Now())).
+			WithSingleflight().
+			Build()
+		_, err := s.ValidateDetailed(context.TODO(), image)
+		require.NoError(t, err)
+		requestsBefore := atomic.LoadInt32(&counting.requests)
+
+		//WHEN
+		trace := s.Explain(context.TODO(), image, ExplainLive())
+
+		//THEN
+		require.Equal(t, OutcomeSignatureVerified, trace.Live.Outcome)
+		require.Greater(t, atomic.LoadInt32(&counting.requests), requestsBefore)
+	})
+}
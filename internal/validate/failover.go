@@ -0,0 +1,93 @@
+package validate
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// notaryEndpoints remembers the endpoint which answered last,
+// so validations don't wait for the failed primary endpoint every time.
+type notaryEndpoints struct {
+	urls      []string
+	preferred int32
+}
+
+func newNotaryEndpoints(c NotaryConfig) *notaryEndpoints {
+	return &notaryEndpoints{urls: c.urls()}
+}
+
+// order returns the endpoints starting with the preferred one, the others keep the configured order.
+func (e *notaryEndpoints) order() []string {
+	preferred := int(atomic.LoadInt32(&e.preferred))
+	if preferred == 0 {
+		return e.urls
+	}
+	ordered := make([]string, 0, len(e.urls))
+	ordered = append(ordered, e.urls[preferred])
+	for i, u := range e.urls {
+		if i != preferred {
+			ordered = append(ordered, u)
+		}
+	}
+	return ordered
+}
+
+func (e *notaryEndpoints) prefer(url string) {
+	for i, u := range e.urls {
+		if u == url {
+			atomic.StoreInt32(&e.preferred, int32(i))
+			return
+		}
+	}
+}
+
+// lookupWithFailover runs the lookup against the notary endpoints in order until one of them answers.
+// Only unavailable endpoints are skipped, answers like a missing target or a wrong signature are final.
+func (s *notaryService) lookupWithFailover(ctx context.Context, imgRepo string, lookup func(targetReader) (signedHash, error)) (signedHash, error) {
+	if s.OfflineTrustBundle != nil {
+		return s.lookupAt(ctx, "", imgRepo, lookup)
+	}
+
+	endpoints := s.endpoints
+	if endpoints == nil {
+		endpoints = newNotaryEndpoints(s.NotaryConfig)
+	}
+	urls := endpoints.order()
+	for i, u := range urls {
+		signed, err := s.lookupAt(ctx, u, imgRepo, lookup)
+		if err == nil {
+			endpoints.prefer(u)
+			signed.endpoint = u
+			return signed, nil
+		}
+		if i == len(urls)-1 || ctx.Err() != nil || !errors.Is(err, ErrNotaryUnavailable) {
+			return signed, err
+		}
+	}
+	// not reachable, urls always contain Url
+	return signedHash{}, errors.New("no notary endpoint configured")
+}
+
+func (s *notaryService) lookupAt(ctx context.Context, notaryURL, imgRepo string, lookup func(targetReader) (signedHash, error)) (_ signedHash, err error) {
+	defer func(start time.Time) {
+		s.metrics.observeNotary(s.notaryHostLabel(notaryURL), start, err)
+	}(time.Now())
+
+	c, err := s.newTargetReader(ctx, imgRepo, notaryURL)
+	if err != nil {
+		return signedHash{}, notaryError(err)
+	}
+	signed, err := lookup(c)
+	if err != nil {
+		return signed, err
+	}
+	if err := s.checkSignatureAge(c); err != nil {
+		return signedHash{}, err
+	}
+	if reporter, ok := c.(expiredMetadataReporter); ok {
+		signed.expired = reporter.expiredMetadata()
+	}
+	return signed, nil
+}
@@ -0,0 +1,70 @@
+package validate_test
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kyma-project/warden/internal/validate"
+	"github.com/kyma-project/warden/internal/validate/validatetest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotaryFailover(t *testing.T) {
+	hash, err := hex.DecodeString("6d8f6f8a2e1c7b4d9f0e3a5c8b7d2e1f4a6c9b0d3e5f7a8c1b2d4e6f8a0c2e4b")
+	require.NoError(t, err)
+	repo := "eu.gcr.io/kyma-project/app"
+	digest := "sha256:" + hex.EncodeToString(hash)
+	secondary := validatetest.NewNotaryServer(t).WithTarget(repo, "1.0", hash)
+
+	t.Run("primary down", func(t *testing.T) {
+		//GIVEN
+		down := httptest.NewServer(http.NotFoundHandler())
+		down.Close()
+		validator, err := validate.NewImageValidator(&validate.ServiceConfig{
+			NotaryConfig: validate.NotaryConfig{Url: down.URL, FallbackUrls: []string{secondary.URL}},
+		}, validate.WithRepoFactory(validate.NotaryRepoFactory{Timeout: time.Second, TrustDir: t.TempDir()}))
+		require.NoError(t, err)
+
+		//WHEN
+		err = validator.ValidateWithDigest(context.TODO(), repo+":1.0", digest)
+
+		//THEN
+		require.NoError(t, err)
+	})
+
+	t.Run("primary slow", func(t *testing.T) {
+		//GIVEN
+		release := make(chan struct{})
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}))
+		defer slow.Close()
+		defer close(release)
+		validator, err := validate.NewImageValidator(&validate.ServiceConfig{
+			NotaryConfig: validate.NotaryConfig{Url: slow.URL, FallbackUrls: []string{secondary.URL}},
+		}, validate.WithRepoFactory(validate.NotaryRepoFactory{Timeout: 100 * time.Millisecond, TrustDir: t.TempDir()}))
+		require.NoError(t, err)
+
+		//WHEN
+		start := time.Now()
+		firstErr := validator.ValidateWithDigest(context.TODO(), repo+":1.0", digest)
+		firstDuration := time.Since(start)
+		start = time.Now()
+		secondErr := validator.ValidateWithDigest(context.TODO(), repo+":1.0", digest)
+		secondDuration := time.Since(start)
+
+		//THEN
+		require.NoError(t, firstErr)
+		require.NoError(t, secondErr)
+		require.GreaterOrEqual(t, firstDuration, 100*time.Millisecond)
+		// the secondary answered last, so the slow primary isn't asked again
+		require.Less(t, secondDuration, 100*time.Millisecond)
+	})
+}
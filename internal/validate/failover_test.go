@@ -0,0 +1,172 @@
+package validate
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/storage"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+// endpointRepoFactory answers lookups with the function of the notary URL and counts clients per URL.
+type endpointRepoFactory struct {
+	mu        sync.Mutex
+	clients   map[string]int
+	endpoints map[string]func(name string, roles ...data.RoleName) (*client.TargetWithRole, error)
+}
+
+func (f *endpointRepoFactory) NewRepoClient(ctx context.Context, img string, c NotaryConfig) (client.Repository, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.clients[c.Url]++
+	lookup, ok := f.endpoints[c.Url]
+	if !ok {
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: c.Url, IsNotFound: true}}
+	}
+	return MockNotaryClientRepository{GetTargetByNameFunc: lookup}, nil
+}
+
+func (f *endpointRepoFactory) clientsOf(url string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.clients[url]
+}
+
+func TestValidate_NotaryFailover(t *testing.T) {
+	image := "eu.gcr.io/kyma-project/image:1.0"
+	registryTransport, img := pushTestImageAs(t, image)
+	signed := NewDefaultMockNotaryFunction().WithHash(configHash(t, img)).Build()
+	notSigned := func(name string, _ ...data.RoleName) (*client.TargetWithRole, error) {
+		return nil, client.ErrNoSuchTarget(name)
+	}
+	serverUnavailable := func(string, ...data.RoleName) (*client.TargetWithRole, error) {
+		return nil, storage.ErrServerUnavailable{}
+	}
+	config := NotaryConfig{
+		Url:          "https://primary.notary",
+		FallbackUrls: []string{"https://secondary.notary", "https://tertiary.notary"},
+	}
+	newService := func(factory *endpointRepoFactory, reg prometheus.Registerer) notaryService {
+		s := NewDefaultMockNotaryService().
+			WithConfig(config).
+			WithRepoFactory(factory).
+			WithRegistryTransport(registryTransport).
+			WithMetrics(reg).
+			Build()
+		s.endpoints = newNotaryEndpoints(config)
+		return s
+	}
+
+	t.Run("unreachable primary fails over and the answering endpoint is preferred", func(t *testing.T) {
+		//GIVEN
+		factory := &endpointRepoFactory{
+			clients: map[string]int{},
+			endpoints: map[string]func(string, ...data.RoleName) (*client.TargetWithRole, error){
+				"https://tertiary.notary": signed,
+			},
+		}
+		reg := prometheus.NewRegistry()
+		s := newService(factory, reg)
+
+		//WHEN
+		first, firstErr := s.ValidateDetailed(context.TODO(), image)
+		second, secondErr := s.ValidateDetailed(context.TODO(), image)
+
+		//THEN
+		require.NoError(t, firstErr)
+		require.NoError(t, secondErr)
+		require.Equal(t, "https://tertiary.notary", first.NotaryEndpoint)
+		require.Equal(t, "https://tertiary.notary", second.NotaryEndpoint)
+		require.Equal(t, 1, factory.clientsOf("https://primary.notary"))
+		require.Equal(t, 1, factory.clientsOf("https://secondary.notary"))
+		require.Equal(t, 2, factory.clientsOf("https://tertiary.notary"))
+		require.Equal(t, float64(1), testutil.ToFloat64(s.metrics.notaryErrors.WithLabelValues("primary.notary", "connection")))
+		require.Equal(t, uint64(2), histogramCount(t, reg, "warden_notary_request_duration_seconds", "tertiary.notary"))
+	})
+
+	t.Run("failing primary fails over", func(t *testing.T) {
+		//GIVEN
+		factory := &endpointRepoFactory{
+			clients: map[string]int{},
+			endpoints: map[string]func(string, ...data.RoleName) (*client.TargetWithRole, error){
+				"https://primary.notary":   serverUnavailable,
+				"https://secondary.notary": signed,
+			},
+		}
+		s := newService(factory, prometheus.NewRegistry())
+
+		//WHEN
+		result, err := s.ValidateDetailed(context.TODO(), image)
+
+		//THEN
+		require.NoError(t, err)
+		require.Equal(t, "https://secondary.notary", result.NotaryEndpoint)
+	})
+
+	t.Run("preferred endpoint failing falls back to the others in order", func(t *testing.T) {
+		//GIVEN
+		factory := &endpointRepoFactory{
+			clients: map[string]int{},
+			endpoints: map[string]func(string, ...data.RoleName) (*client.TargetWithRole, error){
+				"https://primary.notary":   signed,
+				"https://secondary.notary": serverUnavailable,
+			},
+		}
+		s := newService(factory, prometheus.NewRegistry())
+		s.endpoints.prefer("https://secondary.notary")
+
+		//WHEN
+		result, err := s.ValidateDetailed(context.TODO(), image)
+
+		//THEN
+		require.NoError(t, err)
+		require.Equal(t, "https://primary.notary", result.NotaryEndpoint)
+		require.Equal(t, []string{"https://primary.notary", "https://secondary.notary", "https://tertiary.notary"}, s.endpoints.order())
+	})
+
+	t.Run("authoritative answer is final", func(t *testing.T) {
+		//GIVEN
+		factory := &endpointRepoFactory{
+			clients: map[string]int{},
+			endpoints: map[string]func(string, ...data.RoleName) (*client.TargetWithRole, error){
+				"https://primary.notary":   notSigned,
+				"https://secondary.notary": signed,
+			},
+		}
+		s := newService(factory, prometheus.NewRegistry())
+
+		//WHEN
+		err := s.Validate(context.TODO(), image)
+
+		//THEN
+		require.ErrorIs(t, err, ErrNoTrustData)
+		require.Zero(t, factory.clientsOf("https://secondary.notary"))
+	})
+
+	t.Run("error of the last endpoint is returned", func(t *testing.T) {
+		//GIVEN
+		factory := &endpointRepoFactory{clients: map[string]int{}}
+		s := newService(factory, prometheus.NewRegistry())
+
+		//WHEN
+		err := s.Validate(context.TODO(), image)
+
+		//THEN
+		require.ErrorIs(t, err, ErrNotaryUnavailable)
+		require.ErrorContains(t, err, "https://tertiary.notary")
+		require.Equal(t, 1, factory.clientsOf("https://primary.notary"))
+	})
+}
+
+func TestNotaryConfig_urls(t *testing.T) {
+	require.Equal(t, []string{"https://notary"}, NotaryConfig{Url: "https://notary"}.urls())
+	require.Equal(t, []string{"https://notary", "https://fallback"},
+		NotaryConfig{Url: "https://notary", FallbackUrls: []string{"", "https://fallback"}}.urls())
+}
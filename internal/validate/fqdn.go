@@ -0,0 +1,45 @@
+package validate
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	dockerHubRegistry  = "docker.io"
+	dockerHubNamespace = "library"
+)
+
+// ImplicitRegistryError is returned for images which don't name their registry when ServiceConfig.RequireFQDNRegistry is set.
+type ImplicitRegistryError struct {
+	Image string
+	// QualifiedImage is the Docker Hub name the image resolves to.
+	QualifiedImage string
+}
+
+func (e ImplicitRegistryError) Error() string {
+	return fmt.Sprintf("image %s doesn't name its registry, use the fully qualified image name %s", e.Image, e.QualifiedImage)
+}
+
+func (e ImplicitRegistryError) Class() ErrorClassification {
+	return ClassPolicy
+}
+
+// hasRegistryHost uses the rule of the docker CLI: the first path component is a registry host
+// when it contains a dot or a port, or when it's localhost.
+func hasRegistryHost(repo string) bool {
+	i := strings.Index(repo, "/")
+	if i < 0 {
+		return false
+	}
+	host := repo[:i]
+	return strings.ContainsAny(host, ".:") || host == "localhost"
+}
+
+// impliedDockerHubRepo returns the Docker Hub repository which a repository without the registry host resolves to.
+func impliedDockerHubRepo(repo string) string {
+	if !strings.Contains(repo, "/") {
+		repo = dockerHubNamespace + "/" + repo
+	}
+	return dockerHubRegistry + "/" + repo
+}
@@ -0,0 +1,118 @@
+package validate
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+func TestValidate_RequireFQDNRegistry(t *testing.T) {
+	tests := []struct {
+		name              string
+		image             string
+		allowedRegistries []string
+		disabled          bool
+		expectedErr       string
+		expectedOutcome   Outcome
+		expectNotaryCall  bool
+	}{
+		{
+			name:            "bare name is denied",
+			image:           "redis:7",
+			expectedErr:     "image redis:7 doesn't name its registry, use the fully qualified image name docker.io/library/redis:7",
+			expectedOutcome: OutcomeDenied,
+		},
+		{
+			name:            "library name is denied",
+			image:           "library/redis:7",
+			expectedErr:     "image library/redis:7 doesn't name its registry, use the fully qualified image name docker.io/library/redis:7",
+			expectedOutcome: OutcomeDenied,
+		},
+		{
+			name:            "organization name is denied",
+			image:           "bitnami/redis:7",
+			expectedErr:     "image bitnami/redis:7 doesn't name its registry, use the fully qualified image name docker.io/bitnami/redis:7",
+			expectedOutcome: OutcomeDenied,
+		},
+		{
+			name:              "bare name covered by the allow list",
+			image:             "redis:7",
+			allowedRegistries: []string{"docker.io/library/redis"},
+			expectedOutcome:   OutcomeAllowedList,
+		},
+		{
+			name:             "fully qualified docker.io name is verified",
+			image:            "docker.io/library/redis:7",
+			expectedErr:      "tag 7 is not signed by any of roles [targets/releases targets]: No valid trust data for 7",
+			expectedOutcome:  OutcomeDenied,
+			expectNotaryCall: true,
+		},
+		{
+			name:             "bare name is verified when the option is disabled",
+			image:            "redis:7",
+			disabled:         true,
+			expectedErr:      "tag 7 is not signed by any of roles [targets/releases targets]: No valid trust data for 7",
+			expectedOutcome:  OutcomeDenied,
+			expectNotaryCall: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//GIVEN
+			var notaryCalls int32
+			f := func(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+				atomic.AddInt32(&notaryCalls, 1)
+				return nil, client.ErrNoSuchTarget(name)
+			}
+			s := NewDefaultMockNotaryService().WithFunc(f).Build()
+			s.RequireFQDNRegistry = !tt.disabled
+			s.AllowedRegistries = tt.allowedRegistries
+
+			//WHEN
+			result, err := s.ValidateDetailed(context.TODO(), tt.image)
+
+			//THEN
+			require.Equal(t, tt.expectedOutcome, result.Outcome)
+			require.Equal(t, tt.expectNotaryCall, atomic.LoadInt32(&notaryCalls) > 0)
+			if tt.expectedErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.expectedErr)
+		})
+	}
+
+	t.Run("error is typed", func(t *testing.T) {
+		s := NewDefaultMockNotaryService().Build()
+		s.RequireFQDNRegistry = true
+
+		err := s.Validate(context.TODO(), "redis:7")
+
+		var implicitErr ImplicitRegistryError
+		require.True(t, errors.As(err, &implicitErr))
+		require.Equal(t, "docker.io/library/redis:7", implicitErr.QualifiedImage)
+	})
+}
+
+func Test_hasRegistryHost(t *testing.T) {
+	tests := map[string]bool{
+		"redis":                        false,
+		"library/redis":                false,
+		"docker.io/library/redis":      true,
+		"eu.gcr.io/kyma-project/image": true,
+		"localhost/image":              true,
+		"mirror.internal:5000/image":   true,
+		"registry:5000/kyma/image":     true,
+		"kyma-project/dev/bootstrap":   false,
+	}
+	for repo, expected := range tests {
+		t.Run(repo, func(t *testing.T) {
+			require.Equal(t, expected, hasRegistryHost(repo))
+		})
+	}
+}
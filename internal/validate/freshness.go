@@ -0,0 +1,122 @@
+package validate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/theupdateframework/notary"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+// SignatureTooOldError is returned when the metadata protecting the signature was signed longer than
+// NotaryConfig.MaxSignatureAge ago, e.g. for a repository which nobody publishes to anymore.
+type SignatureTooOldError struct {
+	Role     data.RoleName
+	SignedAt time.Time
+	MaxAge   time.Duration
+}
+
+func (e SignatureTooOldError) Error() string {
+	return fmt.Sprintf("%s metadata was signed at %s, signatures older than %s are not accepted",
+		e.Role, e.SignedAt.UTC().Format(time.RFC3339), e.MaxAge)
+}
+
+func (e SignatureTooOldError) Class() ErrorClassification {
+	return ClassPolicy
+}
+
+func (e SignatureTooOldError) Is(target error) bool {
+	return target == ErrSignatureTooOld
+}
+
+// metadataTimesReader is implemented by readers which tell when the metadata of the repository was signed.
+type metadataTimesReader interface {
+	metadataSignedAt() (map[data.RoleName]time.Time, error)
+}
+
+// freshnessRoles are the roles whose signing time is checked against NotaryConfig.MaxSignatureAge, in order.
+var freshnessRoles = []data.RoleName{data.CanonicalTimestampRole, data.CanonicalSnapshotRole}
+
+// metadataValidity is how long notary signs the metadata of the roles for.
+var metadataValidity = map[data.RoleName]time.Duration{
+	data.CanonicalTimestampRole: notary.NotaryTimestampExpiry,
+	data.CanonicalSnapshotRole:  notary.NotarySnapshotExpiry,
+}
+
+// signedAtFromExpiry returns when the metadata of freshnessRoles was signed. TUF metadata has no signing time,
+// so it's derived from the expiry and the validity which notary signs the role for.
+func signedAtFromExpiry(expiry map[data.RoleName]time.Time) map[data.RoleName]time.Time {
+	signedAt := map[data.RoleName]time.Time{}
+	for _, role := range freshnessRoles {
+		if expiresAt, ok := expiry[role]; ok {
+			signedAt[role] = expiresAt.Add(-metadataValidity[role])
+		}
+	}
+	return signedAt
+}
+
+// checkSignatureAge denies signatures whose metadata is older than NotaryConfig.MaxSignatureAge,
+// readers which can't tell the signing time, e.g. the offline trust bundle, aren't checked.
+func (s *notaryService) checkSignatureAge(c targetReader) error {
+	maxAge := s.NotaryConfig.MaxSignatureAge
+	if maxAge <= 0 {
+		return nil
+	}
+	reader, ok := c.(metadataTimesReader)
+	if !ok {
+		return nil
+	}
+	signedAt, err := reader.metadataSignedAt()
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	for _, role := range freshnessRoles {
+		at, ok := signedAt[role]
+		if ok && now.Sub(at) > maxAge {
+			return SignatureTooOldError{Role: role, SignedAt: at, MaxAge: maxAge}
+		}
+	}
+	return nil
+}
+
+// metadataSignedAt reads the metadata which the notary client verified and cached during the lookup.
+func (r cacheLockedRepository) metadataSignedAt() (map[data.RoleName]time.Time, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	expiry := map[data.RoleName]time.Time{}
+	for _, role := range freshnessRoles {
+		raw, err := os.ReadFile(filepath.Join(r.metadataDir, role.String()+".json"))
+		if err != nil {
+			return nil, err
+		}
+		var metadata struct {
+			Signed data.SignedCommon `json:"signed"`
+		}
+		if err := json.Unmarshal(raw, &metadata); err != nil {
+			return nil, fmt.Errorf("invalid cached %s metadata: %w", role, err)
+		}
+		expiry[role] = metadata.Signed.Expires
+	}
+	return signedAtFromExpiry(expiry), nil
+}
+
+// metadataSignedAt returns the times of the metadata loaded without expiry checks when it was used,
+// the lenient load doesn't update the cache.
+func (r *expiryGraceRepository) metadataSignedAt() (map[data.RoleName]time.Time, error) {
+	r.mu.Lock()
+	expiry := r.expiry
+	r.mu.Unlock()
+	if expiry != nil {
+		return signedAtFromExpiry(expiry), nil
+	}
+	reader, ok := r.Repository.(metadataTimesReader)
+	if !ok {
+		return nil, nil
+	}
+	return reader.metadataSignedAt()
+}
@@ -0,0 +1,108 @@
+package validate_test
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kyma-project/warden/internal/validate"
+	"github.com/kyma-project/warden/internal/validate/validatetest"
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary"
+	"github.com/theupdateframework/notary/tuf/data"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestMaxSignatureAge(t *testing.T) {
+	repo := "eu.gcr.io/kyma-project/app"
+	hash, err := hex.DecodeString("5e8b0a2c4d6f8e1a3b5c7d9e0f2a4b6c8d0e1f3a5b7c9d2e4f6a8b0c1d3e5f7a")
+	require.NoError(t, err)
+	digest := "sha256:" + hex.EncodeToString(hash)
+	day := 24 * time.Hour
+	now := time.Now().UTC().Truncate(time.Second)
+
+	tests := []struct {
+		name             string
+		maxAge           time.Duration
+		clockAfter       time.Duration
+		role             data.RoleName
+		expires          time.Time
+		grace            time.Duration
+		expectedRole     data.RoleName
+		expectedSignedAt time.Time
+	}{
+		{
+			name:       "check is disabled by default",
+			clockAfter: 365 * day,
+		},
+		{
+			name:       "fresh signature is accepted",
+			maxAge:     30 * day,
+			clockAfter: 10 * day,
+		},
+		{
+			name:             "timestamp signed before the window is denied",
+			maxAge:           30 * day,
+			clockAfter:       40 * day,
+			expectedRole:     data.CanonicalTimestampRole,
+			expectedSignedAt: now,
+		},
+		{
+			name:             "snapshot signed before the window is denied",
+			maxAge:           30 * day,
+			role:             data.CanonicalSnapshotRole,
+			expires:          now.Add(notary.NotarySnapshotExpiry - 60*day),
+			expectedRole:     data.CanonicalSnapshotRole,
+			expectedSignedAt: now.Add(-60 * day),
+		},
+		{
+			name:    "timestamp accepted within the expiry grace is fresh enough",
+			maxAge:  30 * day,
+			role:    data.CanonicalTimestampRole,
+			expires: now.Add(-10 * time.Minute),
+			grace:   time.Hour,
+		},
+		{
+			name:             "timestamp accepted within the expiry grace is denied when it's too old",
+			maxAge:           7 * day,
+			role:             data.CanonicalTimestampRole,
+			expires:          now.Add(-10 * time.Minute),
+			grace:            time.Hour,
+			expectedRole:     data.CanonicalTimestampRole,
+			expectedSignedAt: now.Add(-notary.NotaryTimestampExpiry - 10*time.Minute),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//GIVEN
+			server := validatetest.NewNotaryServer(t).WithTarget(repo, "1.0", hash)
+			if tt.role != "" {
+				server.WithExpiredMetadata(repo, tt.role, tt.expires)
+			}
+			validator, err := validate.NewImageValidator(&validate.ServiceConfig{
+				NotaryConfig: validate.NotaryConfig{Url: server.URL, MaxSignatureAge: tt.maxAge, ExpiredMetadataGrace: tt.grace},
+			},
+				validate.WithRepoFactory(validate.NotaryRepoFactory{Timeout: time.Second, TrustDir: t.TempDir()}),
+				validate.WithClock(testingclock.NewFakeClock(time.Now().Add(tt.clockAfter))))
+			require.NoError(t, err)
+
+			//WHEN
+			err = validator.ValidateWithDigest(context.TODO(), repo+":1.0", digest)
+
+			//THEN
+			if tt.expectedRole == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, validate.ErrSignatureTooOld)
+			var tooOld validate.SignatureTooOldError
+			require.True(t, errors.As(err, &tooOld))
+			require.Equal(t, tt.expectedRole, tooOld.Role)
+			require.Equal(t, tt.maxAge, tooOld.MaxAge)
+			require.WithinDuration(t, tt.expectedSignedAt, tooOld.SignedAt, 2*time.Minute)
+			require.ErrorContains(t, err, string(tt.expectedRole)+" metadata was signed at")
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package validate
+
+import (
+	"strings"
+)
+
+const (
+	gunTemplateHost = "{host}"
+	gunTemplatePath = "{path}"
+)
+
+// GUNMapping translates image repositories to the GUNs under which they are signed in notary.
+// Repositories which are not mapped are looked up under their own name.
+type GUNMapping struct {
+	// Repositories maps repositories to GUNs, it takes precedence over HostTemplates.
+	Repositories map[string]string
+	// HostTemplates map registry hosts to GUN templates, e.g. "signed/{path}".
+	// {host} is replaced with the registry host and {path} with the repository path without the host.
+	HostTemplates map[string]string
+}
+
+func (m GUNMapping) gun(imgRepo string) string {
+	if gun, ok := m.Repositories[imgRepo]; ok {
+		return gun
+	}
+	host, path, ok := strings.Cut(imgRepo, "/")
+	if !ok {
+		return imgRepo
+	}
+	template, ok := m.HostTemplates[host]
+	if !ok {
+		return imgRepo
+	}
+	return strings.NewReplacer(gunTemplateHost, host, gunTemplatePath, path).Replace(template)
+}
@@ -0,0 +1,94 @@
+package validate
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/client"
+)
+
+func TestGUNMapping_gun(t *testing.T) {
+	m := GUNMapping{
+		Repositories: map[string]string{
+			"harbor.internal/team/legacy": "legacy/app",
+		},
+		HostTemplates: map[string]string{
+			"harbor.internal":    "signed/{path}",
+			"eu.gcr.io":          "{host}/signed/{path}",
+			"[2001:db8::1]:5000": "{host}/signed/{path}",
+		},
+	}
+
+	tests := map[string]string{
+		"harbor.internal/team/app":     "signed/team/app",
+		"harbor.internal/team/legacy":  "legacy/app",
+		"eu.gcr.io/kyma-project/image": "eu.gcr.io/signed/kyma-project/image",
+		"ghcr.io/kyma-project/image":   "ghcr.io/kyma-project/image",
+		"redis":                        "redis",
+		"[2001:db8::1]:5000/app":       "[2001:db8::1]:5000/signed/app",
+		"[2001:db8::1]/app":            "[2001:db8::1]/app",
+	}
+	for repo, expected := range tests {
+		t.Run(repo, func(t *testing.T) {
+			require.Equal(t, expected, m.gun(repo))
+		})
+	}
+}
+
+func TestValidate_GUNMapping(t *testing.T) {
+	//GIVEN
+	image := "harbor.internal/team/app:1.0"
+	routes := newHostRoutingTransport(t, "harbor.internal")
+	imageHash := routes.push(t, "harbor.internal", "team/app:1.0")
+	registry := &pathRecordingTransport{next: routes}
+
+	f := NewDefaultMockNotaryFunction().WithHash(imageHash).Build()
+	factory := &gunRecordingRepoFactory{MockNotaryRepoFactory: MockNotaryRepoFactory{GetTargetByNameFunc: &f}}
+	s := NewDefaultMockNotaryService().WithRepoFactory(factory).WithRegistryTransport(registry).Build()
+	s.GUNMapping = GUNMapping{HostTemplates: map[string]string{"harbor.internal": "signed/{path}"}}
+
+	//WHEN
+	err := s.Validate(context.TODO(), image)
+
+	//THEN
+	require.NoError(t, err)
+	require.Equal(t, []string{"signed/team/app"}, factory.guns)
+	require.NotEmpty(t, registry.paths)
+	for _, path := range registry.paths {
+		if path != "/v2/" {
+			require.True(t, strings.HasPrefix(path, "/v2/team/app/"), path)
+		}
+	}
+}
+
+// gunRecordingRepoFactory records the GUNs used to create repository clients.
+type gunRecordingRepoFactory struct {
+	MockNotaryRepoFactory
+	mu   sync.Mutex
+	guns []string
+}
+
+func (f *gunRecordingRepoFactory) NewRepoClient(ctx context.Context, img string, c NotaryConfig) (client.Repository, error) {
+	f.mu.Lock()
+	f.guns = append(f.guns, img)
+	f.mu.Unlock()
+	return f.MockNotaryRepoFactory.NewRepoClient(ctx, img, c)
+}
+
+// pathRecordingTransport records paths of registry requests.
+type pathRecordingTransport struct {
+	next  http.RoundTripper
+	mu    sync.Mutex
+	paths []string
+}
+
+func (t *pathRecordingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.paths = append(t.paths, r.URL.Path)
+	t.mu.Unlock()
+	return t.next.RoundTrip(r)
+}
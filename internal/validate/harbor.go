@@ -0,0 +1,72 @@
+package validate
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// HarborNotaryPort is the port on which Harbor exposes its notary server.
+	HarborNotaryPort = "4443"
+
+	harborTokenPath    = "/service/token"
+	harborTokenService = "harbor-notary"
+)
+
+// withHarborDefaults derives the notary URL from NotaryConfig.HarborURL when it's not set explicitly.
+// Configs without HarborURL are returned as is.
+func (c NotaryConfig) withHarborDefaults() (NotaryConfig, error) {
+	if c.HarborURL == "" || c.Url != "" {
+		return c, nil
+	}
+	u, err := url.Parse(c.HarborURL)
+	if err != nil {
+		return c, fmt.Errorf("harbor URL parse: %w", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return c, fmt.Errorf("harbor URL %s must have the scheme and the host", c.HarborURL)
+	}
+	c.Url = fmt.Sprintf("%s://%s", u.Scheme, net.JoinHostPort(u.Hostname(), HarborNotaryPort))
+	return c, nil
+}
+
+// harborChallenge replaces the token realm announced by Harbor notary,
+// which often points to the Harbor core service address that isn't reachable from the cluster.
+func (c NotaryConfig) harborChallenge(ping *http.Response) *http.Response {
+	realm := strings.TrimSuffix(c.HarborURL, "/") + harborTokenPath
+	header := http.Header{}
+	header.Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm=%q,service=%q`, realm, harborTokenService))
+	return &http.Response{
+		StatusCode: http.StatusUnauthorized,
+		Header:     header,
+		Request:    ping.Request,
+	}
+}
+
+// harborGUN drops the port from GUNs of repositories in Harbor,
+// Harbor signs images under the host name of its external URL.
+func (c NotaryConfig) harborGUN(gun string) string {
+	if c.HarborURL == "" {
+		return gun
+	}
+	u, err := url.Parse(c.HarborURL)
+	if err != nil {
+		return gun
+	}
+	hostport, path, ok := strings.Cut(gun, "/")
+	if !ok {
+		return gun
+	}
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil || host != u.Hostname() {
+		return gun
+	}
+	if strings.Contains(host, ":") {
+		// IPv6 hosts keep the brackets
+		host = "[" + host + "]"
+	}
+	return host + "/" + path
+}
@@ -0,0 +1,80 @@
+package validate_test
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kyma-project/warden/internal/validate"
+	"github.com/kyma-project/warden/internal/validate/validatetest"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	harborRobotUser     = "robot$warden"
+	harborRobotPassword = "robot-secret"
+	harborToken         = "harbor-token"
+)
+
+func TestHarborPreset(t *testing.T) {
+	//GIVEN
+	hash, err := hex.DecodeString("4e6f8a0c2e4b6d8f6f8a2e1c7b4d9f0e3a5c8b7d2e1f4a6c9b0d3e5f7a8c1b2d")
+	require.NoError(t, err)
+	repo := "harbor.example.com/project/app"
+	signed := validatetest.NewNotaryServer(t).WithTarget(repo, "1.0", hash)
+
+	var issued int32
+	harbor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		switch {
+		case r.URL.Path != "/service/token" || r.URL.Query().Get("service") != "harbor-notary":
+			w.WriteHeader(http.StatusNotFound)
+		case !ok || user != harborRobotUser || password != harborRobotPassword:
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Query().Get("scope") != "repository:"+repo+":pull":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			atomic.AddInt32(&issued, 1)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"token": harborToken, "expires_in": 300})
+		}
+	}))
+	defer harbor.Close()
+
+	// Harbor notary announces the token service under the internal address of Harbor core
+	signedURL, err := url.Parse(signed.URL)
+	require.NoError(t, err)
+	proxy := httputil.NewSingleHostReverseProxy(signedURL)
+	notary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+harborToken {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="http://harbor-core.invalid/service/token",service="harbor-notary"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	defer notary.Close()
+
+	validator, err := validate.NewImageValidator(&validate.ServiceConfig{
+		NotaryConfig: validate.NotaryConfig{
+			Url:       notary.URL,
+			HarborURL: harbor.URL,
+			Username:  harborRobotUser,
+			Password:  harborRobotPassword,
+		},
+	}, validate.WithRepoFactory(validate.NotaryRepoFactory{Timeout: time.Second, TrustDir: t.TempDir()}))
+	require.NoError(t, err)
+
+	//WHEN
+	err = validator.ValidateWithDigest(context.TODO(), repo+":1.0", "sha256:"+hex.EncodeToString(hash))
+
+	//THEN
+	require.NoError(t, err)
+	require.Positive(t, atomic.LoadInt32(&issued))
+}
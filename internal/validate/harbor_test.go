@@ -0,0 +1,80 @@
+package validate
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotaryConfig_withHarborDefaults(t *testing.T) {
+	testCases := []struct {
+		name          string
+		config        NotaryConfig
+		expectedURL   string
+		expectedError string
+	}{
+		{
+			name:        "notary URL is derived from Harbor URL",
+			config:      NotaryConfig{HarborURL: "https://harbor.example.com"},
+			expectedURL: "https://harbor.example.com:4443",
+		},
+		{
+			name:        "port and path of Harbor URL are not used",
+			config:      NotaryConfig{HarborURL: "https://harbor.example.com:8443/harbor/"},
+			expectedURL: "https://harbor.example.com:4443",
+		},
+		{
+			name:        "explicit notary URL takes precedence",
+			config:      NotaryConfig{HarborURL: "https://harbor.example.com", Url: "https://notary.harbor.example.com"},
+			expectedURL: "https://notary.harbor.example.com",
+		},
+		{
+			name:        "config without Harbor URL is unaffected",
+			config:      NotaryConfig{Url: "https://notary.example.com"},
+			expectedURL: "https://notary.example.com",
+		},
+		{
+			name:          "Harbor URL without scheme",
+			config:        NotaryConfig{HarborURL: "harbor.example.com"},
+			expectedError: "harbor URL harbor.example.com must have the scheme and the host",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := tt.config.withHarborDefaults()
+
+			if tt.expectedError != "" {
+				require.EqualError(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedURL, c.Url)
+		})
+	}
+}
+
+func TestNotaryConfig_harborGUN(t *testing.T) {
+	c := NotaryConfig{HarborURL: "https://harbor.example.com"}
+
+	require.Equal(t, "harbor.example.com/project/app", c.harborGUN("harbor.example.com:443/project/app"))
+	require.Equal(t, "harbor.example.com/project/app", c.harborGUN("harbor.example.com/project/app"))
+	require.Equal(t, "registry.example.com:443/project/app", c.harborGUN("registry.example.com:443/project/app"))
+	require.Equal(t, "harbor.example.com:443/project/app", NotaryConfig{}.harborGUN("harbor.example.com:443/project/app"))
+
+	ipv6 := NotaryConfig{HarborURL: "https://[2001:db8::1]"}
+	require.Equal(t, "[2001:db8::1]/project/app", ipv6.harborGUN("[2001:db8::1]:443/project/app"))
+	require.Equal(t, "[2001:db8::1]/project/app", ipv6.harborGUN("[2001:db8::1]/project/app"))
+}
+
+func TestNotaryConfig_harborChallenge(t *testing.T) {
+	ping, err := http.NewRequest(http.MethodGet, "https://harbor.example.com:4443/v2/", nil)
+	require.NoError(t, err)
+	c := NotaryConfig{HarborURL: "https://harbor.example.com/"}
+
+	resp := c.harborChallenge(&http.Response{Request: ping})
+
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	require.Equal(t, `Bearer realm="https://harbor.example.com/service/token",service="harbor-notary"`, resp.Header.Get("WWW-Authenticate"))
+	require.Equal(t, ping, resp.Request)
+}
@@ -0,0 +1,49 @@
+package validate
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	SHA256Algorithm = "sha256"
+	SHA512Algorithm = "sha512"
+)
+
+// supportedHashAlgorithms are ordered from the strongest one, which is preferred when a target has several hashes.
+var supportedHashAlgorithms = []string{SHA512Algorithm, SHA256Algorithm}
+
+func newHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case SHA256Algorithm:
+		return sha256.New(), nil
+	case SHA512Algorithm:
+		return sha512.New(), nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedHashAlgorithm, algorithm)
+}
+
+// selectHash picks the strongest supported algorithm from notary target hashes,
+// hashes with other algorithms are never compared against the registry digest.
+func selectHash(hashes map[string][]byte) (string, []byte, error) {
+	if len(hashes) == 0 {
+		return "", nil, policyError{errors.New("image hash is missing")}
+	}
+	for _, algorithm := range supportedHashAlgorithms {
+		if h, ok := hashes[algorithm]; ok {
+			return algorithm, h, nil
+		}
+	}
+	algorithms := make([]string, 0, len(hashes))
+	for algorithm := range hashes {
+		algorithms = append(algorithms, algorithm)
+	}
+	sort.Strings(algorithms)
+	return "", nil, fmt.Errorf("%w: %s", ErrUnsupportedHashAlgorithm, strings.Join(algorithms, ", "))
+}
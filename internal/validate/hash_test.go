@@ -0,0 +1,262 @@
+package validate
+
+import (
+	"context"
+	"crypto/sha512"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_selectHash(t *testing.T) {
+	sha256Hash := []byte{1, 2, 3}
+	sha512Hash := []byte{4, 5, 6}
+
+	tests := []struct {
+		name              string
+		hashes            map[string][]byte
+		expectedAlgorithm string
+		expectedHash      []byte
+		expectedErr       string
+	}{
+		{
+			name:              "sha256 only",
+			hashes:            map[string][]byte{SHA256Algorithm: sha256Hash},
+			expectedAlgorithm: SHA256Algorithm,
+			expectedHash:      sha256Hash,
+		},
+		{
+			name:              "sha512 only",
+			hashes:            map[string][]byte{SHA512Algorithm: sha512Hash},
+			expectedAlgorithm: SHA512Algorithm,
+			expectedHash:      sha512Hash,
+		},
+		{
+			name:              "sha512 is preferred over sha256",
+			hashes:            map[string][]byte{SHA256Algorithm: sha256Hash, SHA512Algorithm: sha512Hash},
+			expectedAlgorithm: SHA512Algorithm,
+			expectedHash:      sha512Hash,
+		},
+		{
+			name:              "unsupported algorithm is skipped",
+			hashes:            map[string][]byte{"md5": {7, 8, 9}, SHA512Algorithm: sha512Hash},
+			expectedAlgorithm: SHA512Algorithm,
+			expectedHash:      sha512Hash,
+		},
+		{
+			name:        "md5 only",
+			hashes:      map[string][]byte{"md5": {7, 8, 9}},
+			expectedErr: "unsupported hash algorithm: md5",
+		},
+		{
+			name:        "only unsupported algorithms",
+			hashes:      map[string][]byte{"md5": {7, 8, 9}, "sha1": {1, 2, 3}, "custom": {4, 5, 6}},
+			expectedErr: "unsupported hash algorithm: custom, md5, sha1",
+		},
+		{
+			name:        "no hashes",
+			hashes:      map[string][]byte{},
+			expectedErr: "image hash is missing",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			algorithm, hash, err := selectHash(tt.hashes)
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				if len(tt.hashes) > 0 {
+					require.ErrorIs(t, err, ErrUnsupportedHashAlgorithm)
+				}
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedAlgorithm, algorithm)
+			require.Equal(t, tt.expectedHash, hash)
+		})
+	}
+}
+
+func Test_getNotaryImageDigestHash_MultiHashTarget(t *testing.T) {
+	sha256Hash := []byte{1, 2, 3}
+	sha512Hash := []byte{4, 5, 6}
+
+	f := NewDefaultMockNotaryFunction().
+		WithHashes(map[string][]byte{SHA256Algorithm: sha256Hash, SHA512Algorithm: sha512Hash}).
+		Build()
+	s := NewDefaultMockNotaryService().WithFunc(f).Build()
+
+	signed, err := s.getNotaryImageDigestHash(context.TODO(), "eu.gcr.io/kyma-project/image", "tag")
+
+	require.NoError(t, err)
+	require.Equal(t, SHA512Algorithm, signed.algorithm)
+	require.Equal(t, sha512Hash, signed.hash)
+}
+
+func Test_Validate_MultiHashTarget(t *testing.T) {
+	image := "eu.gcr.io/kyma-project/image:multi-hash"
+	registryTransport, img := pushTestImageAs(t, image)
+	rawConfig, err := img.RawConfigFile()
+	require.NoError(t, err)
+	sum := sha512.Sum512(rawConfig)
+	sha512Hash := sum[:]
+	sha256Hash := configHash(t, img)
+	otherHash := func(h []byte) []byte {
+		other := append([]byte{}, h...)
+		other[0]++
+		return other
+	}
+
+	tests := []struct {
+		name        string
+		hashes      map[string][]byte
+		expectedErr string
+	}{
+		{
+			name:   "all hashes match",
+			hashes: map[string][]byte{SHA256Algorithm: sha256Hash, SHA512Algorithm: sha512Hash},
+		},
+		{
+			name:   "sha512 only",
+			hashes: map[string][]byte{SHA512Algorithm: sha512Hash},
+		},
+		{
+			name:   "unsupported algorithm next to a supported one",
+			hashes: map[string][]byte{"blake3": {1, 2, 3}, SHA256Algorithm: sha256Hash},
+		},
+		{
+			// the md5 value has the sha256 length, it must not be compared against the registry digest
+			name:        "md5 only with sha256 length",
+			hashes:      map[string][]byte{"md5": sha256Hash},
+			expectedErr: "unsupported hash algorithm: md5",
+		},
+		{
+			name:        "mixed entries with mismatching supported hash",
+			hashes:      map[string][]byte{"md5": sha256Hash, SHA512Algorithm: otherHash(sha512Hash)},
+			expectedErr: "unexpected image hash value",
+		},
+		{
+			name:        "strongest hash doesn't match",
+			hashes:      map[string][]byte{SHA256Algorithm: sha256Hash, SHA512Algorithm: otherHash(sha512Hash)},
+			expectedErr: "unexpected image hash value",
+		},
+		{
+			name:        "no supported algorithm",
+			hashes:      map[string][]byte{"blake3": {1, 2, 3}},
+			expectedErr: "unsupported hash algorithm: blake3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewDefaultMockNotaryFunction().WithHashes(tt.hashes).Build()
+			s := NewDefaultMockNotaryService().WithFunc(f).WithRegistryTransport(registryTransport).Build()
+
+			err := s.Validate(context.TODO(), image)
+
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func Test_getImageDigestHash_UsesNotaryAlgorithm(t *testing.T) {
+	ref, img := pushTestImage(t)
+	cfgDigest, err := img.ConfigName()
+	require.NoError(t, err)
+	rawConfig, err := img.RawConfigFile()
+	require.NoError(t, err)
+
+	expectedSha256, err := hex.DecodeString(cfgDigest.Hex)
+	require.NoError(t, err)
+	sum := sha512.Sum512(rawConfig)
+	expectedSha512 := sum[:]
+
+	s := NewDefaultMockNotaryService().WithInsecureRegistries(registryHost(ref)).Build()
+
+	t.Run("sha256", func(t *testing.T) {
+		hash, err := s.getImageDigestHash(context.TODO(), ref, SHA256Algorithm)
+		require.NoError(t, err)
+		require.Equal(t, expectedSha256, hash.config)
+	})
+
+	t.Run("sha512", func(t *testing.T) {
+		hash, err := s.getImageDigestHash(context.TODO(), ref, SHA512Algorithm)
+		require.NoError(t, err)
+		require.Equal(t, expectedSha512, hash.config)
+	})
+
+	t.Run("unsupported algorithm", func(t *testing.T) {
+		_, err := s.getImageDigestHash(context.TODO(), ref, "md5")
+		require.EqualError(t, err, "unsupported hash algorithm: md5")
+	})
+}
+
+// pushTestImage pushes a random image to an in-memory registry and returns its reference.
+func pushTestImage(t *testing.T) (string, v1.Image) {
+	srv := httptest.NewServer(registry.New())
+	t.Cleanup(srv.Close)
+
+	img, err := random.Image(128, 1)
+	require.NoError(t, err)
+
+	ref := strings.TrimPrefix(srv.URL, "http://") + "/test/image:tag"
+	tag, err := name.ParseReference(ref)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(tag, img))
+
+	return ref, img
+}
+
+// pushTestImageAs pushes a random image under the given name to an in-memory registry
+// and returns the transport which redirects registry calls to it.
+func pushTestImageAs(t *testing.T, image string) (http.RoundTripper, v1.Image) {
+	srv := httptest.NewServer(registry.New())
+	t.Cleanup(srv.Close)
+	transport := redirectTransport{host: strings.TrimPrefix(srv.URL, "http://")}
+
+	img, err := random.Image(128, 1)
+	require.NoError(t, err)
+
+	ref, err := name.ParseReference(image)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img, remote.WithTransport(transport)))
+
+	return transport, img
+}
+
+// registryHost returns the host of the image reference pushed to the test registry.
+func registryHost(image string) string {
+	return strings.SplitN(image, "/", 2)[0]
+}
+
+// redirectTransport sends all requests to the test registry.
+type redirectTransport struct {
+	host string
+}
+
+func (rt redirectTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Scheme = "http"
+	r.URL.Host = rt.host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// configHash returns the image hash which is compared against notary.
+func configHash(t *testing.T, img v1.Image) []byte {
+	cfgDigest, err := img.ConfigName()
+	require.NoError(t, err)
+	hash, err := hex.DecodeString(cfgDigest.Hex)
+	require.NoError(t, err)
+	return hash
+}
@@ -0,0 +1,96 @@
+package validate
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// DefaultNotaryHealthTimeout caps the health check, so readiness probes don't hang on a slow notary.
+const DefaultNotaryHealthTimeout = 5 * time.Second
+
+// NotaryHealthChecker checks whether notary can be reached with the configured credentials.
+type NotaryHealthChecker struct {
+	factory NotaryRepoFactory
+	config  NotaryConfig
+	// canaryGUN is the repository whose root metadata is fetched,
+	// only the notary endpoint is pinged when it's empty.
+	canaryGUN string
+	timeout   time.Duration
+}
+
+// NewNotaryHealthChecker returns the checker which shares connections and tokens with validations made through factory,
+// DefaultNotaryHealthTimeout is used when timeout isn't set.
+func NewNotaryHealthChecker(factory NotaryRepoFactory, config NotaryConfig, canaryGUN string, timeout time.Duration) *NotaryHealthChecker {
+	if timeout <= 0 {
+		timeout = DefaultNotaryHealthTimeout
+	}
+	return &NotaryHealthChecker{
+		factory:   factory,
+		config:    config,
+		canaryGUN: canaryGUN,
+		timeout:   timeout,
+	}
+}
+
+// Health returns an error when none of the notary endpoints is reachable or serves the canary root metadata.
+func (h *NotaryHealthChecker) Health(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	config, err := h.config.withHarborDefaults()
+	if err != nil {
+		return err
+	}
+	// validations fail over to the other endpoints, so one healthy endpoint is enough
+	for _, u := range config.urls() {
+		config.Url = u
+		if err = h.endpointHealth(ctx, config); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (h *NotaryHealthChecker) endpointHealth(ctx context.Context, config NotaryConfig) error {
+	rt, err := h.factory.authTransport(ctx, h.canaryGUN, config)
+	if err != nil {
+		return errors.Wrap(err, "while connecting to notary")
+	}
+	if h.canaryGUN == "" {
+		return nil
+	}
+
+	u := fmt.Sprintf("%s/v2/%s/_trust/tuf/root.json", config.Url, h.canaryGUN)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := (&http.Client{Transport: rt}).Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "while fetching root metadata of %s", h.canaryGUN)
+	}
+	defer drainBody(resp)
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("notary returned status code %d for root metadata of %s", resp.StatusCode, h.canaryGUN)
+	}
+	return nil
+}
+
+// Check adapts Health to the healthz.Checker signature used by the manager readiness checks.
+func (h *NotaryHealthChecker) Check(req *http.Request) error {
+	return h.Health(req.Context())
+}
+
+// WarningCheck logs the error of Health instead of failing the probe, so a notary outage is reported
+// without taking the whole process out of service.
+func (h *NotaryHealthChecker) WarningCheck(req *http.Request) error {
+	if err := h.Health(req.Context()); err != nil {
+		log.Log.WithName("notary-health").Error(err, "notary isn't healthy", "canaryGUN", h.canaryGUN)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package validate
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotaryHealthChecker_Health(t *testing.T) {
+	canary := "eu.gcr.io/kyma-project/canary"
+
+	t.Run("healthy", func(t *testing.T) {
+		//GIVEN
+		tokenSrv := httptest.NewServer(&fakeTokenService{})
+		defer tokenSrv.Close()
+		notary := &fakeTokenNotary{realm: tokenSrv.URL + "/service/token"}
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "" && r.URL.Path == "/v2/"+canary+"/_trust/tuf/root.json" {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			notary.ServeHTTP(w, r)
+		}))
+		defer srv.Close()
+		config := NotaryConfig{Url: srv.URL, Username: testNotaryUser, Password: testNotaryPassword}
+		h := NewNotaryHealthChecker(NewNotaryRepoFactory(time.Second), config, canary, time.Second)
+
+		//WHEN
+		err := h.Health(context.TODO())
+
+		//THEN
+		require.NoError(t, err)
+	})
+
+	t.Run("healthy without canary", func(t *testing.T) {
+		//GIVEN
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer srv.Close()
+		h := NewNotaryHealthChecker(NewNotaryRepoFactory(time.Second), NotaryConfig{Url: srv.URL}, "", time.Second)
+
+		//WHEN
+		err := h.Health(context.TODO())
+
+		//THEN
+		require.NoError(t, err)
+	})
+
+	t.Run("healthy fallback endpoint", func(t *testing.T) {
+		//GIVEN
+		failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer failing.Close()
+		fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer fallback.Close()
+		config := NotaryConfig{Url: failing.URL, FallbackUrls: []string{fallback.URL}}
+		h := NewNotaryHealthChecker(NewNotaryRepoFactory(time.Second), config, "", time.Second)
+
+		//WHEN
+		err := h.Health(context.TODO())
+
+		//THEN
+		require.NoError(t, err)
+	})
+
+	t.Run("notary returns 500", func(t *testing.T) {
+		//GIVEN
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer srv.Close()
+		h := NewNotaryHealthChecker(NewNotaryRepoFactory(time.Second), NotaryConfig{Url: srv.URL}, canary, time.Second)
+
+		//WHEN
+		err := h.Health(context.TODO())
+
+		//THEN
+		require.EqualError(t, err, "while connecting to notary: couln't correctly connect to notary, status code: 500")
+	})
+
+	t.Run("root metadata returns 500", func(t *testing.T) {
+		//GIVEN
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/v2/" {
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer srv.Close()
+		h := NewNotaryHealthChecker(NewNotaryRepoFactory(time.Second), NotaryConfig{Url: srv.URL}, canary, time.Second)
+
+		//WHEN
+		err := h.Health(context.TODO())
+
+		//THEN
+		require.EqualError(t, err, "notary returned status code 500 for root metadata of "+canary)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		//GIVEN
+		release := make(chan struct{})
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}))
+		defer srv.Close()
+		defer close(release)
+		timeout := 100 * time.Millisecond
+		h := NewNotaryHealthChecker(NewNotaryRepoFactory(time.Minute), NotaryConfig{Url: srv.URL}, canary, timeout)
+		start := time.Now()
+
+		//WHEN
+		err := h.Health(context.TODO())
+
+		//THEN
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Less(t, time.Since(start), 10*timeout)
+	})
+}
+
+func TestNotaryHealthChecker_Checks(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	h := NewNotaryHealthChecker(NewNotaryRepoFactory(time.Second), NotaryConfig{Url: srv.URL}, "eu.gcr.io/kyma-project/canary", time.Second)
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+
+	t.Run("check fails the probe", func(t *testing.T) {
+		require.Error(t, h.Check(req))
+	})
+
+	t.Run("warning check doesn't fail the probe", func(t *testing.T) {
+		require.NoError(t, h.WarningCheck(req))
+	})
+}
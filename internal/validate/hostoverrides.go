@@ -0,0 +1,93 @@
+package validate
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// HostOverride replaces the global timeouts and retries for images from a registry host,
+// fields which aren't set keep the global values.
+type HostOverride struct {
+	// NotaryTimeout replaces ServiceConfig.NotaryTimeout for the notary lookups of the images,
+	// the notary ping is still capped by NotaryRepoFactory.Timeout.
+	NotaryTimeout time.Duration
+	// RegistryTimeout replaces ServiceConfig.RegistryTimeout for the registry lookups of the images.
+	RegistryTimeout time.Duration
+	// RegistryRetries replaces the number of retries of requests which the registry rate limited,
+	// it applies to the registry which is asked, i.e. to the mirror of mirrored registries.
+	RegistryRetries *int
+}
+
+// hostSettings are the timeouts and retries which apply to a registry host.
+type hostSettings struct {
+	notaryTimeout   time.Duration
+	registryTimeout time.Duration
+	registryRetries int
+}
+
+// validateHostOverrides rejects overrides which can't apply to any host or budget.
+func validateHostOverrides(overrides map[string]HostOverride) []error {
+	hosts := make([]string, 0, len(overrides))
+	for host := range overrides {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	var errs []error
+	for _, host := range hosts {
+		o := overrides[host]
+		if err := validateRegistryHost(host); err != nil {
+			errs = append(errs, fmt.Errorf("host override: %w", err))
+		}
+		if o.NotaryTimeout < 0 || o.RegistryTimeout < 0 || (o.RegistryRetries != nil && *o.RegistryRetries < 0) {
+			errs = append(errs, fmt.Errorf("host override %s: timeouts and retries must not be negative", host))
+		}
+	}
+	return errs
+}
+
+// settingsFor returns the settings of the registry host of the repository or the image reference,
+// hosts are normalized, so "docker.io" matches images from Docker Hub and IPv6 hosts match in any notation.
+// Hosts without the override use the global settings.
+func (s *notaryService) settingsFor(image string) hostSettings {
+	var registry string
+	if repo, err := name.NewRepository(image); err == nil {
+		registry = repo.RegistryStr()
+	} else if ref, err := name.ParseReference(image); err == nil {
+		registry = ref.Context().RegistryStr()
+	}
+	return s.settingsForRegistry(registry)
+}
+
+// settingsForRegistry returns the settings of the registry host as go-containerregistry names it.
+func (s *notaryService) settingsForRegistry(registry string) hostSettings {
+	settings := hostSettings{
+		notaryTimeout:   s.NotaryTimeout,
+		registryTimeout: s.RegistryTimeout,
+		registryRetries: registryRateLimitRetries,
+	}
+	if registry == "" {
+		return settings
+	}
+	registry = normalizeRegistryHost(registry)
+	for host, o := range s.HostOverrides {
+		r, err := name.NewRegistry(normalizeRegistryHost(host))
+		if err != nil || normalizeRegistryHost(r.RegistryStr()) != registry {
+			continue
+		}
+		if o.NotaryTimeout > 0 {
+			settings.notaryTimeout = o.NotaryTimeout
+		}
+		if o.RegistryTimeout > 0 {
+			settings.registryTimeout = o.RegistryTimeout
+		}
+		if o.RegistryRetries != nil {
+			settings.registryRetries = *o.RegistryRetries
+		}
+		break
+	}
+	return settings
+}
@@ -0,0 +1,228 @@
+package validate
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+// hostDelayTransport delays the requests to the hosts.
+type hostDelayTransport struct {
+	next   http.RoundTripper
+	delays map[string]time.Duration
+}
+
+func (t hostDelayTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	return slowTransport{next: t.next, delay: t.delays[r.URL.Host]}.RoundTrip(r)
+}
+
+func Test_Validate_HostOverrides(t *testing.T) {
+	delay := 200 * time.Millisecond
+	fastHost, slowHost := "harbor.internal.example.com", "partner.registry.example.com"
+	fastImage, slowImage := fastHost+"/kyma-project/app:fast", slowHost+"/kyma-project/app:slow"
+	registries := newHostRoutingTransport(t, fastHost, slowHost)
+	hashes := map[string][]byte{
+		"fast": registries.push(t, fastHost, "kyma-project/app:fast"),
+		"slow": registries.push(t, slowHost, "kyma-project/app:slow"),
+	}
+	transport := hostDelayTransport{next: registries, delays: map[string]time.Duration{slowHost: delay}}
+	notaryDelays := map[string]time.Duration{"fast": 0, "slow": delay}
+	f := func(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+		time.Sleep(notaryDelays[name])
+		return &client.TargetWithRole{Target: client.Target{Name: name, Hashes: data.Hashes{SHA256Algorithm: hashes[name]}}, Role: NotaryReleasesRole}, nil
+	}
+
+	tests := []struct {
+		name                string
+		notaryTimeout       time.Duration
+		registryTimeout     time.Duration
+		overrides           map[string]HostOverride
+		expectedFastTimeout Phase
+		expectedSlowTimeout Phase
+	}{
+		{
+			name:            "global budget for the worst case",
+			notaryTimeout:   10 * delay,
+			registryTimeout: 10 * delay,
+		},
+		{
+			name:                "slow registry over the global registry budget",
+			notaryTimeout:       10 * delay,
+			registryTimeout:     delay / 2,
+			expectedSlowTimeout: RegistryPhase,
+		},
+		{
+			name:                "slow host over the global notary budget",
+			notaryTimeout:       delay / 2,
+			registryTimeout:     10 * delay,
+			expectedSlowTimeout: NotaryPhase,
+		},
+		{
+			name:            "slow host gets its own budget",
+			notaryTimeout:   delay / 2,
+			registryTimeout: delay / 2,
+			overrides: map[string]HostOverride{
+				slowHost: {NotaryTimeout: 10 * delay, RegistryTimeout: 10 * delay},
+			},
+		},
+		{
+			name:            "override keeps the global value it doesn't set",
+			notaryTimeout:   delay / 2,
+			registryTimeout: 10 * delay,
+			overrides: map[string]HostOverride{
+				slowHost: {RegistryTimeout: 10 * delay},
+			},
+			expectedSlowTimeout: NotaryPhase,
+		},
+		{
+			name:            "fast host gets a tighter budget",
+			notaryTimeout:   10 * delay,
+			registryTimeout: 10 * delay,
+			overrides: map[string]HostOverride{
+				fastHost: {RegistryTimeout: time.Nanosecond},
+			},
+			expectedFastTimeout: RegistryPhase,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//GIVEN
+			s := NewDefaultMockNotaryService().WithFunc(f).WithRegistryTransport(transport).Build()
+			s.NotaryTimeout = tt.notaryTimeout
+			s.RegistryTimeout = tt.registryTimeout
+			s.HostOverrides = tt.overrides
+
+			//WHEN
+			fastErr := s.Validate(context.TODO(), fastImage)
+			slowErr := s.Validate(context.TODO(), slowImage)
+
+			//THEN
+			for _, check := range []struct {
+				err      error
+				expected Phase
+			}{
+				{fastErr, tt.expectedFastTimeout},
+				{slowErr, tt.expectedSlowTimeout},
+			} {
+				if check.expected == "" {
+					require.NoError(t, check.err)
+					continue
+				}
+				var timeoutErr PhaseTimeoutError
+				require.ErrorAs(t, check.err, &timeoutErr)
+				require.Equal(t, check.expected, timeoutErr.Phase)
+			}
+		})
+	}
+}
+
+func Test_fetchDescriptor_HostOverrideRetries(t *testing.T) {
+	noRetries := 0
+	tests := []struct {
+		name             string
+		overrides        map[string]HostOverride
+		expectedRequests int32
+	}{
+		{
+			name:             "default retries",
+			expectedRequests: registryRateLimitRetries + 1,
+		},
+		{
+			name:             "retries of the host",
+			overrides:        map[string]HostOverride{"eu.gcr.io": {RegistryRetries: &noRetries}},
+			expectedRequests: 1,
+		},
+		{
+			name:             "retries of another host",
+			overrides:        map[string]HostOverride{"ghcr.io": {RegistryRetries: &noRetries}},
+			expectedRequests: registryRateLimitRetries + 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//GIVEN
+			var manifestRequests int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.Contains(r.URL.Path, "/manifests/") {
+					atomic.AddInt32(&manifestRequests, 1)
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(http.StatusTooManyRequests)
+				}
+			}))
+			defer srv.Close()
+			s := NewDefaultMockNotaryService().
+				WithRegistryTransport(redirectTransport{host: strings.TrimPrefix(srv.URL, "http://")}).
+				Build()
+			s.HostOverrides = tt.overrides
+
+			//WHEN
+			_, err := s.registryPhase(context.TODO(), "eu.gcr.io/kyma-project/app:1.0", SHA256Algorithm)
+
+			//THEN
+			require.ErrorIs(t, err, ErrRegistryRateLimited)
+			require.Equal(t, tt.expectedRequests, atomic.LoadInt32(&manifestRequests))
+		})
+	}
+}
+
+func Test_settingsFor(t *testing.T) {
+	retries := 1
+	s := NewDefaultMockNotaryService().Build()
+	s.NotaryTimeout = time.Second
+	s.RegistryTimeout = 2 * time.Second
+	s.HostOverrides = map[string]HostOverride{
+		"docker.io":      {NotaryTimeout: time.Minute},
+		"::1":            {RegistryTimeout: time.Minute},
+		"localhost:5000": {RegistryRetries: &retries},
+	}
+	defaults := hostSettings{notaryTimeout: time.Second, registryTimeout: 2 * time.Second, registryRetries: registryRateLimitRetries}
+	tests := []struct {
+		name     string
+		image    string
+		expected hostSettings
+	}{
+		{
+			name:     "docker hub repository",
+			image:    "library/busybox",
+			expected: hostSettings{notaryTimeout: time.Minute, registryTimeout: 2 * time.Second, registryRetries: registryRateLimitRetries},
+		},
+		{
+			name:     "docker hub image",
+			image:    "index.docker.io/library/busybox:1.36",
+			expected: hostSettings{notaryTimeout: time.Minute, registryTimeout: 2 * time.Second, registryRetries: registryRateLimitRetries},
+		},
+		{
+			name:     "IPv6 host",
+			image:    "[0:0::1]/kyma-project/app:1.0",
+			expected: hostSettings{notaryTimeout: time.Second, registryTimeout: time.Minute, registryRetries: registryRateLimitRetries},
+		},
+		{
+			name:     "host with port",
+			image:    "localhost:5000/kyma-project/app:1.0",
+			expected: hostSettings{notaryTimeout: time.Second, registryTimeout: 2 * time.Second, registryRetries: 1},
+		},
+		{
+			name:     "unknown host",
+			image:    "eu.gcr.io/kyma-project/app:1.0",
+			expected: defaults,
+		},
+		{
+			name:     "malformed image",
+			image:    "eu.gcr.io/Kyma-project/app:1.0",
+			expected: defaults,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, s.settingsFor(tt.image))
+		})
+	}
+}
@@ -22,137 +22,657 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
 	"strings"
-
-	"github.com/google/go-containerregistry/pkg/name"
-	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/singleflight"
+	"k8s.io/utils/clock"
 )
 
 const (
 	tagDelim = ":"
 )
 
+var errUnexpectedImageHash error = classifiedError{sentinel: ErrDigestMismatch, err: errors.New("unexpected image hash value")}
+
 //go:generate mockery --name=ImageValidatorService
 type ImageValidatorService interface {
 	Validate(ctx context.Context, image string) error
+	ValidateDetailed(ctx context.Context, image string) (ImageValidationResult, error)
+	// ValidateWithDigest verifies that the digest is the one signed for the image tag without asking the registry.
+	ValidateWithDigest(ctx context.Context, image, digest string) error
 }
 
 type ServiceConfig struct {
 	NotaryConfig      NotaryConfig
 	AllowedRegistries []string
+	// AllowedRegistriesFile is the mounted YAML list of entries allowed in addition to AllowedRegistries,
+	// changes of the file are picked up by the next validation.
+	AllowedRegistriesFile string
+	// Exceptions admit the images without the signature verification until they expire,
+	// they are consulted after the allowed registries.
+	Exceptions []ImageException
+	// PinnedDigests admit images pinned to the digests before any other rule and without network calls.
+	PinnedDigests PinnedDigests
+	// DelegationRoles maps repository prefixes to the only delegation role allowed to sign their images.
+	DelegationRoles map[string]data.RoleName
+	// RequiredSignerKeyIDs maps repository prefixes to the keys of which at least one must sign their images,
+	// the longest matching prefix applies.
+	RequiredSignerKeyIDs map[string][]string
+	// DigestTargets enables the fallback for images pinned by digest whose tag isn't signed,
+	// a target named by the digest or with {"digest": "<digest>"} custom metadata verifies the image without the registry.
+	DigestTargets bool
+	// MaxConcurrentRegistryCalls limits registry calls executed at the same time,
+	// DefaultMaxConcurrentRegistryCalls is used when it's not set.
+	MaxConcurrentRegistryCalls int
+	// NotaryTimeout and RegistryTimeout cap the time of each validation phase,
+	// zero means that only the caller's deadline applies.
+	NotaryTimeout   time.Duration
+	RegistryTimeout time.Duration
+	// HostOverrides replace the timeouts and retries for images from the registry hosts.
+	HostOverrides map[string]HostOverride
+	// OfflineTrustBundle replaces notary as the source of signed image targets when it's set.
+	OfflineTrustBundle *OfflineTrustBundle
+	// RegistryKeychains authenticate registry calls per registry host, the first matching keychain is used.
+	RegistryKeychains []RegistryKeychain
+	// DockerConfigPath is the mounted Docker config.json with credentials for registries without RegistryKeychains,
+	// changes of the file are picked up by the next validation.
+	DockerConfigPath string
+	// InsecureRegistries are registry hosts which may be reached over plain HTTP,
+	// calls to other registries never fall back to HTTP.
+	InsecureRegistries []string
+	// NegativeCacheTTL is how long deterministic denials are cached, DefaultNegativeCacheTTL is used when it's not set.
+	NegativeCacheTTL time.Duration
+	// NegativeCacheMaxEntries bounds the number of cached denials, DefaultNegativeCacheMaxEntries is used when it's not set.
+	NegativeCacheMaxEntries int
+	// DisableNegativeCache turns off caching of denials.
+	DisableNegativeCache bool
+	// DigestCacheTTL is how long registry digests of image references are cached, digests aren't cached when it's not set.
+	// The digest cache is used even when the negative cache is disabled.
+	DigestCacheTTL time.Duration
+	// GUNMapping translates image repositories to notary GUNs, registry calls keep using the image repository.
+	GUNMapping GUNMapping
+	// Platform selects the image of indexes compared against notary, DefaultPlatform is used when it's not set.
+	// ContextWithPlatform overrides it for a single validation.
+	Platform string
+	// RequireFQDNRegistry denies images which don't name their registry, unless the Docker Hub repository
+	// they resolve to is in AllowedRegistries.
+	RequireFQDNRegistry bool
+	// RegistryMirrors map upstream registry hosts to mirror hosts which are asked for image digests instead.
+	RegistryMirrors map[string]string
+	// RegistryMirrorFallback asks the upstream registry when the mirror doesn't know the image.
+	RegistryMirrorFallback bool
+	// AllowSchema1 compares images with the deprecated Docker manifest schema1 by the hash of their
+	// v1 compatibility config, they are denied when it's not set.
+	AllowSchema1 bool
+	// MaxRegistryResponseBytes limits bodies of registry responses, e.g. manifests and indexes,
+	// DefaultMaxRegistryResponseBytes is used when it's not set.
+	MaxRegistryResponseBytes int64
+	// VerificationChain verifies signatures with the listed backends instead of notary alone when it has backends.
+	VerificationChain VerificationChain
+	// SlowValidationThreshold logs the request timings of validations which take longer, zero disables the log.
+	SlowValidationThreshold time.Duration
+	// MetricsRegisterer registers notary and registry metrics, metrics.Registry is used when it's not set.
+	MetricsRegisterer prometheus.Registerer
 }
 
 type notaryService struct {
 	ServiceConfig
 	RepoFactory RepoFactory
+	// clock is used by the limiter, the negative cache and exceptions, clock.RealClock is used when it's not set.
+	clock           clock.Clock
+	limiter         *notaryRateLimiter
+	registryLimiter *registryCallLimiter
+	negativeCache   *negativeCache
+	flights         *singleflight.Group
+	metrics         *phaseMetrics
+	digestCache     *digestCache
+	dockerConfig    *dockerConfigKeychain
+	endpoints       *notaryEndpoints
+	allowedFile     *allowedRegistriesFile
+	// backends are the backends of VerificationChain added with WithBackend.
+	backends map[string]VerificationBackend
+	// registryTransport is used for registry calls, remote.DefaultTransport is used when it's not set.
+	registryTransport http.RoundTripper
+	// decisionHook receives the decisions of validations when it's set.
+	decisionHook *decisionHook
+}
+
+// NewImageValidator returns the validator for the config, options replace the defaults derived from sc.
+// The config with the options applied must pass ServiceConfig.Validate, except that the notary URL
+// isn't required with repository factories other than NotaryRepoFactory, e.g. validatetest.Notary.
+func NewImageValidator(sc *ServiceConfig, opts ...Option) (ImageValidatorService, error) {
+	s := newNotaryService(sc, opts...)
+	if err := s.validateConfig(); err != nil {
+		return nil, err
+	}
+	return s, nil
 }
 
-func NewImageValidator(sc *ServiceConfig, notaryClientFactory RepoFactory) ImageValidatorService {
+func newNotaryService(sc *ServiceConfig, opts ...Option) *notaryService {
+	o := newValidatorOptions(sc, opts)
+	// the service keeps its own copy, so later changes of sc don't affect it
+	config := o.apply(*sc)
+	if notaryConfig, err := config.NotaryConfig.withHarborDefaults(); err == nil {
+		// invalid Harbor URL is reported by the repository factory
+		config.NotaryConfig = notaryConfig
+	}
+	metrics := newPhaseMetrics(config.MetricsRegisterer)
 	return &notaryService{
-		ServiceConfig: ServiceConfig{
-			NotaryConfig:      sc.NotaryConfig,
-			AllowedRegistries: sc.AllowedRegistries,
-		},
-		RepoFactory: notaryClientFactory,
+		ServiceConfig:     config,
+		RepoFactory:       o.repoFactory,
+		clock:             o.clock,
+		limiter:           newNotaryRateLimiter(config.NotaryConfig, o.clock, metrics),
+		registryLimiter:   newRegistryCallLimiter(config.MaxConcurrentRegistryCalls, metrics),
+		negativeCache:     newNegativeCache(&config, o.clock, metrics),
+		flights:           &singleflight.Group{},
+		metrics:           metrics,
+		digestCache:       newDigestCache(&config, o.clock),
+		dockerConfig:      newDockerConfigKeychain(config.DockerConfigPath),
+		endpoints:         newNotaryEndpoints(config.NotaryConfig),
+		allowedFile:       newAllowedRegistriesFile(config.AllowedRegistriesFile),
+		backends:          o.backends,
+		registryTransport: o.registryTransport(),
+		decisionHook:      o.decisionHook,
 	}
 }
 
 func (s *notaryService) Validate(ctx context.Context, image string) error {
+	_, err := s.ValidateDetailed(ctx, image)
+	return err
+}
 
-	split := strings.Split(image, tagDelim)
+func (s *notaryService) ValidateDetailed(ctx context.Context, image string) (ImageValidationResult, error) {
+	result, err := s.validateCached(ctx, image)
+	s.decisionHook.emit(newValidationEvent(image, result, err), s.metrics)
+	return result, err
+}
 
-	if len(split) != 2 {
-		return errors.New("image name is not formatted correctly")
+func (s *notaryService) validateCached(ctx context.Context, image string) (ImageValidationResult, error) {
+	// the same image may resolve to another digest for another platform
+	key := image + " " + s.platformFor(ctx)
+	if s.allowedFile != nil {
+		key += fmt.Sprintf(" %d", s.allowedFile.version())
+	}
+	if result, err, ok := s.negativeCache.get(key); ok {
+		return result, err
 	}
 
-	imgRepo := split[0]
-	imgTag := split[1]
+	generation := s.negativeCache.currentGeneration()
+	start := time.Now()
+	result, err := s.validate(ctx, image)
+	s.warnIfSlow(ctx, image, time.Since(start), result, err)
+	if isKeyRotationError(err) {
+		s.invalidateRepository(image)
+	}
+	if err != nil && isDeterministicDenial(err) {
+		s.negativeCache.addUnlessInvalidated(generation, key, result, err)
+	}
+	return result, err
+}
 
-	if allowed := s.isImageAllowed(imgRepo); allowed {
-		return nil
+func (s *notaryService) validate(ctx context.Context, image string) (ImageValidationResult, error) {
+	image, ref, result, decided, err := s.decide(image, nil)
+	if decided {
+		return result, err
 	}
 
-	expectedShaBytes, err := s.getNotaryImageDigestHash(ctx, imgRepo, imgTag)
+	if s.VerificationChain.enabled() {
+		result, err = s.verifyChain(ctx, image, func(ctx context.Context) (ImageValidationResult, error) {
+			return s.verifyShared(ctx, ref)
+		})
+	} else {
+		result, err = s.verifyShared(ctx, ref)
+	}
+	result.Image = image
+	return result, err
+}
+
+// decide returns the decision of the rules consulted before the signature verification and the sanitized image,
+// decided is false when the signature has to be verified. The consulted rules are recorded to rec.
+func (s *notaryService) decide(image string, rec *ruleRecorder) (string, imageRef, ImageValidationResult, bool, error) {
+	result := ImageValidationResult{
+		Image:   image,
+		Outcome: OutcomeDenied,
+	}
+
+	image, err := sanitizeImageRef(image)
 	if err != nil {
-		return err
+		return "", imageRef{}, result, true, err
+	}
+	if digest, ok := s.matchPinnedDigest(image, rec); ok {
+		result.Outcome = OutcomePinnedDigest
+		result.ResolvedDigest = digest
+		return image, imageRef{}, result, true, nil
+	}
+	ref, err := s.parseImageRef(image)
+	if err != nil {
+		return image, imageRef{}, result, true, err
 	}
+	result, decided, err := s.decideByPolicy(image, ref, rec)
+	return image, ref, result, decided, err
+}
 
-	shaBytes, err := s.getImageDigestHash(image)
+// matchPinnedDigest returns the pinned digest of the image, the consulted rule is recorded to rec.
+// Pinned digests admit bootstrap images during disaster recovery, so no other rule may deny them.
+func (s *notaryService) matchPinnedDigest(image string, rec *ruleRecorder) (string, bool) {
+	if len(s.PinnedDigests) == 0 {
+		return "", false
+	}
+	if digest, ok := s.PinnedDigests.match(image); ok {
+		rec.record(RuleTrace{Rule: RulePinnedDigests, Entry: digest, Outcome: OutcomePinnedDigest})
+		return digest, true
+	}
+	rec.record(RuleTrace{Rule: RulePinnedDigests})
+	return "", false
+}
+
+// parseImageRef rejects references pinned without a tag unless digest targets can verify them.
+func (s *notaryService) parseImageRef(image string) (imageRef, error) {
+	ref, err := parseImageRef(image)
 	if err != nil {
-		return err
+		return imageRef{}, err
+	}
+	if ref.isDigestOnly() && !s.DigestTargets {
+		return imageRef{}, errMalformedImageName
 	}
+	return ref, nil
+}
 
-	if subtle.ConstantTimeCompare(shaBytes, expectedShaBytes) == 0 {
-		return errors.New("unexpected image hash value")
+// decideByPolicy returns the decision for images which are admitted or denied without the signature verification.
+// The consulted rules are recorded to rec, it may be nil.
+func (s *notaryService) decideByPolicy(image string, ref imageRef, rec *ruleRecorder) (ImageValidationResult, bool, error) {
+	result := ImageValidationResult{
+		Image:   image,
+		Outcome: OutcomeDenied,
+	}
+	if s.RequireFQDNRegistry {
+		rule := RuleTrace{Rule: RuleRequireFQDNRegistry}
+		if !hasRegistryHost(ref.repo) {
+			implied := ref
+			implied.repo = impliedDockerHubRepo(ref.repo)
+			if entry, allowed := s.allowedListEntry(implied, nil); allowed {
+				rule.Entry, rule.Outcome = entry, OutcomeAllowedList
+				rec.record(rule)
+				result.Outcome = OutcomeAllowedList
+				result.AllowedListEntry = entry
+				return result, true, nil
+			}
+			err := ImplicitRegistryError{Image: image, QualifiedImage: implied.tagged()}
+			rule.Outcome, rule.Reason = OutcomeDenied, err.Error()
+			rec.record(rule)
+			return result, true, err
+		}
+		rec.record(rule)
 	}
 
-	return nil
+	if entry, allowed := s.allowedListEntry(ref, rec); allowed {
+		result.Outcome = OutcomeAllowedList
+		result.AllowedListEntry = entry
+		return result, true, nil
+	}
+	if exception, ok := s.activeException(image, ref, rec); ok {
+		result.Outcome = OutcomeException
+		result.ExceptionExpiresAt = exception.ExpiresAt
+		return result, true, nil
+	}
+	return result, false, nil
 }
 
-func (s *notaryService) isImageAllowed(imgRepo string) bool {
-	for _, allowed := range s.AllowedRegistries {
-		// repository is in allowed list
-		if strings.HasPrefix(imgRepo, allowed) {
-			return true
+// verify checks the image signature in notary against the image digest in the registry.
+func (s *notaryService) verify(ctx context.Context, ref imageRef) (ImageValidationResult, error) {
+	if ref.isDigestOnly() {
+		// there's no tag to look up, only the target signed for the digest verifies the image
+		timings := newRequestTimings()
+		result, err := s.verifyDigestTarget(contextWithRequestTimings(ctx, timings), ref)
+		result.Durations.NotaryRequests = timings.snapshot()
+		return result, err
+	}
+	result := ImageValidationResult{
+		Outcome: OutcomeDenied,
+	}
+
+	// notary and the registry are asked at once, notary errors cancel the registry lookup and take precedence
+	// over registry errors, so the denial doesn't depend on which lookup finished first
+	notaryTimings, registryTimings := newRequestTimings(), newRequestTimings()
+	var (
+		expected    signedHash
+		digests     imageDigests
+		notaryErr   error
+		registryErr error
+	)
+	// the pinned digest is verified against notary, so the registry is asked for the signed tag
+	// with the algorithm used by notary unless the target was signed with another one
+	lookupRegistry := func(ctx context.Context, algorithm string) {
+		start := time.Now()
+		digests, registryErr = s.registryPhase(contextWithRequestTimings(ctx, registryTimings), ref.tagged(), algorithm)
+		result.Durations.Registry += time.Since(start)
+	}
+	// digest targets verify pinned images without the registry, so it's asked only after notary signed the tag
+	parallel := !s.DigestTargets || !ref.isPinned()
+	g, groupCtx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		start := time.Now()
+		expected, notaryErr = s.notaryPhase(contextWithRequestTimings(groupCtx, notaryTimings), ref.repo, ref.tag)
+		result.Durations.Notary = time.Since(start)
+		if notaryErr != nil {
+			return notaryErr
+		}
+		if ref.isPinned() {
+			return verifyPinnedDigest(ref, expected)
+		}
+		return nil
+	})
+	if parallel {
+		g.Go(func() error {
+			lookupRegistry(groupCtx, SHA256Algorithm)
+			// the notary lookup isn't cancelled, because its error would explain the denial instead
+			return nil
+		})
+	}
+	err := g.Wait()
+	result.Durations.NotaryRequests = notaryTimings.snapshot()
+
+	var noTrustedTarget NoTrustedTargetError
+	if s.DigestTargets && ref.isPinned() && errors.As(notaryErr, &noTrustedTarget) {
+		digestTimings := newRequestTimings()
+		digestResult, digestErr := s.verifyDigestTarget(contextWithRequestTimings(ctx, digestTimings), ref)
+		digestResult.Durations.Notary += result.Durations.Notary
+		digestResult.Durations.NotaryRequests = sumRequestTimings(result.Durations.NotaryRequests, digestTimings.snapshot())
+		if digestErr == nil {
+			return digestResult, nil
+		}
+		// the tag lookup explains the denial better unless the digest lookup failed for another reason
+		if !errors.As(digestErr, &noTrustedTarget) {
+			return digestResult, digestErr
 		}
 	}
-	return false
+	if notaryErr != nil {
+		return result, notaryErr
+	}
+	result.NotaryRole = expected.role
+	result.SigningKeyIDs = expected.keyIDs
+	result.NotaryEndpoint = expected.endpoint
+	result.Warnings = expected.warnings()
+	if err != nil {
+		return result, err
+	}
+
+	if !parallel || (registryErr == nil && expected.algorithm != SHA256Algorithm) {
+		lookupRegistry(ctx, expected.algorithm)
+	}
+	result.Durations.RegistryRequests = registryTimings.snapshot()
+	if registryErr != nil {
+		return result, registryErr
+	}
+
+	matches, err := digests.matches(expected.hash)
+	if err != nil {
+		return result, err
+	}
+	if !matches {
+		return result, errUnexpectedImageHash
+	}
+
+	result.Outcome = OutcomeSignatureVerified
+	// the digest is built from the notary hash which was compared, so it's not fetched again
+	result.ResolvedDigest = verifiedDigest(ref.repo, expected)
+	return result, nil
 }
 
-func (s *notaryService) getImageDigestHash(image string) ([]byte, error) {
-	if len(image) == 0 {
-		return []byte{}, errors.New("empty image provided")
+func (s *notaryService) notaryPhase(ctx context.Context, imgRepo, imgTag string) (signedHash, error) {
+	var signed signedHash
+	err := runPhase(ctx, NotaryPhase, s.settingsFor(imgRepo).notaryTimeout, func(ctx context.Context) (err error) {
+		signed, err = s.getNotaryImageDigestHash(ctx, imgRepo, imgTag)
+		return err
+	})
+	if err != nil {
+		return signedHash{}, err
 	}
+	return signed, nil
+}
 
-	ref, err := name.ParseReference(image)
+func (s *notaryService) registryPhase(ctx context.Context, image, algorithm string) (imageDigests, error) {
+	var digests imageDigests
+	err := runPhase(ctx, RegistryPhase, s.settingsFor(image).registryTimeout, func(ctx context.Context) (err error) {
+		digests, err = s.getImageDigestHash(ctx, image, algorithm)
+		return err
+	})
 	if err != nil {
-		return []byte{}, fmt.Errorf("ref parse: %w", err)
+		return imageDigests{}, err
+	}
+	return digests, nil
+}
+
+// allowedListEntry returns the first AllowedRegistries or AllowedRegistriesFile entry matching the reference.
+// IPv6 registry hosts match in any notation. The configured lists are recorded to rec, it may be nil.
+func (s *notaryService) allowedListEntry(ref imageRef, rec *ruleRecorder) (string, bool) {
+	for _, list := range []struct {
+		rule    Rule
+		entries []string
+		enabled bool
+	}{
+		{RuleAllowedRegistries, s.AllowedRegistries, len(s.AllowedRegistries) > 0},
+		{RuleAllowedRegistriesFile, s.allowedFile.entries(), s.allowedFile != nil},
+	} {
+		if !list.enabled {
+			continue
+		}
+		rule := RuleTrace{Rule: list.rule}
+		for _, allowed := range list.entries {
+			if parseAllowedEntry(allowed).matches(ref) {
+				rule.Entry, rule.Outcome = allowed, OutcomeAllowedList
+				rec.record(rule)
+				return allowed, true
+			}
+		}
+		rec.record(rule)
+	}
+	return "", false
+}
+
+// requiredDelegationFor returns the delegation role for the longest repository prefix matching imgRepo.
+func (s *notaryService) requiredDelegationFor(imgRepo string) (data.RoleName, bool) {
+	var role data.RoleName
+	longest := -1
+	for prefix, r := range s.DelegationRoles {
+		if strings.HasPrefix(imgRepo, prefix) && len(prefix) > longest {
+			role = r
+			longest = len(prefix)
+		}
+	}
+	return role, longest >= 0
+}
+
+// getImageDigestHash returns the hashes of the image computed with the notary algorithm.
+// References to indexes are resolved to the image for the platform of the validation.
+func (s *notaryService) getImageDigestHash(ctx context.Context, image, algorithm string) (_ imageDigests, err error) {
+	if len(image) == 0 {
+		return imageDigests{}, policyError{errors.New("empty image provided")}
 	}
-	i, err := remote.Image(ref)
+	platform, err := parsePlatform(s.platformFor(ctx))
 	if err != nil {
-		return []byte{}, fmt.Errorf("get image: %w", err)
+		return imageDigests{}, err
 	}
-	m, err := i.Manifest()
+	ref, err := s.parseRegistryReference(image)
 	if err != nil {
-		return []byte{}, fmt.Errorf("image manifest: %w", err)
+		return imageDigests{}, fmt.Errorf("ref parse: %w", err)
 	}
+	key := digestCacheKey{ref: ref.Name(), platform: platform.String(), algorithm: algorithm}
+	if digests, ok := s.digestCache.get(key); ok {
+		return digests, nil
+	}
+	generation := s.digestCache.currentGeneration()
 
-	bytes, err := hex.DecodeString(m.Config.Digest.Hex)
+	if err := s.registryLimiter.Acquire(ctx); err != nil {
+		return imageDigests{}, err
+	}
+	defer s.registryLimiter.Release()
 
+	host := ref.Context().RegistryStr()
+	defer func(start time.Time) {
+		s.metrics.observeRegistry(host, start, err)
+	}(time.Now())
+	desc, host, err := s.getDescriptor(ctx, ref, platform)
 	if err != nil {
-		return []byte{}, fmt.Errorf("checksum error: %w", err)
+		return imageDigests{}, registryError(err)
+	}
+
+	digests, err := s.descriptorDigests(image, desc, algorithm)
+	if err != nil {
+		return imageDigests{}, err
+	}
+	s.digestCache.add(generation, key, digests, desc.MediaType)
+	return digests, nil
+}
+
+// newTargetReader returns the reader of imgRepo targets in the notary at notaryURL.
+func (s *notaryService) newTargetReader(ctx context.Context, imgRepo, notaryURL string) (targetReader, error) {
+	if s.OfflineTrustBundle != nil {
+		return s.OfflineTrustBundle.reader(imgRepo)
 	}
 
-	return bytes, nil
+	if err := s.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	// only notary uses the GUN, the offline trust bundle keeps targets under image repositories
+	config := s.NotaryConfig
+	config.Url = notaryURL
+	config.timings = requestTimingsFrom(ctx)
+	return s.RepoFactory.NewRepoClient(ctx, config.harborGUN(s.GUNMapping.gun(imgRepo)), config)
 }
 
-func (s *notaryService) getNotaryImageDigestHash(ctx context.Context, imgRepo, imgTag string) ([]byte, error) {
+func (s *notaryService) notaryHostLabel(notaryURL string) string {
+	if s.OfflineTrustBundle != nil {
+		return offlineNotaryLabel
+	}
+	u, err := url.Parse(notaryURL)
+	if err != nil || u.Host == "" {
+		return otherHostLabel
+	}
+	return u.Host
+}
+
+// verifyPinnedDigest checks if the digest pinned in the tag@digest reference is the one signed for the tag.
+func verifyPinnedDigest(ref imageRef, signed signedHash) error {
+	signedDigest, ok := signed.hashes[ref.digestAlgorithm]
+	if !ok {
+		return policyError{fmt.Errorf("no %s hash signed for tag %s", ref.digestAlgorithm, ref.tag)}
+	}
+	if subtle.ConstantTimeCompare(ref.digest, signedDigest) == 0 {
+		return DigestMismatchError{
+			Tag:          ref.tag,
+			PinnedDigest: ref.pinnedDigest(),
+		}
+	}
+	return nil
+}
+
+// DigestMismatchError is returned when the digest pinned in the image reference differs from the one signed for its tag.
+type DigestMismatchError struct {
+	Tag          string
+	PinnedDigest string
+}
+
+func (e DigestMismatchError) Error() string {
+	return fmt.Sprintf("pinned digest %s doesn't match the digest signed for tag %s", e.PinnedDigest, e.Tag)
+}
+
+func (e DigestMismatchError) Class() ErrorClassification {
+	return ClassPolicy
+}
+
+func (e DigestMismatchError) Is(target error) bool {
+	return target == ErrDigestMismatch
+}
+
+// verifiedDigest returns the image reference pinned to the signed digest in the name.Digest format.
+func verifiedDigest(imgRepo string, signed signedHash) string {
+	return fmt.Sprintf("%s@%s:%s", imgRepo, signed.algorithm, hex.EncodeToString(signed.hash))
+}
+
+// signedHash is the image hash signed in notary.
+type signedHash struct {
+	algorithm string
+	hash      []byte
+	role      data.RoleName
+	// hashes are all hashes of the target, also the ones with not preferred algorithms.
+	hashes map[string][]byte
+	// keyIDs are the keys which signed the role metadata.
+	keyIDs []string
+	// endpoint is the notary URL which answered, it's empty for the offline trust bundle.
+	endpoint string
+	// expired is the expired metadata accepted within NotaryConfig.ExpiredMetadataGrace.
+	expired []TrustMetadataExpiredError
+}
+
+// warnings describe the expired metadata accepted for the signed hash.
+func (h signedHash) warnings() []string {
+	var warnings []string
+	for _, e := range h.expired {
+		warnings = append(warnings, e.Error()+", accepted within the grace period")
+	}
+	return warnings
+}
+
+func (s *notaryService) getNotaryImageDigestHash(ctx context.Context, imgRepo, imgTag string) (signedHash, error) {
 	if len(imgRepo) == 0 || len(imgTag) == 0 {
-		return []byte{}, errors.New("empty arguments provided")
+		return signedHash{}, policyError{errors.New("empty arguments provided")}
 	}
+	return s.lookupWithFailover(ctx, imgRepo, func(c targetReader) (signedHash, error) {
+		return s.signedTarget(c, imgRepo, imgTag)
+	})
+}
 
-	c, err := s.RepoFactory.NewRepoClient(imgRepo, s.NotaryConfig)
-	if err != nil {
-		return []byte{}, err
+// targetRoles returns the roles which may sign targets of imgRepo.
+func (s *notaryService) targetRoles(imgRepo string) []data.RoleName {
+	if delegation, delegated := s.requiredDelegationFor(imgRepo); delegated {
+		return []data.RoleName{delegation}
 	}
+	return s.NotaryConfig.acceptedRoles()
+}
 
-	target, err := c.GetTargetByName(imgTag)
+// signedTarget returns the hash of the target name signed by a role accepted for imgRepo.
+func (s *notaryService) signedTarget(c targetReader, imgRepo, name string) (signedHash, error) {
+	roles := s.targetRoles(imgRepo)
+	target, err := c.GetTargetByName(name, roles...)
+	err = notaryError(err)
+	var noSuchTarget client.ErrNoSuchTarget
+	if errors.As(err, &noSuchTarget) {
+		return signedHash{}, NoTrustedTargetError{Tag: name, Roles: roles, Err: err}
+	}
 	if err != nil {
-		return []byte{}, err
+		return signedHash{}, err
 	}
 
-	if len(target.Hashes) == 0 {
-		return []byte{}, errors.New("image hash is missing")
+	delegation, delegated := s.requiredDelegationFor(imgRepo)
+	if delegated && target.Role != delegation {
+		return signedHash{}, UnacceptedRoleError{Role: target.Role}
 	}
-
-	if len(target.Hashes) > 1 {
-		return []byte{}, errors.New("more than one hash for image")
+	if !delegated && !s.NotaryConfig.isRoleAccepted(target.Role) {
+		return signedHash{}, UnacceptedRoleError{Role: target.Role}
 	}
 
-	key := ""
-	for i := range target.Hashes {
-		key = i
+	algorithm, hash, err := selectHash(target.Hashes)
+	if err != nil {
+		return signedHash{}, err
+	}
+	keyIDs, err := s.verifySignerKeys(c, imgRepo, name, target.Role)
+	if err != nil {
+		return signedHash{}, err
 	}
 
-	return target.Hashes[key], nil
+	return signedHash{
+		algorithm: algorithm,
+		hash:      hash,
+		role:      target.Role,
+		hashes:    target.Hashes,
+		keyIDs:    keyIDs,
+	}, nil
 }
@@ -1,6 +1,8 @@
 package validate
 
 import (
+	"encoding/hex"
+	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -45,8 +47,8 @@ func Test_Validate_InvalidImageName_ShouldReturnError(t *testing.T) {
 			expectedErrMsg: "empty arguments provided",
 		},
 		{
-			name:           "image name with more than one semicolon", //TODO: IMO it's proper image name, but now is not allowed
-			imageName:      "repo:port/image-name:tag",
+			name:           "registry port without tag",
+			imageName:      "repo:5000/image-name",
 			expectedErrMsg: "image name is not formatted correctly",
 		},
 	}
@@ -84,6 +86,144 @@ func Test_Validate_ImageWhichIsInNotaryButIsNotInRegistry_ShouldReturnError(t *t
 	require.ErrorContains(t, err, "MANIFEST_UNKNOWN: Failed to fetch")
 }
 
+func Test_Validate_ImageSignedByNotAcceptedRole_ShouldReturnError(t *testing.T) {
+	f := NewDefaultMockNotaryFunction().WithRole("targets/untrusted").Build()
+	s := NewDefaultMockNotaryService().WithFunc(f).Build()
+	err := s.Validate(context.TODO(), TrustedImageName)
+	require.Error(t, err)
+	require.EqualError(t, err, "image target signed by not accepted role: targets/untrusted")
+
+	var roleErr UnacceptedRoleError
+	require.ErrorAs(t, err, &roleErr)
+	require.Equal(t, data.RoleName("targets/untrusted"), roleErr.Role)
+}
+
+func Test_Validate_AcceptedRoles_ShouldBePassedToNotary(t *testing.T) {
+	tests := []struct {
+		name          string
+		acceptedRoles []data.RoleName
+		expectedRoles []data.RoleName
+	}{
+		{
+			name:          "default roles",
+			expectedRoles: []data.RoleName{NotaryReleasesRole, data.CanonicalTargetsRole},
+		},
+		{
+			name:          "configured roles",
+			acceptedRoles: []data.RoleName{"targets/team-a"},
+			expectedRoles: []data.RoleName{"targets/team-a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requestedRoles []data.RoleName
+			f := func(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+				requestedRoles = roles
+				return nil, errors.New("stop")
+			}
+			s := NewDefaultMockNotaryService().
+				WithConfig(NotaryConfig{AcceptedRoles: tt.acceptedRoles}).
+				WithFunc(f).
+				Build()
+			err := s.Validate(context.TODO(), TrustedImageName)
+			require.EqualError(t, err, "stop")
+			require.Equal(t, tt.expectedRoles, requestedRoles)
+		})
+	}
+}
+
+func Test_Validate_DelegationRoles(t *testing.T) {
+	teamAHash := []byte{1, 1, 1}
+	teamBHash := []byte{2, 2, 2}
+	targetsHash := []byte{3, 3, 3}
+	delegations := map[string]data.RoleName{
+		"eu.gcr.io/team-a":       "targets/team-a",
+		"eu.gcr.io/team-a/admin": "targets/team-a-admin",
+		"eu.gcr.io/team-b":       "targets/team-b",
+	}
+
+	tests := []struct {
+		name         string
+		repo         string
+		roleHashes   map[data.RoleName][]byte
+		expectedHash []byte
+		expectedErr  string
+	}{
+		{
+			name: "image signed by required delegation",
+			repo: "eu.gcr.io/team-a/image",
+			roleHashes: map[data.RoleName][]byte{
+				"targets/team-a":          teamAHash,
+				"targets/team-b":          teamBHash,
+				data.CanonicalTargetsRole: targetsHash,
+			},
+			expectedHash: teamAHash,
+		},
+		{
+			name: "longest prefix decides about delegation",
+			repo: "eu.gcr.io/team-a/admin/image",
+			roleHashes: map[data.RoleName][]byte{
+				"targets/team-a":       teamAHash,
+				"targets/team-a-admin": teamBHash,
+			},
+			expectedHash: teamBHash,
+		},
+		{
+			name: "image signed only by other team delegation",
+			repo: "eu.gcr.io/team-b/image",
+			roleHashes: map[data.RoleName][]byte{
+				"targets/team-a": teamAHash,
+			},
+			expectedErr: "No valid trust data for tag",
+		},
+		{
+			name: "image signed only by top-level targets role",
+			repo: "eu.gcr.io/team-a/image",
+			roleHashes: map[data.RoleName][]byte{
+				data.CanonicalTargetsRole: targetsHash,
+			},
+			expectedErr: "No valid trust data for tag",
+		},
+		{
+			name: "image without delegation uses accepted roles",
+			repo: "eu.gcr.io/other/image",
+			roleHashes: map[data.RoleName][]byte{
+				"targets/team-a":          teamAHash,
+				data.CanonicalTargetsRole: targetsHash,
+			},
+			expectedHash: targetsHash,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewDefaultMockNotaryFunction().WithRoleHashes(tt.roleHashes).Build()
+			s := NewDefaultMockNotaryService().WithFunc(f).WithDelegationRoles(delegations).Build()
+
+			signed, err := s.getNotaryImageDigestHash(context.TODO(), tt.repo, "tag")
+
+			if tt.expectedErr != "" {
+				require.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedHash, signed.hash)
+		})
+	}
+}
+
+func Test_Validate_DelegatedImageReturnedByOtherRole_ShouldReturnError(t *testing.T) {
+	f := NewDefaultMockNotaryFunction().WithRole(data.CanonicalTargetsRole).Build()
+	s := NewDefaultMockNotaryService().
+		WithFunc(f).
+		WithDelegationRoles(map[string]data.RoleName{"eu.gcr.io/kyma-project": "targets/team-a"}).
+		Build()
+
+	err := s.Validate(context.TODO(), TrustedImageName)
+
+	require.Error(t, err)
+	require.EqualError(t, err, "image target signed by not accepted role: targets")
+}
+
 func Test_Validate_WhenNotaryNotResponding_ShouldReturnError(t *testing.T) {
 	s := NewDefaultMockNotaryService().WithRepoFactory(MockNotaryRepoFactoryNoSuchHost{}).Build()
 	err := s.Validate(context.TODO(), TrustedImageName)
@@ -162,11 +302,12 @@ func Test_Validate_WhenNotaryRespondAfterLongTime_ShouldReturnError(t *testing.T
 			Url: testServer.URL,
 		},
 	}
-	f := NotaryRepoFactory{timeout}
-	validator := NewImageValidator(sc, f)
+	f := NewNotaryRepoFactory(timeout)
+	validator, err := NewImageValidator(sc, WithRepoFactory(f))
+	require.NoError(t, err)
 
 	//WHEN
-	err := validator.Validate(ctx, "europe-docker.pkg.dev/kyma-project/dev/bootstrap:PR-6200")
+	err = validator.Validate(ctx, "europe-docker.pkg.dev/kyma-project/dev/bootstrap:PR-6200")
 
 	//THEN
 	require.Error(t, err)
@@ -182,3 +323,254 @@ func Test_Validate_DEV(t *testing.T) {
 	require.Error(t, err)
 	require.EqualError(t, err, "something")
 }
+
+func Test_ValidateDetailed_ImageInAllowedList(t *testing.T) {
+	s := NewDefaultMockNotaryService().Build()
+	s.AllowedRegistries = []string{"eu.gcr.io/kyma-project"}
+
+	result, err := s.ValidateDetailed(context.TODO(), TrustedImageName)
+
+	require.NoError(t, err)
+	require.Equal(t, OutcomeAllowedList, result.Outcome)
+	require.Equal(t, TrustedImageName, result.Image)
+	require.Empty(t, result.ResolvedDigest)
+}
+
+func Test_ValidateDetailed_ImageRejectedByNotary(t *testing.T) {
+	f := NewDefaultMockNotaryFunction().WithRole("targets/untrusted").Build()
+	s := NewDefaultMockNotaryService().WithFunc(f).Build()
+
+	result, err := s.ValidateDetailed(context.TODO(), TrustedImageName)
+
+	require.Error(t, err)
+	require.Equal(t, OutcomeDenied, result.Outcome)
+	require.Empty(t, result.NotaryRole)
+	require.Positive(t, result.Durations.Notary)
+}
+
+func Test_getNotaryImageDigestHash_ReturnsSigningRole(t *testing.T) {
+	f := NewDefaultMockNotaryFunction().WithRole(NotaryReleasesRole).Build()
+	s := NewDefaultMockNotaryService().WithFunc(f).Build()
+
+	signed, err := s.getNotaryImageDigestHash(context.TODO(), "eu.gcr.io/kyma-project/image", "tag")
+
+	require.NoError(t, err)
+	require.Equal(t, NotaryReleasesRole, signed.role)
+	require.Equal(t, SHA256Algorithm, signed.algorithm)
+}
+
+func Test_ValidateDetailed_ReturnsVerifiedDigest(t *testing.T) {
+	image := "eu.gcr.io/kyma-project/function-controller:verified"
+	registryTransport, img := pushTestImageAs(t, image)
+	notaryHash := configHash(t, img)
+
+	s := NewDefaultMockNotaryService().WithHash(notaryHash).WithRegistryTransport(registryTransport).Build()
+
+	result, err := s.ValidateDetailed(context.TODO(), image)
+
+	require.NoError(t, err)
+	require.Equal(t, OutcomeSignatureVerified, result.Outcome)
+	require.Equal(t, "eu.gcr.io/kyma-project/function-controller@sha256:"+hex.EncodeToString(notaryHash), result.ResolvedDigest)
+	digest, err := name.NewDigest(result.ResolvedDigest)
+	require.NoError(t, err)
+	require.Equal(t, "eu.gcr.io/kyma-project/function-controller", digest.Context().Name())
+}
+
+func Test_ValidateDetailed_TagWithDigestReference(t *testing.T) {
+	image := "eu.gcr.io/kyma-project/function-controller:pinned"
+	registryTransport, img := pushTestImageAs(t, image)
+	notaryHash := configHash(t, img)
+	otherHash := make([]byte, len(notaryHash))
+	copy(otherHash, notaryHash)
+	otherHash[0]++
+
+	t.Run("pinned digest agrees with the signed tag", func(t *testing.T) {
+		s := NewDefaultMockNotaryService().WithHash(notaryHash).WithRegistryTransport(registryTransport).Build()
+
+		result, err := s.ValidateDetailed(context.TODO(), image+"@sha256:"+hex.EncodeToString(notaryHash))
+
+		require.NoError(t, err)
+		require.Equal(t, OutcomeSignatureVerified, result.Outcome)
+	})
+
+	t.Run("pinned digest disagrees with the signed tag", func(t *testing.T) {
+		s := NewDefaultMockNotaryService().WithHash(notaryHash).WithRegistryTransport(registryTransport).Build()
+
+		_, err := s.ValidateDetailed(context.TODO(), image+"@sha256:"+hex.EncodeToString(otherHash))
+
+		var mismatchErr DigestMismatchError
+		require.ErrorAs(t, err, &mismatchErr)
+		require.Equal(t, "pinned", mismatchErr.Tag)
+		require.Equal(t, "sha256:"+hex.EncodeToString(otherHash), mismatchErr.PinnedDigest)
+	})
+
+	t.Run("pinned digest for tag unknown to notary", func(t *testing.T) {
+		f := func(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+			return nil, client.ErrNoSuchTarget(name)
+		}
+		s := NewDefaultMockNotaryService().WithFunc(f).WithRegistryTransport(registryTransport).Build()
+
+		_, err := s.ValidateDetailed(context.TODO(), image+"@sha256:"+hex.EncodeToString(notaryHash))
+
+		require.EqualError(t, err, "tag pinned is not signed by any of roles [targets/releases targets]: No valid trust data for pinned")
+	})
+
+	t.Run("pinned digest algorithm is not signed", func(t *testing.T) {
+		s := NewDefaultMockNotaryService().WithHash(notaryHash).WithRegistryTransport(registryTransport).Build()
+
+		_, err := s.ValidateDetailed(context.TODO(), image+"@sha512:"+hex.EncodeToString(notaryHash))
+
+		require.EqualError(t, err, "no sha512 hash signed for tag pinned")
+	})
+}
+
+func Test_ValidateDetailed_RolePreference(t *testing.T) {
+	releasesHash := []byte{1, 1, 1}
+	targetsHash := []byte{2, 2, 2}
+
+	tests := []struct {
+		name          string
+		acceptedRoles []data.RoleName
+		roleHashes    map[data.RoleName][]byte
+		expectedRole  data.RoleName
+		expectedHash  []byte
+	}{
+		{
+			name:         "releases role is preferred by default",
+			roleHashes:   map[data.RoleName][]byte{data.CanonicalTargetsRole: targetsHash, NotaryReleasesRole: releasesHash},
+			expectedRole: NotaryReleasesRole,
+			expectedHash: releasesHash,
+		},
+		{
+			name:         "targets role is used when releases role didn't sign the tag",
+			roleHashes:   map[data.RoleName][]byte{data.CanonicalTargetsRole: targetsHash},
+			expectedRole: data.CanonicalTargetsRole,
+			expectedHash: targetsHash,
+		},
+		{
+			name:          "configured order",
+			acceptedRoles: []data.RoleName{data.CanonicalTargetsRole, NotaryReleasesRole},
+			roleHashes:    map[data.RoleName][]byte{data.CanonicalTargetsRole: targetsHash, NotaryReleasesRole: releasesHash},
+			expectedRole:  data.CanonicalTargetsRole,
+			expectedHash:  targetsHash,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewDefaultMockNotaryFunction().WithRoleHashes(tt.roleHashes).Build()
+			s := NewDefaultMockNotaryService().WithConfig(NotaryConfig{AcceptedRoles: tt.acceptedRoles}).WithFunc(f).Build()
+
+			signed, err := s.getNotaryImageDigestHash(context.TODO(), "eu.gcr.io/kyma-project/image", "tag")
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedRole, signed.role)
+			require.Equal(t, tt.expectedHash, signed.hash)
+		})
+	}
+}
+
+func Test_Validate_NoTrustedTarget(t *testing.T) {
+	t.Run("tag not signed by any preferred role", func(t *testing.T) {
+		f := NewDefaultMockNotaryFunction().WithRoleHashes(map[data.RoleName][]byte{"targets/dev": {1}}).Build()
+		s := NewDefaultMockNotaryService().WithFunc(f).Build()
+
+		result, err := s.ValidateDetailed(context.TODO(), TrustedImageName)
+
+		var noTargetErr NoTrustedTargetError
+		require.ErrorAs(t, err, &noTargetErr)
+		require.Equal(t, "PR-16481", noTargetErr.Tag)
+		require.Equal(t, DefaultAcceptedRoles, noTargetErr.Roles)
+		require.Equal(t, OutcomeDenied, result.Outcome)
+	})
+
+	t.Run("repository doesn't exist in notary", func(t *testing.T) {
+		f := func(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+			return nil, client.ErrRepositoryNotExist{}
+		}
+		s := NewDefaultMockNotaryService().WithFunc(f).Build()
+
+		_, err := s.ValidateDetailed(context.TODO(), TrustedImageName)
+
+		require.ErrorAs(t, err, new(client.ErrRepositoryNotExist))
+		require.False(t, errors.As(err, new(NoTrustedTargetError)))
+	})
+}
+
+// slowTransport delays requests unless they are cancelled in the meantime.
+type slowTransport struct {
+	next  http.RoundTripper
+	delay time.Duration
+}
+
+func (t slowTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	select {
+	case <-time.After(t.delay):
+		return t.next.RoundTrip(r)
+	case <-r.Context().Done():
+		return nil, r.Context().Err()
+	}
+}
+
+func Test_Validate_ParallelLookups(t *testing.T) {
+	//GIVEN
+	delay := 200 * time.Millisecond
+	image := "eu.gcr.io/kyma-project/image:tag"
+	transport, img := pushTestImageAs(t, image)
+	hash := configHash(t, img)
+	f := func(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+		time.Sleep(delay)
+		return &client.TargetWithRole{Target: client.Target{Name: name, Hashes: data.Hashes{SHA256Algorithm: hash}}, Role: NotaryReleasesRole}, nil
+	}
+	s := NewDefaultMockNotaryService().WithFunc(f).WithRegistryTransport(slowTransport{next: transport, delay: delay}).Build()
+	start := time.Now()
+
+	//WHEN
+	result, err := s.ValidateDetailed(context.TODO(), image)
+
+	//THEN
+	require.NoError(t, err)
+	require.Equal(t, OutcomeSignatureVerified, result.Outcome)
+	require.GreaterOrEqual(t, result.Durations.Notary, delay)
+	require.GreaterOrEqual(t, result.Durations.Registry, delay)
+	require.Less(t, time.Since(start), result.Durations.Notary+result.Durations.Registry)
+}
+
+func Test_Validate_ParallelLookupsErrorPrecedence(t *testing.T) {
+	delay := 100 * time.Millisecond
+	tests := []struct {
+		name          string
+		notaryDelay   time.Duration
+		registryDelay time.Duration
+	}{
+		{
+			name:        "registry fails first",
+			notaryDelay: delay,
+		},
+		{
+			name:          "notary fails first",
+			registryDelay: 10 * delay,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//GIVEN
+			f := func(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+				time.Sleep(tt.notaryDelay)
+				return nil, client.ErrNoSuchTarget(name)
+			}
+			s := NewDefaultMockNotaryService().
+				WithFunc(f).
+				WithRegistryTransport(slowTransport{next: unreachableTransport{}, delay: tt.registryDelay}).
+				Build()
+			start := time.Now()
+
+			//WHEN
+			err := s.Validate(context.TODO(), "eu.gcr.io/kyma-project/unsigned:tag")
+
+			//THEN
+			require.ErrorIs(t, err, ErrNoTrustData)
+			// the registry lookup is cancelled when notary denies the image
+			require.Less(t, time.Since(start), 5*delay)
+		})
+	}
+}
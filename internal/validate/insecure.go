@@ -0,0 +1,59 @@
+package validate
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// InsecureRegistryError is returned when a registry call would be sent over plain HTTP
+// to a registry which is not listed in ServiceConfig.InsecureRegistries.
+type InsecureRegistryError struct {
+	Registry string
+}
+
+func (e InsecureRegistryError) Error() string {
+	return fmt.Sprintf("plain HTTP is not allowed for registry %s", e.Registry)
+}
+
+func (e InsecureRegistryError) Class() ErrorClassification {
+	return ClassPolicy
+}
+
+func (s *notaryService) isRegistryInsecure(registry string) bool {
+	registry = normalizeRegistryHost(registry)
+	for _, insecure := range s.InsecureRegistries {
+		if normalizeRegistryHost(insecure) == registry {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRegistryReference parses the image reference, references to insecure registries may use plain HTTP.
+func (s *notaryService) parseRegistryReference(image string) (name.Reference, error) {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return nil, err
+	}
+	if !s.isRegistryInsecure(ref.Context().RegistryStr()) {
+		return ref, nil
+	}
+	return name.ParseReference(image, name.Insecure)
+}
+
+// httpsOnlyTransport rejects plain HTTP requests to registries which are not insecure.
+// go-containerregistry falls back to HTTP for e.g. private IPs and .local hosts on its own,
+// so parsing references without name.Insecure is not enough to prevent the downgrade.
+type httpsOnlyTransport struct {
+	next       http.RoundTripper
+	isInsecure func(registry string) bool
+}
+
+func (t httpsOnlyTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.URL.Scheme != "https" && !t.isInsecure(r.URL.Host) {
+		return nil, InsecureRegistryError{Registry: r.URL.Host}
+	}
+	return t.next.RoundTrip(r)
+}
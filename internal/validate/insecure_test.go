@@ -0,0 +1,96 @@
+package validate
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/require"
+)
+
+// pushTestImageOverHTTP pushes a random image under the given name to a plain HTTP registry stub
+// and returns the transport which dials the stub for every host, without changing the scheme.
+func pushTestImageOverHTTP(t *testing.T, image string) (http.RoundTripper, []byte, *int32) {
+	var requests int32
+	handler := registry.New()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, srv.Listener.Addr().String())
+		},
+	}
+
+	img, err := random.Image(128, 1)
+	require.NoError(t, err)
+	ref, err := name.ParseReference(image, name.Insecure)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img, remote.WithTransport(transport)))
+
+	atomic.StoreInt32(&requests, 0)
+	return transport, configHash(t, img), &requests
+}
+
+func Test_Validate_ImageFromInsecureRegistry(t *testing.T) {
+	t.Run("listed registry is reached over plain HTTP", func(t *testing.T) {
+		//GIVEN
+		image := "registry.example.com/kyma-project/image:tag"
+		transport, hash, _ := pushTestImageOverHTTP(t, image)
+		s := NewDefaultMockNotaryService().
+			WithHash(hash).
+			WithRegistryTransport(transport).
+			WithInsecureRegistries("registry.example.com").
+			Build()
+
+		//WHEN
+		err := s.Validate(context.TODO(), image)
+
+		//THEN
+		require.NoError(t, err)
+	})
+
+	t.Run("not listed registry is never downgraded", func(t *testing.T) {
+		//GIVEN
+		image := "registry.example.com/kyma-project/image:tag"
+		transport, hash, requests := pushTestImageOverHTTP(t, image)
+		s := NewDefaultMockNotaryService().
+			WithHash(hash).
+			WithRegistryTransport(transport).
+			WithInsecureRegistries("other-registry.example.com").
+			Build()
+
+		//WHEN
+		err := s.Validate(context.TODO(), image)
+
+		//THEN
+		require.ErrorContains(t, err, "get image")
+		require.Zero(t, atomic.LoadInt32(requests), "no plain HTTP request should reach the registry")
+	})
+
+	t.Run("not listed local registry is not downgraded by the registry client", func(t *testing.T) {
+		//GIVEN
+		image := "registry.kyma-system.svc.cluster.local/kyma-project/image:tag"
+		transport, hash, requests := pushTestImageOverHTTP(t, image)
+		s := NewDefaultMockNotaryService().
+			WithHash(hash).
+			WithRegistryTransport(transport).
+			Build()
+
+		//WHEN
+		err := s.Validate(context.TODO(), image)
+
+		//THEN
+		require.ErrorContains(t, err, "plain HTTP is not allowed for registry registry.kyma-system.svc.cluster.local")
+		require.Zero(t, atomic.LoadInt32(requests), "no plain HTTP request should reach the registry")
+	})
+}
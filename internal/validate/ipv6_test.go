@@ -0,0 +1,131 @@
+package validate
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_allowedListEntry_IPv6(t *testing.T) {
+	tests := []struct {
+		name     string
+		image    string
+		allowed  string
+		expected bool
+	}{
+		{
+			name:     "same notation with port",
+			image:    "[2001:db8::1]:5000/app:v1",
+			allowed:  "[2001:db8::1]:5000",
+			expected: true,
+		},
+		{
+			name:     "other notation with port",
+			image:    "[2001:db8::1]:5000/app:v1",
+			allowed:  "[2001:0db8:0:0::1]:5000/app",
+			expected: true,
+		},
+		{
+			name:     "without port",
+			image:    "[2001:db8::1]/kyma-project/app:v1",
+			allowed:  "2001:db8::1/kyma-project",
+			expected: true,
+		},
+		{
+			name:     "with trailing slash",
+			image:    "[2001:db8::1]:5000/app:v1",
+			allowed:  "[2001:db8::1]:5000/",
+			expected: true,
+		},
+		{
+			name:     "other port",
+			image:    "[2001:db8::1]:5001/app:v1",
+			allowed:  "[2001:db8::1]:5000",
+			expected: false,
+		},
+		{
+			name:     "other host",
+			image:    "[2001:db8::2]:5000/app:v1",
+			allowed:  "[2001:db8::1]:5000",
+			expected: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//GIVEN
+			s := NewDefaultMockNotaryService().Build()
+			s.AllowedRegistries = []string{tt.allowed}
+			ref, err := parseImageRef(tt.image)
+			require.NoError(t, err)
+
+			//WHEN
+			entry, ok := s.allowedListEntry(ref, nil)
+
+			//THEN
+			require.Equal(t, tt.expected, ok)
+			if tt.expected {
+				require.Equal(t, tt.allowed, entry)
+			}
+		})
+	}
+}
+
+func Test_Validate_IPv6Registry(t *testing.T) {
+	listener, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback isn't available: %s", err)
+	}
+	var mu sync.Mutex
+	var hosts, serverNames []string
+	handler := registry.New()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hosts = append(hosts, r.Host)
+		serverNames = append(serverNames, r.TLS.ServerName)
+		mu.Unlock()
+		handler.ServeHTTP(w, r)
+	}))
+	srv.Listener = listener
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	// the test certificate is valid for the ::1 address
+	host := strings.TrimPrefix(srv.URL, "https://")
+	image := host + "/kyma-project/app:v1"
+	img, err := random.Image(128, 1)
+	require.NoError(t, err)
+	ref, err := name.ParseReference(image)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img, remote.WithTransport(srv.Client().Transport)))
+	mu.Lock()
+	hosts, serverNames = nil, nil
+	mu.Unlock()
+
+	s := NewDefaultMockNotaryService().WithHash(configHash(t, img)).WithRegistryTransport(srv.Client().Transport).Build()
+
+	//WHEN
+	result, err := s.ValidateDetailed(context.TODO(), image)
+
+	//THEN
+	require.NoError(t, err)
+	require.Equal(t, OutcomeSignatureVerified, result.Outcome)
+	require.True(t, strings.HasPrefix(result.ResolvedDigest, host+"/kyma-project/app@sha256:"), result.ResolvedDigest)
+	mu.Lock()
+	defer mu.Unlock()
+	require.NotEmpty(t, hosts)
+	for i := range hosts {
+		require.Equal(t, host, hosts[i])
+		// SNI isn't sent for IP addresses
+		require.Empty(t, serverNames[i])
+	}
+}
@@ -0,0 +1,93 @@
+package validate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// RegistryKeychain authenticates registry calls to Hosts with Keychain,
+// e.g. cloud keychains which exchange the workload identity for short-lived registry tokens.
+type RegistryKeychain struct {
+	// Hosts are registry hosts, the "*." prefix matches all subdomains.
+	Hosts    []string
+	Keychain authn.Keychain
+}
+
+func (k RegistryKeychain) matches(registry string) bool {
+	for _, host := range k.Hosts {
+		if strings.HasPrefix(host, "*.") {
+			if strings.HasSuffix(registry, host[1:]) {
+				return true
+			}
+			continue
+		}
+		if registry == host {
+			return true
+		}
+	}
+	return false
+}
+
+// RegistryAuthError is returned when credentials for the registry couldn't be obtained.
+type RegistryAuthError struct {
+	Registry string
+	Err      error
+}
+
+func (e RegistryAuthError) Error() string {
+	return fmt.Sprintf("registry %s authentication failed: %s", e.Registry, e.Err)
+}
+
+func (e RegistryAuthError) Class() ErrorClassification {
+	return ClassInfrastructure
+}
+
+func (e RegistryAuthError) Unwrap() error {
+	return e.Err
+}
+
+// keychainFor returns the first keychain configured for the registry.
+func (s *notaryService) keychainFor(registry string) (authn.Keychain, bool) {
+	for _, k := range s.RegistryKeychains {
+		if k.matches(registry) {
+			return k.Keychain, true
+		}
+	}
+	return nil, false
+}
+
+// registryAuthOptions resolves credentials for the image registry up front,
+// so failures to obtain a token are not reported as failures to get the image.
+func (s *notaryService) registryAuthOptions(ref name.Reference) ([]remote.Option, error) {
+	registry := ref.Context().RegistryStr()
+	keychain, ok := s.keychainFor(registry)
+	if !ok {
+		return s.dockerConfigAuthOptions(registry)
+	}
+	authenticator, err := keychain.Resolve(ref.Context())
+	if err != nil {
+		return nil, RegistryAuthError{Registry: registry, Err: err}
+	}
+	cfg, err := authenticator.Authorization()
+	if err != nil {
+		return nil, RegistryAuthError{Registry: registry, Err: err}
+	}
+	return []remote.Option{remote.WithAuth(authn.FromConfig(*cfg))}, nil
+}
+
+// dockerConfigAuthOptions uses the credentials from ServiceConfig.DockerConfigPath,
+// registries without an entry in the config are called anonymously.
+func (s *notaryService) dockerConfigAuthOptions(registry string) ([]remote.Option, error) {
+	cfg, ok, err := s.dockerConfig.authFor(registry)
+	if err != nil {
+		return nil, RegistryAuthError{Registry: registry, Err: err}
+	}
+	if !ok {
+		return nil, nil
+	}
+	return []remote.Option{remote.WithAuth(authn.FromConfig(cfg))}, nil
+}
@@ -0,0 +1,79 @@
+package validate
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeKeychain records the registries it was asked for and returns the configured result.
+type fakeKeychain struct {
+	resolved []string
+	err      error
+}
+
+func (k *fakeKeychain) Resolve(r authn.Resource) (authn.Authenticator, error) {
+	k.resolved = append(k.resolved, r.RegistryStr())
+	if k.err != nil {
+		return nil, k.err
+	}
+	return &authn.Basic{Username: "oauth2accesstoken", Password: "short-lived-token"}, nil
+}
+
+func Test_RegistryKeychain_matches(t *testing.T) {
+	k := RegistryKeychain{Hosts: []string{"gcr.io", "*.pkg.dev"}}
+
+	require.True(t, k.matches("gcr.io"))
+	require.True(t, k.matches("europe-docker.pkg.dev"))
+	require.False(t, k.matches("eu.gcr.io"))
+	require.False(t, k.matches("pkg.dev"))
+	require.False(t, k.matches("pkg.dev.attacker.com"))
+}
+
+func Test_Validate_ImageFromKeychainRegistry_ShouldUseKeychain(t *testing.T) {
+	//GIVEN
+	image := "europe-docker.pkg.dev/kyma-project/image:tag"
+	registryTransport, img := pushTestImageAs(t, image)
+	gcp := &fakeKeychain{}
+	ecr := &fakeKeychain{}
+	s := NewDefaultMockNotaryService().
+		WithHash(configHash(t, img)).
+		WithRegistryTransport(registryTransport).
+		WithRegistryKeychains(
+			RegistryKeychain{Hosts: []string{"*.amazonaws.com"}, Keychain: ecr},
+			RegistryKeychain{Hosts: []string{"gcr.io", "*.gcr.io", "*.pkg.dev"}, Keychain: gcp},
+		).Build()
+
+	//WHEN
+	err := s.Validate(context.TODO(), image)
+
+	//THEN
+	require.NoError(t, err)
+	require.Equal(t, []string{"europe-docker.pkg.dev"}, gcp.resolved)
+	require.Empty(t, ecr.resolved)
+}
+
+func Test_Validate_KeychainFailsToObtainToken_ShouldReturnRegistryAuthError(t *testing.T) {
+	//GIVEN
+	image := "europe-docker.pkg.dev/kyma-project/image:tag"
+	registryTransport, img := pushTestImageAs(t, image)
+	tokenErr := errors.New("metadata server is not available")
+	s := NewDefaultMockNotaryService().
+		WithHash(configHash(t, img)).
+		WithRegistryTransport(registryTransport).
+		WithRegistryKeychains(RegistryKeychain{Hosts: []string{"*.pkg.dev"}, Keychain: &fakeKeychain{err: tokenErr}}).
+		Build()
+
+	//WHEN
+	err := s.Validate(context.TODO(), image)
+
+	//THEN
+	var authErr RegistryAuthError
+	require.ErrorAs(t, err, &authErr)
+	require.Equal(t, "europe-docker.pkg.dev", authErr.Registry)
+	require.ErrorIs(t, err, tokenErr)
+	require.NotContains(t, err.Error(), "get image")
+}
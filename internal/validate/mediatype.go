@@ -0,0 +1,110 @@
+package validate
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// UnsupportedMediaTypeError is returned when the reference points to an artifact which is not a container image,
+// e.g. a Helm chart pushed to the registry as an OCI artifact.
+type UnsupportedMediaTypeError struct {
+	MediaType types.MediaType
+}
+
+func (e UnsupportedMediaTypeError) Error() string {
+	return fmt.Sprintf("unsupported image media type: %s", e.MediaType)
+}
+
+func (e UnsupportedMediaTypeError) Class() ErrorClassification {
+	return ClassPolicy
+}
+
+// imageFromDescriptor accepts Docker and OCI manifests and indexes, the image for the default platform is taken from indexes.
+func imageFromDescriptor(desc *remote.Descriptor) (v1.Image, error) {
+	switch desc.MediaType {
+	case types.DockerManifestSchema2, types.OCIManifestSchema1, types.DockerManifestList, types.OCIImageIndex:
+		i, err := desc.Image()
+		if err != nil {
+			return nil, fmt.Errorf("get image: %w", err)
+		}
+		return i, nil
+	}
+	return nil, UnsupportedMediaTypeError{MediaType: desc.MediaType}
+}
+
+// checkImageConfig rejects OCI artifacts which use the image manifest with a non-image config.
+func checkImageConfig(m *v1.Manifest) error {
+	switch m.Config.MediaType {
+	case types.DockerConfigJSON, types.OCIConfigJSON:
+		return nil
+	}
+	return UnsupportedMediaTypeError{MediaType: m.Config.MediaType}
+}
+
+// descriptorDigests returns the hashes of the manifest the descriptor was fetched for.
+func (s *notaryService) descriptorDigests(image string, desc *remote.Descriptor, algorithm string) (imageDigests, error) {
+	if isSchema1(desc.MediaType) {
+		hash, err := s.schema1Hash(image, desc, algorithm)
+		return imageDigests{config: hash}, err
+	}
+
+	digests := imageDigests{}
+	if desc.MediaType.IsIndex() {
+		hash, err := manifestHash(desc, algorithm)
+		if err != nil {
+			return imageDigests{}, err
+		}
+		digests.index = hash
+	}
+	config, err := imageConfigHash(desc, algorithm)
+	if err != nil && digests.index != nil {
+		// notary may have signed the index, so the missing platform image is reported only on mismatch
+		digests.platformErr = err
+		return digests, nil
+	}
+	if err != nil {
+		return imageDigests{}, err
+	}
+	digests.config = config
+	return digests, nil
+}
+
+// imageConfigHash returns the config hash of the image for the platform the descriptor was fetched for.
+func imageConfigHash(desc *remote.Descriptor, algorithm string) ([]byte, error) {
+	i, err := imageFromDescriptor(desc)
+	if err != nil {
+		return []byte{}, err
+	}
+	m, err := i.Manifest()
+	if err != nil {
+		return []byte{}, fmt.Errorf("image manifest: %w", err)
+	}
+	if err := checkImageConfig(m); err != nil {
+		return []byte{}, err
+	}
+
+	if m.Config.Digest.Algorithm == algorithm {
+		bytes, err := hex.DecodeString(m.Config.Digest.Hex)
+		if err != nil {
+			return []byte{}, fmt.Errorf("checksum error: %w", err)
+		}
+		return bytes, nil
+	}
+
+	// registry digest uses a different algorithm, so we have to compute it on our own
+	h, err := newHash(algorithm)
+	if err != nil {
+		return []byte{}, err
+	}
+	config, err := i.RawConfigFile()
+	if err != nil {
+		return []byte{}, fmt.Errorf("image config: %w", err)
+	}
+	h.Write(config)
+
+	return h.Sum(nil), nil
+}
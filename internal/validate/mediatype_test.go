@@ -0,0 +1,119 @@
+package validate
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	helmChartConfigMediaType types.MediaType = "application/vnd.cncf.helm.config.v1+json"
+	orasArtifactMediaType    types.MediaType = "application/vnd.oci.artifact.manifest.v1+json"
+)
+
+// rawManifest is pushed as is, so artifacts unknown to go-containerregistry can be stored in the test registry.
+type rawManifest struct {
+	mediaType types.MediaType
+	manifest  string
+}
+
+func (m rawManifest) RawManifest() ([]byte, error) {
+	return []byte(m.manifest), nil
+}
+
+func (m rawManifest) MediaType() (types.MediaType, error) {
+	return m.mediaType, nil
+}
+
+func newOCIImage(t *testing.T, configMediaType types.MediaType) v1.Image {
+	img, err := random.Image(128, 1)
+	require.NoError(t, err)
+	img = mutate.MediaType(img, types.OCIManifestSchema1)
+	return mutate.ConfigMediaType(img, configMediaType)
+}
+
+func Test_getImageDigestHash_MediaTypes(t *testing.T) {
+	srv := httptest.NewServer(registry.New())
+	defer srv.Close()
+	transport := redirectTransport{host: strings.TrimPrefix(srv.URL, "http://")}
+	push := func(t *testing.T, image string, write func(name.Reference, remote.Option) error) {
+		ref, err := name.ParseReference(image)
+		require.NoError(t, err)
+		require.NoError(t, write(ref, remote.WithTransport(transport)))
+	}
+	s := NewDefaultMockNotaryService().WithRegistryTransport(transport).Build()
+
+	t.Run("OCI manifest", func(t *testing.T) {
+		image := "eu.gcr.io/kyma-project/image:oci-manifest"
+		img := newOCIImage(t, types.OCIConfigJSON)
+		push(t, image, func(ref name.Reference, o remote.Option) error {
+			return remote.Write(ref, img, o)
+		})
+
+		hash, err := s.getImageDigestHash(context.TODO(), image, SHA256Algorithm)
+
+		require.NoError(t, err)
+		require.Equal(t, configHash(t, img), hash.config)
+	})
+
+	t.Run("OCI index", func(t *testing.T) {
+		image := "eu.gcr.io/kyma-project/image:oci-index"
+		amd64 := newOCIImage(t, types.OCIConfigJSON)
+		arm64 := newOCIImage(t, types.OCIConfigJSON)
+		idx := mutate.IndexMediaType(mutate.AppendManifests(empty.Index,
+			mutate.IndexAddendum{Add: arm64, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "arm64"}}},
+			mutate.IndexAddendum{Add: amd64, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}}},
+		), types.OCIImageIndex)
+		push(t, image, func(ref name.Reference, o remote.Option) error {
+			return remote.WriteIndex(ref, idx, o)
+		})
+
+		hash, err := s.getImageDigestHash(context.TODO(), image, SHA256Algorithm)
+
+		require.NoError(t, err)
+		require.Equal(t, configHash(t, amd64), hash.config)
+	})
+
+	t.Run("Helm chart pushed as OCI artifact", func(t *testing.T) {
+		image := "eu.gcr.io/kyma-project/chart:helm"
+		chart := newOCIImage(t, helmChartConfigMediaType)
+		push(t, image, func(ref name.Reference, o remote.Option) error {
+			return remote.Write(ref, chart, o)
+		})
+
+		_, err := s.getImageDigestHash(context.TODO(), image, SHA256Algorithm)
+
+		var mediaTypeErr UnsupportedMediaTypeError
+		require.ErrorAs(t, err, &mediaTypeErr)
+		require.Equal(t, helmChartConfigMediaType, mediaTypeErr.MediaType)
+		require.EqualError(t, err, "unsupported image media type: application/vnd.cncf.helm.config.v1+json")
+	})
+
+	t.Run("ORAS artifact manifest", func(t *testing.T) {
+		image := "eu.gcr.io/kyma-project/sbom:oras"
+		artifact := rawManifest{
+			mediaType: orasArtifactMediaType,
+			manifest:  `{"mediaType":"application/vnd.oci.artifact.manifest.v1+json","artifactType":"application/spdx+json","blobs":[]}`,
+		}
+		push(t, image, func(ref name.Reference, o remote.Option) error {
+			return remote.Put(ref, artifact, o)
+		})
+
+		_, err := s.getImageDigestHash(context.TODO(), image, SHA256Algorithm)
+
+		var mediaTypeErr UnsupportedMediaTypeError
+		require.ErrorAs(t, err, &mediaTypeErr)
+		require.Equal(t, orasArtifactMediaType, mediaTypeErr.MediaType)
+	})
+}
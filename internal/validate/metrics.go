@@ -0,0 +1,289 @@
+package validate
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/theupdateframework/notary/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// maxRegistryHostLabels bounds the registry host label, hosts above the limit are reported as otherHostLabel.
+	maxRegistryHostLabels = 50
+	otherHostLabel        = "other"
+	offlineNotaryLabel    = "offline-trust-bundle"
+)
+
+// phaseMetrics are recorded separately for notary and registry requests, so the slow side is visible.
+type phaseMetrics struct {
+	notaryDuration   *prometheus.HistogramVec
+	notaryErrors     *prometheus.CounterVec
+	registryDuration *prometheus.HistogramVec
+	registryErrors   *prometheus.CounterVec
+	registryHosts    *hostLabels
+	backendResults   *prometheus.CounterVec
+	hookDrops        prometheus.Counter
+	hookPanics       prometheus.Counter
+
+	notaryRateLimiterWait  prometheus.Histogram
+	registryInflightCalls  prometheus.Gauge
+	registryRateLimitHits  *prometheus.CounterVec
+	expiredExceptionHits   prometheus.Counter
+	negativeCacheHits      prometheus.Counter
+	negativeCacheMisses    prometheus.Counter
+	negativeCacheEvictions prometheus.Counter
+}
+
+// newPhaseMetrics registers phase metrics with reg, metrics.Registry is used when reg is nil.
+// Metrics already registered by another validator are reused.
+func newPhaseMetrics(reg prometheus.Registerer) *phaseMetrics {
+	if reg == nil {
+		reg = metrics.Registry
+	}
+	return &phaseMetrics{
+		notaryDuration: registerOrExisting(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "warden_notary_request_duration_seconds",
+			Help:    "Duration of notary lookups of signed image targets.",
+			Buckets: prometheus.ExponentialBuckets(0.005, 2, 12),
+		}, []string{"host"})).(*prometheus.HistogramVec),
+		notaryErrors: registerOrExisting(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "warden_notary_request_errors_total",
+			Help: "Number of failed notary lookups by error class.",
+		}, []string{"host", "class"})).(*prometheus.CounterVec),
+		registryDuration: registerOrExisting(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "warden_registry_request_duration_seconds",
+			Help:    "Duration of registry lookups of image digests.",
+			Buckets: prometheus.ExponentialBuckets(0.005, 2, 12),
+		}, []string{"host"})).(*prometheus.HistogramVec),
+		registryErrors: registerOrExisting(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "warden_registry_request_errors_total",
+			Help: "Number of failed registry lookups by error class.",
+		}, []string{"host", "class"})).(*prometheus.CounterVec),
+		registryHosts: newHostLabels(maxRegistryHostLabels),
+		backendResults: registerOrExisting(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "warden_verification_backend_results_total",
+			Help: "Number of verification chain backend results, \"verified\" or the error class of the denial.",
+		}, []string{"backend", "result"})).(*prometheus.CounterVec),
+		hookDrops: registerOrExisting(reg, prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "warden_decision_hook_dropped_events_total",
+			Help: "Number of validation events dropped because the decision hook queue was full.",
+		})).(prometheus.Counter),
+		hookPanics: registerOrExisting(reg, prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "warden_decision_hook_panics_total",
+			Help: "Number of validation events whose decision hook call panicked.",
+		})).(prometheus.Counter),
+		notaryRateLimiterWait: registerOrExisting(reg, prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "warden_notary_rate_limiter_wait_seconds",
+			Help:    "Time spent waiting on the notary client-side rate limiter.",
+			Buckets: prometheus.ExponentialBuckets(0.001, 4, 8),
+		})).(prometheus.Histogram),
+		registryInflightCalls: registerOrExisting(reg, prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "warden_registry_inflight_calls",
+			Help: "Number of registry calls currently in progress.",
+		})).(prometheus.Gauge),
+		registryRateLimitHits: registerOrExisting(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "warden_registry_rate_limit_hits_total",
+			Help: "Number of registry responses which rate limited the request, per registry host.",
+		}, []string{"registry"})).(*prometheus.CounterVec),
+		expiredExceptionHits: registerOrExisting(reg, prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "warden_expired_image_exception_hits_total",
+			Help: "Number of validated images which matched only expired image exceptions.",
+		})).(prometheus.Counter),
+		negativeCacheHits: registerOrExisting(reg, prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "warden_negative_cache_hits_total",
+			Help: "Number of validations answered by a cached denial.",
+		})).(prometheus.Counter),
+		negativeCacheMisses: registerOrExisting(reg, prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "warden_negative_cache_misses_total",
+			Help: "Number of validations without a cached denial.",
+		})).(prometheus.Counter),
+		negativeCacheEvictions: registerOrExisting(reg, prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "warden_negative_cache_evictions_total",
+			Help: "Number of cached denials evicted before their TTL because the cache was full.",
+		})).(prometheus.Counter),
+	}
+}
+
+func registerOrExisting(reg prometheus.Registerer, c prometheus.Collector) prometheus.Collector {
+	if err := reg.Register(c); err != nil {
+		var alreadyRegistered prometheus.AlreadyRegisteredError
+		if errors.As(err, &alreadyRegistered) {
+			return alreadyRegistered.ExistingCollector
+		}
+		panic(err)
+	}
+	return c
+}
+
+func (m *phaseMetrics) observeNotary(host string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+	m.notaryDuration.WithLabelValues(host).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.notaryErrors.WithLabelValues(host, errorClass(err)).Inc()
+	}
+}
+
+func (m *phaseMetrics) observeBackend(backend string, err error) {
+	if m == nil {
+		return
+	}
+	result := "verified"
+	if err != nil {
+		result = errorClass(err)
+	}
+	m.backendResults.WithLabelValues(backend, result).Inc()
+}
+
+func (m *phaseMetrics) observeDecisionHookDrop() {
+	if m == nil {
+		return
+	}
+	m.hookDrops.Inc()
+}
+
+func (m *phaseMetrics) observeDecisionHookPanic() {
+	if m == nil {
+		return
+	}
+	m.hookPanics.Inc()
+}
+
+func (m *phaseMetrics) observeRateLimiterWait(wait time.Duration) {
+	if m == nil {
+		return
+	}
+	m.notaryRateLimiterWait.Observe(wait.Seconds())
+}
+
+func (m *phaseMetrics) addRegistryInflightCalls(delta float64) {
+	if m == nil {
+		return
+	}
+	m.registryInflightCalls.Add(delta)
+}
+
+// observeRegistryRateLimit shares the host limit with the registry phase metrics.
+func (m *phaseMetrics) observeRegistryRateLimit(host string) {
+	if m == nil {
+		return
+	}
+	m.registryRateLimitHits.WithLabelValues(m.registryHosts.label(host)).Inc()
+}
+
+func (m *phaseMetrics) observeExpiredException() {
+	if m == nil {
+		return
+	}
+	m.expiredExceptionHits.Inc()
+}
+
+func (m *phaseMetrics) observeNegativeCache(hit bool) {
+	if m == nil {
+		return
+	}
+	if hit {
+		m.negativeCacheHits.Inc()
+		return
+	}
+	m.negativeCacheMisses.Inc()
+}
+
+func (m *phaseMetrics) observeNegativeCacheEviction() {
+	if m == nil {
+		return
+	}
+	m.negativeCacheEvictions.Inc()
+}
+
+func (m *phaseMetrics) observeRegistry(host string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+	host = m.registryHosts.label(host)
+	m.registryDuration.WithLabelValues(host).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.registryErrors.WithLabelValues(host, errorClass(err)).Inc()
+	}
+}
+
+// hostLabels keeps the first max hosts as label values, so images from many registries don't explode the series.
+type hostLabels struct {
+	mu    sync.Mutex
+	max   int
+	hosts map[string]struct{}
+}
+
+func newHostLabels(max int) *hostLabels {
+	return &hostLabels{max: max, hosts: map[string]struct{}{}}
+}
+
+func (l *hostLabels) label(host string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.hosts[host]; ok {
+		return host
+	}
+	if len(l.hosts) >= l.max {
+		return otherHostLabel
+	}
+	l.hosts[host] = struct{}{}
+	return host
+}
+
+// errorClass maps errors to a small set of label values.
+func errorClass(err error) string {
+	var (
+		phaseTimeout     PhaseTimeoutError
+		netErr           net.Error
+		registryAuth     RegistryAuthError
+		transportErr     *transport.Error
+		unsupportedMedia UnsupportedMediaTypeError
+		insecure         InsecureRegistryError
+		noSuchTarget     client.ErrNoSuchTarget
+		repoNotExist     client.ErrRepositoryNotExist
+		unacceptedRole   UnacceptedRoleError
+		expired          TrustMetadataExpiredError
+	)
+	switch {
+	case errors.As(err, &phaseTimeout), errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, ErrRegistryRateLimited):
+		return "rate_limited"
+	case errors.As(err, &registryAuth):
+		return "auth"
+	case errors.As(err, &transportErr) && (transportErr.StatusCode == http.StatusUnauthorized || transportErr.StatusCode == http.StatusForbidden):
+		return "auth"
+	case errors.As(err, &transportErr) && transportErr.StatusCode == http.StatusNotFound:
+		return "not_found"
+	case errors.As(err, &noSuchTarget), errors.As(err, &repoNotExist):
+		return "not_found"
+	case errors.As(err, &unacceptedRole):
+		return "untrusted_role"
+	case errors.As(err, &expired):
+		return "expired_metadata"
+	case errors.Is(err, ErrSignatureTooOld):
+		return "signature_too_old"
+	case errors.Is(err, ErrResponseTooLarge):
+		return "response_too_large"
+	case errors.As(err, &unsupportedMedia):
+		return "unsupported_media_type"
+	case errors.As(err, &insecure):
+		return "insecure"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &netErr):
+		return "connection"
+	}
+	return "other"
+}
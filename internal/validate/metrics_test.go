@@ -0,0 +1,159 @@
+package validate
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+// histogramCount returns the number of observations of the histogram with the host label.
+func histogramCount(t *testing.T, reg *prometheus.Registry, name, host string) uint64 {
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "host" && l.GetValue() == host {
+					return m.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func Test_Validate_PhaseMetrics(t *testing.T) {
+	t.Run("notary and registry requests are recorded separately", func(t *testing.T) {
+		//GIVEN
+		image := "eu.gcr.io/kyma-project/image:tag"
+		transport, img := pushTestImageAs(t, image)
+		reg := prometheus.NewRegistry()
+		s := NewDefaultMockNotaryService().
+			WithConfig(NotaryConfig{Url: "https://notary.example.com:4443"}).
+			WithHash(configHash(t, img)).
+			WithRegistryTransport(transport).
+			WithMetrics(reg).
+			Build()
+
+		//WHEN
+		err := s.Validate(context.TODO(), image)
+
+		//THEN
+		require.NoError(t, err)
+		require.Equal(t, uint64(1), histogramCount(t, reg, "warden_notary_request_duration_seconds", "notary.example.com:4443"))
+		require.Equal(t, uint64(1), histogramCount(t, reg, "warden_registry_request_duration_seconds", "eu.gcr.io"))
+		require.Zero(t, testutil.CollectAndCount(s.metrics.notaryErrors))
+		require.Zero(t, testutil.CollectAndCount(s.metrics.registryErrors))
+	})
+
+	t.Run("notary errors are counted by class", func(t *testing.T) {
+		//GIVEN
+		reg := prometheus.NewRegistry()
+		f := func(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+			return nil, client.ErrNoSuchTarget(name)
+		}
+		s := NewDefaultMockNotaryService().
+			WithConfig(NotaryConfig{Url: "https://notary.example.com"}).
+			WithFunc(f).
+			WithMetrics(reg).
+			Build()
+
+		//WHEN
+		err := s.Validate(context.TODO(), "eu.gcr.io/kyma-project/unsigned:tag")
+
+		//THEN
+		require.Error(t, err)
+		notaryErrors := s.metrics.notaryErrors.WithLabelValues("notary.example.com", "not_found")
+		require.Equal(t, float64(1), testutil.ToFloat64(notaryErrors))
+	})
+
+	t.Run("labels never contain image names", func(t *testing.T) {
+		//GIVEN
+		reg := prometheus.NewRegistry()
+		s := NewDefaultMockNotaryService().WithMetrics(reg).Build()
+
+		//WHEN
+		_ = s.Validate(context.TODO(), "eu.gcr.io/kyma-project/image:tag")
+
+		//THEN
+		families, err := reg.Gather()
+		require.NoError(t, err)
+		for _, family := range families {
+			for _, m := range family.GetMetric() {
+				for _, l := range m.GetLabel() {
+					require.NotContains(t, l.GetValue(), "kyma-project")
+				}
+			}
+		}
+	})
+}
+
+func Test_newPhaseMetrics_ReusesRegisteredMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	first := newPhaseMetrics(reg)
+	second := newPhaseMetrics(reg)
+
+	require.Same(t, first.notaryDuration, second.notaryDuration)
+	require.Same(t, first.registryErrors, second.registryErrors)
+	require.Same(t, first.negativeCacheHits, second.negativeCacheHits)
+}
+
+func Test_hostLabels_Bounded(t *testing.T) {
+	l := newHostLabels(2)
+
+	require.Equal(t, "a.io", l.label("a.io"))
+	require.Equal(t, "b.io", l.label("b.io"))
+	require.Equal(t, otherHostLabel, l.label("c.io"))
+	require.Equal(t, "a.io", l.label("a.io"))
+}
+
+func Test_phaseMetrics_RegistryRateLimitHostsBounded(t *testing.T) {
+	//GIVEN
+	m := newPhaseMetrics(prometheus.NewRegistry())
+
+	//WHEN
+	for i := 0; i < maxRegistryHostLabels+10; i++ {
+		m.observeRegistryRateLimit(fmt.Sprintf("registry-%d.io", i))
+	}
+
+	//THEN
+	require.Equal(t, maxRegistryHostLabels+1, testutil.CollectAndCount(m.registryRateLimitHits))
+	require.Equal(t, float64(10), testutil.ToFloat64(m.registryRateLimitHits.WithLabelValues(otherHostLabel)))
+}
+
+func Test_errorClass(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{err: PhaseTimeoutError{Phase: RegistryPhase, Err: context.DeadlineExceeded}, expected: "timeout"},
+		{err: context.Canceled, expected: "canceled"},
+		{err: fmt.Errorf("get image: %w", ErrRegistryRateLimited), expected: "rate_limited"},
+		{err: RegistryAuthError{Registry: "gcr.io", Err: fmt.Errorf("metadata server unavailable")}, expected: "auth"},
+		{err: fmt.Errorf("get image: %w", &transport.Error{StatusCode: http.StatusUnauthorized}), expected: "auth"},
+		{err: fmt.Errorf("get image: %w", &transport.Error{StatusCode: http.StatusNotFound}), expected: "not_found"},
+		{err: client.ErrNoSuchTarget("tag"), expected: "not_found"},
+		{err: UnacceptedRoleError{Role: "targets/dev"}, expected: "untrusted_role"},
+		{err: UnsupportedMediaTypeError{MediaType: "application/json"}, expected: "unsupported_media_type"},
+		{err: InsecureRegistryError{Registry: "10.0.0.1"}, expected: "insecure"},
+		{err: errUnexpectedImageHash, expected: "other"},
+	}
+	for _, tt := range tests {
+		t.Run(strings.ReplaceAll(tt.err.Error(), "/", "_"), func(t *testing.T) {
+			require.Equal(t, tt.expected, errorClass(tt.err))
+		})
+	}
+}
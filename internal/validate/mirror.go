@@ -0,0 +1,96 @@
+package validate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// mirrorFor returns the mirror host configured for the registry host.
+// Mirror keys are normalized, so "docker.io" matches images from Docker Hub.
+func (s *notaryService) mirrorFor(registry string) (string, bool) {
+	for upstream, mirror := range s.RegistryMirrors {
+		r, err := name.NewRegistry(upstream)
+		if err != nil {
+			continue
+		}
+		if r.RegistryStr() == registry {
+			return mirror, true
+		}
+	}
+	return "", false
+}
+
+// mirrorReference rewrites the registry host of ref to its mirror.
+// Only the registry calls use the mirror, the allow list and notary keep using the original image name.
+func (s *notaryService) mirrorReference(ref name.Reference) (name.Reference, bool, error) {
+	mirror, ok := s.mirrorFor(ref.Context().RegistryStr())
+	if !ok {
+		return nil, false, nil
+	}
+	delim := tagDelim
+	if _, ok := ref.(name.Digest); ok {
+		delim = "@"
+	}
+	mirrored, err := s.parseRegistryReference(mirror + "/" + ref.Context().RepositoryStr() + delim + ref.Identifier())
+	if err != nil {
+		return nil, false, fmt.Errorf("mirror ref parse: %w", err)
+	}
+	return mirrored, true, nil
+}
+
+// getDescriptor fetches the image descriptor from the registry mirror when it's configured.
+// The upstream registry is asked when the mirror doesn't know the image and RegistryMirrorFallback is set.
+// The returned host is the registry which served the descriptor.
+func (s *notaryService) getDescriptor(ctx context.Context, ref name.Reference, platform v1.Platform) (*remote.Descriptor, string, error) {
+	mirrored, ok, err := s.mirrorReference(ref)
+	if err != nil {
+		return nil, ref.Context().RegistryStr(), err
+	}
+	if !ok {
+		desc, err := s.fetchDescriptor(ctx, ref, platform)
+		return desc, ref.Context().RegistryStr(), err
+	}
+
+	desc, err := s.fetchDescriptor(ctx, mirrored, platform)
+	if err != nil && s.RegistryMirrorFallback && isRegistryNotFound(err) {
+		desc, err = s.fetchDescriptor(ctx, ref, platform)
+		return desc, ref.Context().RegistryStr(), err
+	}
+	return desc, mirrored.Context().RegistryStr(), err
+}
+
+func (s *notaryService) fetchDescriptor(ctx context.Context, ref name.Reference, platform v1.Platform) (*remote.Descriptor, error) {
+	authOpts, err := s.registryAuthOptions(ref)
+	if err != nil {
+		return nil, err
+	}
+	next := s.registryTransport
+	if next == nil {
+		next = remote.DefaultTransport
+	}
+	next = timingTransport{next: responseLimitTransport{next: next, limit: s.maxRegistryResponseBytes()}}
+	opts := append(authOpts,
+		remote.WithContext(ctx),
+		remote.WithPlatform(platform),
+		remote.WithTransport(httpsOnlyTransport{
+			next:       rateLimitRetryTransport{next: next, maxRetries: s.settingsForRegistry(ref.Context().RegistryStr()).registryRetries, metrics: s.metrics},
+			isInsecure: s.isRegistryInsecure,
+		}))
+	desc, err := remote.Get(ref, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("get image: %w", err)
+	}
+	return desc, nil
+}
+
+func isRegistryNotFound(err error) bool {
+	var transportErr *transport.Error
+	return errors.As(err, &transportErr) && transportErr.StatusCode == http.StatusNotFound
+}
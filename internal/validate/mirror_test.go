@@ -0,0 +1,172 @@
+package validate
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/require"
+)
+
+const testMirrorHost = "mirror.internal:5000"
+
+func TestValidate_RegistryMirror(t *testing.T) {
+	image := "eu.gcr.io/kyma-project/mirrored:1.0"
+
+	t.Run("digest is resolved from the mirror", func(t *testing.T) {
+		//GIVEN
+		routes := newHostRoutingTransport(t, "eu.gcr.io", testMirrorHost)
+		imageHash := routes.push(t, testMirrorHost, "kyma-project/mirrored:1.0")
+		s := NewDefaultMockNotaryService().
+			WithHash(imageHash).
+			WithRegistryTransport(routes).
+			WithInsecureRegistries(testMirrorHost).
+			Build()
+		s.RegistryMirrors = map[string]string{"eu.gcr.io": testMirrorHost}
+		// policy matching uses the original name
+		s.AllowedRegistries = []string{testMirrorHost}
+
+		//WHEN
+		result, err := s.ValidateDetailed(context.TODO(), image)
+
+		//THEN
+		require.NoError(t, err)
+		require.Equal(t, OutcomeSignatureVerified, result.Outcome)
+		require.Equal(t, int32(0), routes.requests("eu.gcr.io"))
+		require.NotZero(t, routes.requests(testMirrorHost))
+	})
+
+	t.Run("image missing in the mirror is denied without fallback", func(t *testing.T) {
+		//GIVEN
+		routes := newHostRoutingTransport(t, "eu.gcr.io", testMirrorHost)
+		imageHash := routes.push(t, "eu.gcr.io", "kyma-project/mirrored:1.0")
+		s := NewDefaultMockNotaryService().
+			WithHash(imageHash).
+			WithRegistryTransport(routes).
+			WithInsecureRegistries(testMirrorHost).
+			Build()
+		s.RegistryMirrors = map[string]string{"eu.gcr.io": testMirrorHost}
+
+		//WHEN
+		err := s.Validate(context.TODO(), image)
+
+		//THEN
+		require.Error(t, err)
+		require.True(t, isRegistryNotFound(err))
+		require.Equal(t, int32(0), routes.requests("eu.gcr.io"))
+	})
+
+	t.Run("image missing in the mirror is resolved from upstream with fallback", func(t *testing.T) {
+		//GIVEN
+		routes := newHostRoutingTransport(t, "eu.gcr.io", testMirrorHost)
+		imageHash := routes.push(t, "eu.gcr.io", "kyma-project/mirrored:1.0")
+		s := NewDefaultMockNotaryService().
+			WithHash(imageHash).
+			WithRegistryTransport(routes).
+			WithInsecureRegistries(testMirrorHost).
+			Build()
+		s.RegistryMirrors = map[string]string{"eu.gcr.io": testMirrorHost}
+		s.RegistryMirrorFallback = true
+
+		//WHEN
+		err := s.Validate(context.TODO(), image)
+
+		//THEN
+		require.NoError(t, err)
+		require.NotZero(t, routes.requests(testMirrorHost))
+		require.NotZero(t, routes.requests("eu.gcr.io"))
+	})
+}
+
+func Test_mirrorReference(t *testing.T) {
+	s := NewDefaultMockNotaryService().WithInsecureRegistries(testMirrorHost).Build()
+	s.RegistryMirrors = map[string]string{"docker.io": testMirrorHost, "eu.gcr.io": "mirror.example.com"}
+
+	tests := []struct {
+		name     string
+		image    string
+		expected string
+	}{
+		{
+			name:     "docker hub image",
+			image:    "nginx:1.23",
+			expected: testMirrorHost + "/library/nginx:1.23",
+		},
+		{
+			name:     "image pinned to digest",
+			image:    "eu.gcr.io/kyma-project/image@sha256:" + strings.Repeat("a", 64),
+			expected: "mirror.example.com/kyma-project/image@sha256:" + strings.Repeat("a", 64),
+		},
+		{
+			name:  "registry without mirror",
+			image: "ghcr.io/kyma-project/image:1.0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref, err := name.ParseReference(tt.image)
+			require.NoError(t, err)
+
+			mirrored, ok, err := s.mirrorReference(ref)
+
+			require.NoError(t, err)
+			if tt.expected == "" {
+				require.False(t, ok)
+				return
+			}
+			require.True(t, ok)
+			require.Equal(t, tt.expected, mirrored.Name())
+		})
+	}
+}
+
+// hostRoutingTransport sends requests to the test registry of the request host and counts them.
+type hostRoutingTransport struct {
+	servers map[string]*httptest.Server
+	counts  map[string]*int32
+}
+
+func newHostRoutingTransport(t *testing.T, hosts ...string) hostRoutingTransport {
+	rt := hostRoutingTransport{servers: map[string]*httptest.Server{}, counts: map[string]*int32{}}
+	for _, host := range hosts {
+		srv := httptest.NewServer(registry.New())
+		t.Cleanup(srv.Close)
+		rt.servers[host] = srv
+		rt.counts[host] = new(int32)
+	}
+	return rt
+}
+
+func (rt hostRoutingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	srv, ok := rt.servers[r.URL.Host]
+	if !ok {
+		return nil, fmt.Errorf("unknown test registry %s", r.URL.Host)
+	}
+	atomic.AddInt32(rt.counts[r.URL.Host], 1)
+	r = r.Clone(r.Context())
+	r.URL.Scheme = "http"
+	r.URL.Host = strings.TrimPrefix(srv.URL, "http://")
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func (rt hostRoutingTransport) requests(host string) int32 {
+	return atomic.LoadInt32(rt.counts[host])
+}
+
+// push writes a random image to the registry of host directly and returns the hash compared against notary.
+func (rt hostRoutingTransport) push(t *testing.T, host, repo string) []byte {
+	img, err := random.Image(128, 1)
+	require.NoError(t, err)
+	ref, err := name.ParseReference(strings.TrimPrefix(rt.servers[host].URL, "http://") + "/" + repo)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img))
+	return configHash(t, img)
+}
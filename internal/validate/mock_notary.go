@@ -1,29 +1,64 @@
 package validate
 
 import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/theupdateframework/notary/client"
 	"github.com/theupdateframework/notary/client/changelist"
 	"github.com/theupdateframework/notary/tuf/data"
 	"github.com/theupdateframework/notary/tuf/signed"
+	"golang.org/x/sync/singleflight"
+	"k8s.io/utils/clock"
 	"net"
+	"net/http"
+	"time"
 )
 
 // MOCK NOTARY CLIENT REPOSITORY
 
 type MockNotaryClientRepository struct {
 	GetTargetByNameFunc func(name string, roles ...data.RoleName) (*client.TargetWithRole, error)
+	// GetAllTargetMetadataByNameFunc is optional, the repository returns no metadata when it's not set.
+	GetAllTargetMetadataByNameFunc func(name string) ([]client.TargetSignedStruct, error)
+	// ListTargetsFunc is optional, the repository lists no targets when it's not set.
+	ListTargetsFunc func(roles ...data.RoleName) ([]*client.TargetWithRole, error)
+	// ctx ends GetTargetByName like it ends the requests of the notary client, it's optional.
+	ctx context.Context
 }
 
 func (m MockNotaryClientRepository) ListTargets(roles ...data.RoleName) ([]*client.TargetWithRole, error) {
-	panic("implement me")
+	if m.ListTargetsFunc == nil {
+		return nil, nil
+	}
+	return m.ListTargetsFunc(roles...)
 }
 
 func (m MockNotaryClientRepository) GetTargetByName(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
-	return m.GetTargetByNameFunc(name, roles...)
+	if m.ctx == nil {
+		return m.GetTargetByNameFunc(name, roles...)
+	}
+	type lookup struct {
+		target *client.TargetWithRole
+		err    error
+	}
+	done := make(chan lookup, 1)
+	go func() {
+		target, err := m.GetTargetByNameFunc(name, roles...)
+		done <- lookup{target: target, err: err}
+	}()
+	select {
+	case l := <-done:
+		return l.target, l.err
+	case <-m.ctx.Done():
+		return nil, m.ctx.Err()
+	}
 }
 
 func (m MockNotaryClientRepository) GetAllTargetMetadataByName(name string) ([]client.TargetSignedStruct, error) {
-	panic("implement me")
+	if m.GetAllTargetMetadataByNameFunc == nil {
+		return nil, nil
+	}
+	return m.GetAllTargetMetadataByNameFunc(name)
 }
 
 func (m MockNotaryClientRepository) ListRoles() ([]client.RoleWithSignatures, error) {
@@ -113,12 +148,16 @@ func (m MockNotaryClientRepository) GetCryptoService() signed.CryptoService {
 // MOCK NOTARY REPO FACTORY
 
 type MockNotaryRepoFactory struct {
-	GetTargetByNameFunc *func(name string, roles ...data.RoleName) (*client.TargetWithRole, error)
+	GetTargetByNameFunc            *func(name string, roles ...data.RoleName) (*client.TargetWithRole, error)
+	GetAllTargetMetadataByNameFunc func(name string) ([]client.TargetSignedStruct, error)
+	ListTargetsFunc                func(roles ...data.RoleName) ([]*client.TargetWithRole, error)
 }
 
-func (f MockNotaryRepoFactory) NewRepoClient(img string, c NotaryConfig) (client.Repository, error) {
-	r := MockNotaryClientRepository{}
+func (f MockNotaryRepoFactory) NewRepoClient(ctx context.Context, img string, c NotaryConfig) (client.Repository, error) {
+	r := MockNotaryClientRepository{ctx: ctx}
 	r.GetTargetByNameFunc = *f.GetTargetByNameFunc
+	r.GetAllTargetMetadataByNameFunc = f.GetAllTargetMetadataByNameFunc
+	r.ListTargetsFunc = f.ListTargetsFunc
 	return r, nil
 }
 
@@ -128,7 +167,7 @@ type MockNotaryRepoFactoryNoSuchHost struct {
 	GetTargetByNameFunc *func(name string, roles ...data.RoleName) (*client.TargetWithRole, error)
 }
 
-func (f MockNotaryRepoFactoryNoSuchHost) NewRepoClient(img string, c NotaryConfig) (client.Repository, error) {
+func (f MockNotaryRepoFactoryNoSuchHost) NewRepoClient(_ context.Context, img string, c NotaryConfig) (client.Repository, error) {
 	return nil, &net.OpError{
 		Op:  "dial",
 		Net: "tcp",
@@ -178,6 +217,92 @@ func (b *MockNotaryServiceBuilder) WithFunc(f func(name string, roles ...data.Ro
 	return b
 }
 
+func (b *MockNotaryServiceBuilder) WithRateLimit(c NotaryConfig, clk clock.Clock) *MockNotaryServiceBuilder {
+	b.NotaryService.limiter = newNotaryRateLimiter(c, clk, b.NotaryService.metrics)
+	return b
+}
+
+func (b *MockNotaryServiceBuilder) WithNegativeCache(ttl time.Duration, clk clock.Clock) *MockNotaryServiceBuilder {
+	b.NotaryService.NegativeCacheTTL = ttl
+	b.NotaryService.negativeCache = newNegativeCache(&b.NotaryService.ServiceConfig, clk, b.NotaryService.metrics)
+	return b
+}
+
+func (b *MockNotaryServiceBuilder) WithDigestCache(ttl time.Duration, clk clock.Clock) *MockNotaryServiceBuilder {
+	b.NotaryService.DigestCacheTTL = ttl
+	b.NotaryService.digestCache = newDigestCache(&b.NotaryService.ServiceConfig, clk)
+	return b
+}
+
+// WithMetrics records the metrics of the service and of its limiters and negative cache to reg.
+func (b *MockNotaryServiceBuilder) WithMetrics(reg prometheus.Registerer) *MockNotaryServiceBuilder {
+	m := newPhaseMetrics(reg)
+	b.NotaryService.metrics = m
+	if b.NotaryService.limiter != nil {
+		b.NotaryService.limiter.metrics = m
+	}
+	if b.NotaryService.registryLimiter != nil {
+		b.NotaryService.registryLimiter.metrics = m
+	}
+	if b.NotaryService.negativeCache != nil {
+		b.NotaryService.negativeCache.metrics = m
+	}
+	return b
+}
+
+func (b *MockNotaryServiceBuilder) WithSingleflight() *MockNotaryServiceBuilder {
+	b.NotaryService.flights = &singleflight.Group{}
+	return b
+}
+
+func (b *MockNotaryServiceBuilder) WithMaxConcurrentRegistryCalls(max int) *MockNotaryServiceBuilder {
+	b.NotaryService.MaxConcurrentRegistryCalls = max
+	b.NotaryService.registryLimiter = newRegistryCallLimiter(max, b.NotaryService.metrics)
+	return b
+}
+
+func (b *MockNotaryServiceBuilder) WithRegistryTransport(rt http.RoundTripper) *MockNotaryServiceBuilder {
+	b.NotaryService.registryTransport = rt
+	return b
+}
+
+func (b *MockNotaryServiceBuilder) WithInsecureRegistries(r ...string) *MockNotaryServiceBuilder {
+	b.NotaryService.InsecureRegistries = r
+	return b
+}
+
+func (b *MockNotaryServiceBuilder) WithRegistryKeychains(k ...RegistryKeychain) *MockNotaryServiceBuilder {
+	b.NotaryService.RegistryKeychains = k
+	return b
+}
+
+func (b *MockNotaryServiceBuilder) WithDelegationRoles(r map[string]data.RoleName) *MockNotaryServiceBuilder {
+	b.NotaryService.DelegationRoles = r
+	return b
+}
+
+func (b *MockNotaryServiceBuilder) WithRequiredSignerKeyIDs(k map[string][]string) *MockNotaryServiceBuilder {
+	b.NotaryService.RequiredSignerKeyIDs = k
+	return b
+}
+
+func (b *MockNotaryServiceBuilder) WithDigestTargets() *MockNotaryServiceBuilder {
+	b.NotaryService.DigestTargets = true
+	return b
+}
+
+func (b *MockNotaryServiceBuilder) WithAllowedRegistriesFile(path string) *MockNotaryServiceBuilder {
+	b.NotaryService.AllowedRegistriesFile = path
+	b.NotaryService.allowedFile = newAllowedRegistriesFile(path)
+	return b
+}
+
+func (b *MockNotaryServiceBuilder) WithExceptions(clk clock.Clock, e ...ImageException) *MockNotaryServiceBuilder {
+	b.NotaryService.Exceptions = e
+	b.NotaryService.clock = clk
+	return b
+}
+
 func (b *MockNotaryServiceBuilder) WithHash(h []byte) *MockNotaryServiceBuilder {
 	f := NewDefaultMockNotaryFunction().WithHash(h).Build()
 	b.NotaryService.RepoFactory = MockNotaryRepoFactory{
@@ -186,6 +311,28 @@ func (b *MockNotaryServiceBuilder) WithHash(h []byte) *MockNotaryServiceBuilder
 	return b
 }
 
+// WithSigningKeys makes the repository report the keys which signed the metadata of each role.
+// It must be used after the target function is set, e.g. with WithFunc.
+func (b *MockNotaryServiceBuilder) WithSigningKeys(keys map[data.RoleName][]string) *MockNotaryServiceBuilder {
+	f := b.NotaryService.RepoFactory.(MockNotaryRepoFactory)
+	f.GetAllTargetMetadataByNameFunc = func(name string) ([]client.TargetSignedStruct, error) {
+		var metadata []client.TargetSignedStruct
+		for role, keyIDs := range keys {
+			m := client.TargetSignedStruct{
+				Role:   data.DelegationRole{BaseRole: data.BaseRole{Name: role}},
+				Target: client.Target{Name: name},
+			}
+			for _, keyID := range keyIDs {
+				m.Signatures = append(m.Signatures, data.Signature{KeyID: keyID})
+			}
+			metadata = append(metadata, m)
+		}
+		return metadata, nil
+	}
+	b.NotaryService.RepoFactory = f
+	return b
+}
+
 func (b *MockNotaryServiceBuilder) Build() notaryService {
 	return b.NotaryService
 }
@@ -193,28 +340,64 @@ func (b *MockNotaryServiceBuilder) Build() notaryService {
 // MOCK NOTARY FUNCTION BUILDER
 
 type MockNotaryFunctionBuilder struct {
-	Hash []byte
+	Hashes     map[string][]byte
+	Role       data.RoleName
+	RoleHashes map[data.RoleName][]byte
 }
 
 func NewDefaultMockNotaryFunction() *MockNotaryFunctionBuilder {
 	return &MockNotaryFunctionBuilder{
-		Hash: []byte{1, 2, 3, 4},
+		Hashes: map[string][]byte{SHA256Algorithm: {1, 2, 3, 4}},
+		Role:   data.CanonicalTargetsRole,
 	}
 }
 
 func (b *MockNotaryFunctionBuilder) WithHash(h []byte) *MockNotaryFunctionBuilder {
-	b.Hash = h
+	b.Hashes = map[string][]byte{SHA256Algorithm: h}
+	return b
+}
+
+func (b *MockNotaryFunctionBuilder) WithHashes(h map[string][]byte) *MockNotaryFunctionBuilder {
+	b.Hashes = h
+	return b
+}
+
+func (b *MockNotaryFunctionBuilder) WithRole(r data.RoleName) *MockNotaryFunctionBuilder {
+	b.Role = r
+	return b
+}
+
+// WithRoleHashes makes the function return the hash signed by the first requested role
+// which is present in the given map, like notary does when walking through the roles.
+func (b *MockNotaryFunctionBuilder) WithRoleHashes(h map[data.RoleName][]byte) *MockNotaryFunctionBuilder {
+	b.RoleHashes = h
 	return b
 }
 
 func (b *MockNotaryFunctionBuilder) Build() func(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
 	f := func(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+		if b.RoleHashes != nil {
+			for _, role := range roles {
+				if hash, ok := b.RoleHashes[role]; ok {
+					return &client.TargetWithRole{
+						Target: client.Target{
+							Name:   name,
+							Hashes: map[string][]byte{SHA256Algorithm: hash},
+							Length: 1,
+						},
+						Role: role,
+					}, nil
+				}
+			}
+			return nil, client.ErrNoSuchTarget(name)
+		}
 		return &client.TargetWithRole{
 			Target: client.Target{
 				Name:   "ignored",
-				Hashes: map[string][]byte{"ignored": b.Hash},
+				Hashes: b.Hashes,
 				Length: 1,
 			},
+			Role: b.Role,
 		}, nil
 	}
 	return f
@@ -6,6 +6,8 @@ import (
 	context "context"
 
 	mock "github.com/stretchr/testify/mock"
+
+	validate "github.com/kyma-project/warden/internal/validate"
 )
 
 // ImageValidatorService is an autogenerated mock type for the ImageValidatorService type
@@ -27,6 +29,41 @@ func (_m *ImageValidatorService) Validate(ctx context.Context, image string) err
 	return r0
 }
 
+// ValidateDetailed provides a mock function with given fields: ctx, image
+func (_m *ImageValidatorService) ValidateDetailed(ctx context.Context, image string) (validate.ImageValidationResult, error) {
+	ret := _m.Called(ctx, image)
+
+	var r0 validate.ImageValidationResult
+	if rf, ok := ret.Get(0).(func(context.Context, string) validate.ImageValidationResult); ok {
+		r0 = rf(ctx, image)
+	} else {
+		r0 = ret.Get(0).(validate.ImageValidationResult)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, image)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ValidateWithDigest provides a mock function with given fields: ctx, image, digest
+func (_m *ImageValidatorService) ValidateWithDigest(ctx context.Context, image string, digest string) error {
+	ret := _m.Called(ctx, image, digest)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, image, digest)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 type mockConstructorTestingTNewImageValidatorService interface {
 	mock.TestingT
 	Cleanup(func())
@@ -0,0 +1,93 @@
+package validate
+
+import (
+	"fmt"
+
+	"golang.org/x/sync/singleflight"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/utils/clock"
+)
+
+// ServiceConfigPatch overrides the global ServiceConfig for a namespace, empty fields are not overridden.
+type ServiceConfigPatch struct {
+	// AllowedRegistries are allowed in the namespace in addition to the global allowed registries.
+	AllowedRegistries []string
+	// NotaryURL replaces the global notary URL.
+	NotaryURL string
+}
+
+// ImageValidatorFactory returns the image validator which applies the trust requirements of the namespace.
+type ImageValidatorFactory interface {
+	GetValidator(namespace string) ImageValidatorService
+}
+
+type namespacedValidators struct {
+	global     ImageValidatorService
+	namespaces map[string]ImageValidatorService
+}
+
+// NewNamespacedImageValidators returns the factory which merges overrides over the global config,
+// namespaces without overrides get the validator for the global config.
+// The global and the merged configs are validated like by NewImageValidator.
+func NewNamespacedImageValidators(sc *ServiceConfig, overrides map[string]ServiceConfigPatch, opts ...Option) (ImageValidatorFactory, error) {
+	global := newNotaryService(sc, opts...)
+	if err := global.validateConfig(); err != nil {
+		return nil, err
+	}
+	v := &namespacedValidators{
+		global:     global,
+		namespaces: make(map[string]ImageValidatorService, len(overrides)),
+	}
+	var errs []error
+	for namespace, patch := range overrides {
+		patched := global.withPatch(patch)
+		if err := patched.validateConfig(); err != nil {
+			errs = append(errs, fmt.Errorf("namespace %s: %w", namespace, err))
+			continue
+		}
+		v.namespaces[namespace] = patched
+	}
+	if len(errs) > 0 {
+		return nil, utilerrors.NewAggregate(errs)
+	}
+	return v, nil
+}
+
+func (v *namespacedValidators) GetValidator(namespace string) ImageValidatorService {
+	if validator, ok := v.namespaces[namespace]; ok {
+		return validator
+	}
+	return v.global
+}
+
+// withPatch returns the copy of the service with the patched config,
+// the registry call limit and the digest cache are shared with the global validator.
+func (s *notaryService) withPatch(p ServiceConfigPatch) *notaryService {
+	patched := *s
+	clk := s.clock
+	if clk == nil {
+		clk = clock.RealClock{}
+	}
+	patched.AllowedRegistries = append(append([]string{}, s.AllowedRegistries...), p.AllowedRegistries...)
+	// denials cached for the global config may not apply to the namespace
+	patched.negativeCache = newNegativeCache(&patched.ServiceConfig, clk, s.metrics)
+	patched.flights = &singleflight.Group{}
+	if p.NotaryURL != "" {
+		patched.NotaryConfig.Url = p.NotaryURL
+		// fallbacks of the global notary don't serve the namespace notary
+		patched.NotaryConfig.FallbackUrls = nil
+		patched.endpoints = newNotaryEndpoints(patched.NotaryConfig)
+		// requests to another notary don't count against the global notary limit
+		patched.limiter = newNotaryRateLimiter(patched.NotaryConfig, clk, s.metrics)
+	}
+	return &patched
+}
+
+// staticValidator returns the same validator for all namespaces.
+type staticValidator struct {
+	validator ImageValidatorService
+}
+
+func (v staticValidator) GetValidator(string) ImageValidatorService {
+	return v.validator
+}
@@ -0,0 +1,102 @@
+package validate
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+// urlRecordingRepoFactory records the notary URLs used to create repository clients.
+type urlRecordingRepoFactory struct {
+	MockNotaryRepoFactory
+	mu   sync.Mutex
+	urls []string
+}
+
+func (f *urlRecordingRepoFactory) NewRepoClient(ctx context.Context, img string, c NotaryConfig) (client.Repository, error) {
+	f.mu.Lock()
+	f.urls = append(f.urls, c.Url)
+	f.mu.Unlock()
+	return f.MockNotaryRepoFactory.NewRepoClient(ctx, img, c)
+}
+
+func Test_NamespacedImageValidators(t *testing.T) {
+	// notary doesn't know the images, so validations which reach it never call the registry
+	mockFunc := func(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+		return nil, client.ErrNoSuchTarget(name)
+	}
+	sc := &ServiceConfig{
+		NotaryConfig:      NotaryConfig{Url: "https://global-notary"},
+		AllowedRegistries: []string{"eu.gcr.io/kyma-project"},
+	}
+	overrides := map[string]ServiceConfigPatch{
+		"experiments": {
+			AllowedRegistries: []string{"docker.io/experiments"},
+			NotaryURL:         "https://experiments-notary",
+		},
+	}
+
+	tests := []struct {
+		name              string
+		namespace         string
+		image             string
+		expectedOutcome   Outcome
+		expectedNotaryURL string
+	}{
+		{
+			name:            "global allowed registry in the overridden namespace",
+			namespace:       "experiments",
+			image:           "eu.gcr.io/kyma-project/image:tag",
+			expectedOutcome: OutcomeAllowedList,
+		},
+		{
+			name:            "namespace allowed registry in the overridden namespace",
+			namespace:       "experiments",
+			image:           "docker.io/experiments/image:tag",
+			expectedOutcome: OutcomeAllowedList,
+		},
+		{
+			name:              "namespace allowed registry in other namespace",
+			namespace:         "production",
+			image:             "docker.io/experiments/image:tag",
+			expectedOutcome:   OutcomeDenied,
+			expectedNotaryURL: "https://global-notary",
+		},
+		{
+			name:              "namespace notary overrides the global notary",
+			namespace:         "experiments",
+			image:             "docker.io/other/image:tag",
+			expectedOutcome:   OutcomeDenied,
+			expectedNotaryURL: "https://experiments-notary",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//GIVEN
+			f := &urlRecordingRepoFactory{MockNotaryRepoFactory: MockNotaryRepoFactory{GetTargetByNameFunc: &mockFunc}}
+			validators, err := NewNamespacedImageValidators(sc, overrides, WithRepoFactory(f))
+			require.NoError(t, err)
+
+			//WHEN
+			result, _ := validators.GetValidator(tt.namespace).ValidateDetailed(context.TODO(), tt.image)
+
+			//THEN
+			require.Equal(t, tt.expectedOutcome, result.Outcome)
+			if tt.expectedNotaryURL != "" {
+				require.Equal(t, []string{tt.expectedNotaryURL}, f.urls)
+			}
+		})
+	}
+
+	t.Run("overrides don't change the global config", func(t *testing.T) {
+		_, err := NewNamespacedImageValidators(sc, overrides, WithRepoFactory(MockNotaryRepoFactory{GetTargetByNameFunc: &mockFunc}))
+		require.NoError(t, err)
+
+		require.Equal(t, []string{"eu.gcr.io/kyma-project"}, sc.AllowedRegistries)
+		require.Equal(t, "https://global-notary", sc.NotaryConfig.Url)
+	})
+}
@@ -0,0 +1,218 @@
+package validate
+
+import (
+	"container/list"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/theupdateframework/notary/client"
+	"k8s.io/utils/clock"
+)
+
+const (
+	// DefaultNegativeCacheTTL is shorter than the time after which a crash-looping pod is restarted,
+	// so a newly signed image is admitted on the next restart.
+	DefaultNegativeCacheTTL = 30 * time.Second
+
+	// DefaultNegativeCacheMaxEntries bounds the memory used when many different images are denied,
+	// the least recently used denials are evicted above it.
+	DefaultNegativeCacheMaxEntries = 5000
+)
+
+// negativeCache stores denials which would be repeated by notary or the registry, keyed by image.
+// It's an LRU, so clusters with high image churn don't grow it without bounds.
+type negativeCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	clock      clock.Clock
+	metrics    *phaseMetrics
+	// lru holds *negativeCacheEntry, the most recently used entry is at the front.
+	lru     *list.List
+	entries map[string]*list.Element
+	// generation is increased by invalidations, so denials of validations which were in flight aren't cached.
+	generation uint64
+}
+
+type negativeCacheEntry struct {
+	image   string
+	result  ImageValidationResult
+	err     error
+	expires time.Time
+}
+
+// newNegativeCache returns nil when the negative cache is disabled.
+func newNegativeCache(sc *ServiceConfig, clk clock.Clock, m *phaseMetrics) *negativeCache {
+	if sc.DisableNegativeCache {
+		return nil
+	}
+	ttl := sc.NegativeCacheTTL
+	if ttl <= 0 {
+		ttl = DefaultNegativeCacheTTL
+	}
+	maxEntries := sc.NegativeCacheMaxEntries
+	if maxEntries <= 0 {
+		maxEntries = DefaultNegativeCacheMaxEntries
+	}
+	return &negativeCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		clock:      clk,
+		metrics:    m,
+		lru:        list.New(),
+		entries:    map[string]*list.Element{},
+	}
+}
+
+func (c *negativeCache) get(image string) (ImageValidationResult, error, bool) {
+	if c == nil {
+		return ImageValidationResult{}, nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[image]
+	if !ok {
+		c.metrics.observeNegativeCache(false)
+		return ImageValidationResult{}, nil, false
+	}
+	entry := elem.Value.(*negativeCacheEntry)
+	if !c.clock.Now().Before(entry.expires) {
+		c.remove(elem)
+		c.metrics.observeNegativeCache(false)
+		return ImageValidationResult{}, nil, false
+	}
+	c.lru.MoveToFront(elem)
+	c.metrics.observeNegativeCache(true)
+	return entry.result, entry.err, true
+}
+
+func (c *negativeCache) add(image string, result ImageValidationResult, err error) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.addLocked(image, result, err)
+}
+
+// currentGeneration is taken before the validation and passed to addUnlessInvalidated.
+func (c *negativeCache) currentGeneration() uint64 {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// addUnlessInvalidated adds the denial only when the cache wasn't invalidated since generation.
+func (c *negativeCache) addUnlessInvalidated(generation uint64, image string, result ImageValidationResult, err error) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation != generation {
+		return
+	}
+	c.addLocked(image, result, err)
+}
+
+func (c *negativeCache) addLocked(image string, result ImageValidationResult, err error) {
+	entry := &negativeCacheEntry{
+		image:   image,
+		result:  result,
+		err:     err,
+		expires: c.clock.Now().Add(c.ttl),
+	}
+	if elem, ok := c.entries[image]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[image] = c.lru.PushFront(entry)
+	if c.lru.Len() > c.maxEntries {
+		c.remove(c.lru.Back())
+		c.metrics.observeNegativeCacheEviction()
+	}
+}
+
+// removeIf drops the entries of images for which match returns true, keys are "<image> <platform>[ <version>]".
+func (c *negativeCache) removeIf(match func(image string) bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for key, elem := range c.entries {
+		image, _, _ := strings.Cut(key, " ")
+		if match(image) {
+			c.remove(elem)
+		}
+	}
+}
+
+func (c *negativeCache) flush() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.lru.Init()
+	c.entries = map[string]*list.Element{}
+}
+
+func (c *negativeCache) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*negativeCacheEntry).image)
+}
+
+func (c *negativeCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// isDeterministicDenial returns true for denials which don't depend on notary or the registry being reachable,
+// transport errors and timeouts are never cached.
+func isDeterministicDenial(err error) bool {
+	var (
+		noSuchTarget     client.ErrNoSuchTarget
+		repoNotExist     client.ErrRepositoryNotExist
+		repoNotInit      client.ErrRepoNotInitialized
+		unacceptedRole   UnacceptedRoleError
+		digestMismatch   DigestMismatchError
+		phaseTimeout     PhaseTimeoutError
+		unsupportedMedia UnsupportedMediaTypeError
+		implicitRegistry ImplicitRegistryError
+		schema1          UnsupportedManifestSchemaError
+		signerKey        SignerKeyError
+	)
+	switch {
+	case errors.As(err, &phaseTimeout):
+		return false
+	case errors.Is(err, errMalformedImageName),
+		errors.Is(err, errMalformedImageDigest),
+		errors.Is(err, errUnexpectedImageHash),
+		errors.Is(err, ErrUnsupportedHashAlgorithm):
+		return true
+	case errors.As(err, &noSuchTarget),
+		errors.As(err, &repoNotExist),
+		errors.As(err, &repoNotInit),
+		errors.As(err, &unacceptedRole),
+		errors.As(err, &digestMismatch),
+		errors.As(err, &unsupportedMedia),
+		errors.As(err, &implicitRegistry),
+		errors.As(err, &schema1),
+		errors.As(err, &signerKey):
+		return true
+	}
+	return false
+}
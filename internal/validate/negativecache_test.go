@@ -0,0 +1,240 @@
+package validate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func Test_Validate_NegativeCache(t *testing.T) {
+	image := "eu.gcr.io/kyma-project/unsigned:tag"
+
+	t.Run("unsigned image is denied from cache", func(t *testing.T) {
+		//GIVEN
+		var calls int32
+		f := func(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+			atomic.AddInt32(&calls, 1)
+			return nil, client.ErrNoSuchTarget(name)
+		}
+		s := NewDefaultMockNotaryService().WithFunc(f).WithNegativeCache(time.Minute, testingclock.NewFakeClock(time.Now())).Build()
+		_, firstErr := s.ValidateDetailed(context.TODO(), image)
+
+		//WHEN
+		result, err := s.ValidateDetailed(context.TODO(), image)
+
+		//THEN
+		require.Equal(t, firstErr, err)
+		require.Equal(t, OutcomeDenied, result.Outcome)
+		require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("denial expires after TTL", func(t *testing.T) {
+		//GIVEN
+		var calls int32
+		f := func(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+			atomic.AddInt32(&calls, 1)
+			return nil, client.ErrNoSuchTarget(name)
+		}
+		clk := testingclock.NewFakeClock(time.Now())
+		s := NewDefaultMockNotaryService().WithFunc(f).WithNegativeCache(time.Minute, clk).Build()
+		_, _ = s.ValidateDetailed(context.TODO(), image)
+
+		//WHEN
+		clk.Step(time.Minute)
+		_, err := s.ValidateDetailed(context.TODO(), image)
+
+		//THEN
+		require.Error(t, err)
+		require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("connection error is retried", func(t *testing.T) {
+		//GIVEN
+		var calls int32
+		f := func(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+			atomic.AddInt32(&calls, 1)
+			return nil, &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "connection refused"}}
+		}
+		s := NewDefaultMockNotaryService().WithFunc(f).WithNegativeCache(time.Minute, testingclock.NewFakeClock(time.Now())).Build()
+		_, _ = s.ValidateDetailed(context.TODO(), image)
+
+		//WHEN
+		_, err := s.ValidateDetailed(context.TODO(), image)
+
+		//THEN
+		require.Error(t, err)
+		require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+}
+
+func Test_newNegativeCache(t *testing.T) {
+	require.Nil(t, newNegativeCache(&ServiceConfig{DisableNegativeCache: true}, testingclock.NewFakeClock(time.Now()), nil))
+	require.Equal(t, DefaultNegativeCacheTTL, newNegativeCache(&ServiceConfig{}, testingclock.NewFakeClock(time.Now()), nil).ttl)
+	require.Equal(t, time.Second, newNegativeCache(&ServiceConfig{NegativeCacheTTL: time.Second}, testingclock.NewFakeClock(time.Now()), nil).ttl)
+	require.Equal(t, DefaultNegativeCacheMaxEntries, newNegativeCache(&ServiceConfig{}, testingclock.NewFakeClock(time.Now()), nil).maxEntries)
+	require.Equal(t, 10, newNegativeCache(&ServiceConfig{NegativeCacheMaxEntries: 10}, testingclock.NewFakeClock(time.Now()), nil).maxEntries)
+}
+
+func Test_negativeCache_LRU(t *testing.T) {
+	errDenied := errors.New("denied")
+
+	t.Run("least recently used entry is evicted", func(t *testing.T) {
+		//GIVEN
+		c := newNegativeCache(&ServiceConfig{NegativeCacheMaxEntries: 2}, testingclock.NewFakeClock(time.Now()), newPhaseMetrics(prometheus.NewRegistry()))
+		c.add("first", ImageValidationResult{}, errDenied)
+		c.add("second", ImageValidationResult{}, errDenied)
+		_, _, _ = c.get("first")
+		evictionsBefore := testutil.ToFloat64(c.metrics.negativeCacheEvictions)
+
+		//WHEN
+		c.add("third", ImageValidationResult{}, errDenied)
+
+		//THEN
+		_, _, ok := c.get("first")
+		require.True(t, ok)
+		_, _, ok = c.get("second")
+		require.False(t, ok)
+		_, _, ok = c.get("third")
+		require.True(t, ok)
+		require.Equal(t, 2, c.len())
+		require.Equal(t, float64(1), testutil.ToFloat64(c.metrics.negativeCacheEvictions)-evictionsBefore)
+	})
+
+	t.Run("re-added entry doesn't grow the cache", func(t *testing.T) {
+		//GIVEN
+		c := newNegativeCache(&ServiceConfig{NegativeCacheMaxEntries: 2}, testingclock.NewFakeClock(time.Now()), newPhaseMetrics(prometheus.NewRegistry()))
+		c.add("first", ImageValidationResult{}, errDenied)
+		evictionsBefore := testutil.ToFloat64(c.metrics.negativeCacheEvictions)
+
+		//WHEN
+		c.add("first", ImageValidationResult{Image: "first"}, errDenied)
+
+		//THEN
+		result, _, ok := c.get("first")
+		require.True(t, ok)
+		require.Equal(t, "first", result.Image)
+		require.Equal(t, 1, c.len())
+		require.Equal(t, float64(0), testutil.ToFloat64(c.metrics.negativeCacheEvictions)-evictionsBefore)
+	})
+
+	t.Run("expired entry is removed on get", func(t *testing.T) {
+		//GIVEN
+		clk := testingclock.NewFakeClock(time.Now())
+		c := newNegativeCache(&ServiceConfig{NegativeCacheTTL: time.Minute}, clk, newPhaseMetrics(prometheus.NewRegistry()))
+		c.add("first", ImageValidationResult{}, errDenied)
+		clk.Step(time.Minute)
+		missesBefore := testutil.ToFloat64(c.metrics.negativeCacheMisses)
+
+		//WHEN
+		_, _, ok := c.get("first")
+
+		//THEN
+		require.False(t, ok)
+		require.Equal(t, 0, c.len())
+		require.Equal(t, float64(1), testutil.ToFloat64(c.metrics.negativeCacheMisses)-missesBefore)
+	})
+
+	t.Run("concurrent access", func(t *testing.T) {
+		//GIVEN
+		c := newNegativeCache(&ServiceConfig{NegativeCacheMaxEntries: 100}, testingclock.NewFakeClock(time.Now()), newPhaseMetrics(prometheus.NewRegistry()))
+		var wg sync.WaitGroup
+
+		//WHEN
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 1000; j++ {
+					image := fmt.Sprintf("image-%d:%d", i, j%150)
+					c.add(image, ImageValidationResult{}, errDenied)
+					_, _, _ = c.get(image)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		//THEN
+		require.Equal(t, 100, c.len())
+		require.Len(t, c.entries, 100)
+	})
+}
+
+func Test_negativeCache_Soak(t *testing.T) {
+	if testing.Short() {
+		t.Skip("soak test")
+	}
+	const (
+		images     = 100000
+		maxEntries = 5000
+	)
+	errDenied := errors.New("denied")
+	c := newNegativeCache(&ServiceConfig{NegativeCacheMaxEntries: maxEntries}, testingclock.NewFakeClock(time.Now()), newPhaseMetrics(prometheus.NewRegistry()))
+	hitsBefore := testutil.ToFloat64(c.metrics.negativeCacheHits)
+	missesBefore := testutil.ToFloat64(c.metrics.negativeCacheMisses)
+	evictionsBefore := testutil.ToFloat64(c.metrics.negativeCacheEvictions)
+
+	var warmedUp uint64
+	for i := 0; i < images; i++ {
+		image := fmt.Sprintf("eu.gcr.io/kyma-project/churn-%d:tag", i)
+		if _, _, ok := c.get(image); !ok {
+			c.add(image, ImageValidationResult{Image: image, Outcome: OutcomeDenied}, errDenied)
+		}
+		// the same pod is restarted a few times before the next image comes
+		_, _, _ = c.get(image)
+		if i == 2*maxEntries {
+			warmedUp = heapInUse()
+		}
+		require.LessOrEqual(t, c.len(), maxEntries)
+	}
+
+	// all images beyond the limit replaced the oldest ones, so memory stays at the level of a full cache
+	require.Len(t, c.entries, maxEntries)
+	require.Less(t, heapInUse(), warmedUp+8<<20)
+	require.Equal(t, float64(images), testutil.ToFloat64(c.metrics.negativeCacheHits)-hitsBefore)
+	require.Equal(t, float64(images), testutil.ToFloat64(c.metrics.negativeCacheMisses)-missesBefore)
+	require.Equal(t, float64(images-maxEntries), testutil.ToFloat64(c.metrics.negativeCacheEvictions)-evictionsBefore)
+}
+
+func heapInUse() uint64 {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapInuse
+}
+
+func Test_isDeterministicDenial(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "malformed image name", err: errMalformedImageName, expected: true},
+		{name: "unexpected image hash", err: errUnexpectedImageHash, expected: true},
+		{name: "no trust data", err: client.ErrNoSuchTarget("tag"), expected: true},
+		{name: "repository not initialized", err: client.ErrRepositoryNotExist{}, expected: true},
+		{name: "not accepted role", err: UnacceptedRoleError{Role: "targets/dev"}, expected: true},
+		{name: "pinned digest mismatch", err: DigestMismatchError{Tag: "tag"}, expected: true},
+		{name: "unsupported hash algorithm", err: fmt.Errorf("%w: md5", ErrUnsupportedHashAlgorithm), expected: true},
+		{name: "connection error", err: &net.OpError{Op: "dial", Err: &net.DNSError{}}, expected: false},
+		{name: "phase timeout", err: PhaseTimeoutError{Phase: NotaryPhase, Err: context.DeadlineExceeded}, expected: false},
+		{name: "registry rate limit", err: ErrRegistryRateLimited, expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, isDeterministicDenial(tt.err))
+		})
+	}
+}
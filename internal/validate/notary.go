@@ -17,12 +17,16 @@ limitations under the License.
 package validate
 
 import (
+	"context"
+	"fmt"
 	"github.com/docker/distribution/registry/client/auth"
 	"github.com/docker/distribution/registry/client/auth/challenge"
 	"github.com/docker/distribution/registry/client/transport"
 	"github.com/pkg/errors"
 	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/storage"
 	"github.com/theupdateframework/notary/trustpinning"
+	"github.com/theupdateframework/notary/tuf"
 	"github.com/theupdateframework/notary/tuf/data"
 	"net"
 	"net/http"
@@ -31,51 +35,279 @@ import (
 
 const (
 	NotaryDefaultTrustDir = "/tmp/.notary"
+
+	NotaryReleasesRole data.RoleName = "targets/releases"
 )
 
+// DefaultAcceptedRoles are used when NotaryConfig doesn't define AcceptedRoles.
+var DefaultAcceptedRoles = []data.RoleName{NotaryReleasesRole, data.CanonicalTargetsRole}
+
 type NotaryConfig struct {
 	Url string `json:"url"`
+	// FallbackUrls are tried in order when notary at Url can't be reached or it fails to answer,
+	// the endpoint which answered last is tried first by the next validations.
+	FallbackUrls []string `json:"fallbackUrls,omitempty"`
+	// AcceptedRoles limits the notary roles which are allowed to sign image targets.
+	// Roles are queried in order and the first role which signed the tag supplies the hash.
+	AcceptedRoles []data.RoleName `json:"acceptedRoles,omitempty"`
+	// RequestsPerSecond limits requests sent to notary across all validations, zero disables the limit.
+	RequestsPerSecond float64 `json:"requestsPerSecond,omitempty"`
+	// Burst is the maximum number of notary requests sent at once when the limit is enabled.
+	Burst int `json:"burst,omitempty"`
+	// TrustDir caches the TUF metadata of notary repositories, e.g. on an emptyDir or PVC which survives restarts.
+	// It overrides NotaryRepoFactory.TrustDir when it's set.
+	TrustDir string `json:"trustDir,omitempty"`
+	// ExpiredMetadataGrace accepts TUF metadata which expired less than the grace ago, e.g. a snapshot or timestamp
+	// which wasn't re-signed in time. Accepted expired metadata is reported in ImageValidationResult.Warnings.
+	ExpiredMetadataGrace time.Duration `json:"expiredMetadataGrace,omitempty"`
+	// MaxSignatureAge denies images whose timestamp or snapshot metadata was signed longer ago,
+	// so repositories which aren't re-signed anymore stop admitting images. Zero disables the check.
+	MaxSignatureAge time.Duration `json:"maxSignatureAge,omitempty"`
+	// MaxResponseBytes limits bodies of notary responses, DefaultMaxNotaryResponseBytes is used when it's not set.
+	MaxResponseBytes int64 `json:"maxResponseBytes,omitempty"`
+	// HarborURL is the external URL of Harbor, it enables the Harbor preset: the notary URL is derived from it
+	// when Url isn't set, tokens are requested from the Harbor token service and GUNs of Harbor repositories have no port.
+	HarborURL string `json:"harborURL,omitempty"`
+	// Username enables basic auth, the password is taken from PasswordFile when it's set.
+	Username     string `json:"username,omitempty"`
+	Password     string `json:"-"`
+	PasswordFile string `json:"passwordFile,omitempty"`
+	// TLS configures TLS of notary requests.
+	TLS NotaryTLSConfig `json:"tls,omitempty"`
+	// Hosts replace TLS, the credentials and the factory timeout for notary hosts, e.g. "notary.example.com:4443",
+	// so endpoints of namespaces and fallback endpoints can have their own settings.
+	Hosts map[string]NotaryHostConfig `json:"hosts,omitempty"`
+
+	// timings collect the request timings of a single validation, notary clients don't pass its context to requests.
+	timings *requestTimings
+}
+
+// String hides the password, so the config can be safely logged.
+func (c NotaryConfig) String() string {
+	password := ""
+	if c.Password != "" {
+		password = "<redacted>"
+	}
+	return fmt.Sprintf("{Url:%s FallbackUrls:%v AcceptedRoles:%v RequestsPerSecond:%v Burst:%d TrustDir:%s ExpiredMetadataGrace:%s MaxSignatureAge:%s MaxResponseBytes:%d HarborURL:%s Username:%s Password:%s PasswordFile:%s TLS:%+v Hosts:%v}",
+		c.Url, c.FallbackUrls, c.AcceptedRoles, c.RequestsPerSecond, c.Burst, c.TrustDir, c.ExpiredMetadataGrace, c.MaxSignatureAge, c.MaxResponseBytes, c.HarborURL, c.Username, password, c.PasswordFile, c.TLS, c.Hosts)
+}
+
+// urls returns Url followed by FallbackUrls.
+func (c NotaryConfig) urls() []string {
+	urls := []string{c.Url}
+	for _, u := range c.FallbackUrls {
+		if u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+func (c NotaryConfig) acceptedRoles() []data.RoleName {
+	if len(c.AcceptedRoles) == 0 {
+		return DefaultAcceptedRoles
+	}
+	return c.AcceptedRoles
+}
+
+func (c NotaryConfig) isRoleAccepted(role data.RoleName) bool {
+	for _, accepted := range c.acceptedRoles() {
+		if accepted == role {
+			return true
+		}
+	}
+	return false
+}
+
+// UnacceptedRoleError is returned when the image target was signed by a role which is not accepted.
+type UnacceptedRoleError struct {
+	Role data.RoleName
+}
+
+func (e UnacceptedRoleError) Error() string {
+	return fmt.Sprintf("image target signed by not accepted role: %s", e.Role)
+}
+
+func (e UnacceptedRoleError) Class() ErrorClassification {
+	return ClassPolicy
+}
+
+// NoTrustedTargetError is returned when none of the queried roles signed the tag,
+// errors for repositories which don't exist in notary are returned as is.
+type NoTrustedTargetError struct {
+	Tag   string
+	Roles []data.RoleName
+	Err   error
+}
+
+func (e NoTrustedTargetError) Error() string {
+	return fmt.Sprintf("tag %s is not signed by any of roles %v: %s", e.Tag, e.Roles, e.Err)
+}
+
+func (e NoTrustedTargetError) Unwrap() error {
+	return e.Err
 }
 
 type NotaryValidator struct {
 }
 
+// RepoFactory returns clients of notary repositories, the requests of a client are cancelled with ctx.
 type RepoFactory interface {
-	NewRepoClient(string, NotaryConfig) (client.Repository, error)
+	NewRepoClient(ctx context.Context, img string, c NotaryConfig) (client.Repository, error)
 }
 
 type NotaryRepoFactory struct {
 	Timeout time.Duration
+	// TrustDir caches the notary metadata, NotaryDefaultTrustDir is used when it's not set.
+	TrustDir string
+	// WrapTransport wraps the transport of all requests to notary, including pings and token requests,
+	// e.g. to add retries, metrics or headers. The wrapped transport keeps the proxy and TLS settings of the factory.
+	WrapTransport TransportWrapper
+	// transport is shared by all repository clients so connections to notary are reused.
+	transport *http.Transport
+	// transports are shared by repository clients of notary hosts with their own TLS settings or timeout.
+	transports *notaryTransportCache
+	// tokenHandlers are shared by all repository clients so tokens are reused until they expire.
+	tokenHandlers *tokenHandlerCache
 }
 
-func (f NotaryRepoFactory) NewRepoClient(img string, c NotaryConfig) (client.Repository, error) {
-	base := &http.Transport{
+// TransportWrapper returns the round tripper which sends requests through next.
+type TransportWrapper func(next http.RoundTripper) http.RoundTripper
+
+// NewNotaryRepoFactory returns the factory which reuses connections and tokens across repository clients.
+func NewNotaryRepoFactory(timeout time.Duration) NotaryRepoFactory {
+	return NotaryRepoFactory{
+		Timeout:       timeout,
+		transport:     newNotaryTransport(timeout),
+		transports:    newNotaryTransportCache(),
+		tokenHandlers: newTokenHandlerCache(),
+	}
+}
+
+func newNotaryTransport(timeout time.Duration) *http.Transport {
+	return &http.Transport{
 		Proxy:               http.ProxyFromEnvironment,
 		TLSHandshakeTimeout: 10 * time.Second,
 		DialContext: (&net.Dialer{
-			Timeout:   f.Timeout,
-			KeepAlive: f.Timeout,
+			Timeout:   timeout,
+			KeepAlive: timeout,
 		}).DialContext,
-		DisableKeepAlives: true,
-	}
-	th := auth.NewTokenHandlerWithOptions(auth.TokenHandlerOptions{
-		Transport: base,
-		Scopes: []auth.Scope{
-			auth.RepositoryScope{
-				Repository: img,
-				Actions:    []string{"pull"},
-			},
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 16,
+		IdleConnTimeout:     90 * time.Second,
+	}
+}
+
+func (f NotaryRepoFactory) NewRepoClient(ctx context.Context, img string, c NotaryConfig) (client.Repository, error) {
+	c, err := c.withHarborDefaults()
+	if err != nil {
+		return nil, err
+	}
+	rt, err := f.authTransport(ctx, img, c)
+	if err != nil {
+		return nil, err
+	}
+	// the notary client doesn't accept a context, so its requests get it from the transport
+	rt = contextTransport{next: rt, ctx: ctx}
+	trustDir := c.TrustDir
+	if trustDir == "" {
+		trustDir = f.TrustDir
+	}
+	if trustDir == "" {
+		trustDir = NotaryDefaultTrustDir
+	}
+	// the cache only saves downloads, so a broken one is dropped instead of failing validations
+	gun := data.GUN(img)
+	lock := trustDirLock(trustDir, gun)
+	lock.Lock()
+	err = removeCorruptedRoot(trustDir, gun)
+	lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	repo, err := client.NewFileCachedRepository(trustDir, gun, c.Url, rt, nil, trustpinning.TrustPinConfig{})
+	if err != nil {
+		return nil, err
+	}
+	locked := cacheLockedRepository{Repository: repo, lock: lock, metadataDir: gunMetadataDir(trustDir, gun)}
+	if c.ExpiredMetadataGrace <= 0 {
+		return locked, nil
+	}
+	cache, err := storage.NewFileStore(gunMetadataDir(trustDir, gun), "json")
+	if err != nil {
+		return nil, err
+	}
+	remote, err := storage.NewNotaryServerStore(c.Url, gun, rt)
+	if err != nil {
+		return nil, err
+	}
+	return &expiryGraceRepository{
+		Repository: locked,
+		grace:      c.ExpiredMetadataGrace,
+		load: func() (*tuf.Repo, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			return loadTUFRepoAllowExpired(gun, cache, remote)
 		},
+	}, nil
+}
+
+// contextTransport sends requests with ctx, so the requests of a repository client end with the validation.
+type contextTransport struct {
+	next http.RoundTripper
+	ctx  context.Context
+}
+
+func (t contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.next.RoundTrip(req.WithContext(t.ctx))
+}
+
+// authTransport pings notary and returns the transport which authenticates requests for the img repository.
+func (f NotaryRepoFactory) authTransport(ctx context.Context, img string, c NotaryConfig) (http.RoundTripper, error) {
+	c, err := c.withHarborDefaults()
+	if err != nil {
+		return nil, err
+	}
+	c, timeout := c.forHost(f.Timeout)
+	var base http.RoundTripper
+	base, err = f.transportFor(c.TLS, timeout)
+	if err != nil {
+		return nil, err
+	}
+	base = responseLimitTransport{next: base, limit: c.maxResponseBytes()}
+	if f.WrapTransport != nil {
+		base = f.WrapTransport(base)
+	}
+	creds, err := c.credentials()
+	if err != nil {
+		return nil, err
+	}
+	var username, password string
+	if creds != nil {
+		username, password = creds.Basic(nil)
+	}
+	key := newTokenHandlerKey(c.Url, img, username, password)
+	th := f.tokenHandlers.get(key, func() auth.AuthenticationHandler {
+		return newTokenHandler(base, creds, img)
 	})
 
 	// challenge manager expects to connect to /v2/ endpoint to obtain the challenges:
 	// https://github.com/notaryproject/notary/blob/master/vendor/github.com/docker/distribution/registry/client/auth/session.go#L75
 	u := c.Url + "/v2/"
-	pingClient := &http.Client{
-		Transport: base,
-		Timeout:   f.Timeout,
+	// the deadline is set on the context, because http.Client.Timeout cancels requests of wrapped transports
+	// with a different error than of *http.Transport
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	// token handlers are shared by validations, so token requests don't record the timings of this one
+	traced := base
+	if c.timings != nil {
+		traced = timingTransport{next: base, timings: c.timings}
 	}
-	req, err := http.NewRequest("GET", u, nil)
+	pingClient := &http.Client{Transport: traced}
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -90,13 +322,22 @@ func (f NotaryRepoFactory) NewRepoClient(img string, c NotaryConfig) (client.Rep
 		// If we didn't get a 2XX range or 401 status code, we're not talking to a notary server.
 		// The http client should be configured to handle redirects so at this point, 3XX is
 		// not a valid status code.
-		return nil, errors.Errorf("couln't correctly connect to notary, status code: %d", resp.StatusCode)
+		return nil, classify(ErrNotaryUnavailable, errors.Errorf("couln't correctly connect to notary, status code: %d", resp.StatusCode))
 	}
 
 	cm := challenge.NewSimpleManager()
 	if err = cm.AddResponse(resp); err != nil {
 		return nil, err
 	}
-	modifier := auth.NewAuthorizer(cm, th)
-	return client.NewFileCachedRepository(NotaryDefaultTrustDir, data.GUN(img), c.Url, transport.NewTransport(base, modifier), nil, trustpinning.TrustPinConfig{})
+	if c.HarborURL != "" {
+		if err = cm.AddResponse(c.harborChallenge(resp)); err != nil {
+			return nil, err
+		}
+	}
+	handlers := []auth.AuthenticationHandler{th}
+	if creds != nil {
+		handlers = append(handlers, auth.NewBasicHandler(creds))
+	}
+	modifier := auth.NewAuthorizer(cm, handlers...)
+	return transport.NewTransport(traced, modifier), nil
 }
@@ -0,0 +1,50 @@
+package validate
+
+import (
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/docker/distribution/registry/client/auth"
+	"github.com/pkg/errors"
+)
+
+// basicCredentials provides notary credentials for both basic auth and token endpoints.
+type basicCredentials struct {
+	username string
+	password string
+}
+
+var _ auth.CredentialStore = basicCredentials{}
+
+func (c basicCredentials) Basic(*url.URL) (string, string) {
+	return c.username, c.password
+}
+
+func (c basicCredentials) RefreshToken(*url.URL, string) string {
+	return ""
+}
+
+func (c basicCredentials) SetRefreshToken(*url.URL, string, string) {
+}
+
+// credentials returns nil when notary doesn't require authentication.
+// The password file is read on every call, so rotated secrets are picked up.
+func (c NotaryConfig) credentials() (auth.CredentialStore, error) {
+	if c.Username == "" {
+		return nil, nil
+	}
+	password := c.Password
+	if c.PasswordFile != "" {
+		content, err := os.ReadFile(c.PasswordFile)
+		if err != nil {
+			// the error describes only the path, it never contains the file content
+			return nil, errors.Wrap(err, "while reading notary password file")
+		}
+		password = strings.TrimSpace(string(content))
+	}
+	return basicCredentials{
+		username: c.Username,
+		password: password,
+	}, nil
+}
@@ -0,0 +1,125 @@
+package validate
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testNotaryUser     = "warden"
+	testNotaryPassword = "s3cr3t-p4ss"
+)
+
+// basicAuthNotary responds 401 to requests without the right credentials and records authorized paths.
+type basicAuthNotary struct {
+	mu         sync.Mutex
+	authorized []string
+}
+
+func (n *basicAuthNotary) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	user, password, ok := r.BasicAuth()
+	if !ok || user != testNotaryUser || password != testNotaryPassword {
+		w.Header().Set("WWW-Authenticate", `Basic realm="notary"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	n.mu.Lock()
+	n.authorized = append(n.authorized, r.URL.Path)
+	n.mu.Unlock()
+	w.WriteHeader(http.StatusNotFound)
+}
+
+func (n *basicAuthNotary) authorizedPaths() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]string{}, n.authorized...)
+}
+
+func TestNotaryRepoFactory_BasicAuth(t *testing.T) {
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte(testNotaryPassword+"\n"), 0600))
+
+	tests := []struct {
+		name           string
+		config         NotaryConfig
+		expectedErr    string
+		expectAuthPath bool
+	}{
+		{
+			name:           "password from config",
+			config:         NotaryConfig{Username: testNotaryUser, Password: testNotaryPassword},
+			expectedErr:    "does not have trust data for",
+			expectAuthPath: true,
+		},
+		{
+			name:           "password from file",
+			config:         NotaryConfig{Username: testNotaryUser, PasswordFile: passwordFile},
+			expectedErr:    "does not have trust data for",
+			expectAuthPath: true,
+		},
+		{
+			name:        "wrong password",
+			config:      NotaryConfig{Username: testNotaryUser, Password: "wrong-password"},
+			expectedErr: "server returned 401",
+		},
+		{
+			name:        "no credentials",
+			config:      NotaryConfig{},
+			expectedErr: "server returned 401",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//GIVEN
+			notary := &basicAuthNotary{}
+			srv := httptest.NewServer(notary)
+			defer srv.Close()
+			tt.config.Url = srv.URL
+
+			//WHEN
+			c, err := NewNotaryRepoFactory(0).NewRepoClient(context.TODO(), "eu.gcr.io/kyma-project/image", tt.config)
+			require.NoError(t, err)
+			_, err = c.GetTargetByName("tag")
+
+			//THEN
+			require.Error(t, err)
+			require.ErrorContains(t, err, tt.expectedErr)
+			require.NotContains(t, err.Error(), "wrong-password")
+			require.NotContains(t, err.Error(), testNotaryPassword)
+			if tt.expectAuthPath {
+				require.Contains(t, notary.authorizedPaths(), "/v2/eu.gcr.io/kyma-project/image/_trust/tuf/root.json")
+			}
+		})
+	}
+}
+
+func TestNotaryRepoFactory_MissingPasswordFile(t *testing.T) {
+	nc := NotaryConfig{Url: "https://notary", Username: testNotaryUser, PasswordFile: "/not/existing/file"}
+
+	_, err := NewNotaryRepoFactory(0).NewRepoClient(context.TODO(), "eu.gcr.io/kyma-project/image", nc)
+
+	require.ErrorContains(t, err, "while reading notary password file")
+}
+
+func TestNotaryConfig_StringHidesPassword(t *testing.T) {
+	nc := NotaryConfig{
+		Url:      "https://notary",
+		Username: testNotaryUser,
+		Password: testNotaryPassword,
+		Hosts:    map[string]NotaryHostConfig{"harbor.example.com": {Username: testNotaryUser, Password: testNotaryPassword}},
+	}
+
+	for _, s := range []string{fmt.Sprint(nc), fmt.Sprintf("%v", nc), fmt.Sprintf("%s", nc)} {
+		require.False(t, strings.Contains(s, testNotaryPassword))
+		require.Contains(t, s, "<redacted>")
+	}
+}
@@ -1,10 +1,14 @@
 package validate
 
 import (
+	"context"
 	"fmt"
 	"github.com/stretchr/testify/require"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -14,7 +18,7 @@ func TestNewReadOnlyRepo(t *testing.T) {
 		Url: "https://signing-dev.repositories.cloud.sap",
 	}
 	f := NotaryRepoFactory{}
-	c, err := f.NewRepoClient("europe-docker.pkg.dev/kyma-project/dev/bootstrap", nc)
+	c, err := f.NewRepoClient(context.TODO(), "europe-docker.pkg.dev/kyma-project/dev/bootstrap", nc)
 	require.NoError(t, err)
 
 	name, err := c.GetTargetByName("PR-6200")
@@ -42,7 +46,7 @@ func TestNotaryHTTPTimeout(t *testing.T) {
 	f := NotaryRepoFactory{Timeout: time.Second}
 
 	//WHEN
-	_, err := f.NewRepoClient("europe-docker.pkg.dev/kyma-project/dev/bootstrap", nc)
+	_, err := f.NewRepoClient(context.TODO(), "europe-docker.pkg.dev/kyma-project/dev/bootstrap", nc)
 
 	//THEn
 	require.Error(t, err)
@@ -50,3 +54,107 @@ func TestNotaryHTTPTimeout(t *testing.T) {
 	require.InDelta(t, timeout.Milliseconds(), time.Since(start).Milliseconds(), 100, "timeout duration is not respected")
 
 }
+
+func TestNotaryRepoClient_ContextCancelsRequests(t *testing.T) {
+	//GIVEN
+	srv := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path == "/v2/" {
+			return
+		}
+		select {
+		case <-request.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer srv.Close()
+	f := NewNotaryRepoFactory(0)
+	f.TrustDir = t.TempDir()
+	ctx, cancel := context.WithCancel(context.TODO())
+	c, err := f.NewRepoClient(ctx, "europe-docker.pkg.dev/kyma-project/dev/bootstrap", NotaryConfig{Url: srv.URL})
+	require.NoError(t, err)
+	start := time.Now()
+
+	//WHEN
+	time.AfterFunc(50*time.Millisecond, cancel)
+	_, err = c.GetTargetByName("tag")
+
+	//THEN
+	require.Error(t, err)
+	require.Less(t, time.Since(start), time.Second)
+}
+
+func TestNotaryRepoFactory_SharedTransport(t *testing.T) {
+	//GIVEN
+	var newConnections int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {}))
+	srv.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&newConnections, 1)
+		}
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	f := newTestTLSNotaryRepoFactory(srv)
+	nc := NotaryConfig{Url: srv.URL}
+
+	t.Run("sequential clients reuse the connection", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			_, err := f.NewRepoClient(context.TODO(), "europe-docker.pkg.dev/kyma-project/dev/bootstrap", nc)
+			require.NoError(t, err)
+		}
+		require.Equal(t, int32(1), atomic.LoadInt32(&newConnections))
+	})
+
+	t.Run("concurrent clients", func(t *testing.T) {
+		wg := sync.WaitGroup{}
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := f.NewRepoClient(context.TODO(), "europe-docker.pkg.dev/kyma-project/dev/bootstrap", nc)
+				require.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+	})
+}
+
+// BenchmarkNotaryRepoFactory compares creating repository clients with the shared transport
+// and with a new transport per client against a local TLS server. Example results:
+//
+//	BenchmarkNotaryRepoFactory/shared_transport         	   22383	     64612 ns/op
+//	BenchmarkNotaryRepoFactory/transport_per_client     	     692	   1854874 ns/op
+//
+// Every new transport has to make a new TLS handshake, which is much slower against a remote notary.
+func BenchmarkNotaryRepoFactory(b *testing.B) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {}))
+	defer srv.Close()
+	nc := NotaryConfig{Url: srv.URL}
+
+	b.Run("shared transport", func(b *testing.B) {
+		f := newTestTLSNotaryRepoFactory(srv)
+		for i := 0; i < b.N; i++ {
+			if _, err := f.NewRepoClient(context.TODO(), "europe-docker.pkg.dev/kyma-project/dev/bootstrap", nc); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("transport per client", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			f := newTestTLSNotaryRepoFactory(srv)
+			if _, err := f.NewRepoClient(context.TODO(), "europe-docker.pkg.dev/kyma-project/dev/bootstrap", nc); err != nil {
+				b.Fatal(err)
+			}
+			f.transport.CloseIdleConnections()
+		}
+	})
+}
+
+// newTestTLSNotaryRepoFactory returns the factory which trusts the test server certificate.
+func newTestTLSNotaryRepoFactory(srv *httptest.Server) NotaryRepoFactory {
+	f := NewNotaryRepoFactory(time.Second)
+	f.transport.TLSClientConfig = srv.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	return f
+}
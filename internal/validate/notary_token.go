@@ -0,0 +1,90 @@
+package validate
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"net/http"
+	"sync"
+
+	"github.com/docker/distribution/registry/client/auth"
+)
+
+// newTokenHandler handles the bearer token flow of notary placed behind a token service.
+// It parses the token service from the challenge, fetches the token using the credentials
+// and caches it until it expires. Requests wait for the token fetched by the first of them.
+func newTokenHandler(base http.RoundTripper, creds auth.CredentialStore, img string) auth.AuthenticationHandler {
+	return auth.NewTokenHandlerWithOptions(auth.TokenHandlerOptions{
+		Transport:   base,
+		Credentials: creds,
+		Scopes: []auth.Scope{
+			auth.RepositoryScope{
+				Repository: img,
+				Actions:    []string{"pull"},
+			},
+		},
+	})
+}
+
+// maxTokenHandlers bounds the cached token handlers, the least recently used handlers are evicted above it.
+const maxTokenHandlers = 1000
+
+// tokenHandlerKey identifies the token scope of the notary host and the repository, the credentials are hashed,
+// so the rotated password gets a new handler without the password being kept in the key.
+type tokenHandlerKey struct {
+	url         string
+	repository  string
+	credentials [sha256.Size]byte
+}
+
+func newTokenHandlerKey(url, repository, username, password string) tokenHandlerKey {
+	key := tokenHandlerKey{url: url, repository: repository}
+	if username != "" || password != "" {
+		key.credentials = sha256.Sum256([]byte(username + "\x00" + password))
+	}
+	return key
+}
+
+// tokenHandlerCache shares token handlers between repository clients of the same repository.
+// It's an LRU, so validations of many repositories don't grow it without bounds.
+type tokenHandlerCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	// lru holds *tokenHandlerEntry, the most recently used entry is at the front.
+	lru      *list.List
+	handlers map[tokenHandlerKey]*list.Element
+}
+
+type tokenHandlerEntry struct {
+	key     tokenHandlerKey
+	handler auth.AuthenticationHandler
+}
+
+func newTokenHandlerCache() *tokenHandlerCache {
+	return &tokenHandlerCache{
+		maxEntries: maxTokenHandlers,
+		lru:        list.New(),
+		handlers:   map[tokenHandlerKey]*list.Element{},
+	}
+}
+
+// get returns the cached handler or the new one, if the cache is nil the handler is not cached.
+func (c *tokenHandlerCache) get(key tokenHandlerKey, newHandler func() auth.AuthenticationHandler) auth.AuthenticationHandler {
+	if c == nil {
+		return newHandler()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.handlers[key]; ok {
+		c.lru.MoveToFront(elem)
+		return elem.Value.(*tokenHandlerEntry).handler
+	}
+	h := newHandler()
+	c.handlers[key] = c.lru.PushFront(&tokenHandlerEntry{key: key, handler: h})
+	if c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.handlers, oldest.Value.(*tokenHandlerEntry).key)
+	}
+	return h
+}
@@ -0,0 +1,170 @@
+package validate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/docker/distribution/registry/client/auth"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testToken = "test-jwt-token"
+)
+
+// fakeTokenService issues tokens for notary like Harbor's /service/token does.
+type fakeTokenService struct {
+	issued int32
+}
+
+func (s *fakeTokenService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	user, password, ok := r.BasicAuth()
+	if !ok || user != testNotaryUser || password != testNotaryPassword {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	atomic.AddInt32(&s.issued, 1)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"token":      testToken,
+		"expires_in": 300,
+	})
+}
+
+// fakeTokenNotary requires the bearer token issued by the token service.
+type fakeTokenNotary struct {
+	realm      string
+	authorized int32
+}
+
+func (n *fakeTokenNotary) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+testToken {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s",service="harbor-notary"`, n.realm))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	atomic.AddInt32(&n.authorized, 1)
+	w.WriteHeader(http.StatusNotFound)
+}
+
+func TestNotaryRepoFactory_TokenAuth(t *testing.T) {
+	//GIVEN
+	tokenService := &fakeTokenService{}
+	tokenSrv := httptest.NewServer(tokenService)
+	defer tokenSrv.Close()
+	notary := &fakeTokenNotary{realm: tokenSrv.URL + "/service/token"}
+	notarySrv := httptest.NewServer(notary)
+	defer notarySrv.Close()
+
+	nc := NotaryConfig{Url: notarySrv.URL, Username: testNotaryUser, Password: testNotaryPassword}
+	f := NewNotaryRepoFactory(0)
+
+	//WHEN
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, err := f.NewRepoClient(context.TODO(), "eu.gcr.io/kyma-project/image", nc)
+			require.NoError(t, err)
+			_, err = c.GetTargetByName("tag")
+			require.ErrorContains(t, err, "does not have trust data for")
+		}()
+	}
+	wg.Wait()
+
+	//THEN
+	require.Positive(t, atomic.LoadInt32(&notary.authorized))
+	require.Equal(t, int32(1), atomic.LoadInt32(&tokenService.issued), "token should be fetched once and reused")
+}
+
+func TestNotaryRepoFactory_TokenAuth_WrongCredentials(t *testing.T) {
+	//GIVEN
+	tokenSrv := httptest.NewServer(&fakeTokenService{})
+	defer tokenSrv.Close()
+	notarySrv := httptest.NewServer(&fakeTokenNotary{realm: tokenSrv.URL + "/service/token"})
+	defer notarySrv.Close()
+
+	nc := NotaryConfig{Url: notarySrv.URL, Username: testNotaryUser, Password: "wrong-password"}
+
+	//WHEN
+	c, err := NewNotaryRepoFactory(0).NewRepoClient(context.TODO(), "eu.gcr.io/kyma-project/image", nc)
+	require.NoError(t, err)
+	_, err = c.GetTargetByName("tag")
+
+	//THEN
+	require.Error(t, err)
+	require.NotContains(t, err.Error(), "wrong-password")
+}
+
+// fakeAuthHandler tells the token handlers of the cache apart.
+type fakeAuthHandler struct {
+	id int
+}
+
+func (h *fakeAuthHandler) Scheme() string {
+	return "bearer"
+}
+
+func (h *fakeAuthHandler) AuthorizeRequest(*http.Request, map[string]string) error {
+	return nil
+}
+
+func Test_tokenHandlerCache(t *testing.T) {
+	created := 0
+	newHandler := func() auth.AuthenticationHandler {
+		created++
+		return &fakeAuthHandler{id: created}
+	}
+
+	t.Run("handler is shared for the repository", func(t *testing.T) {
+		//GIVEN
+		c := newTokenHandlerCache()
+		key := newTokenHandlerKey("https://notary.io", "eu.gcr.io/kyma-project/image", testNotaryUser, testNotaryPassword)
+		first := c.get(key, newHandler)
+
+		//WHEN
+		second := c.get(newTokenHandlerKey("https://notary.io", "eu.gcr.io/kyma-project/image", testNotaryUser, testNotaryPassword), newHandler)
+
+		//THEN
+		require.Same(t, first, second)
+	})
+
+	t.Run("rotated password gets a new handler", func(t *testing.T) {
+		//GIVEN
+		c := newTokenHandlerCache()
+		first := c.get(newTokenHandlerKey("https://notary.io", "eu.gcr.io/kyma-project/image", testNotaryUser, testNotaryPassword), newHandler)
+
+		//WHEN
+		key := newTokenHandlerKey("https://notary.io", "eu.gcr.io/kyma-project/image", testNotaryUser, "rotated-password")
+		second := c.get(key, newHandler)
+
+		//THEN
+		require.NotSame(t, first, second)
+		require.NotContains(t, fmt.Sprintf("%+v", key), "rotated-password")
+	})
+
+	t.Run("least recently used handler is evicted", func(t *testing.T) {
+		//GIVEN
+		c := newTokenHandlerCache()
+		c.maxEntries = 2
+		firstKey := newTokenHandlerKey("https://notary.io", "eu.gcr.io/kyma-project/first", "", "")
+		secondKey := newTokenHandlerKey("https://notary.io", "eu.gcr.io/kyma-project/second", "", "")
+		first := c.get(firstKey, newHandler)
+		second := c.get(secondKey, newHandler)
+		require.Same(t, first, c.get(firstKey, newHandler))
+
+		//WHEN
+		c.get(newTokenHandlerKey("https://notary.io", "eu.gcr.io/kyma-project/third", "", ""), newHandler)
+
+		//THEN
+		require.Equal(t, 2, c.lru.Len())
+		require.Same(t, first, c.get(firstKey, newHandler))
+		require.NotSame(t, second, c.get(secondKey, newHandler))
+	})
+}
@@ -0,0 +1,213 @@
+package validate
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// NotaryTLSConfig configures TLS of notary requests, the zero value trusts the system roots.
+type NotaryTLSConfig struct {
+	// CAFile is the PEM bundle of CAs which are trusted instead of the system roots, e.g. a private CA.
+	CAFile string `json:"caFile,omitempty"`
+	// CertFile and KeyFile are the client certificate presented to notary.
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+	// InsecureSkipVerify accepts any notary certificate, it's meant only for tests.
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+}
+
+func (c NotaryTLSConfig) isZero() bool {
+	return c == NotaryTLSConfig{}
+}
+
+// tlsConfig reads the files, so the TLS config is built once per transport.
+func (c NotaryTLSConfig) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "while reading notary CA file")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("notary CA file %s has no PEM certificates", c.CAFile)
+		}
+		config.RootCAs = pool
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "while loading notary client certificate")
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// NotaryHostConfig replaces the global TLS settings, credentials and the timeout of the repository factory
+// for a notary host, fields which aren't set keep the global values.
+type NotaryHostConfig struct {
+	TLS *NotaryTLSConfig `json:"tls,omitempty"`
+	// Username replaces the global credentials together with Password or PasswordFile.
+	Username     string `json:"username,omitempty"`
+	Password     string `json:"-"`
+	PasswordFile string `json:"passwordFile,omitempty"`
+	// Timeout replaces NotaryRepoFactory.Timeout, which bounds connecting to notary and its ping.
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+// String hides the password, so the config can be safely logged.
+func (c NotaryHostConfig) String() string {
+	password := ""
+	if c.Password != "" {
+		password = "<redacted>"
+	}
+	return fmt.Sprintf("{TLS:%+v Username:%s Password:%s PasswordFile:%s Timeout:%s}",
+		c.TLS, c.Username, password, c.PasswordFile, c.Timeout)
+}
+
+// forHost returns the config with the settings of the host of Url applied and the timeout of the host.
+// Hosts match case-insensitively with the port, or without it when no host with the port is configured.
+func (c NotaryConfig) forHost(timeout time.Duration) (NotaryConfig, time.Duration) {
+	u, err := url.Parse(c.Url)
+	if err != nil || len(c.Hosts) == 0 {
+		return c, timeout
+	}
+	h, ok := c.hostConfig(u.Host)
+	if !ok {
+		h, ok = c.hostConfig(u.Hostname())
+	}
+	if !ok {
+		return c, timeout
+	}
+	if h.TLS != nil {
+		c.TLS = *h.TLS
+	}
+	if h.Username != "" {
+		c.Username, c.Password, c.PasswordFile = h.Username, h.Password, h.PasswordFile
+	}
+	if h.Timeout > 0 {
+		timeout = h.Timeout
+	}
+	return c, timeout
+}
+
+func (c NotaryConfig) hostConfig(host string) (NotaryHostConfig, bool) {
+	for configured, h := range c.Hosts {
+		if strings.EqualFold(configured, host) {
+			return h, true
+		}
+	}
+	return NotaryHostConfig{}, false
+}
+
+// validateNotaryTLS and validateNotaryHosts reject settings which can't be used to connect to notary.
+func validateNotaryTLS(c NotaryTLSConfig) error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return errors.New("notary client certificate and key files must be set together")
+	}
+	return nil
+}
+
+func validateNotaryHosts(hosts map[string]NotaryHostConfig) []error {
+	names := make([]string, 0, len(hosts))
+	for host := range hosts {
+		names = append(names, host)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, host := range names {
+		h := hosts[host]
+		if err := validateRegistryHost(host); err != nil {
+			errs = append(errs, fmt.Errorf("notary host: %w", err))
+		}
+		if h.TLS != nil {
+			if err := validateNotaryTLS(*h.TLS); err != nil {
+				errs = append(errs, fmt.Errorf("notary host %s: %w", host, err))
+			}
+		}
+		if h.Password != "" && h.PasswordFile != "" {
+			errs = append(errs, fmt.Errorf("notary host %s: password and password file are mutually exclusive", host))
+		}
+		if (h.Password != "" || h.PasswordFile != "") && h.Username == "" {
+			errs = append(errs, fmt.Errorf("notary host %s: password requires the username", host))
+		}
+		if h.Timeout < 0 {
+			errs = append(errs, fmt.Errorf("notary host %s: timeout must not be negative", host))
+		}
+	}
+	return errs
+}
+
+// notaryTransportKey identifies transports with the same TLS settings and timeout.
+type notaryTransportKey struct {
+	tls     NotaryTLSConfig
+	timeout time.Duration
+}
+
+// notaryTransportCache shares transports of notary hosts with their own settings,
+// so their connections are reused like the ones of the default transport.
+type notaryTransportCache struct {
+	mu         sync.Mutex
+	transports map[notaryTransportKey]*http.Transport
+}
+
+func newNotaryTransportCache() *notaryTransportCache {
+	return &notaryTransportCache{transports: map[notaryTransportKey]*http.Transport{}}
+}
+
+// transportFor returns the transport for the TLS settings and the timeout, the default transport of the factory
+// is used when the host has no settings of its own. Factories which weren't created by NewNotaryRepoFactory
+// get the transport used only by a single client.
+func (f NotaryRepoFactory) transportFor(c NotaryTLSConfig, timeout time.Duration) (*http.Transport, error) {
+	if c.isZero() && timeout == f.Timeout && f.transport != nil {
+		return f.transport, nil
+	}
+	newTransport := func() (*http.Transport, error) {
+		t := newNotaryTransport(timeout)
+		if !c.isZero() {
+			config, err := c.tlsConfig()
+			if err != nil {
+				return nil, err
+			}
+			t.TLSClientConfig = config
+		}
+		return t, nil
+	}
+	if f.transports == nil {
+		t, err := newTransport()
+		if err != nil {
+			return nil, err
+		}
+		t.DisableKeepAlives = true
+		return t, nil
+	}
+
+	key := notaryTransportKey{tls: c, timeout: timeout}
+	f.transports.mu.Lock()
+	defer f.transports.mu.Unlock()
+	if t, ok := f.transports.transports[key]; ok {
+		return t, nil
+	}
+	// transports which failed, e.g. for a missing CA file, aren't cached, so fixed files are picked up
+	t, err := newTransport()
+	if err != nil {
+		return nil, err
+	}
+	f.transports.transports[key] = t
+	return t, nil
+}
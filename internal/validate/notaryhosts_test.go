@@ -0,0 +1,237 @@
+package validate
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTLSNotaryServer starts the notary stub with the certificate of its own CA and returns the path of the CA file.
+func newTLSNotaryServer(t *testing.T, name string) (*httptest.Server, string) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name + " CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	require.NoError(t, err)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	caFile := filepath.Join(t.TempDir(), name+"-ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), 0o600))
+	return srv, caFile
+}
+
+func TestNotaryRepoFactory_HostTLS(t *testing.T) {
+	harbor, harborCA := newTLSNotaryServer(t, "harbor")
+	kyma, kymaCA := newTLSNotaryServer(t, "kyma")
+	harborHost := strings.TrimPrefix(harbor.URL, "https://")
+	kymaHost := strings.TrimPrefix(kyma.URL, "https://")
+
+	unknownAuthority := "certificate signed by unknown authority"
+	tests := []struct {
+		name              string
+		config            NotaryConfig
+		expectedHarborErr string
+		expectedKymaErr   string
+	}{
+		{
+			name: "each host with its own CA",
+			config: NotaryConfig{Hosts: map[string]NotaryHostConfig{
+				harborHost: {TLS: &NotaryTLSConfig{CAFile: harborCA}},
+				kymaHost:   {TLS: &NotaryTLSConfig{CAFile: kymaCA}},
+			}},
+		},
+		{
+			name: "host CA and the global CA",
+			config: NotaryConfig{
+				TLS:   NotaryTLSConfig{CAFile: kymaCA},
+				Hosts: map[string]NotaryHostConfig{harborHost: {TLS: &NotaryTLSConfig{CAFile: harborCA}}},
+			},
+		},
+		{
+			name:            "global CA of one host",
+			config:          NotaryConfig{TLS: NotaryTLSConfig{CAFile: harborCA}},
+			expectedKymaErr: unknownAuthority,
+		},
+		{
+			name: "swapped CAs",
+			config: NotaryConfig{Hosts: map[string]NotaryHostConfig{
+				harborHost: {TLS: &NotaryTLSConfig{CAFile: kymaCA}},
+				kymaHost:   {TLS: &NotaryTLSConfig{CAFile: harborCA}},
+			}},
+			expectedHarborErr: unknownAuthority,
+			expectedKymaErr:   unknownAuthority,
+		},
+		{
+			name:              "system roots",
+			config:            NotaryConfig{},
+			expectedHarborErr: unknownAuthority,
+			expectedKymaErr:   unknownAuthority,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//GIVEN
+			f := NewNotaryRepoFactory(time.Second)
+			harborConfig, kymaConfig := tt.config, tt.config
+			harborConfig.Url, kymaConfig.Url = harbor.URL, kyma.URL
+
+			//WHEN
+			_, harborErr := f.authTransport(context.TODO(), "eu.gcr.io/kyma-project/app", harborConfig)
+			_, kymaErr := f.authTransport(context.TODO(), "eu.gcr.io/kyma-project/app", kymaConfig)
+
+			//THEN
+			for _, check := range []struct {
+				err      error
+				expected string
+			}{
+				{harborErr, tt.expectedHarborErr},
+				{kymaErr, tt.expectedKymaErr},
+			} {
+				if check.expected == "" {
+					require.NoError(t, check.err)
+					continue
+				}
+				require.ErrorContains(t, check.err, check.expected)
+			}
+		})
+	}
+}
+
+func TestNotaryConfig_forHost(t *testing.T) {
+	harborTLS := &NotaryTLSConfig{CAFile: "/etc/notary/harbor-ca.pem"}
+	global := NotaryConfig{
+		Username:     "warden",
+		PasswordFile: "/etc/notary/password",
+		TLS:          NotaryTLSConfig{CAFile: "/etc/notary/ca.pem"},
+		Hosts: map[string]NotaryHostConfig{
+			"harbor.example.com:4443":    {TLS: harborTLS, Username: "robot$warden", PasswordFile: "/etc/harbor/password", Timeout: time.Minute},
+			"Notary.Partner.Example.com": {Timeout: 2 * time.Minute},
+		},
+	}
+	tests := []struct {
+		name            string
+		url             string
+		expectedTLS     NotaryTLSConfig
+		expectedUser    string
+		expectedFile    string
+		expectedTimeout time.Duration
+	}{
+		{
+			name:            "host with port",
+			url:             "https://harbor.example.com:4443",
+			expectedTLS:     *harborTLS,
+			expectedUser:    "robot$warden",
+			expectedFile:    "/etc/harbor/password",
+			expectedTimeout: time.Minute,
+		},
+		{
+			name:            "host on another port",
+			url:             "https://harbor.example.com",
+			expectedTLS:     global.TLS,
+			expectedUser:    "warden",
+			expectedFile:    "/etc/notary/password",
+			expectedTimeout: time.Second,
+		},
+		{
+			name:            "host without port keeps the global settings it doesn't set",
+			url:             "https://notary.partner.example.com:443",
+			expectedTLS:     global.TLS,
+			expectedUser:    "warden",
+			expectedFile:    "/etc/notary/password",
+			expectedTimeout: 2 * time.Minute,
+		},
+		{
+			name:            "unknown host",
+			url:             "https://notary.example.com",
+			expectedTLS:     global.TLS,
+			expectedUser:    "warden",
+			expectedFile:    "/etc/notary/password",
+			expectedTimeout: time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := global
+			config.Url = tt.url
+
+			c, timeout := config.forHost(time.Second)
+
+			require.Equal(t, tt.expectedTLS, c.TLS)
+			require.Equal(t, tt.expectedUser, c.Username)
+			require.Equal(t, tt.expectedFile, c.PasswordFile)
+			require.Equal(t, tt.expectedTimeout, timeout)
+		})
+	}
+}
+
+func TestNotaryRepoFactory_HostTransports(t *testing.T) {
+	f := NewNotaryRepoFactory(time.Second)
+	_, caFile := newTLSNotaryServer(t, "harbor")
+
+	t.Run("hosts without their own settings share the default transport", func(t *testing.T) {
+		rt, err := f.transportFor(NotaryTLSConfig{}, time.Second)
+
+		require.NoError(t, err)
+		require.Same(t, f.transport, rt)
+	})
+
+	t.Run("hosts with the same settings share the transport", func(t *testing.T) {
+		first, err := f.transportFor(NotaryTLSConfig{CAFile: caFile}, time.Second)
+		require.NoError(t, err)
+		second, err := f.transportFor(NotaryTLSConfig{CAFile: caFile}, time.Second)
+		require.NoError(t, err)
+
+		require.Same(t, first, second)
+		require.NotSame(t, f.transport, first)
+	})
+
+	t.Run("missing CA file", func(t *testing.T) {
+		_, err := f.transportFor(NotaryTLSConfig{CAFile: "/not/existing/ca.pem"}, time.Second)
+
+		require.ErrorContains(t, err, "while reading notary CA file")
+	})
+}
@@ -0,0 +1,173 @@
+package validate
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/utils/clock"
+)
+
+// Option customizes the image validator created by NewImageValidator.
+type Option func(*validatorOptions)
+
+// validatorOptions are applied over ServiceConfig, options not set keep the behaviour defined by the config.
+type validatorOptions struct {
+	repoFactory       RepoFactory
+	clock             clock.Clock
+	negativeCacheTTL  *time.Duration
+	metricsRegisterer prometheus.Registerer
+	keychains         []RegistryKeychain
+	platform          string
+	wrapTransport     TransportWrapper
+	wrapRegistry      TransportWrapper
+	backends          map[string]VerificationBackend
+	decisionHook      *decisionHook
+}
+
+// WithRepoFactory sets the factory of notary clients, NotaryRepoFactory with ServiceConfig.NotaryTimeout is used by default.
+func WithRepoFactory(f RepoFactory) Option {
+	return func(o *validatorOptions) {
+		o.repoFactory = f
+	}
+}
+
+// WithCache enables the cache of deterministic denials with the given TTL,
+// DefaultNegativeCacheTTL is used when ttl is zero.
+func WithCache(ttl time.Duration) Option {
+	return func(o *validatorOptions) {
+		o.negativeCacheTTL = &ttl
+	}
+}
+
+// WithMetrics registers notary and registry metrics with reg instead of ServiceConfig.MetricsRegisterer.
+func WithMetrics(reg prometheus.Registerer) Option {
+	return func(o *validatorOptions) {
+		o.metricsRegisterer = reg
+	}
+}
+
+// WithKeychain adds registry keychains after the ones from ServiceConfig.RegistryKeychains.
+func WithKeychain(keychains ...RegistryKeychain) Option {
+	return func(o *validatorOptions) {
+		o.keychains = append(o.keychains, keychains...)
+	}
+}
+
+// WithClock sets the clock used by the notary rate limiter, the denial cache and image exceptions.
+func WithClock(clk clock.Clock) Option {
+	return func(o *validatorOptions) {
+		o.clock = clk
+	}
+}
+
+// WithPlatform sets the platform whose image is validated when the reference points to an index,
+// it overrides ServiceConfig.Platform.
+func WithPlatform(platform string) Option {
+	return func(o *validatorOptions) {
+		o.platform = platform
+	}
+}
+
+// WithRoundTripper wraps the transports of all requests sent to notary and registries,
+// the wrapped transports keep their proxy and TLS settings. The notary transport is wrapped only when
+// the repository factory is NotaryRepoFactory.
+func WithRoundTripper(wrap TransportWrapper) Option {
+	return func(o *validatorOptions) {
+		o.wrapTransport = wrap
+	}
+}
+
+// WithRegistryTransport wraps the transport of registry requests, it's called before the WithRoundTripper wrapper.
+func WithRegistryTransport(wrap TransportWrapper) Option {
+	return func(o *validatorOptions) {
+		o.wrapRegistry = wrap
+	}
+}
+
+// WithBackend adds the backend which ServiceConfig.VerificationChain refers to by the name,
+// the last backend added with the name wins.
+func WithBackend(name string, backend VerificationBackend) Option {
+	return func(o *validatorOptions) {
+		if o.backends == nil {
+			o.backends = map[string]VerificationBackend{}
+		}
+		o.backends[name] = backend
+	}
+}
+
+// WithDecisionHook calls hook with the decision of every ValidateDetailed and Validate call, e.g. to stream
+// the decisions to a SIEM. The hook is called asynchronously one event at a time, events are dropped when
+// DefaultDecisionHookQueueSize events are waiting and panics of the hook are recovered, both are counted.
+// Validators created with the same option share the hook queue.
+func WithDecisionHook(hook func(ValidationEvent)) Option {
+	h := newDecisionHook(hook, DefaultDecisionHookQueueSize)
+	return func(o *validatorOptions) {
+		o.decisionHook = h
+	}
+}
+
+func newValidatorOptions(sc *ServiceConfig, opts []Option) validatorOptions {
+	o := validatorOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.repoFactory == nil {
+		o.repoFactory = NewNotaryRepoFactory(sc.NotaryTimeout)
+	}
+	if o.wrapTransport != nil {
+		switch f := o.repoFactory.(type) {
+		case NotaryRepoFactory:
+			f.WrapTransport = chainTransportWrappers(f.WrapTransport, o.wrapTransport)
+			o.repoFactory = f
+		case *NotaryRepoFactory:
+			wrapped := *f
+			wrapped.WrapTransport = chainTransportWrappers(f.WrapTransport, o.wrapTransport)
+			o.repoFactory = wrapped
+		}
+	}
+	if o.clock == nil {
+		o.clock = clock.RealClock{}
+	}
+	return o
+}
+
+// registryTransport returns the transport of registry requests, nil means remote.DefaultTransport.
+func (o validatorOptions) registryTransport() http.RoundTripper {
+	if o.wrapTransport == nil && o.wrapRegistry == nil {
+		return nil
+	}
+	return chainTransportWrappers(o.wrapRegistry, o.wrapTransport)(remote.DefaultTransport)
+}
+
+// chainTransportWrappers returns the wrapper which applies inner first, nil wrappers are skipped.
+func chainTransportWrappers(inner, outer TransportWrapper) TransportWrapper {
+	return func(next http.RoundTripper) http.RoundTripper {
+		if inner != nil {
+			next = inner(next)
+		}
+		if outer != nil {
+			next = outer(next)
+		}
+		return next
+	}
+}
+
+// apply returns the copy of sc with the options applied.
+func (o validatorOptions) apply(sc ServiceConfig) ServiceConfig {
+	if o.negativeCacheTTL != nil {
+		sc.NegativeCacheTTL = *o.negativeCacheTTL
+		sc.DisableNegativeCache = false
+	}
+	if o.metricsRegisterer != nil {
+		sc.MetricsRegisterer = o.metricsRegisterer
+	}
+	if o.platform != "" {
+		sc.Platform = o.platform
+	}
+	if len(o.keychains) > 0 {
+		sc.RegistryKeychains = append(append([]RegistryKeychain{}, sc.RegistryKeychains...), o.keychains...)
+	}
+	return sc
+}
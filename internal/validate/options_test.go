@@ -0,0 +1,174 @@
+package validate
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+	"k8s.io/utils/clock"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestNewImageValidator_Options(t *testing.T) {
+	image := "eu.gcr.io/kyma-project/unsigned:tag"
+	countingFactory := func(calls *int32) RepoFactory {
+		f := func(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+			atomic.AddInt32(calls, 1)
+			return nil, client.ErrNoSuchTarget(name)
+		}
+		return MockNotaryRepoFactory{GetTargetByNameFunc: &f}
+	}
+
+	t.Run("defaults are derived from the config", func(t *testing.T) {
+		//GIVEN
+		keychain := RegistryKeychain{Hosts: []string{"eu.gcr.io"}, Keychain: authn.DefaultKeychain}
+		sc := &ServiceConfig{
+			NotaryConfig:      NotaryConfig{Url: "https://notary"},
+			NotaryTimeout:     3 * time.Second,
+			RegistryKeychains: []RegistryKeychain{keychain},
+		}
+
+		//WHEN
+		s := newTestImageValidator(t, sc)
+
+		//THEN
+		factory, ok := s.RepoFactory.(NotaryRepoFactory)
+		require.True(t, ok)
+		require.Equal(t, 3*time.Second, factory.Timeout)
+		require.Equal(t, clock.RealClock{}, s.clock)
+		require.Equal(t, []RegistryKeychain{keychain}, s.RegistryKeychains)
+		require.NotNil(t, s.negativeCache)
+		require.NotNil(t, s.flights)
+		require.NotNil(t, s.metrics)
+	})
+
+	t.Run("clock and cache compose", func(t *testing.T) {
+		//GIVEN
+		var calls int32
+		clk := testingclock.NewFakeClock(time.Now())
+		sc := &ServiceConfig{DisableNegativeCache: true}
+		v := newTestImageValidator(t, sc,
+			WithRepoFactory(countingFactory(&calls)),
+			WithClock(clk),
+			WithCache(time.Minute))
+
+		//WHEN
+		_ = v.Validate(context.TODO(), image)
+		_ = v.Validate(context.TODO(), image)
+		clk.Step(time.Minute)
+		_ = v.Validate(context.TODO(), image)
+
+		//THEN
+		require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("last repo factory wins", func(t *testing.T) {
+		//GIVEN
+		var first, second int32
+		v := newTestImageValidator(t, &ServiceConfig{},
+			WithRepoFactory(countingFactory(&first)),
+			WithRepoFactory(countingFactory(&second)))
+
+		//WHEN
+		err := v.Validate(context.TODO(), image)
+
+		//THEN
+		require.Error(t, err)
+		require.Zero(t, atomic.LoadInt32(&first))
+		require.Equal(t, int32(1), atomic.LoadInt32(&second))
+	})
+
+	t.Run("keychains are appended to the config", func(t *testing.T) {
+		//GIVEN
+		configured := RegistryKeychain{Hosts: []string{"eu.gcr.io"}, Keychain: authn.DefaultKeychain}
+		first := RegistryKeychain{Hosts: []string{"ghcr.io"}, Keychain: authn.DefaultKeychain}
+		second := RegistryKeychain{Hosts: []string{"*.azurecr.io"}, Keychain: authn.DefaultKeychain}
+		sc := &ServiceConfig{NotaryConfig: NotaryConfig{Url: "https://notary"}, RegistryKeychains: []RegistryKeychain{configured}}
+
+		//WHEN
+		s := newTestImageValidator(t, sc, WithKeychain(first), WithKeychain(second))
+
+		//THEN
+		require.Equal(t, []RegistryKeychain{configured, first, second}, s.RegistryKeychains)
+		require.Equal(t, []RegistryKeychain{configured}, sc.RegistryKeychains)
+	})
+
+	t.Run("metrics are registered with the given registerer", func(t *testing.T) {
+		//GIVEN
+		var calls int32
+		reg := prometheus.NewRegistry()
+		s := newTestImageValidator(t, &ServiceConfig{},
+			WithRepoFactory(countingFactory(&calls)),
+			WithMetrics(reg))
+
+		//WHEN
+		_ = s.Validate(context.TODO(), image)
+
+		//THEN
+		require.Equal(t, float64(1), testutil.ToFloat64(s.metrics.notaryErrors.WithLabelValues(otherHostLabel, "not_found")))
+		families, err := reg.Gather()
+		require.NoError(t, err)
+		require.NotEmpty(t, families)
+	})
+
+	t.Run("repository factory option uses the factory", func(t *testing.T) {
+		//GIVEN
+		var calls int32
+		v, err := NewImageValidator(&ServiceConfig{}, WithRepoFactory(countingFactory(&calls)))
+		require.NoError(t, err)
+
+		//WHEN
+		_ = v.Validate(context.TODO(), image)
+
+		//THEN
+		require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("round tripper wraps the registry transport and the notary factory", func(t *testing.T) {
+		//GIVEN
+		var order []string
+		wrapper := func(label string) TransportWrapper {
+			return func(next http.RoundTripper) http.RoundTripper {
+				order = append(order, label)
+				return next
+			}
+		}
+
+		//WHEN
+		s := newTestImageValidator(t, &ServiceConfig{NotaryConfig: NotaryConfig{Url: "https://notary"}},
+			WithRepoFactory(&NotaryRepoFactory{Timeout: time.Second}),
+			WithRoundTripper(wrapper("all")),
+			WithRegistryTransport(wrapper("registry")))
+
+		//THEN
+		require.NotNil(t, s.registryTransport)
+		require.Equal(t, []string{"registry", "all"}, order)
+		factory, ok := s.RepoFactory.(NotaryRepoFactory)
+		require.True(t, ok)
+		require.NotNil(t, factory.WrapTransport)
+	})
+
+	t.Run("registry transport is the default without wrappers", func(t *testing.T) {
+		//WHEN
+		s := newTestImageValidator(t, &ServiceConfig{NotaryConfig: NotaryConfig{Url: "https://notary"}})
+
+		//THEN
+		require.Nil(t, s.registryTransport)
+		require.Nil(t, s.RepoFactory.(NotaryRepoFactory).WrapTransport)
+	})
+}
+
+// newTestImageValidator fails the test when the validator can't be created.
+func newTestImageValidator(t *testing.T, sc *ServiceConfig, opts ...Option) *notaryService {
+	v, err := NewImageValidator(sc, opts...)
+	require.NoError(t, err)
+	return v.(*notaryService)
+}
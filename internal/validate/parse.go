@@ -1,6 +1,13 @@
 package validate
 
-import "strings"
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+)
 
 const (
 	allowedRegistriesSeparator = ","
@@ -17,3 +24,215 @@ func ParseAllowedRegistries(registries string) []string {
 
 	return registriesList
 }
+
+const (
+	digestDelim          = "@"
+	digestAlgorithmDelim = ":"
+	defaultTag           = "latest"
+)
+
+// dockerHubAliases are the hosts which serve Docker Hub images.
+var dockerHubAliases = map[string]bool{
+	"index.docker.io":      true,
+	"registry-1.docker.io": true,
+}
+
+var (
+	errMalformedImageName   = errors.New("image name is not formatted correctly")
+	errMalformedImageDigest = errors.New("image digest is not formatted correctly")
+)
+
+// imageRef is the image reference split into parts used by the validation.
+type imageRef struct {
+	repo string
+	// tag is empty for references pinned without a tag, only digest targets verify them.
+	tag string
+	// digestAlgorithm and digest are set only for pinned references.
+	digestAlgorithm string
+	digest          []byte
+}
+
+func (r imageRef) isPinned() bool {
+	return r.digestAlgorithm != ""
+}
+
+// isDigestOnly is true for references pinned without a tag.
+func (r imageRef) isDigestOnly() bool {
+	return r.isPinned() && r.tag == ""
+}
+
+// pinnedDigest returns the pinned digest in the algorithm:hex form.
+func (r imageRef) pinnedDigest() string {
+	return r.digestAlgorithm + digestAlgorithmDelim + hex.EncodeToString(r.digest)
+}
+
+func (r imageRef) tagged() string {
+	return r.repo + tagDelim + r.tag
+}
+
+// String returns the normalized reference, references which are validated the same way have the same form.
+func (r imageRef) String() string {
+	if !r.isPinned() {
+		return r.tagged()
+	}
+	if r.isDigestOnly() {
+		return r.repo + digestDelim + r.pinnedDigest()
+	}
+	return r.tagged() + digestDelim + r.pinnedDigest()
+}
+
+// ImageRef is the image reference split into its canonical parts, see ParseImageRef.
+type ImageRef struct {
+	// Registry is the registry host with the optional port, docker.io for Docker Hub images.
+	Registry string
+	// Repository is the repository path without the registry host,
+	// official Docker Hub images have the library/ namespace.
+	Repository string
+	// Tag is latest when the reference has neither the tag nor the digest.
+	Tag string
+	// Digest is in the algorithm:hex form, it's empty when the reference isn't pinned.
+	Digest string
+
+	// name is the repository as written in the reference.
+	name            string
+	explicitTag     bool
+	digestAlgorithm string
+	digest          []byte
+}
+
+// String returns the canonical form registry/repository[:tag][@digest],
+// references which name the same image in different ways have the same form.
+func (r ImageRef) String() string {
+	s := r.Registry + "/" + r.Repository
+	if r.Tag != "" {
+		s += tagDelim + r.Tag
+	}
+	if r.Digest != "" {
+		s += digestDelim + r.Digest
+	}
+	return s
+}
+
+// ParseImageRef parses the image reference the way the validation does. Images without the registry host
+// are Docker Hub images, colons of the registry port and of IPv6 hosts aren't taken for the tag delimiter
+// and the latest tag is implied only when the reference has neither the tag nor the digest.
+func ParseImageRef(image string) (ImageRef, error) {
+	image, err := sanitizeImageRef(image)
+	if err != nil {
+		return ImageRef{}, err
+	}
+	return splitImageRef(image)
+}
+
+// splitImageRef splits the sanitized image reference.
+func splitImageRef(image string) (ImageRef, error) {
+	ref := ImageRef{}
+	name := image
+	if i := strings.Index(image, digestDelim); i >= 0 {
+		name = image[:i]
+		algorithm, digest, err := parseDigest(image[i+len(digestDelim):])
+		if err != nil {
+			return ImageRef{}, err
+		}
+		ref.digestAlgorithm = algorithm
+		ref.digest = digest
+		ref.Digest = algorithm + digestAlgorithmDelim + hex.EncodeToString(digest)
+	}
+
+	// colons of the registry port and of IPv6 hosts come before the last slash
+	if i := strings.LastIndex(name, tagDelim); i >= 0 && i > strings.LastIndex(name, "/") {
+		ref.Tag = name[i+len(tagDelim):]
+		ref.explicitTag = true
+		name = name[:i]
+	} else if ref.Digest == "" {
+		ref.Tag = defaultTag
+	}
+	ref.name = name
+
+	ref.Registry, ref.Repository = dockerHubRegistry, name
+	if hasRegistryHost(name) {
+		host, path, _ := strings.Cut(name, "/")
+		ref.Registry, ref.Repository = canonicalRegistryHost(host), path
+	}
+	if ref.Registry == dockerHubRegistry && !strings.Contains(ref.Repository, "/") {
+		ref.Repository = dockerHubNamespace + "/" + ref.Repository
+	}
+	return ref, nil
+}
+
+// canonicalRegistryHost returns the lower case host with IPv6 addresses in the canonical form
+// and Docker Hub under docker.io.
+func canonicalRegistryHost(host string) string {
+	host = normalizeRegistryHost(strings.ToLower(host))
+	if dockerHubAliases[host] {
+		return dockerHubRegistry
+	}
+	return host
+}
+
+// parseImageRef parses the sanitized image reference for the validation, which needs the tag signed in notary
+// or, for references pinned without a tag, the target signed for the digest.
+// The repository is kept as written, because allowed registries and notary repositories match it.
+func parseImageRef(image string) (imageRef, error) {
+	parsed, err := splitImageRef(image)
+	if err != nil {
+		return imageRef{}, err
+	}
+	if !parsed.explicitTag && parsed.Digest == "" {
+		return imageRef{}, errMalformedImageName
+	}
+	return imageRef{
+		repo:            parsed.name,
+		tag:             parsed.Tag,
+		digestAlgorithm: parsed.digestAlgorithm,
+		digest:          parsed.digest,
+	}, nil
+}
+
+// normalizeRepo returns the repository with the IPv6 registry host in the canonical bracketed form,
+// e.g. 2001:0db8::1 and [2001:db8:0::1] become [2001:db8::1]. Other repositories are returned as is.
+func normalizeRepo(repo string) string {
+	host, path, hasPath := strings.Cut(repo, "/")
+	host = normalizeRegistryHost(host)
+	if !hasPath {
+		return host
+	}
+	return host + "/" + path
+}
+
+func normalizeRegistryHost(host string) string {
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		return "[" + ip.String() + "]"
+	}
+	if !strings.HasPrefix(host, "[") {
+		return host
+	}
+	addr, port, err := net.SplitHostPort(host)
+	if err != nil {
+		// bracketed host without port
+		addr, port = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]"), ""
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return host
+	}
+	if port == "" {
+		return "[" + ip.String() + "]"
+	}
+	return net.JoinHostPort(ip.String(), port)
+}
+
+func parseDigest(digest string) (string, []byte, error) {
+	split := strings.Split(digest, digestAlgorithmDelim)
+	if len(split) != 2 {
+		return "", nil, errMalformedImageDigest
+	}
+	if _, err := newHash(split[0]); err != nil {
+		return "", nil, err
+	}
+	hash, err := hex.DecodeString(split[1])
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %s", errMalformedImageDigest, err)
+	}
+	return split[0], hash, nil
+}
@@ -3,6 +3,8 @@ package validate
 import (
 	"reflect"
 	"testing"
+
+	"github.com/stretchr/testify/require"
 )
 
 func TestParseAllowedRegistries(t *testing.T) {
@@ -51,3 +53,250 @@ func TestParseAllowedRegistries(t *testing.T) {
 		})
 	}
 }
+
+func Test_parseImageRef(t *testing.T) {
+	tests := []struct {
+		name        string
+		image       string
+		expected    imageRef
+		expectedErr string
+	}{
+		{
+			name:     "tagged image",
+			image:    "eu.gcr.io/kyma-project/image:v1",
+			expected: imageRef{repo: "eu.gcr.io/kyma-project/image", tag: "v1"},
+		},
+		{
+			name:  "tag with digest",
+			image: "eu.gcr.io/kyma-project/image:v1@sha256:0a0b",
+			expected: imageRef{
+				repo:            "eu.gcr.io/kyma-project/image",
+				tag:             "v1",
+				digestAlgorithm: "sha256",
+				digest:          []byte{10, 11},
+			},
+		},
+		{
+			name:     "registry with port",
+			image:    "registry.example.com:5000/kyma-project/image:v1",
+			expected: imageRef{repo: "registry.example.com:5000/kyma-project/image", tag: "v1"},
+		},
+		{
+			name:     "IPv6 registry with port",
+			image:    "[2001:db8::1]:5000/app:v1",
+			expected: imageRef{repo: "[2001:db8::1]:5000/app", tag: "v1"},
+		},
+		{
+			name:     "IPv6 registry without port",
+			image:    "[2001:db8::1]/kyma-project/app:v1",
+			expected: imageRef{repo: "[2001:db8::1]/kyma-project/app", tag: "v1"},
+		},
+		{
+			name:  "IPv6 registry with tag and digest",
+			image: "[::1]:5000/app:v1@sha256:0a0b",
+			expected: imageRef{
+				repo:            "[::1]:5000/app",
+				tag:             "v1",
+				digestAlgorithm: "sha256",
+				digest:          []byte{10, 11},
+			},
+		},
+		{
+			name:        "IPv6 registry without tag",
+			image:       "[2001:db8::1]:5000/app",
+			expectedErr: "image name is not formatted correctly",
+		},
+		{
+			name:  "digest without tag",
+			image: "eu.gcr.io/kyma-project/image@sha256:0a0b",
+			expected: imageRef{
+				repo:            "eu.gcr.io/kyma-project/image",
+				digestAlgorithm: "sha256",
+				digest:          []byte{10, 11},
+			},
+		},
+		{
+			name:        "digest with unsupported algorithm",
+			image:       "eu.gcr.io/kyma-project/image:v1@md5:0a0b",
+			expectedErr: "unsupported hash algorithm: md5",
+		},
+		{
+			name:        "digest which is not hex",
+			image:       "eu.gcr.io/kyma-project/image:v1@sha256:xyz",
+			expectedErr: "image digest is not formatted correctly: encoding/hex: invalid byte: U+0078 'x'",
+		},
+		{
+			name:        "digest without algorithm",
+			image:       "eu.gcr.io/kyma-project/image:v1@0a0b",
+			expectedErr: "image digest is not formatted correctly",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref, err := parseImageRef(tt.image)
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, ref)
+		})
+	}
+}
+
+func TestParseImageRef(t *testing.T) {
+	digest := "sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+	tests := []struct {
+		name          string
+		image         string
+		expected      ImageRef
+		expectedForm  string
+		expectedError string
+	}{
+		{
+			name:         "fully qualified image",
+			image:        "eu.gcr.io/kyma-project/app:1.0",
+			expected:     ImageRef{Registry: "eu.gcr.io", Repository: "kyma-project/app", Tag: "1.0"},
+			expectedForm: "eu.gcr.io/kyma-project/app:1.0",
+		},
+		{
+			name:         "official docker hub image",
+			image:        "busybox:1.36",
+			expected:     ImageRef{Registry: "docker.io", Repository: "library/busybox", Tag: "1.36"},
+			expectedForm: "docker.io/library/busybox:1.36",
+		},
+		{
+			name:         "docker hub image with namespace",
+			image:        "kyma/app:1.0",
+			expected:     ImageRef{Registry: "docker.io", Repository: "kyma/app", Tag: "1.0"},
+			expectedForm: "docker.io/kyma/app:1.0",
+		},
+		{
+			name:         "default tag",
+			image:        "busybox",
+			expected:     ImageRef{Registry: "docker.io", Repository: "library/busybox", Tag: "latest"},
+			expectedForm: "docker.io/library/busybox:latest",
+		},
+		{
+			name:         "docker hub alias",
+			image:        "index.docker.io/busybox:1.36",
+			expected:     ImageRef{Registry: "docker.io", Repository: "library/busybox", Tag: "1.36"},
+			expectedForm: "docker.io/library/busybox:1.36",
+		},
+		{
+			name:         "localhost registry",
+			image:        "localhost/app:1.0",
+			expected:     ImageRef{Registry: "localhost", Repository: "app", Tag: "1.0"},
+			expectedForm: "localhost/app:1.0",
+		},
+		{
+			name:         "registry port isn't the tag",
+			image:        "registry.example.com:5000/kyma-project/app",
+			expected:     ImageRef{Registry: "registry.example.com:5000", Repository: "kyma-project/app", Tag: "latest"},
+			expectedForm: "registry.example.com:5000/kyma-project/app:latest",
+		},
+		{
+			name:         "registry port with tag",
+			image:        "localhost:5000/app:1.0",
+			expected:     ImageRef{Registry: "localhost:5000", Repository: "app", Tag: "1.0"},
+			expectedForm: "localhost:5000/app:1.0",
+		},
+		{
+			name:         "registry host in upper case",
+			image:        "EU.GCR.IO/kyma-project/app:1.0",
+			expected:     ImageRef{Registry: "eu.gcr.io", Repository: "kyma-project/app", Tag: "1.0"},
+			expectedForm: "eu.gcr.io/kyma-project/app:1.0",
+		},
+		{
+			name:         "IPv6 registry with port",
+			image:        "[2001:0db8:0::1]:5000/app:v1",
+			expected:     ImageRef{Registry: "[2001:db8::1]:5000", Repository: "app", Tag: "v1"},
+			expectedForm: "[2001:db8::1]:5000/app:v1",
+		},
+		{
+			name:         "IPv6 registry without port and tag",
+			image:        "[2001:DB8::1]/kyma-project/app",
+			expected:     ImageRef{Registry: "[2001:db8::1]", Repository: "kyma-project/app", Tag: "latest"},
+			expectedForm: "[2001:db8::1]/kyma-project/app:latest",
+		},
+		{
+			name:         "tag with digest",
+			image:        "eu.gcr.io/kyma-project/app:1.0@" + digest,
+			expected:     ImageRef{Registry: "eu.gcr.io", Repository: "kyma-project/app", Tag: "1.0", Digest: digest},
+			expectedForm: "eu.gcr.io/kyma-project/app:1.0@" + digest,
+		},
+		{
+			name:         "digest without tag",
+			image:        "busybox@" + digest,
+			expected:     ImageRef{Registry: "docker.io", Repository: "library/busybox", Digest: digest},
+			expectedForm: "docker.io/library/busybox@" + digest,
+		},
+		{
+			name:         "digest in upper case",
+			image:        "[::1]:5000/app@sha256:0A0B",
+			expected:     ImageRef{Registry: "[::1]:5000", Repository: "app", Digest: "sha256:0a0b"},
+			expectedForm: "[::1]:5000/app@sha256:0a0b",
+		},
+		{
+			name:         "surrounding whitespace",
+			image:        " eu.gcr.io/kyma-project/app:1.0\n",
+			expected:     ImageRef{Registry: "eu.gcr.io", Repository: "kyma-project/app", Tag: "1.0"},
+			expectedForm: "eu.gcr.io/kyma-project/app:1.0",
+		},
+		{
+			name:          "uppercase repository",
+			image:         "eu.gcr.io/Kyma-project/app:1.0",
+			expectedError: "uppercase character 'K' at position 11",
+		},
+		{
+			name:          "digest with unsupported algorithm",
+			image:         "eu.gcr.io/kyma-project/app:1.0@md5:0a0b",
+			expectedError: "unsupported hash algorithm: md5",
+		},
+		{
+			name:          "digest which is not hex",
+			image:         "eu.gcr.io/kyma-project/app@sha256:xyz",
+			expectedError: "image digest is not formatted correctly",
+		},
+		{
+			name:          "digest without algorithm",
+			image:         "eu.gcr.io/kyma-project/app@0a0b",
+			expectedError: "image digest is not formatted correctly",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//WHEN
+			ref, err := ParseImageRef(tt.image)
+
+			//THEN
+			if tt.expectedError != "" {
+				require.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, ImageRef{Registry: ref.Registry, Repository: ref.Repository, Tag: ref.Tag, Digest: ref.Digest})
+			require.Equal(t, tt.expectedForm, ref.String())
+		})
+	}
+}
+
+func Test_normalizeRepo(t *testing.T) {
+	tests := map[string]string{
+		"[2001:db8::1]:5000/app":       "[2001:db8::1]:5000/app",
+		"[2001:0db8:0::1]:5000/app":    "[2001:db8::1]:5000/app",
+		"[2001:DB8::1]/kyma/app":       "[2001:db8::1]/kyma/app",
+		"2001:db8::1/app":              "[2001:db8::1]/app",
+		"[2001:db8::1]":                "[2001:db8::1]",
+		"[::1]:5000":                   "[::1]:5000",
+		"192.168.0.1:5000/app":         "192.168.0.1:5000/app",
+		"eu.gcr.io/kyma-project/image": "eu.gcr.io/kyma-project/image",
+		"[2001:db8":                    "[2001:db8",
+		"some-registry/allowed-":       "some-registry/allowed-",
+	}
+	for repo, expected := range tests {
+		t.Run(repo, func(t *testing.T) {
+			require.Equal(t, expected, normalizeRepo(repo))
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package validate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type Phase string
+
+const (
+	NotaryPhase   Phase = "notary"
+	RegistryPhase Phase = "registry"
+)
+
+// PhaseTimeoutError is returned when one of the validation phases didn't finish in time.
+type PhaseTimeoutError struct {
+	Phase Phase
+	Err   error
+	// Timings tell which stage of the phase requests took the time, e.g. the TLS handshake or waiting for the response.
+	Timings RequestTimings
+}
+
+func (e PhaseTimeoutError) Error() string {
+	if e.Timings.Requests == 0 {
+		return fmt.Sprintf("%s phase timed out: %s", e.Phase, e.Err)
+	}
+	return fmt.Sprintf("%s phase timed out: %s (%s)", e.Phase, e.Err, e.Timings)
+}
+
+func (e PhaseTimeoutError) Class() ErrorClassification {
+	return ClassInfrastructure
+}
+
+func (e PhaseTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// runPhase runs f with a context capped by the phase timeout and the parent deadline.
+// Timeout errors report the request timings collected in ctx.
+// f has to return when the phase context is done, the requests of notary repository clients are bound to it.
+func runPhase(ctx context.Context, phase Phase, timeout time.Duration, f func(context.Context) error) error {
+	phaseCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		phaseCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	err := f(phaseCtx)
+	// the notary client doesn't keep the context error in the errors of cancelled requests
+	if err != nil && phaseCtx.Err() != nil && !errors.Is(err, phaseCtx.Err()) {
+		err = phaseCtx.Err()
+	}
+
+	if err != nil && errors.Is(phaseCtx.Err(), context.DeadlineExceeded) {
+		return PhaseTimeoutError{Phase: phase, Err: err, Timings: requestTimingsFrom(ctx).snapshot()}
+	}
+	return err
+}
@@ -0,0 +1,128 @@
+package validate
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+func Test_Validate_NotaryPhaseTimeout(t *testing.T) {
+	//GIVEN
+	phaseTimeout := 50 * time.Millisecond
+	f := func(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+		time.Sleep(4 * phaseTimeout)
+		return nil, errors.New("it shouldn't be returned")
+	}
+	s := NewDefaultMockNotaryService().WithFunc(f).Build()
+	s.NotaryTimeout = phaseTimeout
+	start := time.Now()
+
+	//WHEN
+	err := s.Validate(context.TODO(), TrustedImageName)
+
+	//THEN
+	var timeoutErr PhaseTimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	require.Equal(t, NotaryPhase, timeoutErr.Phase)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), 2*phaseTimeout)
+}
+
+func Test_registryPhase_Timeout(t *testing.T) {
+	//GIVEN
+	phaseTimeout := 50 * time.Millisecond
+	reg := registry.New()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(4 * phaseTimeout)
+		reg.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	image := strings.TrimPrefix(srv.URL, "http://") + "/test/image:tag"
+
+	s := NewDefaultMockNotaryService().WithInsecureRegistries(registryHost(image)).Build()
+	s.RegistryTimeout = phaseTimeout
+	start := time.Now()
+
+	//WHEN
+	_, err := s.registryPhase(context.TODO(), image, SHA256Algorithm)
+
+	//THEN
+	var timeoutErr PhaseTimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	require.Equal(t, RegistryPhase, timeoutErr.Phase)
+	require.Less(t, time.Since(start), 2*phaseTimeout)
+}
+
+func Test_registryPhase_GetsBudgetAfterSlowNotary(t *testing.T) {
+	//GIVEN
+	notaryDelay := 100 * time.Millisecond
+	ref, _ := pushTestImage(t)
+	f := func(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+		time.Sleep(notaryDelay)
+		return NewDefaultMockNotaryFunction().Build()(name, roles...)
+	}
+	s := NewDefaultMockNotaryService().WithFunc(f).WithInsecureRegistries(registryHost(ref)).Build()
+	s.NotaryTimeout = 2 * notaryDelay
+	s.RegistryTimeout = time.Second
+
+	// the parent deadline is the only limit shared by the phases
+	ctx, cancel := context.WithTimeout(context.TODO(), 3*notaryDelay+time.Second)
+	defer cancel()
+
+	//WHEN
+	signed, err := s.notaryPhase(ctx, "eu.gcr.io/kyma-project/image", "tag")
+	require.NoError(t, err)
+	_, err = s.registryPhase(ctx, ref, signed.algorithm)
+
+	//THEN
+	require.NoError(t, err)
+}
+
+func Test_runPhase_ParentDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.TODO(), 20*time.Millisecond)
+	defer cancel()
+
+	err := runPhase(ctx, RegistryPhase, time.Minute, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	require.EqualError(t, err, "registry phase timed out: context deadline exceeded")
+}
+
+func Test_runPhase_WaitsForPhase(t *testing.T) {
+	finished := false
+
+	err := runPhase(context.TODO(), NotaryPhase, 20*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		// the phase cleans up after its context is done, e.g. unlocks the trust dir
+		time.Sleep(20 * time.Millisecond)
+		finished = true
+		return errors.New("request cancelled")
+	})
+
+	require.True(t, finished)
+	require.EqualError(t, err, "notary phase timed out: context deadline exceeded")
+}
+
+func Test_runPhase_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	err := runPhase(ctx, NotaryPhase, time.Minute, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	require.ErrorIs(t, err, context.Canceled)
+	require.False(t, errors.As(err, &PhaseTimeoutError{}))
+}
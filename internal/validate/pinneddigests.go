@@ -0,0 +1,68 @@
+package validate
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// PinnedDigests map image repositories to the sha256 digests ("sha256:<hex>") which are admitted without notary
+// and the registry, e.g. the webhook, CNI and CSI images needed to recover a cluster when both are unreachable.
+// Only references pinned to one of the digests match, tag references never do.
+type PinnedDigests map[string][]string
+
+// Validate rejects entries which aren't well-formed sha256 digests, they could never match an image.
+func (p PinnedDigests) Validate() error {
+	for repo, digests := range p {
+		for _, digest := range digests {
+			if _, err := parsePinnedDigest(digest); err != nil {
+				return fmt.Errorf("pinned digest %s of %s: %w", digest, repo, err)
+			}
+		}
+	}
+	return nil
+}
+
+// match returns the image reference in the "<repo>@sha256:<hex>" form when the image is pinned to one of the digests
+// of its repository. References pinned without a tag match too, also when digest targets are disabled.
+func (p PinnedDigests) match(image string) (string, bool) {
+	name, digest, ok := strings.Cut(image, digestDelim)
+	if len(p) == 0 || !ok {
+		return "", false
+	}
+	hash, err := parsePinnedDigest(digest)
+	if err != nil {
+		return "", false
+	}
+	repo := name
+	if i := strings.LastIndex(name, tagDelim); i > strings.LastIndex(name, "/") {
+		repo = name[:i]
+	}
+	for pinnedRepo, digests := range p {
+		if normalizeRepo(pinnedRepo) != normalizeRepo(repo) {
+			continue
+		}
+		for _, pinned := range digests {
+			if pinnedHash, err := parsePinnedDigest(pinned); err == nil && bytes.Equal(pinnedHash, hash) {
+				return repo + digestDelim + SHA256Algorithm + digestAlgorithmDelim + hex.EncodeToString(hash), true
+			}
+		}
+	}
+	return "", false
+}
+
+func parsePinnedDigest(digest string) ([]byte, error) {
+	algorithm, hash, err := parseDigest(digest)
+	if err != nil {
+		return nil, err
+	}
+	if algorithm != SHA256Algorithm {
+		return nil, fmt.Errorf("%w: only %s digests can be pinned", errMalformedImageDigest, SHA256Algorithm)
+	}
+	if len(hash) != sha256.Size {
+		return nil, fmt.Errorf("%w: %s digest must have %d bytes", errMalformedImageDigest, SHA256Algorithm, sha256.Size)
+	}
+	return hash, nil
+}
@@ -0,0 +1,125 @@
+package validate
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+func Test_Validate_PinnedDigests(t *testing.T) {
+	pinned := "sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+	other := "sha256:fcde2b2edba56bf408601fb721fe9b5c338d10ee429ea04fae5511b68fbf8fb9"
+	pinnedDigests := PinnedDigests{
+		"eu.gcr.io/kyma-project/warden/admission": {other, pinned},
+		"busybox": {pinned},
+	}
+	tests := []struct {
+		name                string
+		image               string
+		requireFQDNRegistry bool
+		expectedDigest      string
+	}{
+		{
+			name:           "reference pinned without tag",
+			image:          "eu.gcr.io/kyma-project/warden/admission@" + pinned,
+			expectedDigest: "eu.gcr.io/kyma-project/warden/admission@" + pinned,
+		},
+		{
+			name:           "tagged reference pinned to the digest",
+			image:          "eu.gcr.io/kyma-project/warden/admission:1.0@" + pinned,
+			expectedDigest: "eu.gcr.io/kyma-project/warden/admission@" + pinned,
+		},
+		{
+			name:  "digest which isn't pinned",
+			image: "eu.gcr.io/kyma-project/warden/admission:1.0@sha256:b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c",
+		},
+		{
+			name:  "tag reference",
+			image: "eu.gcr.io/kyma-project/warden/admission:1.0",
+		},
+		{
+			name:  "digest pinned for another repository",
+			image: "eu.gcr.io/kyma-project/warden/operator:1.0@" + pinned,
+		},
+		{
+			name:                "precedence over the denial of images without registry",
+			image:               "busybox@" + pinned,
+			requireFQDNRegistry: true,
+			expectedDigest:      "busybox@" + pinned,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//GIVEN
+			var notaryCalls int
+			f := func(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+				notaryCalls++
+				return nil, client.ErrNoSuchTarget(name)
+			}
+			s := NewDefaultMockNotaryService().WithFunc(f).Build()
+			s.PinnedDigests = pinnedDigests
+			s.RequireFQDNRegistry = tt.requireFQDNRegistry
+
+			//WHEN
+			result, err := s.ValidateDetailed(context.TODO(), tt.image)
+
+			//THEN
+			if tt.expectedDigest == "" {
+				require.Error(t, err)
+				require.Equal(t, OutcomeDenied, result.Outcome)
+				require.Positive(t, notaryCalls)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, OutcomePinnedDigest, result.Outcome)
+			require.Equal(t, tt.expectedDigest, result.ResolvedDigest)
+			require.Zero(t, notaryCalls)
+		})
+	}
+}
+
+func TestPinnedDigests_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		digest string
+		valid  bool
+	}{
+		{
+			name:   "sha256 digest",
+			digest: "sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae",
+			valid:  true,
+		},
+		{
+			name:   "truncated digest",
+			digest: "sha256:2c26b46b68ffc68f",
+		},
+		{
+			name:   "not hex",
+			digest: "sha256:zz26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae",
+		},
+		{
+			name:   "other algorithm",
+			digest: "sha512:f7fbba6e0636f890e56fbbf3283e524c6fa3204ae298382d624741d0dc6638326e282c41be5e4254d8820772c5518a2c5a8c0c7f7eda19594a7eb539453e1ed7",
+		},
+		{
+			name:   "no algorithm",
+			digest: "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//WHEN
+			err := PinnedDigests{"eu.gcr.io/kyma-project/warden/admission": {tt.digest}}.Validate()
+
+			//THEN
+			if tt.valid {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, "pinned digest "+tt.digest)
+		})
+	}
+}
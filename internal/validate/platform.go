@@ -0,0 +1,79 @@
+package validate
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// DefaultPlatform selects the image compared against notary when the reference points to an index.
+const DefaultPlatform = "linux/amd64"
+
+type platformContextKey struct{}
+
+// ContextWithPlatform overrides the platform for validations made with ctx, e.g. for pods scheduled to arm64 nodes.
+// The platform has the "os/arch[/variant]" format.
+func ContextWithPlatform(ctx context.Context, platform string) context.Context {
+	return context.WithValue(ctx, platformContextKey{}, platform)
+}
+
+// platformFor returns the platform of the validation, the platform of ctx takes precedence over ServiceConfig.Platform.
+func (s *notaryService) platformFor(ctx context.Context) string {
+	if platform, ok := ctx.Value(platformContextKey{}).(string); ok && platform != "" {
+		return platform
+	}
+	if s.Platform != "" {
+		return s.Platform
+	}
+	return DefaultPlatform
+}
+
+func parsePlatform(platform string) (v1.Platform, error) {
+	p, err := v1.ParsePlatform(platform)
+	if err != nil {
+		return v1.Platform{}, fmt.Errorf("invalid platform %s: %w", platform, err)
+	}
+	if p.OS == "" || p.Architecture == "" {
+		return v1.Platform{}, fmt.Errorf("invalid platform %s: os and architecture are required", platform)
+	}
+	return *p, nil
+}
+
+// imageDigests are the registry hashes which may be signed in notary.
+type imageDigests struct {
+	// config is the config hash of the image for the validated platform.
+	config []byte
+	// index is the hash of the index manifest, it's set only for references to indexes.
+	index []byte
+	// platformErr is set when the index doesn't have the image for the validated platform,
+	// it's reported only when notary didn't sign the index.
+	platformErr error
+}
+
+// matches prefers the index hash, so images signed by the index digest don't depend on the platform.
+func (d imageDigests) matches(signed []byte) (bool, error) {
+	if d.index != nil && subtle.ConstantTimeCompare(d.index, signed) == 1 {
+		return true, nil
+	}
+	if d.platformErr != nil {
+		return false, d.platformErr
+	}
+	return subtle.ConstantTimeCompare(d.config, signed) == 1, nil
+}
+
+// manifestHash returns the hash of the raw manifest computed with the notary algorithm.
+func manifestHash(desc *remote.Descriptor, algorithm string) ([]byte, error) {
+	if desc.Digest.Algorithm == algorithm {
+		return hex.DecodeString(desc.Digest.Hex)
+	}
+	h, err := newHash(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	h.Write(desc.Manifest)
+	return h.Sum(nil), nil
+}
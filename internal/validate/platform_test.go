@@ -0,0 +1,197 @@
+package validate
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate_Platform(t *testing.T) {
+	image := "eu.gcr.io/kyma-project/multiarch:1.0"
+	transport, idx := pushTestIndexAs(t, image, "linux/amd64", "linux/arm64")
+	amd64Hash := platformConfigHash(t, idx, "linux/amd64")
+	arm64Hash := platformConfigHash(t, idx, "linux/arm64")
+	indexDigest, err := idx.Digest()
+	require.NoError(t, err)
+	indexHash, err := hex.DecodeString(indexDigest.Hex)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name          string
+		signedHash    []byte
+		platform      string
+		ctxPlatform   string
+		expectedError string
+	}{
+		{
+			name:       "default platform is validated",
+			signedHash: amd64Hash,
+		},
+		{
+			name:          "other platform doesn't match the default",
+			signedHash:    arm64Hash,
+			expectedError: "unexpected image hash value",
+		},
+		{
+			name:       "validator platform",
+			signedHash: arm64Hash,
+			platform:   "linux/arm64",
+		},
+		{
+			name:        "context platform takes precedence",
+			signedHash:  arm64Hash,
+			platform:    "linux/amd64",
+			ctxPlatform: "linux/arm64",
+		},
+		{
+			name:       "signed index is valid for any platform",
+			signedHash: indexHash,
+			platform:   "linux/arm64",
+		},
+		{
+			name:       "signed index is valid for platform missing in the index",
+			signedHash: indexHash,
+			platform:   "linux/s390x",
+		},
+		{
+			name:          "platform missing in the index",
+			signedHash:    amd64Hash,
+			platform:      "linux/s390x",
+			expectedError: "no child with platform linux/s390x",
+		},
+		{
+			name:          "invalid platform",
+			signedHash:    amd64Hash,
+			platform:      "linux",
+			expectedError: "invalid platform linux",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			//GIVEN
+			s := NewDefaultMockNotaryService().
+				WithHash(tt.signedHash).
+				WithRegistryTransport(transport).
+				Build()
+			s.Platform = tt.platform
+			ctx := context.TODO()
+			if tt.ctxPlatform != "" {
+				ctx = ContextWithPlatform(ctx, tt.ctxPlatform)
+			}
+
+			//WHEN
+			err := s.Validate(ctx, image)
+
+			//THEN
+			if tt.expectedError == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, tt.expectedError)
+			}
+		})
+	}
+}
+
+func TestValidate_PlatformDenialsAreCachedPerPlatform(t *testing.T) {
+	//GIVEN
+	image := "eu.gcr.io/kyma-project/multiarch:1.0"
+	transport, idx := pushTestIndexAs(t, image, "linux/amd64", "linux/arm64")
+	s := NewDefaultMockNotaryService().
+		WithHash(platformConfigHash(t, idx, "linux/arm64")).
+		WithRegistryTransport(transport).
+		Build()
+
+	//WHEN
+	amd64Err := s.Validate(context.TODO(), image)
+	arm64Err := s.Validate(ContextWithPlatform(context.TODO(), "linux/arm64"), image)
+
+	//THEN
+	require.ErrorContains(t, amd64Err, "unexpected image hash value")
+	require.NoError(t, arm64Err)
+}
+
+func TestWithPlatform(t *testing.T) {
+	sc := &ServiceConfig{Platform: "linux/amd64"}
+
+	s := newNotaryService(sc, WithPlatform("linux/arm64"))
+
+	require.Equal(t, "linux/arm64", s.platformFor(context.TODO()))
+	require.Equal(t, "linux/amd64", sc.Platform)
+}
+
+func Test_parsePlatform(t *testing.T) {
+	testCases := []struct {
+		platform      string
+		expected      v1.Platform
+		expectedError bool
+	}{
+		{platform: "linux/amd64", expected: v1.Platform{OS: "linux", Architecture: "amd64"}},
+		{platform: "linux/arm64/v8", expected: v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}},
+		{platform: "linux", expectedError: true},
+		{platform: "", expectedError: true},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.platform, func(t *testing.T) {
+			p, err := parsePlatform(tt.platform)
+
+			if tt.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, p)
+		})
+	}
+}
+
+// pushTestIndexAs pushes an index with a random image for each platform to an in-memory registry
+// and returns the transport which redirects registry calls to it.
+func pushTestIndexAs(t *testing.T, image string, platforms ...string) (http.RoundTripper, v1.ImageIndex) {
+	srv := httptest.NewServer(registry.New())
+	t.Cleanup(srv.Close)
+	transport := redirectTransport{host: strings.TrimPrefix(srv.URL, "http://")}
+
+	var idx v1.ImageIndex = empty.Index
+	for _, platform := range platforms {
+		p, err := v1.ParsePlatform(platform)
+		require.NoError(t, err)
+		img, err := random.Image(128, 1)
+		require.NoError(t, err)
+		idx = mutate.AppendManifests(idx, mutate.IndexAddendum{Add: img, Descriptor: v1.Descriptor{Platform: p}})
+	}
+
+	ref, err := name.ParseReference(image)
+	require.NoError(t, err)
+	require.NoError(t, remote.WriteIndex(ref, idx, remote.WithTransport(transport)))
+
+	return transport, idx
+}
+
+// platformConfigHash returns the config hash of the index image for the platform.
+func platformConfigHash(t *testing.T, idx v1.ImageIndex, platform string) []byte {
+	p, err := v1.ParsePlatform(platform)
+	require.NoError(t, err)
+	manifest, err := idx.IndexManifest()
+	require.NoError(t, err)
+	for _, m := range manifest.Manifests {
+		if m.Platform != nil && m.Platform.Equals(*p) {
+			img, err := idx.Image(m.Digest)
+			require.NoError(t, err)
+			return configHash(t, img)
+		}
+	}
+	t.Fatalf("index doesn't have platform %s", platform)
+	return nil
+}
@@ -2,7 +2,11 @@ package validate
 
 import (
 	"context"
+	"sort"
+	"sync"
+
 	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	"github.com/kyma-project/warden/pkg"
@@ -11,6 +15,10 @@ import (
 
 type ValidationResult int
 
+// maxConcurrentImageValidations bounds the images of one pod validated at the same time,
+// the registry calls of all validations are bounded by the image validator too.
+const maxConcurrentImageValidations = 8
+
 const (
 	Invalid ValidationResult = iota
 	ServiceUnavailable
@@ -30,12 +38,29 @@ type NamespaceChecker interface {
 var _ PodValidator = &podValidator{}
 
 type podValidator struct {
-	Validator ImageValidatorService
+	Validators ImageValidatorFactory
+	// Audit receives the decision about every validated image, it's optional.
+	Audit AuditSink
 }
 
 func NewPodValidator(imageValidator ImageValidatorService) PodValidator {
 	return &podValidator{
-		imageValidator,
+		Validators: staticValidator{imageValidator},
+	}
+}
+
+// NewNamespacedPodValidator validates pods with the image validator of the pod's namespace.
+func NewNamespacedPodValidator(imageValidators ImageValidatorFactory) PodValidator {
+	return &podValidator{
+		Validators: imageValidators,
+	}
+}
+
+// NewAuditedPodValidator validates pods like NewNamespacedPodValidator and writes every image decision to the audit sink.
+func NewAuditedPodValidator(imageValidators ImageValidatorFactory, audit AuditSink) PodValidator {
+	return &podValidator{
+		Validators: imageValidators,
+		Audit:      audit,
 	}
 }
 
@@ -49,42 +74,152 @@ func (a *podValidator) ValidatePod(ctx context.Context, pod *corev1.Pod, ns *cor
 	if enabled := IsValidationEnabledForNS(ns); !enabled {
 		return NoAction, nil
 	}
-	matched := make(map[string]ValidationResult)
-
-	images := getAllImages(pod)
+	validator := a.Validators.GetValidator(ns.Name)
+	validations := validateDistinctImages(ctx, podImages(pod), func(ctx context.Context, image string) (ImageValidationResult, error) {
+		return a.validateImage(ctx, validator, pod, image)
+	})
 
 	admitResult := Valid
+	for _, v := range validations {
+		if v.err != nil {
+			admitResult = Invalid
+			l.Info(v.err.Error())
+			recordDenialReason(ctx, v.err)
+		}
+	}
+	return admitResult, nil
+}
 
-	for s := range images {
-		result, err := a.validateImage(ctx, s)
-		matched[s] = result
+// imageValidation is the result of one image validation of validateDistinctImages.
+type imageValidation struct {
+	result ImageValidationResult
+	err    error
+}
 
-		if result == Invalid {
-			admitResult = Invalid
-			l.Info(err.Error())
+// validateDistinctImages validates every distinct image once, the images are validated concurrently.
+// The validations are keyed by the image.
+func validateDistinctImages(ctx context.Context, images []string,
+	validate func(ctx context.Context, image string) (ImageValidationResult, error)) map[string]imageValidation {
+	var distinct []string
+	seen := map[string]bool{}
+	for _, image := range images {
+		if !seen[image] {
+			seen[image] = true
+			distinct = append(distinct, image)
 		}
 	}
 
-	return admitResult, nil
+	validations := make(map[string]imageValidation, len(distinct))
+	var (
+		mu sync.Mutex
+		g  errgroup.Group
+	)
+	g.SetLimit(maxConcurrentImageValidations)
+	for _, image := range distinct {
+		image := image
+		g.Go(func() error {
+			result, err := validate(ctx, image)
+			mu.Lock()
+			defer mu.Unlock()
+			validations[image] = imageValidation{result: result, err: err}
+			return nil
+		})
+	}
+	// denials aren't errors of the group, they are collected in the validations
+	_ = g.Wait()
+	return validations
+}
+
+type denialReasonsContextKey struct{}
+
+// DenialReasons collects the reasons of image denials which can be shown to the pod's author,
+// i.e. problems of the image references themselves like ImageReferenceError.
+type DenialReasons struct {
+	mu      sync.Mutex
+	reasons map[string]struct{}
+}
+
+// ContextWithDenialReasons makes pod validations made with ctx record denial reasons to reasons.
+func ContextWithDenialReasons(ctx context.Context, reasons *DenialReasons) context.Context {
+	return context.WithValue(ctx, denialReasonsContextKey{}, reasons)
+}
+
+// Reasons returns the recorded reasons sorted, so the message of the same pod is always the same.
+func (d *DenialReasons) Reasons() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	reasons := make([]string, 0, len(d.reasons))
+	for reason := range d.reasons {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	return reasons
+}
+
+func recordDenialReason(ctx context.Context, err error) {
+	d, ok := ctx.Value(denialReasonsContextKey{}).(*DenialReasons)
+	var refErr ImageReferenceError
+	if !ok || !errors.As(err, &refErr) {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.reasons == nil {
+		d.reasons = map[string]struct{}{}
+	}
+	d.reasons[refErr.Error()] = struct{}{}
 }
 
 func IsValidationEnabledForNS(ns *corev1.Namespace) bool {
 	return ns.GetLabels()[pkg.NamespaceValidationLabel] == pkg.NamespaceValidationEnabled
 }
 
-func (a *podValidator) validateImage(ctx context.Context, image string) (ValidationResult, error) {
-	err := a.Validator.Validate(ctx, image)
+func (a *podValidator) validateImage(ctx context.Context, validator ImageValidatorService, pod *corev1.Pod, image string) (ImageValidationResult, error) {
+	result, err := validator.ValidateDetailed(ctx, image)
+	if a.Audit != nil {
+		entry := newValidationAuditEntry(result, err)
+		entry.Image = image
+		entry.Namespace = pod.Namespace
+		entry.Pod = podName(pod)
+		writeAudit(ctx, a.Audit, entry)
+	}
 	if err != nil {
-		return Invalid, err
+		return result, err
 	}
 
-	return Valid, nil
+	log.FromContext(ctx).V(1).Info("image validated",
+		"image", image,
+		"outcome", result.Outcome,
+		"digest", result.ResolvedDigest,
+		"role", result.NotaryRole,
+		"signingKeyIDs", result.SigningKeyIDs,
+		"warnings", result.Warnings,
+		"notaryDuration", result.Durations.Notary,
+		"registryDuration", result.Durations.Registry)
+	return result, nil
 }
 
-func getAllImages(pod *corev1.Pod) map[string]struct{} {
-	images := make(map[string]struct{})
-	for _, c := range append(pod.Spec.Containers, pod.Spec.InitContainers...) {
-		images[c.Image] = struct{}{}
+// podName falls back to the generate name, because pods created by controllers aren't named yet during admission.
+func podName(pod *corev1.Pod) string {
+	if pod.Name != "" {
+		return pod.Name
+	}
+	return pod.GenerateName
+}
+
+// podImages returns the images of regular, init and ephemeral containers,
+// they don't depend on container names, which aren't validated yet during admission.
+func podImages(pod *corev1.Pod) []string {
+	var images []string
+	for _, c := range pod.Spec.Containers {
+		images = append(images, c.Image)
+	}
+	for _, c := range pod.Spec.InitContainers {
+		images = append(images, c.Image)
+	}
+	for _, c := range pod.Spec.EphemeralContainers {
+		images = append(images, c.Image)
 	}
 	return images
 }
@@ -5,12 +5,14 @@ import (
 	"fmt"
 	"github.com/kyma-project/warden/internal/validate"
 	"github.com/kyma-project/warden/internal/validate/mocks"
+	"github.com/kyma-project/warden/internal/validate/validatetest"
 	"github.com/kyma-project/warden/pkg"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sync"
 	"testing"
 )
 
@@ -108,6 +110,17 @@ func TestValidatePod(t *testing.T) {
 				}},
 			expectedResult: validate.Invalid,
 		},
+		{
+			name: "pod has invalid image in ephemeralContainers",
+			pod: &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: testNs},
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{validContainer},
+					EphemeralContainers: []v1.EphemeralContainer{
+						{EphemeralContainerCommon: v1.EphemeralContainerCommon{Name: "debug", Image: invalidImage}},
+					},
+				}},
+			expectedResult: validate.Invalid,
+		},
 		{
 			name: "pod has invalid image among others images in initContainers",
 			pod: &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: testNs},
@@ -128,8 +141,10 @@ func TestValidatePod(t *testing.T) {
 				}}}
 
 			mockValidator := mocks.ImageValidatorService{}
-			mockValidator.Mock.On("Validate", mock.Anything, invalidImage).Return(errors.New("Invalid image"))
-			mockValidator.Mock.On("Validate", mock.Anything, validImage).Return(nil)
+			mockValidator.Mock.On("ValidateDetailed", mock.Anything, invalidImage).
+				Return(validate.ImageValidationResult{Outcome: validate.OutcomeDenied}, errors.New("Invalid image"))
+			mockValidator.Mock.On("ValidateDetailed", mock.Anything, validImage).
+				Return(validate.ImageValidationResult{Outcome: validate.OutcomeSignatureVerified}, nil)
 
 			podValidator := validate.NewPodValidator(&mockValidator)
 			//WHEN
@@ -141,3 +156,101 @@ func TestValidatePod(t *testing.T) {
 		})
 	}
 }
+
+var errUnsigned = errors.New("unsigned")
+
+// countingValidator admits the signed images and counts validations of every image.
+type countingValidator struct {
+	validate.ImageValidatorService
+	signed map[string]bool
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (v *countingValidator) ValidateDetailed(_ context.Context, image string) (validate.ImageValidationResult, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.counts[image]++
+	if !v.signed[image] {
+		return validate.ImageValidationResult{Image: image, Outcome: validate.OutcomeDenied}, errUnsigned
+	}
+	return validate.ImageValidationResult{Image: image, Outcome: validate.OutcomeSignatureVerified}, nil
+}
+
+func (v *countingValidator) GetValidator(string) validate.ImageValidatorService {
+	return v
+}
+
+func TestValidatePod_DistinctImages(t *testing.T) {
+	signed, unsigned := "eu.gcr.io/kyma-project/app:1.0", "docker.io/library/busybox:latest"
+	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default",
+		Labels: map[string]string{pkg.NamespaceValidationLabel: pkg.NamespaceValidationEnabled}}}
+	tests := []struct {
+		name           string
+		spec           v1.PodSpec
+		expectedResult validate.ValidationResult
+		expectedCounts map[string]int
+	}{
+		{
+			name: "overlapping images across init and regular containers",
+			spec: v1.PodSpec{
+				InitContainers: []v1.Container{{Name: "init", Image: signed}},
+				Containers: []v1.Container{
+					{Name: "app", Image: signed},
+					{Name: "sidecar", Image: signed},
+				},
+			},
+			expectedResult: validate.Valid,
+			expectedCounts: map[string]int{signed: 1},
+		},
+		{
+			name: "denied init container",
+			spec: v1.PodSpec{
+				InitContainers: []v1.Container{{Name: "init", Image: unsigned}},
+				Containers:     []v1.Container{{Name: "app", Image: signed}, {Name: "sidecar", Image: unsigned}},
+			},
+			expectedResult: validate.Invalid,
+			expectedCounts: map[string]int{signed: 1, unsigned: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//GIVEN
+			validator := &countingValidator{signed: map[string]bool{signed: true}, counts: map[string]int{}}
+			pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default"}, Spec: tt.spec}
+
+			//WHEN
+			result, err := validate.NewPodValidator(validator).ValidatePod(context.TODO(), pod, ns)
+
+			//THEN
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedResult, result)
+			require.Equal(t, tt.expectedCounts, validator.counts)
+		})
+	}
+}
+
+func TestValidatePod_NamespaceOverrides(t *testing.T) {
+	//GIVEN
+	validators, err := validate.NewNamespacedImageValidators(&validate.ServiceConfig{},
+		map[string]validate.ServiceConfigPatch{"tools": {AllowedRegistries: []string{"registry.internal/"}}},
+		validate.WithRepoFactory(validatetest.NewNotary()))
+	require.NoError(t, err)
+	v := validate.NewNamespacedPodValidator(validators)
+	spec := v1.PodSpec{Containers: []v1.Container{{Name: "app", Image: "registry.internal/ci-tools/builder:1.0"}}}
+	namespace := func(name string) *v1.Namespace {
+		return &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name,
+			Labels: map[string]string{pkg.NamespaceValidationLabel: pkg.NamespaceValidationEnabled}}}
+	}
+
+	//WHEN
+	tools, toolsErr := v.ValidatePod(context.TODO(), &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "tools"}, Spec: spec}, namespace("tools"))
+	other, otherErr := v.ValidatePod(context.TODO(), &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}, Spec: spec}, namespace("default"))
+
+	//THEN
+	require.NoError(t, toolsErr)
+	require.Equal(t, validate.Valid, tools)
+	require.NoError(t, otherErr)
+	require.Equal(t, validate.Invalid, other)
+}
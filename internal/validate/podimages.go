@@ -0,0 +1,76 @@
+package validate
+
+import (
+	"context"
+	"errors"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// PodValidationResult is the validation of all containers of a pod.
+type PodValidationResult struct {
+	// Containers are keyed by the container name, names are unique across regular, init and ephemeral containers.
+	Containers map[string]ContainerValidationResult
+}
+
+// ContainerValidationResult is the validation of the container image, containers with the same image share it.
+type ContainerValidationResult struct {
+	Image  string
+	Result ImageValidationResult
+	// Err is the reason of the denial, it's nil when the image is admitted.
+	Err error
+}
+
+// Allowed is true when the images of all containers are admitted.
+func (r PodValidationResult) Allowed() bool {
+	for _, c := range r.Containers {
+		if c.Err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// PodImagesValidator validates the images of regular, init and ephemeral containers of pods.
+type PodImagesValidator struct {
+	validators ImageValidatorFactory
+}
+
+// NewPodImagesValidator returns the validator which validates pods with the image validator of the pod's namespace,
+// so namespace overrides of NewNamespacedImageValidators apply.
+func NewPodImagesValidator(validators ImageValidatorFactory) *PodImagesValidator {
+	return &PodImagesValidator{validators: validators}
+}
+
+// ValidatePod validates every distinct image of the pod once, the images are validated concurrently.
+// Denials are reported per container, the error is returned only when the pod can't be validated at all.
+func (v *PodImagesValidator) ValidatePod(ctx context.Context, pod *corev1.Pod) (PodValidationResult, error) {
+	if pod == nil {
+		return PodValidationResult{}, errors.New("no pod provided")
+	}
+	validator := v.validators.GetValidator(pod.Namespace)
+	validations := validateDistinctImages(ctx, podImages(pod), validator.ValidateDetailed)
+
+	containers := podContainerImages(pod)
+	results := PodValidationResult{Containers: make(map[string]ContainerValidationResult, len(containers))}
+	for name, image := range containers {
+		validation := validations[image]
+		results.Containers[name] = ContainerValidationResult{Image: image, Result: validation.result, Err: validation.err}
+	}
+	return results, nil
+}
+
+// podContainerImages returns the images of regular, init and ephemeral containers keyed by the container name.
+func podContainerImages(pod *corev1.Pod) map[string]string {
+	images := map[string]string{}
+	for _, c := range pod.Spec.Containers {
+		images[c.Name] = c.Image
+	}
+	for _, c := range pod.Spec.InitContainers {
+		images[c.Name] = c.Image
+	}
+	for _, c := range pod.Spec.EphemeralContainers {
+		images[c.Name] = c.Image
+	}
+	return images
+}
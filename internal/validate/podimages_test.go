@@ -0,0 +1,112 @@
+package validate_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kyma-project/warden/internal/validate"
+	"github.com/kyma-project/warden/internal/validate/validatetest"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPodImagesValidator_ValidatePod(t *testing.T) {
+	signed, unsigned := "eu.gcr.io/kyma-project/app:1.0", "docker.io/library/busybox:latest"
+	tests := []struct {
+		name            string
+		spec            corev1.PodSpec
+		expectedAllowed bool
+		expectedCounts  map[string]int
+		expectedImages  map[string]string
+	}{
+		{
+			name: "overlapping images across init and regular containers",
+			spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{{Name: "init", Image: signed}},
+				Containers: []corev1.Container{
+					{Name: "app", Image: signed},
+					{Name: "sidecar", Image: signed},
+				},
+			},
+			expectedAllowed: true,
+			expectedCounts:  map[string]int{signed: 1},
+			expectedImages:  map[string]string{"init": signed, "app": signed, "sidecar": signed},
+		},
+		{
+			name: "denied init container",
+			spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{{Name: "init", Image: unsigned}},
+				Containers:     []corev1.Container{{Name: "app", Image: signed}},
+			},
+			expectedCounts: map[string]int{signed: 1, unsigned: 1},
+			expectedImages: map[string]string{"init": unsigned, "app": signed},
+		},
+		{
+			name: "only ephemeral containers",
+			spec: corev1.PodSpec{
+				EphemeralContainers: []corev1.EphemeralContainer{
+					{EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: "debug", Image: unsigned}},
+				},
+			},
+			expectedCounts: map[string]int{unsigned: 1},
+			expectedImages: map[string]string{"debug": unsigned},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//GIVEN
+			validator := &countingValidator{signed: map[string]bool{signed: true}, counts: map[string]int{}}
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default"}, Spec: tt.spec}
+
+			//WHEN
+			result, err := validate.NewPodImagesValidator(validator).ValidatePod(context.TODO(), pod)
+
+			//THEN
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedAllowed, result.Allowed())
+			require.Equal(t, tt.expectedCounts, validator.counts)
+			require.Len(t, result.Containers, len(tt.expectedImages))
+			for name, image := range tt.expectedImages {
+				container := result.Containers[name]
+				require.Equal(t, image, container.Image)
+				require.Equal(t, image, container.Result.Image)
+				if image == unsigned {
+					require.ErrorIs(t, container.Err, errUnsigned)
+				} else {
+					require.NoError(t, container.Err)
+				}
+			}
+		})
+	}
+}
+
+func TestPodImagesValidator_NamespaceOverrides(t *testing.T) {
+	//GIVEN
+	validators, err := validate.NewNamespacedImageValidators(&validate.ServiceConfig{},
+		map[string]validate.ServiceConfigPatch{"tools": {AllowedRegistries: []string{"registry.internal/"}}},
+		validate.WithRepoFactory(validatetest.NewNotary()))
+	require.NoError(t, err)
+	v := validate.NewPodImagesValidator(validators)
+	spec := corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "registry.internal/ci-tools/builder:1.0"}}}
+
+	//WHEN
+	tools, toolsErr := v.ValidatePod(context.TODO(), &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "tools"}, Spec: spec})
+	other, otherErr := v.ValidatePod(context.TODO(), &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}, Spec: spec})
+
+	//THEN
+	require.NoError(t, toolsErr)
+	require.True(t, tools.Allowed())
+	require.Equal(t, validate.OutcomeAllowedList, tools.Containers["app"].Result.Outcome)
+	require.NoError(t, otherErr)
+	require.False(t, other.Allowed())
+	require.ErrorIs(t, other.Containers["app"].Err, validate.ErrNoTrustData)
+}
+
+func TestPodImagesValidator_NoPod(t *testing.T) {
+	//WHEN
+	_, err := validate.NewPodImagesValidator(&countingValidator{}).ValidatePod(context.TODO(), nil)
+
+	//THEN
+	require.Error(t, err)
+}
@@ -0,0 +1,60 @@
+package validate
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+	"k8s.io/utils/clock"
+)
+
+// notaryRateLimiter is a token bucket shared across all validations which limits requests sent to notary.
+type notaryRateLimiter struct {
+	limiter *rate.Limiter
+	clock   clock.Clock
+	metrics *phaseMetrics
+}
+
+// newNotaryRateLimiter returns nil when rate limiting is disabled.
+func newNotaryRateLimiter(c NotaryConfig, clk clock.Clock, m *phaseMetrics) *notaryRateLimiter {
+	if c.RequestsPerSecond <= 0 {
+		return nil
+	}
+	burst := c.Burst
+	if burst <= 0 {
+		burst = 1
+	}
+	return &notaryRateLimiter{
+		limiter: rate.NewLimiter(rate.Limit(c.RequestsPerSecond), burst),
+		clock:   clk,
+		metrics: m,
+	}
+}
+
+// Wait blocks until the request is allowed or the context is done.
+func (l *notaryRateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	start := l.clock.Now()
+	reservation := l.limiter.ReserveN(start, 1)
+	if !reservation.OK() {
+		return errors.New("notary rate limiter can't reserve request")
+	}
+
+	delay := reservation.DelayFrom(start)
+	if delay > 0 {
+		timer := l.clock.NewTimer(delay)
+		select {
+		case <-timer.C():
+		case <-ctx.Done():
+			timer.Stop()
+			reservation.CancelAt(l.clock.Now())
+			return ctx.Err()
+		}
+	}
+
+	l.metrics.observeRateLimiterWait(l.clock.Since(start))
+	return nil
+}
@@ -0,0 +1,79 @@
+package validate
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/utils/clock"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+// timerCountingClock counts timers created by the limiter for requests which have to wait.
+type timerCountingClock struct {
+	*testingclock.FakeClock
+	timers int32
+}
+
+func (c *timerCountingClock) NewTimer(d time.Duration) clock.Timer {
+	defer atomic.AddInt32(&c.timers, 1)
+	return c.FakeClock.NewTimer(d)
+}
+
+func Test_Validate_NotaryRateLimit(t *testing.T) {
+	//GIVEN
+	const validations = 3
+	fakeClock := &timerCountingClock{FakeClock: testingclock.NewFakeClock(time.Now())}
+	s := NewDefaultMockNotaryService().
+		WithRateLimit(NotaryConfig{RequestsPerSecond: 1, Burst: 1}, fakeClock).
+		Build()
+
+	//WHEN
+	done := make(chan error, validations)
+	for i := 0; i < validations; i++ {
+		go func() {
+			_, err := s.getNotaryImageDigestHash(context.TODO(), "eu.gcr.io/kyma-project/image", "tag")
+			done <- err
+		}()
+	}
+
+	//THEN
+	// the burst allows only one request without waiting
+	require.NoError(t, <-done)
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&fakeClock.timers) == validations-1 }, time.Second, time.Millisecond)
+	require.Len(t, done, 0)
+
+	// every second releases exactly one of the waiting requests
+	for i := 1; i < validations; i++ {
+		fakeClock.Step(time.Second)
+		require.NoError(t, <-done)
+		require.Len(t, done, 0)
+	}
+}
+
+func Test_Validate_NotaryRateLimit_ContextCancelled(t *testing.T) {
+	//GIVEN
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	s := NewDefaultMockNotaryService().
+		WithRateLimit(NotaryConfig{RequestsPerSecond: 1, Burst: 1}, fakeClock).
+		Build()
+	_, err := s.getNotaryImageDigestHash(context.TODO(), "eu.gcr.io/kyma-project/image", "tag")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	//WHEN
+	_, err = s.getNotaryImageDigestHash(ctx, "eu.gcr.io/kyma-project/image", "tag")
+
+	//THEN
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func Test_newNotaryRateLimiter_Disabled(t *testing.T) {
+	l := newNotaryRateLimiter(NotaryConfig{}, testingclock.NewFakeClock(time.Now()), nil)
+	require.Nil(t, l)
+	require.NoError(t, l.Wait(context.TODO()))
+}
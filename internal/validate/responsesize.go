@@ -0,0 +1,105 @@
+package validate
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+const (
+	// DefaultMaxNotaryResponseBytes limits bodies of notary responses, TUF metadata of large repositories fits into it.
+	DefaultMaxNotaryResponseBytes int64 = 5 << 20
+	// DefaultMaxRegistryResponseBytes limits bodies of registry responses, it fits the 4 MiB manifests
+	// accepted by common registries and indexes of many platforms.
+	DefaultMaxRegistryResponseBytes int64 = 4 << 20
+)
+
+// ResponseTooLargeError is returned when the body of a notary or registry response exceeds the limit,
+// it's reported before the body is read when the response declares its length.
+type ResponseTooLargeError struct {
+	URL   string
+	Limit int64
+}
+
+func (e ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("response of %s exceeds the limit of %d bytes", e.URL, e.Limit)
+}
+
+func (e ResponseTooLargeError) Class() ErrorClassification {
+	return ClassInfrastructure
+}
+
+func (e ResponseTooLargeError) Is(target error) bool {
+	return target == ErrResponseTooLarge
+}
+
+func (c NotaryConfig) maxResponseBytes() int64 {
+	if c.MaxResponseBytes <= 0 {
+		return DefaultMaxNotaryResponseBytes
+	}
+	return c.MaxResponseBytes
+}
+
+func (s *notaryService) maxRegistryResponseBytes() int64 {
+	if s.MaxRegistryResponseBytes <= 0 {
+		return DefaultMaxRegistryResponseBytes
+	}
+	return s.MaxRegistryResponseBytes
+}
+
+// responseLimitTransport fails reading response bodies which are longer than limit,
+// so a misbehaving server can't make the webhook allocate more than the limit.
+type responseLimitTransport struct {
+	next  http.RoundTripper
+	limit int64
+}
+
+func (t responseLimitTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	tooLarge := ResponseTooLargeError{URL: r.URL.Redacted(), Limit: t.limit}
+	if resp.ContentLength > t.limit {
+		// the error is returned by the first read, clients keep the chain of read errors but not always of round trip errors
+		resp.Body.Close()
+		resp.Body = io.NopCloser(errReader{err: tooLarge})
+		resp.ContentLength = -1
+		return resp, nil
+	}
+	resp.Body = &limitedBody{ReadCloser: resp.Body, remaining: t.limit, err: tooLarge}
+	return resp, nil
+}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+// limitedBody returns err when more than remaining bytes are read, like http.MaxBytesReader.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	err       error
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, b.err
+	}
+	// one byte more than remaining tells that the body is longer than the limit
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		n = int(b.remaining)
+		b.remaining = -1
+		return n, b.err
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
@@ -0,0 +1,146 @@
+package validate
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// endlessBody streams the body until the client goes away, like a malicious server would.
+func endlessBody(w http.ResponseWriter, _ *http.Request) {
+	chunk := make([]byte, 32<<10)
+	for {
+		if _, err := w.Write(chunk); err != nil {
+			return
+		}
+	}
+}
+
+// allocatedBytes returns the bytes allocated by f.
+func allocatedBytes(f func()) uint64 {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	f()
+	runtime.ReadMemStats(&after)
+	return after.TotalAlloc - before.TotalAlloc
+}
+
+func Test_responseLimitTransport(t *testing.T) {
+	const limit = 1 << 10
+	tests := []struct {
+		name        string
+		handler     http.HandlerFunc
+		expected    string
+		expectedErr bool
+	}{
+		{
+			name: "body within the limit",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.Write([]byte(strings.Repeat("a", limit)))
+			},
+			expected: strings.Repeat("a", limit),
+		},
+		{
+			name:        "endless body",
+			handler:     endlessBody,
+			expectedErr: true,
+		},
+		{
+			name: "declared length above the limit",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Length", strconv.Itoa(limit+1))
+				w.Write([]byte(strings.Repeat("a", limit+1)))
+			},
+			expectedErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//GIVEN
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+			c := &http.Client{Transport: responseLimitTransport{next: http.DefaultTransport, limit: limit}}
+
+			//WHEN
+			resp, err := c.Get(srv.URL + "/metadata")
+			require.NoError(t, err)
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			//THEN
+			if !tt.expectedErr {
+				require.NoError(t, err)
+				require.Equal(t, tt.expected, string(body))
+				return
+			}
+			require.ErrorIs(t, err, ErrResponseTooLarge)
+			require.Equal(t, ResponseTooLargeError{URL: srv.URL + "/metadata", Limit: limit}, err)
+			require.LessOrEqual(t, len(body), limit)
+		})
+	}
+}
+
+func TestNotary_ResponseTooLarge(t *testing.T) {
+	//GIVEN
+	notary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			return
+		}
+		endlessBody(w, r)
+	}))
+	defer notary.Close()
+	s := newNotaryService(&ServiceConfig{
+		NotaryConfig: NotaryConfig{Url: notary.URL, TrustDir: t.TempDir(), MaxResponseBytes: 1 << 20},
+	})
+	digest := "sha256:" + strings.Repeat("a", 64)
+
+	//WHEN
+	var err error
+	start := time.Now()
+	allocated := allocatedBytes(func() {
+		err = s.ValidateWithDigest(context.TODO(), "eu.gcr.io/kyma-project/image:1.0", digest)
+	})
+
+	//THEN
+	require.ErrorIs(t, err, ErrResponseTooLarge)
+	require.Equal(t, "response_too_large", errorClass(err))
+	require.Less(t, time.Since(start), 5*time.Second)
+	require.Less(t, allocated, uint64(32<<20))
+}
+
+func TestRegistry_ResponseTooLarge(t *testing.T) {
+	//GIVEN
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.oci.image.index.v1+json")
+		endlessBody(w, r)
+	}))
+	defer registry.Close()
+	s := NewDefaultMockNotaryService().
+		WithHash(TrustedImageHash).
+		WithRegistryTransport(redirectTransport{host: strings.TrimPrefix(registry.URL, "http://")}).
+		Build()
+	s.MaxRegistryResponseBytes = 1 << 20
+
+	//WHEN
+	var err error
+	start := time.Now()
+	allocated := allocatedBytes(func() {
+		err = s.Validate(context.TODO(), "eu.gcr.io/kyma-project/image:1.0")
+	})
+
+	//THEN
+	require.ErrorIs(t, err, ErrResponseTooLarge)
+	require.Less(t, time.Since(start), 5*time.Second)
+	require.Less(t, allocated, uint64(32<<20))
+}
@@ -0,0 +1,56 @@
+package validate
+
+import (
+	"time"
+
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+type Outcome string
+
+const (
+	// OutcomeAllowedList means the image was admitted because it matches AllowedRegistries.
+	OutcomeAllowedList Outcome = "AllowedList"
+	// OutcomeException means the image was admitted because it matches an exception which hasn't expired.
+	OutcomeException Outcome = "Exception"
+	// OutcomePinnedDigest means the image is pinned to a digest from PinnedDigests.
+	OutcomePinnedDigest Outcome = "PinnedDigest"
+	// OutcomeSignatureVerified means the image digest matches the one signed in notary.
+	OutcomeSignatureVerified Outcome = "SignatureVerified"
+	// OutcomeDenied means the image didn't pass the validation.
+	OutcomeDenied Outcome = "Denied"
+)
+
+// PhaseDurations reports how long each phase of the image validation took.
+type PhaseDurations struct {
+	Notary   time.Duration
+	Registry time.Duration
+	// NotaryRequests and RegistryRequests break the phase durations down by stages of the HTTP requests,
+	// they are empty for phases which didn't send requests, e.g. with the offline trust bundle.
+	NotaryRequests   RequestTimings
+	RegistryRequests RequestTimings
+}
+
+// ImageValidationResult describes how the decision about the image was made.
+type ImageValidationResult struct {
+	Image   string
+	Outcome Outcome
+	// AllowedListEntry is the AllowedRegistries entry which matched the image for OutcomeAllowedList.
+	AllowedListEntry string
+	// ExceptionExpiresAt is the expiry time of the exception which admitted the image for OutcomeException.
+	ExceptionExpiresAt time.Time
+	// ResolvedDigest is the image pinned to the verified digest in the name.Digest format ("<repo>@<algorithm>:<hex>").
+	// Callers can use it to pin the image, because it's the digest compared against notary.
+	ResolvedDigest string
+	// NotaryEndpoint is the notary URL which answered, it's empty for the offline trust bundle.
+	NotaryEndpoint string
+	// NotaryRole is the role which signed the image target.
+	NotaryRole data.RoleName
+	// SigningKeyIDs are the keys which signed the NotaryRole metadata.
+	SigningKeyIDs []string
+	// Warnings describe problems which didn't deny the image, e.g. expired trust metadata accepted within the grace period.
+	Warnings []string
+	// VerifiedBy are the VerificationChain backends which verified the image, it's empty without the chain.
+	VerifiedBy []string
+	Durations  PhaseDurations
+}
@@ -0,0 +1,101 @@
+package validate
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// registryRateLimitRetries is the number of retries after the registry rate limited the request.
+	registryRateLimitRetries = 3
+	// defaultRetryAfter is used when the rate limited response doesn't say how long to wait.
+	defaultRetryAfter = time.Second
+)
+
+// ErrRegistryRateLimited is returned when the registry still rate limits requests after all retries
+// or when waiting for the retry would exceed the deadline.
+var ErrRegistryRateLimited = errors.New("registry rate limited the request")
+
+// rateLimitRetryTransport retries registry requests rejected with 429, or 503 with Retry-After,
+// after the time requested by the registry.
+type rateLimitRetryTransport struct {
+	next       http.RoundTripper
+	maxRetries int
+	metrics    *phaseMetrics
+}
+
+func (t rateLimitRetryTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	// only requests without body can be sent again
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return t.next.RoundTrip(r)
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := t.next.RoundTrip(r)
+		if err != nil {
+			return resp, err
+		}
+		retryAfter, limited := rateLimited(resp)
+		if !limited {
+			return resp, nil
+		}
+		t.metrics.observeRegistryRateLimit(r.URL.Host)
+		drainBody(resp)
+
+		if attempt >= t.maxRetries {
+			return nil, fmt.Errorf("%w: %s responded with %d after %d retries", ErrRegistryRateLimited, r.URL.Host, resp.StatusCode, attempt)
+		}
+		if deadline, ok := r.Context().Deadline(); ok && time.Until(deadline) < retryAfter {
+			return nil, fmt.Errorf("%w: %s asked to retry after %s which exceeds the deadline", ErrRegistryRateLimited, r.URL.Host, retryAfter)
+		}
+
+		timer := time.NewTimer(retryAfter)
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			timer.Stop()
+			return nil, r.Context().Err()
+		}
+	}
+}
+
+// rateLimited returns how long to wait before the request is retried, when the response rate limits it.
+func rateLimited(resp *http.Response) (time.Duration, bool) {
+	retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"))
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests && ok:
+		return retryAfter, true
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return defaultRetryAfter, true
+	case resp.StatusCode == http.StatusServiceUnavailable && ok:
+		return retryAfter, true
+	}
+	return 0, false
+}
+
+// parseRetryAfter supports both delay seconds and HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if d := time.Until(date); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func drainBody(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+}
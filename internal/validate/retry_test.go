@@ -0,0 +1,147 @@
+package validate
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// rateLimitingRegistry rejects the first limitedResponses manifest requests with the given status and Retry-After.
+type rateLimitingRegistry struct {
+	registry         http.Handler
+	status           int
+	retryAfter       string
+	limitedResponses int32
+	manifestRequests int32
+}
+
+func (r *rateLimitingRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodGet && strings.Contains(req.URL.Path, "/manifests/") {
+		if atomic.AddInt32(&r.manifestRequests, 1) <= atomic.LoadInt32(&r.limitedResponses) {
+			if r.retryAfter != "" {
+				w.Header().Set("Retry-After", r.retryAfter)
+			}
+			w.WriteHeader(r.status)
+			return
+		}
+	}
+	r.registry.ServeHTTP(w, req)
+}
+
+func pushTestImageToRateLimitingRegistry(t *testing.T, image string, rl *rateLimitingRegistry) (http.RoundTripper, []byte) {
+	rl.registry = registry.New()
+	srv := httptest.NewServer(rl)
+	t.Cleanup(srv.Close)
+	transport := redirectTransport{host: strings.TrimPrefix(srv.URL, "http://")}
+
+	img, err := random.Image(128, 1)
+	require.NoError(t, err)
+	ref, err := name.ParseReference(image)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img, remote.WithTransport(transport)))
+
+	atomic.StoreInt32(&rl.manifestRequests, 0)
+	return transport, configHash(t, img)
+}
+
+func Test_getImageDigestHash_RegistryRateLimit(t *testing.T) {
+	t.Run("retries after Retry-After until the registry responds", func(t *testing.T) {
+		//GIVEN
+		image := "eu.gcr.io/kyma-project/rate-limited:tag"
+		rl := &rateLimitingRegistry{status: http.StatusTooManyRequests, retryAfter: "0", limitedResponses: 2}
+		transport, hash := pushTestImageToRateLimitingRegistry(t, image, rl)
+		s := NewDefaultMockNotaryService().WithMetrics(prometheus.NewRegistry()).WithRegistryTransport(transport).Build()
+
+		//WHEN
+		result, err := s.getImageDigestHash(context.TODO(), image, SHA256Algorithm)
+
+		//THEN
+		require.NoError(t, err)
+		require.Equal(t, hash, result.config)
+		require.Equal(t, int32(3), atomic.LoadInt32(&rl.manifestRequests))
+		require.Equal(t, float64(2), testutil.ToFloat64(s.metrics.registryRateLimitHits.WithLabelValues("eu.gcr.io")))
+	})
+
+	t.Run("fails after retries are exhausted", func(t *testing.T) {
+		//GIVEN
+		image := "eu.gcr.io/kyma-project/rate-limited:tag"
+		rl := &rateLimitingRegistry{status: http.StatusTooManyRequests, retryAfter: "0", limitedResponses: 100}
+		transport, _ := pushTestImageToRateLimitingRegistry(t, image, rl)
+		s := NewDefaultMockNotaryService().WithRegistryTransport(transport).Build()
+
+		//WHEN
+		_, err := s.getImageDigestHash(context.TODO(), image, SHA256Algorithm)
+
+		//THEN
+		require.ErrorIs(t, err, ErrRegistryRateLimited)
+		require.Equal(t, int32(registryRateLimitRetries+1), atomic.LoadInt32(&rl.manifestRequests))
+	})
+
+	t.Run("doesn't wait beyond the deadline", func(t *testing.T) {
+		//GIVEN
+		image := "eu.gcr.io/kyma-project/unavailable:tag"
+		rl := &rateLimitingRegistry{status: http.StatusServiceUnavailable, retryAfter: "3600", limitedResponses: 1}
+		transport, _ := pushTestImageToRateLimitingRegistry(t, image, rl)
+		s := NewDefaultMockNotaryService().WithRegistryTransport(transport).Build()
+		ctx, cancel := context.WithTimeout(context.TODO(), time.Second)
+		defer cancel()
+		start := time.Now()
+
+		//WHEN
+		_, err := s.getImageDigestHash(ctx, image, SHA256Algorithm)
+
+		//THEN
+		require.ErrorIs(t, err, ErrRegistryRateLimited)
+		require.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("503 without Retry-After is not retried", func(t *testing.T) {
+		//GIVEN
+		image := "eu.gcr.io/kyma-project/unavailable:tag"
+		rl := &rateLimitingRegistry{status: http.StatusServiceUnavailable, limitedResponses: 1}
+		transport, _ := pushTestImageToRateLimitingRegistry(t, image, rl)
+		s := NewDefaultMockNotaryService().WithRegistryTransport(transport).Build()
+
+		//WHEN
+		_, err := s.getImageDigestHash(context.TODO(), image, SHA256Algorithm)
+
+		//THEN
+		require.Error(t, err)
+		require.NotErrorIs(t, err, ErrRegistryRateLimited)
+		require.Equal(t, int32(1), atomic.LoadInt32(&rl.manifestRequests))
+	})
+}
+
+func Test_parseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{name: "seconds", value: "5", expected: 5 * time.Second, ok: true},
+		{name: "date in the past", value: "Wed, 21 Oct 2015 07:28:00 GMT", expected: 0, ok: true},
+		{name: "empty", value: "", ok: false},
+		{name: "negative", value: "-1", ok: false},
+		{name: "invalid", value: "soon", ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, ok := parseRetryAfter(tt.value)
+			require.Equal(t, tt.ok, ok)
+			require.Equal(t, tt.expected, d)
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package validate
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"unicode"
+)
+
+// Limits of the OCI reference grammar, see https://github.com/distribution/reference.
+const (
+	// maxImageNameLength limits the repository name including the registry host.
+	maxImageNameLength = 255
+	maxTagLength       = 128
+	// maxHostLabelLength is the DNS limit of a registry host component.
+	maxHostLabelLength = 63
+)
+
+// ImageReferenceError is returned for image references which don't follow the OCI reference grammar.
+// Position is the 1-based position of the offending character in the trimmed reference,
+// it's zero when the problem isn't a single character, e.g. a too long name.
+type ImageReferenceError struct {
+	Image    string
+	Position int
+	Reason   string
+}
+
+func (e ImageReferenceError) Error() string {
+	return fmt.Sprintf("invalid image reference %q: %s", e.Image, e.Reason)
+}
+
+func (e ImageReferenceError) Class() ErrorClassification {
+	return ClassPolicy
+}
+
+// Is makes the error match errMalformedImageName, so it's denied like other references which can't be parsed.
+func (e ImageReferenceError) Is(target error) bool {
+	return target == errMalformedImageName
+}
+
+// sanitizeImageRef trims whitespace around the image reference and checks the characters and lengths of its parts.
+// The structure of the reference, e.g. a missing tag, is checked later by parseImageRef.
+func sanitizeImageRef(image string) (string, error) {
+	ref := strings.TrimSpace(image)
+	for i, r := range []rune(ref) {
+		switch {
+		case r > unicode.MaxASCII:
+			return "", referenceError(ref, i+1, fmt.Sprintf("non-ASCII character %q (%U) at position %d", r, r, i+1))
+		case unicode.IsSpace(r) || unicode.IsControl(r):
+			return "", referenceError(ref, i+1, fmt.Sprintf("whitespace or control character %q at position %d", r, i+1))
+		}
+	}
+
+	// the reference is ASCII from now on, so byte offsets are positions
+	name := ref
+	if i := strings.Index(name, digestDelim); i >= 0 {
+		name = name[:i]
+	}
+	tagStart := -1
+	if i := strings.LastIndex(name, tagDelim); i >= 0 && i > strings.LastIndex(name, "/") {
+		tagStart = i + len(tagDelim)
+		if err := checkTag(ref, name[tagStart:], tagStart); err != nil {
+			return "", err
+		}
+		name = name[:i]
+	}
+	if len(name) > maxImageNameLength {
+		return "", referenceError(ref, 0, fmt.Sprintf("repository name is %d characters long, at most %d are allowed", len(name), maxImageNameLength))
+	}
+
+	path, offset := name, 0
+	if hasRegistryHost(name) {
+		host := name[:strings.Index(name, "/")]
+		if err := checkRegistryHost(ref, host); err != nil {
+			return "", err
+		}
+		path, offset = name[len(host)+1:], len(host)+1
+	}
+	if err := checkRepositoryPath(ref, path, offset); err != nil {
+		return "", err
+	}
+	return ref, nil
+}
+
+func referenceError(image string, position int, reason string) error {
+	return ImageReferenceError{Image: image, Position: position, Reason: reason}
+}
+
+func checkTag(ref, tag string, offset int) error {
+	if len(tag) > maxTagLength {
+		return referenceError(ref, 0, fmt.Sprintf("tag is %d characters long, at most %d are allowed", len(tag), maxTagLength))
+	}
+	for i, c := range tag {
+		pos := offset + i + 1
+		if !isAlphanumeric(c) && c != '_' && c != '.' && c != '-' {
+			return referenceError(ref, pos, fmt.Sprintf("character %q at position %d is not allowed in tags", c, pos))
+		}
+		if i == 0 && (c == '.' || c == '-') {
+			return referenceError(ref, pos, fmt.Sprintf("tag can't start with %q at position %d", c, pos))
+		}
+	}
+	return nil
+}
+
+// checkRegistryHost checks the DNS name or IP and the port of the registry, hosts are case-insensitive.
+func checkRegistryHost(ref, host string) error {
+	if strings.HasPrefix(host, "[") || net.ParseIP(host) != nil {
+		// IPv6 hosts are validated when they're normalized
+		return nil
+	}
+	name, port, hasPort := strings.Cut(host, ":")
+	if hasPort {
+		for i, c := range port {
+			if c < '0' || c > '9' {
+				pos := len(name) + 1 + i + 1
+				return referenceError(ref, pos, fmt.Sprintf("character %q at position %d is not allowed in registry ports", c, pos))
+			}
+		}
+	}
+	offset := 0
+	for _, label := range strings.Split(name, ".") {
+		if len(label) > maxHostLabelLength {
+			return referenceError(ref, 0, fmt.Sprintf("registry host component %q is %d characters long, at most %d are allowed", label, len(label), maxHostLabelLength))
+		}
+		for i, c := range label {
+			if !isAlphanumeric(c) && c != '-' {
+				pos := offset + i + 1
+				return referenceError(ref, pos, fmt.Sprintf("character %q at position %d is not allowed in registry hosts", c, pos))
+			}
+		}
+		offset += len(label) + 1
+	}
+	return nil
+}
+
+// checkRepositoryPath checks the path components, they are lowercase letters and digits joined by separators:
+// a period, one or two underscores or any number of dashes.
+func checkRepositoryPath(ref, path string, offset int) error {
+	if path == "" {
+		return nil
+	}
+	for _, component := range strings.Split(path, "/") {
+		if component == "" {
+			return referenceError(ref, offset+1, fmt.Sprintf("empty path component at position %d", offset+1))
+		}
+		for i, c := range component {
+			pos := offset + i + 1
+			switch {
+			case c >= 'A' && c <= 'Z':
+				return referenceError(ref, pos, fmt.Sprintf("uppercase character %q at position %d, repository paths must be lowercase", c, pos))
+			case !isLowerAlphanumeric(c) && c != '.' && c != '_' && c != '-':
+				return referenceError(ref, pos, fmt.Sprintf("character %q at position %d is not allowed in repository paths", c, pos))
+			}
+		}
+		if err := checkPathSeparators(ref, component, offset); err != nil {
+			return err
+		}
+		offset += len(component) + 1
+	}
+	return nil
+}
+
+func checkPathSeparators(ref, component string, offset int) error {
+	if !isLowerAlphanumeric(rune(component[0])) || !isLowerAlphanumeric(rune(component[len(component)-1])) {
+		return referenceError(ref, offset+1, fmt.Sprintf("path component %q at position %d must start and end with a lowercase letter or digit", component, offset+1))
+	}
+	for start := 0; start < len(component); {
+		if isLowerAlphanumeric(rune(component[start])) {
+			start++
+			continue
+		}
+		end := start
+		for end < len(component) && !isLowerAlphanumeric(rune(component[end])) {
+			end++
+		}
+		separator := component[start:end]
+		if separator != "." && separator != "_" && separator != "__" && strings.Trim(separator, "-") != "" {
+			pos := offset + start + 1
+			return referenceError(ref, pos, fmt.Sprintf("separator %q at position %d is not allowed in repository paths", separator, pos))
+		}
+		start = end
+	}
+	return nil
+}
+
+func isLowerAlphanumeric(c rune) bool {
+	return (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9')
+}
+
+func isAlphanumeric(c rune) bool {
+	return isLowerAlphanumeric(c) || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,177 @@
+package validate
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_sanitizeImageRef(t *testing.T) {
+	tests := []struct {
+		name             string
+		image            string
+		expected         string
+		expectedPosition int
+		expectedReason   string
+	}{
+		{
+			name:     "valid reference",
+			image:    "eu.gcr.io/kyma-project/function-controller:v1.0_rc-1",
+			expected: "eu.gcr.io/kyma-project/function-controller:v1.0_rc-1",
+		},
+		{
+			name:     "surrounding whitespace is trimmed",
+			image:    " \teu.gcr.io/kyma-project/image:tag\n",
+			expected: "eu.gcr.io/kyma-project/image:tag",
+		},
+		{
+			name:     "path separators",
+			image:    "registry.example.com:5000/a.b/c_d/e__f/g---h:tag",
+			expected: "registry.example.com:5000/a.b/c_d/e__f/g---h:tag",
+		},
+		{
+			name:     "uppercase registry host and tag",
+			image:    "Registry.Example.com/image:Latest",
+			expected: "Registry.Example.com/image:Latest",
+		},
+		{
+			name:     "IPv6 registry host",
+			image:    "[2001:db8::1]:5000/app:v1@sha256:0a0b",
+			expected: "[2001:db8::1]:5000/app:v1@sha256:0a0b",
+		},
+		{
+			name:     "structure is checked by the parser",
+			image:    "makapaka",
+			expected: "makapaka",
+		},
+		{
+			name:             "uppercase repository path",
+			image:            "eu.gcr.io/kyma-project/Image:tag",
+			expectedPosition: 24,
+			expectedReason:   "uppercase character 'I' at position 24, repository paths must be lowercase",
+		},
+		{
+			name:             "uppercase Docker Hub repository",
+			image:            "Nginx:1.23",
+			expectedPosition: 1,
+			expectedReason:   "uppercase character 'N' at position 1, repository paths must be lowercase",
+		},
+		{
+			name:             "non-ASCII character",
+			image:            "eu.gcr.io/kyma-projéct/image:tag",
+			expectedPosition: 20,
+			expectedReason:   "non-ASCII character 'é' (U+00E9) at position 20",
+		},
+		{
+			name:             "non-ASCII lookalike",
+			image:            "eu.gcr.io/kyma-project/іmage:tag",
+			expectedPosition: 24,
+			expectedReason:   "non-ASCII character 'і' (U+0456) at position 24",
+		},
+		{
+			name:             "whitespace inside",
+			image:            "eu.gcr.io/kyma-project/image :tag",
+			expectedPosition: 29,
+			expectedReason:   "whitespace or control character ' ' at position 29",
+		},
+		{
+			name:             "not allowed path character",
+			image:            "eu.gcr.io/kyma-project/image!:tag",
+			expectedPosition: 29,
+			expectedReason:   "character '!' at position 29 is not allowed in repository paths",
+		},
+		{
+			name:             "path component starting with separator",
+			image:            "eu.gcr.io/-kyma/image:tag",
+			expectedPosition: 11,
+			expectedReason:   `path component "-kyma" at position 11 must start and end with a lowercase letter or digit`,
+		},
+		{
+			name:             "invalid path separator",
+			image:            "eu.gcr.io/kyma..project/image:tag",
+			expectedPosition: 15,
+			expectedReason:   `separator ".." at position 15 is not allowed in repository paths`,
+		},
+		{
+			name:             "empty path component",
+			image:            "eu.gcr.io/kyma-project//image:tag",
+			expectedPosition: 24,
+			expectedReason:   "empty path component at position 24",
+		},
+		{
+			name:             "not allowed registry host character",
+			image:            "eu_gcr.io/kyma-project/image:tag",
+			expectedPosition: 3,
+			expectedReason:   "character '_' at position 3 is not allowed in registry hosts",
+		},
+		{
+			name:             "not numeric registry port",
+			image:            "registry.example.com:50a0/image:tag",
+			expectedPosition: 24,
+			expectedReason:   "character 'a' at position 24 is not allowed in registry ports",
+		},
+		{
+			name:           "too long registry host component",
+			image:          strings.Repeat("r", 64) + ".example.com/image:tag",
+			expectedReason: `registry host component "` + strings.Repeat("r", 64) + `" is 64 characters long, at most 63 are allowed`,
+		},
+		{
+			name:           "too long repository name",
+			image:          "eu.gcr.io/" + strings.Repeat("a", 250) + ":tag",
+			expectedReason: "repository name is 260 characters long, at most 255 are allowed",
+		},
+		{
+			name:           "too long tag",
+			image:          "eu.gcr.io/kyma-project/image:" + strings.Repeat("1", 129),
+			expectedReason: "tag is 129 characters long, at most 128 are allowed",
+		},
+		{
+			name:             "not allowed tag character",
+			image:            "eu.gcr.io/kyma-project/image:v1+build",
+			expectedPosition: 32,
+			expectedReason:   "character '+' at position 32 is not allowed in tags",
+		},
+		{
+			name:             "tag starting with a period",
+			image:            "eu.gcr.io/kyma-project/image:.v1",
+			expectedPosition: 30,
+			expectedReason:   "tag can't start with '.' at position 30",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//WHEN
+			got, err := sanitizeImageRef(tt.image)
+
+			//THEN
+			if tt.expectedReason == "" {
+				require.NoError(t, err)
+				require.Equal(t, tt.expected, got)
+				return
+			}
+			var refErr ImageReferenceError
+			require.ErrorAs(t, err, &refErr)
+			require.Equal(t, tt.expectedReason, refErr.Reason)
+			require.Equal(t, tt.expectedPosition, refErr.Position)
+			require.Equal(t, strings.TrimSpace(tt.image), refErr.Image)
+			require.ErrorIs(t, err, errMalformedImageName)
+		})
+	}
+}
+
+func Test_Validate_MalformedImageReference(t *testing.T) {
+	//GIVEN
+	s := NewDefaultMockNotaryService().WithHash(TrustedImageHash).Build()
+
+	//WHEN
+	result, err := s.ValidateDetailed(context.TODO(), "eu.gcr.io/Kyma-project/image:tag")
+
+	//THEN
+	require.EqualError(t, err, `invalid image reference "eu.gcr.io/Kyma-project/image:tag": uppercase character 'K' at position 11, repository paths must be lowercase`)
+	require.Equal(t, OutcomeDenied, result.Outcome)
+	require.True(t, isDeterministicDenial(err))
+	require.False(t, errors.Is(err, ErrNotaryUnavailable))
+}
@@ -0,0 +1,66 @@
+package validate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// ErrUnsupportedManifestSchema is wrapped by UnsupportedManifestSchemaError, so callers can match it with errors.Is.
+var ErrUnsupportedManifestSchema = errors.New("unsupported manifest schema")
+
+// UnsupportedManifestSchemaError is returned for images pushed with the deprecated Docker manifest schema1,
+// unless ServiceConfig.AllowSchema1 is set.
+type UnsupportedManifestSchemaError struct {
+	Image     string
+	MediaType types.MediaType
+}
+
+func (e UnsupportedManifestSchemaError) Error() string {
+	return fmt.Sprintf("image %s uses the deprecated manifest schema %s, re-push it with a current docker or OCI client", e.Image, e.MediaType)
+}
+
+func (e UnsupportedManifestSchemaError) Class() ErrorClassification {
+	return ClassPolicy
+}
+
+func (e UnsupportedManifestSchemaError) Unwrap() error {
+	return ErrUnsupportedManifestSchema
+}
+
+func isSchema1(mediaType types.MediaType) bool {
+	return mediaType == types.DockerManifestSchema1 || mediaType == types.DockerManifestSchema1Signed
+}
+
+// schema1Manifest has only the fields of the schema1 manifest needed for the compatibility hash.
+type schema1Manifest struct {
+	History []struct {
+		V1Compatibility string `json:"v1Compatibility"`
+	} `json:"history"`
+}
+
+// schema1Hash returns the hash of the v1 compatibility config of the top layer,
+// schema1 manifests don't reference the image config which is compared for other images.
+func (s *notaryService) schema1Hash(image string, desc *remote.Descriptor, algorithm string) ([]byte, error) {
+	if !s.AllowSchema1 {
+		return []byte{}, UnsupportedManifestSchemaError{Image: image, MediaType: desc.MediaType}
+	}
+
+	m := schema1Manifest{}
+	if err := json.Unmarshal(desc.Manifest, &m); err != nil {
+		return []byte{}, fmt.Errorf("schema1 manifest: %w", err)
+	}
+	if len(m.History) == 0 || m.History[0].V1Compatibility == "" {
+		return []byte{}, fmt.Errorf("schema1 manifest of %s has no v1 compatibility config", image)
+	}
+
+	h, err := newHash(algorithm)
+	if err != nil {
+		return []byte{}, err
+	}
+	h.Write([]byte(m.History[0].V1Compatibility))
+	return h.Sum(nil), nil
+}
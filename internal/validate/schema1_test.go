@@ -0,0 +1,94 @@
+package validate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/require"
+)
+
+const schema1V1Compatibility = `{"id":"5d2a9f3c","created":"2016-01-01T00:00:00Z","container_config":{"Cmd":["/bin/sh"]}}`
+
+var schema1TestManifest = `{
+  "schemaVersion": 1,
+  "name": "kyma-project/legacy",
+  "tag": "1.0",
+  "architecture": "amd64",
+  "fsLayers": [{"blobSum": "sha256:a3ed95caeb02ffe68cdd9fd84406680ae93d633cb16422d00e8a7c22955b46d4"}],
+  "history": [{"v1Compatibility": ` + jsonString(schema1V1Compatibility) + `}]
+}`
+
+func jsonString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func TestValidate_Schema1(t *testing.T) {
+	image := "eu.gcr.io/kyma-project/legacy:1.0"
+	srv := httptest.NewServer(registry.New())
+	defer srv.Close()
+	transport := redirectTransport{host: strings.TrimPrefix(srv.URL, "http://")}
+	ref, err := name.ParseReference(image)
+	require.NoError(t, err)
+	legacy := rawManifest{mediaType: types.DockerManifestSchema1Signed, manifest: schema1TestManifest}
+	require.NoError(t, remote.Put(ref, legacy, remote.WithTransport(transport)))
+	v1CompatibilityHash := sha256.Sum256([]byte(schema1V1Compatibility))
+
+	t.Run("schema1 is denied by default", func(t *testing.T) {
+		//GIVEN
+		s := NewDefaultMockNotaryService().
+			WithHash(v1CompatibilityHash[:]).
+			WithRegistryTransport(transport).
+			Build()
+
+		//WHEN
+		err := s.Validate(context.TODO(), image)
+
+		//THEN
+		var schemaErr UnsupportedManifestSchemaError
+		require.ErrorAs(t, err, &schemaErr)
+		require.ErrorIs(t, err, ErrUnsupportedManifestSchema)
+		require.Equal(t, image, schemaErr.Image)
+		require.Equal(t, types.DockerManifestSchema1Signed, schemaErr.MediaType)
+		require.ErrorContains(t, err, "re-push it")
+		require.True(t, isDeterministicDenial(err))
+	})
+
+	t.Run("schema1 is compared by the v1 compatibility hash when it's allowed", func(t *testing.T) {
+		//GIVEN
+		s := NewDefaultMockNotaryService().
+			WithHash(v1CompatibilityHash[:]).
+			WithRegistryTransport(transport).
+			Build()
+		s.AllowSchema1 = true
+
+		//WHEN
+		err := s.Validate(context.TODO(), image)
+
+		//THEN
+		require.NoError(t, err)
+	})
+
+	t.Run("schema1 with another signed hash is denied when it's allowed", func(t *testing.T) {
+		//GIVEN
+		s := NewDefaultMockNotaryService().
+			WithHash([]byte("another-hash")).
+			WithRegistryTransport(transport).
+			Build()
+		s.AllowSchema1 = true
+
+		//WHEN
+		err := s.Validate(context.TODO(), image)
+
+		//THEN
+		require.ErrorContains(t, err, "unexpected image hash value")
+	})
+}
@@ -0,0 +1,118 @@
+package validate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+// signingKeyReader is implemented by notary repositories, the offline trust bundle doesn't keep signatures.
+type signingKeyReader interface {
+	GetAllTargetMetadataByName(name string) ([]client.TargetSignedStruct, error)
+}
+
+// signingKeyIDs returns sorted IDs of the keys which signed the role metadata containing the tag.
+// Key IDs are recorded only for audit, so lookup errors leave them empty instead of failing the validation.
+func signingKeyIDs(r targetReader, tag string, role data.RoleName) []string {
+	keyIDs, err := lookupSigningKeyIDs(r, tag, role)
+	if err != nil {
+		return nil
+	}
+	return keyIDs
+}
+
+// lookupSigningKeyIDs is signingKeyIDs which returns lookup errors,
+// readers without signatures return no key IDs.
+func lookupSigningKeyIDs(r targetReader, tag string, role data.RoleName) ([]string, error) {
+	keyReader, ok := r.(signingKeyReader)
+	if !ok {
+		return nil, nil
+	}
+	metadata, err := keyReader.GetAllTargetMetadataByName(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]struct{}{}
+	var keyIDs []string
+	for _, m := range metadata {
+		if m.Role.Name != role {
+			continue
+		}
+		for _, sig := range m.Signatures {
+			if _, ok := seen[sig.KeyID]; ok {
+				continue
+			}
+			seen[sig.KeyID] = struct{}{}
+			keyIDs = append(keyIDs, sig.KeyID)
+		}
+	}
+	sort.Strings(keyIDs)
+	return keyIDs, nil
+}
+
+// shortKeyIDLength is the length of key IDs in errors, like the short IDs printed by the notary CLI.
+const shortKeyIDLength = 7
+
+// SignerKeyError is returned when none of the keys which signed the target is required for the repository.
+type SignerKeyError struct {
+	Expected []string
+	Actual   []string
+}
+
+func (e SignerKeyError) Error() string {
+	return fmt.Sprintf("image target not signed by required keys, expected one of [%s], signed by [%s]",
+		shortKeyIDs(e.Expected), shortKeyIDs(e.Actual))
+}
+
+func (e SignerKeyError) Class() ErrorClassification {
+	return ClassPolicy
+}
+
+func shortKeyIDs(keyIDs []string) string {
+	short := make([]string, len(keyIDs))
+	for i, id := range keyIDs {
+		if len(id) > shortKeyIDLength {
+			id = id[:shortKeyIDLength]
+		}
+		short[i] = id
+	}
+	return strings.Join(short, " ")
+}
+
+// requiredSignerKeyIDsFor returns the signer key IDs for the longest repository prefix matching imgRepo.
+func (s *notaryService) requiredSignerKeyIDsFor(imgRepo string) ([]string, bool) {
+	var keyIDs []string
+	longest := -1
+	for prefix, ids := range s.RequiredSignerKeyIDs {
+		if strings.HasPrefix(imgRepo, prefix) && len(prefix) > longest {
+			keyIDs = ids
+			longest = len(prefix)
+		}
+	}
+	return keyIDs, longest >= 0
+}
+
+// verifySignerKeys returns the keys which signed the role metadata containing the tag,
+// at least one of them must be required for the repository when RequiredSignerKeyIDs matches it.
+func (s *notaryService) verifySignerKeys(r targetReader, imgRepo, tag string, role data.RoleName) ([]string, error) {
+	required, ok := s.requiredSignerKeyIDsFor(imgRepo)
+	if !ok {
+		return signingKeyIDs(r, tag, role), nil
+	}
+	keyIDs, err := lookupSigningKeyIDs(r, tag, role)
+	if err != nil {
+		return nil, notaryError(err)
+	}
+	for _, id := range keyIDs {
+		for _, requiredID := range required {
+			if id == requiredID {
+				return keyIDs, nil
+			}
+		}
+	}
+	return nil, SignerKeyError{Expected: required, Actual: keyIDs}
+}
@@ -0,0 +1,154 @@
+package validate
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+func TestValidateDetailed_SigningRoleAndKeys(t *testing.T) {
+	//GIVEN
+	image := "eu.gcr.io/kyma-project/signed:1.0"
+	registryTransport, img := pushTestImageAs(t, image)
+	f := NewDefaultMockNotaryFunction().
+		WithRoleHashes(map[data.RoleName][]byte{NotaryReleasesRole: configHash(t, img)}).
+		Build()
+	s := NewDefaultMockNotaryService().
+		WithFunc(f).
+		WithSigningKeys(map[data.RoleName][]string{
+			NotaryReleasesRole:        {"release-key-2", "release-key-1", "release-key-1"},
+			data.CanonicalTargetsRole: {"targets-key"},
+		}).
+		WithRegistryTransport(registryTransport).
+		Build()
+
+	//WHEN
+	result, err := s.ValidateDetailed(context.TODO(), image)
+
+	//THEN
+	require.NoError(t, err)
+	require.Equal(t, OutcomeSignatureVerified, result.Outcome)
+	require.Equal(t, NotaryReleasesRole, result.NotaryRole)
+	require.Equal(t, []string{"release-key-1", "release-key-2"}, result.SigningKeyIDs)
+}
+
+func Test_signingKeyIDs(t *testing.T) {
+	t.Run("metadata lookup error leaves keys empty", func(t *testing.T) {
+		r := MockNotaryClientRepository{
+			GetAllTargetMetadataByNameFunc: func(string) ([]client.TargetSignedStruct, error) {
+				return nil, errors.New("notary unavailable")
+			},
+		}
+
+		require.Empty(t, signingKeyIDs(r, "1.0", data.CanonicalTargetsRole))
+	})
+
+	t.Run("reader without signatures", func(t *testing.T) {
+		r := targetOnlyReader{}
+
+		require.Empty(t, signingKeyIDs(r, "1.0", data.CanonicalTargetsRole))
+	})
+}
+
+// targetOnlyReader reads targets like the offline trust bundle, without signatures.
+type targetOnlyReader struct{}
+
+func (targetOnlyReader) GetTargetByName(name string, _ ...data.RoleName) (*client.TargetWithRole, error) {
+	return nil, client.ErrNoSuchTarget(name)
+}
+
+func TestValidate_RequiredSignerKeyIDs(t *testing.T) {
+	image := "eu.gcr.io/kyma-project/signed:1.0"
+	registryTransport, img := pushTestImageAs(t, image)
+	releaseKey := "4f1a9c3e7b2d8a6f0c5e1b9d3a7f2c8e6b0d4a9f1c3e5b7d9a2f4c6e8b0d1a3f"
+	otherKey := "9b8e7d6c5a4f3e2d1c0b9a8f7e6d5c4b3a2f1e0d9c8b7a6f5e4d3c2b1a0f9e8d"
+
+	testCases := []struct {
+		name          string
+		required      map[string][]string
+		signingKeys   []string
+		expectedError string
+	}{
+		{
+			name:        "repository without required keys",
+			required:    map[string][]string{"eu.gcr.io/other": {releaseKey}},
+			signingKeys: []string{otherKey},
+		},
+		{
+			name:        "signed by required key",
+			required:    map[string][]string{"eu.gcr.io/kyma-project": {releaseKey}},
+			signingKeys: []string{otherKey, releaseKey},
+		},
+		{
+			name:          "signed by other key",
+			required:      map[string][]string{"eu.gcr.io/kyma-project": {releaseKey}},
+			signingKeys:   []string{otherKey},
+			expectedError: "image target not signed by required keys, expected one of [4f1a9c3], signed by [9b8e7d6]",
+		},
+		{
+			name: "longest prefix applies",
+			required: map[string][]string{
+				"eu.gcr.io":                     {otherKey},
+				"eu.gcr.io/kyma-project/signed": {releaseKey},
+			},
+			signingKeys:   []string{otherKey},
+			expectedError: "image target not signed by required keys, expected one of [4f1a9c3], signed by [9b8e7d6]",
+		},
+		{
+			name:          "no signatures",
+			required:      map[string][]string{"eu.gcr.io/kyma-project": {releaseKey, otherKey}},
+			expectedError: "image target not signed by required keys, expected one of [4f1a9c3 9b8e7d6], signed by []",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			//GIVEN
+			f := NewDefaultMockNotaryFunction().
+				WithRoleHashes(map[data.RoleName][]byte{NotaryReleasesRole: configHash(t, img)}).
+				Build()
+			s := NewDefaultMockNotaryService().
+				WithFunc(f).
+				WithSigningKeys(map[data.RoleName][]string{NotaryReleasesRole: tt.signingKeys}).
+				WithRequiredSignerKeyIDs(tt.required).
+				WithRegistryTransport(registryTransport).
+				Build()
+
+			//WHEN
+			err := s.Validate(context.TODO(), image)
+
+			//THEN
+			if tt.expectedError == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.expectedError)
+			require.True(t, isDeterministicDenial(err))
+		})
+	}
+
+	t.Run("metadata lookup error fails the validation", func(t *testing.T) {
+		//GIVEN
+		f := NewDefaultMockNotaryFunction().WithHash(configHash(t, img)).Build()
+		s := NewDefaultMockNotaryService().
+			WithRepoFactory(MockNotaryRepoFactory{
+				GetTargetByNameFunc: &f,
+				GetAllTargetMetadataByNameFunc: func(string) ([]client.TargetSignedStruct, error) {
+					return nil, errors.New("notary unavailable")
+				},
+			}).
+			WithRequiredSignerKeyIDs(map[string][]string{"eu.gcr.io/kyma-project": {releaseKey}}).
+			WithRegistryTransport(registryTransport).
+			Build()
+
+		//WHEN
+		err := s.Validate(context.TODO(), image)
+
+		//THEN
+		require.EqualError(t, err, "notary unavailable")
+		require.False(t, isDeterministicDenial(err))
+	})
+}
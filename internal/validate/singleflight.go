@@ -0,0 +1,35 @@
+package validate
+
+import (
+	"context"
+)
+
+// verifyShared runs one verification for concurrent callers validating the same image reference.
+// The shared verification isn't cancelled when the caller which started it gives up.
+func (s *notaryService) verifyShared(ctx context.Context, ref imageRef) (ImageValidationResult, error) {
+	if s.flights == nil {
+		return s.verify(ctx, ref)
+	}
+
+	platform := s.platformFor(ctx)
+	flight := s.flights.DoChan(ref.String()+" "+platform, func() (interface{}, error) {
+		flightCtx, cancel := detachedContext(ctx)
+		defer cancel()
+		return s.verify(ContextWithPlatform(flightCtx, platform), ref)
+	})
+
+	select {
+	case r := <-flight:
+		return r.Val.(ImageValidationResult), r.Err
+	case <-ctx.Done():
+		return ImageValidationResult{Outcome: OutcomeDenied}, ctx.Err()
+	}
+}
+
+// detachedContext keeps the deadline of ctx, but it's not cancelled together with ctx.
+func detachedContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if deadline, ok := ctx.Deadline(); ok {
+		return context.WithDeadline(context.Background(), deadline)
+	}
+	return context.WithCancel(context.Background())
+}
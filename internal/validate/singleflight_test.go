@@ -0,0 +1,106 @@
+package validate
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+// blockingNotary counts notary lookups, which block until release is closed.
+type blockingNotary struct {
+	calls   int32
+	release chan struct{}
+}
+
+func newBlockingNotary() *blockingNotary {
+	return &blockingNotary{release: make(chan struct{})}
+}
+
+func (n *blockingNotary) GetTargetByName(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+	atomic.AddInt32(&n.calls, 1)
+	<-n.release
+	return nil, client.ErrNoSuchTarget(name)
+}
+
+func Test_Validate_ConcurrentIdenticalValidationsShareOneLookup(t *testing.T) {
+	//GIVEN
+	const validations = 50
+	notary := newBlockingNotary()
+	s := NewDefaultMockNotaryService().WithFunc(notary.GetTargetByName).WithSingleflight().Build()
+
+	//WHEN
+	wg := sync.WaitGroup{}
+	errs := make(chan error, validations)
+	for i := 0; i < validations; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.ValidateDetailed(context.TODO(), "eu.gcr.io/kyma-project/image:tag")
+			errs <- err
+		}()
+	}
+	// give all validations time to join the flight
+	time.Sleep(100 * time.Millisecond)
+	close(notary.release)
+	wg.Wait()
+	close(errs)
+
+	//THEN
+	require.Equal(t, int32(1), atomic.LoadInt32(&notary.calls))
+	for err := range errs {
+		require.ErrorAs(t, err, new(client.ErrNoSuchTarget))
+	}
+}
+
+func Test_Validate_CancelledWaiterDoesNotCancelSharedLookup(t *testing.T) {
+	//GIVEN
+	image := "eu.gcr.io/kyma-project/image:tag"
+	notary := newBlockingNotary()
+	s := NewDefaultMockNotaryService().WithFunc(notary.GetTargetByName).WithSingleflight().Build()
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := s.ValidateDetailed(ctx, image)
+		firstErr <- err
+	}()
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&notary.calls) == 1 }, time.Second, time.Millisecond)
+
+	secondErr := make(chan error, 1)
+	go func() {
+		_, err := s.ValidateDetailed(context.TODO(), image)
+		secondErr <- err
+	}()
+	// give the second validation time to join the flight
+	time.Sleep(100 * time.Millisecond)
+
+	//WHEN
+	cancel()
+	require.ErrorIs(t, <-firstErr, context.Canceled)
+	close(notary.release)
+
+	//THEN
+	err := <-secondErr
+	require.ErrorAs(t, err, new(client.ErrNoSuchTarget))
+	require.Equal(t, int32(1), atomic.LoadInt32(&notary.calls))
+}
+
+func Test_Validate_DifferentReferencesAreNotShared(t *testing.T) {
+	//GIVEN
+	notary := newBlockingNotary()
+	close(notary.release)
+	s := NewDefaultMockNotaryService().WithFunc(notary.GetTargetByName).WithSingleflight().Build()
+
+	//WHEN
+	_, _ = s.ValidateDetailed(context.TODO(), "eu.gcr.io/kyma-project/image:v1")
+	_, _ = s.ValidateDetailed(context.TODO(), "eu.gcr.io/kyma-project/image:v2")
+
+	//THEN
+	require.Equal(t, int32(2), atomic.LoadInt32(&notary.calls))
+}
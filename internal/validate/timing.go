@@ -0,0 +1,181 @@
+package validate
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// RequestTimings breaks down the time of the HTTP requests of a validation phase by their stages,
+// the stages of all requests of the phase are summed up.
+type RequestTimings struct {
+	Requests     int
+	DNS          time.Duration
+	Connect      time.Duration
+	TLSHandshake time.Duration
+	// FirstByte is the time between sending the request and receiving the first byte of the response.
+	FirstByte time.Duration
+}
+
+// String returns the compact breakdown, e.g. "requests=3 dns=1ms connect=2ms tls=15ms first-byte=4.2s".
+func (t RequestTimings) String() string {
+	parts := []string{fmt.Sprintf("requests=%d", t.Requests)}
+	for _, stage := range []struct {
+		name     string
+		duration time.Duration
+	}{
+		{"dns", t.DNS},
+		{"connect", t.Connect},
+		{"tls", t.TLSHandshake},
+		{"first-byte", t.FirstByte},
+	} {
+		if stage.duration > 0 {
+			parts = append(parts, fmt.Sprintf("%s=%s", stage.name, stage.duration.Round(time.Millisecond)))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func sumRequestTimings(a, b RequestTimings) RequestTimings {
+	return RequestTimings{
+		Requests:     a.Requests + b.Requests,
+		DNS:          a.DNS + b.DNS,
+		Connect:      a.Connect + b.Connect,
+		TLSHandshake: a.TLSHandshake + b.TLSHandshake,
+		FirstByte:    a.FirstByte + b.FirstByte,
+	}
+}
+
+// requestTimings collects RequestTimings of the requests sent in one validation phase.
+// Stages which haven't finished yet, e.g. when the phase timed out, count until the snapshot.
+type requestTimings struct {
+	mu     sync.Mutex
+	now    func() time.Time
+	traces []*requestTrace
+}
+
+// requestTrace holds the start times of the stages in progress, they are zero when the stage isn't running.
+type requestTrace struct {
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	wroteAt      time.Time
+	done         RequestTimings
+}
+
+func newRequestTimings() *requestTimings {
+	return &requestTimings{now: time.Now}
+}
+
+type requestTimingsKey struct{}
+
+// contextWithRequestTimings makes requests sent with the context record their stages in timings.
+func contextWithRequestTimings(ctx context.Context, timings *requestTimings) context.Context {
+	return context.WithValue(ctx, requestTimingsKey{}, timings)
+}
+
+func requestTimingsFrom(ctx context.Context) *requestTimings {
+	timings, _ := ctx.Value(requestTimingsKey{}).(*requestTimings)
+	return timings
+}
+
+// clientTrace returns the trace of a single request, traces can't be shared by requests sent concurrently.
+func (c *requestTimings) clientTrace() *httptrace.ClientTrace {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tr := &requestTrace{}
+	c.traces = append(c.traces, tr)
+
+	start := func(at *time.Time) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if at.IsZero() {
+			*at = c.now()
+		}
+	}
+	stop := func(at *time.Time, d *time.Duration) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if !at.IsZero() {
+			*d += c.now().Sub(*at)
+			*at = time.Time{}
+		}
+	}
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { start(&tr.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { stop(&tr.dnsStart, &tr.done.DNS) },
+		// dual-stack dialing may start several connections, the first one to start is measured
+		ConnectStart:         func(string, string) { start(&tr.connectStart) },
+		ConnectDone:          func(string, string, error) { stop(&tr.connectStart, &tr.done.Connect) },
+		TLSHandshakeStart:    func() { start(&tr.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { stop(&tr.tlsStart, &tr.done.TLSHandshake) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { start(&tr.wroteAt) },
+		GotFirstResponseByte: func() { stop(&tr.wroteAt, &tr.done.FirstByte) },
+	}
+}
+
+// snapshot sums up the stages of all requests, it's safe to call on nil timings.
+func (c *requestTimings) snapshot() RequestTimings {
+	if c == nil {
+		return RequestTimings{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	running := func(at time.Time) time.Duration {
+		if at.IsZero() {
+			return 0
+		}
+		return now.Sub(at)
+	}
+	total := RequestTimings{Requests: len(c.traces)}
+	for _, tr := range c.traces {
+		total.DNS += tr.done.DNS + running(tr.dnsStart)
+		total.Connect += tr.done.Connect + running(tr.connectStart)
+		total.TLSHandshake += tr.done.TLSHandshake + running(tr.tlsStart)
+		total.FirstByte += tr.done.FirstByte + running(tr.wroteAt)
+	}
+	return total
+}
+
+// timingTransport records the stages of requests in its timings, or in the timings of the request context
+// when it has none, e.g. for registry requests which carry the context of the validation.
+type timingTransport struct {
+	next    http.RoundTripper
+	timings *requestTimings
+}
+
+func (t timingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	timings := t.timings
+	if timings == nil {
+		timings = requestTimingsFrom(r.Context())
+	}
+	if timings == nil {
+		return t.next.RoundTrip(r)
+	}
+	return t.next.RoundTrip(r.WithContext(httptrace.WithClientTrace(r.Context(), timings.clientTrace())))
+}
+
+// warnIfSlow logs the breakdown of validations which took longer than ServiceConfig.SlowValidationThreshold.
+func (s *notaryService) warnIfSlow(ctx context.Context, image string, took time.Duration, result ImageValidationResult, err error) {
+	if s.SlowValidationThreshold <= 0 || took < s.SlowValidationThreshold {
+		return
+	}
+	log.FromContext(ctx).Info("slow image validation",
+		"image", image,
+		"duration", took,
+		"threshold", s.SlowValidationThreshold,
+		"notaryDuration", result.Durations.Notary,
+		"notaryRequests", result.Durations.NotaryRequests.String(),
+		"registryDuration", result.Durations.Registry,
+		"registryRequests", result.Durations.RegistryRequests.String(),
+		"error", err)
+}
@@ -0,0 +1,254 @@
+package validate
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/zapr"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func Test_requestTimings(t *testing.T) {
+	stage := 100 * time.Millisecond
+	tests := []struct {
+		name     string
+		run      func(trace *httptrace.ClientTrace, advance func())
+		expected RequestTimings
+	}{
+		{
+			name: "dns",
+			run: func(trace *httptrace.ClientTrace, advance func()) {
+				trace.DNSStart(httptrace.DNSStartInfo{Host: "notary.example.com"})
+				advance()
+				trace.DNSDone(httptrace.DNSDoneInfo{})
+			},
+			expected: RequestTimings{Requests: 1, DNS: stage},
+		},
+		{
+			name: "connect of the first dual-stack dial",
+			run: func(trace *httptrace.ClientTrace, advance func()) {
+				trace.ConnectStart("tcp", "[::1]:443")
+				advance()
+				trace.ConnectStart("tcp", "127.0.0.1:443")
+				trace.ConnectDone("tcp", "[::1]:443", nil)
+				trace.ConnectDone("tcp", "127.0.0.1:443", nil)
+			},
+			expected: RequestTimings{Requests: 1, Connect: stage},
+		},
+		{
+			name: "tls handshake",
+			run: func(trace *httptrace.ClientTrace, advance func()) {
+				trace.TLSHandshakeStart()
+				advance()
+				trace.TLSHandshakeDone(tls.ConnectionState{}, nil)
+			},
+			expected: RequestTimings{Requests: 1, TLSHandshake: stage},
+		},
+		{
+			name: "first byte",
+			run: func(trace *httptrace.ClientTrace, advance func()) {
+				trace.WroteRequest(httptrace.WroteRequestInfo{})
+				advance()
+				trace.GotFirstResponseByte()
+			},
+			expected: RequestTimings{Requests: 1, FirstByte: stage},
+		},
+		{
+			name: "stage in progress counts until the snapshot",
+			run: func(trace *httptrace.ClientTrace, advance func()) {
+				trace.TLSHandshakeStart()
+				advance()
+			},
+			expected: RequestTimings{Requests: 1, TLSHandshake: stage},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//GIVEN
+			now := time.Now()
+			timings := newRequestTimings()
+			timings.now = func() time.Time { return now }
+
+			//WHEN
+			tt.run(timings.clientTrace(), func() { now = now.Add(stage) })
+
+			//THEN
+			require.Equal(t, tt.expected, timings.snapshot())
+		})
+	}
+}
+
+func TestRequestTimings_String(t *testing.T) {
+	timings := RequestTimings{Requests: 2, TLSHandshake: 15 * time.Millisecond, FirstByte: 4200 * time.Millisecond}
+
+	require.Equal(t, "requests=2 tls=15ms first-byte=4.2s", timings.String())
+}
+
+// delayedListener delays accepting connections, so clients wait for the TLS handshake.
+type delayedListener struct {
+	net.Listener
+	delay time.Duration
+}
+
+func (l delayedListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	time.Sleep(l.delay)
+	return conn, err
+}
+
+func Test_NotaryRepoFactory_RequestTimings(t *testing.T) {
+	delay := 100 * time.Millisecond
+	tests := []struct {
+		name        string
+		acceptDelay time.Duration
+		pingDelay   time.Duration
+		check       func(t *testing.T, timings RequestTimings)
+	}{
+		{
+			name:        "slow TLS handshake",
+			acceptDelay: delay,
+			check: func(t *testing.T, timings RequestTimings) {
+				require.GreaterOrEqual(t, timings.TLSHandshake, delay)
+				require.Less(t, timings.FirstByte, delay)
+			},
+		},
+		{
+			name:      "slow notary response",
+			pingDelay: delay,
+			check: func(t *testing.T, timings RequestTimings) {
+				require.GreaterOrEqual(t, timings.FirstByte, delay)
+				require.Less(t, timings.TLSHandshake, delay)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//GIVEN
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(tt.pingDelay)
+			}))
+			srv.Listener = delayedListener{Listener: srv.Listener, delay: tt.acceptDelay}
+			srv.StartTLS()
+			defer srv.Close()
+			f := newTestTLSNotaryRepoFactory(srv)
+			timings := newRequestTimings()
+
+			//WHEN
+			_, err := f.NewRepoClient(context.TODO(), "europe-docker.pkg.dev/kyma-project/dev/bootstrap", NotaryConfig{Url: srv.URL, timings: timings})
+
+			//THEN
+			require.NoError(t, err)
+			snapshot := timings.snapshot()
+			require.Equal(t, 1, snapshot.Requests)
+			tt.check(t, snapshot)
+		})
+	}
+}
+
+func Test_registryPhase_RequestTimings(t *testing.T) {
+	//GIVEN
+	delay := 100 * time.Millisecond
+	reg := registry.New()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/manifests/") {
+			time.Sleep(delay)
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	image := strings.TrimPrefix(srv.URL, "http://") + "/test/image:tag"
+	s := NewDefaultMockNotaryService().WithInsecureRegistries(registryHost(image)).Build()
+	timings := newRequestTimings()
+
+	//WHEN
+	_, err := s.registryPhase(contextWithRequestTimings(context.TODO(), timings), image, SHA256Algorithm)
+
+	//THEN
+	require.Error(t, err)
+	snapshot := timings.snapshot()
+	require.Positive(t, snapshot.Requests)
+	require.GreaterOrEqual(t, snapshot.FirstByte, delay)
+	// the insecure registry is tried over HTTPS first
+	require.Less(t, snapshot.TLSHandshake, delay)
+}
+
+func Test_registryPhase_TimeoutTimings(t *testing.T) {
+	//GIVEN
+	phaseTimeout := 100 * time.Millisecond
+	reg := registry.New()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(4 * phaseTimeout)
+		reg.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	image := strings.TrimPrefix(srv.URL, "http://") + "/test/image:tag"
+	s := NewDefaultMockNotaryService().WithInsecureRegistries(registryHost(image)).Build()
+	s.RegistryTimeout = phaseTimeout
+
+	//WHEN
+	_, err := s.registryPhase(contextWithRequestTimings(context.TODO(), newRequestTimings()), image, SHA256Algorithm)
+
+	//THEN
+	var timeoutErr PhaseTimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	require.Positive(t, timeoutErr.Timings.Requests)
+	require.GreaterOrEqual(t, timeoutErr.Timings.FirstByte, phaseTimeout/2)
+	require.Contains(t, err.Error(), "first-byte=")
+}
+
+func Test_ValidateDetailed_SlowValidationWarning(t *testing.T) {
+	tests := []struct {
+		name          string
+		threshold     time.Duration
+		expectedLines int
+	}{
+		{
+			name:          "over the threshold",
+			threshold:     time.Nanosecond,
+			expectedLines: 1,
+		},
+		{
+			name:      "under the threshold",
+			threshold: time.Hour,
+		},
+		{
+			name: "disabled",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//GIVEN
+			core, logs := observer.New(zapcore.InfoLevel)
+			ctx := log.IntoContext(context.TODO(), zapr.NewLogger(zap.New(core)))
+			s := NewDefaultMockNotaryService().Build()
+			s.AllowedRegistries = []string{"eu.gcr.io/kyma-project/"}
+			s.SlowValidationThreshold = tt.threshold
+
+			//WHEN
+			_, err := s.ValidateDetailed(ctx, TrustedImageName)
+
+			//THEN
+			require.NoError(t, err)
+			lines := logs.FilterMessage("slow image validation").All()
+			require.Len(t, lines, tt.expectedLines)
+			for _, line := range lines {
+				fields := line.ContextMap()
+				require.Equal(t, TrustedImageName, fields["image"])
+				require.Contains(t, fields, "notaryRequests")
+				require.Contains(t, fields, "registryRequests")
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package validate_test
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/kyma-project/warden/internal/validate"
+	"github.com/kyma-project/warden/internal/validate/validatetest"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingTransport records the host and path of every request before sending it through next.
+type recordingTransport struct {
+	next     http.RoundTripper
+	mu       *sync.Mutex
+	requests *[]string
+}
+
+func (rt recordingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	rt.mu.Lock()
+	*rt.requests = append(*rt.requests, r.URL.Host+r.URL.Path)
+	rt.mu.Unlock()
+	return rt.next.RoundTrip(r)
+}
+
+// registryRedirect sends registry requests to the plain HTTP test registry through next.
+type registryRedirect struct {
+	next http.RoundTripper
+	host string
+}
+
+func (rt registryRedirect) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Scheme = "http"
+	r.URL.Host = rt.host
+	return rt.next.RoundTrip(r)
+}
+
+// countingServer serves handler and counts the requests it received.
+func countingServer(t *testing.T, handler http.Handler) (*httptest.Server, *int32) {
+	var count int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &count
+}
+
+func TestWithRoundTripper(t *testing.T) {
+	//GIVEN
+	image := "eu.gcr.io/kyma-project/app:1.0"
+	registrySrv, registryRequests := countingServer(t, registry.New())
+	registryHost := strings.TrimPrefix(registrySrv.URL, "http://")
+	img, err := random.Image(128, 1)
+	require.NoError(t, err)
+	ref, err := name.ParseReference(image)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img, remote.WithTransport(registryRedirect{next: http.DefaultTransport, host: registryHost})))
+	pushRequests := atomic.LoadInt32(registryRequests)
+	cfgDigest, err := img.ConfigName()
+	require.NoError(t, err)
+	hash, err := hex.DecodeString(cfgDigest.Hex)
+	require.NoError(t, err)
+
+	notary := validatetest.NewNotaryServer(t).WithTarget("eu.gcr.io/kyma-project/app", "1.0", hash)
+	notaryURL, err := url.Parse(notary.URL)
+	require.NoError(t, err)
+	notarySrv, notaryRequests := countingServer(t, httputil.NewSingleHostReverseProxy(notaryURL))
+
+	var mu sync.Mutex
+	var recorded []string
+	validator, err := validate.NewImageValidator(&validate.ServiceConfig{
+		NotaryConfig: validate.NotaryConfig{Url: notarySrv.URL},
+	},
+		validate.WithRepoFactory(validate.NotaryRepoFactory{Timeout: time.Second, TrustDir: t.TempDir()}),
+		validate.WithRoundTripper(func(next http.RoundTripper) http.RoundTripper {
+			return recordingTransport{next: next, mu: &mu, requests: &recorded}
+		}),
+		validate.WithRegistryTransport(func(next http.RoundTripper) http.RoundTripper {
+			return registryRedirect{next: next, host: registryHost}
+		}),
+	)
+	require.NoError(t, err)
+
+	//WHEN
+	err = validator.Validate(context.TODO(), image)
+
+	//THEN
+	require.NoError(t, err)
+	mu.Lock()
+	defer mu.Unlock()
+	var toNotary, toRegistry int32
+	for _, r := range recorded {
+		switch {
+		case strings.HasPrefix(r, strings.TrimPrefix(notarySrv.URL, "http://")):
+			toNotary++
+		case strings.HasPrefix(r, "eu.gcr.io/"):
+			toRegistry++
+		}
+	}
+	require.Positive(t, toNotary)
+	require.Positive(t, toRegistry)
+	require.Equal(t, atomic.LoadInt32(notaryRequests), toNotary)
+	require.Equal(t, atomic.LoadInt32(registryRequests)-pushRequests, toRegistry)
+	require.Len(t, recorded, int(toNotary+toRegistry))
+}
@@ -0,0 +1,145 @@
+package validate
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+// targetReader is the part of the notary repository used to read image targets.
+type targetReader interface {
+	GetTargetByName(name string, roles ...data.RoleName) (*client.TargetWithRole, error)
+}
+
+// trustBundleFile is the format of the exported trust bundle.
+// Signature is an ed25519 signature of the raw Signed content.
+type trustBundleFile struct {
+	Signed    json.RawMessage `json:"signed"`
+	Signature []byte          `json:"signature"`
+}
+
+type trustBundleContent struct {
+	// Repositories maps repository -> tag -> signed target.
+	Repositories map[string]map[string]trustBundleTarget `json:"repositories"`
+}
+
+type trustBundleTarget struct {
+	Hashes data.Hashes   `json:"hashes"`
+	Role   data.RoleName `json:"role"`
+}
+
+// OfflineTrustBundle provides signed image targets exported from notary for clusters without access to notary.
+// The bundle file is reloaded when it changes.
+type OfflineTrustBundle struct {
+	path      string
+	publicKey ed25519.PublicKey
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	content trustBundleContent
+}
+
+func NewOfflineTrustBundle(path string, publicKey ed25519.PublicKey) (*OfflineTrustBundle, error) {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid offline trust bundle public key")
+	}
+	b := &OfflineTrustBundle{
+		path:      path,
+		publicKey: publicKey,
+	}
+	if err := b.refresh(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// refresh reloads the bundle if the file changed since the last load.
+func (b *OfflineTrustBundle) refresh() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	info, err := os.Stat(b.path)
+	if err != nil {
+		return errors.Wrap(err, "while reading offline trust bundle")
+	}
+	if info.ModTime().Equal(b.modTime) && info.Size() == b.size {
+		return nil
+	}
+
+	raw, err := os.ReadFile(b.path)
+	if err != nil {
+		return errors.Wrap(err, "while reading offline trust bundle")
+	}
+	content, err := b.parse(raw)
+	if err != nil {
+		return err
+	}
+
+	b.content = content
+	b.modTime = info.ModTime()
+	b.size = info.Size()
+	return nil
+}
+
+func (b *OfflineTrustBundle) parse(raw []byte) (trustBundleContent, error) {
+	file := trustBundleFile{}
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return trustBundleContent{}, errors.Wrap(err, "while parsing offline trust bundle")
+	}
+	if !ed25519.Verify(b.publicKey, file.Signed, file.Signature) {
+		return trustBundleContent{}, errors.New("offline trust bundle signature verification failed")
+	}
+	content := trustBundleContent{}
+	if err := json.Unmarshal(file.Signed, &content); err != nil {
+		return trustBundleContent{}, errors.Wrap(err, "while parsing offline trust bundle content")
+	}
+	return content, nil
+}
+
+// reader returns targets of the given repository. A changed bundle which can't be loaded fails the validation.
+func (b *OfflineTrustBundle) reader(imgRepo string) (targetReader, error) {
+	if err := b.refresh(); err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	targets, ok := b.content.Repositories[imgRepo]
+	if !ok {
+		return nil, classify(ErrNoTrustData, errors.Errorf("offline trust bundle does not have trust data for %s", imgRepo))
+	}
+	return trustBundleRepo(targets), nil
+}
+
+type trustBundleRepo map[string]trustBundleTarget
+
+// GetTargetByName returns the target signed by the first of the given roles, like notary does.
+func (r trustBundleRepo) GetTargetByName(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+	target, ok := r[name]
+	if !ok {
+		return nil, client.ErrNoSuchTarget(name)
+	}
+	if len(roles) == 0 {
+		roles = []data.RoleName{data.CanonicalTargetsRole}
+	}
+	for _, role := range roles {
+		if target.Role == role {
+			return &client.TargetWithRole{
+				Target: client.Target{
+					Name:   name,
+					Hashes: target.Hashes,
+				},
+				Role: target.Role,
+			}, nil
+		}
+	}
+	return nil, client.ErrNoSuchTarget(name)
+}
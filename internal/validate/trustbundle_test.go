@@ -0,0 +1,144 @@
+package validate
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+const (
+	bundleRepo = "eu.gcr.io/kyma-project/function-controller"
+)
+
+func Test_OfflineTrustBundle(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "bundle.json")
+	writeTrustBundle(t, path, privateKey, map[string]map[string]trustBundleTarget{
+		bundleRepo: {
+			"v1":       {Hashes: data.Hashes{SHA256Algorithm: TrustedImageHash}, Role: data.CanonicalTargetsRole},
+			"delegate": {Hashes: data.Hashes{SHA256Algorithm: TrustedImageHash}, Role: "targets/untrusted"},
+		},
+	})
+	bundle, err := NewOfflineTrustBundle(path, publicKey)
+	require.NoError(t, err)
+
+	f := func(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+		return nil, errors.New("notary shouldn't be called")
+	}
+	s := NewDefaultMockNotaryService().WithFunc(f).Build()
+	s.OfflineTrustBundle = bundle
+
+	t.Run("signed image is read from the bundle", func(t *testing.T) {
+		signed, err := s.getNotaryImageDigestHash(context.TODO(), bundleRepo, "v1")
+		require.NoError(t, err)
+		require.Equal(t, TrustedImageHash, signed.hash)
+		require.Equal(t, data.CanonicalTargetsRole, signed.role)
+	})
+
+	t.Run("image absent from the bundle is unsigned", func(t *testing.T) {
+		_, err := s.getNotaryImageDigestHash(context.TODO(), "eu.gcr.io/kyma-project/unknown", "v1")
+		require.EqualError(t, err, "offline trust bundle does not have trust data for eu.gcr.io/kyma-project/unknown")
+	})
+
+	t.Run("tag absent from the bundle is unsigned", func(t *testing.T) {
+		_, err := s.getNotaryImageDigestHash(context.TODO(), bundleRepo, "v2")
+		require.EqualError(t, err, "tag v2 is not signed by any of roles [targets/releases targets]: No valid trust data for v2")
+	})
+
+	t.Run("target signed by not accepted role", func(t *testing.T) {
+		_, err := s.getNotaryImageDigestHash(context.TODO(), bundleRepo, "delegate")
+		require.EqualError(t, err, "tag delegate is not signed by any of roles [targets/releases targets]: No valid trust data for delegate")
+	})
+}
+
+func Test_OfflineTrustBundle_Refresh(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "bundle.json")
+	writeTrustBundle(t, path, privateKey, map[string]map[string]trustBundleTarget{})
+	bundle, err := NewOfflineTrustBundle(path, publicKey)
+	require.NoError(t, err)
+
+	_, err = bundle.reader(bundleRepo)
+	require.Error(t, err)
+
+	t.Run("changed bundle is reloaded", func(t *testing.T) {
+		writeTrustBundle(t, path, privateKey, map[string]map[string]trustBundleTarget{
+			bundleRepo: {"v1": {Hashes: data.Hashes{SHA256Algorithm: TrustedImageHash}, Role: data.CanonicalTargetsRole}},
+		})
+		touch(t, path, time.Now().Add(time.Minute))
+
+		r, err := bundle.reader(bundleRepo)
+		require.NoError(t, err)
+		target, err := r.GetTargetByName("v1")
+		require.NoError(t, err)
+		require.Equal(t, TrustedImageHash, target.Hashes[SHA256Algorithm])
+	})
+
+	t.Run("bundle signed with other key is rejected", func(t *testing.T) {
+		_, otherKey, err := ed25519.GenerateKey(nil)
+		require.NoError(t, err)
+		writeTrustBundle(t, path, otherKey, map[string]map[string]trustBundleTarget{})
+		touch(t, path, time.Now().Add(2*time.Minute))
+
+		_, err = bundle.reader(bundleRepo)
+		require.EqualError(t, err, "offline trust bundle signature verification failed")
+	})
+}
+
+func Test_NewOfflineTrustBundle_Errors(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	dir := t.TempDir()
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := NewOfflineTrustBundle(filepath.Join(dir, "missing.json"), publicKey)
+		require.ErrorContains(t, err, "while reading offline trust bundle")
+	})
+
+	t.Run("invalid public key", func(t *testing.T) {
+		_, err := NewOfflineTrustBundle(filepath.Join(dir, "missing.json"), []byte{1, 2, 3})
+		require.EqualError(t, err, "invalid offline trust bundle public key")
+	})
+
+	t.Run("tampered content", func(t *testing.T) {
+		path := filepath.Join(dir, "tampered.json")
+		writeTrustBundle(t, path, privateKey, map[string]map[string]trustBundleTarget{})
+		raw, err := os.ReadFile(path)
+		require.NoError(t, err)
+		file := trustBundleFile{}
+		require.NoError(t, json.Unmarshal(raw, &file))
+		file.Signed = json.RawMessage(`{"repositories":{"evil/image":{}}}`)
+		raw, err = json.Marshal(file)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path, raw, 0600))
+
+		_, err = NewOfflineTrustBundle(path, publicKey)
+		require.EqualError(t, err, "offline trust bundle signature verification failed")
+	})
+}
+
+func writeTrustBundle(t *testing.T, path string, key ed25519.PrivateKey, repos map[string]map[string]trustBundleTarget) {
+	signed, err := json.Marshal(trustBundleContent{Repositories: repos})
+	require.NoError(t, err)
+	raw, err := json.Marshal(trustBundleFile{
+		Signed:    signed,
+		Signature: ed25519.Sign(key, signed),
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, raw, 0600))
+}
+
+func touch(t *testing.T, path string, modTime time.Time) {
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
@@ -0,0 +1,135 @@
+package validate
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+// notaryTUFDir and notaryMetadataDir are the directories where the notary client caches TUF metadata of a GUN:
+// <trust dir>/tuf/<gun>/metadata.
+const (
+	notaryTUFDir      = "tuf"
+	notaryMetadataDir = "metadata"
+)
+
+// trustDirLocks serialize metadata updates of the same GUN in the same trust dir,
+// the notary file store rewrites cached files in place, so concurrent updates could tear them.
+var trustDirLocks sync.Map
+
+func trustDirLock(trustDir string, gun data.GUN) *sync.Mutex {
+	lock, _ := trustDirLocks.LoadOrStore(gunMetadataDir(trustDir, gun), &sync.Mutex{})
+	return lock.(*sync.Mutex)
+}
+
+func gunMetadataDir(trustDir string, gun data.GUN) string {
+	return filepath.Join(trustDir, notaryTUFDir, filepath.FromSlash(gun.String()), notaryMetadataDir)
+}
+
+// cacheLockedRepository holds the GUN lock while the notary client updates and reads the cached metadata.
+type cacheLockedRepository struct {
+	client.Repository
+	lock *sync.Mutex
+	// metadataDir holds the cached metadata of the repository.
+	metadataDir string
+}
+
+func (r cacheLockedRepository) GetTargetByName(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.Repository.GetTargetByName(name, roles...)
+}
+
+func (r cacheLockedRepository) GetAllTargetMetadataByName(name string) ([]client.TargetSignedStruct, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.Repository.GetAllTargetMetadataByName(name)
+}
+
+func (r cacheLockedRepository) ListTargets(roles ...data.RoleName) ([]*client.TargetWithRole, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.Repository.ListTargets(roles...)
+}
+
+// removeCorruptedRoot deletes the cached metadata of the GUN when its root can't be parsed.
+// The notary client refetches other broken roles, but it fails on a broken root because the cached root pins the trust.
+func removeCorruptedRoot(trustDir string, gun data.GUN) error {
+	dir := gunMetadataDir(trustDir, gun)
+	raw, err := os.ReadFile(filepath.Join(dir, data.CanonicalRootRole.String()+".json"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err == nil && isParsableRoot(raw) {
+		return nil
+	}
+	return errors.Wrapf(os.RemoveAll(dir), "while removing corrupted TUF metadata of %s", gun)
+}
+
+func isParsableRoot(raw []byte) bool {
+	signed := &data.Signed{}
+	if err := json.Unmarshal(raw, signed); err != nil {
+		return false
+	}
+	_, err := data.RootFromSigned(signed)
+	return err == nil
+}
+
+// PruneTrustDir removes the cached TUF metadata of GUNs which weren't updated for maxAge,
+// it returns the number of removed GUNs. Validations refetch the metadata when it's needed again.
+func PruneTrustDir(trustDir string, maxAge time.Duration, now time.Time) (int, error) {
+	root := filepath.Join(trustDir, notaryTUFDir)
+	var stale []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !info.IsDir() || info.Name() != notaryMetadataDir {
+			return nil
+		}
+		updated, err := newestModTime(path)
+		if err != nil {
+			return err
+		}
+		if now.Sub(updated) > maxAge {
+			stale = append(stale, filepath.Dir(path))
+		}
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "while reading TUF metadata cache")
+	}
+	for _, dir := range stale {
+		if err := os.RemoveAll(dir); err != nil {
+			return 0, errors.Wrap(err, "while pruning TUF metadata cache")
+		}
+	}
+	return len(stale), nil
+}
+
+func newestModTime(dir string) (time.Time, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var newest time.Time
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+	}
+	return newest, nil
+}
@@ -0,0 +1,133 @@
+package validate_test
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kyma-project/warden/internal/validate"
+	"github.com/kyma-project/warden/internal/validate/validatetest"
+	"github.com/stretchr/testify/require"
+)
+
+// metadataCounter counts downloads of TUF metadata per role, also of the consistent "<role>.<checksum>.json" files.
+type metadataCounter struct {
+	mu    sync.Mutex
+	roles map[string]int
+}
+
+func (c *metadataCounter) get(role string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.roles[role]
+}
+
+func (c *metadataCounter) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.roles = map[string]int{}
+}
+
+func newCountingNotary(t *testing.T, target string) (*httptest.Server, *metadataCounter) {
+	u, err := url.Parse(target)
+	require.NoError(t, err)
+	proxy := httputil.NewSingleHostReverseProxy(u)
+	counter := &metadataCounter{roles: map[string]int{}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		counter.mu.Lock()
+		role, _, _ := strings.Cut(path.Base(r.URL.Path), ".")
+		counter.roles[role]++
+		counter.mu.Unlock()
+		proxy.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, counter
+}
+
+func TestTrustDirCache(t *testing.T) {
+	hash, err := hex.DecodeString("8f6f8a2e1c7b4d9f0e3a5c8b7d2e1f4a6c9b0d3e5f7a8c1b2d4e6f8a0c2e4b6d")
+	require.NoError(t, err)
+	repo := "eu.gcr.io/kyma-project/app"
+	digest := "sha256:" + hex.EncodeToString(hash)
+	signed := validatetest.NewNotaryServer(t).WithTarget(repo, "1.0", hash)
+	newValidator := func(notaryURL, trustDir string) validate.ImageValidatorService {
+		validator, err := validate.NewImageValidator(&validate.ServiceConfig{
+			NotaryConfig: validate.NotaryConfig{Url: notaryURL, TrustDir: trustDir},
+		}, validate.WithRepoFactory(validate.NewNotaryRepoFactory(time.Second)))
+		require.NoError(t, err)
+		return validator
+	}
+
+	t.Run("second validator reuses the cached metadata", func(t *testing.T) {
+		//GIVEN
+		trustDir := t.TempDir()
+		notary, counter := newCountingNotary(t, signed.URL)
+		require.NoError(t, newValidator(notary.URL, trustDir).ValidateWithDigest(context.TODO(), repo+":1.0", digest))
+		require.Equal(t, 1, counter.get("root"))
+		require.Equal(t, 1, counter.get("targets"))
+		counter.reset()
+
+		//WHEN
+		err := newValidator(notary.URL, trustDir).ValidateWithDigest(context.TODO(), repo+":1.0", digest)
+
+		//THEN
+		require.NoError(t, err)
+		require.Zero(t, counter.get("root"))
+		require.Zero(t, counter.get("snapshot"))
+		require.Zero(t, counter.get("targets"))
+	})
+
+	t.Run("corrupted cache is refetched", func(t *testing.T) {
+		//GIVEN
+		trustDir := t.TempDir()
+		notary, counter := newCountingNotary(t, signed.URL)
+		require.NoError(t, newValidator(notary.URL, trustDir).ValidateWithDigest(context.TODO(), repo+":1.0", digest))
+		metadata := filepath.Join(trustDir, "tuf", filepath.FromSlash(repo), "metadata")
+		for _, role := range []string{"root.json", "targets.json"} {
+			require.NoError(t, os.WriteFile(filepath.Join(metadata, role), []byte(`{"signed": {"_type": "Ro`), 0o600))
+		}
+		counter.reset()
+
+		//WHEN
+		err := newValidator(notary.URL, trustDir).ValidateWithDigest(context.TODO(), repo+":1.0", digest)
+
+		//THEN
+		require.NoError(t, err)
+		require.Equal(t, 1, counter.get("root"))
+		require.Equal(t, 1, counter.get("targets"))
+	})
+
+	t.Run("concurrent validators share the cache", func(t *testing.T) {
+		//GIVEN
+		trustDir := t.TempDir()
+		validators := []validate.ImageValidatorService{newValidator(signed.URL, trustDir), newValidator(signed.URL, trustDir)}
+
+		//WHEN
+		var wg sync.WaitGroup
+		errs := make(chan error, 20)
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(v validate.ImageValidatorService) {
+				defer wg.Done()
+				errs <- v.ValidateWithDigest(context.TODO(), repo+":1.0", digest)
+			}(validators[i%2])
+		}
+		wg.Wait()
+		close(errs)
+
+		//THEN
+		for err := range errs {
+			require.NoError(t, err)
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package validate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPruneTrustDir(t *testing.T) {
+	now := time.Now()
+
+	t.Run("metadata of stale GUNs is removed", func(t *testing.T) {
+		//GIVEN
+		trustDir := t.TempDir()
+		fresh := writeCachedMetadata(t, trustDir, "eu.gcr.io/kyma-project/fresh", now.Add(-time.Hour))
+		stale := writeCachedMetadata(t, trustDir, "eu.gcr.io/kyma-project/stale", now.Add(-48*time.Hour))
+
+		//WHEN
+		pruned, err := PruneTrustDir(trustDir, 24*time.Hour, now)
+
+		//THEN
+		require.NoError(t, err)
+		require.Equal(t, 1, pruned)
+		require.DirExists(t, fresh)
+		require.NoDirExists(t, stale)
+	})
+
+	t.Run("missing trust dir", func(t *testing.T) {
+		//WHEN
+		pruned, err := PruneTrustDir(filepath.Join(t.TempDir(), "missing"), time.Hour, now)
+
+		//THEN
+		require.NoError(t, err)
+		require.Zero(t, pruned)
+	})
+}
+
+func Test_removeCorruptedRoot(t *testing.T) {
+	gun := "eu.gcr.io/kyma-project/app"
+
+	t.Run("corrupted root removes the GUN metadata", func(t *testing.T) {
+		trustDir := t.TempDir()
+		dir := writeCachedMetadata(t, trustDir, gun, time.Now())
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "root.json"), []byte(`{"signed":`), 0o600))
+
+		require.NoError(t, removeCorruptedRoot(trustDir, "eu.gcr.io/kyma-project/app"))
+		require.NoDirExists(t, dir)
+	})
+
+	t.Run("without cached root", func(t *testing.T) {
+		trustDir := t.TempDir()
+		dir := writeCachedMetadata(t, trustDir, gun, time.Now())
+
+		require.NoError(t, removeCorruptedRoot(trustDir, "eu.gcr.io/kyma-project/app"))
+		require.DirExists(t, dir)
+	})
+}
+
+// writeCachedMetadata writes the timestamp metadata of the GUN like the notary client caches it.
+func writeCachedMetadata(t *testing.T, trustDir, gun string, modTime time.Time) string {
+	dir := filepath.Join(trustDir, "tuf", filepath.FromSlash(gun), "metadata")
+	require.NoError(t, os.MkdirAll(dir, 0o700))
+	file := filepath.Join(dir, "timestamp.json")
+	require.NoError(t, os.WriteFile(file, []byte("{}"), 0o600))
+	require.NoError(t, os.Chtimes(file, modTime, modTime))
+	return dir
+}
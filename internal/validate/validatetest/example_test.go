@@ -0,0 +1,61 @@
+package validatetest_test
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kyma-project/warden/internal/validate"
+	"github.com/kyma-project/warden/internal/validate/validatetest"
+	"github.com/stretchr/testify/require"
+)
+
+func ExampleNotary() {
+	hash, _ := hex.DecodeString("6f8a2e1c7b4d9f0e3a5c8b7d2e1f4a6c9b0d3e5f7a8c1b2d4e6f8a0c2e4b6d8f")
+	notary := validatetest.NewNotary().
+		WithTarget("eu.gcr.io/kyma-project/app", "1.0", hash).
+		WithLatency(10 * time.Millisecond)
+	validator, err := validate.NewImageValidator(&validate.ServiceConfig{}, validate.WithRepoFactory(notary))
+	if err != nil {
+		panic(err)
+	}
+
+	// ValidateWithDigest compares the digest known from the pod status without calling the registry
+	fmt.Println(validator.ValidateWithDigest(context.TODO(), "eu.gcr.io/kyma-project/app:1.0", "sha256:"+hex.EncodeToString(hash)))
+	fmt.Println(validator.Validate(context.TODO(), "eu.gcr.io/kyma-project/app:2.0"))
+	// Output:
+	// <nil>
+	// tag 2.0 is not signed by any of roles [targets/releases targets]: No valid trust data for 2.0
+}
+
+func ExampleNotary_WithError() {
+	notary := validatetest.NewNotary().
+		WithError("eu.gcr.io/kyma-project/app", errors.New("notary is down"))
+	validator, err := validate.NewImageValidator(&validate.ServiceConfig{}, validate.WithRepoFactory(notary))
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println(validator.Validate(context.TODO(), "eu.gcr.io/kyma-project/app:1.0"))
+	// Output:
+	// notary is down
+}
+
+// TestNotaryServer shows the end-to-end setup with the real notary client.
+func TestNotaryServer(t *testing.T) {
+	hash, err := hex.DecodeString("6f8a2e1c7b4d9f0e3a5c8b7d2e1f4a6c9b0d3e5f7a8c1b2d4e6f8a0c2e4b6d8f")
+	require.NoError(t, err)
+	digest := "sha256:" + hex.EncodeToString(hash)
+
+	notary := validatetest.NewNotaryServer(t).WithTarget("eu.gcr.io/kyma-project/app", "1.0", hash)
+	validator, err := validate.NewImageValidator(&validate.ServiceConfig{NotaryConfig: notary.Config()},
+		validate.WithRepoFactory(validate.NotaryRepoFactory{Timeout: time.Second, TrustDir: t.TempDir()}))
+	require.NoError(t, err)
+
+	require.NoError(t, validator.ValidateWithDigest(context.TODO(), "eu.gcr.io/kyma-project/app:1.0", digest))
+	require.ErrorContains(t, validator.ValidateWithDigest(context.TODO(), "eu.gcr.io/kyma-project/app:2.0", digest), "is not signed")
+	require.Error(t, validator.ValidateWithDigest(context.TODO(), "eu.gcr.io/kyma-project/other:1.0", digest))
+}
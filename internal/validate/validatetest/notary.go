@@ -0,0 +1,171 @@
+// Package validatetest provides fake notary implementations for tests of code which uses the image validator.
+package validatetest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/kyma-project/warden/internal/validate"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+var _ validate.RepoFactory = &Notary{}
+
+// Notary is the fake notary used through validate.WithRepoFactory, it signs targets of the targets role.
+// Builder methods may be called while validations are running.
+type Notary struct {
+	mu      sync.RWMutex
+	targets map[string]map[string][]byte
+	errors  map[string]error
+	keyIDs  map[string][]string
+	latency time.Duration
+}
+
+// NewNotary returns the fake notary without any signed targets.
+func NewNotary() *Notary {
+	return &Notary{
+		targets: map[string]map[string][]byte{},
+		errors:  map[string]error{},
+		keyIDs:  map[string][]string{},
+	}
+}
+
+// WithTarget signs the tag of the repository with the sha256 hash of the image config.
+func (n *Notary) WithTarget(repo, tag string, hash []byte) *Notary {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.targets[repo] == nil {
+		n.targets[repo] = map[string][]byte{}
+	}
+	n.targets[repo][tag] = hash
+	return n
+}
+
+// WithError makes all target lookups in the repository fail with err, e.g. client.ErrRepositoryNotExist.
+func (n *Notary) WithError(repo string, err error) *Notary {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.errors[repo] = err
+	return n
+}
+
+// WithSigningKeys makes the targets metadata of the repository signed by keys with keyIDs.
+func (n *Notary) WithSigningKeys(repo string, keyIDs ...string) *Notary {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.keyIDs[repo] = keyIDs
+	return n
+}
+
+// WithLatency delays every target lookup by d, the lookup ends earlier when the context of the client is done.
+func (n *Notary) WithLatency(d time.Duration) *Notary {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.latency = d
+	return n
+}
+
+// NewRepoClient returns the client of the repository, the notary config is ignored.
+func (n *Notary) NewRepoClient(ctx context.Context, repo string, _ validate.NotaryConfig) (client.Repository, error) {
+	return &repository{ctx: ctx, notary: n, gun: data.GUN(repo)}, nil
+}
+
+func (n *Notary) lookup(repo, tag string) ([]byte, time.Duration, error) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	if err := n.errors[repo]; err != nil {
+		return nil, n.latency, err
+	}
+	hash, ok := n.targets[repo][tag]
+	if !ok {
+		return nil, n.latency, client.ErrNoSuchTarget(tag)
+	}
+	return hash, n.latency, nil
+}
+
+// repository implements the read methods used by the validator,
+// the other methods of client.Repository panic.
+type repository struct {
+	client.Repository
+	ctx    context.Context
+	notary *Notary
+	gun    data.GUN
+}
+
+func (r *repository) GetTargetByName(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+	hash, latency, err := r.notary.lookup(r.gun.String(), name)
+	if waitErr := r.wait(latency); waitErr != nil {
+		return nil, waitErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !hasRole(roles, data.CanonicalTargetsRole) {
+		return nil, client.ErrNoSuchTarget(name)
+	}
+	return &client.TargetWithRole{
+		Target: client.Target{
+			Name:   name,
+			Hashes: data.Hashes{validate.SHA256Algorithm: hash},
+			Length: 1,
+		},
+		Role: data.CanonicalTargetsRole,
+	}, nil
+}
+
+func (r *repository) GetAllTargetMetadataByName(string) ([]client.TargetSignedStruct, error) {
+	r.notary.mu.RLock()
+	defer r.notary.mu.RUnlock()
+
+	keyIDs := r.notary.keyIDs[r.gun.String()]
+	if len(keyIDs) == 0 {
+		return nil, nil
+	}
+	signatures := make([]data.Signature, len(keyIDs))
+	for i, id := range keyIDs {
+		signatures[i] = data.Signature{KeyID: id}
+	}
+	return []client.TargetSignedStruct{{
+		Role:       data.DelegationRole{BaseRole: data.BaseRole{Name: data.CanonicalTargetsRole}},
+		Signatures: signatures,
+	}}, nil
+}
+
+func (r *repository) GetGUN() data.GUN {
+	return r.gun
+}
+
+// hasRole returns true for the empty list, notary looks the target up in all roles then.
+func hasRole(roles []data.RoleName, role data.RoleName) bool {
+	if len(roles) == 0 {
+		return true
+	}
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// wait returns the error of the client context when it's done before the latency elapsed.
+func (r *repository) wait(latency time.Duration) error {
+	if latency <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(latency)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-r.ctx.Done():
+		return r.ctx.Err()
+	}
+}
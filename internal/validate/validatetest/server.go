@@ -0,0 +1,162 @@
+package validatetest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kyma-project/warden/internal/validate"
+	"github.com/theupdateframework/notary/tuf"
+	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/tuf/testutils"
+)
+
+// NotaryServer serves TUF metadata of the signed targets like notary server does,
+// keys of each repository are generated when its first target is signed.
+// Clients trust the root metadata on first use, so they must start with an empty trust directory,
+// e.g. validate.NotaryRepoFactory{TrustDir: t.TempDir()}.
+type NotaryServer struct {
+	URL string
+
+	t        testing.TB
+	mu       sync.RWMutex
+	repos    map[data.GUN]*tuf.Repo
+	metadata map[data.GUN]map[data.RoleName][]byte
+	expires  map[data.GUN]map[data.RoleName]time.Time
+}
+
+// NewNotaryServer starts the server, it's closed when the test finishes.
+func NewNotaryServer(t testing.TB) *NotaryServer {
+	s := &NotaryServer{
+		t:        t,
+		repos:    map[data.GUN]*tuf.Repo{},
+		metadata: map[data.GUN]map[data.RoleName][]byte{},
+		expires:  map[data.GUN]map[data.RoleName]time.Time{},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	t.Cleanup(srv.Close)
+	s.URL = srv.URL
+	return s
+}
+
+// Config returns the notary config of the validator which uses the server.
+func (s *NotaryServer) Config() validate.NotaryConfig {
+	return validate.NotaryConfig{Url: s.URL}
+}
+
+// WithTarget signs the tag of the repository with the sha256 hash of the image config.
+func (s *NotaryServer) WithTarget(repo, tag string, hash []byte) *NotaryServer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	gun := data.GUN(repo)
+	r, ok := s.repos[gun]
+	if !ok {
+		var err error
+		r, _, err = testutils.EmptyRepo(gun)
+		if err != nil {
+			s.t.Fatalf("creating notary repository %s: %s", repo, err)
+		}
+		s.repos[gun] = r
+	}
+	files := data.Files{tag: data.FileMeta{Length: 1, Hashes: data.Hashes{validate.SHA256Algorithm: hash}}}
+	if _, err := r.AddTargets(data.CanonicalTargetsRole, files); err != nil {
+		s.t.Fatalf("signing %s:%s: %s", repo, tag, err)
+	}
+	s.sign(gun)
+	return s
+}
+
+// WithExpiredMetadata re-signs the snapshot or timestamp metadata of the repository, which must have a signed target,
+// so it expires at expires. The timestamp is re-signed too, so it references the new snapshot.
+func (s *NotaryServer) WithExpiredMetadata(repo string, role data.RoleName, expires time.Time) *NotaryServer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	gun := data.GUN(repo)
+	if _, ok := s.repos[gun]; !ok {
+		s.t.Fatalf("repository %s has no signed target", repo)
+	}
+	if role != data.CanonicalSnapshotRole && role != data.CanonicalTimestampRole {
+		s.t.Fatalf("expiry of role %s can't be set", role)
+	}
+	if s.expires[gun] == nil {
+		s.expires[gun] = map[data.RoleName]time.Time{}
+	}
+	s.expires[gun][role] = expires
+	s.sign(gun)
+	return s
+}
+
+// sign serializes the metadata of the repository, snapshot and timestamp expire as set by WithExpiredMetadata.
+func (s *NotaryServer) sign(gun data.GUN) {
+	r := s.repos[gun]
+	metadata, err := testutils.SignAndSerialize(r)
+	if err != nil {
+		s.t.Fatalf("signing metadata of %s: %s", gun, err)
+	}
+	expires := s.expires[gun]
+	if len(expires) > 0 {
+		snapshotExpires, ok := expires[data.CanonicalSnapshotRole]
+		if !ok {
+			snapshotExpires = data.DefaultExpires(data.CanonicalSnapshotRole)
+		}
+		timestampExpires, ok := expires[data.CanonicalTimestampRole]
+		if !ok {
+			timestampExpires = data.DefaultExpires(data.CanonicalTimestampRole)
+		}
+		snapshot, err := r.SignSnapshot(snapshotExpires)
+		if err != nil {
+			s.t.Fatalf("signing snapshot of %s: %s", gun, err)
+		}
+		timestamp, err := r.SignTimestamp(timestampExpires)
+		if err != nil {
+			s.t.Fatalf("signing timestamp of %s: %s", gun, err)
+		}
+		metadata[data.CanonicalSnapshotRole] = mustMarshal(s.t, snapshot)
+		metadata[data.CanonicalTimestampRole] = mustMarshal(s.t, timestamp)
+	}
+	s.metadata[gun] = metadata
+}
+
+func mustMarshal(t testing.TB, signed *data.Signed) []byte {
+	raw, err := json.Marshal(signed)
+	if err != nil {
+		t.Fatalf("serializing metadata: %s", err)
+	}
+	return raw
+}
+
+// serveHTTP answers the notary ping and metadata requests,
+// versioned and consistent names like 1.root.json or snapshot.<checksum>.json serve the current metadata.
+func (s *NotaryServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/v2/" {
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/v2/")
+	repo, file, ok := strings.Cut(path, "/_trust/tuf/")
+	if !ok || !strings.HasSuffix(file, ".json") {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	metadata, ok := s.metadata[data.GUN(repo)]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	for _, part := range strings.Split(strings.TrimSuffix(file, ".json"), ".") {
+		if m, ok := metadata[data.RoleName(part)]; ok {
+			w.Write(m)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
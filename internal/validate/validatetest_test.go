@@ -0,0 +1,91 @@
+package validate_test
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/kyma-project/warden/internal/validate"
+	"github.com/kyma-project/warden/internal/validate/validatetest"
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/client"
+)
+
+// These tests use the exported fake notary like validator users do, so it's kept in sync with the validator.
+func TestValidateWithDigest_FakeNotary(t *testing.T) {
+	hash, err := hex.DecodeString("0c2e4b6d8f6f8a2e1c7b4d9f0e3a5c8b7d2e1f4a6c9b0d3e5f7a8c1b2d4e6f8a")
+	require.NoError(t, err)
+	digest := "sha256:" + hex.EncodeToString(hash)
+
+	t.Run("signed digest", func(t *testing.T) {
+		//GIVEN
+		notary := validatetest.NewNotary().WithTarget("eu.gcr.io/kyma-project/app", "1.0", hash)
+		validator, err := validate.NewImageValidator(&validate.ServiceConfig{}, validate.WithRepoFactory(notary))
+		require.NoError(t, err)
+
+		//WHEN
+		err = validator.ValidateWithDigest(context.TODO(), "eu.gcr.io/kyma-project/app:1.0", digest)
+		otherTagErr := validator.ValidateWithDigest(context.TODO(), "eu.gcr.io/kyma-project/app:2.0", digest)
+
+		//THEN
+		require.NoError(t, err)
+		var noTrustedTarget validate.NoTrustedTargetError
+		require.ErrorAs(t, otherTagErr, &noTrustedTarget)
+	})
+
+	t.Run("repository error", func(t *testing.T) {
+		//GIVEN
+		notary := validatetest.NewNotary().WithError("eu.gcr.io/kyma-project/app", client.ErrRepositoryNotExist{})
+		validator, err := validate.NewImageValidator(&validate.ServiceConfig{}, validate.WithRepoFactory(notary))
+		require.NoError(t, err)
+
+		//WHEN
+		err = validator.ValidateWithDigest(context.TODO(), "eu.gcr.io/kyma-project/app:1.0", digest)
+
+		//THEN
+		require.ErrorAs(t, err, &client.ErrRepositoryNotExist{})
+	})
+
+	t.Run("required signer keys", func(t *testing.T) {
+		//GIVEN
+		notary := validatetest.NewNotary().
+			WithTarget("eu.gcr.io/kyma-project/app", "1.0", hash).
+			WithSigningKeys("eu.gcr.io/kyma-project/app", "release-key")
+		validator := func(keyID string) validate.ImageValidatorService {
+			validator, err := validate.NewImageValidator(&validate.ServiceConfig{
+				RequiredSignerKeyIDs: map[string][]string{"eu.gcr.io/kyma-project": {keyID}},
+			}, validate.WithRepoFactory(notary))
+			require.NoError(t, err)
+			return validator
+		}
+
+		//WHEN
+		err := validator("release-key").ValidateWithDigest(context.TODO(), "eu.gcr.io/kyma-project/app:1.0", digest)
+		otherKeyErr := validator("other-key").ValidateWithDigest(context.TODO(), "eu.gcr.io/kyma-project/app:1.0", digest)
+
+		//THEN
+		require.NoError(t, err)
+		var signerKeyErr validate.SignerKeyError
+		require.ErrorAs(t, otherKeyErr, &signerKeyErr)
+		require.Equal(t, []string{"release-key"}, signerKeyErr.Actual)
+	})
+
+	t.Run("slow notary times out", func(t *testing.T) {
+		//GIVEN
+		notary := validatetest.NewNotary().
+			WithTarget("eu.gcr.io/kyma-project/app", "1.0", hash).
+			WithLatency(time.Second)
+		validator, err := validate.NewImageValidator(&validate.ServiceConfig{NotaryTimeout: 10 * time.Millisecond},
+			validate.WithRepoFactory(notary))
+		require.NoError(t, err)
+
+		//WHEN
+		err = validator.ValidateWithDigest(context.TODO(), "eu.gcr.io/kyma-project/app:1.0", digest)
+
+		//THEN
+		var timeoutErr validate.PhaseTimeoutError
+		require.ErrorAs(t, err, &timeoutErr)
+		require.Equal(t, validate.NotaryPhase, timeoutErr.Phase)
+	})
+}
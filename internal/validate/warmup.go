@@ -0,0 +1,150 @@
+package validate
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kyma-project/warden/pkg"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// DefaultWarmUpTimeout bounds the warm-up, so the readiness isn't held back by a slow notary.
+	DefaultWarmUpTimeout = 2 * time.Minute
+	// DefaultWarmUpConcurrency keeps the warm-up from competing with admissions for notary and registries.
+	DefaultWarmUpConcurrency = 2
+)
+
+var errWarmUpInProgress = errors.New("cache warm-up is in progress")
+
+// CacheWarmer validates the images of running pods in namespaces with enabled validation,
+// so the caches of the validators are populated before the first admissions after a restart.
+type CacheWarmer struct {
+	reader      client.Reader
+	validators  ImageValidatorFactory
+	timeout     time.Duration
+	concurrency int
+	done        chan struct{}
+}
+
+// NewCacheWarmer returns the warmer which lists pods with reader, e.g. the API reader of the manager,
+// so the warm-up doesn't wait for informers. DefaultWarmUpTimeout and DefaultWarmUpConcurrency are used
+// when timeout and concurrency aren't set.
+func NewCacheWarmer(reader client.Reader, validators ImageValidatorFactory, timeout time.Duration, concurrency int) *CacheWarmer {
+	if timeout <= 0 {
+		timeout = DefaultWarmUpTimeout
+	}
+	if concurrency <= 0 {
+		concurrency = DefaultWarmUpConcurrency
+	}
+	return &CacheWarmer{
+		reader:      reader,
+		validators:  validators,
+		timeout:     timeout,
+		concurrency: concurrency,
+		done:        make(chan struct{}),
+	}
+}
+
+// Start runs the warm-up, it implements manager.Runnable. Failures are logged and never returned,
+// because validations work with cold caches too.
+func (w *CacheWarmer) Start(ctx context.Context) error {
+	defer close(w.done)
+	ctx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+	logger := log.FromContext(ctx).WithName("cache-warm-up")
+
+	start := time.Now()
+	images, err := w.images(ctx)
+	if err != nil {
+		logger.Error(err, "failed to list images of running pods")
+		return nil
+	}
+
+	// the validator of each namespace has its own caches, so images are validated once per validator
+	work := make(chan warmUpImage)
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		denied int
+	)
+	for i := 0; i < w.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for img := range work {
+				if err := img.validator.Validate(ctx, img.image); err != nil {
+					logger.V(1).Info("image denied during cache warm-up", "image", img.image, "error", err.Error())
+					mu.Lock()
+					denied++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+dispatch:
+	for _, img := range images {
+		select {
+		case work <- img:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		logger.Info("cache warm-up timed out", "images", len(images), "timeout", w.timeout)
+		return nil
+	}
+	logger.Info("cache warm-up finished", "images", len(images), "denied", denied, "duration", time.Since(start))
+	return nil
+}
+
+// Check fails until the warm-up finished or timed out, it's meant to be added as the readiness check.
+func (w *CacheWarmer) Check(_ *http.Request) error {
+	select {
+	case <-w.done:
+		return nil
+	default:
+		return errWarmUpInProgress
+	}
+}
+
+type warmUpImage struct {
+	validator ImageValidatorService
+	image     string
+}
+
+// images returns the distinct images of the pods per validator of their namespace.
+func (w *CacheWarmer) images(ctx context.Context) ([]warmUpImage, error) {
+	var namespaces corev1.NamespaceList
+	if err := w.reader.List(ctx, &namespaces, client.MatchingLabels{pkg.NamespaceValidationLabel: pkg.NamespaceValidationEnabled}); err != nil {
+		return nil, err
+	}
+
+	var images []warmUpImage
+	seen := map[warmUpImage]bool{}
+	for _, ns := range namespaces.Items {
+		var pods corev1.PodList
+		if err := w.reader.List(ctx, &pods, client.InNamespace(ns.Name)); err != nil {
+			return nil, err
+		}
+		validator := w.validators.GetValidator(ns.Name)
+		for i := range pods.Items {
+			for _, image := range podImages(&pods.Items[i]) {
+				img := warmUpImage{validator: validator, image: image}
+				if !seen[img] {
+					seen[img] = true
+					images = append(images, img)
+				}
+			}
+		}
+	}
+	return images, nil
+}
@@ -0,0 +1,128 @@
+package validate
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kyma-project/warden/pkg"
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newWarmUpObjects() []runtime.Object {
+	enabled := map[string]string{pkg.NamespaceValidationLabel: pkg.NamespaceValidationEnabled}
+	pod := func(namespace, name string, images ...string) *corev1.Pod {
+		p := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+		for _, image := range images {
+			p.Spec.Containers = append(p.Spec.Containers, corev1.Container{Name: name, Image: image})
+		}
+		return p
+	}
+	return []runtime.Object{
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default", Labels: enabled}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tools", Labels: enabled}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+		pod("default", "app", "eu.gcr.io/kyma-project/app:1.0"),
+		pod("default", "app-2", "eu.gcr.io/kyma-project/app:1.0"),
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "with-init"},
+			Spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{{Name: "init", Image: "eu.gcr.io/kyma-project/init:1.0"}},
+				Containers:     []corev1.Container{{Name: "app", Image: "eu.gcr.io/kyma-project/app:1.0"}},
+			},
+		},
+		pod("tools", "builder", "eu.gcr.io/kyma-project/app:1.0"),
+		pod("kube-system", "dns", "eu.gcr.io/kyma-project/dns:1.0"),
+	}
+}
+
+func newWarmUpScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	return scheme
+}
+
+func TestCacheWarmer(t *testing.T) {
+	t.Run("images of pods in namespaces with enabled validation are validated once per validator", func(t *testing.T) {
+		//GIVEN
+		var notaryCalls int32
+		f := func(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+			atomic.AddInt32(&notaryCalls, 1)
+			return nil, client.ErrNoSuchTarget(name)
+		}
+		validators, err := NewNamespacedImageValidators(&ServiceConfig{},
+			map[string]ServiceConfigPatch{"tools": {NotaryURL: "https://notary.tools"}},
+			WithRepoFactory(MockNotaryRepoFactory{GetTargetByNameFunc: &f}))
+		require.NoError(t, err)
+		reader := fake.NewClientBuilder().WithScheme(newWarmUpScheme(t)).WithRuntimeObjects(newWarmUpObjects()...).Build()
+		warmer := NewCacheWarmer(reader, validators, time.Minute, 1)
+		require.ErrorIs(t, warmer.Check(&http.Request{}), errWarmUpInProgress)
+
+		//WHEN
+		err = warmer.Start(context.TODO())
+
+		//THEN
+		require.NoError(t, err)
+		require.NoError(t, warmer.Check(&http.Request{}))
+		// app and init images with the global validator, app image with the validator of tools
+		require.Equal(t, int32(3), atomic.LoadInt32(&notaryCalls))
+
+		// the denials are cached, so admissions don't ask notary again
+		require.Error(t, validators.GetValidator("default").Validate(context.TODO(), "eu.gcr.io/kyma-project/app:1.0"))
+		require.Error(t, validators.GetValidator("tools").Validate(context.TODO(), "eu.gcr.io/kyma-project/app:1.0"))
+		require.Equal(t, int32(3), atomic.LoadInt32(&notaryCalls))
+	})
+
+	t.Run("warm-up is bounded by the timeout", func(t *testing.T) {
+		//GIVEN
+		release := make(chan struct{})
+		defer close(release)
+		f := func(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+			<-release
+			return nil, client.ErrNoSuchTarget(name)
+		}
+		s := NewDefaultMockNotaryService().WithFunc(f).Build()
+		s.NotaryTimeout = time.Minute
+		reader := fake.NewClientBuilder().WithScheme(newWarmUpScheme(t)).WithRuntimeObjects(newWarmUpObjects()...).Build()
+		timeout := 100 * time.Millisecond
+		warmer := NewCacheWarmer(reader, staticValidator{validator: &s}, timeout, 1)
+		start := time.Now()
+
+		//WHEN
+		err := warmer.Start(context.TODO())
+
+		//THEN
+		require.NoError(t, err)
+		require.Less(t, time.Since(start), 10*timeout)
+		require.NoError(t, warmer.Check(&http.Request{}))
+	})
+
+	t.Run("list failure isn't fatal", func(t *testing.T) {
+		//GIVEN
+		var notaryCalls int32
+		f := func(name string, roles ...data.RoleName) (*client.TargetWithRole, error) {
+			atomic.AddInt32(&notaryCalls, 1)
+			return nil, client.ErrNoSuchTarget(name)
+		}
+		s := NewDefaultMockNotaryService().WithFunc(f).Build()
+		// pods and namespaces aren't known to the empty scheme
+		reader := fake.NewClientBuilder().WithScheme(runtime.NewScheme()).Build()
+		warmer := NewCacheWarmer(reader, staticValidator{validator: &s}, time.Minute, 1)
+
+		//WHEN
+		err := warmer.Start(context.TODO())
+
+		//THEN
+		require.NoError(t, err)
+		require.NoError(t, warmer.Check(&http.Request{}))
+		require.Zero(t, atomic.LoadInt32(&notaryCalls))
+	})
+}
@@ -0,0 +1,85 @@
+package certs
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	"k8s.io/client-go/discovery"
+)
+
+const (
+	AdmissionReviewV1      = "v1"
+	AdmissionReviewV1beta1 = "v1beta1"
+)
+
+var (
+	// defaultAdmissionReviewVersions are advertised when the versions weren't negotiated, the API server
+	// sends the first one it supports.
+	defaultAdmissionReviewVersions   = []string{AdmissionReviewV1beta1, AdmissionReviewV1}
+	supportedAdmissionReviewVersions = []string{AdmissionReviewV1, AdmissionReviewV1beta1}
+)
+
+func validateAdmissionReviewVersions(versions []string) []error {
+	if versions == nil {
+		return nil
+	}
+	if len(versions) == 0 {
+		return []error{errors.New("webhook must have at least one admission review version")}
+	}
+	var errs []error
+	seen := map[string]bool{}
+	for _, v := range versions {
+		switch {
+		case !containsVersion(supportedAdmissionReviewVersions, v):
+			errs = append(errs, fmt.Errorf("unsupported webhook admission review version %s, supported are %v", v, supportedAdmissionReviewVersions))
+		case seen[v]:
+			errs = append(errs, fmt.Errorf("webhook admission review version %s is duplicated", v))
+		}
+		seen[v] = true
+	}
+	return errs
+}
+
+func containsVersion(versions []string, version string) bool {
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+func (c WebhookConfig) admissionReviewVersions() []string {
+	if c.AdmissionReviewVersions != nil {
+		return append([]string{}, c.AdmissionReviewVersions...)
+	}
+	return append([]string{}, defaultAdmissionReviewVersions...)
+}
+
+// NegotiateAdmissionReviewVersions advertises v1beta1 only to API servers which still serve the v1beta1
+// admissionregistration API, current ones get v1 alone. Versions set in the config aren't negotiated,
+// e.g. on air-gapped clusters where discovery is unreliable. The defaults are kept when the discovery fails,
+// the error tells why.
+func (c WebhookConfig) NegotiateAdmissionReviewVersions(d discovery.ServerGroupsInterface) (WebhookConfig, error) {
+	if c.AdmissionReviewVersions != nil {
+		return c, nil
+	}
+	groups, err := d.ServerGroups()
+	if err != nil {
+		return c, errors.Wrap(err, "while discovering admissionregistration versions for webhook admission reviews")
+	}
+	c.AdmissionReviewVersions = []string{AdmissionReviewV1}
+	for _, group := range groups.Groups {
+		if group.Name != admissionregistrationv1.GroupName {
+			continue
+		}
+		for _, version := range group.Versions {
+			if version.Version == admissionregistrationv1beta1.SchemeGroupVersion.Version {
+				c.AdmissionReviewVersions = append([]string{}, defaultAdmissionReviewVersions...)
+			}
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,99 @@
+package certs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestWebhookConfig_NegotiateAdmissionReviewVersions(t *testing.T) {
+	server := func(versions ...string) *fakediscovery.FakeDiscovery {
+		fake := &clienttesting.Fake{}
+		for _, version := range versions {
+			fake.Resources = append(fake.Resources, &metav1.APIResourceList{
+				GroupVersion: "admissionregistration.k8s.io/" + version,
+				APIResources: []metav1.APIResource{{Name: "validatingwebhookconfigurations"}},
+			})
+		}
+		fake.Resources = append(fake.Resources, &metav1.APIResourceList{GroupVersion: "v1", APIResources: []metav1.APIResource{{Name: "pods"}}})
+		return &fakediscovery.FakeDiscovery{Fake: fake}
+	}
+
+	t.Run("v1 on current servers", func(t *testing.T) {
+		got, err := WebhookConfig{}.NegotiateAdmissionReviewVersions(server("v1"))
+
+		require.NoError(t, err)
+		require.Equal(t, []string{"v1"}, got.AdmissionReviewVersions)
+	})
+
+	t.Run("v1beta1 and v1 on old servers", func(t *testing.T) {
+		got, err := WebhookConfig{}.NegotiateAdmissionReviewVersions(server("v1", "v1beta1"))
+
+		require.NoError(t, err)
+		require.Equal(t, []string{"v1beta1", "v1"}, got.AdmissionReviewVersions)
+	})
+
+	t.Run("configured versions aren't negotiated", func(t *testing.T) {
+		got, err := WebhookConfig{AdmissionReviewVersions: []string{"v1beta1"}}.NegotiateAdmissionReviewVersions(failingDiscovery{})
+
+		require.NoError(t, err)
+		require.Equal(t, []string{"v1beta1"}, got.AdmissionReviewVersions)
+	})
+
+	t.Run("defaults when the versions can't be discovered", func(t *testing.T) {
+		got, err := WebhookConfig{}.NegotiateAdmissionReviewVersions(failingDiscovery{})
+
+		require.ErrorContains(t, err, "while discovering admissionregistration versions for webhook admission reviews")
+		require.Nil(t, got.AdmissionReviewVersions)
+		require.Equal(t, []string{"v1beta1", "v1"}, got.admissionReviewVersions())
+	})
+}
+
+func TestEnsureWebhookConfigurationFor_AdmissionReviewVersions(t *testing.T) {
+	base := WebhookConfig{ServiceName: "warden-admission", ServiceNamespace: "kyma-system", CABundel: []byte("ca")}
+
+	t.Run("negotiated versions in all webhooks", func(t *testing.T) {
+		//GIVEN
+		client := newFakeClient()
+		config := base
+		config.ValidateWorkloads = true
+		config.AdmissionReviewVersions = []string{"v1"}
+
+		//WHEN
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, MutatingWebhook))
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, ValidatingWebHook))
+
+		//THEN
+		mutating := &admissionregistrationv1.MutatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: DefaultingWebhookName}, mutating))
+		require.Equal(t, []string{"v1"}, mutating.Webhooks[0].AdmissionReviewVersions)
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, validating))
+		for _, webhook := range validating.Webhooks {
+			require.Equal(t, []string{"v1"}, webhook.AdmissionReviewVersions)
+		}
+	})
+
+	t.Run("invalid versions", func(t *testing.T) {
+		config := base
+		config.AdmissionReviewVersions = []string{"v1", "v2", "v1"}
+
+		err := config.Validate()
+
+		require.EqualError(t, err, "[unsupported webhook admission review version v2, supported are [v1 v1beta1], "+
+			"webhook admission review version v1 is duplicated]")
+	})
+
+	t.Run("no versions", func(t *testing.T) {
+		config := base
+		config.AdmissionReviewVersions = []string{}
+
+		require.EqualError(t, config.Validate(), "webhook must have at least one admission review version")
+	})
+}
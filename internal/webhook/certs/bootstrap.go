@@ -0,0 +1,271 @@
+package certs
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
+	"k8s.io/utils/clock"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	CACertFile = "ca-cert.pem"
+
+	// DefaultCertificateValidity is the lifetime of the CA and the serving certificate.
+	DefaultCertificateValidity = 365 * 24 * time.Hour
+	// DefaultRenewalThreshold renews certificates after 2/3 of their lifetime.
+	DefaultRenewalThreshold = 2.0 / 3
+)
+
+// CertificateOptions describes the serving certificate of the webhook service and the Secret it's stored in.
+type CertificateOptions struct {
+	SecretName       string
+	ServiceName      string
+	ServiceNamespace string
+	// ClusterDomain qualifies the service names, DefaultClusterDomain when it's empty.
+	ClusterDomain string
+	// ExtraSANs are added to the service names, the certificate is renewed when they change.
+	ExtraSANs []string
+	// Validity is DefaultCertificateValidity when it's zero.
+	Validity time.Duration
+	// RenewalThreshold is the part of the certificate lifetime after which it's renewed,
+	// DefaultRenewalThreshold when it's zero.
+	RenewalThreshold float64
+	// Clock is the real clock when it's nil.
+	Clock clock.PassiveClock
+}
+
+func (o CertificateOptions) withDefaults() CertificateOptions {
+	if o.Validity == 0 {
+		o.Validity = DefaultCertificateValidity
+	}
+	if o.RenewalThreshold == 0 {
+		o.RenewalThreshold = DefaultRenewalThreshold
+	}
+	if o.Clock == nil {
+		o.Clock = clock.RealClock{}
+	}
+	return o
+}
+
+// altNames returns the DNS names the serving certificate is valid for.
+func (o CertificateOptions) altNames() []string {
+	return serviceAltNames(o.ServiceName, o.ServiceNamespace, o.ClusterDomain, o.ExtraSANs)
+}
+
+// EnsureCertificate makes sure the Secret in the service namespace holds a CA and the serving certificate and key
+// of the webhook service signed by it, and returns the CA bundle for the webhook configurations.
+// A Secret with the certificate valid for the service names is reused, so restarts keep the certificate.
+// When replicas race to create the Secret, the one which loses uses the certificate of the winner.
+// Renewed certificates are signed by a new CA, the bundle keeps the previous CAs until they expire,
+// so the certificates they signed stay trusted while the replicas switch to the new one.
+func EnsureCertificate(ctx context.Context, client ctrlclient.Client, opts CertificateOptions) ([]byte, error) {
+	secret, err := ensureCertificateSecret(ctx, client, opts.withDefaults())
+	if err != nil {
+		return nil, err
+	}
+	return secret.Data[CACertFile], nil
+}
+
+func ensureCertificateSecret(ctx context.Context, client ctrlclient.Client, opts CertificateOptions) (*corev1.Secret, error) {
+	key := types.NamespacedName{Name: opts.SecretName, Namespace: opts.ServiceNamespace}
+
+	secret := &corev1.Secret{}
+	err := client.Get(ctx, key, secret)
+	if apiErrors.IsNotFound(err) {
+		created, err := buildCertificateSecret(opts, nil)
+		if err != nil {
+			return nil, err
+		}
+		err = client.Create(ctx, created)
+		if err == nil {
+			return created, nil
+		}
+		if !apiErrors.IsAlreadyExists(err) {
+			return nil, errors.Wrap(err, "failed to create certificate secret")
+		}
+		// another replica created the secret in the meantime
+		if err := client.Get(ctx, key, secret); err != nil {
+			return nil, errors.Wrap(err, "failed to get certificate secret")
+		}
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to get certificate secret")
+	}
+
+	if serving, err := verifyServingCertificate(secret.Data, opts); err == nil && !renewalDue(serving, opts) {
+		return secret, nil
+	}
+	renewed, err := buildCertificateSecret(opts, secret.Data[CACertFile])
+	if err != nil {
+		return nil, err
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	for k, v := range renewed.Data {
+		secret.Data[k] = v
+	}
+	err = client.Update(ctx, secret)
+	if apiErrors.IsConflict(err) {
+		// another replica renewed the secret in the meantime
+		if err := client.Get(ctx, key, secret); err != nil {
+			return nil, errors.Wrap(err, "failed to get certificate secret")
+		}
+		if _, err := verifyServingCertificate(secret.Data, opts); err != nil {
+			return nil, errors.Wrap(err, "certificate secret was updated with an invalid certificate")
+		}
+		return secret, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to update certificate secret")
+	}
+	return secret, nil
+}
+
+// verifyServingCertificate checks the certificate matches the key and is signed by the CA bundle
+// for exactly the names of the options, and returns the certificate.
+func verifyServingCertificate(data map[string][]byte, opts CertificateOptions) (*x509.Certificate, error) {
+	for _, k := range []string{CACertFile, CertFile, KeyFile} {
+		if len(data[k]) == 0 {
+			return nil, errors.Errorf("certificate secret has no %s", k)
+		}
+	}
+	if _, err := tls.X509KeyPair(data[CertFile], data[KeyFile]); err != nil {
+		return nil, errors.Wrap(err, "certificate doesn't match the key")
+	}
+	certificates, err := cert.ParseCertsPEM(data[CertFile])
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse certificate data")
+	}
+	roots, err := cert.NewPoolFromBytes(data[CACertFile])
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse CA data")
+	}
+	altNames := opts.altNames()
+	if !hasAltNames(certificates[0], altNames) {
+		return nil, errors.Errorf("certificate SANs %v don't match the configured SANs %v", certificates[0].DNSNames, altNames)
+	}
+	for _, name := range altNames {
+		_, err := certificates[0].Verify(x509.VerifyOptions{
+			DNSName:     name,
+			Roots:       roots,
+			CurrentTime: opts.Clock.Now(),
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "certificate verification failed")
+		}
+	}
+	return certificates[0], nil
+}
+
+// renewalDue is true when RenewalThreshold of the certificate lifetime passed.
+func renewalDue(c *x509.Certificate, opts CertificateOptions) bool {
+	lifetime := c.NotAfter.Sub(c.NotBefore)
+	renewAt := c.NotBefore.Add(time.Duration(float64(lifetime) * opts.RenewalThreshold))
+	return !opts.Clock.Now().Before(renewAt)
+}
+
+// buildCertificateSecret generates the secret with a new CA, the bundle keeps the CAs of the previous bundle
+// which didn't expire yet.
+func buildCertificateSecret(opts CertificateOptions, previousBundle []byte) (*corev1.Secret, error) {
+	ca, servingCert, servingKey, err := generateServingCertificate(opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate webhook certificates")
+	}
+	// the previous bundle may be missing or broken, then only the new CA is trusted
+	if previous, err := cert.ParseCertsPEM(previousBundle); err == nil {
+		for _, c := range previous {
+			if c.IsCA && opts.Clock.Now().Before(c.NotAfter) {
+				ca = append(ca, pem.EncodeToMemory(&pem.Block{Type: cert.CertificateBlockType, Bytes: c.Raw})...)
+			}
+		}
+	}
+	return &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      opts.SecretName,
+			Namespace: opts.ServiceNamespace,
+		},
+		Data: map[string][]byte{
+			CACertFile: ca,
+			CertFile:   servingCert,
+			KeyFile:    servingKey,
+		},
+	}, nil
+}
+
+// generateServingCertificate returns the PEM encoded CA, and the serving certificate and key signed by it.
+func generateServingCertificate(opts CertificateOptions) ([]byte, []byte, []byte, error) {
+	altNames := opts.altNames()
+	now := opts.Clock.Now()
+
+	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	caSerial, err := newSerialNumber()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          caSerial,
+		Subject:               pkix.Name{CommonName: altNames[0] + "-ca"},
+		NotBefore:             now.Add(-time.Minute).UTC(),
+		NotAfter:              now.Add(opts.Validity).UTC(),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	serial, err := newSerialNumber()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: altNames[0]},
+		DNSNames:     altNames,
+		NotBefore:    now.Add(-time.Minute).UTC(),
+		NotAfter:     now.Add(opts.Validity).UTC(),
+		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: cert.CertificateBlockType, Bytes: caDER})
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: cert.CertificateBlockType, Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: keyutil.RSAPrivateKeyBlockType, Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return caPEM, certPEM, keyPEM, nil
+}
+
+func newSerialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
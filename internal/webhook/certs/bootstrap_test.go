@@ -0,0 +1,201 @@
+package certs
+
+import (
+	"context"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/cert"
+	testingclock "k8s.io/utils/clock/testing"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// racingClient creates the secret of another replica right before the Create of the tested one.
+type racingClient struct {
+	ctrlclient.Client
+	winner *corev1.Secret
+}
+
+func (c racingClient) Create(ctx context.Context, obj ctrlclient.Object, opts ...ctrlclient.CreateOption) error {
+	if err := c.Client.Create(ctx, c.winner.DeepCopy()); err != nil {
+		return err
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func TestEnsureCertificate(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	opts := CertificateOptions{
+		SecretName:       "warden-webhook-cert",
+		ServiceName:      "warden-admission",
+		ServiceNamespace: "kyma-system",
+		Validity:         30 * 24 * time.Hour,
+		Clock:            testingclock.NewFakeClock(now),
+	}
+	key := types.NamespacedName{Name: opts.SecretName, Namespace: opts.ServiceNamespace}
+	getSecret := func(t *testing.T, client ctrlclient.Client) *corev1.Secret {
+		secret := &corev1.Secret{}
+		require.NoError(t, client.Get(context.TODO(), key, secret))
+		return secret
+	}
+
+	t.Run("creates the secret with the certificate of the service", func(t *testing.T) {
+		//GIVEN
+		client := fake.NewClientBuilder().Build()
+
+		//WHEN
+		caBundle, err := EnsureCertificate(context.TODO(), client, opts)
+
+		//THEN
+		require.NoError(t, err)
+		secret := getSecret(t, client)
+		require.Equal(t, caBundle, secret.Data[CACertFile])
+
+		certificates, err := cert.ParseCertsPEM(secret.Data[CertFile])
+		require.NoError(t, err)
+		serving := certificates[0]
+		require.ElementsMatch(t, []string{
+			"warden-admission",
+			"warden-admission.kyma-system",
+			"warden-admission.kyma-system.svc",
+			"warden-admission.kyma-system.svc.cluster.local",
+		}, serving.DNSNames)
+		require.Equal(t, now.Add(opts.Validity), serving.NotAfter)
+		require.False(t, serving.NotBefore.After(now))
+		require.False(t, serving.IsCA)
+
+		roots, err := cert.NewPoolFromBytes(caBundle)
+		require.NoError(t, err)
+		_, err = serving.Verify(x509.VerifyOptions{
+			DNSName:     "warden-admission.kyma-system.svc",
+			Roots:       roots,
+			CurrentTime: now,
+		})
+		require.NoError(t, err)
+		_, err = serving.Verify(x509.VerifyOptions{Roots: roots, CurrentTime: now.Add(opts.Validity + time.Minute)})
+		require.Error(t, err)
+
+		cas, err := cert.ParseCertsPEM(caBundle)
+		require.NoError(t, err)
+		require.True(t, cas[0].IsCA)
+		require.NoError(t, verifyKey(secret.Data[KeyFile]))
+	})
+
+	t.Run("reuses the valid secret", func(t *testing.T) {
+		//GIVEN
+		client := fake.NewClientBuilder().Build()
+		first, err := EnsureCertificate(context.TODO(), client, opts)
+		require.NoError(t, err)
+		before := getSecret(t, client)
+
+		//WHEN
+		second, err := EnsureCertificate(context.TODO(), client, opts)
+
+		//THEN
+		require.NoError(t, err)
+		require.Equal(t, first, second)
+		require.Equal(t, before.ResourceVersion, getSecret(t, client).ResourceVersion)
+	})
+
+	t.Run("renews the certificate which is about to expire", func(t *testing.T) {
+		//GIVEN
+		client := fake.NewClientBuilder().Build()
+		first, err := EnsureCertificate(context.TODO(), client, opts)
+		require.NoError(t, err)
+		later := opts
+		later.Clock = testingclock.NewFakeClock(now.Add(opts.Validity - 5*24*time.Hour))
+
+		//WHEN
+		second, err := EnsureCertificate(context.TODO(), client, later)
+
+		//THEN
+		require.NoError(t, err)
+		require.NotEqual(t, first, second)
+		require.Equal(t, second, getSecret(t, client).Data[CACertFile])
+	})
+
+	t.Run("renews the valid certificate when the SANs change", func(t *testing.T) {
+		//GIVEN
+		client := fake.NewClientBuilder().Build()
+		first, err := EnsureCertificate(context.TODO(), client, opts)
+		require.NoError(t, err)
+		custom := opts
+		custom.ClusterDomain = "cluster.example"
+		custom.ExtraSANs = []string{"warden-admission-headless.kyma-system.svc"}
+
+		//WHEN
+		second, err := EnsureCertificate(context.TODO(), client, custom)
+
+		//THEN
+		require.NoError(t, err)
+		require.NotEqual(t, first, second)
+		serving, err := verifyServingCertificate(getSecret(t, client).Data, custom.withDefaults())
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{
+			"warden-admission",
+			"warden-admission.kyma-system",
+			"warden-admission.kyma-system.svc",
+			"warden-admission.kyma-system.svc.cluster.example",
+			"warden-admission-headless.kyma-system.svc",
+		}, serving.DNSNames)
+	})
+
+	t.Run("replaces the certificate of other service names and keeps other keys", func(t *testing.T) {
+		//GIVEN
+		client := fake.NewClientBuilder().Build()
+		renamed := opts
+		renamed.ServiceName = "warden-old"
+		_, err := EnsureCertificate(context.TODO(), client, renamed)
+		require.NoError(t, err)
+		secret := getSecret(t, client)
+		secret.Data["extra"] = []byte("kept")
+		require.NoError(t, client.Update(context.TODO(), secret))
+
+		//WHEN
+		caBundle, err := EnsureCertificate(context.TODO(), client, opts)
+
+		//THEN
+		require.NoError(t, err)
+		secret = getSecret(t, client)
+		require.Equal(t, caBundle, secret.Data[CACertFile])
+		_, err = verifyServingCertificate(secret.Data, opts.withDefaults())
+		require.NoError(t, err)
+		require.Equal(t, []byte("kept"), secret.Data["extra"])
+	})
+
+	t.Run("replaces the self-signed secret without the CA", func(t *testing.T) {
+		//GIVEN
+		legacy, err := buildSecret(opts.SecretName, opts.ServiceNamespace, opts.altNames())
+		require.NoError(t, err)
+		client := fake.NewClientBuilder().WithObjects(legacy).Build()
+
+		//WHEN
+		caBundle, err := EnsureCertificate(context.TODO(), client, opts)
+
+		//THEN
+		require.NoError(t, err)
+		require.NotEmpty(t, caBundle)
+		_, err = verifyServingCertificate(getSecret(t, client).Data, opts.withDefaults())
+		require.NoError(t, err)
+	})
+
+	t.Run("replica which loses the race uses the secret of the winner", func(t *testing.T) {
+		//GIVEN
+		winner, err := buildCertificateSecret(opts.withDefaults(), nil)
+		require.NoError(t, err)
+		client := racingClient{Client: fake.NewClientBuilder().Build(), winner: winner}
+
+		//WHEN
+		caBundle, err := EnsureCertificate(context.TODO(), client, opts)
+
+		//THEN
+		require.NoError(t, err)
+		require.Equal(t, winner.Data[CACertFile], caBundle)
+		require.Equal(t, winner.Data, getSecret(t, client).Data)
+	})
+}
@@ -0,0 +1,178 @@
+package certs
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// CertificateReloader switches the webhook server to the certificate of the Secret, e.g. SecretCertificateLoader.
+type CertificateReloader interface {
+	Load(ctx context.Context) error
+}
+
+// SetupCABundleController watches the CABundleSecret of the config, when it's rotated, e.g. by Warden or by
+// another process, the CA bundle of both webhook configurations is replaced and reloader, when it's set,
+// switches the webhook server to the new certificate. The configurations are watched too, so a CA bundle
+// changed by someone else is injected again.
+func SetupCABundleController(mgr ctrl.Manager, webhookConfig WebhookConfig, reloader CertificateReloader, log *zap.SugaredLogger) error {
+	if webhookConfig.CABundleSecret == "" {
+		return errors.New("CA bundle controller requires the CA bundle secret")
+	}
+	secret := types.NamespacedName{Name: webhookConfig.CABundleSecret, Namespace: webhookConfig.ServiceNamespace}
+	c, err := controller.New("ca-bundle-controller", mgr, controller.Options{
+		Reconciler: &caBundleReconciler{
+			webhookConfig: webhookConfig,
+			client:        mgr.GetClient(),
+			reloader:      reloader,
+			logger:        log.Named("ca-bundle-controller"),
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to create ca-bundle-controller")
+	}
+
+	if err := c.Watch(&source.Kind{
+		Type: &corev1.Secret{}},
+		&handler.EnqueueRequestForObject{},
+		predicate.NewPredicateFuncs(func(object ctrlclient.Object) bool {
+			return object.GetName() == secret.Name && object.GetNamespace() == secret.Namespace
+		}),
+	); err != nil {
+		return errors.Wrap(err, "failed to watch Secrets")
+	}
+	// every change of the configurations is reconciled as a change of the secret
+	enqueueSecret := handler.EnqueueRequestsFromMapFunc(func(ctrlclient.Object) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: secret}}
+	})
+	managedWebhooks := predicate.NewPredicateFuncs(func(object ctrlclient.Object) bool {
+		return object.GetName() == webhookConfig.webhookName(DefaultingWebhookName) ||
+			object.GetName() == webhookConfig.webhookName(ValidationWebhookName)
+	})
+	if err := c.Watch(&source.Kind{Type: &admissionregistrationv1.MutatingWebhookConfiguration{}}, enqueueSecret, managedWebhooks); err != nil {
+		return errors.Wrap(err, "failed to watch MutatingWebhookConfiguration")
+	}
+	if err := c.Watch(&source.Kind{Type: &admissionregistrationv1.ValidatingWebhookConfiguration{}}, enqueueSecret, managedWebhooks); err != nil {
+		return errors.Wrap(err, "failed to watch ValidatingWebhookConfiguration")
+	}
+	return nil
+}
+
+type caBundleReconciler struct {
+	webhookConfig WebhookConfig
+	client        ctrlclient.Client
+	reloader      CertificateReloader
+	logger        *zap.SugaredLogger
+}
+
+func (r *caBundleReconciler) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	secret := &corev1.Secret{}
+	if err := r.client.Get(ctx, request.NamespacedName, secret); err != nil {
+		if apiErrors.IsNotFound(err) {
+			// the configurations keep the CA bundle until the secret is created again
+			r.logger.With("name", request.Name).Warn("certificate secret not found")
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, errors.Wrapf(err, "failed to get certificate secret %s", request.NamespacedName)
+	}
+	bundle := caBundleFromSecret(secret)
+	if len(bundle) == 0 {
+		return reconcile.Result{}, errors.Errorf("certificate secret %s has no CA bundle", request.NamespacedName)
+	}
+
+	// the new CA is trusted before the server switches to the certificate it signed
+	mutatingPatched, validatingPatched, err := injectCABundles(ctx, r.client, r.webhookConfig, bundle)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if mutatingPatched || validatingPatched {
+		r.logger.With("defaulting", mutatingPatched, "validation", validatingPatched).Info("CA bundle injected into webhook configurations")
+	}
+
+	if r.reloader != nil {
+		if err := r.reloader.Load(ctx); err != nil {
+			return reconcile.Result{}, errors.Wrap(err, "failed to reload serving certificate")
+		}
+	}
+	return reconcile.Result{}, nil
+}
+
+// injectCABundles sets the CA bundle of both webhook configurations, it returns whether the defaulting
+// and the validation configuration were patched.
+func injectCABundles(ctx context.Context, client ctrlclient.Client, config WebhookConfig, bundle []byte) (bool, bool, error) {
+	mutating := &admissionregistrationv1.MutatingWebhookConfiguration{}
+	mutating.Name = config.webhookName(DefaultingWebhookName)
+	mutatingPatched, err := injectCABundle(ctx, client, mutating, bundle, func() []*admissionregistrationv1.WebhookClientConfig {
+		clientConfigs := make([]*admissionregistrationv1.WebhookClientConfig, 0, len(mutating.Webhooks))
+		for i := range mutating.Webhooks {
+			clientConfigs = append(clientConfigs, &mutating.Webhooks[i].ClientConfig)
+		}
+		return clientConfigs
+	})
+	if err != nil {
+		return false, false, err
+	}
+	validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+	validating.Name = config.webhookName(ValidationWebhookName)
+	validatingPatched, err := injectCABundle(ctx, client, validating, bundle, func() []*admissionregistrationv1.WebhookClientConfig {
+		clientConfigs := make([]*admissionregistrationv1.WebhookClientConfig, 0, len(validating.Webhooks))
+		for i := range validating.Webhooks {
+			clientConfigs = append(clientConfigs, &validating.Webhooks[i].ClientConfig)
+		}
+		return clientConfigs
+	})
+	return mutatingPatched, validatingPatched, err
+}
+
+// injectCABundle patches the CA bundle of the webhooks which have another one, the configurations which don't
+// exist yet are left to the resources controller. It returns true when the configuration was patched.
+func injectCABundle(ctx context.Context, client ctrlclient.Client, configuration ctrlclient.Object, bundle []byte, clientConfigs func() []*admissionregistrationv1.WebhookClientConfig) (bool, error) {
+	name := configuration.GetName()
+	if err := client.Get(ctx, types.NamespacedName{Name: name}, configuration); err != nil {
+		if apiErrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "failed to get webhook configuration %s", name)
+	}
+	original := configuration.DeepCopyObject().(ctrlclient.Object)
+	changed := false
+	for _, clientConfig := range clientConfigs() {
+		if !bytes.Equal(clientConfig.CABundle, bundle) {
+			clientConfig.CABundle = bundle
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+	// the lock fails the patch when the webhooks were changed in the meantime, the caller retries then
+	patch := ctrlclient.MergeFromWithOptions(original, ctrlclient.MergeFromWithOptimisticLock{})
+	if err := client.Patch(ctx, configuration, patch, ctrlclient.FieldOwner(FieldManager)); err != nil {
+		return false, errors.Wrapf(err, "failed to inject CA bundle into webhook configuration %s", name)
+	}
+	return true, nil
+}
+
+// caBundleFromSecret returns the CA of the secret, the keys of cert-manager and of Warden are looked up,
+// the certificate itself is the CA of the self-signed secret.
+func caBundleFromSecret(secret *corev1.Secret) []byte {
+	for _, key := range []string{corev1.ServiceAccountRootCAKey, CACertFile, CertFile} {
+		if len(secret.Data[key]) > 0 {
+			return secret.Data[key]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package certs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// reloadCounter counts the reloads of the serving certificate.
+type reloadCounter struct {
+	reloads int
+	err     error
+}
+
+func (r *reloadCounter) Load(context.Context) error {
+	r.reloads++
+	return r.err
+}
+
+func TestCABundleReconciler(t *testing.T) {
+	config := WebhookConfig{
+		ServiceName:      "warden-admission",
+		ServiceNamespace: "kyma-system",
+		CABundel:         []byte("old-ca"),
+		CABundleSecret:   "warden-admission-cert",
+	}
+	secretKey := types.NamespacedName{Name: config.CABundleSecret, Namespace: config.ServiceNamespace}
+	newSecret := func(data map[string][]byte) *corev1.Secret {
+		return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: secretKey.Name, Namespace: secretKey.Namespace}, Data: data}
+	}
+	newClient := func(t *testing.T, secret *corev1.Secret) ctrlclient.Client {
+		client := newFakeClient(secret)
+		require.NoError(t, EnsureAllWebhookConfigurations(context.TODO(), client, config))
+		return client
+	}
+	caBundles := func(t *testing.T, client ctrlclient.Client) ([]byte, []byte) {
+		mutating := &admissionregistrationv1.MutatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: DefaultingWebhookName}, mutating))
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, validating))
+		for _, webhook := range validating.Webhooks {
+			require.Equal(t, validating.Webhooks[0].ClientConfig.CABundle, webhook.ClientConfig.CABundle)
+		}
+		return mutating.Webhooks[0].ClientConfig.CABundle, validating.Webhooks[0].ClientConfig.CABundle
+	}
+	reconcileSecret := func(client ctrlclient.Client, reloader CertificateReloader) error {
+		r := &caBundleReconciler{webhookConfig: config, client: client, reloader: reloader, logger: zap.NewNop().Sugar()}
+		_, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: secretKey})
+		return err
+	}
+
+	t.Run("injects the rotated CA and reloads the certificate", func(t *testing.T) {
+		//GIVEN
+		client := newClient(t, newSecret(map[string][]byte{CACertFile: []byte("new-ca"), CertFile: []byte("cert")}))
+		reloader := &reloadCounter{}
+
+		//WHEN
+		err := reconcileSecret(client, reloader)
+
+		//THEN
+		require.NoError(t, err)
+		mutating, validating := caBundles(t, client)
+		require.Equal(t, []byte("new-ca"), mutating)
+		require.Equal(t, []byte("new-ca"), validating)
+		require.Equal(t, 1, reloader.reloads)
+	})
+
+	t.Run("doesn't patch the configurations with the same CA", func(t *testing.T) {
+		//GIVEN
+		client := newClient(t, newSecret(map[string][]byte{CertFile: []byte("old-ca")}))
+		before := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, before))
+
+		//WHEN
+		err := reconcileSecret(client, nil)
+
+		//THEN
+		require.NoError(t, err)
+		after := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, after))
+		require.Equal(t, before.ResourceVersion, after.ResourceVersion)
+	})
+
+	t.Run("prefers the CA of cert-manager", func(t *testing.T) {
+		//GIVEN
+		client := newClient(t, newSecret(map[string][]byte{
+			corev1.ServiceAccountRootCAKey: []byte("issuer-ca"),
+			corev1.TLSCertKey:              []byte("cert"),
+			CertFile:                       []byte("self-signed"),
+		}))
+
+		//WHEN
+		err := reconcileSecret(client, nil)
+
+		//THEN
+		require.NoError(t, err)
+		mutating, _ := caBundles(t, client)
+		require.Equal(t, []byte("issuer-ca"), mutating)
+	})
+
+	t.Run("secret without CA error", func(t *testing.T) {
+		//GIVEN
+		client := newClient(t, newSecret(map[string][]byte{KeyFile: []byte("key")}))
+
+		//WHEN
+		err := reconcileSecret(client, nil)
+
+		//THEN
+		require.EqualError(t, err, "certificate secret kyma-system/warden-admission-cert has no CA bundle")
+		mutating, _ := caBundles(t, client)
+		require.Equal(t, []byte("old-ca"), mutating)
+	})
+
+	t.Run("reload error", func(t *testing.T) {
+		//GIVEN
+		client := newClient(t, newSecret(map[string][]byte{CACertFile: []byte("new-ca")}))
+
+		//WHEN
+		err := reconcileSecret(client, &reloadCounter{err: errors.New("certificate doesn't match the key")})
+
+		//THEN
+		require.EqualError(t, err, "failed to reload serving certificate: certificate doesn't match the key")
+		mutating, _ := caBundles(t, client)
+		require.Equal(t, []byte("new-ca"), mutating)
+	})
+
+	t.Run("ensure keeps the injected CA", func(t *testing.T) {
+		//GIVEN
+		client := newClient(t, newSecret(map[string][]byte{CACertFile: []byte("new-ca")}))
+		require.NoError(t, reconcileSecret(client, nil))
+
+		//WHEN
+		mutating, validating, err := ensureAllWebhookConfigurations(context.TODO(), client, config)
+
+		//THEN
+		require.NoError(t, err)
+		require.Equal(t, EnsureUnchanged, mutating)
+		require.Equal(t, EnsureUnchanged, validating)
+		mutatingBundle, validatingBundle := caBundles(t, client)
+		require.Equal(t, []byte("new-ca"), mutatingBundle)
+		require.Equal(t, []byte("new-ca"), validatingBundle)
+	})
+}
+
+func TestCABundleController_InjectsRotatedCA(t *testing.T) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		t.Skip("KUBEBUILDER_ASSETS isn't set, envtest can't start the API server")
+	}
+	testEnv := &envtest.Environment{}
+	cfg, err := testEnv.Start()
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, testEnv.Stop())
+	}()
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{Scheme: scheme.Scheme, MetricsBindAddress: "0"})
+	require.NoError(t, err)
+	config := WebhookConfig{
+		ServiceName:      "warden-admission",
+		ServiceNamespace: "kyma-system",
+		CABundel:         []byte("old-ca"),
+		CABundleSecret:   "warden-admission-cert",
+	}
+	reloader := &reloadCounter{}
+	require.NoError(t, SetupCABundleController(mgr, config, reloader, zap.NewNop().Sugar()))
+	client, err := ctrlclient.New(cfg, ctrlclient.Options{Scheme: scheme.Scheme})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, client.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: config.ServiceNamespace}}))
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: config.CABundleSecret, Namespace: config.ServiceNamespace},
+		Data:       map[string][]byte{CACertFile: []byte("old-ca")},
+	}
+	require.NoError(t, client.Create(ctx, secret))
+	require.NoError(t, EnsureAllWebhookConfigurations(ctx, client, config))
+	go func() {
+		_ = mgr.Start(ctx)
+	}()
+
+	//WHEN
+	secret.Data[CACertFile] = []byte("new-ca")
+	require.NoError(t, client.Update(ctx, secret))
+
+	//THEN
+	require.Eventually(t, func() bool {
+		mutating := &admissionregistrationv1.MutatingWebhookConfiguration{}
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		return client.Get(ctx, types.NamespacedName{Name: DefaultingWebhookName}, mutating) == nil &&
+			client.Get(ctx, types.NamespacedName{Name: ValidationWebhookName}, validating) == nil &&
+			string(mutating.Webhooks[0].ClientConfig.CABundle) == "new-ca" &&
+			string(validating.Webhooks[0].ClientConfig.CABundle) == "new-ca"
+	}, 10*time.Second, 100*time.Millisecond)
+}
@@ -0,0 +1,147 @@
+package certs
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"k8s.io/client-go/util/cert"
+	"k8s.io/utils/clock"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultCABundleCheckInterval is how often the CA bundle file is checked for changes.
+const DefaultCABundleCheckInterval = 10 * time.Second
+
+// CABundleFile serves the CA bundle of the CABundlePath file and injects it into the webhook configurations
+// when the file changes. Contents which aren't PEM certificates are rejected, the last good bundle stays in use.
+type CABundleFile struct {
+	client        ctrlclient.Client
+	webhookConfig WebhookConfig
+	interval      time.Duration
+	clock         clock.WithTicker
+	log           *zap.SugaredLogger
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	bundle  []byte
+	// injected is the bundle the configurations got the last time, a failed injection is retried by the next sync
+	injected []byte
+}
+
+// NewCABundleFile loads the CA bundle of webhookConfig.CABundlePath, it fails when the file isn't a PEM bundle.
+func NewCABundleFile(client ctrlclient.Client, webhookConfig WebhookConfig, interval time.Duration, log *zap.SugaredLogger) (*CABundleFile, error) {
+	if interval <= 0 {
+		interval = DefaultCABundleCheckInterval
+	}
+	f := &CABundleFile{
+		client:        client,
+		webhookConfig: webhookConfig,
+		interval:      interval,
+		clock:         clock.RealClock{},
+		log:           log.Named("ca-bundle-file"),
+	}
+	if err := f.reloadIfChanged(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Bundle returns the last good CA bundle, the returned slice is never modified.
+func (f *CABundleFile) Bundle() []byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.bundle
+}
+
+// Sync reloads the file when it changed and injects a new CA bundle into the webhook configurations,
+// the configurations which were deleted are ensured with it again.
+func (f *CABundleFile) Sync(ctx context.Context) error {
+	if err := f.reloadIfChanged(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	bundle, injected := f.bundle, f.injected
+	f.mu.Unlock()
+	if bytes.Equal(bundle, injected) {
+		return nil
+	}
+	return f.inject(ctx, bundle)
+}
+
+func (f *CABundleFile) inject(ctx context.Context, bundle []byte) error {
+	config := f.webhookConfig
+	config.CABundel = bundle
+	if err := EnsureAllWebhookConfigurations(ctx, f.client, config); err != nil {
+		return err
+	}
+	mutatingPatched, validatingPatched, err := injectCABundles(ctx, f.client, config, bundle)
+	if err != nil {
+		return err
+	}
+	if mutatingPatched || validatingPatched {
+		f.log.With("defaulting", mutatingPatched, "validation", validatingPatched).Info("CA bundle injected into webhook configurations")
+	}
+	f.mu.Lock()
+	f.injected = bundle
+	f.mu.Unlock()
+	return nil
+}
+
+// reloadIfChanged reads the file when it changed, the bundle is kept when the file isn't a PEM bundle.
+func (f *CABundleFile) reloadIfChanged() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := f.webhookConfig.CABundlePath
+	info, err := os.Stat(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read CA bundle file %s", path)
+	}
+	if info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return nil
+	}
+	// the file is marked as loaded also when it's broken, so the error is reported once per change
+	f.modTime, f.size = info.ModTime(), info.Size()
+
+	bundle, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read CA bundle file %s", path)
+	}
+	if _, err := cert.ParseCertsPEM(bundle); err != nil {
+		return errors.Wrapf(err, "invalid CA bundle file %s, keeping the previous CA bundle", path)
+	}
+	f.bundle = bundle
+	return nil
+}
+
+// Start injects the CA bundle and keeps checking the file until the context is done, it implements manager.Runnable.
+// The bundle is injected at start, as the file may have changed while Warden wasn't running.
+func (f *CABundleFile) Start(ctx context.Context) error {
+	if err := f.Sync(ctx); err != nil {
+		f.log.Error("failed to sync CA bundle file ", err.Error())
+	}
+	ticker := f.clock.NewTicker(f.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C():
+			// the previous CA bundle stays injected, the next check retries
+			if err := f.Sync(ctx); err != nil {
+				f.log.Error("failed to sync CA bundle file ", err.Error())
+			}
+		}
+	}
+}
+
+// NeedLeaderElection is true, like the resources controller only the leader writes the configurations.
+func (f *CABundleFile) NeedLeaderElection() bool {
+	return true
+}
@@ -0,0 +1,158 @@
+package certs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/cert"
+	testingclock "k8s.io/utils/clock/testing"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestCABundleFile(t *testing.T) {
+	newCA := func(t *testing.T, host string) []byte {
+		ca, _, err := cert.GenerateSelfSignedCertKey(host, nil, nil)
+		require.NoError(t, err)
+		return ca
+	}
+	modTime := time.Now()
+	// every write gets a new modification time, so the changes are noticed also within the timestamp resolution
+	writeBundle := func(t *testing.T, path string, content []byte) {
+		require.NoError(t, os.WriteFile(path, content, 0o600))
+		modTime = modTime.Add(time.Second)
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+	newConfig := func(t *testing.T) WebhookConfig {
+		return WebhookConfig{
+			ServiceName:      "warden-admission",
+			ServiceNamespace: "kyma-system",
+			CABundlePath:     filepath.Join(t.TempDir(), "ca.crt"),
+		}
+	}
+	caBundles := func(t *testing.T, client ctrlclient.Client) ([]byte, []byte) {
+		mutating := &admissionregistrationv1.MutatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: DefaultingWebhookName}, mutating))
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, validating))
+		return mutating.Webhooks[0].ClientConfig.CABundle, validating.Webhooks[0].ClientConfig.CABundle
+	}
+
+	t.Run("rotated bundle is injected and a corrupt one is rejected", func(t *testing.T) {
+		//GIVEN
+		config := newConfig(t)
+		first, second, third := newCA(t, "first"), newCA(t, "second"), newCA(t, "third")
+		writeBundle(t, config.CABundlePath, first)
+		client := newFakeClient()
+		f, err := NewCABundleFile(client, config, time.Second, zap.NewNop().Sugar())
+		require.NoError(t, err)
+		require.NoError(t, f.Sync(context.TODO()))
+		mutating, validating := caBundles(t, client)
+		require.Equal(t, first, mutating)
+		require.Equal(t, first, validating)
+
+		//WHEN
+		writeBundle(t, config.CABundlePath, second)
+		secondErr := f.Sync(context.TODO())
+		secondMutating, secondValidating := caBundles(t, client)
+		writeBundle(t, config.CABundlePath, []byte("-----BEGIN CERTIFICATE-----\ntruncated"))
+		garbageErr := f.Sync(context.TODO())
+		garbageMutating, garbageValidating := caBundles(t, client)
+		garbageBundle := f.Bundle()
+		writeBundle(t, config.CABundlePath, third)
+		thirdErr := f.Sync(context.TODO())
+
+		//THEN
+		require.NoError(t, secondErr)
+		require.Equal(t, second, secondMutating)
+		require.Equal(t, second, secondValidating)
+		require.ErrorContains(t, garbageErr, "invalid CA bundle file "+config.CABundlePath+", keeping the previous CA bundle")
+		require.Equal(t, second, garbageBundle)
+		require.Equal(t, second, garbageMutating)
+		require.Equal(t, second, garbageValidating)
+		require.NoError(t, thirdErr)
+		mutating, validating = caBundles(t, client)
+		require.Equal(t, third, mutating)
+		require.Equal(t, third, validating)
+		require.Equal(t, third, f.Bundle())
+	})
+
+	t.Run("unchanged file isn't injected again", func(t *testing.T) {
+		//GIVEN
+		config := newConfig(t)
+		writeBundle(t, config.CABundlePath, newCA(t, "first"))
+		client := newFakeClient()
+		f, err := NewCABundleFile(client, config, time.Second, zap.NewNop().Sugar())
+		require.NoError(t, err)
+		require.NoError(t, f.Sync(context.TODO()))
+		mutating := &admissionregistrationv1.MutatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: DefaultingWebhookName}, mutating))
+		mutating.Webhooks[0].ClientConfig.CABundle = []byte("other")
+		require.NoError(t, client.Update(context.TODO(), mutating))
+
+		//WHEN
+		err = f.Sync(context.TODO())
+
+		//THEN
+		require.NoError(t, err)
+		bundle, _ := caBundles(t, client)
+		require.Equal(t, []byte("other"), bundle)
+	})
+
+	t.Run("checks the file until the context is done", func(t *testing.T) {
+		//GIVEN
+		config := newConfig(t)
+		writeBundle(t, config.CABundlePath, newCA(t, "first"))
+		client := newFakeClient()
+		f, err := NewCABundleFile(client, config, time.Minute, zap.NewNop().Sugar())
+		require.NoError(t, err)
+		clk := testingclock.NewFakeClock(time.Now())
+		f.clock = clk
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() { done <- f.Start(ctx) }()
+		require.Eventually(t, clk.HasWaiters, time.Second, time.Millisecond)
+
+		//WHEN
+		second := newCA(t, "second")
+		writeBundle(t, config.CABundlePath, second)
+		clk.Step(time.Minute)
+
+		//THEN
+		require.Eventually(t, func() bool {
+			mutating, validating := caBundles(t, client)
+			return string(mutating) == string(second) && string(validating) == string(second)
+		}, time.Second, time.Millisecond)
+		cancel()
+		require.NoError(t, <-done)
+	})
+
+	t.Run("corrupt file at start error", func(t *testing.T) {
+		//GIVEN
+		config := newConfig(t)
+		writeBundle(t, config.CABundlePath, []byte("not a certificate"))
+
+		//WHEN
+		_, err := NewCABundleFile(newFakeClient(), config, time.Second, zap.NewNop().Sugar())
+
+		//THEN
+		require.ErrorContains(t, err, "invalid CA bundle file")
+	})
+
+	t.Run("missing file error", func(t *testing.T) {
+		//GIVEN
+		config := newConfig(t)
+
+		//WHEN
+		_, err := NewCABundleFile(newFakeClient(), config, time.Second, zap.NewNop().Sugar())
+
+		//THEN
+		require.ErrorContains(t, err, "failed to read CA bundle file "+config.CABundlePath)
+	})
+}
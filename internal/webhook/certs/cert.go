@@ -16,6 +16,7 @@ import (
 	apiErrors "k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
 	ctrl "sigs.k8s.io/controller-runtime"
 	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -24,9 +25,12 @@ const (
 	CertFile       = "server-cert.pem"
 	KeyFile        = "server-key.pem"
 	DefaultCertDir = "/tmp/k8s-webhook-server/serving-certs"
+	// DefaultClusterDomain qualifies the service names in the serving certificate.
+	DefaultClusterDomain = "cluster.local"
 )
 
-func SetupCertSecret(ctx context.Context, secretName, secretNamespace, serviceName string, logger *zap.SugaredLogger) error {
+// SetupCertSecret ensures the secret with the self-signed certificate valid for sans, see ServingCertificateSANs.
+func SetupCertSecret(ctx context.Context, secretName, secretNamespace string, sans []string, logger *zap.SugaredLogger) error {
 	// We are going to talk to the API server _before_ we start the manager.
 	// Since the default manager client reads from cache, we will get an error.
 	// So, we create a "serverClient" that would read from the API directly.
@@ -39,13 +43,15 @@ func SetupCertSecret(ctx context.Context, secretName, secretNamespace, serviceNa
 		return errors.Wrap(err, "while adding apiextensions.v1 schema to k8s client")
 	}
 
-	if err := EnsureWebhookSecret(ctx, serverClient, secretName, secretNamespace, serviceName, logger); err != nil {
+	if err := EnsureWebhookSecret(ctx, serverClient, secretName, secretNamespace, sans, logger); err != nil {
 		return errors.Wrap(err, "failed to ensure webhook secret")
 	}
 	return nil
 }
 
-func EnsureWebhookSecret(ctx context.Context, client ctrlclient.Client, secretName, secretNamespace, serviceName string, log *zap.SugaredLogger) error {
+// EnsureWebhookSecret creates the secret with a self-signed certificate valid for sans, it's regenerated when
+// it expires soon or the sans changed.
+func EnsureWebhookSecret(ctx context.Context, client ctrlclient.Client, secretName, secretNamespace string, sans []string, log *zap.SugaredLogger) error {
 	secret := &corev1.Secret{}
 	log.Info("ensuring webhook secret")
 	err := client.Get(ctx, types.NamespacedName{Name: secretName, Namespace: secretNamespace}, secret)
@@ -55,18 +61,18 @@ func EnsureWebhookSecret(ctx context.Context, client ctrlclient.Client, secretNa
 
 	if apiErrors.IsNotFound(err) {
 		log.Info("creating webhook secret")
-		return createSecret(ctx, client, secretName, secretNamespace, serviceName)
+		return createSecret(ctx, client, secretName, secretNamespace, sans)
 	}
 
 	log.Info("updating pre-exiting webhook secret")
-	if err := updateSecret(ctx, client, log, secret, serviceName); err != nil {
+	if err := updateSecret(ctx, client, log, secret, sans); err != nil {
 		return errors.Wrap(err, "failed to update secret")
 	}
 	return nil
 }
 
-func createSecret(ctx context.Context, client ctrlclient.Client, name, namespace, serviceName string) error {
-	secret, err := buildSecret(name, namespace, serviceName)
+func createSecret(ctx context.Context, client ctrlclient.Client, name, namespace string, sans []string) error {
+	secret, err := buildSecret(name, namespace, sans)
 	if err != nil {
 		return errors.Wrap(err, "failed to create secret object")
 	}
@@ -76,8 +82,8 @@ func createSecret(ctx context.Context, client ctrlclient.Client, name, namespace
 	return nil
 }
 
-func updateSecret(ctx context.Context, client ctrlclient.Client, log *zap.SugaredLogger, secret *corev1.Secret, serviceName string) error {
-	valid, err := isValidSecret(secret)
+func updateSecret(ctx context.Context, client ctrlclient.Client, log *zap.SugaredLogger, secret *corev1.Secret, sans []string) error {
+	valid, err := isValidSecret(secret, sans)
 	if valid {
 		return nil
 	}
@@ -85,7 +91,7 @@ func updateSecret(ctx context.Context, client ctrlclient.Client, log *zap.Sugare
 		log.Error(err, "invalid certificate")
 	}
 
-	newSecret, err := buildSecret(secret.Name, secret.Namespace, serviceName)
+	newSecret, err := buildSecret(secret.Name, secret.Namespace, sans)
 	if err != nil {
 		return errors.Wrap(err, "failed to create secret object")
 	}
@@ -97,11 +103,11 @@ func updateSecret(ctx context.Context, client ctrlclient.Client, log *zap.Sugare
 	return nil
 }
 
-func isValidSecret(s *corev1.Secret) (bool, error) {
+func isValidSecret(s *corev1.Secret, sans []string) (bool, error) {
 	if !hasRequiredKeys(s.Data) {
 		return false, nil
 	}
-	if err := verifyCertificate(s.Data[CertFile]); err != nil {
+	if err := verifyCertificate(s.Data[CertFile], sans); err != nil {
 		return false, err
 	}
 	if err := verifyKey(s.Data[KeyFile]); err != nil {
@@ -111,7 +117,7 @@ func isValidSecret(s *corev1.Secret) (bool, error) {
 	return true, nil
 }
 
-func verifyCertificate(c []byte) error {
+func verifyCertificate(c []byte, sans []string) error {
 	certificate, err := cert.ParseCertsPEM(c)
 	if err != nil {
 		return errors.Wrap(err, "failed to parse certificate data")
@@ -126,6 +132,9 @@ func verifyCertificate(c []byte) error {
 	if err != nil {
 		return errors.Wrap(err, "certificate verification failed")
 	}
+	if !hasAltNames(certificate[0], sans) {
+		return errors.Errorf("certificate SANs %v don't match the configured SANs %v", certificate[0].DNSNames, sans)
+	}
 	return nil
 }
 
@@ -153,8 +162,8 @@ func hasRequiredKeys(data map[string][]byte) bool {
 	return true
 }
 
-func buildSecret(name, namespace, serviceName string) (*corev1.Secret, error) {
-	cert, key, err := generateWebhookCertificates(serviceName, namespace)
+func buildSecret(name, namespace string, sans []string) (*corev1.Secret, error) {
+	cert, key, err := generateWebhookCertificates(sans)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to generate webhook certificates")
 	}
@@ -170,20 +179,39 @@ func buildSecret(name, namespace, serviceName string) (*corev1.Secret, error) {
 	}, nil
 }
 
-func generateWebhookCertificates(serviceName, namespace string) ([]byte, []byte, error) {
-	altNames := serviceAltNames(serviceName, namespace)
-	return cert.GenerateSelfSignedCertKey(altNames[0], nil, altNames)
+func generateWebhookCertificates(sans []string) ([]byte, []byte, error) {
+	return cert.GenerateSelfSignedCertKey(sans[0], nil, sans)
 }
 
-func serviceAltNames(serviceName, namespace string) []string {
+// serviceAltNames returns the DNS names of the service followed by extraSANs, the first one is the common name.
+func serviceAltNames(serviceName, namespace, clusterDomain string, extraSANs []string) []string {
+	if clusterDomain == "" {
+		clusterDomain = DefaultClusterDomain
+	}
 	namespacedServiceName := strings.Join([]string{serviceName, namespace}, ".")
 	commonName := strings.Join([]string{namespacedServiceName, "svc"}, ".")
-	serviceHostname := fmt.Sprintf("%s.%s.svc.cluster.local", serviceName, namespace)
+	serviceHostname := fmt.Sprintf("%s.%s.svc.%s", serviceName, namespace, clusterDomain)
 
-	return []string{
+	return appendAltNames([]string{
 		commonName,
 		serviceName,
 		namespacedServiceName,
 		serviceHostname,
+	}, extraSANs...)
+}
+
+// appendAltNames appends the names which aren't in altNames yet.
+func appendAltNames(altNames []string, names ...string) []string {
+	for _, name := range names {
+		if !sets.NewString(altNames...).Has(name) {
+			altNames = append(altNames, name)
+		}
 	}
+	return altNames
+}
+
+// hasAltNames is true when the certificate is valid exactly for the names, so it's regenerated also when
+// a name is removed from the configuration.
+func hasAltNames(c *x509.Certificate, names []string) bool {
+	return sets.NewString(c.DNSNames...).Equal(sets.NewString(names...))
 }
@@ -0,0 +1,123 @@
+package certs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/cert"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestWebhookConfig_ServingCertificateSANs(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   WebhookConfig
+		expected []string
+	}{
+		{
+			name:   "default cluster domain",
+			config: WebhookConfig{ServiceName: "warden-admission", ServiceNamespace: "kyma-system"},
+			expected: []string{
+				"warden-admission.kyma-system.svc",
+				"warden-admission",
+				"warden-admission.kyma-system",
+				"warden-admission.kyma-system.svc.cluster.local",
+			},
+		},
+		{
+			name: "custom cluster domain and extra SANs",
+			config: WebhookConfig{
+				ServiceName:      "warden-admission",
+				ServiceNamespace: "kyma-system",
+				ClusterDomain:    "cluster.example",
+				ExtraSANs:        []string{"warden-admission-headless.kyma-system.svc", "warden-admission.kyma-system.svc"},
+			},
+			expected: []string{
+				"warden-admission.kyma-system.svc",
+				"warden-admission",
+				"warden-admission.kyma-system",
+				"warden-admission.kyma-system.svc.cluster.example",
+				"warden-admission-headless.kyma-system.svc",
+			},
+		},
+		{
+			name:     "url",
+			config:   WebhookConfig{URL: "https://host.docker.internal:9443", ExtraSANs: []string{"localhost"}},
+			expected: []string{"host.docker.internal", "localhost"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, tt.config.ServingCertificateSANs())
+		})
+	}
+}
+
+func TestEnsureWebhookSecret(t *testing.T) {
+	key := types.NamespacedName{Name: "warden-admission-cert", Namespace: "kyma-system"}
+	config := WebhookConfig{ServiceName: "warden-admission", ServiceNamespace: "kyma-system"}
+	getSecret := func(t *testing.T, client ctrlclient.Client) *corev1.Secret {
+		secret := &corev1.Secret{}
+		require.NoError(t, client.Get(context.TODO(), key, secret))
+		return secret
+	}
+	ensure := func(client ctrlclient.Client, config WebhookConfig) error {
+		return EnsureWebhookSecret(context.TODO(), client, key.Name, key.Namespace, config.ServingCertificateSANs(), zap.NewNop().Sugar())
+	}
+
+	t.Run("creates the certificate for the SANs", func(t *testing.T) {
+		//GIVEN
+		client := fake.NewClientBuilder().Build()
+		custom := config
+		custom.ClusterDomain = "cluster.example"
+
+		//WHEN
+		err := ensure(client, custom)
+
+		//THEN
+		require.NoError(t, err)
+		certificates, err := cert.ParseCertsPEM(getSecret(t, client).Data[CertFile])
+		require.NoError(t, err)
+		require.Contains(t, certificates[0].DNSNames, "warden-admission.kyma-system.svc.cluster.example")
+		require.True(t, hasAltNames(certificates[0], custom.ServingCertificateSANs()))
+	})
+
+	t.Run("keeps the valid certificate", func(t *testing.T) {
+		//GIVEN
+		client := fake.NewClientBuilder().Build()
+		require.NoError(t, ensure(client, config))
+		before := getSecret(t, client)
+
+		//WHEN
+		err := ensure(client, config)
+
+		//THEN
+		require.NoError(t, err)
+		require.Equal(t, before.Data, getSecret(t, client).Data)
+	})
+
+	t.Run("regenerates the valid certificate when the SANs change", func(t *testing.T) {
+		//GIVEN
+		client := fake.NewClientBuilder().Build()
+		withExtra := config
+		withExtra.ExtraSANs = []string{"warden-admission-headless.kyma-system.svc"}
+		require.NoError(t, ensure(client, withExtra))
+		before := getSecret(t, client)
+
+		//WHEN
+		err := ensure(client, config)
+
+		//THEN
+		require.NoError(t, err)
+		after := getSecret(t, client)
+		require.NotEqual(t, before.Data[CertFile], after.Data[CertFile])
+		certificates, err := cert.ParseCertsPEM(after.Data[CertFile])
+		require.NoError(t, err)
+		require.NotContains(t, certificates[0].DNSNames, "warden-admission-headless.kyma-system.svc")
+	})
+}
@@ -0,0 +1,143 @@
+package certs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CertManagerInjectCAAnnotation makes the cert-manager CA injector set the CA bundle of the webhook configurations.
+const CertManagerInjectCAAnnotation = "cert-manager.io/inject-ca-from"
+
+// DefaultCertificateReloadInterval is how often the serving certificate is re-read from the cert-manager Secret.
+const DefaultCertificateReloadInterval = time.Minute
+
+// CertificateMode tells who issues the serving certificate of the webhooks.
+type CertificateMode string
+
+const (
+	// SelfManagedCertificates is the default, Warden generates the certificate and sets the CA bundle.
+	SelfManagedCertificates CertificateMode = "self-managed"
+	// CertManagerCertificates leaves the certificate and the CA bundle to cert-manager.
+	CertManagerCertificates CertificateMode = "cert-manager"
+)
+
+// CertManagerConfig points at the cert-manager Certificate of the webhook service and the Secret it's issued to.
+type CertManagerConfig struct {
+	// Certificate is "<namespace>/<name>", or the name of the Certificate in the service namespace.
+	Certificate string
+	// SecretName is the Secret in the service namespace cert-manager stores the certificate in.
+	SecretName string
+}
+
+// InjectCAFrom returns the value of CertManagerInjectCAAnnotation for the Certificate.
+func (c CertManagerConfig) InjectCAFrom(serviceNamespace string) string {
+	if strings.Contains(c.Certificate, "/") {
+		return c.Certificate
+	}
+	return serviceNamespace + "/" + c.Certificate
+}
+
+// ValidateCertificateMode rejects unknown modes, cert-manager settings in the self-managed mode
+// and the cert-manager mode without the Certificate or the Secret.
+func ValidateCertificateMode(mode CertificateMode, c CertManagerConfig) error {
+	switch mode {
+	case "", SelfManagedCertificates:
+		if c != (CertManagerConfig{}) {
+			return fmt.Errorf("cert-manager settings can be used only in the %s certificate mode", CertManagerCertificates)
+		}
+	case CertManagerCertificates:
+		if c.Certificate == "" {
+			return errors.New("cert-manager certificate mode requires the certificate")
+		}
+		if parts := strings.Split(c.Certificate, "/"); len(parts) > 2 || parts[0] == "" || parts[len(parts)-1] == "" {
+			return fmt.Errorf("cert-manager certificate %s must be <namespace>/<name> or <name>", c.Certificate)
+		}
+		if c.SecretName == "" {
+			return errors.New("cert-manager certificate mode requires the secret name")
+		}
+	default:
+		return fmt.Errorf("unknown certificate mode %s, supported modes are %s and %s",
+			mode, SelfManagedCertificates, CertManagerCertificates)
+	}
+	return nil
+}
+
+// SecretCertificateLoader serves the certificate of the Secret issued by cert-manager and re-reads it periodically,
+// so renewed certificates are served without restarting the webhook server.
+type SecretCertificateLoader struct {
+	servingCertificate
+	reader   ctrlclient.Reader
+	secret   types.NamespacedName
+	certKey  string
+	keyKey   string
+	interval time.Duration
+	log      *zap.SugaredLogger
+}
+
+func NewSecretCertificateLoader(reader ctrlclient.Reader, secret types.NamespacedName, interval time.Duration, log *zap.SugaredLogger) *SecretCertificateLoader {
+	if interval <= 0 {
+		interval = DefaultCertificateReloadInterval
+	}
+	return &SecretCertificateLoader{
+		reader:   reader,
+		secret:   secret,
+		certKey:  corev1.TLSCertKey,
+		keyKey:   corev1.TLSPrivateKeyKey,
+		interval: interval,
+		log:      log.Named("cert-loader"),
+	}
+}
+
+// NewSelfManagedCertificateLoader serves the certificate of the Secret created by Warden, or rotated
+// by another process, which stores it under CertFile and KeyFile.
+func NewSelfManagedCertificateLoader(reader ctrlclient.Reader, secret types.NamespacedName, interval time.Duration, log *zap.SugaredLogger) *SecretCertificateLoader {
+	loader := NewSecretCertificateLoader(reader, secret, interval, log)
+	loader.certKey, loader.keyKey = CertFile, KeyFile
+	return loader
+}
+
+// Load reads the certificate from the Secret and serves it when it changed.
+func (l *SecretCertificateLoader) Load(ctx context.Context) error {
+	secret := &corev1.Secret{}
+	if err := l.reader.Get(ctx, l.secret, secret); err != nil {
+		return errors.Wrapf(err, "failed to get certificate secret %s", l.secret)
+	}
+	loaded, err := l.load(secret.Data[l.certKey], secret.Data[l.keyKey])
+	if err != nil {
+		return errors.Wrapf(err, "invalid certificate secret %s", l.secret)
+	}
+	if loaded {
+		l.log.With("notAfter", l.current.Load().Leaf.NotAfter).Info("serving certificate loaded")
+	}
+	return nil
+}
+
+// Start keeps re-reading the certificate until the context is done, it implements manager.Runnable.
+func (l *SecretCertificateLoader) Start(ctx context.Context) error {
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			// the current certificate stays served, the next check retries
+			if err := l.Load(ctx); err != nil {
+				l.log.Error("failed to reload serving certificate ", err.Error())
+			}
+		}
+	}
+}
+
+// NeedLeaderElection is false, every replica has to serve the current certificate.
+func (l *SecretCertificateLoader) NeedLeaderElection() bool {
+	return false
+}
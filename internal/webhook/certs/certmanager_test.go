@@ -0,0 +1,240 @@
+package certs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	testingclock "k8s.io/utils/clock/testing"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestValidateCertificateMode(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        CertificateMode
+		certManager CertManagerConfig
+		expectedErr string
+	}{
+		{name: "default mode"},
+		{name: "self-managed", mode: SelfManagedCertificates},
+		{
+			name:        "cert-manager",
+			mode:        CertManagerCertificates,
+			certManager: CertManagerConfig{Certificate: "kyma-system/warden-admission", SecretName: "warden-admission-tls"},
+		},
+		{
+			name:        "cert-manager with the certificate name",
+			mode:        CertManagerCertificates,
+			certManager: CertManagerConfig{Certificate: "warden-admission", SecretName: "warden-admission-tls"},
+		},
+		{
+			name:        "cert-manager settings in the self-managed mode",
+			mode:        SelfManagedCertificates,
+			certManager: CertManagerConfig{Certificate: "warden-admission"},
+			expectedErr: "cert-manager settings can be used only in the cert-manager certificate mode",
+		},
+		{
+			name:        "cert-manager settings in the default mode",
+			certManager: CertManagerConfig{SecretName: "warden-admission-tls"},
+			expectedErr: "cert-manager settings can be used only in the cert-manager certificate mode",
+		},
+		{
+			name:        "cert-manager without the certificate",
+			mode:        CertManagerCertificates,
+			certManager: CertManagerConfig{SecretName: "warden-admission-tls"},
+			expectedErr: "cert-manager certificate mode requires the certificate",
+		},
+		{
+			name:        "cert-manager without the secret",
+			mode:        CertManagerCertificates,
+			certManager: CertManagerConfig{Certificate: "warden-admission"},
+			expectedErr: "cert-manager certificate mode requires the secret name",
+		},
+		{
+			name:        "malformed certificate",
+			mode:        CertManagerCertificates,
+			certManager: CertManagerConfig{Certificate: "kyma-system/", SecretName: "warden-admission-tls"},
+			expectedErr: "cert-manager certificate kyma-system/ must be <namespace>/<name> or <name>",
+		},
+		{
+			name:        "unknown mode",
+			mode:        "vault",
+			expectedErr: "unknown certificate mode vault, supported modes are self-managed and cert-manager",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCertificateMode(tt.mode, tt.certManager)
+
+			if tt.expectedErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestEnsureWebhookConfigurationFor_CertManager(t *testing.T) {
+	certManagerConfig := WebhookConfig{
+		ServiceName:            "warden-admission",
+		ServiceNamespace:       "kyma-system",
+		CABundel:               []byte("ignored"),
+		CertManagerCertificate: "kyma-system/warden-admission",
+	}
+	mutating := func(t *testing.T, client ctrlclient.Client) *admissionregistrationv1.MutatingWebhookConfiguration {
+		c := &admissionregistrationv1.MutatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: DefaultingWebhookName}, c))
+		return c
+	}
+	validating := func(t *testing.T, client ctrlclient.Client) *admissionregistrationv1.ValidatingWebhookConfiguration {
+		c := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, c))
+		return c
+	}
+
+	t.Run("creates configurations annotated for the CA injector", func(t *testing.T) {
+		//GIVEN
+		client := newFakeClient()
+
+		//WHEN
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, certManagerConfig, MutatingWebhook))
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, certManagerConfig, ValidatingWebHook))
+
+		//THEN
+		m := mutating(t, client)
+		require.Equal(t, "kyma-system/warden-admission", m.Annotations[CertManagerInjectCAAnnotation])
+		require.Nil(t, m.Webhooks[0].ClientConfig.CABundle)
+		v := validating(t, client)
+		require.Equal(t, "kyma-system/warden-admission", v.Annotations[CertManagerInjectCAAnnotation])
+		require.Nil(t, v.Webhooks[0].ClientConfig.CABundle)
+	})
+
+	t.Run("keeps the injected CA bundle", func(t *testing.T) {
+		//GIVEN
+		client := newFakeClient()
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, certManagerConfig, MutatingWebhook))
+		m := mutating(t, client)
+		m.Webhooks[0].ClientConfig.CABundle = []byte("injected")
+		require.NoError(t, client.Update(context.TODO(), m))
+		injected := mutating(t, client)
+
+		//WHEN
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, certManagerConfig, MutatingWebhook))
+
+		//THEN
+		m = mutating(t, client)
+		require.Equal(t, injected.ResourceVersion, m.ResourceVersion)
+		require.Equal(t, []byte("injected"), m.Webhooks[0].ClientConfig.CABundle)
+	})
+
+	t.Run("annotates the configuration of the self-managed mode", func(t *testing.T) {
+		//GIVEN
+		selfManaged := WebhookConfig{ServiceName: "warden-admission", ServiceNamespace: "kyma-system", CABundel: []byte("self-managed")}
+		client := newFakeClient()
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, selfManaged, ValidatingWebHook))
+
+		//WHEN
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, certManagerConfig, ValidatingWebHook))
+
+		//THEN
+		v := validating(t, client)
+		require.Equal(t, "kyma-system/warden-admission", v.Annotations[CertManagerInjectCAAnnotation])
+		// cert-manager replaces the bundle it doesn't know
+		require.Equal(t, []byte("self-managed"), v.Webhooks[0].ClientConfig.CABundle)
+	})
+
+	t.Run("self-managed mode removes the annotation", func(t *testing.T) {
+		//GIVEN
+		selfManaged := WebhookConfig{ServiceName: "warden-admission", ServiceNamespace: "kyma-system", CABundel: []byte("self-managed")}
+		client := newFakeClient()
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, certManagerConfig, MutatingWebhook))
+
+		//WHEN
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, selfManaged, MutatingWebhook))
+
+		//THEN
+		m := mutating(t, client)
+		require.NotContains(t, m.Annotations, CertManagerInjectCAAnnotation)
+		require.Equal(t, []byte("self-managed"), m.Webhooks[0].ClientConfig.CABundle)
+	})
+}
+
+func TestSecretCertificateLoader(t *testing.T) {
+	// cert-manager stores the certificate under the keys of kubernetes.io/tls secrets
+	issue := func(t *testing.T, at time.Time) map[string][]byte {
+		secret, err := buildCertificateSecret(CertificateOptions{
+			ServiceName:      "warden-admission",
+			ServiceNamespace: "kyma-system",
+			Clock:            testingclock.NewFakeClock(at),
+		}.withDefaults(), nil)
+		require.NoError(t, err)
+		return map[string][]byte{
+			corev1.TLSCertKey:       secret.Data[CertFile],
+			corev1.TLSPrivateKeyKey: secret.Data[KeyFile],
+			"ca.crt":                secret.Data[CACertFile],
+		}
+	}
+	key := types.NamespacedName{Name: "warden-admission-tls", Namespace: "kyma-system"}
+
+	t.Run("reloads the renewed certificate", func(t *testing.T) {
+		//GIVEN
+		secret := &corev1.Secret{
+			ObjectMeta: v1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+			Type:       corev1.SecretTypeTLS,
+			Data:       issue(t, time.Now()),
+		}
+		client := newFakeClient(secret)
+		l := NewSecretCertificateLoader(client, key, time.Minute, zap.NewNop().Sugar())
+		require.NoError(t, l.Load(context.TODO()))
+		before, err := l.GetCertificate(nil)
+		require.NoError(t, err)
+
+		//WHEN
+		require.NoError(t, l.Load(context.TODO()))
+		unchanged, _ := l.GetCertificate(nil)
+		secret.Data = issue(t, time.Now().Add(time.Hour))
+		require.NoError(t, client.Update(context.TODO(), secret))
+		require.NoError(t, l.Load(context.TODO()))
+
+		//THEN
+		require.Same(t, before, unchanged)
+		after, err := l.GetCertificate(nil)
+		require.NoError(t, err)
+		require.NotEqual(t, before.Leaf.SerialNumber, after.Leaf.SerialNumber)
+	})
+
+	t.Run("missing secret", func(t *testing.T) {
+		l := NewSecretCertificateLoader(newFakeClient(), key, 0, zap.NewNop().Sugar())
+
+		require.ErrorContains(t, l.Load(context.TODO()), "failed to get certificate secret kyma-system/warden-admission-tls")
+		_, err := l.GetCertificate(nil)
+		require.Error(t, err)
+	})
+
+	t.Run("secret without the certificate keeps the served one", func(t *testing.T) {
+		//GIVEN
+		secret := &corev1.Secret{ObjectMeta: v1.ObjectMeta{Name: key.Name, Namespace: key.Namespace}, Data: issue(t, time.Now())}
+		client := newFakeClient(secret)
+		l := NewSecretCertificateLoader(client, key, 0, zap.NewNop().Sugar())
+		require.NoError(t, l.Load(context.TODO()))
+		before, _ := l.GetCertificate(nil)
+
+		//WHEN
+		secret.Data = map[string][]byte{}
+		require.NoError(t, client.Update(context.TODO(), secret))
+		err := l.Load(context.TODO())
+
+		//THEN
+		require.ErrorContains(t, err, "invalid certificate secret kyma-system/warden-admission-tls")
+		after, _ := l.GetCertificate(nil)
+		require.Same(t, before, after)
+	})
+}
@@ -0,0 +1,51 @@
+package certs
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	ctlrclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DeleteWebhookConfigurations removes the webhook configurations of Warden, e.g. on uninstall, so the API server
+// doesn't call the removed service anymore. Configurations without the managed-by label of Warden are kept,
+// they belong to someone else. The names of the configurations have the name prefix of the config.
+func DeleteWebhookConfigurations(ctx context.Context, client ctlrclient.Client, config WebhookConfig) error {
+	var errs []error
+	for _, configuration := range []ctlrclient.Object{
+		&admissionregistrationv1.MutatingWebhookConfiguration{},
+		&admissionregistrationv1.ValidatingWebhookConfiguration{},
+	} {
+		name := config.webhookName(DefaultingWebhookName)
+		if _, ok := configuration.(*admissionregistrationv1.ValidatingWebhookConfiguration); ok {
+			name = config.webhookName(ValidationWebhookName)
+		}
+		if err := deleteWebhookConfiguration(ctx, client, name, configuration); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func deleteWebhookConfiguration(ctx context.Context, client ctlrclient.Client, name string, configuration ctlrclient.Object) error {
+	if err := client.Get(ctx, types.NamespacedName{Name: name}, configuration); err != nil {
+		if apiErrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "failed to get webhook configuration %s", name)
+	}
+	if configuration.GetLabels()[ManagedByLabel] != ManagedBy {
+		return nil
+	}
+	// the precondition keeps a configuration recreated by someone else in the meantime
+	uid := configuration.GetUID()
+	err := client.Delete(ctx, configuration, ctlrclient.Preconditions{UID: &uid})
+	if err != nil && !apiErrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete webhook configuration %s", name)
+	}
+	return nil
+}
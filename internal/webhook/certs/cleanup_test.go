@@ -0,0 +1,60 @@
+package certs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestDeleteWebhookConfigurations(t *testing.T) {
+	config := WebhookConfig{ServiceName: "warden-admission", ServiceNamespace: "kyma-system", CABundel: []byte("ca")}
+
+	t.Run("deletes the configurations of warden", func(t *testing.T) {
+		//GIVEN
+		client := newFakeClient()
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, MutatingWebhook))
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, ValidatingWebHook))
+
+		//WHEN
+		err := DeleteWebhookConfigurations(context.TODO(), client, config)
+
+		//THEN
+		require.NoError(t, err)
+		err = client.Get(context.TODO(), types.NamespacedName{Name: DefaultingWebhookName}, &admissionregistrationv1.MutatingWebhookConfiguration{})
+		require.True(t, apierrors.IsNotFound(err))
+		err = client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, &admissionregistrationv1.ValidatingWebhookConfiguration{})
+		require.True(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("absent configurations", func(t *testing.T) {
+		err := DeleteWebhookConfigurations(context.TODO(), newFakeClient(), config)
+
+		require.NoError(t, err)
+	})
+
+	t.Run("keeps configurations of others", func(t *testing.T) {
+		//GIVEN
+		foreign := &admissionregistrationv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   ValidationWebhookName,
+				Labels: map[string]string{ManagedByLabel: "Helm"},
+			},
+		}
+		client := newFakeClient(foreign)
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, MutatingWebhook))
+
+		//WHEN
+		err := DeleteWebhookConfigurations(context.TODO(), client, config)
+
+		//THEN
+		require.NoError(t, err)
+		err = client.Get(context.TODO(), types.NamespacedName{Name: DefaultingWebhookName}, &admissionregistrationv1.MutatingWebhookConfiguration{})
+		require.True(t, apierrors.IsNotFound(err))
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, &admissionregistrationv1.ValidatingWebhookConfiguration{}))
+	})
+}
@@ -1,7 +1,355 @@
 package certs
 
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/utils/pointer"
+)
+
 type WebhookConfig struct {
 	CABundel         []byte
 	ServiceName      string
 	ServiceNamespace string
+	// CertManagerCertificate is the "<namespace>/<name>" of the cert-manager Certificate whose CA cert-manager
+	// injects into the webhook configurations, CABundel isn't used then.
+	CertManagerCertificate string
+	// CABundleSecret is the Secret in the service namespace the CA bundle is read from when it's rotated,
+	// see SetupCABundleController. Ensured configurations keep the injected CA bundle then, CABundel is set
+	// only when they are created.
+	CABundleSecret string
+	// CABundlePath is the file the CA bundle is read from, e.g. a projected volume, instead of the certificate
+	// directory, see NewCABundleFile. Like with CABundleSecret, ensured configurations keep the injected CA bundle.
+	CABundlePath string
+	// Version of Warden recorded in the webhook configurations it applies.
+	Version string
+	// NamePrefix is prepended to the names of the webhook configurations and of their webhooks, so more
+	// installations of Warden in a cluster don't overwrite the configurations of each other.
+	NamePrefix string
+	// Port is the port of the service the API server calls the webhooks on, DefaultServicePort when it's nil.
+	Port *int32
+	// URL is the base URL of a webhook server outside the cluster, e.g. for local development, the paths
+	// of the webhooks are appended to it. The webhooks call the service when it's empty.
+	URL string
+	// ClusterDomain qualifies the service names in the self-managed serving certificate, DefaultClusterDomain
+	// when it's empty.
+	ClusterDomain string
+	// ExtraSANs are added to the DNS names of the self-managed serving certificate, e.g. other names
+	// of the service or of its headless variant.
+	ExtraSANs []string
+
+	// TimeoutSeconds, FailurePolicy and MatchPolicy replace the defaults of both webhooks when they are set,
+	// ReinvocationPolicy the one of the defaulting webhook. Configurations applied with another match policy,
+	// e.g. Exact of older versions, are updated to the configured one.
+	TimeoutSeconds     *int32
+	FailurePolicy      *admissionregistrationv1.FailurePolicyType
+	MatchPolicy        *admissionregistrationv1.MatchPolicyType
+	ReinvocationPolicy *admissionregistrationv1.ReinvocationPolicyType
+	// Scope of the pod rules, Namespaced when it's nil as pods are namespaced. Configurations applied with
+	// another scope, e.g. * of older versions, are updated to it.
+	Scope *admissionregistrationv1.ScopeType
+	// NamespaceSelector limits both webhooks to the selected namespaces, DefaultNamespaceSelector is used when
+	// it's nil and an empty selector selects all namespaces.
+	NamespaceSelector *metav1.LabelSelector
+	// ObjectSelector limits both webhooks to the selected pods, e.g. the ones with the validation label,
+	// so the API server doesn't call Warden for the others. All pods are selected when it's nil.
+	ObjectSelector *metav1.LabelSelector
+	// MutatingOperations and ValidatingOperations are the pod operations the webhooks are called for,
+	// CREATE and UPDATE when they are nil. Only the validating webhook supports DELETE.
+	MutatingOperations   []admissionregistrationv1.OperationType
+	ValidatingOperations []admissionregistrationv1.OperationType
+	// AdmissionReviewVersions the webhooks accept, see NegotiateAdmissionReviewVersions. The defaults are used
+	// when it's nil.
+	AdmissionReviewVersions []string
+	// SkipDryRun writes the webhook configurations without applying them in the dry-run mode first,
+	// for API servers which don't allow dry runs.
+	SkipDryRun bool
+	// SkipEphemeralContainers removes the pods/ephemeralcontainers rule of the pod validation webhook,
+	// e.g. for clusters which don't serve the subresource.
+	SkipEphemeralContainers bool
+	// ValidateWorkloads adds the webhook which validates the pod templates of deployments, statefulsets,
+	// daemonsets, jobs and cronjobs to the validation configuration.
+	ValidateWorkloads bool
+	// MatchConditions are added to the pod validation and defaulting webhooks on API servers which support them,
+	// see ForServer. The workload and namespace webhooks don't get them, as they test pod fields.
+	MatchConditions []MatchCondition
+}
+
+// DefaultNamespaceSelector excludes kube-system, kube-node-lease and the namespace of Warden, so the cluster
+// and Warden itself keep working when the webhooks are down.
+func DefaultNamespaceSelector(serviceNamespace string) *metav1.LabelSelector {
+	excluded := []string{metav1.NamespaceSystem, corev1.NamespaceNodeLease}
+	if serviceNamespace != "" && serviceNamespace != metav1.NamespaceSystem {
+		excluded = append(excluded, serviceNamespace)
+	}
+	return &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: corev1.LabelMetadataName, Operator: metav1.LabelSelectorOpNotIn, Values: excluded},
+		},
+	}
+}
+
+// Validate rejects overrides the API server doesn't accept.
+func (c WebhookConfig) Validate() error {
+	var errs []error
+	if c.Port != nil && (*c.Port < 1 || *c.Port > 65535) {
+		errs = append(errs, fmt.Errorf("webhook service port %d must be between 1 and 65535", *c.Port))
+	}
+	if c.URL != "" {
+		errs = append(errs, validateURL(c)...)
+	}
+	if c.NamePrefix != "" {
+		// the workload webhook has the longest name
+		for _, msg := range validation.IsDNS1123Subdomain(c.webhookName(WorkloadValidationWebhookName)) {
+			errs = append(errs, fmt.Errorf("invalid webhook name prefix %s: %s", c.NamePrefix, msg))
+		}
+	}
+	if c.CABundlePath != "" && (c.CABundleSecret != "" || c.CertManagerCertificate != "") {
+		errs = append(errs, fmt.Errorf("CA bundle path %s can't be used together with the CA bundle secret or cert-manager", c.CABundlePath))
+	}
+	if c.ClusterDomain != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(c.ClusterDomain) {
+			errs = append(errs, fmt.Errorf("invalid cluster domain %s: %s", c.ClusterDomain, msg))
+		}
+	}
+	for _, san := range c.ExtraSANs {
+		msgs := validation.IsDNS1123Subdomain(san)
+		if strings.HasPrefix(san, "*.") {
+			msgs = validation.IsWildcardDNS1123Subdomain(san)
+		}
+		for _, msg := range msgs {
+			errs = append(errs, fmt.Errorf("invalid extra SAN %s: %s", san, msg))
+		}
+	}
+	if c.TimeoutSeconds != nil && (*c.TimeoutSeconds < 1 || *c.TimeoutSeconds > 30) {
+		errs = append(errs, fmt.Errorf("webhook timeout %ds must be between 1 and 30 seconds", *c.TimeoutSeconds))
+	}
+	if c.FailurePolicy != nil {
+		switch *c.FailurePolicy {
+		case admissionregistrationv1.Ignore, admissionregistrationv1.Fail:
+		default:
+			errs = append(errs, fmt.Errorf("unsupported webhook failure policy %s, supported are %s and %s",
+				*c.FailurePolicy, admissionregistrationv1.Ignore, admissionregistrationv1.Fail))
+		}
+	}
+	if c.MatchPolicy != nil {
+		switch *c.MatchPolicy {
+		case admissionregistrationv1.Exact, admissionregistrationv1.Equivalent:
+		default:
+			errs = append(errs, fmt.Errorf("unsupported webhook match policy %s, supported are %s and %s",
+				*c.MatchPolicy, admissionregistrationv1.Exact, admissionregistrationv1.Equivalent))
+		}
+	}
+	if c.ReinvocationPolicy != nil {
+		switch *c.ReinvocationPolicy {
+		case admissionregistrationv1.NeverReinvocationPolicy, admissionregistrationv1.IfNeededReinvocationPolicy:
+		default:
+			errs = append(errs, fmt.Errorf("unsupported webhook reinvocation policy %s, supported are %s and %s",
+				*c.ReinvocationPolicy, admissionregistrationv1.NeverReinvocationPolicy, admissionregistrationv1.IfNeededReinvocationPolicy))
+		}
+	}
+	if c.Scope != nil {
+		switch *c.Scope {
+		case admissionregistrationv1.NamespacedScope, admissionregistrationv1.AllScopes:
+		default:
+			errs = append(errs, fmt.Errorf("unsupported webhook rule scope %s, supported are %s and %s",
+				*c.Scope, admissionregistrationv1.NamespacedScope, admissionregistrationv1.AllScopes))
+		}
+	}
+	if c.NamespaceSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(c.NamespaceSelector); err != nil {
+			errs = append(errs, fmt.Errorf("invalid webhook namespace selector: %w", err))
+		}
+	}
+	if c.ObjectSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(c.ObjectSelector); err != nil {
+			errs = append(errs, fmt.Errorf("invalid webhook object selector: %w", err))
+		}
+	}
+	errs = append(errs, validateOperations("defaulting", c.MutatingOperations, supportedMutatingOperations)...)
+	errs = append(errs, validateOperations("validation", c.ValidatingOperations, supportedValidatingOperations)...)
+	errs = append(errs, validateAdmissionReviewVersions(c.AdmissionReviewVersions)...)
+	errs = append(errs, validateMatchConditions(c.MatchConditions)...)
+	return utilerrors.NewAggregate(errs)
+}
+
+func validateURL(c WebhookConfig) []error {
+	var errs []error
+	if c.ServiceName != "" || c.Port != nil {
+		errs = append(errs, fmt.Errorf("webhook URL %s can't be used together with the service", c.URL))
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return append(errs, fmt.Errorf("invalid webhook URL: %w", err))
+	}
+	switch {
+	case u.Scheme != "https":
+		errs = append(errs, fmt.Errorf("webhook URL %s must use the https scheme", c.URL))
+	case u.Host == "":
+		errs = append(errs, fmt.Errorf("webhook URL %s must have the host", c.URL))
+	case u.User != nil || u.RawQuery != "" || u.Fragment != "":
+		errs = append(errs, fmt.Errorf("webhook URL %s can't have the user, query or fragment", c.URL))
+	}
+	return errs
+}
+
+// webhookName returns the name of the webhook configuration or webhook with the prefix of the installation.
+func (c WebhookConfig) webhookName(name string) string {
+	return c.NamePrefix + name
+}
+
+// injectsCABundle is true when the CA bundle is injected by a controller which follows its source,
+// so the ensures don't overwrite it with CABundel.
+func (c WebhookConfig) injectsCABundle() bool {
+	return c.CABundleSecret != "" || c.CABundlePath != ""
+}
+
+// ServingCertificateSANs returns the DNS names the self-managed serving certificate must be valid for,
+// the names of the service, or the host of the URL, and ExtraSANs.
+func (c WebhookConfig) ServingCertificateSANs() []string {
+	if c.URL != "" {
+		if u, err := url.Parse(c.URL); err == nil {
+			return appendAltNames([]string{u.Hostname()}, c.ExtraSANs...)
+		}
+	}
+	return serviceAltNames(c.ServiceName, c.ServiceNamespace, c.ClusterDomain, c.ExtraSANs)
+}
+
+// ServicePort returns the port the webhook configurations point at, so the service and the server
+// can be set up from the same config.
+func (c WebhookConfig) ServicePort() int32 {
+	if c.Port != nil {
+		return *c.Port
+	}
+	return DefaultServicePort
+}
+
+var (
+	defaultOperations = []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update}
+	// the defaulting webhook has nothing to default on DELETE
+	supportedMutatingOperations   = defaultOperations
+	supportedValidatingOperations = []admissionregistrationv1.OperationType{
+		admissionregistrationv1.Create, admissionregistrationv1.Update, admissionregistrationv1.Delete, admissionregistrationv1.OperationAll,
+	}
+)
+
+func validateOperations(webhook string, operations, supported []admissionregistrationv1.OperationType) []error {
+	if operations == nil {
+		return nil
+	}
+	if len(operations) == 0 {
+		return []error{fmt.Errorf("%s webhook must have at least one operation", webhook)}
+	}
+	var errs []error
+	seen := map[admissionregistrationv1.OperationType]bool{}
+	for _, operation := range operations {
+		switch {
+		case !containsOperation(supported, operation):
+			errs = append(errs, fmt.Errorf("unsupported %s webhook operation %s, supported are %v", webhook, operation, supported))
+		case seen[operation]:
+			errs = append(errs, fmt.Errorf("%s webhook operation %s is duplicated", webhook, operation))
+		}
+		seen[operation] = true
+	}
+	return errs
+}
+
+func containsOperation(operations []admissionregistrationv1.OperationType, operation admissionregistrationv1.OperationType) bool {
+	for _, o := range operations {
+		if o == operation {
+			return true
+		}
+	}
+	return false
+}
+
+func (c WebhookConfig) mutatingOperations() []admissionregistrationv1.OperationType {
+	if c.MutatingOperations != nil {
+		return append([]admissionregistrationv1.OperationType{}, c.MutatingOperations...)
+	}
+	return append([]admissionregistrationv1.OperationType{}, defaultOperations...)
+}
+
+func (c WebhookConfig) validatingOperations() []admissionregistrationv1.OperationType {
+	if c.ValidatingOperations != nil {
+		return append([]admissionregistrationv1.OperationType{}, c.ValidatingOperations...)
+	}
+	return append([]admissionregistrationv1.OperationType{}, defaultOperations...)
+}
+
+func (c WebhookConfig) timeoutSeconds() int32 {
+	if c.TimeoutSeconds != nil {
+		return *c.TimeoutSeconds
+	}
+	return WebhookTimeout
+}
+
+func (c WebhookConfig) failurePolicy() admissionregistrationv1.FailurePolicyType {
+	if c.FailurePolicy != nil {
+		return *c.FailurePolicy
+	}
+	return admissionregistrationv1.Ignore
+}
+
+func (c WebhookConfig) matchPolicy() admissionregistrationv1.MatchPolicyType {
+	if c.MatchPolicy != nil {
+		return *c.MatchPolicy
+	}
+	return DefaultMatchPolicy
+}
+
+// reinvocationPolicy is Never by default. IfNeeded calls the defaulting webhook again when later mutating
+// webhooks, e.g. sidecar injectors, changed the pod, so their containers are validated and labeled too.
+func (c WebhookConfig) reinvocationPolicy() admissionregistrationv1.ReinvocationPolicyType {
+	if c.ReinvocationPolicy != nil {
+		return *c.ReinvocationPolicy
+	}
+	return admissionregistrationv1.NeverReinvocationPolicy
+}
+
+func (c WebhookConfig) scope() admissionregistrationv1.ScopeType {
+	if c.Scope != nil {
+		return *c.Scope
+	}
+	return admissionregistrationv1.NamespacedScope
+}
+
+func (c WebhookConfig) namespaceSelector() *metav1.LabelSelector {
+	if c.NamespaceSelector != nil {
+		return c.NamespaceSelector.DeepCopy()
+	}
+	return DefaultNamespaceSelector(c.ServiceNamespace)
+}
+
+// objectSelector is empty instead of nil like the one defaulted by the API server, so they don't differ.
+func (c WebhookConfig) objectSelector() *metav1.LabelSelector {
+	if c.ObjectSelector != nil {
+		return c.ObjectSelector.DeepCopy()
+	}
+	return &metav1.LabelSelector{}
+}
+
+// clientConfig points the webhook at the path of the URL or of the service.
+func (c WebhookConfig) clientConfig(path string) admissionregistrationv1.WebhookClientConfig {
+	if c.URL != "" {
+		return admissionregistrationv1.WebhookClientConfig{
+			CABundle: webhookCABundle(c),
+			URL:      pointer.String(strings.TrimSuffix(c.URL, "/") + path),
+		}
+	}
+	return admissionregistrationv1.WebhookClientConfig{
+		CABundle: webhookCABundle(c),
+		Service: &admissionregistrationv1.ServiceReference{
+			Namespace: c.ServiceNamespace,
+			Name:      c.ServiceName,
+			Path:      pointer.String(path),
+			Port:      pointer.Int32(c.ServicePort()),
+		},
+	}
 }
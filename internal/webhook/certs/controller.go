@@ -7,31 +7,48 @@ import (
 	"time"
 
 	"go.uber.org/zap"
+	"golang.org/x/time/rate"
 
 	"github.com/pkg/errors"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
 	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/ratelimiter"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
-func SetupResourcesController(ctx context.Context, mgr ctrl.Manager, serviceName, serviceNamespace, secretName string, log *zap.SugaredLogger) error {
+// SetupResourcesController ensures the webhook configurations and keeps them, and the self-managed certificate
+// secret, up to date. The CA bundle is read from the certificate directory, or from CABundlePath which is then
+// watched for changes. In the cert-manager mode, i.e. when the config has CertManagerCertificate, the secret
+// is left to cert-manager and the CA bundle to its CA injector.
+// Besides the watch, the configurations are resynced every resyncInterval, the resync is a health check of mgr.
+func SetupResourcesController(ctx context.Context, mgr ctrl.Manager, webhookConfig WebhookConfig, secretName string, resyncInterval time.Duration, log *zap.SugaredLogger) error {
 	logger := log.Named("resource-ctrl")
-	certPath := path.Join(DefaultCertDir, CertFile)
-	certBytes, err := os.ReadFile(certPath)
-	if err != nil {
-		return errors.Wrapf(err, "failed to read caBundel file: %s", certPath)
-	}
-
-	webhookConfig := WebhookConfig{
-		CABundel:         certBytes,
-		ServiceName:      serviceName,
-		ServiceNamespace: serviceNamespace,
+	if webhookConfig.CABundlePath != "" {
+		caBundleFile, err := NewCABundleFile(mgr.GetClient(), webhookConfig, DefaultCABundleCheckInterval, log)
+		if err != nil {
+			return err
+		}
+		if err := mgr.Add(caBundleFile); err != nil {
+			return errors.Wrap(err, "failed to add CA bundle file watch")
+		}
+		webhookConfig.CABundel = caBundleFile.Bundle()
+	} else if webhookConfig.CertManagerCertificate == "" {
+		certPath := path.Join(DefaultCertDir, CertFile)
+		certBytes, err := os.ReadFile(certPath)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read caBundel file: %s", certPath)
+		}
+		webhookConfig.CABundel = certBytes
 	}
 	// We are going to talk to the API server _before_ we start the manager.
 	// Since the default manager client reads from cache, we will get an error.
@@ -42,32 +59,51 @@ func SetupResourcesController(ctx context.Context, mgr ctrl.Manager, serviceName
 		return errors.Wrap(err, "failed to create a server client")
 	}
 
-	logger.Info("initializing the defaulting webhook configuration")
-	if err := EnsureWebhookConfigurationFor(ctx, serverClient, webhookConfig, MutatingWebhook); err != nil {
-		return errors.Wrap(err, "failed to ensure defaulting webhook configuration")
+	logger.Info("initializing the webhook configurations")
+	mutating, validating, err := ensureAllWebhookConfigurations(ctx, serverClient, webhookConfig)
+	if err != nil {
+		return err
+	}
+	logger.With("defaulting", mutating, "validation", validating).Info("webhook configurations initialized")
+	if err := setupResourcesController(mgr, webhookConfig, secretName, log); err != nil {
+		return err
 	}
 
-	logger.Info("initializing the validation webhook configuration")
-	if err := EnsureWebhookConfigurationFor(ctx, serverClient, webhookConfig, ValidatingWebHook); err != nil {
-		return errors.Wrap(err, "failed to ensure validating webhook configuration")
+	resyncer := NewWebhookResyncer(mgr.GetClient(), webhookConfig, resyncInterval, log)
+	if err := mgr.Add(resyncer); err != nil {
+		return errors.Wrap(err, "failed to add webhook configurations resync")
 	}
-	// watch over the configuration
-	logger.Info("creating webhook resources controller")
+	// a liveness check would restart the replicas when the API server can't be written for a while,
+	// which would turn it into an outage of the webhooks
+	return errors.Wrap(mgr.AddReadyzCheck("webhook-resync", resyncer.Check), "failed to add webhook configurations resync check")
+}
+
+// setupResourcesController watches the webhook configurations and the certificate secret of Warden and repairs
+// them when they are deleted or changed.
+func setupResourcesController(mgr ctrl.Manager, webhookConfig WebhookConfig, secretName string, log *zap.SugaredLogger) error {
+	log.Named("resource-ctrl").Info("creating webhook resources controller")
 	c, err := controller.New("webhook-resources-controller", mgr, controller.Options{
 		Reconciler: &resourceReconciler{
 			webhookConfig: webhookConfig,
 			client:        mgr.GetClient(),
+			recorder:      mgr.GetEventRecorderFor("warden-admission"),
 			secretName:    secretName,
 			logger:        log.Named("webhook-resource-controller"),
 		},
+		RateLimiter: repairRateLimiter(),
 	})
 	if err != nil {
 		return errors.Wrap(err, "failed to create webhook-config-controller")
 	}
 
+	managedWebhooks := predicate.NewPredicateFuncs(func(object ctrlclient.Object) bool {
+		return object.GetName() == webhookConfig.webhookName(DefaultingWebhookName) ||
+			object.GetName() == webhookConfig.webhookName(ValidationWebhookName)
+	})
 	if err := c.Watch(&source.Kind{
 		Type: &admissionregistrationv1.ValidatingWebhookConfiguration{}},
 		&handler.EnqueueRequestForObject{},
+		managedWebhooks,
 	); err != nil {
 		return errors.Wrap(err, "failed to watch ValidatingWebhookConfiguration")
 	}
@@ -75,30 +111,44 @@ func SetupResourcesController(ctx context.Context, mgr ctrl.Manager, serviceName
 	if err := c.Watch(&source.Kind{
 		Type: &admissionregistrationv1.MutatingWebhookConfiguration{}},
 		&handler.EnqueueRequestForObject{},
+		managedWebhooks,
 	); err != nil {
 		return errors.Wrap(err, "failed to watch MutatingWebhookConfiguration")
 	}
 	if err := c.Watch(&source.Kind{
 		Type: &corev1.Secret{}},
 		&handler.EnqueueRequestForObject{},
+		predicate.NewPredicateFuncs(func(object ctrlclient.Object) bool {
+			return object.GetName() == secretName && object.GetNamespace() == webhookConfig.ServiceNamespace
+		}),
 	); err != nil {
 		return errors.Wrap(err, "failed to watch Secrets")
 	}
 	return nil
 }
 
+// repairRateLimiter backs off failing repairs and bounds the retries overall, so Warden doesn't flood
+// the API server when something else keeps changing the configurations.
+func repairRateLimiter() ratelimiter.RateLimiter {
+	return workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(time.Second, 5*time.Minute),
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(1), 10)},
+	)
+}
+
 type resourceReconciler struct {
 	webhookConfig WebhookConfig
 	secretName    string
 	client        ctrlclient.Client
+	recorder      record.EventRecorder
 	logger        *zap.SugaredLogger
 }
 
 func (r *resourceReconciler) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
 	// if the request is not one of our managed resources, we bail.
 	secretNamespaced := types.NamespacedName{Name: r.secretName, Namespace: r.webhookConfig.ServiceNamespace}
-	if request.Name != DefaultingWebhookName &&
-		request.Name != ValidationWebhookName &&
+	if request.Name != r.webhookConfig.webhookName(DefaultingWebhookName) &&
+		request.Name != r.webhookConfig.webhookName(ValidationWebhookName) &&
 		request.NamespacedName.String() != secretNamespaced.String() {
 		return reconcile.Result{}, nil
 	}
@@ -115,28 +165,45 @@ func (r *resourceReconciler) Reconcile(ctx context.Context, request reconcile.Re
 }
 
 func (r *resourceReconciler) reconcilerWebhooks(ctx context.Context, request reconcile.Request) error {
-	if request.Name == DefaultingWebhookName {
+	if request.Name == r.webhookConfig.webhookName(DefaultingWebhookName) {
 		r.logger.Info("reconciling webhook defaulting webhook configuration")
-		if err := EnsureWebhookConfigurationFor(ctx, r.client, r.webhookConfig, MutatingWebhook); err != nil {
+		result, err := ensureWebhookConfigurationFor(ctx, r.client, r.webhookConfig, MutatingWebhook)
+		if err != nil {
 			return errors.Wrap(err, "failed to ensure defaulting webhook configuration")
 		}
+		r.logger.With("result", result).Info("defaulting webhook configuration reconciled")
+		if result != EnsureUnchanged {
+			r.reportRepair(&admissionregistrationv1.MutatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{Name: request.Name}})
+		}
 	}
-	if request.Name == ValidationWebhookName {
+	if request.Name == r.webhookConfig.webhookName(ValidationWebhookName) {
 		r.logger.Info("reconciling webhook validating webhook configuration")
-		if err := EnsureWebhookConfigurationFor(ctx, r.client, r.webhookConfig, ValidatingWebHook); err != nil {
+		result, err := ensureWebhookConfigurationFor(ctx, r.client, r.webhookConfig, ValidatingWebHook)
+		if err != nil {
 			return errors.Wrap(err, "failed to ensure validating webhook configuration")
 		}
+		r.logger.With("result", result).Info("validating webhook configuration reconciled")
+		if result != EnsureUnchanged {
+			r.reportRepair(&admissionregistrationv1.ValidatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{Name: request.Name}})
+		}
 	}
 	return nil
 }
 
+func (r *resourceReconciler) reportRepair(object ctrlclient.Object) {
+	r.logger.With("name", object.GetName()).Warn("webhook configuration was deleted or changed, repaired it")
+	webhookConfigurationRepairs.WithLabelValues(object.GetName()).Inc()
+	r.recorder.Event(object, corev1.EventTypeWarning, "WebhookConfigurationRepaired",
+		"the webhook configuration was deleted or changed and Warden restored it")
+}
+
 func (r *resourceReconciler) reconcilerSecret(ctx context.Context, request reconcile.Request) error {
 	ctrl.LoggerFrom(ctx).Info("reconciling webhook secret")
 	secretNamespaced := types.NamespacedName{Name: r.secretName, Namespace: r.webhookConfig.ServiceNamespace}
-	if request.NamespacedName.String() != secretNamespaced.String() {
+	if request.NamespacedName.String() != secretNamespaced.String() || r.webhookConfig.CertManagerCertificate != "" {
 		return nil
 	}
-	if err := EnsureWebhookSecret(ctx, r.client, request.Name, request.Namespace, r.webhookConfig.ServiceName, r.logger); err != nil {
+	if err := EnsureWebhookSecret(ctx, r.client, request.Name, request.Namespace, r.webhookConfig.ServingCertificateSANs(), r.logger); err != nil {
 		return errors.Wrap(err, "failed to reconcile webhook secret")
 	}
 	return nil
@@ -0,0 +1,154 @@
+package certs
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestResourceReconciler_RepairsWebhookConfigurations(t *testing.T) {
+	config := WebhookConfig{ServiceName: "warden-admission", ServiceNamespace: "kyma-system", CABundel: []byte("ca")}
+	newReconciler := func(client ctrlclient.Client) (*resourceReconciler, *record.FakeRecorder) {
+		recorder := record.NewFakeRecorder(10)
+		return &resourceReconciler{
+			webhookConfig: config,
+			secretName:    "warden-webhook-cert",
+			client:        client,
+			recorder:      recorder,
+			logger:        zap.NewNop().Sugar(),
+		}, recorder
+	}
+	reconcileWebhook := func(t *testing.T, r *resourceReconciler, name string) {
+		_, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{Name: name}})
+		require.NoError(t, err)
+	}
+
+	t.Run("recreates the deleted configuration", func(t *testing.T) {
+		//GIVEN
+		client := newFakeClient()
+		r, recorder := newReconciler(client)
+		before := testutil.ToFloat64(webhookConfigurationRepairs.WithLabelValues(DefaultingWebhookName))
+
+		//WHEN
+		reconcileWebhook(t, r, DefaultingWebhookName)
+
+		//THEN
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: DefaultingWebhookName}, &admissionregistrationv1.MutatingWebhookConfiguration{}))
+		require.Equal(t, before+1, testutil.ToFloat64(webhookConfigurationRepairs.WithLabelValues(DefaultingWebhookName)))
+		require.Contains(t, <-recorder.Events, "WebhookConfigurationRepaired")
+	})
+
+	t.Run("reverts the changed timeout", func(t *testing.T) {
+		//GIVEN
+		client := newFakeClient()
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, ValidatingWebHook))
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, validating))
+		validating.Webhooks[0].TimeoutSeconds = pointer.Int32(1)
+		require.NoError(t, client.Update(context.TODO(), validating))
+		r, recorder := newReconciler(client)
+
+		//WHEN
+		reconcileWebhook(t, r, ValidationWebhookName)
+
+		//THEN
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, validating))
+		require.Equal(t, int32(WebhookTimeout), *validating.Webhooks[0].TimeoutSeconds)
+		require.Len(t, recorder.Events, 1)
+	})
+
+	t.Run("ignores changes of fields it doesn't manage", func(t *testing.T) {
+		//GIVEN
+		client := newFakeClient()
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, ValidatingWebHook))
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, validating))
+		validating.Annotations = map[string]string{"backup.example.com/policy": "daily"}
+		require.NoError(t, client.Update(context.TODO(), validating))
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, validating))
+		r, recorder := newReconciler(client)
+		before := testutil.ToFloat64(webhookConfigurationRepairs.WithLabelValues(ValidationWebhookName))
+
+		//WHEN
+		reconcileWebhook(t, r, ValidationWebhookName)
+
+		//THEN
+		after := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, after))
+		require.Equal(t, validating.ResourceVersion, after.ResourceVersion)
+		require.Equal(t, before, testutil.ToFloat64(webhookConfigurationRepairs.WithLabelValues(ValidationWebhookName)))
+		require.Empty(t, recorder.Events)
+	})
+}
+
+func TestResourcesController_RepairsDrift(t *testing.T) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		t.Skip("KUBEBUILDER_ASSETS isn't set, envtest can't start the API server")
+	}
+	testEnv := &envtest.Environment{}
+	cfg, err := testEnv.Start()
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, testEnv.Stop())
+	}()
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{Scheme: scheme.Scheme, MetricsBindAddress: "0"})
+	require.NoError(t, err)
+	config := WebhookConfig{ServiceName: "warden-admission", ServiceNamespace: "kyma-system", CABundel: []byte("ca")}
+	require.NoError(t, setupResourcesController(mgr, config, "warden-webhook-cert", zap.NewNop().Sugar()))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = mgr.Start(ctx)
+	}()
+	client, err := ctrlclient.New(cfg, ctrlclient.Options{Scheme: scheme.Scheme})
+	require.NoError(t, err)
+	require.NoError(t, EnsureWebhookConfigurationFor(ctx, client, config, MutatingWebhook))
+	require.NoError(t, EnsureWebhookConfigurationFor(ctx, client, config, ValidatingWebHook))
+
+	t.Run("deleted configuration is recreated", func(t *testing.T) {
+		//GIVEN
+		mutating := &admissionregistrationv1.MutatingWebhookConfiguration{}
+		require.NoError(t, client.Get(ctx, types.NamespacedName{Name: DefaultingWebhookName}, mutating))
+
+		//WHEN
+		require.NoError(t, client.Delete(ctx, mutating))
+
+		//THEN
+		require.Eventually(t, func() bool {
+			recreated := &admissionregistrationv1.MutatingWebhookConfiguration{}
+			err := client.Get(ctx, types.NamespacedName{Name: DefaultingWebhookName}, recreated)
+			return err == nil && recreated.UID != mutating.UID
+		}, 10*time.Second, 100*time.Millisecond)
+	})
+
+	t.Run("changed timeout is reverted", func(t *testing.T) {
+		//GIVEN
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(t, client.Get(ctx, types.NamespacedName{Name: ValidationWebhookName}, validating))
+
+		//WHEN
+		validating.Webhooks[0].TimeoutSeconds = pointer.Int32(1)
+		require.NoError(t, client.Update(ctx, validating))
+
+		//THEN
+		require.Eventually(t, func() bool {
+			reverted := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+			err := client.Get(ctx, types.NamespacedName{Name: ValidationWebhookName}, reverted)
+			return err == nil && *reverted.Webhooks[0].TimeoutSeconds == WebhookTimeout
+		}, 10*time.Second, 100*time.Millisecond)
+	})
+}
@@ -0,0 +1,101 @@
+package certs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// rejectingServer rejects the webhook configurations like the validation of the API server,
+// also in the dry-run mode, and counts the dry runs.
+type rejectingServer struct {
+	ctrlclient.Client
+	invalid field.ErrorList
+	dryRuns int
+}
+
+func (s *rejectingServer) Patch(ctx context.Context, obj ctrlclient.Object, patch ctrlclient.Patch, opts ...ctrlclient.PatchOption) error {
+	for _, opt := range opts {
+		if opt == ctrlclient.DryRunAll {
+			s.dryRuns++
+		}
+	}
+	if patch.Type() == types.ApplyPatchType && len(s.invalid) > 0 {
+		return apierrors.NewInvalid(admissionregistrationv1.SchemeGroupVersion.WithKind("ValidatingWebhookConfiguration").GroupKind(), obj.GetName(), s.invalid)
+	}
+	return s.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func TestEnsureWebhookConfigurationFor_DryRun(t *testing.T) {
+	config := WebhookConfig{ServiceName: "warden-admission", ServiceNamespace: "kyma-system", CABundel: []byte("ca")}
+	invalid := field.ErrorList{
+		field.Invalid(field.NewPath("webhooks").Index(0).Child("clientConfig", "service", "name"), "Warden_Admission", "a lowercase RFC 1123 label must consist of lower case alphanumeric characters or '-'"),
+		field.Required(field.NewPath("webhooks").Index(0).Child("sideEffects"), ""),
+	}
+
+	t.Run("rejected fields are reported", func(t *testing.T) {
+		//GIVEN
+		client := &rejectingServer{Client: newFakeClient(), invalid: invalid}
+
+		//WHEN
+		err := EnsureWebhookConfigurationFor(context.TODO(), client, config, ValidatingWebHook)
+
+		//THEN
+		require.EqualError(t, err, "while creating webhook validation configuration: "+
+			"webhook configuration validation.webhook.warden.kyma-project.io is rejected by the API server: "+
+			"webhooks[0].clientConfig.service.name: Invalid value: \"Warden_Admission\": a lowercase RFC 1123 label must consist of lower case alphanumeric characters or '-', "+
+			"webhooks[0].sideEffects: Required value")
+		require.Equal(t, 1, client.dryRuns)
+		list := &admissionregistrationv1.ValidatingWebhookConfigurationList{}
+		require.NoError(t, client.List(context.TODO(), list))
+		require.Empty(t, list.Items)
+	})
+
+	t.Run("dry run before the write", func(t *testing.T) {
+		//GIVEN
+		client := &rejectingServer{Client: newFakeClient()}
+
+		//WHEN
+		err := EnsureWebhookConfigurationFor(context.TODO(), client, config, ValidatingWebHook)
+
+		//THEN
+		require.NoError(t, err)
+		require.Equal(t, 1, client.dryRuns)
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, validating))
+	})
+
+	t.Run("dry run can be skipped", func(t *testing.T) {
+		//GIVEN
+		client := &rejectingServer{Client: newFakeClient()}
+		skipping := config
+		skipping.SkipDryRun = true
+
+		//WHEN
+		err := EnsureWebhookConfigurationFor(context.TODO(), client, skipping, MutatingWebhook)
+
+		//THEN
+		require.NoError(t, err)
+		require.Zero(t, client.dryRuns)
+	})
+
+	t.Run("other dry-run errors are wrapped", func(t *testing.T) {
+		//GIVEN
+		client := &concurrentWriter{Client: newFakeClient(), failures: 1, race: func(_ context.Context, obj ctrlclient.Object) error {
+			return apierrors.NewBadRequest("dry run is not supported")
+		}}
+
+		//WHEN
+		err := EnsureWebhookConfigurationFor(context.TODO(), client, config, MutatingWebhook)
+
+		//THEN
+		require.EqualError(t, err, "while creating webhook mutation configuration: "+
+			"while applying defaulting.webhook.warden.kyma-project.io in the dry-run mode: dry run is not supported")
+	})
+}
@@ -0,0 +1,72 @@
+package certs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	ctlrclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// EnsureAllWebhookConfigurations ensures the defaulting and then the validation webhook configuration.
+// When the validation configuration fails, the defaulting one is rolled back, so pods aren't labeled
+// without anything validating them.
+func EnsureAllWebhookConfigurations(ctx context.Context, client ctlrclient.Client, config WebhookConfig) error {
+	_, _, err := ensureAllWebhookConfigurations(ctx, client, config)
+	return err
+}
+
+// ensureAllWebhookConfigurations returns the results of the defaulting and the validation configuration.
+func ensureAllWebhookConfigurations(ctx context.Context, client ctlrclient.Client, config WebhookConfig) (EnsureResult, EnsureResult, error) {
+	gvk := admissionregistrationv1.SchemeGroupVersion.WithKind("MutatingWebhookConfiguration")
+	name := config.webhookName(DefaultingWebhookName)
+	// the previous configuration is kept unstructured, so the rollback restores also fields the API types lack
+	previous := &unstructured.Unstructured{}
+	previous.SetGroupVersionKind(gvk)
+	if err := client.Get(ctx, types.NamespacedName{Name: name}, previous); err != nil {
+		if !apiErrors.IsNotFound(err) {
+			return EnsureUnchanged, EnsureUnchanged, errors.Wrapf(err, "failed to get defaulting MutatingWebhookConfiguration: %s", name)
+		}
+		previous = nil
+	}
+
+	mutating, err := ensureWebhookConfigurationFor(ctx, client, config, MutatingWebhook)
+	if err != nil {
+		return mutating, EnsureUnchanged, errors.Wrap(err, "failed to ensure defaulting webhook configuration")
+	}
+	validating, err := ensureWebhookConfigurationFor(ctx, client, config, ValidatingWebHook)
+	if err == nil {
+		return mutating, validating, nil
+	}
+
+	err = errors.Wrap(err, "failed to ensure validating webhook configuration")
+	if mutating == EnsureUnchanged {
+		return mutating, validating, err
+	}
+	if rollbackErr := rollbackMutatingWebhookConfiguration(ctx, client, name, previous); rollbackErr != nil {
+		return mutating, validating, fmt.Errorf("%s, the defaulting webhook configuration was %s but rolling it back failed: %s",
+			err.Error(), mutating, rollbackErr.Error())
+	}
+	return mutating, validating, errors.Wrapf(err, "the %s defaulting webhook configuration was rolled back", mutating)
+}
+
+// rollbackMutatingWebhookConfiguration deletes the created configuration or restores the previous one.
+func rollbackMutatingWebhookConfiguration(ctx context.Context, client ctlrclient.Client, name string, previous *unstructured.Unstructured) error {
+	current := &admissionregistrationv1.MutatingWebhookConfiguration{}
+	if err := client.Get(ctx, types.NamespacedName{Name: name}, current); err != nil {
+		return errors.Wrapf(err, "failed to get webhook configuration %s", name)
+	}
+	if previous == nil {
+		uid := current.GetUID()
+		return errors.Wrapf(client.Delete(ctx, current, ctlrclient.Preconditions{UID: &uid}),
+			"failed to delete webhook configuration %s", name)
+	}
+	restored := previous.DeepCopy()
+	restored.SetResourceVersion(current.GetResourceVersion())
+	return errors.Wrapf(client.Update(ctx, restored, ctlrclient.FieldOwner(FieldManager)),
+		"failed to restore webhook configuration %s", name)
+}
@@ -0,0 +1,109 @@
+package certs
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// validatingFailure fails the writes of validating webhook configurations and optionally the deletes.
+type validatingFailure struct {
+	ctrlclient.Client
+	failDelete bool
+}
+
+func (c *validatingFailure) Patch(ctx context.Context, obj ctrlclient.Object, patch ctrlclient.Patch, opts ...ctrlclient.PatchOption) error {
+	if _, ok := obj.(*admissionregistrationv1.ValidatingWebhookConfiguration); ok {
+		return errors.New("admission webhook quota exceeded")
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c *validatingFailure) Delete(ctx context.Context, obj ctrlclient.Object, opts ...ctrlclient.DeleteOption) error {
+	if c.failDelete {
+		return errors.New("connection refused")
+	}
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func TestEnsureAllWebhookConfigurations(t *testing.T) {
+	config := WebhookConfig{ServiceName: "warden-admission", ServiceNamespace: "kyma-system", CABundel: []byte("ca")}
+
+	t.Run("ensures both configurations", func(t *testing.T) {
+		//GIVEN
+		client := newFakeClient()
+
+		//WHEN
+		mutating, validating, err := ensureAllWebhookConfigurations(context.TODO(), client, config)
+
+		//THEN
+		require.NoError(t, err)
+		require.Equal(t, EnsureCreated, mutating)
+		require.Equal(t, EnsureCreated, validating)
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: DefaultingWebhookName}, &admissionregistrationv1.MutatingWebhookConfiguration{}))
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, &admissionregistrationv1.ValidatingWebhookConfiguration{}))
+	})
+
+	t.Run("created defaulting configuration is deleted", func(t *testing.T) {
+		//GIVEN
+		client := &validatingFailure{Client: newFakeClient()}
+
+		//WHEN
+		err := EnsureAllWebhookConfigurations(context.TODO(), client, config)
+
+		//THEN
+		require.ErrorContains(t, err, "the created defaulting webhook configuration was rolled back: failed to ensure validating webhook configuration")
+		require.ErrorContains(t, err, "admission webhook quota exceeded")
+		err = client.Get(context.TODO(), types.NamespacedName{Name: DefaultingWebhookName}, &admissionregistrationv1.MutatingWebhookConfiguration{})
+		require.True(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("updated defaulting configuration is restored", func(t *testing.T) {
+		//GIVEN
+		previous := createMutatingWebhookConfiguration(config)
+		previous.Webhooks[0].TimeoutSeconds = pointer.Int32(5)
+		client := &validatingFailure{Client: newFakeClient(previous)}
+
+		//WHEN
+		err := EnsureAllWebhookConfigurations(context.TODO(), client, config)
+
+		//THEN
+		require.ErrorContains(t, err, "the updated defaulting webhook configuration was rolled back")
+		mutating := &admissionregistrationv1.MutatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: DefaultingWebhookName}, mutating))
+		require.Equal(t, int32(5), *mutating.Webhooks[0].TimeoutSeconds)
+	})
+
+	t.Run("unchanged defaulting configuration is kept", func(t *testing.T) {
+		//GIVEN
+		client := &validatingFailure{Client: newFakeClient()}
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, MutatingWebhook))
+
+		//WHEN
+		err := EnsureAllWebhookConfigurations(context.TODO(), client, config)
+
+		//THEN
+		require.EqualError(t, err, "failed to ensure validating webhook configuration: while creating webhook validation configuration: "+
+			"while applying validation.webhook.warden.kyma-project.io in the dry-run mode: admission webhook quota exceeded")
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: DefaultingWebhookName}, &admissionregistrationv1.MutatingWebhookConfiguration{}))
+	})
+
+	t.Run("failed rollback is reported", func(t *testing.T) {
+		//GIVEN
+		client := &validatingFailure{Client: newFakeClient(), failDelete: true}
+
+		//WHEN
+		err := EnsureAllWebhookConfigurations(context.TODO(), client, config)
+
+		//THEN
+		require.ErrorContains(t, err, "the defaulting webhook configuration was created but rolling it back failed: "+
+			"failed to delete webhook configuration defaulting.webhook.warden.kyma-project.io: connection refused")
+	})
+}
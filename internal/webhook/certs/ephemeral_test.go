@@ -0,0 +1,56 @@
+package certs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestEnsureWebhookConfigurationFor_EphemeralContainers(t *testing.T) {
+	base := WebhookConfig{ServiceName: "warden-admission", ServiceNamespace: "kyma-system", CABundel: []byte("ca")}
+	ensure := func(t *testing.T, client ctrlclient.Client, config WebhookConfig) admissionregistrationv1.ValidatingWebhook {
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, ValidatingWebHook))
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, validating))
+		return validating.Webhooks[0]
+	}
+
+	t.Run("ephemeral containers rule by default", func(t *testing.T) {
+		pods := ensure(t, newFakeClient(), base)
+
+		require.Len(t, pods.Rules, 2)
+		require.Equal(t, []string{"pods"}, pods.Rules[0].Resources)
+		require.Equal(t, []string{"pods/ephemeralcontainers"}, pods.Rules[1].Resources)
+		require.Equal(t, []string{""}, pods.Rules[1].APIGroups)
+		require.Equal(t, []string{"v1"}, pods.Rules[1].APIVersions)
+		require.Equal(t, []admissionregistrationv1.OperationType{admissionregistrationv1.Update}, pods.Rules[1].Operations)
+	})
+
+	t.Run("skipped on old clusters", func(t *testing.T) {
+		config := base
+		config.SkipEphemeralContainers = true
+
+		pods := ensure(t, newFakeClient(), config)
+
+		require.Len(t, pods.Rules, 1)
+		require.Equal(t, []string{"pods"}, pods.Rules[0].Resources)
+	})
+
+	t.Run("skipping removes the rule", func(t *testing.T) {
+		//GIVEN
+		client := newFakeClient()
+		ensure(t, client, base)
+		config := base
+		config.SkipEphemeralContainers = true
+
+		//WHEN
+		pods := ensure(t, client, config)
+
+		//THEN
+		require.Len(t, pods.Rules, 1)
+	})
+}
@@ -0,0 +1,136 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"k8s.io/client-go/util/cert"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// DefaultCertificateFileDebounce is how long the certificate files have to stay unchanged before they are reloaded,
+// so the certificate and the key written one after the other are loaded together.
+const DefaultCertificateFileDebounce = time.Second
+
+// ServingCertificateLoader serves the certificate of the webhook server and reloads it until it's stopped.
+type ServingCertificateLoader interface {
+	CertificateReloader
+	manager.Runnable
+	TLSOpt(config *tls.Config)
+}
+
+// FileCertificateLoader serves the certificate of the certificate and key files, e.g. of a mounted Secret,
+// and reloads it when the files change. A pair which doesn't load or is expired is rejected, the served
+// certificate stays.
+type FileCertificateLoader struct {
+	servingCertificate
+	certPath string
+	keyPath  string
+	debounce time.Duration
+	log      *zap.SugaredLogger
+}
+
+func NewFileCertificateLoader(certDir, certName, keyName string, debounce time.Duration, log *zap.SugaredLogger) *FileCertificateLoader {
+	if debounce <= 0 {
+		debounce = DefaultCertificateFileDebounce
+	}
+	return &FileCertificateLoader{
+		certPath: filepath.Join(certDir, certName),
+		keyPath:  filepath.Join(certDir, keyName),
+		debounce: debounce,
+		log:      log.Named("cert-file-loader"),
+	}
+}
+
+// Load reads the certificate files and serves the certificate when it changed.
+func (l *FileCertificateLoader) Load(_ context.Context) error {
+	certPEM, err := os.ReadFile(l.certPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read serving certificate file %s", l.certPath)
+	}
+	keyPEM, err := os.ReadFile(l.keyPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read serving key file %s", l.keyPath)
+	}
+	// an expired certificate would fail every admission, the served one may still be valid
+	if certificates, err := cert.ParseCertsPEM(certPEM); err == nil && time.Now().After(certificates[0].NotAfter) {
+		return errors.Errorf("serving certificate file %s expired at %s", l.certPath, certificates[0].NotAfter)
+	}
+	loaded, err := l.load(certPEM, keyPEM)
+	if err != nil {
+		return errors.Wrapf(err, "invalid serving certificate files %s and %s", l.certPath, l.keyPath)
+	}
+	if loaded {
+		l.log.With("notAfter", l.current.Load().Leaf.NotAfter).Info("serving certificate loaded")
+	}
+	return nil
+}
+
+// Start reloads the certificate when the files change until the context is done, it implements manager.Runnable.
+// The directories are watched instead of the files, as Secret volumes replace the files by swapping a symlink.
+func (l *FileCertificateLoader) Start(ctx context.Context) error {
+	watcher, err := l.watch()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	// the files may have changed before they were watched
+	if err := l.Load(ctx); err != nil {
+		l.log.Error("failed to reload serving certificate ", err.Error())
+	}
+	return l.reloadOnChange(ctx, watcher)
+}
+
+func (l *FileCertificateLoader) watch() (*fsnotify.Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to watch serving certificate files")
+	}
+	for _, dir := range []string{filepath.Dir(l.certPath), filepath.Dir(l.keyPath)} {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return nil, errors.Wrapf(err, "failed to watch serving certificate directory %s", dir)
+		}
+	}
+	return watcher, nil
+}
+
+// reloadOnChange loads the files when no change came for the debounce time, until the context is done.
+func (l *FileCertificateLoader) reloadOnChange(ctx context.Context, watcher *fsnotify.Watcher) error {
+	debounce := time.NewTimer(l.debounce)
+	debounce.Stop()
+	defer debounce.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case _, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			// every change restarts the wait, the files are loaded once they settled
+			debounce.Reset(l.debounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			l.log.Error("failed to watch serving certificate files ", err.Error())
+		case <-debounce.C:
+			// the current certificate stays served, the next change retries
+			if err := l.Load(ctx); err != nil {
+				l.log.Error("failed to reload serving certificate ", err.Error())
+			}
+		}
+	}
+}
+
+// NeedLeaderElection is false, every replica has to serve the current certificate.
+func (l *FileCertificateLoader) NeedLeaderElection() bool {
+	return false
+}
@@ -0,0 +1,151 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"k8s.io/client-go/util/cert"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestFileCertificateLoader(t *testing.T) {
+	const host = "warden-admission.kyma-system.svc"
+	roots := x509.NewCertPool()
+	// issue returns the serving certificate and key signed by a new CA, which is trusted by the clients
+	issue := func(t *testing.T, at time.Time) ([]byte, []byte) {
+		secret, err := buildCertificateSecret(CertificateOptions{
+			ServiceName:      "warden-admission",
+			ServiceNamespace: "kyma-system",
+			Clock:            testingclock.NewFakeClock(at),
+		}.withDefaults(), nil)
+		require.NoError(t, err)
+		require.True(t, roots.AppendCertsFromPEM(secret.Data[CACertFile]))
+		return secret.Data[CertFile], secret.Data[KeyFile]
+	}
+	serialOf := func(t *testing.T, certPEM []byte) *big.Int {
+		certificates, err := cert.ParseCertsPEM(certPEM)
+		require.NoError(t, err)
+		return certificates[0].SerialNumber
+	}
+	writeFile := func(t *testing.T, dir, name string, content []byte) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), content, 0o600))
+	}
+
+	t.Run("new connections get the rotated certificate", func(t *testing.T) {
+		//GIVEN
+		dir := t.TempDir()
+		firstCert, firstKey := issue(t, time.Now())
+		writeFile(t, dir, CertFile, firstCert)
+		writeFile(t, dir, KeyFile, firstKey)
+		l := NewFileCertificateLoader(dir, CertFile, KeyFile, 10*time.Millisecond, zap.NewNop().Sugar())
+		require.NoError(t, l.Load(context.TODO()))
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+		server.TLS = &tls.Config{}
+		l.TLSOpt(server.TLS)
+		server.StartTLS()
+		defer server.Close()
+		servedSerial := func() *big.Int {
+			conn, err := tls.Dial("tcp", server.Listener.Addr().String(), &tls.Config{RootCAs: roots, ServerName: host})
+			if err != nil {
+				return nil
+			}
+			defer conn.Close()
+			return conn.ConnectionState().PeerCertificates[0].SerialNumber
+		}
+		require.Equal(t, serialOf(t, firstCert), servedSerial())
+		watcher, err := l.watch()
+		require.NoError(t, err)
+		defer watcher.Close()
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() { done <- l.reloadOnChange(ctx, watcher) }()
+
+		//WHEN
+		secondCert, secondKey := issue(t, time.Now().Add(-time.Hour))
+		// the certificate doesn't match the old key until the key is written too
+		writeFile(t, dir, CertFile, secondCert)
+		writeFile(t, dir, KeyFile, secondKey)
+
+		//THEN
+		require.Eventually(t, func() bool {
+			serial := servedSerial()
+			return serial != nil && serial.Cmp(serialOf(t, secondCert)) == 0
+		}, 5*time.Second, 10*time.Millisecond)
+		served, err := l.GetCertificate(nil)
+		require.NoError(t, err)
+		require.Equal(t, float64(served.Leaf.NotAfter.Unix()), testutil.ToFloat64(servingCertificateNotAfter))
+		cancel()
+		require.NoError(t, <-done)
+	})
+
+	t.Run("invalid files keep the served certificate", func(t *testing.T) {
+		//GIVEN
+		dir := t.TempDir()
+		certPEM, keyPEM := issue(t, time.Now())
+		writeFile(t, dir, CertFile, certPEM)
+		writeFile(t, dir, KeyFile, keyPEM)
+		l := NewFileCertificateLoader(dir, CertFile, KeyFile, 0, zap.NewNop().Sugar())
+		require.NoError(t, l.Load(context.TODO()))
+		before, _ := l.GetCertificate(nil)
+		otherCert, _ := issue(t, time.Now())
+		expiredCert, expiredKey := issue(t, time.Now().Add(-2*DefaultCertificateValidity))
+
+		//WHEN
+		writeFile(t, dir, CertFile, otherCert)
+		mismatchErr := l.Load(context.TODO())
+		writeFile(t, dir, CertFile, []byte("-----BEGIN CERTIFICATE-----\ntruncated"))
+		garbageErr := l.Load(context.TODO())
+		writeFile(t, dir, CertFile, expiredCert)
+		writeFile(t, dir, KeyFile, expiredKey)
+		expiredErr := l.Load(context.TODO())
+
+		//THEN
+		require.ErrorContains(t, mismatchErr, "invalid serving certificate files")
+		require.ErrorContains(t, garbageErr, "invalid serving certificate files")
+		require.ErrorContains(t, expiredErr, "serving certificate file "+filepath.Join(dir, CertFile)+" expired at")
+		after, _ := l.GetCertificate(nil)
+		require.Same(t, before, after)
+	})
+
+	t.Run("missing files error", func(t *testing.T) {
+		dir := t.TempDir()
+		l := NewFileCertificateLoader(dir, CertFile, KeyFile, 0, zap.NewNop().Sugar())
+
+		require.ErrorContains(t, l.Load(context.TODO()), "failed to read serving certificate file "+filepath.Join(dir, CertFile))
+		_, err := l.GetCertificate(nil)
+		require.Error(t, err)
+	})
+
+	t.Run("stops when the context is done", func(t *testing.T) {
+		//GIVEN
+		dir := t.TempDir()
+		certPEM, keyPEM := issue(t, time.Now())
+		writeFile(t, dir, CertFile, certPEM)
+		writeFile(t, dir, KeyFile, keyPEM)
+		l := NewFileCertificateLoader(dir, CertFile, KeyFile, 0, zap.NewNop().Sugar())
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() { done <- l.Start(ctx) }()
+
+		//WHEN
+		require.Eventually(t, func() bool {
+			_, err := l.GetCertificate(nil)
+			return err == nil
+		}, time.Second, time.Millisecond)
+		cancel()
+
+		//THEN
+		require.NoError(t, <-done)
+	})
+}
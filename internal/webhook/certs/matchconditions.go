@@ -0,0 +1,149 @@
+package certs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/client-go/discovery"
+	ctlrclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// maxMatchConditions is the limit of the API server for a webhook.
+const maxMatchConditions = 64
+
+// matchConditionsMinVersion is the first version which enables match conditions by default,
+// 1.27 has them only behind the AdmissionWebhookMatchConditions feature gate.
+var matchConditionsMinVersion = version.MustParseGeneric("1.28")
+
+// MatchCondition is the CEL expression the API server evaluates before calling the webhook, the request is sent
+// only when all conditions are true. It mirrors admissionregistration.k8s.io/v1 MatchCondition which the API types
+// used by Warden predate, so the conditions are patched into the webhook configurations.
+type MatchCondition struct {
+	Name       string `json:"name"`
+	Expression string `json:"expression"`
+}
+
+func validateMatchConditions(conditions []MatchCondition) []error {
+	var errs []error
+	if len(conditions) > maxMatchConditions {
+		errs = append(errs, fmt.Errorf("webhook has %d match conditions, at most %d are allowed", len(conditions), maxMatchConditions))
+	}
+	names := map[string]bool{}
+	for _, c := range conditions {
+		switch {
+		case c.Name == "":
+			errs = append(errs, errors.New("webhook match condition must have the name"))
+		case names[c.Name]:
+			errs = append(errs, fmt.Errorf("webhook match condition %s is duplicated", c.Name))
+		case c.Expression == "":
+			errs = append(errs, fmt.Errorf("webhook match condition %s must have the expression", c.Name))
+		}
+		names[c.Name] = true
+	}
+	return errs
+}
+
+// ForServer returns the config without the match conditions when the API server doesn't support them,
+// so older clusters don't reject the webhook configurations. The conditions are dropped also when the version
+// can't be discovered, the error tells why.
+func (c WebhookConfig) ForServer(d discovery.ServerVersionInterface) (WebhookConfig, error) {
+	if len(c.MatchConditions) == 0 {
+		return c, nil
+	}
+	supported, err := matchConditionsSupported(d)
+	if !supported {
+		c.MatchConditions = nil
+	}
+	return c, err
+}
+
+func matchConditionsSupported(d discovery.ServerVersionInterface) (bool, error) {
+	info, err := d.ServerVersion()
+	if err != nil {
+		return false, errors.Wrap(err, "while discovering API server version for webhook match conditions")
+	}
+	v, err := version.ParseGeneric(info.GitVersion)
+	if err != nil {
+		return false, errors.Wrapf(err, "while parsing API server version %s", info.GitVersion)
+	}
+	return v.AtLeast(matchConditionsMinVersion), nil
+}
+
+// ensureMatchConditions patches the match conditions of the pod webhook of the configuration when they differ.
+// The conditions test pod fields, so the other webhooks of the configuration, e.g. of workloads or namespaces,
+// mustn't have any. Configurations which have none and shouldn't have any aren't patched, so it's a no-op
+// on older clusters. It returns true when it patched the configuration.
+func ensureMatchConditions(ctx context.Context, client ctlrclient.Client, gvk schema.GroupVersionKind, name string, conditions []MatchCondition) (bool, error) {
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(gvk)
+	if err := client.Get(ctx, types.NamespacedName{Name: name}, live); err != nil {
+		return false, errors.Wrapf(err, "failed to get %s %s", gvk.Kind, name)
+	}
+	// the pod webhook is named like its configuration
+	patch, err := matchConditionsPatch(live, name, conditions)
+	if err != nil || patch == nil {
+		return false, err
+	}
+	return true, errors.Wrapf(client.Patch(ctx, live, ctlrclient.RawPatch(types.JSONPatchType, patch), ctlrclient.FieldOwner(FieldManager)),
+		"while patching match conditions of %s %s", gvk.Kind, name)
+}
+
+type jsonPatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// matchConditionsPatch returns the JSON patch which sets the conditions of the podWebhook and removes them
+// from the other webhooks of the configuration, or nil when they are already set. The name of each patched webhook
+// is tested, so a configuration changed in the meantime isn't patched at wrong positions.
+func matchConditionsPatch(live *unstructured.Unstructured, podWebhook string, conditions []MatchCondition) ([]byte, error) {
+	webhooks, _, err := unstructured.NestedSlice(live.Object, "webhooks")
+	if err != nil {
+		return nil, errors.Wrap(err, "while reading webhooks")
+	}
+	podConditions := make([]interface{}, 0, len(conditions))
+	for _, c := range conditions {
+		podConditions = append(podConditions, map[string]interface{}{"name": c.Name, "expression": c.Expression})
+	}
+
+	var ops []jsonPatchOperation
+	for i, w := range webhooks {
+		webhook, ok := w.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		current, found, err := unstructured.NestedSlice(webhook, "matchConditions")
+		if err != nil {
+			return nil, errors.Wrap(err, "while reading match conditions")
+		}
+		desired := podConditions
+		if webhook["name"] != podWebhook {
+			desired = nil
+		}
+		path := fmt.Sprintf("/webhooks/%d", i)
+		switch {
+		case len(desired) == 0 && !found:
+			continue
+		case len(desired) == 0:
+			ops = append(ops,
+				jsonPatchOperation{Op: "test", Path: path + "/name", Value: webhook["name"]},
+				jsonPatchOperation{Op: "remove", Path: path + "/matchConditions"})
+		case !reflect.DeepEqual(current, desired):
+			ops = append(ops,
+				jsonPatchOperation{Op: "test", Path: path + "/name", Value: webhook["name"]},
+				jsonPatchOperation{Op: "add", Path: path + "/matchConditions", Value: desired})
+		}
+	}
+	if len(ops) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(ops)
+}
@@ -0,0 +1,217 @@
+package certs
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var trustedImages = MatchCondition{Name: "skip-trusted", Expression: "!object.spec.containers.all(c, c.image.startsWith('eu.gcr.io/kyma-project/'))"}
+
+func TestWebhookConfig_ForServer(t *testing.T) {
+	config := WebhookConfig{MatchConditions: []MatchCondition{trustedImages}}
+	server := func(gitVersion string) *fakediscovery.FakeDiscovery {
+		return &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}, FakedServerVersion: &version.Info{GitVersion: gitVersion}}
+	}
+
+	t.Run("keeps the conditions on supporting servers", func(t *testing.T) {
+		got, err := config.ForServer(server("v1.28.3+k3s1"))
+
+		require.NoError(t, err)
+		require.Equal(t, config.MatchConditions, got.MatchConditions)
+	})
+
+	t.Run("drops the conditions on older servers", func(t *testing.T) {
+		got, err := config.ForServer(server("v1.26.9-gke.100"))
+
+		require.NoError(t, err)
+		require.Empty(t, got.MatchConditions)
+	})
+
+	t.Run("drops the conditions when the version can't be discovered", func(t *testing.T) {
+		got, err := config.ForServer(failingDiscovery{})
+
+		require.ErrorContains(t, err, "while discovering API server version for webhook match conditions")
+		require.Empty(t, got.MatchConditions)
+	})
+
+	t.Run("drops the conditions when the version can't be parsed", func(t *testing.T) {
+		got, err := config.ForServer(server("unknown"))
+
+		require.ErrorContains(t, err, "while parsing API server version unknown")
+		require.Empty(t, got.MatchConditions)
+	})
+}
+
+func TestMatchConditionsPatch(t *testing.T) {
+	configuration := func(conditions ...interface{}) *unstructured.Unstructured {
+		webhook := map[string]interface{}{"name": ValidationWebhookName}
+		if conditions != nil {
+			webhook["matchConditions"] = conditions
+		}
+		return &unstructured.Unstructured{Object: map[string]interface{}{"webhooks": []interface{}{webhook}}}
+	}
+	trusted := map[string]interface{}{"name": trustedImages.Name, "expression": trustedImages.Expression}
+
+	tests := []struct {
+		name       string
+		live       *unstructured.Unstructured
+		conditions []MatchCondition
+		expected   string
+	}{
+		{
+			name:       "adds the conditions",
+			live:       configuration(),
+			conditions: []MatchCondition{trustedImages},
+			expected: `[{"op":"test","path":"/webhooks/0/name","value":"validation.webhook.warden.kyma-project.io"},` +
+				`{"op":"add","path":"/webhooks/0/matchConditions","value":[{"expression":"` + trustedImages.Expression + `","name":"skip-trusted"}]}]`,
+		},
+		{
+			name:       "unchanged conditions",
+			live:       configuration(trusted),
+			conditions: []MatchCondition{trustedImages},
+		},
+		{
+			name:       "replaces changed conditions",
+			live:       configuration(map[string]interface{}{"name": "old", "expression": "true"}),
+			conditions: []MatchCondition{trustedImages},
+			expected: `[{"op":"test","path":"/webhooks/0/name","value":"validation.webhook.warden.kyma-project.io"},` +
+				`{"op":"add","path":"/webhooks/0/matchConditions","value":[{"expression":"` + trustedImages.Expression + `","name":"skip-trusted"}]}]`,
+		},
+		{
+			name:     "removes the conditions",
+			live:     configuration(trusted),
+			expected: `[{"op":"test","path":"/webhooks/0/name","value":"validation.webhook.warden.kyma-project.io"},{"op":"remove","path":"/webhooks/0/matchConditions"}]`,
+		},
+		{
+			name: "no conditions",
+			live: configuration(),
+		},
+		{
+			name: "only the pod webhook gets the conditions",
+			live: &unstructured.Unstructured{Object: map[string]interface{}{"webhooks": []interface{}{
+				map[string]interface{}{"name": ValidationWebhookName},
+				map[string]interface{}{"name": NamespaceValidationWebhookName},
+			}}},
+			conditions: []MatchCondition{trustedImages},
+			expected: `[{"op":"test","path":"/webhooks/0/name","value":"validation.webhook.warden.kyma-project.io"},` +
+				`{"op":"add","path":"/webhooks/0/matchConditions","value":[{"expression":"` + trustedImages.Expression + `","name":"skip-trusted"}]}]`,
+		},
+		{
+			name: "removes the conditions of other webhooks",
+			live: &unstructured.Unstructured{Object: map[string]interface{}{"webhooks": []interface{}{
+				map[string]interface{}{"name": ValidationWebhookName, "matchConditions": []interface{}{trusted}},
+				map[string]interface{}{"name": WorkloadValidationWebhookName, "matchConditions": []interface{}{trusted}},
+			}}},
+			conditions: []MatchCondition{trustedImages},
+			expected: `[{"op":"test","path":"/webhooks/1/name","value":"workloads.validation.webhook.warden.kyma-project.io"},` +
+				`{"op":"remove","path":"/webhooks/1/matchConditions"}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := matchConditionsPatch(tt.live, ValidationWebhookName, tt.conditions)
+
+			require.NoError(t, err)
+			if tt.expected == "" {
+				require.Nil(t, patch)
+				return
+			}
+			require.JSONEq(t, tt.expected, string(patch))
+		})
+	}
+}
+
+type failingDiscovery struct{}
+
+func (failingDiscovery) ServerVersion() (*version.Info, error) {
+	return nil, errors.New("connection refused")
+}
+
+func (failingDiscovery) ServerGroups() (*metav1.APIGroupList, error) {
+	return nil, errors.New("connection refused")
+}
+
+// patchRecorder records the patches, the fake client drops match conditions as the API types don't have them.
+type patchRecorder struct {
+	ctrlclient.Client
+	patches []string
+}
+
+func (r *patchRecorder) Patch(ctx context.Context, obj ctrlclient.Object, patch ctrlclient.Patch, opts ...ctrlclient.PatchOption) error {
+	if patch.Type() != types.JSONPatchType {
+		return r.Client.Patch(ctx, obj, patch, opts...)
+	}
+	data, err := patch.Data(nil)
+	r.patches = append(r.patches, string(data))
+	return err
+}
+
+func TestEnsureWebhookConfigurationFor_MatchConditions(t *testing.T) {
+	base := WebhookConfig{ServiceName: "warden-admission", ServiceNamespace: "kyma-system", CABundel: []byte("ca")}
+
+	t.Run("patches the conditions into the pod webhooks", func(t *testing.T) {
+		//GIVEN
+		client := &patchRecorder{Client: newFakeClient()}
+		config := base
+		config.MatchConditions = []MatchCondition{trustedImages}
+
+		//WHEN
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, MutatingWebhook))
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, ValidatingWebHook))
+
+		//THEN
+		require.Len(t, client.patches, 2)
+		require.Contains(t, client.patches[0], DefaultingWebhookName)
+		require.Contains(t, client.patches[1], ValidationWebhookName)
+		for _, patch := range client.patches {
+			require.Contains(t, patch, `"op":"add","path":"/webhooks/0/matchConditions"`)
+			require.Contains(t, patch, trustedImages.Name)
+		}
+	})
+
+	t.Run("workload and namespace webhooks don't get the conditions", func(t *testing.T) {
+		//GIVEN
+		client := &patchRecorder{Client: newFakeClient()}
+		config := base
+		config.ValidateWorkloads = true
+		config.MatchConditions = []MatchCondition{trustedImages}
+
+		//WHEN
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, ValidatingWebHook))
+
+		//THEN
+		require.Len(t, client.patches, 1)
+		require.Contains(t, client.patches[0], `"op":"add","path":"/webhooks/0/matchConditions"`)
+		require.NotContains(t, client.patches[0], WorkloadValidationWebhookName)
+		require.NotContains(t, client.patches[0], NamespaceValidationWebhookName)
+	})
+
+	t.Run("configurations without conditions aren't patched", func(t *testing.T) {
+		client := &patchRecorder{Client: newFakeClient()}
+
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, base, MutatingWebhook))
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, base, ValidatingWebHook))
+
+		require.Empty(t, client.patches)
+	})
+
+	t.Run("invalid conditions", func(t *testing.T) {
+		config := base
+		config.MatchConditions = []MatchCondition{trustedImages, trustedImages, {Name: "empty"}, {Expression: "true"}}
+
+		err := config.Validate()
+
+		require.EqualError(t, err, "[webhook match condition skip-trusted is duplicated, "+
+			"webhook match condition empty must have the expression, webhook match condition must have the name]")
+	})
+}
@@ -0,0 +1,64 @@
+package certs
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+	ComponentLabel = "app.kubernetes.io/component"
+	InstanceLabel  = "app.kubernetes.io/instance"
+
+	ManagedBy        = "warden"
+	WebhookComponent = "admission-webhook"
+
+	// AppliedVersionAnnotation records the version of Warden which applied the webhook configuration last.
+	AppliedVersionAnnotation = "warden.kyma-project.io/applied-version"
+)
+
+// webhookLabels lets admins and cleanup tooling find the webhook configurations of the Warden instance.
+func webhookLabels(config WebhookConfig) map[string]string {
+	return map[string]string{
+		ManagedByLabel: ManagedBy,
+		ComponentLabel: WebhookComponent,
+		InstanceLabel:  config.ServiceName,
+	}
+}
+
+func webhookAnnotations(config WebhookConfig) map[string]string {
+	annotations := map[string]string{}
+	if config.CertManagerCertificate != "" {
+		annotations[CertManagerInjectCAAnnotation] = config.CertManagerCertificate
+	}
+	if config.Version != "" {
+		annotations[AppliedVersionAnnotation] = config.Version
+	}
+	if len(annotations) == 0 {
+		return nil
+	}
+	return annotations
+}
+
+// ensureMetadata enforces the labels and annotations of Warden and keeps the ones added by others.
+// It returns true when the metadata changed.
+func ensureMetadata(meta *metav1.ObjectMeta, config WebhookConfig) bool {
+	changed := ensureInjectCAAnnotation(meta, config)
+	for key, value := range webhookLabels(config) {
+		if current, ok := meta.Labels[key]; ok && current == value {
+			continue
+		}
+		if meta.Labels == nil {
+			meta.Labels = map[string]string{}
+		}
+		meta.Labels[key] = value
+		changed = true
+	}
+	if config.Version != "" && meta.Annotations[AppliedVersionAnnotation] != config.Version {
+		if meta.Annotations == nil {
+			meta.Annotations = map[string]string{}
+		}
+		meta.Annotations[AppliedVersionAnnotation] = config.Version
+		changed = true
+	}
+	return changed
+}
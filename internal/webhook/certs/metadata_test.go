@@ -0,0 +1,79 @@
+package certs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestEnsureWebhookConfigurationFor_Metadata(t *testing.T) {
+	config := WebhookConfig{ServiceName: "warden-admission", ServiceNamespace: "kyma-system", CABundel: []byte("ca"), Version: "0.10.0"}
+	expectedLabels := map[string]string{
+		"app.kubernetes.io/managed-by": "warden",
+		"app.kubernetes.io/component":  "admission-webhook",
+		"app.kubernetes.io/instance":   "warden-admission",
+	}
+
+	t.Run("labels and version on create", func(t *testing.T) {
+		//GIVEN
+		client := newFakeClient()
+
+		//WHEN
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, MutatingWebhook))
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, ValidatingWebHook))
+
+		//THEN
+		mutating := &admissionregistrationv1.MutatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: DefaultingWebhookName}, mutating))
+		require.Equal(t, expectedLabels, mutating.Labels)
+		require.Equal(t, map[string]string{AppliedVersionAnnotation: "0.10.0"}, mutating.Annotations)
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, validating))
+		require.Equal(t, expectedLabels, validating.Labels)
+		require.Equal(t, map[string]string{AppliedVersionAnnotation: "0.10.0"}, validating.Annotations)
+	})
+
+	t.Run("update keeps the metadata of others", func(t *testing.T) {
+		//GIVEN
+		client := newFakeClient()
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, ValidatingWebHook))
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, validating))
+		validating.Labels["team"] = "security"
+		validating.Labels["app.kubernetes.io/managed-by"] = "helm"
+		validating.Annotations["backup.example.com/policy"] = "daily"
+		require.NoError(t, client.Update(context.TODO(), validating))
+		upgraded := config
+		upgraded.Version = "0.11.0"
+
+		//WHEN
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, upgraded, ValidatingWebHook))
+
+		//THEN
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, validating))
+		require.Equal(t, "security", validating.Labels["team"])
+		require.Equal(t, "warden", validating.Labels["app.kubernetes.io/managed-by"])
+		require.Equal(t, "daily", validating.Annotations["backup.example.com/policy"])
+		require.Equal(t, "0.11.0", validating.Annotations[AppliedVersionAnnotation])
+	})
+
+	t.Run("configurations created before the labels get them", func(t *testing.T) {
+		//GIVEN
+		unlabeled := createMutatingWebhookConfiguration(config)
+		unlabeled.Labels = nil
+		unlabeled.Annotations = nil
+		client := newFakeClient(unlabeled)
+
+		//WHEN
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, MutatingWebhook))
+
+		//THEN
+		mutating := &admissionregistrationv1.MutatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: DefaultingWebhookName}, mutating))
+		require.Equal(t, expectedLabels, mutating.Labels)
+		require.Equal(t, "0.10.0", mutating.Annotations[AppliedVersionAnnotation])
+	})
+}
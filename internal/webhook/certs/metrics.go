@@ -0,0 +1,45 @@
+package certs
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var webhookConfigurationRepairs = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "warden_webhook_configuration_repairs_total",
+	Help: "Number of webhook configurations recreated or reverted after they were deleted or changed, per configuration.",
+}, []string{"configuration"})
+
+var (
+	webhookConfigurationEnsures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "warden_webhook_configuration_ensures_total",
+		Help: "Number of times the webhook configurations were ensured, per webhook type and outcome.",
+	}, []string{"webhook", "outcome"})
+	webhookConfigurationInSync = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "warden_webhook_configuration_in_sync",
+		Help: "1 when the webhook configuration was ensured successfully the last time, 0 when it failed, per webhook type.",
+	}, []string{"webhook"})
+	webhookConfigurationLastSync = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "warden_webhook_configuration_last_sync_timestamp_seconds",
+		Help: "Unix time of the last successful periodic resync of the webhook configurations.",
+	})
+	servingCertificateNotAfter = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "warden_webhook_serving_certificate_not_after_timestamp_seconds",
+		Help: "Unix time the certificate served by the webhook server expires at.",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(webhookConfigurationRepairs, webhookConfigurationEnsures, webhookConfigurationInSync, webhookConfigurationLastSync,
+		servingCertificateNotAfter)
+}
+
+// recordEnsure counts the outcome, failed ensures aren't counted but take the configuration out of sync.
+func recordEnsure(wt WebHookType, result EnsureResult, err error) {
+	if err != nil {
+		webhookConfigurationInSync.WithLabelValues(string(wt)).Set(0)
+		return
+	}
+	webhookConfigurationEnsures.WithLabelValues(string(wt), string(result)).Inc()
+	webhookConfigurationInSync.WithLabelValues(string(wt)).Set(1)
+}
@@ -0,0 +1,92 @@
+package certs
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestEnsureWebhookConfigurationFor_Result(t *testing.T) {
+	config := WebhookConfig{ServiceName: "warden-admission", ServiceNamespace: "kyma-system", CABundel: []byte("ca")}
+	ensures := func(wt WebHookType, result EnsureResult) float64 {
+		return testutil.ToFloat64(webhookConfigurationEnsures.WithLabelValues(string(wt), string(result)))
+	}
+	inSync := func(wt WebHookType) float64 {
+		return testutil.ToFloat64(webhookConfigurationInSync.WithLabelValues(string(wt)))
+	}
+
+	t.Run("create, no-op and drift", func(t *testing.T) {
+		//GIVEN
+		client := newFakeClient()
+		created := ensures(ValidatingWebHook, EnsureCreated)
+		unchanged := ensures(ValidatingWebHook, EnsureUnchanged)
+		updated := ensures(ValidatingWebHook, EnsureUpdated)
+
+		//WHEN
+		createResult, err := ensureWebhookConfigurationFor(context.TODO(), client, config, ValidatingWebHook)
+		require.NoError(t, err)
+		noopResult, err := ensureWebhookConfigurationFor(context.TODO(), client, config, ValidatingWebHook)
+		require.NoError(t, err)
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, validating))
+		validating.Webhooks[0].TimeoutSeconds = pointer.Int32(29)
+		require.NoError(t, client.Update(context.TODO(), validating))
+		driftResult, err := ensureWebhookConfigurationFor(context.TODO(), client, config, ValidatingWebHook)
+		require.NoError(t, err)
+
+		//THEN
+		require.Equal(t, EnsureCreated, createResult)
+		require.Equal(t, EnsureUnchanged, noopResult)
+		require.Equal(t, EnsureUpdated, driftResult)
+		require.Equal(t, created+1, ensures(ValidatingWebHook, EnsureCreated))
+		require.Equal(t, unchanged+1, ensures(ValidatingWebHook, EnsureUnchanged))
+		require.Equal(t, updated+1, ensures(ValidatingWebHook, EnsureUpdated))
+		require.Equal(t, float64(1), inSync(ValidatingWebHook))
+	})
+
+	t.Run("mutating webhook is counted apart", func(t *testing.T) {
+		//GIVEN
+		client := newFakeClient()
+		created := ensures(MutatingWebhook, EnsureCreated)
+		validatingCreated := ensures(ValidatingWebHook, EnsureCreated)
+
+		//WHEN
+		result, err := ensureWebhookConfigurationFor(context.TODO(), client, config, MutatingWebhook)
+
+		//THEN
+		require.NoError(t, err)
+		require.Equal(t, EnsureCreated, result)
+		require.Equal(t, created+1, ensures(MutatingWebhook, EnsureCreated))
+		require.Equal(t, validatingCreated, ensures(ValidatingWebHook, EnsureCreated))
+	})
+
+	t.Run("failure takes the configuration out of sync", func(t *testing.T) {
+		//GIVEN
+		client := failingGetClient{Client: newFakeClient()}
+		_, err := ensureWebhookConfigurationFor(context.TODO(), newFakeClient(), config, MutatingWebhook)
+		require.NoError(t, err)
+		require.Equal(t, float64(1), inSync(MutatingWebhook))
+
+		//WHEN
+		_, err = ensureWebhookConfigurationFor(context.TODO(), client, config, MutatingWebhook)
+
+		//THEN
+		require.ErrorContains(t, err, "failed to get defaulting MutatingWebhookConfiguration")
+		require.Equal(t, float64(0), inSync(MutatingWebhook))
+	})
+}
+
+type failingGetClient struct {
+	ctrlclient.Client
+}
+
+func (failingGetClient) Get(context.Context, ctrlclient.ObjectKey, ctrlclient.Object, ...ctrlclient.GetOption) error {
+	return errors.New("connection refused")
+}
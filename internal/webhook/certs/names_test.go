@@ -0,0 +1,120 @@
+package certs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestEnsureWebhookConfigurationFor_NamePrefix(t *testing.T) {
+	prod := WebhookConfig{ServiceName: "warden-admission", ServiceNamespace: "warden-prod", CABundel: []byte("prod-ca")}
+	dev := WebhookConfig{ServiceName: "warden-admission", ServiceNamespace: "warden-dev", CABundel: []byte("dev-ca"), NamePrefix: "dev-", ValidateWorkloads: true}
+	ensureBoth := func(t *testing.T, config WebhookConfig, client ctrlclient.Client) {
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, MutatingWebhook))
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, ValidatingWebHook))
+	}
+	getValidating := func(t *testing.T, client ctrlclient.Client, name string) *admissionregistrationv1.ValidatingWebhookConfiguration {
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: name}, validating))
+		return validating
+	}
+
+	t.Run("installations side by side", func(t *testing.T) {
+		//GIVEN
+		client := newFakeClient()
+
+		//WHEN
+		ensureBoth(t, prod, client)
+		ensureBoth(t, dev, client)
+
+		//THEN
+		mutatings := &admissionregistrationv1.MutatingWebhookConfigurationList{}
+		require.NoError(t, client.List(context.TODO(), mutatings))
+		require.Len(t, mutatings.Items, 2)
+		prodValidating := getValidating(t, client, ValidationWebhookName)
+		require.Len(t, prodValidating.Webhooks, 2)
+		require.Equal(t, "warden-prod", prodValidating.Webhooks[0].ClientConfig.Service.Namespace)
+		require.Equal(t, []byte("prod-ca"), prodValidating.Webhooks[0].ClientConfig.CABundle)
+		devValidating := getValidating(t, client, "dev-validation.webhook.warden.kyma-project.io")
+		require.Len(t, devValidating.Webhooks, 3)
+		require.Equal(t, "dev-validation.webhook.warden.kyma-project.io", devValidating.Webhooks[0].Name)
+		require.Equal(t, "dev-namespaces.validation.webhook.warden.kyma-project.io", devValidating.Webhooks[1].Name)
+		require.Equal(t, "dev-workloads.validation.webhook.warden.kyma-project.io", devValidating.Webhooks[2].Name)
+		require.Equal(t, "warden-dev", devValidating.Webhooks[0].ClientConfig.Service.Namespace)
+		devMutating := &admissionregistrationv1.MutatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: "dev-defaulting.webhook.warden.kyma-project.io"}, devMutating))
+		require.Equal(t, "dev-defaulting.webhook.warden.kyma-project.io", devMutating.Webhooks[0].Name)
+	})
+
+	t.Run("ensuring one installation keeps the other", func(t *testing.T) {
+		//GIVEN
+		client := newFakeClient()
+		ensureBoth(t, prod, client)
+		ensureBoth(t, dev, client)
+		before := getValidating(t, client, ValidationWebhookName)
+		changed := dev
+		changed.TimeoutSeconds = pointer.Int32(5)
+
+		//WHEN
+		result, err := ensureWebhookConfigurationFor(context.TODO(), client, changed, ValidatingWebHook)
+
+		//THEN
+		require.NoError(t, err)
+		require.Equal(t, EnsureUpdated, result)
+		require.Equal(t, before, getValidating(t, client, ValidationWebhookName))
+		require.Equal(t, int32(5), *getValidating(t, client, "dev-validation.webhook.warden.kyma-project.io").Webhooks[0].TimeoutSeconds)
+	})
+
+	t.Run("drift controller repairs its own configurations", func(t *testing.T) {
+		//GIVEN
+		client := newFakeClient()
+		ensureBoth(t, prod, client)
+		r := &resourceReconciler{webhookConfig: dev, client: client, recorder: record.NewFakeRecorder(10), logger: zap.NewNop().Sugar()}
+
+		//WHEN
+		for _, name := range []string{DefaultingWebhookName, ValidationWebhookName, "dev-defaulting.webhook.warden.kyma-project.io"} {
+			_, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{Name: name}})
+			require.NoError(t, err)
+		}
+
+		//THEN
+		mutatings := &admissionregistrationv1.MutatingWebhookConfigurationList{}
+		require.NoError(t, client.List(context.TODO(), mutatings))
+		require.Len(t, mutatings.Items, 2)
+		validatings := &admissionregistrationv1.ValidatingWebhookConfigurationList{}
+		require.NoError(t, client.List(context.TODO(), validatings))
+		require.Len(t, validatings.Items, 1)
+		require.Equal(t, "warden-prod", getValidating(t, client, ValidationWebhookName).Webhooks[0].ClientConfig.Service.Namespace)
+	})
+
+	t.Run("cleanup deletes only its own configurations", func(t *testing.T) {
+		//GIVEN
+		client := newFakeClient()
+		ensureBoth(t, prod, client)
+		ensureBoth(t, dev, client)
+
+		//WHEN
+		require.NoError(t, DeleteWebhookConfigurations(context.TODO(), client, dev))
+
+		//THEN
+		getValidating(t, client, ValidationWebhookName)
+		err := client.Get(context.TODO(), types.NamespacedName{Name: "dev-validation.webhook.warden.kyma-project.io"}, &admissionregistrationv1.ValidatingWebhookConfiguration{})
+		require.True(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("invalid prefix", func(t *testing.T) {
+		config := dev
+		config.NamePrefix = "Dev_"
+
+		require.ErrorContains(t, config.Validate(), "invalid webhook name prefix Dev_: a lowercase RFC 1123 subdomain must consist of")
+	})
+}
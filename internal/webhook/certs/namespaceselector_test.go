@@ -0,0 +1,136 @@
+package certs
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+func TestEnsureWebhookConfigurationFor_NamespaceSelector(t *testing.T) {
+	base := WebhookConfig{ServiceName: "warden-admission", ServiceNamespace: "kyma-system", CABundel: []byte("ca")}
+	selectors := func(t *testing.T, client ctrlclient.Client, config WebhookConfig) (*metav1.LabelSelector, *metav1.LabelSelector) {
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, MutatingWebhook))
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, ValidatingWebHook))
+		mutating := &admissionregistrationv1.MutatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: DefaultingWebhookName}, mutating))
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, validating))
+		return mutating.Webhooks[0].NamespaceSelector, validating.Webhooks[0].NamespaceSelector
+	}
+
+	t.Run("default excludes the system namespaces and the one of warden", func(t *testing.T) {
+		mutating, validating := selectors(t, newFakeClient(), base)
+
+		expected := &metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{{
+				Key:      "kubernetes.io/metadata.name",
+				Operator: metav1.LabelSelectorOpNotIn,
+				Values:   []string{"kube-system", "kube-node-lease", "kyma-system"},
+			}},
+		}
+		require.Equal(t, expected, mutating)
+		require.Equal(t, expected, validating)
+	})
+
+	t.Run("default of warden in kube-system", func(t *testing.T) {
+		selector := DefaultNamespaceSelector("kube-system")
+
+		require.Equal(t, []string{"kube-system", "kube-node-lease"}, selector.MatchExpressions[0].Values)
+	})
+
+	t.Run("custom selector", func(t *testing.T) {
+		config := base
+		config.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"namespaces.warden.kyma-project.io/validate": "enabled"}}
+
+		mutating, validating := selectors(t, newFakeClient(), config)
+
+		require.Equal(t, config.NamespaceSelector, mutating)
+		require.Equal(t, config.NamespaceSelector, validating)
+	})
+
+	t.Run("empty selector selects all namespaces", func(t *testing.T) {
+		config := base
+		config.NamespaceSelector = &metav1.LabelSelector{}
+
+		mutating, validating := selectors(t, newFakeClient(), config)
+
+		require.Equal(t, &metav1.LabelSelector{}, mutating)
+		require.Equal(t, &metav1.LabelSelector{}, validating)
+	})
+
+	t.Run("selector changes are reconciled", func(t *testing.T) {
+		//GIVEN
+		client := newFakeClient()
+		selectors(t, client, base)
+		config := base
+		config.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"team": "apps"}}
+
+		//WHEN
+		mutating, validating := selectors(t, client, config)
+
+		//THEN
+		require.Equal(t, config.NamespaceSelector, mutating)
+		require.Equal(t, config.NamespaceSelector, validating)
+	})
+
+	t.Run("invalid selector", func(t *testing.T) {
+		config := base
+		config.NamespaceSelector = &metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "team", Operator: "Near"}},
+		}
+
+		err := config.Validate()
+
+		require.ErrorContains(t, err, "invalid webhook namespace selector")
+	})
+}
+
+func TestNamespaceSelector_SystemNamespacesBypassWebhook(t *testing.T) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		t.Skip("KUBEBUILDER_ASSETS isn't set, envtest can't start the API server")
+	}
+	testEnv := &envtest.Environment{}
+	cfg, err := testEnv.Start()
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, testEnv.Stop())
+	}()
+	client, err := ctrlclient.New(cfg, ctrlclient.Options{Scheme: scheme.Scheme})
+	require.NoError(t, err)
+
+	//GIVEN
+	// the service doesn't exist, so the webhook rejects every pod it matches
+	fail := admissionregistrationv1.Fail
+	config := WebhookConfig{
+		ServiceName:      "warden-admission",
+		ServiceNamespace: "kyma-system",
+		CABundel:         []byte("ca"),
+		FailurePolicy:    &fail,
+		TimeoutSeconds:   pointer.Int32(1),
+	}
+	require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, ValidatingWebHook))
+	newPod := func(namespace string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: namespace},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "eu.gcr.io/kyma-project/app:1.0"}}},
+		}
+	}
+
+	//WHEN
+	systemErr := client.Create(context.TODO(), newPod(metav1.NamespaceSystem))
+	defaultErr := client.Create(context.TODO(), newPod(metav1.NamespaceDefault))
+
+	//THEN
+	require.NoError(t, systemErr)
+	require.ErrorContains(t, defaultErr, ValidationWebhookName)
+}
@@ -0,0 +1,68 @@
+package certs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kyma-project/warden/internal/admission"
+	"github.com/kyma-project/warden/pkg"
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+)
+
+func TestEnsureWebhookConfigurationFor_Namespaces(t *testing.T) {
+	config := WebhookConfig{ServiceName: "warden-admission", ServiceNamespace: "kyma-system", CABundel: []byte("ca")}
+
+	t.Run("pod and namespace webhooks", func(t *testing.T) {
+		//GIVEN
+		client := newFakeClient()
+
+		//WHEN
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, ValidatingWebHook))
+
+		//THEN
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, validating))
+		require.Len(t, validating.Webhooks, 2)
+		require.Equal(t, ValidationWebhookName, validating.Webhooks[0].Name)
+		namespaces := validating.Webhooks[1]
+		require.Equal(t, NamespaceValidationWebhookName, namespaces.Name)
+		require.Equal(t, admission.NamespaceValidationPath, *namespaces.ClientConfig.Service.Path)
+		require.Equal(t, []string{""}, namespaces.Rules[0].APIGroups)
+		require.Equal(t, []string{"namespaces"}, namespaces.Rules[0].Resources)
+		require.Equal(t, []admissionregistrationv1.OperationType{admissionregistrationv1.Update}, namespaces.Rules[0].Operations)
+		require.Equal(t, admissionregistrationv1.ClusterScope, *namespaces.Rules[0].Scope)
+		require.Equal(t, pkg.NamespaceValidationLabel, namespaces.ObjectSelector.MatchExpressions[0].Key)
+	})
+
+	tests := []struct {
+		name    string
+		drifted int
+		kept    int
+	}{
+		{name: "namespace webhook drift is repaired", drifted: 1, kept: 0},
+		{name: "pod webhook drift is repaired", drifted: 0, kept: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//GIVEN
+			client := newFakeClient()
+			require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, ValidatingWebHook))
+			validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+			require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, validating))
+			kept := validating.Webhooks[tt.kept].DeepCopy()
+			validating.Webhooks[tt.drifted].TimeoutSeconds = pointer.Int32(29)
+			require.NoError(t, client.Update(context.TODO(), validating))
+
+			//WHEN
+			require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, ValidatingWebHook))
+
+			//THEN
+			require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, validating))
+			require.Equal(t, int32(WebhookTimeout), *validating.Webhooks[tt.drifted].TimeoutSeconds)
+			require.Equal(t, *kept, validating.Webhooks[tt.kept])
+		})
+	}
+}
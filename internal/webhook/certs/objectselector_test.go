@@ -0,0 +1,81 @@
+package certs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kyma-project/warden/pkg"
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestEnsureWebhookConfigurationFor_ObjectSelector(t *testing.T) {
+	base := WebhookConfig{ServiceName: "warden-admission", ServiceNamespace: "kyma-system", CABundel: []byte("ca")}
+	labeled := &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: pkg.PodValidationLabel, Operator: metav1.LabelSelectorOpExists},
+		},
+	}
+	selectors := func(t *testing.T, client ctrlclient.Client, config WebhookConfig) (*metav1.LabelSelector, *metav1.LabelSelector) {
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, MutatingWebhook))
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, ValidatingWebHook))
+		mutating := &admissionregistrationv1.MutatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: DefaultingWebhookName}, mutating))
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, validating))
+		return mutating.Webhooks[0].ObjectSelector, validating.Webhooks[0].ObjectSelector
+	}
+
+	t.Run("all pods are selected by default", func(t *testing.T) {
+		mutating, validating := selectors(t, newFakeClient(), base)
+
+		require.Equal(t, &metav1.LabelSelector{}, mutating)
+		require.Equal(t, &metav1.LabelSelector{}, validating)
+	})
+
+	t.Run("selector lands in both configurations", func(t *testing.T) {
+		config := base
+		config.ObjectSelector = labeled
+
+		mutating, validating := selectors(t, newFakeClient(), config)
+
+		require.Equal(t, labeled, mutating)
+		require.Equal(t, labeled, validating)
+	})
+
+	t.Run("selector changes are reconciled", func(t *testing.T) {
+		//GIVEN
+		client := newFakeClient()
+		config := base
+		config.ObjectSelector = labeled
+		selectors(t, client, config)
+		changed := &metav1.LabelSelector{MatchLabels: map[string]string{pkg.PodValidationLabel: pkg.ValidationStatusPending}}
+		config.ObjectSelector = changed
+
+		//WHEN
+		mutating, validating := selectors(t, client, config)
+
+		//THEN
+		require.Equal(t, changed, mutating)
+		require.Equal(t, changed, validating)
+
+		// removing the selector selects all pods again
+		mutating, validating = selectors(t, client, base)
+		require.Equal(t, &metav1.LabelSelector{}, mutating)
+		require.Equal(t, &metav1.LabelSelector{}, validating)
+	})
+
+	t.Run("invalid selector", func(t *testing.T) {
+		config := base
+		config.ObjectSelector = &metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{{Key: pkg.PodValidationLabel, Operator: metav1.LabelSelectorOpIn}},
+		}
+
+		err := EnsureWebhookConfigurationFor(context.TODO(), newFakeClient(), config, MutatingWebhook)
+
+		require.ErrorContains(t, err, "invalid webhook object selector")
+	})
+}
@@ -0,0 +1,102 @@
+package certs
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/utils/clock"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DefaultResyncInterval is how often the webhook configurations are ensured besides the watch of the controller.
+	DefaultResyncInterval = 10 * time.Minute
+	// resyncJitter spreads the resyncs of the replicas, so they don't write at the same time.
+	resyncJitter = 0.1
+	// resyncStaleAfter is the number of intervals without a successful resync after which the check fails.
+	resyncStaleAfter = 3
+)
+
+// WebhookResyncer ensures the webhook configurations periodically, it repairs changes the watch missed,
+// e.g. after a watch outage, and records the time of the last successful resync.
+type WebhookResyncer struct {
+	client        ctrlclient.Client
+	webhookConfig WebhookConfig
+	interval      time.Duration
+	clock         clock.Clock
+	log           *zap.SugaredLogger
+	// lastSync is the unix time in nanoseconds of the last successful resync, or of the start before it
+	lastSync atomic.Int64
+}
+
+// NewWebhookResyncer ensures the webhook configurations of webhookConfig every interval, with a jitter.
+func NewWebhookResyncer(client ctrlclient.Client, webhookConfig WebhookConfig, interval time.Duration, log *zap.SugaredLogger) *WebhookResyncer {
+	if interval <= 0 {
+		interval = DefaultResyncInterval
+	}
+	return &WebhookResyncer{
+		client:        client,
+		webhookConfig: webhookConfig,
+		interval:      interval,
+		clock:         clock.RealClock{},
+		log:           log.Named("webhook-resync"),
+	}
+}
+
+// Resync ensures both webhook configurations and records the time when it succeeds.
+func (r *WebhookResyncer) Resync(ctx context.Context) error {
+	mutating, validating, err := ensureAllWebhookConfigurations(ctx, r.client, r.webhookConfig)
+	if err != nil {
+		return errors.Wrap(err, "failed to resync webhook configurations")
+	}
+	if mutating != EnsureUnchanged || validating != EnsureUnchanged {
+		r.log.With("defaulting", mutating, "validation", validating).Warn("webhook configurations were out of sync, resynced them")
+	}
+	now := r.clock.Now()
+	r.lastSync.Store(now.UnixNano())
+	webhookConfigurationLastSync.Set(float64(now.Unix()))
+	return nil
+}
+
+// Start resyncs the webhook configurations until the context is done, it implements manager.Runnable.
+// Failed resyncs are retried with the next one.
+func (r *WebhookResyncer) Start(ctx context.Context) error {
+	r.lastSync.Store(r.clock.Now().UnixNano())
+	for {
+		timer := r.clock.NewTimer(wait.Jitter(r.interval, resyncJitter))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C():
+			if err := r.Resync(ctx); err != nil {
+				r.log.Error("failed to resync webhook configurations ", err.Error())
+			}
+		}
+	}
+}
+
+// NeedLeaderElection is true, like the resources controller only the leader writes the configurations.
+func (r *WebhookResyncer) NeedLeaderElection() bool {
+	return true
+}
+
+// Check fails when the webhook configurations weren't resynced successfully for a few intervals,
+// it has the healthz.Checker signature used by the manager readiness checks. Only the leader resyncs,
+// so only its readiness depends on it.
+func (r *WebhookResyncer) Check(_ *http.Request) error {
+	last := r.lastSync.Load()
+	if last == 0 {
+		return nil
+	}
+	since := r.clock.Since(time.Unix(0, last))
+	if since > resyncStaleAfter*r.interval {
+		return errors.Errorf("webhook configurations weren't resynced for %s", since.Round(time.Second))
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package certs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	testingclock "k8s.io/utils/clock/testing"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// failingReads fails the reads of unstructured objects, which the resync starts with, when fail is set.
+type failingReads struct {
+	ctrlclient.Client
+	fail atomic.Bool
+}
+
+func (c *failingReads) Get(ctx context.Context, key ctrlclient.ObjectKey, obj ctrlclient.Object, opts ...ctrlclient.GetOption) error {
+	if _, ok := obj.(*unstructured.Unstructured); ok && c.fail.Load() {
+		return errors.New("connection refused")
+	}
+	return c.Client.Get(ctx, key, obj, opts...)
+}
+
+func TestWebhookResyncer(t *testing.T) {
+	config := WebhookConfig{ServiceName: "warden-admission", ServiceNamespace: "kyma-system", CABundel: []byte("ca")}
+	interval := time.Minute
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	newResyncer := func(client ctrlclient.Client, clk *testingclock.FakeClock) *WebhookResyncer {
+		r := NewWebhookResyncer(client, config, interval, zap.NewNop().Sugar())
+		r.clock = clk
+		return r
+	}
+	// every resync ensures the defaulting configuration once
+	resyncs := func() float64 {
+		var sum float64
+		for _, result := range []EnsureResult{EnsureCreated, EnsureUpdated, EnsureUnchanged} {
+			sum += testutil.ToFloat64(webhookConfigurationEnsures.WithLabelValues(string(MutatingWebhook), string(result)))
+		}
+		return sum
+	}
+
+	t.Run("resyncs repeatedly and stops with the context", func(t *testing.T) {
+		//GIVEN
+		client := newFakeClient()
+		clk := testingclock.NewFakeClock(start)
+		r := newResyncer(client, clk)
+		before := resyncs()
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() { done <- r.Start(ctx) }()
+		resynced := func(n float64) func() bool {
+			return func() bool { return resyncs()-before == n && clk.HasWaiters() }
+		}
+
+		//WHEN
+		require.Eventually(t, resynced(0), time.Second, time.Millisecond)
+		clk.Step(2 * interval)
+		require.Eventually(t, resynced(1), time.Second, time.Millisecond)
+		require.NoError(t, client.Delete(context.TODO(), &admissionregistrationv1.MutatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: DefaultingWebhookName},
+		}))
+		clk.Step(2 * interval)
+		require.Eventually(t, resynced(2), time.Second, time.Millisecond)
+		cancel()
+
+		//THEN
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("resyncer didn't stop")
+		}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: DefaultingWebhookName}, &admissionregistrationv1.MutatingWebhookConfiguration{}))
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, &admissionregistrationv1.ValidatingWebhookConfiguration{}))
+		require.Equal(t, float64(clk.Now().Unix()), testutil.ToFloat64(webhookConfigurationLastSync))
+	})
+
+	t.Run("doesn't resync before the interval", func(t *testing.T) {
+		//GIVEN
+		clk := testingclock.NewFakeClock(start)
+		r := newResyncer(newFakeClient(), clk)
+		before := resyncs()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() { _ = r.Start(ctx) }()
+		require.Eventually(t, clk.HasWaiters, time.Second, time.Millisecond)
+
+		//WHEN
+		clk.Step(interval - interval/10 - time.Second)
+
+		//THEN
+		require.Never(t, func() bool { return resyncs() > before }, 50*time.Millisecond, time.Millisecond)
+	})
+
+	t.Run("check fails when resyncs keep failing", func(t *testing.T) {
+		//GIVEN
+		client := &failingReads{Client: newFakeClient()}
+		clk := testingclock.NewFakeClock(start)
+		r := newResyncer(client, clk)
+		require.NoError(t, r.Check(nil))
+		require.NoError(t, r.Resync(context.TODO()))
+
+		//WHEN
+		client.fail.Store(true)
+		require.ErrorContains(t, r.Resync(context.TODO()), "failed to resync webhook configurations")
+		clk.Step(2 * interval)
+		healthyErr := r.Check(nil)
+		clk.Step(2 * interval)
+		staleErr := r.Check(nil)
+		client.fail.Store(false)
+		require.NoError(t, r.Resync(context.TODO()))
+
+		//THEN
+		require.NoError(t, healthyErr)
+		require.EqualError(t, staleErr, "webhook configurations weren't resynced for 4m0s")
+		require.NoError(t, r.Check(nil))
+	})
+}
@@ -0,0 +1,87 @@
+package certs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// concurrentWriter fails the first applies of webhook configurations like a concurrent writer would.
+type concurrentWriter struct {
+	ctrlclient.Client
+	failures int
+	race     func(ctx context.Context, obj ctrlclient.Object) error
+}
+
+func (c *concurrentWriter) Patch(ctx context.Context, obj ctrlclient.Object, patch ctrlclient.Patch, opts ...ctrlclient.PatchOption) error {
+	if patch.Type() == types.ApplyPatchType && c.failures > 0 {
+		c.failures--
+		return c.race(ctx, obj)
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func TestEnsureWebhookConfigurationFor_ConcurrentWriter(t *testing.T) {
+	config := WebhookConfig{ServiceName: "warden-admission", ServiceNamespace: "kyma-system", CABundel: []byte("ca")}
+	conflict := func(_ context.Context, obj ctrlclient.Object) error {
+		return apierrors.NewConflict(admissionregistrationv1.Resource("validatingwebhookconfigurations"), obj.GetName(), nil)
+	}
+
+	t.Run("conflict on update is retried", func(t *testing.T) {
+		//GIVEN
+		drifted := createValidatingWebhookConfiguration(config)
+		drifted.Webhooks[0].TimeoutSeconds = pointer.Int32(29)
+		client := &concurrentWriter{Client: newFakeClient(drifted), failures: 1, race: conflict}
+
+		//WHEN
+		result, err := ensureWebhookConfigurationFor(context.TODO(), client, config, ValidatingWebHook)
+
+		//THEN
+		require.NoError(t, err)
+		require.Equal(t, EnsureUpdated, result)
+		require.Zero(t, client.failures)
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, validating))
+		require.Equal(t, int32(WebhookTimeout), *validating.Webhooks[0].TimeoutSeconds)
+	})
+
+	t.Run("already existing configuration is updated", func(t *testing.T) {
+		//GIVEN
+		client := &concurrentWriter{Client: newFakeClient(), failures: 1}
+		client.race = func(ctx context.Context, obj ctrlclient.Object) error {
+			// the other replica creates the configuration of an older version first
+			other := createValidatingWebhookConfiguration(config)
+			other.Webhooks[0].TimeoutSeconds = pointer.Int32(29)
+			require.NoError(t, client.Client.Create(ctx, other))
+			return apierrors.NewAlreadyExists(admissionregistrationv1.Resource("validatingwebhookconfigurations"), obj.GetName())
+		}
+
+		//WHEN
+		result, err := ensureWebhookConfigurationFor(context.TODO(), client, config, ValidatingWebHook)
+
+		//THEN
+		require.NoError(t, err)
+		require.Equal(t, EnsureUpdated, result)
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, validating))
+		require.Equal(t, int32(WebhookTimeout), *validating.Webhooks[0].TimeoutSeconds)
+	})
+
+	t.Run("persistent conflicts fail", func(t *testing.T) {
+		//GIVEN
+		client := &concurrentWriter{Client: newFakeClient(), failures: 100, race: conflict}
+
+		//WHEN
+		err := EnsureWebhookConfigurationFor(context.TODO(), client, config, ValidatingWebHook)
+
+		//THEN
+		require.ErrorContains(t, err, "while creating webhook validation configuration")
+		require.True(t, apierrors.IsConflict(err))
+	})
+}
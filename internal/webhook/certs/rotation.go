@@ -0,0 +1,93 @@
+package certs
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultRotationCheckInterval is how often the rotator checks whether the serving certificate is due for renewal.
+const DefaultRotationCheckInterval = time.Hour
+
+// CertificateRotator renews the serving certificate stored in the Secret before it expires, injects the CA bundle
+// into the webhook configurations and serves the current certificate through GetCertificate, so the webhook server
+// doesn't need a restart. It runs on every replica, replicas pick up the certificate renewed by another one.
+type CertificateRotator struct {
+	servingCertificate
+	client        ctrlclient.Client
+	opts          CertificateOptions
+	webhookConfig WebhookConfig
+	interval      time.Duration
+	log           *zap.SugaredLogger
+}
+
+// NewCertificateRotator rotates the certificate of opts, the webhook configurations are ensured with webhookConfig
+// and the CA bundle of the Secret.
+func NewCertificateRotator(client ctrlclient.Client, opts CertificateOptions, webhookConfig WebhookConfig, interval time.Duration, log *zap.SugaredLogger) *CertificateRotator {
+	if interval <= 0 {
+		interval = DefaultRotationCheckInterval
+	}
+	webhookConfig.ServiceName, webhookConfig.ServiceNamespace = opts.ServiceName, opts.ServiceNamespace
+	return &CertificateRotator{
+		client:        client,
+		opts:          opts.withDefaults(),
+		webhookConfig: webhookConfig,
+		interval:      interval,
+		log:           log.Named("cert-rotator"),
+	}
+}
+
+// Rotate renews the certificate when it's due and switches the served certificate to the one in the Secret.
+// The webhook configurations get the CA bundle before the certificate is switched, the bundle trusts both
+// the previous and the new CA, so admissions don't fail while the replicas switch.
+func (r *CertificateRotator) Rotate(ctx context.Context) error {
+	secret, err := ensureCertificateSecret(ctx, r.client, r.opts)
+	if err != nil {
+		return errors.Wrap(err, "failed to ensure serving certificate")
+	}
+	config := r.webhookConfig
+	config.CABundel = secret.Data[CACertFile]
+	if err := EnsureWebhookConfigurationFor(ctx, r.client, config, MutatingWebhook); err != nil {
+		return errors.Wrap(err, "failed to ensure defaulting webhook configuration")
+	}
+	if err := EnsureWebhookConfigurationFor(ctx, r.client, config, ValidatingWebHook); err != nil {
+		return errors.Wrap(err, "failed to ensure validating webhook configuration")
+	}
+
+	loaded, err := r.load(secret.Data[CertFile], secret.Data[KeyFile])
+	if err != nil {
+		return err
+	}
+	if loaded {
+		r.log.With("notAfter", r.current.Load().Leaf.NotAfter).Info("serving certificate loaded")
+	}
+	return nil
+}
+
+// Start rotates the certificate and keeps checking it until the context is done, it implements manager.Runnable.
+func (r *CertificateRotator) Start(ctx context.Context) error {
+	if err := r.Rotate(ctx); err != nil {
+		return err
+	}
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			// the current certificate stays served, the next check retries
+			if err := r.Rotate(ctx); err != nil {
+				r.log.Error("failed to rotate serving certificate ", err.Error())
+			}
+		}
+	}
+}
+
+// NeedLeaderElection is false, every replica has to serve the current certificate.
+func (r *CertificateRotator) NeedLeaderElection() bool {
+	return false
+}
@@ -0,0 +1,173 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/cert"
+	testingclock "k8s.io/utils/clock/testing"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestCertificateRotator(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	newRotator := func(client ctrlclient.Client, clk *testingclock.FakeClock) *CertificateRotator {
+		return NewCertificateRotator(client, CertificateOptions{
+			SecretName:       "warden-webhook-cert",
+			ServiceName:      "warden-admission",
+			ServiceNamespace: "kyma-system",
+			Validity:         30 * 24 * time.Hour,
+			Clock:            clk,
+		}, WebhookConfig{}, time.Minute, zap.NewNop().Sugar())
+	}
+	caBundles := func(t *testing.T, client ctrlclient.Client) ([]byte, []byte) {
+		mutating := &admissionregistrationv1.MutatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: DefaultingWebhookName}, mutating))
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, validating))
+		return mutating.Webhooks[0].ClientConfig.CABundle, validating.Webhooks[0].ClientConfig.CABundle
+	}
+	served := func(t *testing.T, r *CertificateRotator) *x509.Certificate {
+		certificate, err := r.GetCertificate(nil)
+		require.NoError(t, err)
+		return certificate.Leaf
+	}
+	verify := func(c *x509.Certificate, bundle []byte, at time.Time) error {
+		roots, err := cert.NewPoolFromBytes(bundle)
+		if err != nil {
+			return err
+		}
+		_, err = c.Verify(x509.VerifyOptions{DNSName: "warden-admission.kyma-system.svc", Roots: roots, CurrentTime: at})
+		return err
+	}
+
+	t.Run("serves the certificate trusted by the webhook configurations", func(t *testing.T) {
+		//GIVEN
+		client := newFakeClient()
+		r := newRotator(client, testingclock.NewFakeClock(start))
+		_, err := r.GetCertificate(nil)
+		require.Error(t, err)
+
+		//WHEN
+		require.NoError(t, r.Rotate(context.TODO()))
+
+		//THEN
+		mutatingBundle, validatingBundle := caBundles(t, client)
+		require.Equal(t, mutatingBundle, validatingBundle)
+		require.NoError(t, verify(served(t, r), mutatingBundle, start))
+	})
+
+	t.Run("keeps the certificate before the renewal threshold", func(t *testing.T) {
+		//GIVEN
+		client := newFakeClient()
+		clk := testingclock.NewFakeClock(start)
+		r := newRotator(client, clk)
+		require.NoError(t, r.Rotate(context.TODO()))
+		before, _ := r.GetCertificate(nil)
+
+		//WHEN
+		clk.Step(19 * 24 * time.Hour)
+		require.NoError(t, r.Rotate(context.TODO()))
+
+		//THEN
+		after, _ := r.GetCertificate(nil)
+		require.Same(t, before, after)
+	})
+
+	t.Run("old certificate stays trusted during the overlap", func(t *testing.T) {
+		//GIVEN
+		client := newFakeClient()
+		clk := testingclock.NewFakeClock(start)
+		r := newRotator(client, clk)
+		// another replica which didn't switch to the renewed certificate yet
+		lagging := newRotator(client, clk)
+		require.NoError(t, r.Rotate(context.TODO()))
+		require.NoError(t, lagging.Rotate(context.TODO()))
+		old := served(t, r)
+		require.Equal(t, old.SerialNumber, served(t, lagging).SerialNumber)
+
+		//WHEN
+		clk.Step(21 * 24 * time.Hour)
+		require.NoError(t, r.Rotate(context.TODO()))
+
+		//THEN
+		renewed := served(t, r)
+		require.NotEqual(t, old.SerialNumber, renewed.SerialNumber)
+		require.Equal(t, clk.Now().Add(30*24*time.Hour), renewed.NotAfter)
+
+		bundle, _ := caBundles(t, client)
+		cas, err := cert.ParseCertsPEM(bundle)
+		require.NoError(t, err)
+		require.Len(t, cas, 2)
+		require.NoError(t, verify(renewed, bundle, clk.Now()))
+		require.NoError(t, verify(served(t, lagging), bundle, clk.Now()))
+
+		// the lagging replica switches on its next check
+		require.NoError(t, lagging.Rotate(context.TODO()))
+		require.Equal(t, renewed.SerialNumber, served(t, lagging).SerialNumber)
+
+		// the CA of the old certificate is dropped once it expired
+		clk.Step(20 * 24 * time.Hour)
+		require.NoError(t, r.Rotate(context.TODO()))
+		bundle, _ = caBundles(t, client)
+		cas, err = cert.ParseCertsPEM(bundle)
+		require.NoError(t, err)
+		require.Len(t, cas, 2)
+		require.Error(t, verify(old, bundle, clk.Now()))
+		require.NoError(t, verify(served(t, r), bundle, clk.Now()))
+	})
+
+	t.Run("TLS server picks up the renewed certificate without restart", func(t *testing.T) {
+		//GIVEN
+		client := newFakeClient()
+		clk := testingclock.NewFakeClock(start)
+		r := newRotator(client, clk)
+		require.NoError(t, r.Rotate(context.TODO()))
+		serverConfig := &tls.Config{}
+		r.TLSOpt(serverConfig)
+		listener, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+		require.NoError(t, err)
+		defer listener.Close()
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				_ = conn.(*tls.Conn).Handshake()
+				conn.Close()
+			}
+		}()
+		handshake := func() *x509.Certificate {
+			bundle, _ := caBundles(t, client)
+			roots, err := cert.NewPoolFromBytes(bundle)
+			require.NoError(t, err)
+			conn, err := tls.DialWithDialer(&net.Dialer{Timeout: time.Second}, "tcp", listener.Addr().String(), &tls.Config{
+				RootCAs:    roots,
+				ServerName: "warden-admission.kyma-system.svc",
+				Time:       clk.Now,
+			})
+			require.NoError(t, err)
+			defer conn.Close()
+			return conn.ConnectionState().PeerCertificates[0]
+		}
+		before := handshake()
+
+		//WHEN
+		clk.Step(21 * 24 * time.Hour)
+		require.NoError(t, r.Rotate(context.TODO()))
+
+		//THEN
+		after := handshake()
+		require.NotEqual(t, before.SerialNumber, after.SerialNumber)
+		require.Equal(t, served(t, r).SerialNumber, after.SerialNumber)
+	})
+}
@@ -0,0 +1,46 @@
+package certs
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// servingCertificate holds the certificate the webhook server presents, it's replaced without restarting the server.
+type servingCertificate struct {
+	current atomic.Pointer[tls.Certificate]
+}
+
+// load switches to the PEM encoded certificate and key, it returns false when they are already served.
+func (s *servingCertificate) load(certPEM, keyPEM []byte) (bool, error) {
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to load serving certificate")
+	}
+	if current := s.current.Load(); current != nil && bytes.Equal(current.Certificate[0], certificate.Certificate[0]) {
+		return false, nil
+	}
+	if certificate.Leaf, err = x509.ParseCertificate(certificate.Certificate[0]); err != nil {
+		return false, errors.Wrap(err, "failed to parse serving certificate")
+	}
+	s.current.Store(&certificate)
+	servingCertificateNotAfter.Set(float64(certificate.Leaf.NotAfter.Unix()))
+	return true, nil
+}
+
+// GetCertificate returns the current serving certificate, it's meant for tls.Config.
+func (s *servingCertificate) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	certificate := s.current.Load()
+	if certificate == nil {
+		return nil, errors.New("serving certificate isn't loaded yet")
+	}
+	return certificate, nil
+}
+
+// TLSOpt makes the webhook server serve the current certificate, e.g. in webhook.Server.TLSOpts.
+func (s *servingCertificate) TLSOpt(config *tls.Config) {
+	config.GetCertificate = s.GetCertificate
+}
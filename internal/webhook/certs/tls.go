@@ -0,0 +1,78 @@
+package certs
+
+import (
+	"crypto/tls"
+	"fmt"
+
+	"github.com/pkg/errors"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// DefaultTLSMinVersion is the oldest TLS version the webhook server accepts.
+const DefaultTLSMinVersion = "1.2"
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSConfig restricts the TLS versions and cipher suites of the webhook server, which accepts TLS 1.0
+// with the defaults of the webhook server.
+type TLSConfig struct {
+	// MinVersion is 1.2 or 1.3, DefaultTLSMinVersion when it's empty.
+	MinVersion string
+	// CipherSuites are the names of the TLS 1.2 cipher suites the server accepts, e.g.
+	// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, the secure suites of Go when it's empty. TLS 1.3 suites can't be
+	// configured. Go chooses the order of the suites itself, it prefers the ones with hardware support.
+	CipherSuites []string
+}
+
+// Validate rejects unknown versions and suites, the insecure suites and the suites with TLS 1.3 only.
+func (c TLSConfig) Validate() error {
+	var errs []error
+	if _, ok := tlsVersions[c.minVersion()]; !ok {
+		errs = append(errs, fmt.Errorf("unsupported TLS min version %s, supported are 1.2 and 1.3", c.MinVersion))
+	}
+	if c.minVersion() == "1.3" && len(c.CipherSuites) > 0 {
+		errs = append(errs, errors.New("TLS cipher suites can't be used with the TLS min version 1.3"))
+	}
+	for _, name := range c.CipherSuites {
+		if _, ok := tls12CipherSuites()[name]; !ok {
+			errs = append(errs, fmt.Errorf("unsupported TLS cipher suite %s", name))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// TLSOpt applies the config to the webhook server, e.g. in webhook.Server.TLSOpts, the config must be valid.
+func (c TLSConfig) TLSOpt(config *tls.Config) {
+	config.MinVersion = tlsVersions[c.minVersion()]
+	if len(c.CipherSuites) == 0 {
+		return
+	}
+	suites := tls12CipherSuites()
+	config.CipherSuites = make([]uint16, 0, len(c.CipherSuites))
+	for _, name := range c.CipherSuites {
+		config.CipherSuites = append(config.CipherSuites, suites[name])
+	}
+}
+
+func (c TLSConfig) minVersion() string {
+	if c.MinVersion == "" {
+		return DefaultTLSMinVersion
+	}
+	return c.MinVersion
+}
+
+// tls12CipherSuites returns the IDs of the secure suites which can be used with TLS 1.2 by their names.
+func tls12CipherSuites() map[string]uint16 {
+	suites := map[string]uint16{}
+	for _, suite := range tls.CipherSuites() {
+		for _, version := range suite.SupportedVersions {
+			if version == tls.VersionTLS12 {
+				suites[suite.Name] = suite.ID
+			}
+		}
+	}
+	return suites
+}
@@ -0,0 +1,129 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/util/cert"
+)
+
+func TestTLSConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      TLSConfig
+		expectedErr string
+	}{
+		{name: "defaults"},
+		{name: "TLS 1.3 only", config: TLSConfig{MinVersion: "1.3"}},
+		{
+			name:   "cipher suites",
+			config: TLSConfig{MinVersion: "1.2", CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}},
+		},
+		{
+			name:        "old version",
+			config:      TLSConfig{MinVersion: "1.1"},
+			expectedErr: "unsupported TLS min version 1.1, supported are 1.2 and 1.3",
+		},
+		{
+			name:        "TLS 1.3 only with cipher suites",
+			config:      TLSConfig{MinVersion: "1.3", CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}},
+			expectedErr: "TLS cipher suites can't be used with the TLS min version 1.3",
+		},
+		{
+			name:        "insecure cipher suite",
+			config:      TLSConfig{CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}},
+			expectedErr: "unsupported TLS cipher suite TLS_RSA_WITH_RC4_128_SHA",
+		},
+		{
+			name:        "TLS 1.3 cipher suite",
+			config:      TLSConfig{CipherSuites: []string{"TLS_AES_128_GCM_SHA256"}},
+			expectedErr: "unsupported TLS cipher suite TLS_AES_128_GCM_SHA256",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+
+			if tt.expectedErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestTLSConfig_TLSOpt(t *testing.T) {
+	const host = "warden-admission.kyma-system.svc"
+	certPEM, keyPEM, err := cert.GenerateSelfSignedCertKey(host, nil, nil)
+	require.NoError(t, err)
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM(certPEM))
+	startServer := func(t *testing.T, config TLSConfig) string {
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+		server.TLS = &tls.Config{Certificates: []tls.Certificate{certificate}}
+		config.TLSOpt(server.TLS)
+		server.StartTLS()
+		t.Cleanup(server.Close)
+		return server.Listener.Addr().String()
+	}
+	handshake := func(addr string, client *tls.Config) error {
+		client.RootCAs, client.ServerName = roots, host
+		conn, err := tls.Dial("tcp", addr, client)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	t.Run("default rejects TLS 1.1", func(t *testing.T) {
+		//GIVEN
+		addr := startServer(t, TLSConfig{})
+
+		//WHEN
+		oldErr := handshake(addr, &tls.Config{MinVersion: tls.VersionTLS10, MaxVersion: tls.VersionTLS11})
+		tls12Err := handshake(addr, &tls.Config{MaxVersion: tls.VersionTLS12})
+
+		//THEN
+		require.Error(t, oldErr)
+		require.NoError(t, tls12Err)
+	})
+
+	t.Run("TLS 1.3 only rejects TLS 1.2", func(t *testing.T) {
+		//GIVEN
+		addr := startServer(t, TLSConfig{MinVersion: "1.3"})
+
+		//WHEN
+		tls12Err := handshake(addr, &tls.Config{MaxVersion: tls.VersionTLS12})
+		tls13Err := handshake(addr, &tls.Config{MinVersion: tls.VersionTLS13})
+
+		//THEN
+		require.Error(t, tls12Err)
+		require.NoError(t, tls13Err)
+	})
+
+	t.Run("only the configured cipher suites are accepted", func(t *testing.T) {
+		//GIVEN
+		addr := startServer(t, TLSConfig{CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"}})
+
+		//WHEN
+		otherErr := handshake(addr, &tls.Config{
+			MaxVersion:   tls.VersionTLS12,
+			CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+		})
+		configuredErr := handshake(addr, &tls.Config{
+			MaxVersion:   tls.VersionTLS12,
+			CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384},
+		})
+
+		//THEN
+		require.Error(t, otherErr)
+		require.NoError(t, configuredErr)
+	})
+}
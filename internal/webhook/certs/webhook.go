@@ -2,15 +2,21 @@ package certs
 
 import (
 	"context"
-	"github.com/kyma-project/warden/internal/admission"
-	"reflect"
+	"fmt"
+	"strings"
 
+	"github.com/kyma-project/warden/internal/admission"
+	"github.com/kyma-project/warden/pkg"
 	"github.com/pkg/errors"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apiErrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
 	"k8s.io/utils/pointer"
 	ctlrclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -23,56 +29,223 @@ const (
 
 	DefaultingWebhookName = "defaulting.webhook.warden.kyma-project.io"
 	ValidationWebhookName = "validation.webhook.warden.kyma-project.io"
+	// WorkloadValidationWebhookName is the webhook of the validation configuration for workloads.
+	WorkloadValidationWebhookName = "workloads.validation.webhook.warden.kyma-project.io"
+	// NamespaceValidationWebhookName is the webhook of the validation configuration which protects the namespace label.
+	NamespaceValidationWebhookName = "namespaces.validation.webhook.warden.kyma-project.io"
 
 	WebhookTimeout = 15
 
+	DefaultServicePort = 443
+
+	// DefaultMatchPolicy lets the webhooks match pods also when the API server serves them in another
+	// group or version than the rules list, Exact has to be configured explicitly.
+	DefaultMatchPolicy = admissionregistrationv1.Equivalent
+
 	PodValidationPath = "/validation/pods"
+
+	// FieldManager owns the fields of the webhook configurations applied by Warden.
+	FieldManager = "warden"
+)
+
+// EnsureResult tells what ensuring a webhook configuration did.
+type EnsureResult string
+
+const (
+	EnsureCreated   EnsureResult = "created"
+	EnsureUpdated   EnsureResult = "updated"
+	EnsureUnchanged EnsureResult = "unchanged"
 )
 
 func EnsureWebhookConfigurationFor(ctx context.Context, client ctlrclient.Client, config WebhookConfig, wt WebHookType) error {
+	_, err := ensureWebhookConfigurationFor(ctx, client, config, wt)
+	return err
+}
+
+// ensureWebhookConfigurationFor returns whether it created, changed or kept the webhook configuration
+// and records it in the metrics.
+func ensureWebhookConfigurationFor(ctx context.Context, client ctlrclient.Client, config WebhookConfig, wt WebHookType) (EnsureResult, error) {
+	result, err := ensureWebhookConfiguration(ctx, client, config, wt)
+	recordEnsure(wt, result, err)
+	return result, err
+}
+
+func ensureWebhookConfiguration(ctx context.Context, client ctlrclient.Client, config WebhookConfig, wt WebHookType) (EnsureResult, error) {
+	if err := config.Validate(); err != nil {
+		return EnsureUnchanged, errors.Wrap(err, "invalid webhook configuration")
+	}
+	gvk := admissionregistrationv1.SchemeGroupVersion.WithKind("ValidatingWebhookConfiguration")
+	name := config.webhookName(ValidationWebhookName)
+	ensure := ensureValidatingWebhookConfigFor
 	if wt == MutatingWebhook {
-		return ensureMutatingWebhookConfigFor(ctx, client, config)
+		gvk = admissionregistrationv1.SchemeGroupVersion.WithKind("MutatingWebhookConfiguration")
+		name = config.webhookName(DefaultingWebhookName)
+		ensure = ensureMutatingWebhookConfigFor
+	}
+	var result EnsureResult
+	// another replica may write the configuration at the same time, every attempt reads it again
+	err := retry.OnError(retry.DefaultRetry, isConcurrentWrite, func() (err error) {
+		result, err = ensure(ctx, client, config)
+		return err
+	})
+	if err != nil {
+		return result, err
 	}
-	return ensureValidatingWebhookConfigFor(ctx, client, config)
+	patched, err := ensureMatchConditions(ctx, client, gvk, name, config.MatchConditions)
+	if patched && result == EnsureUnchanged {
+		result = EnsureUpdated
+	}
+	return result, err
 }
 
-func ensureMutatingWebhookConfigFor(ctx context.Context, client ctlrclient.Client, config WebhookConfig) error {
+func ensureMutatingWebhookConfigFor(ctx context.Context, client ctlrclient.Client, config WebhookConfig) (EnsureResult, error) {
+	ensuredMwhc := createMutatingWebhookConfiguration(config)
 	mwhc := &admissionregistrationv1.MutatingWebhookConfiguration{}
-	if err := client.Get(ctx, types.NamespacedName{Name: DefaultingWebhookName}, mwhc); err != nil {
+	if err := client.Get(ctx, types.NamespacedName{Name: ensuredMwhc.Name}, mwhc); err != nil {
 		if apiErrors.IsNotFound(err) {
-			return errors.Wrap(client.Create(ctx, createMutatingWebhookConfiguration(config)), "while creating webhook mutation configuration")
+			return EnsureCreated, errors.Wrap(applyWebhookConfiguration(ctx, client, ensuredMwhc, config), "while creating webhook mutation configuration")
+		}
+		return EnsureUnchanged, errors.Wrapf(err, "failed to get defaulting MutatingWebhookConfiguration: %s", ensuredMwhc.Name)
+	}
+	if config.CertManagerCertificate != "" || config.injectsCABundle() {
+		injected := map[string][]byte{}
+		for _, webhook := range mwhc.Webhooks {
+			injected[webhook.Name] = webhook.ClientConfig.CABundle
+		}
+		for i := range ensuredMwhc.Webhooks {
+			clientConfig := &ensuredMwhc.Webhooks[i].ClientConfig
+			clientConfig.CABundle = injectedCABundle(config, clientConfig.CABundle, injected[ensuredMwhc.Webhooks[i].Name])
 		}
-		return errors.Wrapf(err, "failed to get defaulting MutatingWebhookConfiguration: %s", DefaultingWebhookName)
 	}
-	ensuredMwhc := createMutatingWebhookConfiguration(config)
 
-	if !reflect.DeepEqual(ensuredMwhc.Webhooks, mwhc.Webhooks) {
-		ensuredMwhc.ObjectMeta = *mwhc.ObjectMeta.DeepCopy()
-		return errors.Wrap(client.Update(ctx, ensuredMwhc), "while updating webhook mutation configuration")
+	metadataChanged := ensureMetadata(mwhc.ObjectMeta.DeepCopy(), config)
+	if !metadataChanged && equality.Semantic.DeepEqual(ensuredMwhc.Webhooks, mwhc.Webhooks) {
+		return EnsureUnchanged, nil
+	}
+	if err := removeInjectCAAnnotation(ctx, client, mwhc, config); err != nil {
+		return EnsureUnchanged, err
 	}
-	return nil
+	return EnsureUpdated, errors.Wrap(applyWebhookConfiguration(ctx, client, ensuredMwhc, config), "while updating webhook mutation configuration")
 }
 
-func ensureValidatingWebhookConfigFor(ctx context.Context, client ctlrclient.Client, config WebhookConfig) error {
+func ensureValidatingWebhookConfigFor(ctx context.Context, client ctlrclient.Client, config WebhookConfig) (EnsureResult, error) {
+	ensuredVwhc := createValidatingWebhookConfiguration(config)
 	vwhc := &admissionregistrationv1.ValidatingWebhookConfiguration{}
-	if err := client.Get(ctx, types.NamespacedName{Name: ValidationWebhookName}, vwhc); err != nil {
+	if err := client.Get(ctx, types.NamespacedName{Name: ensuredVwhc.Name}, vwhc); err != nil {
 		if apiErrors.IsNotFound(err) {
-			return client.Create(ctx, createValidatingWebhookConfiguration(config))
+			return EnsureCreated, errors.Wrap(applyWebhookConfiguration(ctx, client, ensuredVwhc, config), "while creating webhook validation configuration")
 		}
-		return errors.Wrapf(err, "failed to get validation ValidatingWebhookConfiguration: %s", ValidationWebhookName)
+		return EnsureUnchanged, errors.Wrapf(err, "failed to get validation ValidatingWebhookConfiguration: %s", ensuredVwhc.Name)
 	}
-	ensuredVwhc := createValidatingWebhookConfiguration(config)
-	if !reflect.DeepEqual(ensuredVwhc.Webhooks, vwhc.Webhooks) {
-		ensuredVwhc.ObjectMeta = *vwhc.ObjectMeta.DeepCopy()
-		return client.Update(ctx, ensuredVwhc)
+	if config.CertManagerCertificate != "" || config.injectsCABundle() {
+		injected := map[string][]byte{}
+		for _, webhook := range vwhc.Webhooks {
+			injected[webhook.Name] = webhook.ClientConfig.CABundle
+		}
+		for i := range ensuredVwhc.Webhooks {
+			clientConfig := &ensuredVwhc.Webhooks[i].ClientConfig
+			clientConfig.CABundle = injectedCABundle(config, clientConfig.CABundle, injected[ensuredVwhc.Webhooks[i].Name])
+		}
 	}
-	return nil
+
+	metadataChanged := ensureMetadata(vwhc.ObjectMeta.DeepCopy(), config)
+	if !metadataChanged && equality.Semantic.DeepEqual(ensuredVwhc.Webhooks, vwhc.Webhooks) {
+		return EnsureUnchanged, nil
+	}
+	if err := removeInjectCAAnnotation(ctx, client, vwhc, config); err != nil {
+		return EnsureUnchanged, err
+	}
+	return EnsureUpdated, errors.Wrap(applyWebhookConfiguration(ctx, client, ensuredVwhc, config), "while updating webhook validation configuration")
+}
+
+func isConcurrentWrite(err error) bool {
+	return apiErrors.IsConflict(err) || apiErrors.IsAlreadyExists(err)
+}
+
+// applyWebhookConfiguration applies the webhooks, labels and annotations of Warden with server-side apply,
+// so the fields of other managers, e.g. labels of GitOps tools, are kept. Conflicts are forced only
+// for the fields of the applied configuration. The configuration is applied in the dry-run mode first,
+// unless it's skipped, so the fields the API server rejects are reported before anything is written.
+func applyWebhookConfiguration(ctx context.Context, client ctlrclient.Client, configuration ctlrclient.Object, config WebhookConfig) error {
+	if !config.SkipDryRun {
+		dryRun := configuration.DeepCopyObject().(ctlrclient.Object)
+		err := client.Patch(ctx, dryRun, ctlrclient.Apply, ctlrclient.FieldOwner(FieldManager), ctlrclient.ForceOwnership, ctlrclient.DryRunAll)
+		if err != nil {
+			return invalidFieldsError(configuration, err)
+		}
+	}
+	return client.Patch(ctx, configuration, ctlrclient.Apply, ctlrclient.FieldOwner(FieldManager), ctlrclient.ForceOwnership)
+}
+
+// invalidFieldsError lists the fields the API server rejected in the dry run, other errors are only wrapped.
+func invalidFieldsError(configuration ctlrclient.Object, err error) error {
+	var status apiErrors.APIStatus
+	if !apiErrors.IsInvalid(err) || !errors.As(err, &status) || status.Status().Details == nil {
+		return errors.Wrapf(err, "while applying %s in the dry-run mode", configuration.GetName())
+	}
+	fields := make([]string, 0, len(status.Status().Details.Causes))
+	for _, cause := range status.Status().Details.Causes {
+		fields = append(fields, fmt.Sprintf("%s: %s", cause.Field, cause.Message))
+	}
+	return fmt.Errorf("webhook configuration %s is rejected by the API server: %s", configuration.GetName(), strings.Join(fields, ", "))
+}
+
+// removeInjectCAAnnotation removes the cert-manager annotation in the self-managed mode explicitly,
+// apply removes only the fields Warden applied and the annotation may be set by an update of an older version.
+func removeInjectCAAnnotation(ctx context.Context, client ctlrclient.Client, configuration ctlrclient.Object, config WebhookConfig) error {
+	if _, ok := configuration.GetAnnotations()[CertManagerInjectCAAnnotation]; !ok || config.CertManagerCertificate != "" {
+		return nil
+	}
+	patch := []byte(`{"metadata":{"annotations":{"` + CertManagerInjectCAAnnotation + `":null}}}`)
+	return errors.Wrapf(client.Patch(ctx, configuration, ctlrclient.RawPatch(types.MergePatchType, patch), ctlrclient.FieldOwner(FieldManager)),
+		"while removing %s annotation", CertManagerInjectCAAnnotation)
+}
+
+// ensureInjectCAAnnotation sets the cert-manager annotation in the cert-manager mode and removes it otherwise,
+// so the CA injector doesn't overwrite the CA bundle set by Warden. It returns true when the annotations changed.
+func ensureInjectCAAnnotation(meta *metav1.ObjectMeta, config WebhookConfig) bool {
+	current, ok := meta.Annotations[CertManagerInjectCAAnnotation]
+	if config.CertManagerCertificate == "" {
+		delete(meta.Annotations, CertManagerInjectCAAnnotation)
+		return ok
+	}
+	if ok && current == config.CertManagerCertificate {
+		return false
+	}
+	if meta.Annotations == nil {
+		meta.Annotations = map[string]string{}
+	}
+	meta.Annotations[CertManagerInjectCAAnnotation] = config.CertManagerCertificate
+	return true
+}
+
+// injectedCABundle keeps the CA bundle injected by cert-manager, or by the controllers which follow
+// the CA bundle source unless the webhook has none yet.
+func injectedCABundle(config WebhookConfig, ensured, injected []byte) []byte {
+	if config.CertManagerCertificate != "" || len(injected) > 0 {
+		return injected
+	}
+	return ensured
+}
+
+// webhookCABundle is empty in the cert-manager mode, cert-manager injects it.
+func webhookCABundle(config WebhookConfig) []byte {
+	if config.CertManagerCertificate != "" {
+		return nil
+	}
+	return config.CABundel
 }
 
 func createMutatingWebhookConfiguration(config WebhookConfig) *admissionregistrationv1.MutatingWebhookConfiguration {
 	return &admissionregistrationv1.MutatingWebhookConfiguration{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: admissionregistrationv1.SchemeGroupVersion.String(),
+			Kind:       "MutatingWebhookConfiguration",
+		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name: DefaultingWebhookName,
+			Name:        config.webhookName(DefaultingWebhookName),
+			Labels:      webhookLabels(config),
+			Annotations: webhookAnnotations(config),
 		},
 		Webhooks: []admissionregistrationv1.MutatingWebhook{
 			getFunctionMutatingWebhookCfg(config),
@@ -81,30 +254,21 @@ func createMutatingWebhookConfiguration(config WebhookConfig) *admissionregistra
 }
 
 func getFunctionMutatingWebhookCfg(config WebhookConfig) admissionregistrationv1.MutatingWebhook {
-	failurePolicy := admissionregistrationv1.Ignore
-	matchPolicy := admissionregistrationv1.Exact
-	reinvocationPolicy := admissionregistrationv1.NeverReinvocationPolicy
-	scope := admissionregistrationv1.AllScopes
+	failurePolicy := config.failurePolicy()
+	matchPolicy := config.matchPolicy()
+	reinvocationPolicy := config.reinvocationPolicy()
+	scope := config.scope()
 	sideEffects := admissionregistrationv1.SideEffectClassNone
 
 	return admissionregistrationv1.MutatingWebhook{
-		Name: DefaultingWebhookName,
-		AdmissionReviewVersions: []string{
-			"v1beta1",
-			"v1",
-		},
-		ClientConfig: admissionregistrationv1.WebhookClientConfig{
-			CABundle: config.CABundel,
-			Service: &admissionregistrationv1.ServiceReference{
-				Namespace: config.ServiceNamespace,
-				Name:      config.ServiceName,
-				Path:      pointer.String(admission.DefaultingPath),
-				Port:      pointer.Int32(443),
-			},
-		},
-		FailurePolicy:      &failurePolicy,
-		MatchPolicy:        &matchPolicy,
-		ReinvocationPolicy: &reinvocationPolicy,
+		Name:                    config.webhookName(DefaultingWebhookName),
+		AdmissionReviewVersions: config.admissionReviewVersions(),
+		ClientConfig:            config.clientConfig(admission.DefaultingPath),
+		FailurePolicy:           &failurePolicy,
+		MatchPolicy:             &matchPolicy,
+		NamespaceSelector:       config.namespaceSelector(),
+		ObjectSelector:          config.objectSelector(),
+		ReinvocationPolicy:      &reinvocationPolicy,
 		Rules: []admissionregistrationv1.RuleWithOperations{
 			{
 				Rule: admissionregistrationv1.Rule{
@@ -115,45 +279,39 @@ func getFunctionMutatingWebhookCfg(config WebhookConfig) admissionregistrationv1
 					Resources:   []string{string(corev1.ResourcePods)},
 					Scope:       &scope,
 				},
-				Operations: []admissionregistrationv1.OperationType{
-					admissionregistrationv1.Create,
-					admissionregistrationv1.Update,
-				},
+				Operations: config.mutatingOperations(),
 			},
 		},
 		SideEffects:    &sideEffects,
-		TimeoutSeconds: pointer.Int32(WebhookTimeout),
+		TimeoutSeconds: pointer.Int32(config.timeoutSeconds()),
 	}
 }
 
 func createValidatingWebhookConfiguration(config WebhookConfig) *admissionregistrationv1.ValidatingWebhookConfiguration {
-	failurePolicy := admissionregistrationv1.Ignore
-	matchPolicy := admissionregistrationv1.Exact
-	scope := admissionregistrationv1.AllScopes
+	failurePolicy := config.failurePolicy()
+	matchPolicy := config.matchPolicy()
+	scope := config.scope()
 	sideEffects := admissionregistrationv1.SideEffectClassNone
 
-	return &admissionregistrationv1.ValidatingWebhookConfiguration{
+	configuration := &admissionregistrationv1.ValidatingWebhookConfiguration{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: admissionregistrationv1.SchemeGroupVersion.String(),
+			Kind:       "ValidatingWebhookConfiguration",
+		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name: ValidationWebhookName,
+			Name:        config.webhookName(ValidationWebhookName),
+			Labels:      webhookLabels(config),
+			Annotations: webhookAnnotations(config),
 		},
 		Webhooks: []admissionregistrationv1.ValidatingWebhook{
 			{
-				Name: ValidationWebhookName,
-				AdmissionReviewVersions: []string{
-					"v1beta1",
-					"v1",
-				},
-				ClientConfig: admissionregistrationv1.WebhookClientConfig{
-					CABundle: config.CABundel,
-					Service: &admissionregistrationv1.ServiceReference{
-						Namespace: config.ServiceNamespace,
-						Name:      config.ServiceName,
-						Path:      pointer.String(PodValidationPath),
-						Port:      pointer.Int32(443),
-					},
-				},
-				FailurePolicy: &failurePolicy,
-				MatchPolicy:   &matchPolicy,
+				Name:                    config.webhookName(ValidationWebhookName),
+				AdmissionReviewVersions: config.admissionReviewVersions(),
+				ClientConfig:            config.clientConfig(PodValidationPath),
+				FailurePolicy:           &failurePolicy,
+				MatchPolicy:             &matchPolicy,
+				NamespaceSelector:       config.namespaceSelector(),
+				ObjectSelector:          config.objectSelector(),
 				Rules: []admissionregistrationv1.RuleWithOperations{
 					{
 						Rule: admissionregistrationv1.Rule{
@@ -164,15 +322,113 @@ func createValidatingWebhookConfiguration(config WebhookConfig) *admissionregist
 							Resources:   []string{string(corev1.ResourcePods)},
 							Scope:       &scope,
 						},
-						Operations: []admissionregistrationv1.OperationType{
-							admissionregistrationv1.Create,
-							admissionregistrationv1.Update,
-						}},
+						Operations: config.validatingOperations(),
+					},
 				},
 
 				SideEffects:    &sideEffects,
-				TimeoutSeconds: pointer.Int32(WebhookTimeout),
+				TimeoutSeconds: pointer.Int32(config.timeoutSeconds()),
 			},
 		},
 	}
+	if !config.SkipEphemeralContainers {
+		configuration.Webhooks[0].Rules = append(configuration.Webhooks[0].Rules, ephemeralContainersRule(config))
+	}
+	configuration.Webhooks = append(configuration.Webhooks, getNamespaceValidatingWebhookCfg(config))
+	if config.ValidateWorkloads {
+		configuration.Webhooks = append(configuration.Webhooks, getWorkloadValidatingWebhookCfg(config))
+	}
+	return configuration
+}
+
+// ephemeralContainersRule lets the pod webhook validate the containers added by kubectl debug,
+// the subresource supports only UPDATE.
+func ephemeralContainersRule(config WebhookConfig) admissionregistrationv1.RuleWithOperations {
+	scope := config.scope()
+	return admissionregistrationv1.RuleWithOperations{
+		Rule: admissionregistrationv1.Rule{
+			APIGroups:   []string{corev1.GroupName},
+			APIVersions: []string{corev1.SchemeGroupVersion.Version},
+			Resources:   []string{string(corev1.ResourcePods) + "/" + admission.EphemeralContainersSubResource},
+			Scope:       &scope,
+		},
+		Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Update},
+	}
+}
+
+// getWorkloadValidatingWebhookCfg rejects workloads whose pod templates have invalid images. It doesn't have
+// the object selector, which selects pods.
+func getWorkloadValidatingWebhookCfg(config WebhookConfig) admissionregistrationv1.ValidatingWebhook {
+	failurePolicy := config.failurePolicy()
+	matchPolicy := config.matchPolicy()
+	scope := admissionregistrationv1.NamespacedScope
+	sideEffects := admissionregistrationv1.SideEffectClassNone
+
+	return admissionregistrationv1.ValidatingWebhook{
+		Name:                    config.webhookName(WorkloadValidationWebhookName),
+		AdmissionReviewVersions: config.admissionReviewVersions(),
+		ClientConfig:            config.clientConfig(admission.WorkloadValidationPath),
+		FailurePolicy:           &failurePolicy,
+		MatchPolicy:             &matchPolicy,
+		NamespaceSelector:       config.namespaceSelector(),
+		ObjectSelector:          &metav1.LabelSelector{},
+		Rules: []admissionregistrationv1.RuleWithOperations{
+			{
+				Rule: admissionregistrationv1.Rule{
+					APIGroups:   []string{appsv1.GroupName},
+					APIVersions: []string{appsv1.SchemeGroupVersion.Version},
+					Resources:   []string{"deployments", "statefulsets", "daemonsets"},
+					Scope:       &scope,
+				},
+				Operations: config.validatingOperations(),
+			},
+			{
+				Rule: admissionregistrationv1.Rule{
+					APIGroups:   []string{batchv1.GroupName},
+					APIVersions: []string{batchv1.SchemeGroupVersion.Version},
+					Resources:   []string{"jobs", "cronjobs"},
+					Scope:       &scope,
+				},
+				Operations: config.validatingOperations(),
+			},
+		},
+		SideEffects:    &sideEffects,
+		TimeoutSeconds: pointer.Int32(config.timeoutSeconds()),
+	}
+}
+
+// getNamespaceValidatingWebhookCfg protects the validation label of namespaces. The object selector matches
+// also updates which remove the label, the API server evaluates it for the old and the new namespace.
+func getNamespaceValidatingWebhookCfg(config WebhookConfig) admissionregistrationv1.ValidatingWebhook {
+	failurePolicy := config.failurePolicy()
+	matchPolicy := config.matchPolicy()
+	scope := admissionregistrationv1.ClusterScope
+	sideEffects := admissionregistrationv1.SideEffectClassNone
+
+	return admissionregistrationv1.ValidatingWebhook{
+		Name:                    config.webhookName(NamespaceValidationWebhookName),
+		AdmissionReviewVersions: config.admissionReviewVersions(),
+		ClientConfig:            config.clientConfig(admission.NamespaceValidationPath),
+		FailurePolicy:           &failurePolicy,
+		MatchPolicy:             &matchPolicy,
+		NamespaceSelector:       config.namespaceSelector(),
+		ObjectSelector: &metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: pkg.NamespaceValidationLabel, Operator: metav1.LabelSelectorOpExists},
+			},
+		},
+		Rules: []admissionregistrationv1.RuleWithOperations{
+			{
+				Rule: admissionregistrationv1.Rule{
+					APIGroups:   []string{corev1.GroupName},
+					APIVersions: []string{corev1.SchemeGroupVersion.Version},
+					Resources:   []string{"namespaces"},
+					Scope:       &scope,
+				},
+				Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Update},
+			},
+		},
+		SideEffects:    &sideEffects,
+		TimeoutSeconds: pointer.Int32(config.timeoutSeconds()),
+	}
 }
@@ -0,0 +1,620 @@
+package certs
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+func TestEnsureWebhookConfigurationFor_Overrides(t *testing.T) {
+	fail := admissionregistrationv1.Fail
+	equivalent := admissionregistrationv1.Equivalent
+	exact := admissionregistrationv1.Exact
+	ifNeeded := admissionregistrationv1.IfNeededReinvocationPolicy
+	base := WebhookConfig{ServiceName: "warden-admission", ServiceNamespace: "kyma-system", CABundel: []byte("ca")}
+	ensure := func(t *testing.T, client ctrlclient.Client, config WebhookConfig) (admissionregistrationv1.MutatingWebhook, admissionregistrationv1.ValidatingWebhook) {
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, MutatingWebhook))
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, ValidatingWebHook))
+		mutating := &admissionregistrationv1.MutatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: DefaultingWebhookName}, mutating))
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, validating))
+		return mutating.Webhooks[0], validating.Webhooks[0]
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		mutating, validating := ensure(t, newFakeClient(), base)
+
+		require.Equal(t, int32(WebhookTimeout), *mutating.TimeoutSeconds)
+		require.Equal(t, admissionregistrationv1.Ignore, *mutating.FailurePolicy)
+		require.Equal(t, admissionregistrationv1.Equivalent, *mutating.MatchPolicy)
+		require.Equal(t, admissionregistrationv1.NeverReinvocationPolicy, *mutating.ReinvocationPolicy)
+		require.Equal(t, int32(WebhookTimeout), *validating.TimeoutSeconds)
+		require.Equal(t, admissionregistrationv1.Ignore, *validating.FailurePolicy)
+		require.Equal(t, admissionregistrationv1.Equivalent, *validating.MatchPolicy)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		config := base
+		config.TimeoutSeconds = pointer.Int32(30)
+
+		mutating, validating := ensure(t, newFakeClient(), config)
+
+		require.Equal(t, int32(30), *mutating.TimeoutSeconds)
+		require.Equal(t, int32(30), *validating.TimeoutSeconds)
+	})
+
+	t.Run("failure policy", func(t *testing.T) {
+		config := base
+		config.FailurePolicy = &fail
+
+		mutating, validating := ensure(t, newFakeClient(), config)
+
+		require.Equal(t, fail, *mutating.FailurePolicy)
+		require.Equal(t, fail, *validating.FailurePolicy)
+	})
+
+	t.Run("match policy", func(t *testing.T) {
+		for _, policy := range []admissionregistrationv1.MatchPolicyType{exact, equivalent} {
+			config := base
+			config.MatchPolicy = &policy
+
+			mutating, validating := ensure(t, newFakeClient(), config)
+
+			require.Equal(t, policy, *mutating.MatchPolicy)
+			require.Equal(t, policy, *validating.MatchPolicy)
+		}
+	})
+
+	t.Run("configurations of older versions get the default match policy", func(t *testing.T) {
+		//GIVEN
+		previous := base
+		previous.MatchPolicy = &exact
+		previous.ValidateWorkloads = true
+		client := newFakeClient(createMutatingWebhookConfiguration(previous), createValidatingWebhookConfiguration(previous))
+		config := base
+		config.ValidateWorkloads = true
+
+		//WHEN
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, MutatingWebhook))
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, ValidatingWebHook))
+
+		//THEN
+		mutating := &admissionregistrationv1.MutatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: DefaultingWebhookName}, mutating))
+		require.Equal(t, equivalent, *mutating.Webhooks[0].MatchPolicy)
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, validating))
+		for _, webhook := range validating.Webhooks {
+			require.Equal(t, equivalent, *webhook.MatchPolicy)
+		}
+	})
+
+	t.Run("explicit Exact is reconciled", func(t *testing.T) {
+		//GIVEN
+		client := newFakeClient()
+		config := base
+		config.MatchPolicy = &exact
+		ensure(t, client, config)
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, validating))
+		validating.Webhooks[0].MatchPolicy = &equivalent
+		require.NoError(t, client.Update(context.TODO(), validating))
+
+		//WHEN
+		_, v := ensure(t, client, config)
+
+		//THEN
+		require.Equal(t, exact, *v.MatchPolicy)
+	})
+
+	t.Run("pod rules are namespaced by default", func(t *testing.T) {
+		mutating, validating := ensure(t, newFakeClient(), base)
+
+		require.Equal(t, admissionregistrationv1.NamespacedScope, *mutating.Rules[0].Scope)
+		for _, rule := range validating.Rules {
+			require.Equal(t, admissionregistrationv1.NamespacedScope, *rule.Scope)
+		}
+	})
+
+	t.Run("scope", func(t *testing.T) {
+		config := base
+		allScopes := admissionregistrationv1.AllScopes
+		config.Scope = &allScopes
+
+		mutating, validating := ensure(t, newFakeClient(), config)
+
+		require.Equal(t, allScopes, *mutating.Rules[0].Scope)
+		for _, rule := range validating.Rules {
+			require.Equal(t, allScopes, *rule.Scope)
+		}
+	})
+
+	t.Run("configurations of older versions get the namespaced scope", func(t *testing.T) {
+		//GIVEN
+		previous := base
+		allScopes := admissionregistrationv1.AllScopes
+		previous.Scope = &allScopes
+		client := newFakeClient(createMutatingWebhookConfiguration(previous), createValidatingWebhookConfiguration(previous))
+
+		//WHEN
+		mutating, validating := ensure(t, client, base)
+
+		//THEN
+		require.Equal(t, admissionregistrationv1.NamespacedScope, *mutating.Rules[0].Scope)
+		for _, rule := range validating.Rules {
+			require.Equal(t, admissionregistrationv1.NamespacedScope, *rule.Scope)
+		}
+	})
+
+	t.Run("reinvocation policy", func(t *testing.T) {
+		config := base
+		config.ReinvocationPolicy = &ifNeeded
+
+		mutating, _ := ensure(t, newFakeClient(), config)
+
+		require.Equal(t, ifNeeded, *mutating.ReinvocationPolicy)
+	})
+
+	t.Run("service port", func(t *testing.T) {
+		config := base
+		config.Port = pointer.Int32(8443)
+
+		mutating, validating := ensure(t, newFakeClient(), config)
+
+		require.Equal(t, int32(8443), *mutating.ClientConfig.Service.Port)
+		require.Equal(t, int32(8443), *validating.ClientConfig.Service.Port)
+	})
+
+	t.Run("validating webhook observes DELETE", func(t *testing.T) {
+		config := base
+		config.ValidatingOperations = []admissionregistrationv1.OperationType{
+			admissionregistrationv1.Create, admissionregistrationv1.Update, admissionregistrationv1.Delete,
+		}
+
+		mutating, validating := ensure(t, newFakeClient(), config)
+
+		require.Equal(t, []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update}, mutating.Rules[0].Operations)
+		require.Equal(t, config.ValidatingOperations, validating.Rules[0].Operations)
+	})
+
+	t.Run("operations without UPDATE", func(t *testing.T) {
+		config := base
+		config.MutatingOperations = []admissionregistrationv1.OperationType{admissionregistrationv1.Create}
+		config.ValidatingOperations = []admissionregistrationv1.OperationType{admissionregistrationv1.Create}
+
+		mutating, validating := ensure(t, newFakeClient(), config)
+
+		require.Equal(t, []admissionregistrationv1.OperationType{admissionregistrationv1.Create}, mutating.Rules[0].Operations)
+		require.Equal(t, []admissionregistrationv1.OperationType{admissionregistrationv1.Create}, validating.Rules[0].Operations)
+	})
+
+	t.Run("changed overrides are applied to existing configurations", func(t *testing.T) {
+		//GIVEN
+		client := newFakeClient()
+		ensure(t, client, base)
+		config := base
+		config.TimeoutSeconds = pointer.Int32(5)
+		config.FailurePolicy = &fail
+		config.ValidatingOperations = []admissionregistrationv1.OperationType{admissionregistrationv1.OperationAll}
+
+		//WHEN
+		mutating, validating := ensure(t, client, config)
+
+		//THEN
+		require.Equal(t, int32(5), *mutating.TimeoutSeconds)
+		require.Equal(t, fail, *mutating.FailurePolicy)
+		require.Equal(t, int32(5), *validating.TimeoutSeconds)
+		require.Equal(t, fail, *validating.FailurePolicy)
+		require.Equal(t, []admissionregistrationv1.OperationType{admissionregistrationv1.OperationAll}, validating.Rules[0].Operations)
+	})
+
+	t.Run("runtime changes are reverted", func(t *testing.T) {
+		//GIVEN
+		client := newFakeClient()
+		ensure(t, client, base)
+		mutating := &admissionregistrationv1.MutatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: DefaultingWebhookName}, mutating))
+		mutating.Webhooks[0].TimeoutSeconds = pointer.Int32(1)
+		mutating.Webhooks[0].FailurePolicy = &fail
+		require.NoError(t, client.Update(context.TODO(), mutating))
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, validating))
+		validating.Webhooks[0].MatchPolicy = &exact
+		validating.Webhooks[0].ClientConfig.Service.Port = pointer.Int32(9443)
+		validating.Webhooks[0].Rules[0].Operations = append(validating.Webhooks[0].Rules[0].Operations, admissionregistrationv1.Delete)
+		require.NoError(t, client.Update(context.TODO(), validating))
+
+		//WHEN
+		m, v := ensure(t, client, base)
+
+		//THEN
+		require.Equal(t, int32(WebhookTimeout), *m.TimeoutSeconds)
+		require.Equal(t, admissionregistrationv1.Ignore, *m.FailurePolicy)
+		require.Equal(t, admissionregistrationv1.Equivalent, *v.MatchPolicy)
+		require.Equal(t, int32(DefaultServicePort), *v.ClientConfig.Service.Port)
+		require.Equal(t, []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update}, v.Rules[0].Operations)
+	})
+}
+
+func TestEnsureWebhookConfigurationFor_ClientConfig(t *testing.T) {
+	ensure := func(t *testing.T, config WebhookConfig) (*admissionregistrationv1.MutatingWebhookConfiguration, *admissionregistrationv1.ValidatingWebhookConfiguration) {
+		client := newFakeClient()
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, MutatingWebhook))
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, ValidatingWebHook))
+		mutating := &admissionregistrationv1.MutatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: DefaultingWebhookName}, mutating))
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, validating))
+		return mutating, validating
+	}
+
+	t.Run("service", func(t *testing.T) {
+		//GIVEN
+		config := WebhookConfig{ServiceName: "warden-admission", ServiceNamespace: "kyma-system", CABundel: []byte("ca")}
+
+		//WHEN
+		mutating, validating := ensure(t, config)
+
+		//THEN
+		require.Nil(t, mutating.Webhooks[0].ClientConfig.URL)
+		require.Equal(t, &admissionregistrationv1.ServiceReference{
+			Namespace: "kyma-system",
+			Name:      "warden-admission",
+			Path:      pointer.String("/defaulting/pods"),
+			Port:      pointer.Int32(DefaultServicePort),
+		}, mutating.Webhooks[0].ClientConfig.Service)
+		require.Equal(t, []byte("ca"), mutating.Webhooks[0].ClientConfig.CABundle)
+		for _, webhook := range validating.Webhooks {
+			require.Nil(t, webhook.ClientConfig.URL)
+			require.Equal(t, "warden-admission", webhook.ClientConfig.Service.Name)
+		}
+	})
+
+	t.Run("url", func(t *testing.T) {
+		//GIVEN
+		config := WebhookConfig{URL: "https://host.docker.internal:9443/", ServiceNamespace: "kyma-system", CABundel: []byte("ca"), ValidateWorkloads: true}
+
+		//WHEN
+		mutating, validating := ensure(t, config)
+
+		//THEN
+		require.Nil(t, mutating.Webhooks[0].ClientConfig.Service)
+		require.Equal(t, "https://host.docker.internal:9443/defaulting/pods", *mutating.Webhooks[0].ClientConfig.URL)
+		require.Equal(t, []byte("ca"), mutating.Webhooks[0].ClientConfig.CABundle)
+		require.Len(t, validating.Webhooks, 3)
+		for _, webhook := range validating.Webhooks {
+			require.Nil(t, webhook.ClientConfig.Service)
+			require.Equal(t, []byte("ca"), webhook.ClientConfig.CABundle)
+		}
+		require.Equal(t, "https://host.docker.internal:9443"+PodValidationPath, *validating.Webhooks[0].ClientConfig.URL)
+		require.Equal(t, "https://host.docker.internal:9443/validation/namespaces", *validating.Webhooks[1].ClientConfig.URL)
+		require.Equal(t, "https://host.docker.internal:9443/validation/workloads", *validating.Webhooks[2].ClientConfig.URL)
+	})
+}
+
+func TestWebhookConfig_Validate(t *testing.T) {
+	unknownFailure := admissionregistrationv1.FailurePolicyType("Retry")
+	unknownMatch := admissionregistrationv1.MatchPolicyType("Fuzzy")
+	unknownReinvocation := admissionregistrationv1.ReinvocationPolicyType("Always")
+	clusterScope := admissionregistrationv1.ClusterScope
+	fail := admissionregistrationv1.Fail
+
+	tests := []struct {
+		name        string
+		config      WebhookConfig
+		expectedErr string
+	}{
+		{name: "no overrides"},
+		{name: "valid overrides", config: WebhookConfig{TimeoutSeconds: pointer.Int32(1), FailurePolicy: &fail}},
+		{
+			name:        "port out of range",
+			config:      WebhookConfig{Port: pointer.Int32(70000)},
+			expectedErr: "webhook service port 70000 must be between 1 and 65535",
+		},
+		{name: "url", config: WebhookConfig{URL: "https://host.docker.internal:9443"}},
+		{
+			name:        "url and service",
+			config:      WebhookConfig{URL: "https://host.docker.internal:9443", ServiceName: "warden-admission"},
+			expectedErr: "webhook URL https://host.docker.internal:9443 can't be used together with the service",
+		},
+		{
+			name:        "url and service port",
+			config:      WebhookConfig{URL: "https://host.docker.internal:9443", Port: pointer.Int32(443)},
+			expectedErr: "webhook URL https://host.docker.internal:9443 can't be used together with the service",
+		},
+		{
+			name:        "url without https",
+			config:      WebhookConfig{URL: "http://localhost:9443"},
+			expectedErr: "webhook URL http://localhost:9443 must use the https scheme",
+		},
+		{
+			name:        "url with query",
+			config:      WebhookConfig{URL: "https://localhost:9443?debug=true"},
+			expectedErr: "webhook URL https://localhost:9443?debug=true can't have the user, query or fragment",
+		},
+		{
+			name:        "timeout too short",
+			config:      WebhookConfig{TimeoutSeconds: pointer.Int32(0)},
+			expectedErr: "webhook timeout 0s must be between 1 and 30 seconds",
+		},
+		{
+			name:        "timeout too long",
+			config:      WebhookConfig{TimeoutSeconds: pointer.Int32(31)},
+			expectedErr: "webhook timeout 31s must be between 1 and 30 seconds",
+		},
+		{
+			name:        "unknown failure policy",
+			config:      WebhookConfig{FailurePolicy: &unknownFailure},
+			expectedErr: "unsupported webhook failure policy Retry, supported are Ignore and Fail",
+		},
+		{
+			name:        "unknown match policy",
+			config:      WebhookConfig{MatchPolicy: &unknownMatch},
+			expectedErr: "unsupported webhook match policy Fuzzy, supported are Exact and Equivalent",
+		},
+		{
+			name:        "unknown reinvocation policy",
+			config:      WebhookConfig{ReinvocationPolicy: &unknownReinvocation},
+			expectedErr: "unsupported webhook reinvocation policy Always, supported are Never and IfNeeded",
+		},
+		{
+			name:        "cluster scope",
+			config:      WebhookConfig{Scope: &clusterScope},
+			expectedErr: "unsupported webhook rule scope Cluster, supported are Namespaced and *",
+		},
+		{
+			name:        "unknown operation",
+			config:      WebhookConfig{ValidatingOperations: []admissionregistrationv1.OperationType{"create", "PATCH"}},
+			expectedErr: "[unsupported validation webhook operation create, supported are [CREATE UPDATE DELETE *], unsupported validation webhook operation PATCH, supported are [CREATE UPDATE DELETE *]]",
+		},
+		{
+			name:        "DELETE in the defaulting webhook",
+			config:      WebhookConfig{MutatingOperations: []admissionregistrationv1.OperationType{admissionregistrationv1.Delete}},
+			expectedErr: "unsupported defaulting webhook operation DELETE, supported are [CREATE UPDATE]",
+		},
+		{
+			name:        "no operations",
+			config:      WebhookConfig{ValidatingOperations: []admissionregistrationv1.OperationType{}},
+			expectedErr: "validation webhook must have at least one operation",
+		},
+		{
+			name:        "duplicated operation",
+			config:      WebhookConfig{MutatingOperations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Create}},
+			expectedErr: "defaulting webhook operation CREATE is duplicated",
+		},
+		{
+			name:   "certificate SANs",
+			config: WebhookConfig{ClusterDomain: "cluster.example", ExtraSANs: []string{"warden.example.com", "*.warden.example.com"}},
+		},
+		{
+			name:        "CA bundle path and secret",
+			config:      WebhookConfig{CABundlePath: "/etc/warden/ca.crt", CABundleSecret: "warden-admission-cert"},
+			expectedErr: "CA bundle path /etc/warden/ca.crt can't be used together with the CA bundle secret or cert-manager",
+		},
+		{
+			name:        "invalid cluster domain",
+			config:      WebhookConfig{ClusterDomain: "Cluster_Local"},
+			expectedErr: "invalid cluster domain Cluster_Local: a lowercase RFC 1123 subdomain must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character (e.g. 'example.com', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?(\\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*')",
+		},
+		{
+			name:        "invalid extra SAN",
+			config:      WebhookConfig{ExtraSANs: []string{"warden..example.com"}},
+			expectedErr: "invalid extra SAN warden..example.com: a lowercase RFC 1123 subdomain must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character (e.g. 'example.com', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?(\\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*')",
+		},
+		{
+			name:        "all errors are reported",
+			config:      WebhookConfig{TimeoutSeconds: pointer.Int32(60), MatchPolicy: &unknownMatch},
+			expectedErr: "[webhook timeout 60s must be between 1 and 30 seconds, unsupported webhook match policy Fuzzy, supported are Exact and Equivalent]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+
+			if tt.expectedErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.expectedErr)
+		})
+	}
+
+	t.Run("invalid config isn't applied", func(t *testing.T) {
+		client := newFakeClient()
+		config := WebhookConfig{ServiceName: "warden-admission", ServiceNamespace: "kyma-system", TimeoutSeconds: pointer.Int32(45)}
+
+		err := EnsureWebhookConfigurationFor(context.TODO(), client, config, MutatingWebhook)
+
+		require.ErrorContains(t, err, "invalid webhook configuration: webhook timeout 45s must be between 1 and 30 seconds")
+		list := &admissionregistrationv1.MutatingWebhookConfigurationList{}
+		require.NoError(t, client.List(context.TODO(), list))
+		require.Empty(t, list.Items)
+	})
+}
+
+func TestEnsureWebhookConfigurationFor_NoopReconcile(t *testing.T) {
+	config := WebhookConfig{ServiceName: "warden-admission", ServiceNamespace: "kyma-system", CABundel: []byte("ca"), Version: "0.10.0"}
+
+	//GIVEN
+	client := &writeCounter{Client: &serverDefaultsClient{Client: newFakeClient()}}
+	require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, MutatingWebhook))
+	require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, ValidatingWebHook))
+	client.writes = 0
+
+	//WHEN
+	require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, MutatingWebhook))
+	require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, ValidatingWebHook))
+
+	//THEN
+	require.Zero(t, client.writes)
+}
+
+func TestEnsureWebhookConfigurationFor_NoopReconcileAfterDefaulting(t *testing.T) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		t.Skip("KUBEBUILDER_ASSETS isn't set, envtest can't start the API server")
+	}
+	testEnv := &envtest.Environment{}
+	cfg, err := testEnv.Start()
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, testEnv.Stop())
+	}()
+	apiClient, err := ctrlclient.New(cfg, ctrlclient.Options{Scheme: scheme.Scheme})
+	require.NoError(t, err)
+	client := &writeCounter{Client: apiClient}
+	config := WebhookConfig{ServiceName: "warden-admission", ServiceNamespace: "kyma-system", CABundel: []byte("ca")}
+
+	//GIVEN
+	require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, MutatingWebhook))
+	require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, ValidatingWebHook))
+	mutating := &admissionregistrationv1.MutatingWebhookConfiguration{}
+	require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: DefaultingWebhookName}, mutating))
+	client.writes = 0
+
+	//WHEN
+	require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, MutatingWebhook))
+	require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, ValidatingWebHook))
+
+	//THEN
+	require.Zero(t, client.writes)
+	after := &admissionregistrationv1.MutatingWebhookConfiguration{}
+	require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: DefaultingWebhookName}, after))
+	require.Equal(t, mutating.ResourceVersion, after.ResourceVersion)
+}
+
+func TestEnsureWebhookConfigurationFor_ServerSideApply(t *testing.T) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		t.Skip("KUBEBUILDER_ASSETS isn't set, envtest can't start the API server")
+	}
+	testEnv := &envtest.Environment{}
+	cfg, err := testEnv.Start()
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, testEnv.Stop())
+	}()
+	client, err := ctrlclient.New(cfg, ctrlclient.Options{Scheme: scheme.Scheme})
+	require.NoError(t, err)
+	config := WebhookConfig{ServiceName: "warden-admission", ServiceNamespace: "kyma-system", CABundel: []byte("ca")}
+
+	//GIVEN
+	require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, MutatingWebhook))
+	mutating := &admissionregistrationv1.MutatingWebhookConfiguration{}
+	require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: DefaultingWebhookName}, mutating))
+	mutating.Labels["argocd.argoproj.io/instance"] = "warden"
+	mutating.Webhooks[0].TimeoutSeconds = pointer.Int32(2)
+	require.NoError(t, client.Update(context.TODO(), mutating, ctrlclient.FieldOwner("argocd")))
+
+	//WHEN
+	require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, MutatingWebhook))
+
+	//THEN
+	require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: DefaultingWebhookName}, mutating))
+	require.Equal(t, "warden", mutating.Labels["argocd.argoproj.io/instance"])
+	require.Equal(t, ManagedBy, mutating.Labels[ManagedByLabel])
+	require.Equal(t, int32(WebhookTimeout), *mutating.Webhooks[0].TimeoutSeconds)
+	var managers []string
+	for _, entry := range mutating.ManagedFields {
+		managers = append(managers, entry.Manager)
+	}
+	require.Contains(t, managers, FieldManager)
+}
+
+// applyClient handles server-side apply which the fake client doesn't support. The applied object is created
+// or merged into the stored one, that's what apply does for the configurations which only Warden manages.
+type applyClient struct {
+	ctrlclient.Client
+}
+
+func newFakeClient(objects ...ctrlclient.Object) ctrlclient.Client {
+	return &applyClient{Client: fake.NewClientBuilder().WithObjects(objects...).Build()}
+}
+
+func (c *applyClient) Patch(ctx context.Context, obj ctrlclient.Object, patch ctrlclient.Patch, opts ...ctrlclient.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+	data, err := patch.Data(obj)
+	if err != nil {
+		return err
+	}
+	var dryRun []string
+	for _, opt := range opts {
+		if opt == ctrlclient.DryRunAll {
+			dryRun = []string{metav1.DryRunAll}
+		}
+	}
+	live := obj.DeepCopyObject().(ctrlclient.Object)
+	if err := c.Client.Get(ctx, ctrlclient.ObjectKeyFromObject(obj), live); err != nil {
+		if apierrors.IsNotFound(err) {
+			return c.Client.Create(ctx, obj, &ctrlclient.CreateOptions{DryRun: dryRun})
+		}
+		return err
+	}
+	return c.Client.Patch(ctx, obj, ctrlclient.RawPatch(types.MergePatchType, data), &ctrlclient.PatchOptions{DryRun: dryRun})
+}
+
+// writeCounter counts the writes of webhook configurations.
+type writeCounter struct {
+	ctrlclient.Client
+	writes int
+}
+
+func (c *writeCounter) Create(ctx context.Context, obj ctrlclient.Object, opts ...ctrlclient.CreateOption) error {
+	c.writes++
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *writeCounter) Update(ctx context.Context, obj ctrlclient.Object, opts ...ctrlclient.UpdateOption) error {
+	c.writes++
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *writeCounter) Patch(ctx context.Context, obj ctrlclient.Object, patch ctrlclient.Patch, opts ...ctrlclient.PatchOption) error {
+	c.writes++
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+// serverDefaultsClient returns empty instead of nil maps and slices like the API server does for defaulted fields.
+type serverDefaultsClient struct {
+	ctrlclient.Client
+}
+
+func (c *serverDefaultsClient) Get(ctx context.Context, key ctrlclient.ObjectKey, obj ctrlclient.Object, opts ...ctrlclient.GetOption) error {
+	if err := c.Client.Get(ctx, key, obj, opts...); err != nil {
+		return err
+	}
+	switch configuration := obj.(type) {
+	case *admissionregistrationv1.MutatingWebhookConfiguration:
+		for i := range configuration.Webhooks {
+			defaultSelector(configuration.Webhooks[i].ObjectSelector)
+			defaultSelector(configuration.Webhooks[i].NamespaceSelector)
+		}
+	case *admissionregistrationv1.ValidatingWebhookConfiguration:
+		for i := range configuration.Webhooks {
+			defaultSelector(configuration.Webhooks[i].ObjectSelector)
+			defaultSelector(configuration.Webhooks[i].NamespaceSelector)
+		}
+	}
+	return nil
+}
+
+func defaultSelector(selector *metav1.LabelSelector) {
+	if selector.MatchLabels == nil {
+		selector.MatchLabels = map[string]string{}
+	}
+	if selector.MatchExpressions == nil {
+		selector.MatchExpressions = []metav1.LabelSelectorRequirement{}
+	}
+}
@@ -0,0 +1,70 @@
+package certs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kyma-project/warden/internal/admission"
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestEnsureWebhookConfigurationFor_Workloads(t *testing.T) {
+	base := WebhookConfig{ServiceName: "warden-admission", ServiceNamespace: "kyma-system", CABundel: []byte("ca")}
+
+	t.Run("pods and namespaces only by default", func(t *testing.T) {
+		client := newFakeClient()
+
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, base, ValidatingWebHook))
+
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, validating))
+		require.Len(t, validating.Webhooks, 2)
+	})
+
+	t.Run("workload rules", func(t *testing.T) {
+		//GIVEN
+		client := newFakeClient()
+		config := base
+		config.ValidateWorkloads = true
+
+		//WHEN
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, ValidatingWebHook))
+
+		//THEN
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, validating))
+		require.Len(t, validating.Webhooks, 3)
+		require.Equal(t, ValidationWebhookName, validating.Webhooks[0].Name)
+		workloads := validating.Webhooks[2]
+		require.Equal(t, WorkloadValidationWebhookName, workloads.Name)
+		require.Equal(t, "/validation/workloads", admission.WorkloadValidationPath)
+		require.Equal(t, admission.WorkloadValidationPath, *workloads.ClientConfig.Service.Path)
+		require.Equal(t, []string{"apps"}, workloads.Rules[0].APIGroups)
+		require.Equal(t, []string{"v1"}, workloads.Rules[0].APIVersions)
+		require.Equal(t, []string{"deployments", "statefulsets", "daemonsets"}, workloads.Rules[0].Resources)
+		require.Equal(t, []string{"batch"}, workloads.Rules[1].APIGroups)
+		require.Equal(t, []string{"v1"}, workloads.Rules[1].APIVersions)
+		require.Equal(t, []string{"jobs", "cronjobs"}, workloads.Rules[1].Resources)
+		require.Equal(t, validating.Webhooks[0].NamespaceSelector, workloads.NamespaceSelector)
+	})
+
+	t.Run("disabling removes the workload webhook", func(t *testing.T) {
+		//GIVEN
+		client := newFakeClient()
+		config := base
+		config.ValidateWorkloads = true
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, config, ValidatingWebHook))
+
+		//WHEN
+		require.NoError(t, EnsureWebhookConfigurationFor(context.TODO(), client, base, ValidatingWebHook))
+
+		//THEN
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(t, client.Get(context.TODO(), types.NamespacedName{Name: ValidationWebhookName}, validating))
+		require.Len(t, validating.Webhooks, 2)
+		require.Equal(t, ValidationWebhookName, validating.Webhooks[0].Name)
+		require.Equal(t, NamespaceValidationWebhookName, validating.Webhooks[1].Name)
+	})
+}
@@ -15,3 +15,6 @@ const (
 	//Reject is used to pass status between webhooks
 	ValidationStatusReject = "reject"
 )
+
+// PodValidationRejectReasonAnnotation passes the reasons of the rejection, which are shown to the user, between webhooks.
+const PodValidationRejectReasonAnnotation = "pods.warden.kyma-project.io/reject-reason"